@@ -8,4 +8,21 @@ import (
 type Context struct {
 	echo.Context
 	Kommons *kommons.Client
+
+	// User is the authenticated user making the request.
+	// It's nil when authentication is disabled.
+	User *User
+
+	// Tenant the request is scoped to.
+	// It's empty when tenancy is disabled.
+	Tenant string
+}
+
+// User is an authenticated identity
+type User struct {
+	Name   string   `json:"name"`
+	Groups []string `json:"groups,omitempty"`
+
+	// Tenant is the tenant the credentials are bound to, if any
+	Tenant string `json:"tenant,omitempty"`
 }
@@ -0,0 +1,35 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// AlibabaSLSBackendConfig searches the logstores of Alibaba Cloud Log Service with the GetLogs api, returning a result per log.
+// The project and logstore templates route the searches by their params, e.g. {{index .Labels "logstore"}},
+// and the fields of the logs other than the message are returned as labels.
+// +kubebuilder:object:generate=true
+type AlibabaSLSBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Endpoint of the region of the projects, e.g. cn-hangzhou.log.aliyuncs.com, or its internal endpoint
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+
+	// AccessKeyID and AccessKeySecret of the RAM user with the log:GetLogStoreLogs permission on the logstores
+	AccessKeyID     *kommons.EnvVar `yaml:"accessKeyID,omitempty" json:"accessKeyID,omitempty"`
+	AccessKeySecret *kommons.EnvVar `yaml:"accessKeySecret,omitempty" json:"accessKeySecret,omitempty"`
+	// SecurityToken of the temporary STS credentials, with the access key of the credentials
+	SecurityToken *kommons.EnvVar `yaml:"securityToken,omitempty" json:"securityToken,omitempty"`
+
+	// Project is the template of the project searched, rendered with the search params
+	Project string `yaml:"project" json:"project"`
+
+	// Logstore is the template of the logstore searched, rendered with the search params
+	Logstore string `yaml:"logstore" json:"logstore"`
+
+	// Query is the template of the search statement, rendered with the search params, e.g. level: ERROR and {{.Query}}.
+	// The query of the search is passed through as search statement when it's empty.
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+
+	// MessageField of the logs. Defaults to content, or message.
+	MessageField string `yaml:"messageField,omitempty" json:"messageField,omitempty"`
+}
@@ -0,0 +1,81 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// ArchiveObjects selects the log objects of a bucket searched, e.g. the cold archive of the logs after their retention expired.
+// +kubebuilder:object:generate=true
+type ArchiveObjects struct {
+	// Prefix of the keys of the objects, a template rendered with the search params, e.g. pods/{{.Id}}/
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// KeyTimeFormat is the layout of the time encoded in the keys, e.g. 2006/01/02 or dt=2006-01-02/hour=15,
+	// with the reference time of the go time package. The objects outside of the time range of the search are skipped,
+	// as are the objects last modified before its start.
+	KeyTimeFormat string `yaml:"keyTimeFormat,omitempty" json:"keyTimeFormat,omitempty"`
+	// KeyTimePrefix is true when the keys start with their time after the prefix, e.g. blob/2006/01/02/1504 in the $logs containers.
+	// The objects are then listed by the prefixes of the days of the search, instead of listing every object of the prefix.
+	KeyTimePrefix bool `yaml:"keyTimePrefix,omitempty" json:"keyTimePrefix,omitempty"`
+
+	// Format of the lines, text or json for the newline delimited json objects. Defaults to text.
+	Format string `yaml:"format,omitempty" json:"format,omitempty"`
+	// Fields of the json objects used as the timestamp and message, default to timestamp and message.
+	// The nested fields are separated by dots, e.g. jsonPayload.message, and the other fields are labels.
+	Fields ElasticSearchFields `yaml:"fields,omitempty" json:"fields,omitempty"`
+}
+
+// S3ArchiveBackendConfig searches the log objects of an S3 bucket, optionally gzipped, returning a result per line
+// +kubebuilder:object:generate=true
+type S3ArchiveBackendConfig struct {
+	CommonBackend  `json:",inline" yaml:",inline"`
+	ArchiveObjects `json:",inline" yaml:",inline"`
+
+	Bucket string `yaml:"bucket" json:"bucket"`
+
+	// Endpoint overrides the S3 endpoint, e.g. for MinIO
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	// UsePathStyle addresses the bucket in the path instead of the host, required by MinIO
+	UsePathStyle bool `yaml:"usePathStyle,omitempty" json:"usePathStyle,omitempty"`
+
+	// Auth falls back to the default credential chain when no keys are set
+	Auth      AWSAuthentication `yaml:"auth,omitempty" json:"auth,omitempty"`
+	Namespace string            `yaml:"namespace,omitempty" json:"namespace,omitempty"` // Namespace to search the kommons.EnvVar in
+}
+
+// GCSArchiveBackendConfig searches the log objects of a Google Cloud Storage bucket, optionally gzipped, returning a result per line
+// +kubebuilder:object:generate=true
+type GCSArchiveBackendConfig struct {
+	CommonBackend  `json:",inline" yaml:",inline"`
+	ArchiveObjects `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Cloud Storage json api, the url defaults to https://storage.googleapis.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Credentials is the json key of the service account. Defaults to the application default credentials, e.g. the workload identity.
+	Credentials *kommons.EnvVar `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+
+	Bucket string `yaml:"bucket" json:"bucket"`
+}
+
+// AzureBlobArchiveBackendConfig searches the log objects of an Azure Blob Storage container, optionally gzipped, returning a result per line,
+// e.g. the storage analytics logs of the $logs container.
+// The requests are authenticated with the SAS token, or the service principal, or else the managed identity.
+// +kubebuilder:object:generate=true
+type AzureBlobArchiveBackendConfig struct {
+	CommonBackend  `json:",inline" yaml:",inline"`
+	ArchiveObjects `json:",inline" yaml:",inline"`
+
+	// Account of the container, its url defaults to https://<account>.blob.core.windows.net
+	Account   string `yaml:"account" json:"account"`
+	Container string `yaml:"container" json:"container"`
+	// URL of the Blob service, e.g. for the sovereign clouds or Azurite
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+
+	// SASToken is the shared access signature with the read and list permissions of the container
+	SASToken *kommons.EnvVar `yaml:"sasToken,omitempty" json:"sasToken,omitempty"`
+	// Auth is the service principal with the Storage Blob Data Reader role
+	Auth *AzureAuthentication `yaml:"auth,omitempty" json:"auth,omitempty"`
+	// ManagedIdentityClientID selects a user assigned managed identity, the system assigned identity is used otherwise
+	ManagedIdentityClientID string `yaml:"managedIdentityClientID,omitempty" json:"managedIdentityClientID,omitempty"`
+
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+}
@@ -0,0 +1,43 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// AzureAuthentication is the service principal authenticating with the client credentials flow of Azure AD
+// +kubebuilder:object:generate=true
+type AzureAuthentication struct {
+	TenantID     string          `yaml:"tenantID" json:"tenantID"`
+	ClientID     *kommons.EnvVar `yaml:"clientID,omitempty" json:"clientID,omitempty"`
+	ClientSecret *kommons.EnvVar `yaml:"clientSecret,omitempty" json:"clientSecret,omitempty"`
+
+	// AuthorityURL of Azure AD, e.g. for the sovereign clouds. Defaults to https://login.microsoftonline.com
+	AuthorityURL string `yaml:"authorityURL,omitempty" json:"authorityURL,omitempty"`
+}
+
+// AzureLogAnalyticsBackendConfig runs KQL queries against a Log Analytics workspace with the Log Analytics Query api,
+// returning a result per row. The columns other than the timestamp, message and id columns are returned as labels.
+// +kubebuilder:object:generate=true
+type AzureLogAnalyticsBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Log Analytics api, the url defaults to https://api.loganalytics.io.
+	// The requests are authenticated with Auth instead of a token.
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Auth is the service principal with the Log Analytics Reader role on the workspace
+	Auth AzureAuthentication `yaml:"auth" json:"auth"`
+
+	// WorkspaceID is the id of the workspace, not its name
+	WorkspaceID string `yaml:"workspaceID" json:"workspaceID"`
+
+	// Query is the template of the KQL query, rendered with the search params,
+	// e.g. ContainerLogV2 | where LogMessage contains "{{.Query}}"
+	Query string `yaml:"query" json:"query"`
+
+	// TimestampColumn holds the time of the rows. Defaults to TimeGenerated.
+	TimestampColumn string `yaml:"timestampColumn,omitempty" json:"timestampColumn,omitempty"`
+
+	// MessageColumn holds the log line of the rows. Defaults to Message.
+	MessageColumn string `yaml:"messageColumn,omitempty" json:"messageColumn,omitempty"`
+
+	// IDColumn holds the id of the rows, if any
+	IDColumn string `yaml:"idColumn,omitempty" json:"idColumn,omitempty"`
+}
@@ -0,0 +1,59 @@
+package logs
+
+import "fmt"
+
+// LogCheck is a log based check evaluated by apm-hub, e.g. on behalf of canary-checker.
+// The check fails when the number of results matching the search is out of the thresholds.
+// When no threshold is set the check fails on any result.
+type LogCheck struct {
+	SearchParams `yaml:",inline" json:",inline"`
+
+	// MinCount fails the check when fewer results are found, e.g. to detect missing heartbeats
+	MinCount *int `json:"minCount,omitempty" yaml:"minCount,omitempty"`
+
+	// MaxCount fails the check when more results are found
+	MaxCount *int `json:"maxCount,omitempty" yaml:"maxCount,omitempty"`
+
+	// Samples is the number of results returned with the check result. Defaults to 5.
+	Samples int `json:"samples,omitempty" yaml:"samples,omitempty"`
+}
+
+// LogCheckResult is the outcome of a LogCheck
+type LogCheckResult struct {
+	Pass    bool   `json:"pass"`
+	Message string `json:"message"`
+	// Count is the number of results found, up to the limit of the search
+	Count   int      `json:"count"`
+	Samples []Result `json:"samples,omitempty"`
+}
+
+// Evaluate compares the number of results to the thresholds of the check
+func (t LogCheck) Evaluate(results []Result) LogCheckResult {
+	result := LogCheckResult{Pass: true, Count: len(results)}
+
+	maxCount := t.MaxCount
+	if t.MinCount == nil && maxCount == nil {
+		maxCount = new(int)
+	}
+
+	switch {
+	case t.MinCount != nil && result.Count < *t.MinCount:
+		result.Pass = false
+		result.Message = fmt.Sprintf("found %d results, expected at least %d", result.Count, *t.MinCount)
+	case maxCount != nil && result.Count > *maxCount:
+		result.Pass = false
+		result.Message = fmt.Sprintf("found %d results, expected at most %d", result.Count, *maxCount)
+	default:
+		result.Message = fmt.Sprintf("found %d results", result.Count)
+	}
+
+	samples := t.Samples
+	if samples <= 0 {
+		samples = 5
+	}
+	if len(results) > samples {
+		results = results[:samples]
+	}
+	result.Samples = results
+	return result
+}
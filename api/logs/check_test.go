@@ -0,0 +1,38 @@
+package logs
+
+import "testing"
+
+func TestLogCheck_Evaluate(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+	results := make([]Result, 8)
+
+	tests := []struct {
+		name     string
+		check    LogCheck
+		results  []Result
+		wantPass bool
+	}{
+		{name: "no results", results: nil, wantPass: true},
+		{name: "any result fails by default", results: results[:1]},
+		{name: "under max", check: LogCheck{MaxCount: intPtr(10)}, results: results, wantPass: true},
+		{name: "over max", check: LogCheck{MaxCount: intPtr(3)}, results: results},
+		{name: "heartbeat found", check: LogCheck{MinCount: intPtr(1)}, results: results[:1], wantPass: true},
+		{name: "heartbeat missing", check: LogCheck{MinCount: intPtr(1)}},
+		{name: "in range", check: LogCheck{MinCount: intPtr(5), MaxCount: intPtr(8)}, results: results, wantPass: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.check.Evaluate(tt.results)
+			if got.Pass != tt.wantPass {
+				t.Errorf("Evaluate() = %+v, want pass %v", got, tt.wantPass)
+			}
+			if got.Count != len(tt.results) {
+				t.Errorf("Count = %d, want %d", got.Count, len(tt.results))
+			}
+			if len(got.Samples) > 5 {
+				t.Errorf("expected at most 5 samples, got %d", len(got.Samples))
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package logs
+
+// GitHubActionsBackendConfig searches the logs of the jobs of the workflow runs of GitHub Actions, e.g. of the deployments.
+// The repository is the repository label of the search, owner/name or the name of a repository of the owner,
+// or the configured repository. The searches return the logs of the jobs of the run of their run_id label, or of their id,
+// otherwise of the last run in the time range, filtered by the workflow and branch labels.
+// The lines are labelled with their job and the step running when they were written.
+// +kubebuilder:object:generate=true
+type GitHubActionsBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+
+	// HTTPConnection to the GitHub api, with a token allowed to read the actions. The url defaults to https://api.github.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Owner of the repositories, the user or the organization
+	Owner string `yaml:"owner,omitempty" json:"owner,omitempty"`
+
+	// Repository searched when the search has no repository label, owner/name or the name of a repository of the owner
+	Repository string `yaml:"repository,omitempty" json:"repository,omitempty"`
+}
+
+// GitLabBackendConfig searches the logs (traces) of the jobs of GitLab CI pipelines.
+// The project is the project label of the search, its id or path e.g. acme/shop, or the configured project.
+// The searches return the logs of the jobs of the pipeline of their pipeline_id label, or of their id,
+// otherwise of the last pipeline updated in the time range, of the ref label. The job_id label returns the logs of the job.
+// The lines are labelled with their job and their section, e.g. step_script.
+// +kubebuilder:object:generate=true
+type GitLabBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+
+	// HTTPConnection to the GitLab api, with a token allowed to read the api. The url defaults to https://gitlab.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Project searched when the search has no project label, its id or path
+	Project string `yaml:"project,omitempty" json:"project,omitempty"`
+}
+
+// JenkinsBackendConfig searches the console output of the builds of Jenkins jobs.
+// The id of the search is the full name of the job, e.g. folder/deploy, and its build label the number of the build,
+// defaulting to the last build. The searches are paginated by the offset of the progressive text api,
+// the running builds returning the offset to follow their output from.
+// The username and the api token, as password, are sent with basic auth. The searches only read,
+// so no CSRF crumb is required.
+// +kubebuilder:object:generate=true
+type JenkinsBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+
+	// HTTPConnection to Jenkins, e.g. https://jenkins.example.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Job searched when the search has no id, its full name
+	Job string `yaml:"job,omitempty" json:"job,omitempty"`
+}
@@ -0,0 +1,44 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// CloudflareBackendConfig searches the HTTP request logs of a Cloudflare zone with the Logpull api, or pushed to R2 by Logpush,
+// returning a result per request. The fields of the requests are returned as labels.
+// The query of the search filters the fields of the requests, e.g. status>=500 path=/api/*, and matches the other words in the message.
+// +kubebuilder:object:generate=true
+type CloudflareBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Cloudflare api, with an api token with the Zone Logs read permission.
+	// The url defaults to https://api.cloudflare.com/client/v4
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// ZoneID is the id of the zone whose request logs are searched
+	ZoneID string `yaml:"zoneID" json:"zoneID"`
+
+	// Fields of the requests returned, defaults to the client, request, response and ray id fields
+	Fields []string `yaml:"fields,omitempty" json:"fields,omitempty"`
+
+	// Sample is the fraction of the requests returned, e.g. 0.1. Every request is returned when empty.
+	Sample string `yaml:"sample,omitempty" json:"sample,omitempty"`
+
+	// R2 reads the requests pushed to an R2 bucket by a Logpush job instead of the Logpull api,
+	// searching further back than the retention of Logpull and over wider time ranges
+	R2 *CloudflareR2Config `yaml:"r2,omitempty" json:"r2,omitempty"`
+}
+
+// CloudflareR2Config is the R2 bucket of a Logpush job of the http requests, in the newline delimited json format
+// +kubebuilder:object:generate=true
+type CloudflareR2Config struct {
+	// AccountID of the bucket
+	AccountID string `yaml:"accountID" json:"accountID"`
+	Bucket    string `yaml:"bucket" json:"bucket"`
+	// Path of the destination of the job before its {DATE} folders, e.g. logs/http_requests
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// AccessKeyID and SecretAccessKey of an R2 api token with the object read permission on the bucket
+	AccessKeyID     *kommons.EnvVar `yaml:"accessKeyID,omitempty" json:"accessKeyID,omitempty"`
+	SecretAccessKey *kommons.EnvVar `yaml:"secretAccessKey,omitempty" json:"secretAccessKey,omitempty"`
+
+	// Endpoint of the S3 api of R2. Defaults to https://<account id>.r2.cloudflarestorage.com
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+}
@@ -0,0 +1,39 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// The providers of the managed control planes
+const (
+	ControlPlaneEKS = "eks"
+	ControlPlaneGKE = "gke"
+	ControlPlaneAKS = "aks"
+)
+
+// ControlPlaneBackendConfig searches the logs of the control plane of a managed cluster with the logging of its cloud,
+// i.e. CloudWatch for EKS, Cloud Logging for GKE and Log Analytics for AKS, with the log groups, filters and queries
+// of the apiserver, audit, scheduler, controller-manager and authenticator logs.
+// The searches select the logs of a component with the component label, and search all of them otherwise.
+// +kubebuilder:object:generate=true
+type ControlPlaneBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// Provider of the cluster, one of eks, gke or aks
+	Provider string `yaml:"provider" json:"provider"`
+	// Cluster is the name of the cluster
+	Cluster string `yaml:"cluster" json:"cluster"`
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// AWS authenticates to CloudWatch for EKS, with the region of the cluster.
+	// Defaults to the default credentials when the access keys aren't provided, e.g. of the IAM role of the service account.
+	AWS AWSAuthentication `yaml:"aws,omitempty" json:"aws,omitempty"`
+
+	// Project of the cluster for GKE
+	Project string `yaml:"project,omitempty" json:"project,omitempty"`
+	// Credentials is the json key of the service account for GKE. Defaults to the application default credentials.
+	Credentials *kommons.EnvVar `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+
+	// Azure authenticates to Log Analytics for AKS
+	Azure AzureAuthentication `yaml:"azure,omitempty" json:"azure,omitempty"`
+	// WorkspaceID of the Log Analytics workspace the diagnostic settings of the cluster send the logs to, for AKS
+	WorkspaceID string `yaml:"workspaceID,omitempty" json:"workspaceID,omitempty"`
+}
@@ -0,0 +1,93 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// SentryBackendConfig searches the issues of a Sentry project, returning a result per issue, or its events.
+// The query of the search is sent as a Sentry search query, e.g. is:unresolved level:error.
+// +kubebuilder:object:generate=true
+type SentryBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Sentry api, with an auth token. The url defaults to https://sentry.io
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Organization is the slug of the organization of the project
+	Organization string `yaml:"organization" json:"organization"`
+
+	// Project is the slug of the project, every project of the organization is searched when empty
+	Project string `yaml:"project,omitempty" json:"project,omitempty"`
+
+	// Query is the Sentry search query sent when the search has no query, e.g. is:unresolved
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+
+	// Tags are the labels of the search added to the query as tag filters, e.g. environment
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+
+	// Events returns the events of the project instead of its issues, with their tags as labels.
+	// The events of an issue are returned by the searches with its issue_id label.
+	Events bool `yaml:"events,omitempty" json:"events,omitempty"`
+}
+
+// RollbarBackendConfig searches the items of a Rollbar project, returning a result per item last seen in the time range.
+// The query of the search is sent as the text of the item search, unless it's an RQL query, e.g.
+// SELECT * FROM item_occurrence WHERE item.counter = 42, whose rows are returned instead.
+// +kubebuilder:object:generate=true
+type RollbarBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Rollbar api. The url defaults to https://api.rollbar.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// AccessToken is the project access token with the read scope
+	AccessToken *kommons.EnvVar `yaml:"accessToken,omitempty" json:"accessToken,omitempty"`
+
+	// Environment of the items searched when the search has no environment label
+	Environment string `yaml:"environment,omitempty" json:"environment,omitempty"`
+
+	// Status of the items searched, e.g. active. Every item is searched when empty
+	Status string `yaml:"status,omitempty" json:"status,omitempty"`
+}
+
+// ElasticAPMBackendConfig searches the errors and transactions of Elastic APM, returning a result per error or transaction.
+// The Application searches are of the name of the service, filtered by the environment, transaction, trace_id
+// and event labels. The cluster storing the APM data is connected to like with the elasticsearch backend.
+// +kubebuilder:object:generate=true
+type ElasticAPMBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	Address       string `yaml:"address,omitempty" json:"address,omitempty"`
+	Namespace     string `yaml:"namespace,omitempty" json:"namespace,omitempty"` // Namespace to search the kommons.EnvVar in
+
+	CloudID  *kommons.EnvVar `yaml:"cloudID,omitempty" json:"cloud_id,omitempty"`
+	APIKey   *kommons.EnvVar `yaml:"apiKey,omitempty" json:"api_key,omitempty"`
+	Username *kommons.EnvVar `yaml:"username,omitempty" json:"username,omitempty"`
+	Password *kommons.EnvVar `yaml:"password,omitempty" json:"password,omitempty"`
+
+	// ErrorIndex is the index pattern of the errors. Defaults to logs-apm.error-*,apm-*-error*
+	ErrorIndex string `yaml:"errorIndex,omitempty" json:"errorIndex,omitempty"`
+	// TransactionIndex is the index pattern of the transactions. Defaults to traces-apm*,apm-*-transaction*
+	TransactionIndex string `yaml:"transactionIndex,omitempty" json:"transactionIndex,omitempty"`
+
+	// Transactions returned alongside the errors: failure, all or none. Defaults to failure, the transactions with a failure outcome.
+	Transactions string `yaml:"transactions,omitempty" json:"transactions,omitempty"`
+}
+
+// AppDynamicsBackendConfig searches the business transactions of AppDynamics with the Analytics Events api,
+// returning a result per transaction with an error experience. The id of the search is the name of the application,
+// and the tier, node and transaction labels filter the transactions.
+// +kubebuilder:object:generate=true
+type AppDynamicsBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Events api of the region of the controller. The url defaults to https://analytics.api.appdynamics.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Account is the global account name of the controller, sent in the X-Events-API-AccountName header
+	Account string `yaml:"account" json:"account"`
+
+	// APIKey of the Events api with the query permission on the transactions, sent in the X-Events-API-Key header
+	APIKey *kommons.EnvVar `yaml:"apiKey,omitempty" json:"apiKey,omitempty"`
+
+	// Experiences of the transactions returned, e.g. ERROR, STALL or VERY_SLOW. Defaults to ERROR.
+	Experiences []string `yaml:"experiences,omitempty" json:"experiences,omitempty"`
+
+	// Query is the template of the ADQL query, rendered with the search params, replacing the query of the experiences,
+	// application, labels and query of the search, e.g. SELECT * FROM transactions WHERE application = '{{.Id}}'
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+}
@@ -0,0 +1,29 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// EventHubsBackendConfig replays the events of an Azure event hub enqueued within the time range of the search, returning a result per event,
+// e.g. the diagnostic logs exported by the diagnostic settings of the Azure resources.
+// The events are read from every partition with the consumer group, without checkpoints.
+// +kubebuilder:object:generate=true
+type EventHubsBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+
+	// ConnectionString of the namespace or of the event hub, with the Listen claim.
+	// The credentials of the environment, e.g. the workload identity, authenticate with the namespace otherwise.
+	ConnectionString *kommons.EnvVar `yaml:"connectionString,omitempty" json:"connectionString,omitempty"`
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// FullyQualifiedNamespace of the event hub, e.g. logs.servicebus.windows.net. Required without a connection string.
+	FullyQualifiedNamespace string `yaml:"fullyQualifiedNamespace,omitempty" json:"fullyQualifiedNamespace,omitempty"`
+	// EventHub is the name of the event hub, unless the connection string has its EntityPath
+	EventHub string `yaml:"eventHub,omitempty" json:"eventHub,omitempty"`
+	// ConsumerGroup reading the events. Defaults to $Default.
+	ConsumerGroup string `yaml:"consumerGroup,omitempty" json:"consumerGroup,omitempty"`
+	// Partitions to read the events of. Defaults to every partition of the event hub.
+	Partitions []string `yaml:"partitions,omitempty" json:"partitions,omitempty"`
+
+	// Timeout of the searches. Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
@@ -0,0 +1,22 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// GoogleCloudLoggingBackendConfig searches the log entries of Cloud Logging with the entries.list api, returning a result per entry.
+// The labels of the monitored resource and of the entries are returned as labels, e.g. cluster_name and component_name.
+// +kubebuilder:object:generate=true
+type GoogleCloudLoggingBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Cloud Logging api, the url defaults to https://logging.googleapis.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Credentials is the json key of the service account. Defaults to the application default credentials, e.g. the workload identity.
+	Credentials *kommons.EnvVar `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+
+	// Project of the log entries
+	Project string `yaml:"project" json:"project"`
+
+	// Filter is the template of the filter of the entries, rendered with the search params,
+	// e.g. resource.type="k8s_container" "{{.Query}}". The time range of the search is added to the filter.
+	Filter string `yaml:"filter,omitempty" json:"filter,omitempty"`
+}
@@ -0,0 +1,83 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// JournaldBackendConfig searches the systemd journal with journalctl, e.g. of the VMs running apm-hub,
+// or of the hosts of a DaemonSet mounting their journal.
+// The labels of the search filter the entries: unit, user_unit, identifier and priority (e.g. err or 0..3)
+// are passed to the flags of journalctl, the other labels of the journal fields, e.g. hostname or boot_id, are matches.
+// The values are comma separated lists. The searches are paginated by the cursor of the entries, from the oldest.
+// +kubebuilder:object:generate=true
+type JournaldBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+
+	// Command is the path of journalctl. Defaults to journalctl.
+	Command string `yaml:"command,omitempty" json:"command,omitempty"`
+
+	// Directory of the journal files, e.g. the /var/log/journal of the host. Defaults to the journal of the system.
+	Directory string `yaml:"directory,omitempty" json:"directory,omitempty"`
+
+	// Timeout of the searches. Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// DockerBackendConfig searches the logs of the containers of a Docker daemon, e.g. of the hosts that don't run Kubernetes.
+// The id of the search is the name, or the id, of the container. The labels of the search filter the containers by their labels.
+// The results are labelled with the container, its image and its labels.
+// +kubebuilder:object:generate=true
+type DockerBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+
+	// HTTPConnection to the Docker Engine api, the url defaults to unix:///var/run/docker.sock
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// All searches the stopped containers too
+	All bool `yaml:"all,omitempty" json:"all,omitempty"`
+}
+
+// CRIBackendConfig searches the log files written by the container runtime (CRI) on the node apm-hub runs on,
+// when it's deployed as a DaemonSet mounting the /var/log/containers and /var/log/pods directories of the hosts.
+// The KubernetesPod searches are of <namespace>/<name>, or of the name with the namespace label, and the KubernetesNode searches
+// of the node return the logs of every pod. The containerName label filters the containers. The rotated files are searched too.
+// +kubebuilder:object:generate=true
+type CRIBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+
+	// Path of the directory of the symlinks to the log files, named <pod>_<namespace>_<container>-<id>.log.
+	// Defaults to /var/log/containers.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// NodeName is the name of the node, the nodeName label of the results. Defaults to the NODE_NAME environment variable.
+	NodeName string `yaml:"nodeName,omitempty" json:"nodeName,omitempty"`
+}
+
+// SSHBackendConfig greps the log files of hosts over SSH, e.g. of the VMs without a log shipper.
+// The id of the search is the host searched, every host is searched without id.
+// The lines are returned with the path of their file, and the time of their leading timestamp.
+// +kubebuilder:object:generate=true
+type SSHBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+
+	// Hosts are the addresses of the hosts, as host or host:port, the port defaulting to 22
+	Hosts []string `yaml:"hosts" json:"hosts"`
+
+	// Paths of the log files on the hosts, shell globs are expanded, e.g. /var/log/nginx/*.log
+	Paths []string `yaml:"paths" json:"paths"`
+
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	Username string `yaml:"username" json:"username"`
+	// PrivateKey is the PEM encoded private key, authenticating before the password
+	PrivateKey *kommons.EnvVar `yaml:"privateKey,omitempty" json:"privateKey,omitempty"`
+	Passphrase *kommons.EnvVar `yaml:"passphrase,omitempty" json:"passphrase,omitempty"`
+	Password   *kommons.EnvVar `yaml:"password,omitempty" json:"password,omitempty"`
+
+	// KnownHosts are the keys of the hosts, in the known_hosts format
+	KnownHosts *kommons.EnvVar `yaml:"knownHosts,omitempty" json:"knownHosts,omitempty"`
+	// InsecureIgnoreHostKey accepts any key of the hosts when the known hosts aren't set
+	InsecureIgnoreHostKey bool `yaml:"insecureIgnoreHostKey,omitempty" json:"insecureIgnoreHostKey,omitempty"`
+
+	// Timeout of the searches. Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
@@ -0,0 +1,166 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// LogScaleBackendConfig searches a Falcon LogScale (Humio) repository with the Query Jobs api, returning a result per event.
+// The fields of the events are returned as labels, the tags without their # prefix.
+// +kubebuilder:object:generate=true
+type LogScaleBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the LogScale api, with an api token allowed to search the repository.
+	// The url defaults to https://cloud.humio.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Repository is the template of the repository or view searched, rendered with the search params,
+	// e.g. logs-{{index .Labels "cluster"}}
+	Repository string `yaml:"repository" json:"repository"`
+
+	// Query is the template of the LogScale query, rendered with the search params.
+	// The query of the search is passed through as LogScale query language when it's empty,
+	// and piped after the template when the template doesn't use it.
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+}
+
+// CoralogixBackendConfig searches the logs of Coralogix with the DataPrime query api, returning a result per log.
+// The application and subsystem names are returned as the application and subsystem labels,
+// and the searches with these labels are filtered by them.
+// +kubebuilder:object:generate=true
+type CoralogixBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Coralogix api, with an api key with the DataQuerying permission as token.
+	// The url defaults to the api of the region.
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Region of the Coralogix account: EU1, EU2, US1, US2, AP1, AP2 or AP3. Defaults to EU1.
+	Region string `yaml:"region,omitempty" json:"region,omitempty"`
+
+	// Syntax of the queries, dataprime or lucene. Defaults to dataprime.
+	Syntax string `yaml:"syntax,omitempty" json:"syntax,omitempty"`
+
+	// Query is the template of the query, rendered with the search params. Defaults to "source logs" with DataPrime.
+	// When the template doesn't use the query of the search, the DataPrime queries starting with source are passed through,
+	// and the other queries are applied as a lucene filter after the template.
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+}
+
+// LogzioBackendConfig searches a Logz.io account with its Elasticsearch compatible search api,
+// returning a result per hit like the elasticsearch backend.
+// +kubebuilder:object:generate=true
+type LogzioBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Logz.io api. The url defaults to the api of the region.
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Region of the Logz.io account: us, eu, uk, au, ca or wa. Defaults to us.
+	Region string `yaml:"region,omitempty" json:"region,omitempty"`
+
+	// APIToken of the account, sent in the X-API-TOKEN header
+	APIToken *kommons.EnvVar `yaml:"apiToken,omitempty" json:"apiToken,omitempty"`
+
+	// Accounts are the api tokens of the sub accounts, by the name of the account.
+	// The searches with an account label search the sub account of the label instead of the main account.
+	Accounts map[string]kommons.EnvVar `yaml:"accounts,omitempty" json:"accounts,omitempty"`
+
+	// Query is the template of the search body, rendered with the search params like the elasticsearch query.
+	// Defaults to a query_string query of the query of the search over its time range, sorted by the timestamp.
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+
+	// Fields of the message and timestamp, default to message and @timestamp
+	Fields ElasticSearchFields `yaml:"fields,omitempty" json:"fields,omitempty"`
+}
+
+// BetterStackBackendConfig searches the sources of Better Stack Logs, formerly Logtail, with its query api,
+// returning a result per log. The other fields of the logs are returned as labels, the nested fields flattened with dots.
+// +kubebuilder:object:generate=true
+type BetterStackBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Better Stack api, with an api token of the team as token.
+	// The url defaults to https://logs.betterstack.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Sources is the template of the comma separated ids of the sources searched, rendered with the search params,
+	// e.g. {{index .Labels "source_id"}}. All the sources of the team are searched when it's empty.
+	Sources string `yaml:"sources,omitempty" json:"sources,omitempty"`
+}
+
+// PapertrailBackendConfig searches the events of Papertrail with its search api, returning a result per event.
+// The hostname, program, severity and facility of the events are returned as labels.
+// +kubebuilder:object:generate=true
+type PapertrailBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Papertrail api. The url defaults to https://papertrailapp.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// APIToken of the Papertrail user, sent in the X-Papertrail-Token header
+	APIToken *kommons.EnvVar `yaml:"apiToken,omitempty" json:"apiToken,omitempty"`
+
+	// System is the template of the id or name of the system searched, rendered with the search params,
+	// e.g. {{index .Labels "hostname"}}
+	System string `yaml:"system,omitempty" json:"system,omitempty"`
+
+	// Group is the template of the id of the group searched, rendered with the search params
+	Group string `yaml:"group,omitempty" json:"group,omitempty"`
+}
+
+// SigNozBackendConfig searches the logs of SigNoz with the query range api of its query service, returning a result per log.
+// The resource attributes of the logs are returned as labels, e.g. k8s.namespace.name, and so are the log attributes.
+// +kubebuilder:object:generate=true
+type SigNozBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the query service, with an access token as token.
+	// SigNoz Cloud takes its api key in the SIGNOZ-API-KEY header instead.
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Resources are the labels of the search matched against the resource attributes of the logs.
+	// Defaults to service.name, k8s.cluster.name, k8s.namespace.name, k8s.pod.name, k8s.container.name and host.name.
+	Resources []string `yaml:"resources,omitempty" json:"resources,omitempty"`
+
+	// Attributes are the labels of the search matched against the attributes of the logs, e.g. http.status_code
+	Attributes []string `yaml:"attributes,omitempty" json:"attributes,omitempty"`
+}
+
+// DynatraceBackendConfig searches the logs of a Dynatrace environment with the Logs api v2, returning a result per log record.
+// The additional columns of the records are returned as labels, e.g. dt.entity.host and k8s.pod.name.
+// The labels of the entities are resolved with the entity selector of their type and the logs filtered by the ids of the entities.
+// +kubebuilder:object:generate=true
+type DynatraceBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the environment, e.g. https://abc12345.live.dynatrace.com,
+	// or to the platform, e.g. https://abc12345.apps.dynatrace.com, with DQL
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// APIToken with the logs.read and entities.read scopes, sent as an Api-Token.
+	// The platform token of the storage:logs:read scope with DQL, sent as a bearer token.
+	APIToken *kommons.EnvVar `yaml:"apiToken,omitempty" json:"apiToken,omitempty"`
+
+	// Query is the template of the filter of the logs in the Dynatrace search syntax, rendered with the search params,
+	// e.g. k8s.namespace.name="{{index .Labels "namespace"}}". The query of the search is passed through when it's empty.
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+
+	// DQL is the template of the Dynatrace Query Language query of the logs, rendered with the search params,
+	// e.g. fetch logs | filter k8s.namespace.name == "{{index .Labels "namespace"}}".
+	// The logs are queried from Grail instead of the Logs api v2 when it's set, without pagination.
+	DQL string `yaml:"dql,omitempty" json:"dql,omitempty"`
+
+	// Entities are the types of the entities of the labels of the searches, by label.
+	// Defaults to host: HOST, process_group: PROCESS_GROUP and service: SERVICE.
+	Entities map[string]string `yaml:"entities,omitempty" json:"entities,omitempty"`
+}
+
+// HerokuBackendConfig reads the recent logs of Heroku apps with the log sessions of the platform api, returning a result per line.
+// The source and dyno of the lines are returned as the source and dyno labels. Logplex only retains the last 1500 lines of an app.
+// +kubebuilder:object:generate=true
+type HerokuBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the platform api, with an api key or OAuth token as token. The url defaults to https://api.heroku.com
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// App is the template of the name or id of the app, rendered with the search params. Defaults to {{.Id}}.
+	App string `yaml:"app,omitempty" json:"app,omitempty"`
+
+	// Dyno is the template of the dyno or process type of the logs, e.g. {{index .Labels "dyno"}}. Every dyno is read when empty.
+	Dyno string `yaml:"dyno,omitempty" json:"dyno,omitempty"`
+
+	// Source is the template of the source of the logs, app or heroku. Every source is read when empty.
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+}
@@ -0,0 +1,63 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// HTTPConnection is the connection to the http api of a backend.
+// The token is sent as a bearer token, otherwise the username and password are sent with basic auth.
+// +kubebuilder:object:generate=true
+type HTTPConnection struct {
+	// URL of the api
+	URL string `yaml:"url" json:"url"`
+
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	Token    *kommons.EnvVar `yaml:"token,omitempty" json:"token,omitempty"`
+	Username *kommons.EnvVar `yaml:"username,omitempty" json:"username,omitempty"`
+	Password *kommons.EnvVar `yaml:"password,omitempty" json:"password,omitempty"`
+
+	// Headers are sent with every request, e.g. the api keys not sent as bearer tokens
+	Headers map[string]kommons.EnvVar `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Timeout of the requests. Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// HTTPBackendConfig searches a json api, e.g. a proprietary log api, described by the templates of its requests
+// and the JSONPath of the fields of its results. The templates are rendered with the search params,
+// e.g. {{.Query}}, {{.GetStartISO}}, {{.GetEndISO}}, {{.Limit}} and {{.Page}}.
+// +kubebuilder:object:generate=true
+type HTTPBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+
+	// HTTPConnection to the api, the paths of the requests are relative to its url
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Method of the requests. Defaults to GET, or to POST with a body.
+	Method string `yaml:"method,omitempty" json:"method,omitempty"`
+
+	// Path of the requests, with their query, e.g. /api/logs?q={{.Query | urlquery}}&from={{.GetStartISO | urlquery}}
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Body of the requests, sent as json. The json function encodes the values, e.g. {"query": {{json .Query}}}
+	Body string `yaml:"body,omitempty" json:"body,omitempty"`
+
+	// Results is the JSONPath of the results in the response, e.g. {.data.logs}. Defaults to the response, an array of results.
+	Results string `yaml:"results,omitempty" json:"results,omitempty"`
+
+	// NextPage is the JSONPath of the token of the next page in the response, the page of the next search
+	NextPage string `yaml:"nextPage,omitempty" json:"nextPage,omitempty"`
+
+	Fields HTTPFields `yaml:"fields" json:"fields"`
+}
+
+// HTTPFields are the JSONPath of the fields of a result, e.g. {.attributes.timestamp}.
+// The timestamps are RFC3339, ISO8601 or epoch seconds or milliseconds.
+// +kubebuilder:object:generate=true
+type HTTPFields struct {
+	Timestamp string `yaml:"timestamp,omitempty" json:"timestamp,omitempty"`
+	Message   string `yaml:"message,omitempty" json:"message,omitempty"`
+	ID        string `yaml:"id,omitempty" json:"id,omitempty"`
+	// Labels are the JSONPath of the labels, by label
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
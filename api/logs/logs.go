@@ -1,8 +1,11 @@
 package logs
 
 import (
-	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,6 +22,8 @@ type SearchConfig struct {
 	// Path is the path of this config file
 	Path     string               `yaml:"-" json:"-"`
 	Backends SearchBackendConfigs `yaml:"backends,omitempty" json:"backends,omitempty"`
+
+	ServerConfig `yaml:",inline" json:",inline"`
 }
 
 // +kubebuilder:object:generate=true
@@ -28,6 +33,54 @@ type SearchBackendConfig struct {
 	CloudWatch    *CloudWatchBackendConfig       `json:"cloudwatch,omitempty" yaml:"cloudwatch,omitempty"`
 	Kubernetes    *KubernetesSearchBackendConfig `json:"kubernetes,omitempty" yaml:"kubernetes,omitempty"`
 	File          *FileSearchBackendConfig       `json:"file,omitempty" yaml:"file,omitempty"`
+	Store         *StoreBackendConfig            `json:"store,omitempty" yaml:"store,omitempty"`
+	Remote        *RemoteBackendConfig           `json:"remote,omitempty" yaml:"remote,omitempty"`
+	Jaeger        *JaegerBackendConfig           `json:"jaeger,omitempty" yaml:"jaeger,omitempty"`
+	Tempo         *TempoBackendConfig            `json:"tempo,omitempty" yaml:"tempo,omitempty"`
+	Zipkin        *ZipkinBackendConfig           `json:"zipkin,omitempty" yaml:"zipkin,omitempty"`
+	Prometheus    *PrometheusBackendConfig       `json:"prometheus,omitempty" yaml:"prometheus,omitempty"`
+	Sentry        *SentryBackendConfig           `json:"sentry,omitempty" yaml:"sentry,omitempty"`
+	Rollbar       *RollbarBackendConfig          `json:"rollbar,omitempty" yaml:"rollbar,omitempty"`
+	EventHubs     *EventHubsBackendConfig        `json:"eventHubs,omitempty" yaml:"eventHubs,omitempty"`
+	Cloudflare    *CloudflareBackendConfig       `json:"cloudflare,omitempty" yaml:"cloudflare,omitempty"`
+
+	AzureLogAnalytics  *AzureLogAnalyticsBackendConfig  `json:"azureLogAnalytics,omitempty" yaml:"azureLogAnalytics,omitempty"`
+	LogScale           *LogScaleBackendConfig           `json:"logscale,omitempty" yaml:"logscale,omitempty"`
+	Coralogix          *CoralogixBackendConfig          `json:"coralogix,omitempty" yaml:"coralogix,omitempty"`
+	Logzio             *LogzioBackendConfig             `json:"logzio,omitempty" yaml:"logzio,omitempty"`
+	BetterStack        *BetterStackBackendConfig        `json:"betterStack,omitempty" yaml:"betterStack,omitempty"`
+	Papertrail         *PapertrailBackendConfig         `json:"papertrail,omitempty" yaml:"papertrail,omitempty"`
+	SigNoz             *SigNozBackendConfig             `json:"signoz,omitempty" yaml:"signoz,omitempty"`
+	ZincSearch         *ZincSearchBackendConfig         `json:"zincsearch,omitempty" yaml:"zincsearch,omitempty"`
+	Postgres           *PostgresBackendConfig           `json:"postgres,omitempty" yaml:"postgres,omitempty"`
+	SQL                *SQLBackendConfig                `json:"sql,omitempty" yaml:"sql,omitempty"`
+	SQLite             *SQLiteBackendConfig             `json:"sqlite,omitempty" yaml:"sqlite,omitempty"`
+	BigQuery           *BigQueryBackendConfig           `json:"bigquery,omitempty" yaml:"bigquery,omitempty"`
+	Athena             *AthenaBackendConfig             `json:"athena,omitempty" yaml:"athena,omitempty"`
+	S3Archive          *S3ArchiveBackendConfig          `json:"s3Archive,omitempty" yaml:"s3Archive,omitempty"`
+	GCSArchive         *GCSArchiveBackendConfig         `json:"gcsArchive,omitempty" yaml:"gcsArchive,omitempty"`
+	AzureBlobArchive   *AzureBlobArchiveBackendConfig   `json:"azureBlobArchive,omitempty" yaml:"azureBlobArchive,omitempty"`
+	NATS               *NATSBackendConfig               `json:"nats,omitempty" yaml:"nats,omitempty"`
+	Journald           *JournaldBackendConfig           `json:"journald,omitempty" yaml:"journald,omitempty"`
+	Docker             *DockerBackendConfig             `json:"docker,omitempty" yaml:"docker,omitempty"`
+	CRI                *CRIBackendConfig                `json:"cri,omitempty" yaml:"cri,omitempty"`
+	SSH                *SSHBackendConfig                `json:"ssh,omitempty" yaml:"ssh,omitempty"`
+	HTTP               *HTTPBackendConfig               `json:"http,omitempty" yaml:"http,omitempty"`
+	Plugin             *PluginBackendConfig             `json:"plugin,omitempty" yaml:"plugin,omitempty"`
+	WASM               *WASMBackendConfig               `json:"wasm,omitempty" yaml:"wasm,omitempty"`
+	GitHubActions      *GitHubActionsBackendConfig      `json:"githubActions,omitempty" yaml:"githubActions,omitempty"`
+	GitLab             *GitLabBackendConfig             `json:"gitlab,omitempty" yaml:"gitlab,omitempty"`
+	Jenkins            *JenkinsBackendConfig            `json:"jenkins,omitempty" yaml:"jenkins,omitempty"`
+	KubernetesAudit    *KubernetesAuditBackendConfig    `json:"kubernetesAudit,omitempty" yaml:"kubernetesAudit,omitempty"`
+	KubernetesNode     *KubernetesNodeBackendConfig     `json:"kubernetesNode,omitempty" yaml:"kubernetesNode,omitempty"`
+	GoogleCloudLogging *GoogleCloudLoggingBackendConfig `json:"googleCloudLogging,omitempty" yaml:"googleCloudLogging,omitempty"`
+	ControlPlane       *ControlPlaneBackendConfig       `json:"controlPlane,omitempty" yaml:"controlPlane,omitempty"`
+	ElasticAPM         *ElasticAPMBackendConfig         `json:"elasticAPM,omitempty" yaml:"elasticAPM,omitempty"`
+	Dynatrace          *DynatraceBackendConfig          `json:"dynatrace,omitempty" yaml:"dynatrace,omitempty"`
+	AppDynamics        *AppDynamicsBackendConfig        `json:"appDynamics,omitempty" yaml:"appDynamics,omitempty"`
+	OCILogging         *OCILoggingBackendConfig         `json:"ociLogging,omitempty" yaml:"ociLogging,omitempty"`
+	AlibabaSLS         *AlibabaSLSBackendConfig         `json:"alibabaSLS,omitempty" yaml:"alibabaSLS,omitempty"`
+	Heroku             *HerokuBackendConfig             `json:"heroku,omitempty" yaml:"heroku,omitempty"`
 }
 
 func NewSearchBackend(api SearchAPI) SearchBackend {
@@ -37,7 +90,23 @@ func NewSearchBackend(api SearchAPI) SearchBackend {
 }
 
 type SearchBackend struct {
-	API SearchAPI
+	// Name is the name of the backend as configured in CommonBackend
+	Name string
+	API  SearchAPI
+
+	// Routes are the routes configured for the backend in CommonBackend
+	Routes Routes
+
+	// Pipeline processes the results returned by the API.
+	// It's nil when the backend has no processing steps.
+	Pipeline Processor
+
+	// Limiter bounds the concurrent searches of the backend.
+	// It's nil when the backend has no concurrency limit.
+	Limiter ConcurrencyLimiter
+
+	// Shadow backends are searched in the background, and their results are only compared to the other backends'
+	Shadow bool
 }
 
 type Routes []SearchRoute
@@ -54,11 +123,42 @@ func (t Routes) MatchRoute(q *SearchParams) (match bool, isAdditive bool) {
 
 // +kubebuilder:object:generate=true
 type CommonBackend struct {
+	// Name identifies the backend, e.g. in the rbac rules
+	Name   string `yaml:"name,omitempty" json:"name,omitempty"`
 	Routes Routes `yaml:"routes,omitempty" json:"routes,omitempty"`
 
 	// Labels are custom labels specified in the configuration file for a backend
 	// that will be attached to each log line returned by that backend.
 	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
+	// Pipeline is the list of processing steps applied to the results of the backend.
+	Pipeline []PipelineStep `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
+
+	// Transform is the list of expressions applied to the results of the backend, after the pipeline.
+	Transform []TransformStep `yaml:"transform,omitempty" json:"transform,omitempty"`
+
+	// Concurrency limits the searches running at the same time against the backend
+	Concurrency *ConcurrencyConfig `yaml:"concurrency,omitempty" json:"concurrency,omitempty"`
+
+	// Shadow searches the backend in the background with every search matching its routes,
+	// and logs its latency and number of results next to the ones of the other backends, without returning its results.
+	// It's meant to validate a new cluster or query before switching to it.
+	Shadow bool `yaml:"shadow,omitempty" json:"shadow,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// ConcurrencyConfig bounds the searches sent to a backend at the same time,
+// so that many users searching at once don't overload it.
+type ConcurrencyConfig struct {
+	// Max is the number of searches running at the same time
+	Max int `yaml:"max" json:"max"`
+
+	// Queue is the number of searches waiting for one of the running searches to complete.
+	// The searches beyond it are rejected right away. Defaults to 0, rejecting the searches over the limit.
+	Queue int `yaml:"queue,omitempty" json:"queue,omitempty"`
+
+	// Timeout is how long a search waits in the queue before being rejected. Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 }
 
 type SearchBackendConfigs []SearchBackendConfig
@@ -104,12 +204,104 @@ type KubernetesSearchBackendConfig struct {
 	Namespace string `json:"namespace,omitempty"`
 }
 
+// KubernetesNodeBackendConfig searches the logs of the nodes, e.g. of the kubelet, containerd and the kernel,
+// with the /logs endpoint of the kubelet through the node proxy of the API server.
+// The services are queried with the node log query of the kubelet, which requires the NodeLogQuery feature gate
+// and enableSystemLogQuery in the kubelet configuration. The files of /var/log are read as a whole.
+// +kubebuilder:object:generate=true
+type KubernetesNodeBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// empty kubeconfig indicates to use the current kubeconfig for connection
+	Kubeconfig *kommons.EnvVar `json:"kubeconfig,omitempty" yaml:"kubeconfig,omitempty"`
+	//namespace to search the kommons.EnvVar in
+	Namespace string `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	// Services are the systemd services, or the files of /var/log prefixed with /, searched by default.
+	// Defaults to kubelet, containerd and /kern.log, the searches can set them with the service label.
+	Services []string `json:"services,omitempty" yaml:"services,omitempty"`
+}
+
 // +kubebuilder:object:generate=true
 type FileSearchBackendConfig struct {
 	CommonBackend `json:",inline" yaml:",inline"`
 	Paths         []string `yaml:"path,omitempty" json:"path,omitempty"`
 }
 
+// KubernetesAuditBackendConfig searches the audit events written by the log audit backend of the API servers,
+// i.e. the json lines of --audit-log-path. The rotated files compressed with --audit-log-compress are read too.
+// The audit events sent by the webhook audit backend to /ingest/audit are searched with the store backend.
+// +kubebuilder:object:generate=true
+type KubernetesAuditBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// Paths are the glob patterns of the audit log files, e.g. /var/log/kubernetes/audit/audit*.log*
+	Paths []string `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+// StoreBackendConfig searches the logs pushed to the /ingest endpoint
+// +kubebuilder:object:generate=true
+type StoreBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// Path is the directory of the store, same as the ingest path
+	Path string `yaml:"path" json:"path"`
+}
+
+// RemoteBackendConfig searches a backend implemented out of process, behind a gRPC server.
+// See pkg/remote/backend.proto for the service to implement.
+// +kubebuilder:object:generate=true
+type RemoteBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// Address of the gRPC server, e.g. host:port or unix:///path/to/socket
+	Address string `yaml:"address" json:"address"`
+	// Insecure disables TLS, e.g. for a plugin running as a sidecar
+	Insecure bool `yaml:"insecure,omitempty" json:"insecure,omitempty"`
+	// Token is sent as a bearer token in the metadata of every call
+	Token *kommons.EnvVar `yaml:"token,omitempty" json:"token,omitempty"`
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	// Timeout of the searches. Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// PluginBackendConfig searches a backend shipped as an executable, started by apm-hub.
+// The plugin serves the service in pkg/remote/backend.proto on the address of its handshake, see pkg/plugin.
+// +kubebuilder:object:generate=true
+type PluginBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// Path of the executable of the plugin
+	Path string `yaml:"path" json:"path"`
+	// Args of the executable
+	Args []string `yaml:"args,omitempty" json:"args,omitempty"`
+	// Env of the plugin, in addition to the environment of apm-hub, e.g. the credentials of its backend
+	Env map[string]kommons.EnvVar `yaml:"env,omitempty" json:"env,omitempty"`
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	// Timeout of the handshake of the plugin and of the searches. Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
+// WASMBackendConfig searches a backend implemented by a WebAssembly module, run in the sandbox of apm-hub.
+// The module exports:
+//   - memory
+//   - allocate(size i32) i32, returning a buffer of size bytes in the memory
+//   - search(ptr i32, len i32) i64, called with the json encoded search params written to an allocated buffer.
+//     It returns the location of the json encoded search results as ptr<<32 | len, or 0 without results.
+//     The results fail the search with their error field, e.g. {"error": "..."}.
+//   - configure(ptr i32, len i32) i32, optional, called once with the settings when the module is loaded.
+//     It returns 0, or fails the loading of the module.
+//
+// WASI modules are supported, their _initialize function is called when the module is loaded.
+// +kubebuilder:object:generate=true
+type WASMBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// Path to the .wasm module
+	Path string `yaml:"path" json:"path"`
+	// Settings are passed to the configure function of the module, e.g. json
+	Settings string `yaml:"settings,omitempty" json:"settings,omitempty"`
+	// Env are the environment variables of the module
+	Env map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+	// Timeout of the searches, the module being reloaded when a search doesn't complete in time. Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
+
 // +kubebuilder:object:generate=true
 type AWSAuthentication struct {
 	Region    string          `yaml:"region,omitempty" json:"region,omitempty"`
@@ -123,7 +315,13 @@ type CloudWatchBackendConfig struct {
 	Auth          AWSAuthentication `yaml:"auth,omitempty" json:"auth,omitempty"`
 	Namespace     string            `yaml:"namespace,omitempty" json:"namespace,omitempty"` // Namespace to search the kommons.EnvVar in
 	LogGroup      string            `yaml:"log_group,omitempty" json:"log_group,omitempty"`
-	Query         string            `yaml:"query,omitempty" json:"query,omitempty"`
+
+	// Query is the template of the Logs Insights query, rendered with the search params,
+	// e.g. fields @timestamp, @message | filter @message like /{{.Query}}/
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+
+	// Timeout is how long the query can run before it's stopped, when the search has no earlier deadline. Defaults to 5m.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
 }
 
 // +kubebuilder:object:generate=true
@@ -163,6 +361,19 @@ type OpenSearchBackendConfig struct {
 	Password *kommons.EnvVar `yaml:"password,omitempty" json:"password,omitempty"`
 }
 
+// ZincSearchBackendConfig searches a ZincSearch index with its Elasticsearch compatible search api.
+// The query is rendered and the hits are returned like with the elasticsearch backend.
+// +kubebuilder:object:generate=true
+type ZincSearchBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to ZincSearch, with the username and password of a user
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	Query  string              `yaml:"query,omitempty" json:"query,omitempty"`
+	Index  string              `yaml:"index,omitempty" json:"index,omitempty"`
+	Fields ElasticSearchFields `yaml:"fields,omitempty" json:"fields,omitempty"`
+}
+
 type SearchParams struct {
 	// Limit is the maximum number of results to return.
 	Limit      int64 `json:"limit,omitempty"`
@@ -221,6 +432,15 @@ func (p SearchParams) GetStartISO() string {
 	return start.UTC().Format("2006-01-02T15:04:05.000Z")
 }
 
+func (p SearchParams) GetEndISO() string {
+	end := p.GetEnd()
+	if end == nil {
+		return ""
+	}
+
+	return end.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
 func (p *SearchParams) GetStart() *time.Time {
 	if p.start != nil {
 		return p.start
@@ -236,6 +456,12 @@ func (p *SearchParams) GetStart() *time.Time {
 	return p.start
 }
 
+// SetStart replaces the start of the search
+func (p *SearchParams) SetStart(start time.Time) {
+	p.Start = start.Format(time.RFC3339)
+	p.start = &start
+}
+
 func (p *SearchParams) GetEnd() *time.Time {
 	if p.end != nil {
 		return p.end
@@ -280,6 +506,41 @@ func (q SearchParams) String() string {
 	return s
 }
 
+// Values returns the search params as url query params, e.g. to link to the search in a UI
+func (q SearchParams) Values() url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("query", q.Query)
+	set("type", q.Type)
+	set("id", q.Id)
+	set("start", q.Start)
+	set("end", q.End)
+	if q.Limit > 0 {
+		values.Set("limit", strconv.FormatInt(q.Limit, 10))
+	}
+	for k, v := range q.Labels {
+		values.Add("labels", k+"="+v)
+	}
+	return values
+}
+
+// Link appends the search params to the url, or returns an empty string when the url is empty
+func (q SearchParams) Link(baseURL string) string {
+	if baseURL == "" {
+		return ""
+	}
+
+	separator := "?"
+	if strings.Contains(baseURL, "?") {
+		separator = "&"
+	}
+	return baseURL + separator + q.Values().Encode()
+}
+
 type SearchResults struct {
 	Total    int      `json:"total,omitempty"`
 	Results  []Result `json:"results,omitempty"`
@@ -301,26 +562,108 @@ type Result struct {
 	Labels  map[string]string `json:"labels,omitempty"`
 }
 
+// Process moves the RFC3339 timestamp at the start of the message to the result's time
 func (r Result) Process() Result {
-	scanner := bufio.NewScanner(strings.NewReader(r.Message))
-	scanner.Split(bufio.ScanWords)
-	if scanner.Scan() {
-		timestamp := scanner.Text()
-		if _, err := time.Parse(time.RFC3339, timestamp); err == nil {
-			r.Time = timestamp
-			r.Message = strings.ReplaceAll(r.Message, timestamp, "")
-		}
+	return r.ProcessTimestamp(defaultTimestampParser)
+}
+
+// ProcessTimestamp moves the timestamp at the start of the message to the result's time,
+// normalized to RFC3339.
+func (r Result) ProcessTimestamp(parser *TimestampParser) Result {
+	message := strings.TrimSpace(r.Message)
+	if ts, rest, ok := parser.ParsePrefix(message); ok {
+		r.Time = ts.Format(time.RFC3339Nano)
+		message = rest
 	}
-	r.Message = strings.TrimSpace(r.Message)
+	r.Message = strings.TrimSpace(message)
 	return r
 }
 
 // +kubebuilder:object:generate=false
 type SearchAPI interface {
-	Search(q *SearchParams) (r SearchResults, err error)
+	// Search returns the results of the search, the backends stop the calls to the underlying system
+	// once the context is done, e.g. when the client disconnects.
+	Search(ctx context.Context, q *SearchParams) (r SearchResults, err error)
 	MatchRoute(q *SearchParams) (match bool, isAdditive bool)
+	Capabilities() Capabilities
+}
+
+// SearchStreamer is implemented by the backends that can send the results as they're found,
+// without holding all of them in memory. It returns once every result is sent, the search fails
+// or the context is done. The channel isn't closed.
+type SearchStreamer interface {
+	SearchStream(ctx context.Context, q *SearchParams, results chan<- Result) error
+}
+
+// HealthChecker is implemented by the backends that can check that they're reachable
+// without running a search, e.g. with a ping.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// Capabilities are the features supported natively by a backend.
+// The search is adapted to the features it doesn't support.
+type Capabilities struct {
+	// Query is true when the backend applies the query of the search params.
+	// Otherwise the results are filtered by the query once returned.
+	Query bool `json:"query"`
+
+	// Pagination is true when the backend returns the token of the next page of results
+	Pagination bool `json:"pagination"`
+
+	// Streaming is true when the backend streams the results as they're found (SearchStreamer)
+	Streaming bool `json:"streaming"`
+
+	// Aggregations is true when the backend counts the values of the labels natively (LabelAggregator)
+	Aggregations bool `json:"aggregations"`
+
+	// MaxTimeRange is the widest time range the backend can search, 0 when unlimited.
+	// The start of the wider searches is moved forward.
+	MaxTimeRange time.Duration `json:"-"`
+}
+
+func (t Capabilities) MarshalJSON() ([]byte, error) {
+	type capabilities Capabilities
+	v := struct {
+		capabilities
+		MaxTimeRange string `json:"maxTimeRange,omitempty"`
+	}{capabilities: capabilities(t)}
+	if t.MaxTimeRange > 0 {
+		v.MaxTimeRange = t.MaxTimeRange.String()
+	}
+	return json.Marshal(v)
+}
+
+// Processor transforms the results returned by a backend
+type Processor interface {
+	Process(results []Result) []Result
+}
+
+// ConcurrencyLimiter bounds the searches running at the same time against a backend
+type ConcurrencyLimiter interface {
+	// Acquire waits for a slot and returns the function releasing it
+	Acquire(ctx context.Context) (release func(), err error)
+}
+
+// QueryRenderer is implemented by backends that can render the native query
+// they send to the underlying system for the given search params.
+type QueryRenderer interface {
+	RenderQuery(q *SearchParams) (string, error)
+}
+
+// LabelAggregator is implemented by backends that can count the values of a label natively,
+// without fetching the results.
+type LabelAggregator interface {
+	TopValues(ctx context.Context, q *SearchParams, label string, size int) ([]LabelValue, error)
+}
+
+// LabelValue is the number of results carrying a value of a label
+type LabelValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
 }
 
+// SearchMapper expands a search into the searches of its concrete sources
 type SearchMapper interface {
-	MapSearchParams(p *SearchParams) ([]SearchParams, error)
+	MapSearchParams(ctx context.Context, p *SearchParams) ([]SearchParams, error)
 }
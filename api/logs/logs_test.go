@@ -1,6 +1,10 @@
 package logs
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
 
 func TestSearchRoute_Match(t *testing.T) {
 	type fields struct {
@@ -208,3 +212,23 @@ func TestSearchRoute_Match(t *testing.T) {
 		})
 	}
 }
+
+func TestCapabilities_MarshalJSON(t *testing.T) {
+	tests := []struct {
+		capabilities Capabilities
+		want         string
+	}{
+		{Capabilities{}, `{"query":false,"pagination":false,"streaming":false,"aggregations":false}`},
+		{Capabilities{Query: true, MaxTimeRange: 24 * time.Hour}, `{"query":true,"pagination":false,"streaming":false,"aggregations":false,"maxTimeRange":"24h0m0s"}`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.capabilities)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal() = %s, want %s", data, tt.want)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package logs
+
+// PrometheusBackendConfig runs PromQL queries against the Prometheus http api,
+// returning a result per sample so that the metrics can be read next to the logs.
+// The query of the search is sent as PromQL, the configured query is sent when the search has none.
+// +kubebuilder:object:generate=true
+type PrometheusBackendConfig struct {
+	CommonBackend  `json:",inline" yaml:",inline"`
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Query is the PromQL query sent when the search has no query
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+
+	// Instant runs instant queries at the end of the search instead of range queries over its time range
+	Instant bool `yaml:"instant,omitempty" json:"instant,omitempty"`
+
+	// Step is the resolution of the range queries, e.g. 1m.
+	// Defaults to the time range divided by 250, and no less than 15s.
+	Step string `yaml:"step,omitempty" json:"step,omitempty"`
+}
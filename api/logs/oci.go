@@ -0,0 +1,50 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// OCIAuthentication signs the requests to the Oracle Cloud apis with the api signing key of a user,
+// or with the instance principal of the compute instance or node running apm-hub when the key isn't provided
+// +kubebuilder:object:generate=true
+type OCIAuthentication struct {
+	// Tenancy is the OCID of the tenancy of the user
+	Tenancy string `yaml:"tenancy,omitempty" json:"tenancy,omitempty"`
+	// User is the OCID of the user of the key
+	User string `yaml:"user,omitempty" json:"user,omitempty"`
+	// Fingerprint of the public key of the user
+	Fingerprint string `yaml:"fingerprint,omitempty" json:"fingerprint,omitempty"`
+	// PrivateKey is the PEM encoded api signing key of the user
+	PrivateKey *kommons.EnvVar `yaml:"privateKey,omitempty" json:"privateKey,omitempty"`
+	// Passphrase of the private key, when it's encrypted
+	Passphrase *kommons.EnvVar `yaml:"passphrase,omitempty" json:"passphrase,omitempty"`
+}
+
+// OCILoggingBackendConfig searches the logs of Oracle Cloud Infrastructure Logging with the Logging Search api,
+// returning a result per log entry. The search is scoped to a compartment, or to a log group or log of the compartment,
+// the templates of which route the searches by their labels, e.g. {{index .Labels "log_group"}}.
+// +kubebuilder:object:generate=true
+type OCILoggingBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+	// HTTPConnection to the Logging Search api. The url defaults to https://logging.<region>.oci.oraclecloud.com.
+	// The requests are signed with Auth instead of a token.
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Region of the logs, e.g. us-ashburn-1
+	Region string `yaml:"region" json:"region"`
+
+	// Auth signs the requests, with the instance principal when no private key is provided.
+	// The user or the dynamic group of the instance requires the read log-content permission on the compartment.
+	Auth OCIAuthentication `yaml:"auth,omitempty" json:"auth,omitempty"`
+
+	// Compartment is the template of the OCID of the compartment searched, rendered with the search params
+	Compartment string `yaml:"compartment" json:"compartment"`
+
+	// LogGroup is the template of the OCID of the log group searched, every log group of the compartment is searched when empty
+	LogGroup string `yaml:"logGroup,omitempty" json:"logGroup,omitempty"`
+
+	// Log is the template of the OCID of the log searched, every log of the log group is searched when empty
+	Log string `yaml:"log,omitempty" json:"log,omitempty"`
+
+	// Query is the template of the clauses of the query piped after the search of the logs, rendered with the search params,
+	// e.g. where data.message = '{{.Query}}'. The query of the search is piped as a clause when it's empty.
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+}
@@ -0,0 +1,235 @@
+package logs
+
+// +kubebuilder:object:generate=true
+// PipelineStep is a single processing step applied, in order, to the results of a backend
+// before they're returned. Only one of the steps must be set.
+type PipelineStep struct {
+	Redact    *RedactStep    `yaml:"redact,omitempty" json:"redact,omitempty"`
+	Grok      *GrokStep      `yaml:"grok,omitempty" json:"grok,omitempty"`
+	Extract   *ExtractStep   `yaml:"extract,omitempty" json:"extract,omitempty"`
+	JSON      *JSONStep      `yaml:"json,omitempty" json:"json,omitempty"`
+	Severity  *SeverityStep  `yaml:"severity,omitempty" json:"severity,omitempty"`
+	Timestamp *TimestampStep `yaml:"timestamp,omitempty" json:"timestamp,omitempty"`
+	GeoIP     *GeoIPStep     `yaml:"geoip,omitempty" json:"geoip,omitempty"`
+	Truncate  *TruncateStep  `yaml:"truncate,omitempty" json:"truncate,omitempty"`
+	LabelMap  *LabelMapStep  `yaml:"labelMap,omitempty" json:"labelMap,omitempty"`
+	Drop      *DropStep      `yaml:"drop,omitempty" json:"drop,omitempty"`
+	Sanitize  *SanitizeStep  `yaml:"sanitize,omitempty" json:"sanitize,omitempty"`
+	WASM      *WASMStep      `yaml:"wasm,omitempty" json:"wasm,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// RedactStep masks sensitive data in the message and the labels of the results.
+type RedactStep struct {
+	// Builtin is the list of builtin patterns to mask: creditCard, bearerToken, awsKey, email.
+	// All builtin patterns are used when neither builtin nor custom patterns are set.
+	Builtin []string `yaml:"builtin,omitempty" json:"builtin,omitempty"`
+
+	// Patterns are custom regular expressions to mask
+	Patterns []string `yaml:"patterns,omitempty" json:"patterns,omitempty"`
+
+	// Replacement is the text that replaces the matches. Defaults to [REDACTED]
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// GrokStep extracts labels from unstructured messages with grok patterns.
+// The standard grok pattern library (e.g. COMMONAPACHELOG, SYSLOGLINE) is available.
+type GrokStep struct {
+	// Patterns are tried in order and the named captures of the first matching pattern are added as labels
+	Patterns []string `yaml:"patterns" json:"patterns"`
+
+	// Definitions are custom patterns that can be referenced from the patterns
+	Definitions map[string]string `yaml:"definitions,omitempty" json:"definitions,omitempty"`
+
+	// Source is the label to parse instead of the message
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// ExtractStep promotes the named capture groups of regular expressions to labels,
+// e.g. `status=(?P<status>\d+) latency=(?P<latency_ms>\d+)ms`.
+type ExtractStep struct {
+	// Patterns are tried in order and the named captures of the first matching pattern are added as labels
+	Patterns []string `yaml:"patterns" json:"patterns"`
+
+	// Source is the label to parse instead of the message
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+
+	// Labels restricts the step to the results carrying these labels
+	// (comma separated values, same as the route labels).
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// JSONStep parses the messages that are JSON objects and lifts their fields into labels.
+// Messages that aren't JSON objects are left untouched.
+type JSONStep struct {
+	// Keys are the fields to lift into labels, nested fields are separated by dots (e.g. log.level).
+	// The labels are named after the keys. All the top level fields are lifted when empty.
+	Keys []string `yaml:"keys,omitempty" json:"keys,omitempty"`
+
+	// Message is the field that replaces the message, e.g. msg
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// SeverityStep normalizes the many spellings of the severity (WARN, warning, 40, W ...)
+// into a canonical severity label: trace, debug, info, warning, error or fatal.
+type SeverityStep struct {
+	// Sources are the labels holding the original severity, the first one set is used.
+	// Defaults to severity, level, lvl, loglevel, log.level and priority.
+	Sources []string `yaml:"sources,omitempty" json:"sources,omitempty"`
+
+	// Label is the label the canonical severity is written to. Defaults to "severity".
+	Label string `yaml:"label,omitempty" json:"label,omitempty"`
+
+	// Mapping maps additional spellings to a canonical severity, e.g. {"crit": "fatal"}
+	Mapping map[string]string `yaml:"mapping,omitempty" json:"mapping,omitempty"`
+
+	// FromMessage detects the severity from the start of the message (e.g. "ERROR ..." or klog's "E0208")
+	// when none of the sources are set.
+	FromMessage bool `yaml:"fromMessage,omitempty" json:"fromMessage,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// TimestampStep parses the timestamp of the results and normalizes it to RFC3339.
+type TimestampStep struct {
+	// Layouts are tried in order. A layout is either one of rfc3339, iso8601, klog, syslog,
+	// epochMillis, epochSeconds or a fixed width Go time layout (e.g. "2006/01/02 15:04:05").
+	// Defaults to all the builtin layouts.
+	Layouts []string `yaml:"layouts,omitempty" json:"layouts,omitempty"`
+
+	// Timezone is used for the timestamps without a zone, e.g. Europe/Berlin. Defaults to UTC.
+	Timezone string `yaml:"timezone,omitempty" json:"timezone,omitempty"`
+
+	// Source is the label holding the timestamp.
+	// Defaults to the start of the message, from where the timestamp is removed.
+	Source string `yaml:"source,omitempty" json:"source,omitempty"`
+
+	// KeepMessage leaves the timestamp in the message
+	KeepMessage bool `yaml:"keepMessage,omitempty" json:"keepMessage,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// GeoIPStep enriches the results containing a public IP address with its location
+// from MaxMind databases (GeoLite2 or GeoIP2).
+type GeoIPStep struct {
+	// Database is the path to the City or Country database
+	Database string `yaml:"database,omitempty" json:"database,omitempty"`
+
+	// ASNDatabase is the path to the ASN database
+	ASNDatabase string `yaml:"asnDatabase,omitempty" json:"asnDatabase,omitempty"`
+
+	// Sources are the labels holding the IP address, the first one set is used.
+	// Defaults to the first IP address found in the message.
+	Sources []string `yaml:"sources,omitempty" json:"sources,omitempty"`
+
+	// Prefix of the labels added: country, city, asn and as_org. Defaults to "geo_".
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// TruncateStep limits the size of the messages.
+type TruncateStep struct {
+	// MaxLength is the maximum size of the message in bytes
+	MaxLength int `yaml:"maxLength" json:"maxLength"`
+
+	// Marker is appended to the truncated messages. Defaults to "...[truncated]"
+	Marker string `yaml:"marker,omitempty" json:"marker,omitempty"`
+
+	// Label carries the original size of the truncated messages. Defaults to "original_size"
+	Label string `yaml:"label,omitempty" json:"label,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// LabelMapStep maps the labels of a backend to a consistent vocabulary.
+// Labels are renamed first, then dropped and finally filtered by the keep list.
+type LabelMapStep struct {
+	// Rename maps the original label to its new name, e.g. {"kubernetes.pod_name": "pod"}
+	Rename map[string]string `yaml:"rename,omitempty" json:"rename,omitempty"`
+
+	// Drop removes the labels matching any of the glob patterns, e.g. agent.*
+	Drop []string `yaml:"drop,omitempty" json:"drop,omitempty"`
+
+	// Keep removes the labels not matching any of the glob patterns
+	Keep []string `yaml:"keep,omitempty" json:"keep,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// DropStep filters out the known noise, e.g. health checks.
+// The dropped results are counted in the apm_hub_pipeline_dropped_lines_total metric.
+type DropStep struct {
+	// Rules drop the results matching any of them
+	Rules []FilterRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+
+	// Keep drops the results not matching any of the rules
+	Keep []FilterRule `yaml:"keep,omitempty" json:"keep,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// FilterRule matches the results satisfying all of its conditions
+type FilterRule struct {
+	// Message is a regular expression matched against the message
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+
+	// Labels are matched against the labels of the result (comma separated values, same as the route labels)
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// SanitizeStep cleans up the messages of the terminal escapes and non-printable characters
+// that render badly outside of a terminal, e.g. the colors of container logs.
+type SanitizeStep struct {
+	// Strip is the list of what to remove from the messages: ansi (escape sequences),
+	// control (non-printable characters except tabs and newlines) and invalidUTF8.
+	// Defaults to all of them.
+	Strip []string `yaml:"strip,omitempty" json:"strip,omitempty"`
+
+	// Replacement of the removed control characters and invalid UTF-8 bytes. Defaults to removing them.
+	Replacement string `yaml:"replacement,omitempty" json:"replacement,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// WASMStep runs the results through a WebAssembly module, to ship custom parsing logic
+// without rebuilding apm-hub. The module exports:
+//   - memory
+//   - allocate(size i32) i32, returning a buffer of size bytes in the memory
+//   - process(ptr i32, len i32) i64, called with the json encoded result written to an allocated buffer.
+//     It returns the location of the json encoded processed result as ptr<<32 | len, or 0 to drop the result.
+//
+// WASI modules are supported, their _initialize function is called once when the module is loaded.
+type WASMStep struct {
+	// Path to the .wasm module
+	Path string `yaml:"path" json:"path"`
+
+	// Env are the environment variables of the module, to configure it
+	Env map[string]string `yaml:"env,omitempty" json:"env,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// TransformStep rewrites the results with an expression. Only one of cel or template must be set.
+type TransformStep struct {
+	// CEL is evaluated with the id, time, message and labels variables of the result.
+	// It returns either:
+	//  - a bool, false drops the result
+	//  - a string that replaces the message
+	//  - a map with any of the message (string), labels (map) and drop (bool) keys.
+	//    The labels replace the result's labels, which allows adding and removing labels.
+	CEL string `yaml:"cel,omitempty" json:"cel,omitempty"`
+
+	Template *TransformTemplate `yaml:"template,omitempty" json:"template,omitempty"`
+}
+
+// +kubebuilder:object:generate=true
+// TransformTemplate holds Go templates rendered with the result (.Id, .Time, .Message, .Labels)
+type TransformTemplate struct {
+	// Message replaces the message
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+
+	// Labels are set to the rendered templates. Labels rendered empty are removed.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+
+	// Drop drops the result when rendered to "true"
+	Drop string `yaml:"drop,omitempty" json:"drop,omitempty"`
+}
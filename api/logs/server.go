@@ -0,0 +1,687 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// ServerConfig holds the settings of the apm-hub http server.
+// Unlike the backends, these are only read from the config files passed to the server.
+type ServerConfig struct {
+	Auth  *AuthConfig  `yaml:"auth,omitempty" json:"auth,omitempty"`
+	TLS   *TLSConfig   `yaml:"tls,omitempty" json:"tls,omitempty"`
+	RBAC  *RBACConfig  `yaml:"rbac,omitempty" json:"rbac,omitempty"`
+	Audit *AuditConfig `yaml:"audit,omitempty" json:"audit,omitempty"`
+
+	RateLimit *RateLimitConfig `yaml:"rateLimit,omitempty" json:"rateLimit,omitempty"`
+	Tenancy   *TenancyConfig   `yaml:"tenancy,omitempty" json:"tenancy,omitempty"`
+
+	// TrustedProxies are the CIDRs of the proxies in front of apm-hub, e.g. the ingress controller.
+	// The IP of the clients, e.g. rate limited and audited, is read from the X-Forwarded-For header
+	// set by these proxies. Without it, the IP of the clients is the address of the connection.
+	TrustedProxies []string `yaml:"trustedProxies,omitempty" json:"trustedProxies,omitempty"`
+
+	// Pipeline is applied to the results of every backend, after the backend's own pipeline
+	Pipeline []PipelineStep `yaml:"pipeline,omitempty" json:"pipeline,omitempty"`
+
+	Anomaly        *AnomalyConfig        `yaml:"anomaly,omitempty" json:"anomaly,omitempty"`
+	MissionControl *MissionControlConfig `yaml:"missionControl,omitempty" json:"missionControl,omitempty"`
+	ConfigDB       *ConfigDBConfig       `yaml:"configDB,omitempty" json:"configDB,omitempty"`
+	Alerting       *AlertingConfig       `yaml:"alerting,omitempty" json:"alerting,omitempty"`
+
+	// Forwarders post the new results of standing searches to webhooks
+	Forwarders []ForwarderConfig `yaml:"forwarders,omitempty" json:"forwarders,omitempty"`
+
+	// Ingest accepts logs pushed by agents into the local store
+	Ingest *IngestConfig `yaml:"ingest,omitempty" json:"ingest,omitempty"`
+
+	// Export writes the results of large searches to a bucket
+	Export *ExportConfig `yaml:"export,omitempty" json:"export,omitempty"`
+
+	// Mappers expand the search params, in order, before they're routed to the backends
+	Mappers []MapperConfig `yaml:"mappers,omitempty" json:"mappers,omitempty"`
+
+	// Health probes the backends in the background and stops searching the failing ones
+	Health *HealthConfig `yaml:"health,omitempty" json:"health,omitempty"`
+
+	// Guardrails reject or clamp the expensive searches before they reach the backends
+	Guardrails *GuardrailsConfig `yaml:"guardrails,omitempty" json:"guardrails,omitempty"`
+
+	// Annotations keeps the notes attached to the results of the searches
+	Annotations *AnnotationsConfig `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+
+	// Links keeps the searches shared with short links
+	Links *LinksConfig `yaml:"links,omitempty" json:"links,omitempty"`
+}
+
+// Merge overrides the settings with the ones set in other.
+func (t *ServerConfig) Merge(other ServerConfig) {
+	if other.Auth != nil {
+		t.Auth = other.Auth
+	}
+	if other.TLS != nil {
+		t.TLS = other.TLS
+	}
+	if other.RBAC != nil {
+		t.RBAC = other.RBAC
+	}
+	if other.Audit != nil {
+		t.Audit = other.Audit
+	}
+	if other.RateLimit != nil {
+		t.RateLimit = other.RateLimit
+	}
+	if other.Tenancy != nil {
+		t.Tenancy = other.Tenancy
+	}
+	if other.TrustedProxies != nil {
+		t.TrustedProxies = other.TrustedProxies
+	}
+	if other.Pipeline != nil {
+		t.Pipeline = other.Pipeline
+	}
+	if other.Anomaly != nil {
+		t.Anomaly = other.Anomaly
+	}
+	if other.MissionControl != nil {
+		t.MissionControl = other.MissionControl
+	}
+	if other.ConfigDB != nil {
+		t.ConfigDB = other.ConfigDB
+	}
+	if other.Alerting != nil {
+		t.Alerting = other.Alerting
+	}
+	if other.Forwarders != nil {
+		t.Forwarders = other.Forwarders
+	}
+	if other.Ingest != nil {
+		t.Ingest = other.Ingest
+	}
+	if other.Export != nil {
+		t.Export = other.Export
+	}
+	if other.Mappers != nil {
+		t.Mappers = other.Mappers
+	}
+	if other.Health != nil {
+		t.Health = other.Health
+	}
+	if other.Guardrails != nil {
+		t.Guardrails = other.Guardrails
+	}
+	if other.Annotations != nil {
+		t.Annotations = other.Annotations
+	}
+	if other.Links != nil {
+		t.Links = other.Links
+	}
+}
+
+// AnnotationsConfig configures where the annotations of the results are stored.
+// An annotation links a note to a result with the name of its backend, its id and its timestamp.
+type AnnotationsConfig struct {
+	// Path is the directory of the annotations. Defaults to the path of the ingest store.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+// LinksConfig configures where the searches shared with short links are stored.
+// The time range of the searches is frozen when the link is created, so the link always shows the same logs.
+type LinksConfig struct {
+	// Path is the directory of the links. Defaults to the path of the ingest store.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+}
+
+// HealthConfig configures the health checks of the backends.
+// A backend is unhealthy, and skipped by the searches, after FailureThreshold consecutive failed
+// probes or searches. It's searched again once a probe succeeds. The backends that can't be
+// probed are given another chance after every interval.
+type HealthConfig struct {
+	// Interval between the probes. Defaults to 30s.
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	// Timeout of a probe. Defaults to 10s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+
+	// FailureThreshold is the number of consecutive failures after which the backend is unhealthy. Defaults to 3.
+	FailureThreshold int `yaml:"failureThreshold,omitempty" json:"failureThreshold,omitempty"`
+}
+
+// GuardrailsConfig bounds the cost of the searches. The searches over the limits are rejected
+// with an error telling how to narrow them, or clamped to the limits when Clamp is set.
+type GuardrailsConfig struct {
+	// MaxTimeRange is the widest time range of a search, e.g. 7d
+	MaxTimeRange string `yaml:"maxTimeRange,omitempty" json:"maxTimeRange,omitempty"`
+
+	// MaxLimit is the largest number of results a search can return at once
+	MaxLimit int64 `yaml:"maxLimit,omitempty" json:"maxLimit,omitempty"`
+
+	// RequireSelector rejects the searches without a type, id or labels, that would scan every log
+	RequireSelector bool `yaml:"requireSelector,omitempty" json:"requireSelector,omitempty"`
+
+	// Clamp narrows the time range and lowers the limit of the searches over the limits instead of rejecting them
+	Clamp bool `yaml:"clamp,omitempty" json:"clamp,omitempty"`
+}
+
+// AuthConfig configures the authentication of the http api.
+// When no authentication method is configured, the api is left unauthenticated.
+type AuthConfig struct {
+	// Namespace to search the kommons.EnvVar in
+	Namespace string          `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Basic     []BasicAuthUser `yaml:"basic,omitempty" json:"basic,omitempty"`
+	APIKeys   []APIKey        `yaml:"apiKeys,omitempty" json:"apiKeys,omitempty"`
+	JWT       *JWTConfig      `yaml:"jwt,omitempty" json:"jwt,omitempty"`
+}
+
+func (t AuthConfig) Enabled() bool {
+	return len(t.Basic) > 0 || len(t.APIKeys) > 0 || t.JWT != nil
+}
+
+type BasicAuthUser struct {
+	Username string         `yaml:"username" json:"username"`
+	Password kommons.EnvVar `yaml:"password" json:"password"`
+	Groups   []string       `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Tenant   string         `yaml:"tenant,omitempty" json:"tenant,omitempty"`
+}
+
+// APIKey is a static key that can be passed either in the X-API-Key header
+// or as a bearer token.
+type APIKey struct {
+	// Name identifies the client using the key
+	Name   string         `yaml:"name" json:"name"`
+	Key    kommons.EnvVar `yaml:"key" json:"key"`
+	Groups []string       `yaml:"groups,omitempty" json:"groups,omitempty"`
+	Tenant string         `yaml:"tenant,omitempty" json:"tenant,omitempty"`
+}
+
+// JWTConfig configures the verification of bearer tokens issued by an identity provider.
+// Either a HMAC secret or a PEM encoded RSA/ECDSA public key must be provided.
+type JWTConfig struct {
+	Secret    *kommons.EnvVar `yaml:"secret,omitempty" json:"secret,omitempty"`
+	PublicKey *kommons.EnvVar `yaml:"publicKey,omitempty" json:"publicKey,omitempty"`
+	Issuer    string          `yaml:"issuer,omitempty" json:"issuer,omitempty"`
+	Audience  string          `yaml:"audience,omitempty" json:"audience,omitempty"`
+
+	// UsernameClaim is the claim holding the username. Defaults to "sub".
+	UsernameClaim string `yaml:"usernameClaim,omitempty" json:"usernameClaim,omitempty"`
+
+	// GroupsClaim is the claim holding the list of groups. Defaults to "groups".
+	GroupsClaim string `yaml:"groupsClaim,omitempty" json:"groupsClaim,omitempty"`
+
+	// TenantClaim is the claim holding the tenant of the user, when tenancy is enabled.
+	TenantClaim string `yaml:"tenantClaim,omitempty" json:"tenantClaim,omitempty"`
+}
+
+// TLSConfig configures the server to serve over TLS
+// and optionally require certificates from its clients (mTLS).
+type TLSConfig struct {
+	CertFile string `yaml:"certFile" json:"certFile"`
+	KeyFile  string `yaml:"keyFile" json:"keyFile"`
+
+	// ClientCAFile is the path to the CA bundle used to verify client certificates.
+	// Setting it requires every client to present a certificate signed by one of the CAs,
+	// except on the paths served without authentication, e.g. /ready and /ingest.
+	ClientCAFile string `yaml:"clientCAFile,omitempty" json:"clientCAFile,omitempty"`
+
+	// AllowedNames restricts the client certificates to the ones whose
+	// common name or one of the DNS/URI/email SANs is in the list.
+	// Items can be negated with a "!" prefix, same as the route labels.
+	AllowedNames []string `yaml:"allowedNames,omitempty" json:"allowedNames,omitempty"`
+}
+
+// RBACConfig restricts what the authenticated users can search.
+// Rules are evaluated in order and the first rule that matches the user
+// and the search params is applied. Requests matching no rule are denied.
+// The identities of a rule without backends, types and labels are admins,
+// e.g. they can read the slow query log.
+type RBACConfig struct {
+	Rules []RBACRule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+type RBACRule struct {
+	// Users and Groups select the identities the rule applies to.
+	// A rule without users and groups applies to everyone, including anonymous requests.
+	Users  []string `yaml:"users,omitempty" json:"users,omitempty"`
+	Groups []string `yaml:"groups,omitempty" json:"groups,omitempty"`
+
+	// Backends is the list of backend names the identities are allowed to search.
+	// Empty allows all backends.
+	Backends []string `yaml:"backends,omitempty" json:"backends,omitempty"`
+
+	// Types is the list of search types the identities are allowed to search.
+	// Empty allows all types.
+	Types []string `yaml:"types,omitempty" json:"types,omitempty"`
+
+	// Labels constrain the values of the search labels (comma separated, same as the route labels).
+	// A missing label is injected when it's constrained to a single value.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// AuditConfig configures where every search is recorded.
+// Multiple sinks can be configured at the same time.
+type AuditConfig struct {
+	// Namespace to search the kommons.EnvVar in
+	Namespace     string                  `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	File          string                  `yaml:"file,omitempty" json:"file,omitempty"`
+	ElasticSearch *AuditElasticSearchSink `yaml:"elasticsearch,omitempty" json:"elasticsearch,omitempty"`
+	Webhook       *AuditWebhookSink       `yaml:"webhook,omitempty" json:"webhook,omitempty"`
+	Redact        AuditRedaction          `yaml:"redact,omitempty" json:"redact,omitempty"`
+}
+
+type AuditElasticSearchSink struct {
+	Address  string          `yaml:"address" json:"address"`
+	Index    string          `yaml:"index" json:"index"`
+	Username *kommons.EnvVar `yaml:"username,omitempty" json:"username,omitempty"`
+	Password *kommons.EnvVar `yaml:"password,omitempty" json:"password,omitempty"`
+}
+
+type AuditWebhookSink struct {
+	URL     string                    `yaml:"url" json:"url"`
+	Headers map[string]kommons.EnvVar `yaml:"headers,omitempty" json:"headers,omitempty"`
+}
+
+// AuditRedaction controls which parts of the search params are masked in the audit records.
+type AuditRedaction struct {
+	// Query masks the free text query
+	Query bool `yaml:"query,omitempty" json:"query,omitempty"`
+	// Labels is the list of label keys whose values are masked
+	Labels []string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// RateLimitConfig limits the rate of requests of every client with a token bucket.
+// Clients are identified by their authenticated username (or api key name)
+// and fall back to their IP address for unauthenticated requests, see ServerConfig.TrustedProxies.
+// Every IP address is also limited before the authentication, so that the credentials can't be brute forced.
+type RateLimitConfig struct {
+	// RequestsPerSecond is the rate at which the bucket of every client is refilled
+	RequestsPerSecond float64 `yaml:"requestsPerSecond" json:"requestsPerSecond"`
+
+	// Burst is the size of the bucket. Defaults to RequestsPerSecond rounded up.
+	Burst int `yaml:"burst,omitempty" json:"burst,omitempty"`
+
+	// Clients overrides the limits of specific clients
+	Clients []ClientRateLimit `yaml:"clients,omitempty" json:"clients,omitempty"`
+}
+
+type ClientRateLimit struct {
+	// Name is the username, api key name or IP address of the client
+	Name              string  `yaml:"name" json:"name"`
+	RequestsPerSecond float64 `yaml:"requestsPerSecond" json:"requestsPerSecond"`
+	Burst             int     `yaml:"burst,omitempty" json:"burst,omitempty"`
+}
+
+// TenancyConfig scopes every request to a single tenant.
+// The tenant is injected as a label into the search params, overriding any value
+// sent by the client, so that routes and backends only see the tenant's logs.
+type TenancyConfig struct {
+	// Header carries the tenant of the requests when the authentication is disabled.
+	// It must only be set by a trusted proxy in front of apm-hub.
+	// The authenticated users are scoped to the tenant bound to their credentials.
+	Header string `yaml:"header,omitempty" json:"header,omitempty"`
+
+	// Label is the label key holding the tenant. Defaults to "tenant".
+	Label string `yaml:"label,omitempty" json:"label,omitempty"`
+
+	// Strict drops the results that don't carry the tenant label.
+	// Results carrying another tenant are always dropped.
+	Strict bool `yaml:"strict,omitempty" json:"strict,omitempty"`
+
+	// Tenants restrict the backends each tenant can search and the labels of its searches.
+	// When set, the requests of the tenants that aren't listed are rejected.
+	Tenants []TenantConfig `yaml:"tenants,omitempty" json:"tenants,omitempty"`
+}
+
+type TenantConfig struct {
+	// Name is the tenant, as bound to the credentials or sent in the header
+	Name string `yaml:"name" json:"name"`
+
+	// Backends is the list of backend names the tenant can search, "*" matching every backend.
+	// Items can be negated with a "!" prefix, e.g. ["*", "!billing"]. Empty allows all backends.
+	Backends []string `yaml:"backends,omitempty" json:"backends,omitempty"`
+
+	// Labels are injected into the searches of the tenant, overriding the values sent by the client.
+	// The results carrying other values are dropped.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// AnomalyConfig configures the background job that baselines the log volume
+// and the error ratio of the watches and flags the spikes and silences.
+type AnomalyConfig struct {
+	// Interval is the period of the checks and the width of the measured windows. Defaults to 5m.
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	// Baseline is the number of past windows the current one is compared to. Defaults to 12.
+	Baseline int `yaml:"baseline,omitempty" json:"baseline,omitempty"`
+
+	// Threshold is the number of standard deviations from the baseline
+	// above which a window is an anomaly. Defaults to 3.
+	Threshold float64 `yaml:"threshold,omitempty" json:"threshold,omitempty"`
+
+	// MinVolume is the average volume of the baseline under which silences aren't reported. Defaults to 10.
+	MinVolume float64 `yaml:"minVolume,omitempty" json:"minVolume,omitempty"`
+
+	// SeverityLabels are the labels holding the severity used to compute the error ratio.
+	// Defaults to severity and level.
+	SeverityLabels []string `yaml:"severityLabels,omitempty" json:"severityLabels,omitempty"`
+
+	// Watches are the searches whose volume is monitored.
+	// Defaults to one watch per route of the backends.
+	Watches []AnomalyWatch `yaml:"watches,omitempty" json:"watches,omitempty"`
+
+	// Notify is the list of notification channels the anomalies are sent to
+	Notify []string `yaml:"notify,omitempty" json:"notify,omitempty"`
+}
+
+type AnomalyWatch struct {
+	Name   string            `yaml:"name" json:"name"`
+	Type   string            `yaml:"type,omitempty" json:"type,omitempty"`
+	Id     string            `yaml:"id,omitempty" json:"id,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Query  string            `yaml:"query,omitempty" json:"query,omitempty"`
+}
+
+// MissionControlConfig configures the attachment of search snapshots
+// to the incidents of Mission Control (incident-commander).
+type MissionControlConfig struct {
+	// URL of the Mission Control api, e.g. http://incident-commander.default.svc
+	URL string `yaml:"url" json:"url"`
+
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Token is sent as a bearer token, otherwise the username and password are used for basic auth
+	Token    *kommons.EnvVar `yaml:"token,omitempty" json:"token,omitempty"`
+	Username *kommons.EnvVar `yaml:"username,omitempty" json:"username,omitempty"`
+	Password *kommons.EnvVar `yaml:"password,omitempty" json:"password,omitempty"`
+
+	// CreatedBy is the id of the person the snapshots are attached as
+	CreatedBy string `yaml:"createdBy,omitempty" json:"createdBy,omitempty"`
+
+	// SearchURL is the url of the UI the snapshots link to, the search params are appended as query params
+	SearchURL string `yaml:"searchURL,omitempty" json:"searchURL,omitempty"`
+
+	// Results is the number of results included in the snapshots. Defaults to 10.
+	Results int `yaml:"results,omitempty" json:"results,omitempty"`
+}
+
+// ConfigDBConfig enables the resolution of the search ids that are config-db item ids.
+// The type, cluster, namespace and name of the config item are then used for the routing
+// and by the backends, e.g. a Kubernetes::Pod becomes a KubernetesPod search of <namespace>/<name>.
+type ConfigDBConfig struct {
+	// Types maps the config types to the search types, e.g. {"AWS::EC2::Instance": "VM"}.
+	// Unmapped config types have their "::" separators removed.
+	Types map[string]string `yaml:"types,omitempty" json:"types,omitempty"`
+
+	// ClusterTag is the tag of the config items holding their cluster. Defaults to "cluster".
+	ClusterTag string `yaml:"clusterTag,omitempty" json:"clusterTag,omitempty"`
+
+	// CacheTTL is how long the config items are cached for. Defaults to 5m.
+	CacheTTL string `yaml:"cacheTTL,omitempty" json:"cacheTTL,omitempty"`
+}
+
+// MapperConfig is a step of the chain expanding a logical search into the searches of its concrete sources.
+// Every search returned by a step goes through the next steps. Only one of the mappers must be set.
+type MapperConfig struct {
+	// ConfigDB resolves the config item ids, see ConfigDBConfig
+	ConfigDB *ConfigDBConfig `yaml:"configDB,omitempty" json:"configDB,omitempty"`
+
+	// Kubernetes expands the searches of workloads into the searches of their pods
+	Kubernetes *KubernetesMapperConfig `yaml:"kubernetes,omitempty" json:"kubernetes,omitempty"`
+
+	// Aliases replace the searches of an id with the searches of their targets
+	Aliases []SearchAlias `yaml:"aliases,omitempty" json:"aliases,omitempty"`
+}
+
+// KubernetesMapperConfig expands the searches of Kubernetes workloads into KubernetesPod searches
+// of <namespace>/<pod>, one per pod selected by the workload.
+type KubernetesMapperConfig struct {
+	// Kubeconfig of the cluster, defaults to the current kubeconfig
+	Kubeconfig *kommons.EnvVar `yaml:"kubeconfig,omitempty" json:"kubeconfig,omitempty"`
+
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Types are the workload search types to expand.
+	// Defaults to KubernetesDeployment, KubernetesStatefulSet, KubernetesDaemonSet, KubernetesReplicaSet and KubernetesJob.
+	Types []string `yaml:"types,omitempty" json:"types,omitempty"`
+}
+
+// SearchAlias maps the id of a logical search to the searches of its sources
+type SearchAlias struct {
+	// Id of the searches replaced
+	Id string `yaml:"id" json:"id"`
+
+	// Type restricts the alias to the searches of this type
+	Type string `yaml:"type,omitempty" json:"type,omitempty"`
+
+	Targets []SearchAliasTarget `yaml:"targets" json:"targets"`
+}
+
+// SearchAliasTarget replaces the type and id of the search, the empty ones are kept.
+// The labels are added to the labels of the search.
+type SearchAliasTarget struct {
+	Type   string            `yaml:"type,omitempty" json:"type,omitempty"`
+	Id     string            `yaml:"id,omitempty" json:"id,omitempty"`
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// AlertingConfig configures the notification channels and the scheduled searches alerting them.
+type AlertingConfig struct {
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// SearchURL is the url of the UI the notifications link to, the search params are appended as query params
+	SearchURL string `yaml:"searchURL,omitempty" json:"searchURL,omitempty"`
+
+	Channels []NotificationChannel `yaml:"channels,omitempty" json:"channels,omitempty"`
+	Rules    []AlertRule           `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// NotificationChannel is where the notifications are sent. Only one of the channel types must be set.
+type NotificationChannel struct {
+	Name  string        `yaml:"name" json:"name"`
+	Slack *SlackChannel `yaml:"slack,omitempty" json:"slack,omitempty"`
+	Teams *TeamsChannel `yaml:"teams,omitempty" json:"teams,omitempty"`
+
+	PagerDuty *PagerDutyChannel `yaml:"pagerduty,omitempty" json:"pagerduty,omitempty"`
+	Opsgenie  *OpsgenieChannel  `yaml:"opsgenie,omitempty" json:"opsgenie,omitempty"`
+}
+
+// SlackChannel posts the notifications either to an incoming webhook
+// or, with the token of a Slack app, to a channel.
+type SlackChannel struct {
+	WebhookURL *kommons.EnvVar `yaml:"webhookURL,omitempty" json:"webhookURL,omitempty"`
+	Token      *kommons.EnvVar `yaml:"token,omitempty" json:"token,omitempty"`
+	// Channel is the id or name of the channel, required with the token
+	Channel string `yaml:"channel,omitempty" json:"channel,omitempty"`
+}
+
+// TeamsChannel posts the notifications either to an incoming webhook
+// or, with the token of an app registration, to a channel through the Microsoft Graph api.
+type TeamsChannel struct {
+	WebhookURL *kommons.EnvVar `yaml:"webhookURL,omitempty" json:"webhookURL,omitempty"`
+	Token      *kommons.EnvVar `yaml:"token,omitempty" json:"token,omitempty"`
+	// Team and Channel are the ids of the team and channel, required with the token
+	Team    string `yaml:"team,omitempty" json:"team,omitempty"`
+	Channel string `yaml:"channel,omitempty" json:"channel,omitempty"`
+}
+
+// PagerDutyChannel triggers and resolves PagerDuty incidents through the Events v2 api.
+// The alerts are deduplicated by name, so a firing alert opens a single incident.
+type PagerDutyChannel struct {
+	// RoutingKey is the integration key of the service
+	RoutingKey kommons.EnvVar `yaml:"routingKey" json:"routingKey"`
+	// Severity of the incidents, one of critical, error, warning or info. Defaults to error.
+	Severity string `yaml:"severity,omitempty" json:"severity,omitempty"`
+}
+
+// OpsgenieChannel creates and closes Opsgenie alerts, aliased by the alert name for deduplication.
+type OpsgenieChannel struct {
+	APIKey kommons.EnvVar `yaml:"apiKey" json:"apiKey"`
+	// URL of the api, e.g. https://api.eu.opsgenie.com for the EU instance. Defaults to https://api.opsgenie.com
+	URL string `yaml:"url,omitempty" json:"url,omitempty"`
+	// Priority of the alerts, P1 to P5. Defaults to P3.
+	Priority string   `yaml:"priority,omitempty" json:"priority,omitempty"`
+	Tags     []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	// Teams are the names of the teams the alerts are routed to
+	Teams []string `yaml:"teams,omitempty" json:"teams,omitempty"`
+}
+
+// AlertRule is a log check evaluated at every interval over the last interval.
+// The channels are notified when the check starts failing and when it recovers.
+type AlertRule struct {
+	Name string `yaml:"name" json:"name"`
+
+	// Interval between the evaluations, the logs of the last interval are searched. Defaults to 5m.
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	LogCheck `yaml:",inline" json:",inline"`
+
+	// Channels is the list of notification channels to notify
+	Channels []string `yaml:"channels" json:"channels"`
+}
+
+// ForwarderConfig polls a search and posts its new results to a webhook in batches.
+// The failed batches are retried with an exponential backoff.
+type ForwarderConfig struct {
+	Name string `yaml:"name" json:"name"`
+
+	SearchParams `yaml:",inline" json:",inline"`
+
+	// Interval between the polls of the search. Defaults to 1m.
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	// Overlap is how far before the end of the previous poll the search starts, so that the results
+	// indexed late are still forwarded. The results already posted aren't posted again. Defaults to the interval.
+	Overlap string `yaml:"overlap,omitempty" json:"overlap,omitempty"`
+
+	URL string `yaml:"url" json:"url"`
+	// Namespace to search the kommons.EnvVar in
+	Namespace string                    `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+	Headers   map[string]kommons.EnvVar `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// BatchSize is the maximum number of results per request. Defaults to 100.
+	BatchSize int `yaml:"batchSize,omitempty" json:"batchSize,omitempty"`
+
+	// MaxRetries is the number of retries of a batch before it's dropped. Defaults to 5.
+	MaxRetries int `yaml:"maxRetries,omitempty" json:"maxRetries,omitempty"`
+
+	// Backoff is the delay before the first retry, doubled at every retry. Defaults to 1s.
+	Backoff string `yaml:"backoff,omitempty" json:"backoff,omitempty"`
+}
+
+// IngestConfig configures the /ingest endpoint receiving batches of logs
+// from agents such as fluent-bit (http output) and Vector (http sink).
+// The logs are written to the local store at Path, searched with a store backend.
+type IngestConfig struct {
+	// Path is the directory of the store
+	Path string `yaml:"path" json:"path"`
+
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Tokens are the bearer tokens accepted from the agents.
+	// Unlike the api keys, they only allow pushing logs.
+	Tokens []IngestToken `yaml:"tokens" json:"tokens"`
+
+	Retention *RetentionConfig `yaml:"retention,omitempty" json:"retention,omitempty"`
+
+	// WAL buffers the batches on disk before they are written to the store
+	WAL *WALConfig `yaml:"wal,omitempty" json:"wal,omitempty"`
+
+	// ALS receives the access logs streamed by Envoy, and the Istio sidecars, with the gRPC access log service
+	ALS *ALSConfig `yaml:"als,omitempty" json:"als,omitempty"`
+
+	// Forward receives the logs of fluent-bit and Fluentd with the forward protocol
+	Forward *ForwardConfig `yaml:"forward,omitempty" json:"forward,omitempty"`
+}
+
+// ALSConfig configures the gRPC server implementing the Envoy access log service (envoy.service.accesslog.v3).
+// The proxies authenticate with an ingest token, sent in the authorization metadata as a bearer token,
+// e.g. with the initial_metadata of the grpc_service of the access logger.
+// It's served over TLS with the certificate of the server, and its client CA, when tls is configured.
+type ALSConfig struct {
+	// Address the gRPC server listens on. Defaults to :9001.
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+}
+
+// ForwardConfig configures the TCP listener implementing the Fluentd forward protocol (v1).
+// The agents authenticate with the shared key handshake, an ingest token being the shared key,
+// e.g. with the Shared_Key of the forward output of fluent-bit.
+// It's served over TLS with the certificate of the server, and its client CA, when tls is configured,
+// e.g. with the tls option of the forward output.
+type ForwardConfig struct {
+	// Address the listener listens on. Defaults to :24224.
+	Address string `yaml:"address,omitempty" json:"address,omitempty"`
+
+	// Hostname sent to the agents in the handshake. Defaults to the hostname of the server.
+	Hostname string `yaml:"hostname,omitempty" json:"hostname,omitempty"`
+}
+
+// WALConfig configures the write-ahead log of the ingest endpoint.
+// The batches are acknowledged once they are synced to the log, and moved to the store in the background.
+// When the log is full, the batches are rejected with a 429 so that the agents retry them later.
+type WALConfig struct {
+	// Path is the directory of the log. Defaults to the wal directory in the store path.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// MaxSize of the log on disk, e.g. 1Gi. Defaults to 1Gi.
+	MaxSize string `yaml:"maxSize,omitempty" json:"maxSize,omitempty"`
+}
+
+// RetentionConfig deletes the old log lines of the store and merges its small segments, at every interval.
+type RetentionConfig struct {
+	// Interval between the compactions. Defaults to 1h.
+	Interval string `yaml:"interval,omitempty" json:"interval,omitempty"`
+
+	// MaxAge of the log lines, e.g. 7d. Lines are kept forever when empty.
+	MaxAge string `yaml:"maxAge,omitempty" json:"maxAge,omitempty"`
+
+	// MaxSize of the store, e.g. 10Gi. The oldest segments are deleted first when it's exceeded.
+	MaxSize string `yaml:"maxSize,omitempty" json:"maxSize,omitempty"`
+
+	// Overrides change the max age of the lines matching their labels.
+	// The first matching override applies.
+	Overrides []RetentionOverride `yaml:"overrides,omitempty" json:"overrides,omitempty"`
+}
+
+type RetentionOverride struct {
+	// Labels to match, values are comma separated lists same as the route labels
+	Labels map[string]string `yaml:"labels" json:"labels"`
+	MaxAge string            `yaml:"maxAge" json:"maxAge"`
+}
+
+type IngestToken struct {
+	// Name identifies the agent using the token
+	Name  string         `yaml:"name" json:"name"`
+	Token kommons.EnvVar `yaml:"token" json:"token"`
+
+	// Labels are attached to every log line pushed with the token, overriding the agent's fields.
+	// e.g. the tenant label when tenancy is enabled.
+	Labels map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+}
+
+// ExportConfig configures the bucket the /export endpoint writes the search results to.
+// GCS buckets are supported through their S3 compatible api, with the endpoint set
+// to https://storage.googleapis.com and HMAC keys as the access and secret keys.
+type ExportConfig struct {
+	Bucket string `yaml:"bucket" json:"bucket"`
+
+	// Prefix of the keys of the exported objects
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// Endpoint overrides the S3 endpoint, e.g. for GCS or MinIO
+	Endpoint string `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+
+	// UsePathStyle addresses the bucket in the path instead of the host, required by MinIO
+	UsePathStyle bool `yaml:"usePathStyle,omitempty" json:"usePathStyle,omitempty"`
+
+	// Auth falls back to the default credential chain when no keys are set
+	Auth AWSAuthentication `yaml:"auth,omitempty" json:"auth,omitempty"`
+
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Expiry of the presigned urls. Defaults to 1h.
+	Expiry string `yaml:"expiry,omitempty" json:"expiry,omitempty"`
+
+	// MaxResults is the maximum number of results of an export. Defaults to 1000000.
+	MaxResults int64 `yaml:"maxResults,omitempty" json:"maxResults,omitempty"`
+}
@@ -0,0 +1,35 @@
+package logs
+
+import "github.com/flanksource/kommons"
+
+// NATSBackendConfig searches the messages of a JetStream stream within the time range of the search, returning a result per message.
+// The searches are paginated by the sequence of the messages, from the oldest.
+// +kubebuilder:object:generate=true
+type NATSBackendConfig struct {
+	CommonBackend `json:",inline" yaml:",inline"`
+
+	// URL of the servers, e.g. nats://nats:4222
+	URL string `yaml:"url" json:"url"`
+	// Namespace to search the kommons.EnvVar in
+	Namespace string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+
+	// Credentials is the content of the user credentials file, with the user jwt and nkey seed
+	Credentials *kommons.EnvVar `yaml:"credentials,omitempty" json:"credentials,omitempty"`
+	Token       *kommons.EnvVar `yaml:"token,omitempty" json:"token,omitempty"`
+	Username    *kommons.EnvVar `yaml:"username,omitempty" json:"username,omitempty"`
+	Password    *kommons.EnvVar `yaml:"password,omitempty" json:"password,omitempty"`
+
+	Stream string `yaml:"stream" json:"stream"`
+
+	// Subject filtering the messages of the stream, a template rendered with the search params,
+	// e.g. logs.{{index .Labels "namespace"}}.>. Defaults to every subject of the stream.
+	Subject string `yaml:"subject,omitempty" json:"subject,omitempty"`
+
+	// SubjectLabels are the labels of the tokens of the subjects, by position, e.g. ["", "namespace", "pod"]
+	// for the subjects logs.<namespace>.<pod>. The empty labels are skipped.
+	// The labels of the search filter the messages by their subject.
+	SubjectLabels []string `yaml:"subjectLabels,omitempty" json:"subjectLabels,omitempty"`
+
+	// Timeout of the searches, and of the wait for the next message. Defaults to 30s.
+	Timeout string `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+}
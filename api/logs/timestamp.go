@@ -0,0 +1,162 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The builtin timestamp layouts
+const (
+	TimestampRFC3339      = "rfc3339"
+	TimestampISO8601      = "iso8601"
+	TimestampKlog         = "klog"
+	TimestampSyslog       = "syslog"
+	TimestampEpochMillis  = "epochMillis"
+	TimestampEpochSeconds = "epochSeconds"
+)
+
+type timestampLayout struct {
+	// prefix matches the timestamp at the start of a message
+	prefix *regexp.Regexp
+	parse  func(s string, loc *time.Location) (time.Time, error)
+}
+
+var builtinTimestampLayouts = map[string]timestampLayout{
+	TimestampRFC3339: {
+		prefix: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})`),
+		parse: func(s string, _ *time.Location) (time.Time, error) {
+			return time.Parse(time.RFC3339Nano, s)
+		},
+	},
+	TimestampISO8601: {
+		prefix: regexp.MustCompile(`^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?`),
+		parse: func(s string, loc *time.Location) (time.Time, error) {
+			s = strings.Replace(strings.Replace(s, " ", "T", 1), ",", ".", 1)
+			return time.ParseInLocation("2006-01-02T15:04:05.999999999", s, loc)
+		},
+	},
+	TimestampKlog: {
+		// e.g. I0208 16:51:50.123456, the severity letter is part of the timestamp
+		prefix: regexp.MustCompile(`^[IWEF]\d{4} \d{2}:\d{2}:\d{2}\.\d{6}`),
+		parse: func(s string, loc *time.Location) (time.Time, error) {
+			return parseWithoutYear("0102 15:04:05.000000", s[1:], loc)
+		},
+	},
+	TimestampSyslog: {
+		// e.g. Feb  8 16:51:50, or Feb 08 16:51:50.123456 with the microseconds of journalctl --output=short-precise
+		prefix: regexp.MustCompile(`^[A-Z][a-z]{2} [ \d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)?`),
+		parse: func(s string, loc *time.Location) (time.Time, error) {
+			return parseWithoutYear(time.Stamp, s, loc)
+		},
+	},
+	TimestampEpochMillis: {
+		prefix: regexp.MustCompile(`^\d{13}\b`),
+		parse: func(s string, _ *time.Location) (time.Time, error) {
+			ms, err := strconv.ParseInt(s, 10, 64)
+			return time.UnixMilli(ms), err
+		},
+	},
+	TimestampEpochSeconds: {
+		prefix: regexp.MustCompile(`^\d{10}(?:\.\d+)?\b`),
+		parse: func(s string, _ *time.Location) (time.Time, error) {
+			secs, err := strconv.ParseFloat(s, 64)
+			return time.UnixMilli(int64(secs * 1000)), err
+		},
+	},
+}
+
+var defaultTimestampLayouts = []string{TimestampRFC3339, TimestampISO8601, TimestampKlog, TimestampSyslog, TimestampEpochMillis, TimestampEpochSeconds}
+
+// defaultTimestampParser only recognizes RFC3339 timestamps
+var defaultTimestampParser = &TimestampParser{
+	layouts:  []timestampLayout{builtinTimestampLayouts[TimestampRFC3339]},
+	location: time.UTC,
+}
+
+// TimestampParser parses timestamps in any of its layouts
+type TimestampParser struct {
+	layouts  []timestampLayout
+	location *time.Location
+}
+
+// NewTimestampParser creates a parser for the given layouts, in order of preference.
+// A layout is either one of the builtin layouts or a fixed width Go time layout.
+// All builtin layouts are used when empty.
+// The timezone is used for the timestamps without a zone and defaults to UTC.
+func NewTimestampParser(layouts []string, timezone string) (*TimestampParser, error) {
+	p := &TimestampParser{location: time.UTC}
+	if timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("error loading the timezone %s: %w", timezone, err)
+		}
+		p.location = loc
+	}
+
+	if len(layouts) == 0 {
+		layouts = defaultTimestampLayouts
+	}
+
+	for _, layout := range layouts {
+		if builtin, ok := builtinTimestampLayouts[layout]; ok {
+			p.layouts = append(p.layouts, builtin)
+			continue
+		}
+		p.layouts = append(p.layouts, goTimestampLayout(layout))
+	}
+
+	return p, nil
+}
+
+// ParsePrefix parses the timestamp at the start of s and returns the time
+// along with the rest of s.
+func (t *TimestampParser) ParsePrefix(s string) (time.Time, string, bool) {
+	for _, layout := range t.layouts {
+		match := layout.prefix.FindString(s)
+		if match == "" {
+			continue
+		}
+
+		if ts, err := layout.parse(match, t.location); err == nil {
+			return ts, s[len(match):], true
+		}
+	}
+
+	return time.Time{}, s, false
+}
+
+// Parse parses s as a whole
+func (t *TimestampParser) Parse(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	ts, rest, ok := t.ParsePrefix(s)
+	return ts, ok && rest == ""
+}
+
+// goTimestampLayout matches the Go time layout against the prefix of the same length
+func goTimestampLayout(layout string) timestampLayout {
+	return timestampLayout{
+		prefix: regexp.MustCompile(fmt.Sprintf(`^.{%d}`, len(layout))),
+		parse: func(s string, loc *time.Location) (time.Time, error) {
+			return time.ParseInLocation(layout, s, loc)
+		},
+	}
+}
+
+// parseWithoutYear parses the timestamps that don't carry a year
+// assuming they're from the last 12 months.
+func parseWithoutYear(layout, s string, loc *time.Location) (time.Time, error) {
+	ts, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return ts, err
+	}
+
+	now := time.Now().In(loc)
+	ts = ts.AddDate(now.Year(), 0, 0)
+	if ts.After(now.Add(24 * time.Hour)) {
+		ts = ts.AddDate(-1, 0, 0)
+	}
+	return ts, nil
+}
@@ -0,0 +1,44 @@
+package logs
+
+import (
+	"testing"
+	"time"
+)
+
+func TestResult_ProcessTimestamp(t *testing.T) {
+	year := time.Now().Year()
+	tests := []struct {
+		name        string
+		layouts     []string
+		timezone    string
+		message     string
+		wantTime    string
+		wantMessage string
+	}{
+		{name: "rfc3339", message: "2023-02-08T16:51:50.123Z GET /", wantTime: "2023-02-08T16:51:50.123Z", wantMessage: "GET /"},
+		{name: "iso without zone", timezone: "Europe/Berlin", message: "2023-02-08 16:51:50,5 started", wantTime: "2023-02-08T16:51:50.5+01:00", wantMessage: "started"},
+		{name: "klog", layouts: []string{TimestampKlog}, message: "I0101 00:00:01.000000   1 main.go:1] ready", wantTime: time.Date(year, 1, 1, 0, 0, 1, 0, time.UTC).Format(time.RFC3339Nano), wantMessage: "1 main.go:1] ready"},
+		{name: "syslog", layouts: []string{TimestampSyslog}, message: "Jan  1 00:00:01 host sshd[1]: accepted", wantTime: time.Date(year, 1, 1, 0, 0, 1, 0, time.UTC).Format(time.RFC3339Nano), wantMessage: "host sshd[1]: accepted"},
+		{name: "syslog with microseconds", layouts: []string{TimestampSyslog}, message: "Jan 01 00:00:01.250000 host kubelet[1]: started", wantTime: time.Date(year, 1, 1, 0, 0, 1, 250000000, time.UTC).Format(time.RFC3339Nano), wantMessage: "host kubelet[1]: started"},
+		{name: "epoch millis", message: "1675875110123 done", wantTime: time.UnixMilli(1675875110123).Format(time.RFC3339Nano), wantMessage: "done"},
+		{name: "go layout", layouts: []string{"2006/01/02 15:04:05"}, message: "2023/02/08 16:51:50 [notice] 1#1: exit", wantTime: "2023-02-08T16:51:50Z", wantMessage: "[notice] 1#1: exit"},
+		{name: "no timestamp", message: " hello world ", wantMessage: "hello world"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser, err := NewTimestampParser(tt.layouts, tt.timezone)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := Result{Message: tt.message}.ProcessTimestamp(parser)
+			if r.Time != tt.wantTime {
+				t.Errorf("time = %q, want %q", r.Time, tt.wantTime)
+			}
+			if r.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", r.Message, tt.wantMessage)
+			}
+		})
+	}
+}
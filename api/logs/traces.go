@@ -0,0 +1,49 @@
+package logs
+
+// JaegerBackendConfig searches the traces of the Jaeger query service, returning a result per span.
+// The id of the search is either a trace id or the service, that can also be set with the service label.
+// The operation label selects the operation.
+// +kubebuilder:object:generate=true
+type JaegerBackendConfig struct {
+	CommonBackend  `json:",inline" yaml:",inline"`
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Service searched when the search has neither an id nor a service label
+	Service string `yaml:"service,omitempty" json:"service,omitempty"`
+
+	// Tags are the labels of the search matched against the tags of the spans, e.g. http.status_code
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
+
+// TempoBackendConfig searches the traces of Grafana Tempo with TraceQL, returning a result per span and span event.
+// The id of the search is either a trace id or the service, that can also be set with the service label.
+// The query of the search, when it's a TraceQL query, is sent as is.
+// +kubebuilder:object:generate=true
+type TempoBackendConfig struct {
+	CommonBackend  `json:",inline" yaml:",inline"`
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Query is the TraceQL query sent when the search selects no span, defaults to {}
+	Query string `yaml:"query,omitempty" json:"query,omitempty"`
+
+	// Tags are the labels of the search matched against the attributes of the spans, e.g. http.status_code
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+
+	// SpansPerSpanSet is the maximum number of spans returned per trace by the searches, defaults to 3
+	SpansPerSpanSet int `yaml:"spansPerSpanSet,omitempty" json:"spansPerSpanSet,omitempty"`
+}
+
+// ZipkinBackendConfig searches the spans of the Zipkin api, returning a result per span and span annotation.
+// The id of the search is either a trace id or the service, that can also be set with the service label.
+// The operation label selects the span name.
+// +kubebuilder:object:generate=true
+type ZipkinBackendConfig struct {
+	CommonBackend  `json:",inline" yaml:",inline"`
+	HTTPConnection `json:",inline" yaml:",inline"`
+
+	// Service searched when the search has neither an id nor a service label
+	Service string `yaml:"service,omitempty" json:"service,omitempty"`
+
+	// Tags are the labels of the search matched against the tags of the spans, e.g. http.status_code
+	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+}
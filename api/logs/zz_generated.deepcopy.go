@@ -51,220 +51,307 @@ func (in *AWSAuthentication) DeepCopy() *AWSAuthentication {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CloudWatchBackendConfig) DeepCopyInto(out *CloudWatchBackendConfig) {
+func (in *AlibabaSLSBackendConfig) DeepCopyInto(out *AlibabaSLSBackendConfig) {
 	*out = *in
 	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
-	in.Auth.DeepCopyInto(&out.Auth)
+	if in.AccessKeyID != nil {
+		in, out := &in.AccessKeyID, &out.AccessKeyID
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AccessKeySecret != nil {
+		in, out := &in.AccessKeySecret, &out.AccessKeySecret
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SecurityToken != nil {
+		in, out := &in.SecurityToken, &out.SecurityToken
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudWatchBackendConfig.
-func (in *CloudWatchBackendConfig) DeepCopy() *CloudWatchBackendConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlibabaSLSBackendConfig.
+func (in *AlibabaSLSBackendConfig) DeepCopy() *AlibabaSLSBackendConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(CloudWatchBackendConfig)
+	out := new(AlibabaSLSBackendConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CommonBackend) DeepCopyInto(out *CommonBackend) {
+func (in *AppDynamicsBackendConfig) DeepCopyInto(out *AppDynamicsBackendConfig) {
 	*out = *in
-	if in.Routes != nil {
-		in, out := &in.Routes, &out.Routes
-		*out = make(Routes, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.APIKey != nil {
+		in, out := &in.APIKey, &out.APIKey
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
 	}
-	if in.Labels != nil {
-		in, out := &in.Labels, &out.Labels
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+	if in.Experiences != nil {
+		in, out := &in.Experiences, &out.Experiences
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommonBackend.
-func (in *CommonBackend) DeepCopy() *CommonBackend {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppDynamicsBackendConfig.
+func (in *AppDynamicsBackendConfig) DeepCopy() *AppDynamicsBackendConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(CommonBackend)
+	out := new(AppDynamicsBackendConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ElasticSearchBackendConfig) DeepCopyInto(out *ElasticSearchBackendConfig) {
+func (in *ArchiveObjects) DeepCopyInto(out *ArchiveObjects) {
 	*out = *in
-	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
 	in.Fields.DeepCopyInto(&out.Fields)
-	if in.CloudID != nil {
-		in, out := &in.CloudID, &out.CloudID
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ArchiveObjects.
+func (in *ArchiveObjects) DeepCopy() *ArchiveObjects {
+	if in == nil {
+		return nil
+	}
+	out := new(ArchiveObjects)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AthenaBackendConfig) DeepCopyInto(out *AthenaBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.Auth.DeepCopyInto(&out.Auth)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AthenaBackendConfig.
+func (in *AthenaBackendConfig) DeepCopy() *AthenaBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AthenaBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureAuthentication) DeepCopyInto(out *AzureAuthentication) {
+	*out = *in
+	if in.ClientID != nil {
+		in, out := &in.ClientID, &out.ClientID
 		*out = new(kommons.EnvVar)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.APIKey != nil {
-		in, out := &in.APIKey, &out.APIKey
+	if in.ClientSecret != nil {
+		in, out := &in.ClientSecret, &out.ClientSecret
 		*out = new(kommons.EnvVar)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.Username != nil {
-		in, out := &in.Username, &out.Username
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureAuthentication.
+func (in *AzureAuthentication) DeepCopy() *AzureAuthentication {
+	if in == nil {
+		return nil
+	}
+	out := new(AzureAuthentication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AzureBlobArchiveBackendConfig) DeepCopyInto(out *AzureBlobArchiveBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.ArchiveObjects.DeepCopyInto(&out.ArchiveObjects)
+	if in.SASToken != nil {
+		in, out := &in.SASToken, &out.SASToken
 		*out = new(kommons.EnvVar)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.Password != nil {
-		in, out := &in.Password, &out.Password
-		*out = new(kommons.EnvVar)
+	if in.Auth != nil {
+		in, out := &in.Auth, &out.Auth
+		*out = new(AzureAuthentication)
 		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticSearchBackendConfig.
-func (in *ElasticSearchBackendConfig) DeepCopy() *ElasticSearchBackendConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureBlobArchiveBackendConfig.
+func (in *AzureBlobArchiveBackendConfig) DeepCopy() *AzureBlobArchiveBackendConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(ElasticSearchBackendConfig)
+	out := new(AzureBlobArchiveBackendConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ElasticSearchFields) DeepCopyInto(out *ElasticSearchFields) {
+func (in *AzureLogAnalyticsBackendConfig) DeepCopyInto(out *AzureLogAnalyticsBackendConfig) {
 	*out = *in
-	if in.Exclusions != nil {
-		in, out := &in.Exclusions, &out.Exclusions
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	in.Auth.DeepCopyInto(&out.Auth)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticSearchFields.
-func (in *ElasticSearchFields) DeepCopy() *ElasticSearchFields {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AzureLogAnalyticsBackendConfig.
+func (in *AzureLogAnalyticsBackendConfig) DeepCopy() *AzureLogAnalyticsBackendConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(ElasticSearchFields)
+	out := new(AzureLogAnalyticsBackendConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *FileSearchBackendConfig) DeepCopyInto(out *FileSearchBackendConfig) {
+func (in *BetterStackBackendConfig) DeepCopyInto(out *BetterStackBackendConfig) {
 	*out = *in
 	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
-	if in.Paths != nil {
-		in, out := &in.Paths, &out.Paths
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileSearchBackendConfig.
-func (in *FileSearchBackendConfig) DeepCopy() *FileSearchBackendConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BetterStackBackendConfig.
+func (in *BetterStackBackendConfig) DeepCopy() *BetterStackBackendConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(FileSearchBackendConfig)
+	out := new(BetterStackBackendConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *KubernetesSearchBackendConfig) DeepCopyInto(out *KubernetesSearchBackendConfig) {
+func (in *BigQueryBackendConfig) DeepCopyInto(out *BigQueryBackendConfig) {
 	*out = *in
 	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
-	if in.Kubeconfig != nil {
-		in, out := &in.Kubeconfig, &out.Kubeconfig
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
 		*out = new(kommons.EnvVar)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.LabelColumns != nil {
+		in, out := &in.LabelColumns, &out.LabelColumns
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesSearchBackendConfig.
-func (in *KubernetesSearchBackendConfig) DeepCopy() *KubernetesSearchBackendConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BigQueryBackendConfig.
+func (in *BigQueryBackendConfig) DeepCopy() *BigQueryBackendConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(KubernetesSearchBackendConfig)
+	out := new(BigQueryBackendConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *OpenSearchBackendConfig) DeepCopyInto(out *OpenSearchBackendConfig) {
+func (in *CRIBackendConfig) DeepCopyInto(out *CRIBackendConfig) {
 	*out = *in
 	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
-	in.Fields.DeepCopyInto(&out.Fields)
-	if in.Username != nil {
-		in, out := &in.Username, &out.Username
-		*out = new(kommons.EnvVar)
-		(*in).DeepCopyInto(*out)
-	}
-	if in.Password != nil {
-		in, out := &in.Password, &out.Password
-		*out = new(kommons.EnvVar)
-		(*in).DeepCopyInto(*out)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CRIBackendConfig.
+func (in *CRIBackendConfig) DeepCopy() *CRIBackendConfig {
+	if in == nil {
+		return nil
 	}
+	out := new(CRIBackendConfig)
+	in.DeepCopyInto(out)
+	return out
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenSearchBackendConfig.
-func (in *OpenSearchBackendConfig) DeepCopy() *OpenSearchBackendConfig {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudWatchBackendConfig) DeepCopyInto(out *CloudWatchBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.Auth.DeepCopyInto(&out.Auth)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudWatchBackendConfig.
+func (in *CloudWatchBackendConfig) DeepCopy() *CloudWatchBackendConfig {
 	if in == nil {
 		return nil
 	}
-	out := new(OpenSearchBackendConfig)
+	out := new(CloudWatchBackendConfig)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SearchBackendConfig) DeepCopyInto(out *SearchBackendConfig) {
+func (in *CloudflareBackendConfig) DeepCopyInto(out *CloudflareBackendConfig) {
 	*out = *in
-	if in.ElasticSearch != nil {
-		in, out := &in.ElasticSearch, &out.ElasticSearch
-		*out = new(ElasticSearchBackendConfig)
-		(*in).DeepCopyInto(*out)
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.Fields != nil {
+		in, out := &in.Fields, &out.Fields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	if in.OpenSearch != nil {
-		in, out := &in.OpenSearch, &out.OpenSearch
-		*out = new(OpenSearchBackendConfig)
+	if in.R2 != nil {
+		in, out := &in.R2, &out.R2
+		*out = new(CloudflareR2Config)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.CloudWatch != nil {
-		in, out := &in.CloudWatch, &out.CloudWatch
-		*out = new(CloudWatchBackendConfig)
-		(*in).DeepCopyInto(*out)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareBackendConfig.
+func (in *CloudflareBackendConfig) DeepCopy() *CloudflareBackendConfig {
+	if in == nil {
+		return nil
 	}
-	if in.Kubernetes != nil {
-		in, out := &in.Kubernetes, &out.Kubernetes
-		*out = new(KubernetesSearchBackendConfig)
+	out := new(CloudflareBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudflareR2Config) DeepCopyInto(out *CloudflareR2Config) {
+	*out = *in
+	if in.AccessKeyID != nil {
+		in, out := &in.AccessKeyID, &out.AccessKeyID
+		*out = new(kommons.EnvVar)
 		(*in).DeepCopyInto(*out)
 	}
-	if in.File != nil {
-		in, out := &in.File, &out.File
-		*out = new(FileSearchBackendConfig)
+	if in.SecretAccessKey != nil {
+		in, out := &in.SecretAccessKey, &out.SecretAccessKey
+		*out = new(kommons.EnvVar)
 		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchBackendConfig.
-func (in *SearchBackendConfig) DeepCopy() *SearchBackendConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudflareR2Config.
+func (in *CloudflareR2Config) DeepCopy() *CloudflareR2Config {
 	if in == nil {
 		return nil
 	}
-	out := new(SearchBackendConfig)
+	out := new(CloudflareR2Config)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SearchRoute) DeepCopyInto(out *SearchRoute) {
+func (in *CommonBackend) DeepCopyInto(out *CommonBackend) {
 	*out = *in
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make(Routes, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Labels != nil {
 		in, out := &in.Labels, &out.Labels
 		*out = make(map[string]string, len(*in))
@@ -272,14 +359,1827 @@ func (in *SearchRoute) DeepCopyInto(out *SearchRoute) {
 			(*out)[key] = val
 		}
 	}
+	if in.Pipeline != nil {
+		in, out := &in.Pipeline, &out.Pipeline
+		*out = make([]PipelineStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Transform != nil {
+		in, out := &in.Transform, &out.Transform
+		*out = make([]TransformStep, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Concurrency != nil {
+		in, out := &in.Concurrency, &out.Concurrency
+		*out = new(ConcurrencyConfig)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchRoute.
-func (in *SearchRoute) DeepCopy() *SearchRoute {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommonBackend.
+func (in *CommonBackend) DeepCopy() *CommonBackend {
 	if in == nil {
 		return nil
 	}
-	out := new(SearchRoute)
+	out := new(CommonBackend)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConcurrencyConfig) DeepCopyInto(out *ConcurrencyConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConcurrencyConfig.
+func (in *ConcurrencyConfig) DeepCopy() *ConcurrencyConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ConcurrencyConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControlPlaneBackendConfig) DeepCopyInto(out *ControlPlaneBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.AWS.DeepCopyInto(&out.AWS)
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	in.Azure.DeepCopyInto(&out.Azure)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControlPlaneBackendConfig.
+func (in *ControlPlaneBackendConfig) DeepCopy() *ControlPlaneBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ControlPlaneBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CoralogixBackendConfig) DeepCopyInto(out *CoralogixBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CoralogixBackendConfig.
+func (in *CoralogixBackendConfig) DeepCopy() *CoralogixBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(CoralogixBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DockerBackendConfig) DeepCopyInto(out *DockerBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DockerBackendConfig.
+func (in *DockerBackendConfig) DeepCopy() *DockerBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DockerBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DropStep) DeepCopyInto(out *DropStep) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]FilterRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Keep != nil {
+		in, out := &in.Keep, &out.Keep
+		*out = make([]FilterRule, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DropStep.
+func (in *DropStep) DeepCopy() *DropStep {
+	if in == nil {
+		return nil
+	}
+	out := new(DropStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DynatraceBackendConfig) DeepCopyInto(out *DynatraceBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.APIToken != nil {
+		in, out := &in.APIToken, &out.APIToken
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Entities != nil {
+		in, out := &in.Entities, &out.Entities
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DynatraceBackendConfig.
+func (in *DynatraceBackendConfig) DeepCopy() *DynatraceBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DynatraceBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticAPMBackendConfig) DeepCopyInto(out *ElasticAPMBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.CloudID != nil {
+		in, out := &in.CloudID, &out.CloudID
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.APIKey != nil {
+		in, out := &in.APIKey, &out.APIKey
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Username != nil {
+		in, out := &in.Username, &out.Username
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticAPMBackendConfig.
+func (in *ElasticAPMBackendConfig) DeepCopy() *ElasticAPMBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticAPMBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticSearchBackendConfig) DeepCopyInto(out *ElasticSearchBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.Fields.DeepCopyInto(&out.Fields)
+	if in.CloudID != nil {
+		in, out := &in.CloudID, &out.CloudID
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.APIKey != nil {
+		in, out := &in.APIKey, &out.APIKey
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Username != nil {
+		in, out := &in.Username, &out.Username
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticSearchBackendConfig.
+func (in *ElasticSearchBackendConfig) DeepCopy() *ElasticSearchBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticSearchBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ElasticSearchFields) DeepCopyInto(out *ElasticSearchFields) {
+	*out = *in
+	if in.Exclusions != nil {
+		in, out := &in.Exclusions, &out.Exclusions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ElasticSearchFields.
+func (in *ElasticSearchFields) DeepCopy() *ElasticSearchFields {
+	if in == nil {
+		return nil
+	}
+	out := new(ElasticSearchFields)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EventHubsBackendConfig) DeepCopyInto(out *EventHubsBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.ConnectionString != nil {
+		in, out := &in.ConnectionString, &out.ConnectionString
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Partitions != nil {
+		in, out := &in.Partitions, &out.Partitions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EventHubsBackendConfig.
+func (in *EventHubsBackendConfig) DeepCopy() *EventHubsBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EventHubsBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExtractStep) DeepCopyInto(out *ExtractStep) {
+	*out = *in
+	if in.Patterns != nil {
+		in, out := &in.Patterns, &out.Patterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExtractStep.
+func (in *ExtractStep) DeepCopy() *ExtractStep {
+	if in == nil {
+		return nil
+	}
+	out := new(ExtractStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FileSearchBackendConfig) DeepCopyInto(out *FileSearchBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FileSearchBackendConfig.
+func (in *FileSearchBackendConfig) DeepCopy() *FileSearchBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(FileSearchBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FilterRule) DeepCopyInto(out *FilterRule) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FilterRule.
+func (in *FilterRule) DeepCopy() *FilterRule {
+	if in == nil {
+		return nil
+	}
+	out := new(FilterRule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCSArchiveBackendConfig) DeepCopyInto(out *GCSArchiveBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.ArchiveObjects.DeepCopyInto(&out.ArchiveObjects)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCSArchiveBackendConfig.
+func (in *GCSArchiveBackendConfig) DeepCopy() *GCSArchiveBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GCSArchiveBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GeoIPStep) DeepCopyInto(out *GeoIPStep) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GeoIPStep.
+func (in *GeoIPStep) DeepCopy() *GeoIPStep {
+	if in == nil {
+		return nil
+	}
+	out := new(GeoIPStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitHubActionsBackendConfig) DeepCopyInto(out *GitHubActionsBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitHubActionsBackendConfig.
+func (in *GitHubActionsBackendConfig) DeepCopy() *GitHubActionsBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GitHubActionsBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GitLabBackendConfig) DeepCopyInto(out *GitLabBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GitLabBackendConfig.
+func (in *GitLabBackendConfig) DeepCopy() *GitLabBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GitLabBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GoogleCloudLoggingBackendConfig) DeepCopyInto(out *GoogleCloudLoggingBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GoogleCloudLoggingBackendConfig.
+func (in *GoogleCloudLoggingBackendConfig) DeepCopy() *GoogleCloudLoggingBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GoogleCloudLoggingBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GrokStep) DeepCopyInto(out *GrokStep) {
+	*out = *in
+	if in.Patterns != nil {
+		in, out := &in.Patterns, &out.Patterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Definitions != nil {
+		in, out := &in.Definitions, &out.Definitions
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GrokStep.
+func (in *GrokStep) DeepCopy() *GrokStep {
+	if in == nil {
+		return nil
+	}
+	out := new(GrokStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPBackendConfig) DeepCopyInto(out *HTTPBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	in.Fields.DeepCopyInto(&out.Fields)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPBackendConfig.
+func (in *HTTPBackendConfig) DeepCopy() *HTTPBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPConnection) DeepCopyInto(out *HTTPConnection) {
+	*out = *in
+	if in.Token != nil {
+		in, out := &in.Token, &out.Token
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Username != nil {
+		in, out := &in.Username, &out.Username
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]kommons.EnvVar, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPConnection.
+func (in *HTTPConnection) DeepCopy() *HTTPConnection {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPConnection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPFields) DeepCopyInto(out *HTTPFields) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPFields.
+func (in *HTTPFields) DeepCopy() *HTTPFields {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPFields)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HerokuBackendConfig) DeepCopyInto(out *HerokuBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HerokuBackendConfig.
+func (in *HerokuBackendConfig) DeepCopy() *HerokuBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(HerokuBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JSONStep) DeepCopyInto(out *JSONStep) {
+	*out = *in
+	if in.Keys != nil {
+		in, out := &in.Keys, &out.Keys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JSONStep.
+func (in *JSONStep) DeepCopy() *JSONStep {
+	if in == nil {
+		return nil
+	}
+	out := new(JSONStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JaegerBackendConfig) DeepCopyInto(out *JaegerBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JaegerBackendConfig.
+func (in *JaegerBackendConfig) DeepCopy() *JaegerBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(JaegerBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JenkinsBackendConfig) DeepCopyInto(out *JenkinsBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JenkinsBackendConfig.
+func (in *JenkinsBackendConfig) DeepCopy() *JenkinsBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(JenkinsBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *JournaldBackendConfig) DeepCopyInto(out *JournaldBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new JournaldBackendConfig.
+func (in *JournaldBackendConfig) DeepCopy() *JournaldBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(JournaldBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubernetesAuditBackendConfig) DeepCopyInto(out *KubernetesAuditBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesAuditBackendConfig.
+func (in *KubernetesAuditBackendConfig) DeepCopy() *KubernetesAuditBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubernetesAuditBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubernetesNodeBackendConfig) DeepCopyInto(out *KubernetesNodeBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.Kubeconfig != nil {
+		in, out := &in.Kubeconfig, &out.Kubeconfig
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Services != nil {
+		in, out := &in.Services, &out.Services
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesNodeBackendConfig.
+func (in *KubernetesNodeBackendConfig) DeepCopy() *KubernetesNodeBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubernetesNodeBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KubernetesSearchBackendConfig) DeepCopyInto(out *KubernetesSearchBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.Kubeconfig != nil {
+		in, out := &in.Kubeconfig, &out.Kubeconfig
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KubernetesSearchBackendConfig.
+func (in *KubernetesSearchBackendConfig) DeepCopy() *KubernetesSearchBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(KubernetesSearchBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LabelMapStep) DeepCopyInto(out *LabelMapStep) {
+	*out = *in
+	if in.Rename != nil {
+		in, out := &in.Rename, &out.Rename
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Drop != nil {
+		in, out := &in.Drop, &out.Drop
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Keep != nil {
+		in, out := &in.Keep, &out.Keep
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LabelMapStep.
+func (in *LabelMapStep) DeepCopy() *LabelMapStep {
+	if in == nil {
+		return nil
+	}
+	out := new(LabelMapStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LogScaleBackendConfig) DeepCopyInto(out *LogScaleBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogScaleBackendConfig.
+func (in *LogScaleBackendConfig) DeepCopy() *LogScaleBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LogScaleBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LogzioBackendConfig) DeepCopyInto(out *LogzioBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.APIToken != nil {
+		in, out := &in.APIToken, &out.APIToken
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Accounts != nil {
+		in, out := &in.Accounts, &out.Accounts
+		*out = make(map[string]kommons.EnvVar, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	in.Fields.DeepCopyInto(&out.Fields)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LogzioBackendConfig.
+func (in *LogzioBackendConfig) DeepCopy() *LogzioBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LogzioBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NATSBackendConfig) DeepCopyInto(out *NATSBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Token != nil {
+		in, out := &in.Token, &out.Token
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Username != nil {
+		in, out := &in.Username, &out.Username
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SubjectLabels != nil {
+		in, out := &in.SubjectLabels, &out.SubjectLabels
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NATSBackendConfig.
+func (in *NATSBackendConfig) DeepCopy() *NATSBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(NATSBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCIAuthentication) DeepCopyInto(out *OCIAuthentication) {
+	*out = *in
+	if in.PrivateKey != nil {
+		in, out := &in.PrivateKey, &out.PrivateKey
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Passphrase != nil {
+		in, out := &in.Passphrase, &out.Passphrase
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCIAuthentication.
+func (in *OCIAuthentication) DeepCopy() *OCIAuthentication {
+	if in == nil {
+		return nil
+	}
+	out := new(OCIAuthentication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCILoggingBackendConfig) DeepCopyInto(out *OCILoggingBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	in.Auth.DeepCopyInto(&out.Auth)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCILoggingBackendConfig.
+func (in *OCILoggingBackendConfig) DeepCopy() *OCILoggingBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OCILoggingBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpenSearchBackendConfig) DeepCopyInto(out *OpenSearchBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.Fields.DeepCopyInto(&out.Fields)
+	if in.Username != nil {
+		in, out := &in.Username, &out.Username
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpenSearchBackendConfig.
+func (in *OpenSearchBackendConfig) DeepCopy() *OpenSearchBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OpenSearchBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PapertrailBackendConfig) DeepCopyInto(out *PapertrailBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.APIToken != nil {
+		in, out := &in.APIToken, &out.APIToken
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PapertrailBackendConfig.
+func (in *PapertrailBackendConfig) DeepCopy() *PapertrailBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PapertrailBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PipelineStep) DeepCopyInto(out *PipelineStep) {
+	*out = *in
+	if in.Redact != nil {
+		in, out := &in.Redact, &out.Redact
+		*out = new(RedactStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Grok != nil {
+		in, out := &in.Grok, &out.Grok
+		*out = new(GrokStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Extract != nil {
+		in, out := &in.Extract, &out.Extract
+		*out = new(ExtractStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.JSON != nil {
+		in, out := &in.JSON, &out.JSON
+		*out = new(JSONStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Severity != nil {
+		in, out := &in.Severity, &out.Severity
+		*out = new(SeverityStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Timestamp != nil {
+		in, out := &in.Timestamp, &out.Timestamp
+		*out = new(TimestampStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GeoIP != nil {
+		in, out := &in.GeoIP, &out.GeoIP
+		*out = new(GeoIPStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Truncate != nil {
+		in, out := &in.Truncate, &out.Truncate
+		*out = new(TruncateStep)
+		**out = **in
+	}
+	if in.LabelMap != nil {
+		in, out := &in.LabelMap, &out.LabelMap
+		*out = new(LabelMapStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Drop != nil {
+		in, out := &in.Drop, &out.Drop
+		*out = new(DropStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sanitize != nil {
+		in, out := &in.Sanitize, &out.Sanitize
+		*out = new(SanitizeStep)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WASM != nil {
+		in, out := &in.WASM, &out.WASM
+		*out = new(WASMStep)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PipelineStep.
+func (in *PipelineStep) DeepCopy() *PipelineStep {
+	if in == nil {
+		return nil
+	}
+	out := new(PipelineStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginBackendConfig) DeepCopyInto(out *PluginBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]kommons.EnvVar, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginBackendConfig.
+func (in *PluginBackendConfig) DeepCopy() *PluginBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgresBackendConfig) DeepCopyInto(out *PostgresBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.Connection.DeepCopyInto(&out.Connection)
+	if in.LabelKeys != nil {
+		in, out := &in.LabelKeys, &out.LabelKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgresBackendConfig.
+func (in *PostgresBackendConfig) DeepCopy() *PostgresBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgresBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrometheusBackendConfig) DeepCopyInto(out *PrometheusBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrometheusBackendConfig.
+func (in *PrometheusBackendConfig) DeepCopy() *PrometheusBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(PrometheusBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RedactStep) DeepCopyInto(out *RedactStep) {
+	*out = *in
+	if in.Builtin != nil {
+		in, out := &in.Builtin, &out.Builtin
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Patterns != nil {
+		in, out := &in.Patterns, &out.Patterns
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RedactStep.
+func (in *RedactStep) DeepCopy() *RedactStep {
+	if in == nil {
+		return nil
+	}
+	out := new(RedactStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteBackendConfig) DeepCopyInto(out *RemoteBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.Token != nil {
+		in, out := &in.Token, &out.Token
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteBackendConfig.
+func (in *RemoteBackendConfig) DeepCopy() *RemoteBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RemoteBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RollbarBackendConfig) DeepCopyInto(out *RollbarBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.AccessToken != nil {
+		in, out := &in.AccessToken, &out.AccessToken
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RollbarBackendConfig.
+func (in *RollbarBackendConfig) DeepCopy() *RollbarBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RollbarBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3ArchiveBackendConfig) DeepCopyInto(out *S3ArchiveBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.ArchiveObjects.DeepCopyInto(&out.ArchiveObjects)
+	in.Auth.DeepCopyInto(&out.Auth)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3ArchiveBackendConfig.
+func (in *S3ArchiveBackendConfig) DeepCopy() *S3ArchiveBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(S3ArchiveBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SQLBackendConfig) DeepCopyInto(out *SQLBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.Connection.DeepCopyInto(&out.Connection)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SQLBackendConfig.
+func (in *SQLBackendConfig) DeepCopy() *SQLBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SQLBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SQLiteBackendConfig) DeepCopyInto(out *SQLiteBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SQLiteBackendConfig.
+func (in *SQLiteBackendConfig) DeepCopy() *SQLiteBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SQLiteBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SSHBackendConfig) DeepCopyInto(out *SSHBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.Hosts != nil {
+		in, out := &in.Hosts, &out.Hosts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PrivateKey != nil {
+		in, out := &in.PrivateKey, &out.PrivateKey
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Passphrase != nil {
+		in, out := &in.Passphrase, &out.Passphrase
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Password != nil {
+		in, out := &in.Password, &out.Password
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KnownHosts != nil {
+		in, out := &in.KnownHosts, &out.KnownHosts
+		*out = new(kommons.EnvVar)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SSHBackendConfig.
+func (in *SSHBackendConfig) DeepCopy() *SSHBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SSHBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SanitizeStep) DeepCopyInto(out *SanitizeStep) {
+	*out = *in
+	if in.Strip != nil {
+		in, out := &in.Strip, &out.Strip
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SanitizeStep.
+func (in *SanitizeStep) DeepCopy() *SanitizeStep {
+	if in == nil {
+		return nil
+	}
+	out := new(SanitizeStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SearchBackendConfig) DeepCopyInto(out *SearchBackendConfig) {
+	*out = *in
+	if in.ElasticSearch != nil {
+		in, out := &in.ElasticSearch, &out.ElasticSearch
+		*out = new(ElasticSearchBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OpenSearch != nil {
+		in, out := &in.OpenSearch, &out.OpenSearch
+		*out = new(OpenSearchBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CloudWatch != nil {
+		in, out := &in.CloudWatch, &out.CloudWatch
+		*out = new(CloudWatchBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Kubernetes != nil {
+		in, out := &in.Kubernetes, &out.Kubernetes
+		*out = new(KubernetesSearchBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.File != nil {
+		in, out := &in.File, &out.File
+		*out = new(FileSearchBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Store != nil {
+		in, out := &in.Store, &out.Store
+		*out = new(StoreBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Remote != nil {
+		in, out := &in.Remote, &out.Remote
+		*out = new(RemoteBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Jaeger != nil {
+		in, out := &in.Jaeger, &out.Jaeger
+		*out = new(JaegerBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Tempo != nil {
+		in, out := &in.Tempo, &out.Tempo
+		*out = new(TempoBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Zipkin != nil {
+		in, out := &in.Zipkin, &out.Zipkin
+		*out = new(ZipkinBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Prometheus != nil {
+		in, out := &in.Prometheus, &out.Prometheus
+		*out = new(PrometheusBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sentry != nil {
+		in, out := &in.Sentry, &out.Sentry
+		*out = new(SentryBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Rollbar != nil {
+		in, out := &in.Rollbar, &out.Rollbar
+		*out = new(RollbarBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EventHubs != nil {
+		in, out := &in.EventHubs, &out.EventHubs
+		*out = new(EventHubsBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cloudflare != nil {
+		in, out := &in.Cloudflare, &out.Cloudflare
+		*out = new(CloudflareBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AzureLogAnalytics != nil {
+		in, out := &in.AzureLogAnalytics, &out.AzureLogAnalytics
+		*out = new(AzureLogAnalyticsBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LogScale != nil {
+		in, out := &in.LogScale, &out.LogScale
+		*out = new(LogScaleBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Coralogix != nil {
+		in, out := &in.Coralogix, &out.Coralogix
+		*out = new(CoralogixBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Logzio != nil {
+		in, out := &in.Logzio, &out.Logzio
+		*out = new(LogzioBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BetterStack != nil {
+		in, out := &in.BetterStack, &out.BetterStack
+		*out = new(BetterStackBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Papertrail != nil {
+		in, out := &in.Papertrail, &out.Papertrail
+		*out = new(PapertrailBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SigNoz != nil {
+		in, out := &in.SigNoz, &out.SigNoz
+		*out = new(SigNozBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ZincSearch != nil {
+		in, out := &in.ZincSearch, &out.ZincSearch
+		*out = new(ZincSearchBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Postgres != nil {
+		in, out := &in.Postgres, &out.Postgres
+		*out = new(PostgresBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SQL != nil {
+		in, out := &in.SQL, &out.SQL
+		*out = new(SQLBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SQLite != nil {
+		in, out := &in.SQLite, &out.SQLite
+		*out = new(SQLiteBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BigQuery != nil {
+		in, out := &in.BigQuery, &out.BigQuery
+		*out = new(BigQueryBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Athena != nil {
+		in, out := &in.Athena, &out.Athena
+		*out = new(AthenaBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.S3Archive != nil {
+		in, out := &in.S3Archive, &out.S3Archive
+		*out = new(S3ArchiveBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GCSArchive != nil {
+		in, out := &in.GCSArchive, &out.GCSArchive
+		*out = new(GCSArchiveBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AzureBlobArchive != nil {
+		in, out := &in.AzureBlobArchive, &out.AzureBlobArchive
+		*out = new(AzureBlobArchiveBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NATS != nil {
+		in, out := &in.NATS, &out.NATS
+		*out = new(NATSBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Journald != nil {
+		in, out := &in.Journald, &out.Journald
+		*out = new(JournaldBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Docker != nil {
+		in, out := &in.Docker, &out.Docker
+		*out = new(DockerBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CRI != nil {
+		in, out := &in.CRI, &out.CRI
+		*out = new(CRIBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SSH != nil {
+		in, out := &in.SSH, &out.SSH
+		*out = new(SSHBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HTTP != nil {
+		in, out := &in.HTTP, &out.HTTP
+		*out = new(HTTPBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Plugin != nil {
+		in, out := &in.Plugin, &out.Plugin
+		*out = new(PluginBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.WASM != nil {
+		in, out := &in.WASM, &out.WASM
+		*out = new(WASMBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GitHubActions != nil {
+		in, out := &in.GitHubActions, &out.GitHubActions
+		*out = new(GitHubActionsBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GitLab != nil {
+		in, out := &in.GitLab, &out.GitLab
+		*out = new(GitLabBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Jenkins != nil {
+		in, out := &in.Jenkins, &out.Jenkins
+		*out = new(JenkinsBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KubernetesAudit != nil {
+		in, out := &in.KubernetesAudit, &out.KubernetesAudit
+		*out = new(KubernetesAuditBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.KubernetesNode != nil {
+		in, out := &in.KubernetesNode, &out.KubernetesNode
+		*out = new(KubernetesNodeBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GoogleCloudLogging != nil {
+		in, out := &in.GoogleCloudLogging, &out.GoogleCloudLogging
+		*out = new(GoogleCloudLoggingBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ControlPlane != nil {
+		in, out := &in.ControlPlane, &out.ControlPlane
+		*out = new(ControlPlaneBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ElasticAPM != nil {
+		in, out := &in.ElasticAPM, &out.ElasticAPM
+		*out = new(ElasticAPMBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Dynatrace != nil {
+		in, out := &in.Dynatrace, &out.Dynatrace
+		*out = new(DynatraceBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AppDynamics != nil {
+		in, out := &in.AppDynamics, &out.AppDynamics
+		*out = new(AppDynamicsBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OCILogging != nil {
+		in, out := &in.OCILogging, &out.OCILogging
+		*out = new(OCILoggingBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AlibabaSLS != nil {
+		in, out := &in.AlibabaSLS, &out.AlibabaSLS
+		*out = new(AlibabaSLSBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Heroku != nil {
+		in, out := &in.Heroku, &out.Heroku
+		*out = new(HerokuBackendConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchBackendConfig.
+func (in *SearchBackendConfig) DeepCopy() *SearchBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SearchBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SearchRoute) DeepCopyInto(out *SearchRoute) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchRoute.
+func (in *SearchRoute) DeepCopy() *SearchRoute {
+	if in == nil {
+		return nil
+	}
+	out := new(SearchRoute)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SentryBackendConfig) DeepCopyInto(out *SentryBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SentryBackendConfig.
+func (in *SentryBackendConfig) DeepCopy() *SentryBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SentryBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SeverityStep) DeepCopyInto(out *SeverityStep) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Mapping != nil {
+		in, out := &in.Mapping, &out.Mapping
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SeverityStep.
+func (in *SeverityStep) DeepCopy() *SeverityStep {
+	if in == nil {
+		return nil
+	}
+	out := new(SeverityStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SigNozBackendConfig) DeepCopyInto(out *SigNozBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Attributes != nil {
+		in, out := &in.Attributes, &out.Attributes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SigNozBackendConfig.
+func (in *SigNozBackendConfig) DeepCopy() *SigNozBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(SigNozBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StoreBackendConfig) DeepCopyInto(out *StoreBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StoreBackendConfig.
+func (in *StoreBackendConfig) DeepCopy() *StoreBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(StoreBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TempoBackendConfig) DeepCopyInto(out *TempoBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TempoBackendConfig.
+func (in *TempoBackendConfig) DeepCopy() *TempoBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(TempoBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TimestampStep) DeepCopyInto(out *TimestampStep) {
+	*out = *in
+	if in.Layouts != nil {
+		in, out := &in.Layouts, &out.Layouts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TimestampStep.
+func (in *TimestampStep) DeepCopy() *TimestampStep {
+	if in == nil {
+		return nil
+	}
+	out := new(TimestampStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TransformStep) DeepCopyInto(out *TransformStep) {
+	*out = *in
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(TransformTemplate)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransformStep.
+func (in *TransformStep) DeepCopy() *TransformStep {
+	if in == nil {
+		return nil
+	}
+	out := new(TransformStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TransformTemplate) DeepCopyInto(out *TransformTemplate) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransformTemplate.
+func (in *TransformTemplate) DeepCopy() *TransformTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(TransformTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TruncateStep) DeepCopyInto(out *TruncateStep) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TruncateStep.
+func (in *TruncateStep) DeepCopy() *TruncateStep {
+	if in == nil {
+		return nil
+	}
+	out := new(TruncateStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WASMBackendConfig) DeepCopyInto(out *WASMBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WASMBackendConfig.
+func (in *WASMBackendConfig) DeepCopy() *WASMBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(WASMBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WASMStep) DeepCopyInto(out *WASMStep) {
+	*out = *in
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WASMStep.
+func (in *WASMStep) DeepCopy() *WASMStep {
+	if in == nil {
+		return nil
+	}
+	out := new(WASMStep)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZincSearchBackendConfig) DeepCopyInto(out *ZincSearchBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	in.Fields.DeepCopyInto(&out.Fields)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZincSearchBackendConfig.
+func (in *ZincSearchBackendConfig) DeepCopy() *ZincSearchBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ZincSearchBackendConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ZipkinBackendConfig) DeepCopyInto(out *ZipkinBackendConfig) {
+	*out = *in
+	in.CommonBackend.DeepCopyInto(&out.CommonBackend)
+	in.HTTPConnection.DeepCopyInto(&out.HTTPConnection)
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ZipkinBackendConfig.
+func (in *ZipkinBackendConfig) DeepCopy() *ZipkinBackendConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ZipkinBackendConfig)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -4,6 +4,8 @@ import (
 	"os"
 
 	"github.com/flanksource/apm-hub/db"
+	"github.com/flanksource/apm-hub/pkg/analytics"
+	"github.com/flanksource/apm-hub/pkg/slowquery"
 	"github.com/flanksource/commons/logger"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
@@ -22,6 +24,8 @@ var metricsPort int
 func ServerFlags(flags *pflag.FlagSet) {
 	flags.IntVar(&httpPort, "httpPort", 8080, "Port to expose the http server")
 	flags.IntVar(&metricsPort, "metricsPort", 8081, "Port to expose a health dashboard")
+	slowquery.Flags(flags)
+	analytics.Flags(flags)
 }
 
 func readFromEnv(v string) string {
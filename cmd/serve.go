@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"crypto/tls"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -8,13 +10,35 @@ import (
 	"github.com/flanksource/apm-hub/api/logs"
 	"github.com/flanksource/apm-hub/db"
 	"github.com/flanksource/apm-hub/pkg"
+	"github.com/flanksource/apm-hub/pkg/alert"
+	"github.com/flanksource/apm-hub/pkg/annotation"
+	"github.com/flanksource/apm-hub/pkg/anomaly"
+	"github.com/flanksource/apm-hub/pkg/audit"
+	"github.com/flanksource/apm-hub/pkg/auth"
+	"github.com/flanksource/apm-hub/pkg/configdb"
+	"github.com/flanksource/apm-hub/pkg/export"
+	"github.com/flanksource/apm-hub/pkg/forward"
+	"github.com/flanksource/apm-hub/pkg/guardrail"
+	"github.com/flanksource/apm-hub/pkg/health"
+	"github.com/flanksource/apm-hub/pkg/ingest"
+	"github.com/flanksource/apm-hub/pkg/link"
+	"github.com/flanksource/apm-hub/pkg/mapper"
+	"github.com/flanksource/apm-hub/pkg/missioncontrol"
+	"github.com/flanksource/apm-hub/pkg/notification"
+	"github.com/flanksource/apm-hub/pkg/pipeline"
+	"github.com/flanksource/apm-hub/pkg/ratelimit"
+	"github.com/flanksource/apm-hub/pkg/slowquery"
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/kommons"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 
 	"github.com/labstack/echo/v4"
 )
 
+// serverConfig is the server configuration merged from all the config files
+var serverConfig logs.ServerConfig
+
 var Serve = &cobra.Command{
 	Use:   "serve config.yaml",
 	Short: "Start the for querying the logs",
@@ -39,6 +63,8 @@ func runServe(cmd *cobra.Command, configFiles []string) {
 				continue
 			}
 
+			serverConfig.Merge(config.ServerConfig)
+
 			err = db.PersistLoggingBackendConfigFile(*config)
 			if err != nil {
 				logger.Errorf("error persisting backend to file: %v", err)
@@ -54,11 +80,33 @@ func runServe(cmd *cobra.Command, configFiles []string) {
 
 	server := SetupServer(kommonsClient)
 	addr := "0.0.0.0:" + strconv.Itoa(httpPort)
+	if tlsConfig := newTLSConfig(); tlsConfig != nil {
+		server.Logger.Fatal(server.StartServer(&http.Server{Addr: addr, TLSConfig: tlsConfig}))
+	}
 	server.Logger.Fatal(server.Start(addr))
 }
 
+// newTLSConfig returns the tls config of the http and gRPC servers, nil when tls isn't configured
+func newTLSConfig() *tls.Config {
+	if serverConfig.TLS == nil {
+		return nil
+	}
+
+	tlsConfig, err := auth.NewTLSConfig(*serverConfig.TLS)
+	if err != nil {
+		logger.Fatalf("error setting up tls: %v", err)
+	}
+	return tlsConfig
+}
+
 func SetupServer(kClient *kommons.Client) *echo.Echo {
 	e := echo.New()
+	ipExtractor, err := ratelimit.NewIPExtractor(serverConfig.TrustedProxies)
+	if err != nil {
+		logger.Fatalf("error setting up the trusted proxies: %v", err)
+	}
+	e.IPExtractor = ipExtractor
+
 	// Extending the context and fetching the kubeconfig client here.
 	// For more info see: https://echo.labstack.com/guide/context/#extending-context
 	e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -71,11 +119,221 @@ func SetupServer(kClient *kommons.Client) *echo.Echo {
 		}
 	})
 
+	// The IP addresses are limited before the authentication, so that the credentials can't be brute forced
+	if serverConfig.RateLimit != nil {
+		e.Use(ratelimit.NewLimiter(*serverConfig.RateLimit).IPMiddleware)
+	}
+
+	if serverConfig.TLS != nil && serverConfig.TLS.ClientCAFile != "" {
+		e.Use(auth.ClientCertMiddleware)
+	}
+
+	if serverConfig.Auth != nil && serverConfig.Auth.Enabled() {
+		authenticator, err := auth.NewAuthenticator(kClient, *serverConfig.Auth)
+		if err != nil {
+			logger.Fatalf("error setting up authentication: %v", err)
+		}
+		e.Use(authenticator.Middleware)
+	}
+
+	if serverConfig.Tenancy != nil {
+		auth.GlobalTenancy = auth.NewTenancy(*serverConfig.Tenancy)
+		e.Use(auth.GlobalTenancy.Middleware)
+	}
+
+	if serverConfig.RateLimit != nil {
+		e.Use(ratelimit.NewLimiter(*serverConfig.RateLimit).Middleware)
+	}
+
+	if serverConfig.RBAC != nil {
+		auth.GlobalAuthorizer = auth.NewAuthorizer(*serverConfig.RBAC)
+	}
+
+	if serverConfig.Guardrails != nil {
+		guardrails, err := guardrail.New(*serverConfig.Guardrails)
+		if err != nil {
+			logger.Fatalf("error setting up the guardrails: %v", err)
+		}
+		guardrail.GlobalGuardrails = guardrails
+	}
+
+	if serverConfig.Audit != nil {
+		auditor, err := audit.NewAuditor(kClient, *serverConfig.Audit)
+		if err != nil {
+			logger.Fatalf("error setting up auditing: %v", err)
+		}
+		audit.GlobalAuditor = auditor
+	}
+
+	if len(serverConfig.Pipeline) > 0 {
+		globalPipeline, err := pipeline.New("global", serverConfig.Pipeline, nil)
+		if err != nil {
+			logger.Fatalf("error setting up the global pipeline: %v", err)
+		}
+		pipeline.GlobalPipeline = globalPipeline
+	}
+
+	if serverConfig.Alerting != nil {
+		notifier, err := notification.NewNotifier(kClient, serverConfig.Alerting.Namespace, serverConfig.Alerting.Channels)
+		if err != nil {
+			logger.Fatalf("error setting up the notification channels: %v", err)
+		}
+		notification.GlobalNotifier = notifier
+
+		manager, err := alert.NewManager(*serverConfig.Alerting, notifier, pkg.SearchAll)
+		if err != nil {
+			logger.Fatalf("error setting up the alert rules: %v", err)
+		}
+		alert.GlobalManager = manager
+		manager.Start()
+	}
+
+	if serverConfig.Anomaly != nil {
+		detector, err := anomaly.NewDetector(*serverConfig.Anomaly, logs.GlobalBackends, pkg.SearchAll)
+		if err != nil {
+			logger.Fatalf("error setting up anomaly detection: %v", err)
+		}
+		if channels := serverConfig.Anomaly.Notify; len(channels) > 0 {
+			detector.OnAnomaly(func(a anomaly.Anomaly) {
+				notification.GlobalNotifier.Notify(channels, notification.Message{
+					Key:   fmt.Sprintf("anomaly/%s/%s", a.Watch, a.Kind),
+					Title: fmt.Sprintf("[anomaly] %s of %s", a.Kind, a.Watch),
+					Text:  a.String(),
+				})
+			})
+		}
+		anomaly.GlobalDetector = detector
+		detector.Start()
+	}
+
+	for _, config := range serverConfig.Forwarders {
+		forwarder, err := forward.NewForwarder(kClient, config, pkg.SearchAllPages)
+		if err != nil {
+			logger.Fatalf("error setting up the forwarder: %v", err)
+		}
+		forwarder.Start()
+	}
+
+	if serverConfig.MissionControl != nil {
+		client, err := missioncontrol.NewClient(kClient, *serverConfig.MissionControl)
+		if err != nil {
+			logger.Fatalf("error setting up mission control: %v", err)
+		}
+		missioncontrol.GlobalClient = client
+	}
+
+	if serverConfig.ConfigDB != nil {
+		resolver, err := configdb.NewResolver(*serverConfig.ConfigDB, db.GetConfigItem)
+		if err != nil {
+			logger.Fatalf("error setting up the config-db lookup: %v", err)
+		}
+		configdb.GlobalResolver = resolver
+	}
+
+	if len(serverConfig.Mappers) > 0 {
+		chain, err := mapper.NewChain(kClient, serverConfig.Mappers, db.GetConfigItem)
+		if err != nil {
+			logger.Fatalf("error setting up the search mappers: %v", err)
+		}
+		mapper.GlobalChain = chain
+	}
+
+	if serverConfig.Health != nil {
+		checker, err := health.NewChecker(*serverConfig.Health)
+		if err != nil {
+			logger.Fatalf("error setting up the health checks: %v", err)
+		}
+		health.GlobalChecker = checker
+		checker.Start(pkg.NamedBackends)
+	}
+
+	if serverConfig.Export != nil {
+		exporter, err := export.NewExporter(kClient, *serverConfig.Export)
+		if err != nil {
+			logger.Fatalf("error setting up exports: %v", err)
+		}
+		export.GlobalExporter = exporter
+	}
+
+	if serverConfig.Annotations != nil {
+		config := *serverConfig.Annotations
+		if config.Path == "" && serverConfig.Ingest != nil {
+			config.Path = serverConfig.Ingest.Path
+		}
+		store, err := annotation.Open(config)
+		if err != nil {
+			logger.Fatalf("error setting up annotations: %v", err)
+		}
+		annotation.GlobalStore = store
+	}
+
+	if serverConfig.Links != nil {
+		config := *serverConfig.Links
+		if config.Path == "" && serverConfig.Ingest != nil {
+			config.Path = serverConfig.Ingest.Path
+		}
+		store, err := link.Open(config)
+		if err != nil {
+			logger.Fatalf("error setting up links: %v", err)
+		}
+		link.GlobalStore = store
+	}
+
 	e.GET("/", func(c echo.Context) error {
 		return c.String(http.StatusOK, "apm-hub server running")
 	})
 
 	e.POST("/search", pkg.Search)
+	e.POST("/aggregate/volume", pkg.Volume)
+	e.POST("/aggregate/top", pkg.Top)
+	e.POST("/aggregate/patterns", pkg.Patterns)
+	e.POST("/aggregate/severity", pkg.Severity)
+	e.POST("/export", pkg.Export)
+	e.GET("/backends", pkg.Backends)
+	e.GET("/ready", health.ReadyHandler)
+	e.GET("/slow-queries", slowquery.Handler)
+	e.GET("/anomalies", anomaly.Handler)
+	e.GET("/alerts", alert.Handler)
+	e.POST("/incidents/:id/snapshot", pkg.AttachSnapshot)
+	e.GET("/annotations", annotation.ListHandler)
+	e.POST("/annotations", annotation.CreateHandler)
+	e.DELETE("/annotations/:id", annotation.DeleteHandler)
+	e.POST("/links", link.CreateHandler)
+	e.GET("/links/:token", link.GetHandler)
+
+	// Grafana JSON datasources, /search is already taken by the log search
+	e.GET("/grafana", pkg.GrafanaHealth)
+	e.GET("/grafana/", pkg.GrafanaHealth)
+	e.POST("/grafana/search", pkg.GrafanaSearch)
+	e.POST("/grafana/query", pkg.GrafanaQuery)
+	e.POST("/grafana/annotations", pkg.GrafanaAnnotations)
+
+	if serverConfig.Ingest != nil {
+		ingester, err := ingest.NewIngester(kClient, *serverConfig.Ingest)
+		if err != nil {
+			logger.Fatalf("error setting up ingestion: %v", err)
+		}
+		ingester.Start()
+		e.POST("/ingest", ingester.Handler)
+		e.POST("/upload", ingester.UploadHandler)
+		e.POST("/ingest/audit", ingester.AuditHandler)
+
+		if serverConfig.Ingest.ALS != nil {
+			if err := ingester.ServeALS(*serverConfig.Ingest.ALS, newTLSConfig()); err != nil {
+				logger.Fatalf("error setting up the envoy access log service: %v", err)
+			}
+		}
+
+		if serverConfig.Ingest.Forward != nil {
+			if err := ingester.ServeForward(*serverConfig.Ingest.Forward, newTLSConfig()); err != nil {
+				logger.Fatalf("error setting up the forward listener: %v", err)
+			}
+		}
+	}
+
+	// The checks api is versioned, as it's called by canary-checker
+	e.POST("/v1/checks/logs", pkg.Check)
+	e.GET("/metrics", echo.WrapHandler(promhttp.Handler()))
 
 	return e
 }
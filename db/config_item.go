@@ -0,0 +1,22 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// GetConfigItem returns the config-db item with the id, or nil if it doesn't exist
+func GetConfigItem(id uuid.UUID) (*models.ConfigItem, error) {
+	var item models.ConfigItem
+	err := gormDB.Where("id = ? AND deleted_at IS NULL", id).First(&item).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
@@ -1,7 +1,9 @@
 package elasticsearch
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/flanksource/apm-hub/api/logs"
 	"github.com/flanksource/commons/collections"
@@ -36,6 +38,59 @@ type SearchHit struct {
 	Source map[string]any `json:"_source"`
 }
 
+// TermsAggregationResponse is the response of a query made with TermsAggregationQuery
+type TermsAggregationResponse struct {
+	Aggregations struct {
+		Values struct {
+			Buckets []struct {
+				Key      any   `json:"key"`
+				DocCount int64 `json:"doc_count"`
+			} `json:"buckets"`
+		} `json:"values"`
+	} `json:"aggregations"`
+}
+
+// TermsAggregationQuery turns the search query into a query counting the most frequent values of the field.
+// The field must be a keyword field.
+func TermsAggregationQuery(query, field string, size int) (string, error) {
+	body := make(map[string]any)
+	if strings.TrimSpace(query) != "" {
+		if err := json.Unmarshal([]byte(query), &body); err != nil {
+			return "", fmt.Errorf("error parsing the query: %w", err)
+		}
+	}
+
+	// Paging and sorting don't apply to aggregations
+	delete(body, "sort")
+	delete(body, "search_after")
+	body["size"] = 0
+	body["aggs"] = map[string]any{
+		"values": map[string]any{
+			"terms": map[string]any{"field": field, "size": size},
+		},
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling the query: %w", err)
+	}
+	return string(data), nil
+}
+
+// LabelValues returns the buckets of the terms aggregation
+func (t *TermsAggregationResponse) LabelValues() []logs.LabelValue {
+	values := make([]logs.LabelValue, 0, len(t.Aggregations.Values.Buckets))
+	for _, bucket := range t.Aggregations.Values.Buckets {
+		key, err := utils.Stringify(bucket.Key)
+		if err != nil {
+			logger.Debugf("error stringifying %v: %v", bucket.Key, err)
+			continue
+		}
+		values = append(values, logs.LabelValue{Value: key, Count: int(bucket.DocCount)})
+	}
+	return values
+}
+
 // NextPage returns the next page token.
 func (t *HitsInfo) NextPage(requestedRowsCount int) string {
 	if len(t.Hits) == 0 {
@@ -91,7 +146,8 @@ func (t *HitsInfo) GetResultsFromHits(requestedRowsCount int64, msgField, timest
 			Id:      row.ID,
 			Message: msg,
 			Time:    timestamp,
-			Labels:  collections.MergeMap(labelsToAttach, labels),
+			// MergeMap merges into its first map, the labels to attach are shared by all the hits
+			Labels: collections.MergeMap(collections.MergeMap(nil, labelsToAttach), labels),
 		})
 	}
 
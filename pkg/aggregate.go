@@ -0,0 +1,329 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/labstack/echo/v4"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/analytics"
+	"github.com/flanksource/apm-hub/pkg/audit"
+	"github.com/flanksource/apm-hub/pkg/auth"
+	"github.com/flanksource/apm-hub/pkg/guardrail"
+	"github.com/flanksource/apm-hub/pkg/health"
+	"github.com/flanksource/apm-hub/pkg/pipeline"
+	"github.com/flanksource/apm-hub/pkg/slowquery"
+)
+
+// Volume returns the number of log lines per interval of every backend
+func Volume(c echo.Context) error {
+	cc := c.(*api.Context)
+	params := new(analytics.VolumeParams)
+	if err := c.Bind(params); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if params.Limit <= 0 {
+		params.Limit = guardrail.GlobalGuardrails.DefaultLimit(analytics.DefaultLimit)
+	}
+	params.SetDefaults()
+
+	grant, err := authorize(cc, &params.SearchParams)
+	if err != nil {
+		return err
+	}
+
+	start, end, interval, err := params.Range(time.Now())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	key, cacheable := analytics.CacheKey(cacheScope(cc), params)
+	if cacheable {
+		if histogram, ok := analytics.Cached(key); ok {
+			return cc.JSON(http.StatusOK, histogram)
+		}
+	}
+
+	results, total, backends := aggregateResults(cc, &params.SearchParams, grant)
+	histogram := analytics.NewHistogram(start, end, interval, params.GroupBy, results)
+	audit.GlobalAuditor.Record(newAuditEvent(cc, &params.SearchParams, backends, total, nil))
+	if cacheable {
+		analytics.Cache(key, histogram)
+	}
+
+	return cc.JSON(http.StatusOK, histogram)
+}
+
+// Top returns the most frequent values of a label across the backends
+func Top(c echo.Context) error {
+	cc := c.(*api.Context)
+	params := new(analytics.TopParams)
+	if err := c.Bind(params); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if params.Limit <= 0 {
+		params.Limit = guardrail.GlobalGuardrails.DefaultLimit(analytics.DefaultLimit)
+	}
+	params.SetDefaults()
+	if err := params.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	grant, err := authorize(cc, &params.SearchParams)
+	if err != nil {
+		return err
+	}
+
+	key, cacheable := analytics.CacheKey(cacheScope(cc), params)
+	if cacheable {
+		if top, ok := analytics.Cached(key); ok {
+			return cc.JSON(http.StatusOK, top)
+		}
+	}
+
+	start := time.Now()
+	var lists [][]logs.LabelValue
+	backends := eachBackend(cc.Request().Context(), cc.Tenant, &params.SearchParams, grant, func(i int, backend logs.SearchBackend, q logs.SearchParams) {
+		values, err := topValues(cc, backend, grant, q, params)
+		if err != nil {
+			logger.Errorf("error counting the values of %s in backend[%d]: %v", params.Label, i, err)
+			return
+		}
+		lists = append(lists, values)
+	})
+
+	top := analytics.TopValues{Label: params.Label, Values: analytics.MergeTop(params.Size, lists...)}
+	logger.Debugf("[%s] => top %d values of %s in %s", params.SearchParams, len(top.Values), params.Label, time.Since(start))
+	audit.GlobalAuditor.Record(newAuditEvent(cc, &params.SearchParams, backends, len(top.Values), nil))
+	if cacheable {
+		analytics.Cache(key, top)
+	}
+
+	return cc.JSON(http.StatusOK, top)
+}
+
+// topValues counts the values of the label natively when the backend supports it,
+// otherwise the results are streamed through the pipelines and counted.
+func topValues(cc *api.Context, backend logs.SearchBackend, grant *auth.Grant, q logs.SearchParams, params *analytics.TopParams) ([]logs.LabelValue, error) {
+	if aggregator, ok := nativeAggregator(backend, grant); ok {
+		return nativeTopValues(cc.Request().Context(), backend, aggregator, q, params.Label, params.Size)
+	}
+
+	counter := analytics.NewCounter(params.Label)
+	err := streamResults(cc.Request().Context(), cc.Tenant, backend, grant, q, counter.Add)
+	return counter.Values(), err
+}
+
+// Severity counts the results of the backends by severity
+func Severity(c echo.Context) error {
+	cc := c.(*api.Context)
+	params := new(analytics.SeverityParams)
+	if err := c.Bind(params); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if params.Limit <= 0 {
+		params.Limit = guardrail.GlobalGuardrails.DefaultLimit(analytics.DefaultLimit)
+	}
+	params.SetDefaults()
+
+	grant, err := authorize(cc, &params.SearchParams)
+	if err != nil {
+		return err
+	}
+
+	key, cacheable := analytics.CacheKey(cacheScope(cc), params)
+	if cacheable {
+		if breakdown, ok := analytics.Cached(key); ok {
+			return cc.JSON(http.StatusOK, breakdown)
+		}
+	}
+
+	start := time.Now()
+	counter := analytics.NewSeverityCounter(*params)
+	backends := eachBackend(cc.Request().Context(), cc.Tenant, &params.SearchParams, grant, func(i int, backend logs.SearchBackend, q logs.SearchParams) {
+		// The native aggregations can't be split by another label
+		if aggregator, ok := nativeAggregator(backend, grant); ok && params.GroupBy == "" {
+			for _, label := range counter.Labels() {
+				values, err := nativeTopValues(cc.Request().Context(), backend, aggregator, q, label, analytics.MaxTopSize)
+				if err != nil {
+					logger.Errorf("error counting the values of %s in backend[%d]: %v", label, i, err)
+					return
+				}
+				if len(values) > 0 {
+					counter.AddValues(values)
+					return
+				}
+			}
+			return
+		}
+
+		if err := streamResults(cc.Request().Context(), cc.Tenant, backend, grant, q, counter.Add); err != nil {
+			logger.Errorf("error counting the severities of backend[%d]: %v", i, err)
+		}
+	})
+
+	breakdown := counter.Breakdown()
+	logger.Debugf("[%s] => %d results by severity in %s", params.SearchParams, breakdown.Total, time.Since(start))
+	audit.GlobalAuditor.Record(newAuditEvent(cc, &params.SearchParams, backends, breakdown.Total, nil))
+	if cacheable {
+		analytics.Cache(key, breakdown)
+	}
+
+	return cc.JSON(http.StatusOK, breakdown)
+}
+
+// eachBackend calls fn with every healthy backend allowed by the grant and the tenant whose routes match the searches
+// the search params expand to, and returns their names.
+func eachBackend(ctx context.Context, tenant string, searchParams *logs.SearchParams, grant *auth.Grant, fn func(i int, backend logs.SearchBackend, q logs.SearchParams)) []string {
+	var backends []string
+	for _, q := range mapSearches(ctx, searchParams, grant) {
+		for i, backend := range logs.GlobalBackends {
+			if backend.Shadow || !grant.AllowsBackend(backend) || !auth.GlobalTenancy.AllowsBackend(tenant, backend) {
+				continue
+			}
+
+			matched, isAdditive := backend.API.MatchRoute(&q)
+			if !matched {
+				continue
+			}
+
+			name := backendName(i, backend)
+			if !health.GlobalChecker.Allow(name) {
+				continue
+			}
+
+			backends = append(backends, name)
+			fn(i, backend, q)
+			if isAdditive {
+				break
+			}
+		}
+	}
+	return backends
+}
+
+// nativeAggregator returns the aggregator of the backend, when it supports aggregations
+// and its results don't need to be filtered or processed.
+func nativeAggregator(backend logs.SearchBackend, grant *auth.Grant) (logs.LabelAggregator, bool) {
+	aggregator, ok := backend.API.(logs.LabelAggregator)
+	native := ok && backend.API.Capabilities().Aggregations && !grant.FiltersResults() && auth.GlobalTenancy == nil &&
+		backend.Pipeline == nil && pipeline.GlobalPipeline == nil
+	return aggregator, native
+}
+
+// nativeTopValues counts the values of the label with the aggregator of the backend,
+// within the concurrency limit of the backend
+func nativeTopValues(ctx context.Context, backend logs.SearchBackend, aggregator logs.LabelAggregator, q logs.SearchParams, label string, size int) ([]logs.LabelValue, error) {
+	release, err := acquire(ctx, backend)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return aggregator.TopValues(ctx, &q, label, size)
+}
+
+// streamResults searches the backend page by page, up to analytics.MaxPages,
+// and passes the processed results of every page to fn.
+// The streaming backends send the same number of results, without the pages being held in memory.
+func streamResults(ctx context.Context, tenant string, backend logs.SearchBackend, grant *auth.Grant, q logs.SearchParams, fn func([]logs.Result)) error {
+	if backend.API.Capabilities().Streaming && q.Limit > 0 {
+		size := int(q.Limit)
+		maxResults := analytics.MaxPages * size
+		q.Limit = int64(maxResults)
+
+		streamed := 0
+		return streamBackend(ctx, backend, q, size, func(results []logs.Result) bool {
+			streamed += len(results)
+			fn(processResults(tenant, backend, grant, results))
+			return streamed < maxResults
+		})
+	}
+
+	for page := 0; page < analytics.MaxPages; page++ {
+		result, err := searchBackend(ctx, backend, q)
+		if err != nil {
+			return err
+		}
+		fn(processResults(tenant, backend, grant, result.Results))
+
+		if result.NextPage == "" || result.NextPage == q.Page {
+			return nil
+		}
+		q.Page = result.NextPage
+	}
+	return nil
+}
+
+// Patterns groups the messages of the backends into templates
+func Patterns(c echo.Context) error {
+	cc := c.(*api.Context)
+	params := new(analytics.PatternParams)
+	if err := c.Bind(params); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if params.Limit <= 0 {
+		params.Limit = guardrail.GlobalGuardrails.DefaultLimit(analytics.DefaultLimit)
+	}
+	params.SetDefaults()
+	if err := params.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	grant, err := authorize(cc, &params.SearchParams)
+	if err != nil {
+		return err
+	}
+
+	key, cacheable := analytics.CacheKey(cacheScope(cc), params)
+	if cacheable {
+		if patterns, ok := analytics.Cached(key); ok {
+			return cc.JSON(http.StatusOK, patterns)
+		}
+	}
+
+	results, total, backends := aggregateResults(cc, &params.SearchParams, grant)
+	drain := analytics.NewDrain(*params)
+	for _, backend := range backends {
+		drain.Add(results[backend])
+		delete(results, backend)
+	}
+	patterns := drain.Patterns(params.Size)
+	audit.GlobalAuditor.Record(newAuditEvent(cc, &params.SearchParams, backends, total, nil))
+	if cacheable {
+		analytics.Cache(key, patterns)
+	}
+
+	return cc.JSON(http.StatusOK, patterns)
+}
+
+// aggregateResults searches the backends and returns their results by backend name
+func aggregateResults(cc *api.Context, searchParams *logs.SearchParams, grant *auth.Grant) (map[string][]logs.Result, int, []string) {
+	start := time.Now()
+	backendResults, backendQueries := searchBackends(cc.Request().Context(), cc.Tenant, searchParams, grant)
+
+	total := 0
+	var backends []string
+	results := make(map[string][]logs.Result, len(backendResults))
+	for _, backendResult := range backendResults {
+		backends = append(backends, backendResult.Backend)
+		results[backendResult.Backend] = append(results[backendResult.Backend], backendResult.Results...)
+		total += len(backendResult.Results)
+	}
+
+	logger.Debugf("[%s] => aggregated %d results in %s", searchParams, total, time.Since(start))
+	slowquery.Record(cc.Tenant, *searchParams, time.Since(start), total, backendQueries)
+	return results, total, backends
+}
+
+// cacheScope identifies what the request is allowed to see
+func cacheScope(cc *api.Context) string {
+	scope := cc.Tenant
+	if cc.User != nil {
+		scope += "/" + cc.User.Name
+	}
+	return scope
+}
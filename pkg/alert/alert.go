@@ -0,0 +1,198 @@
+package alert
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/auth"
+	"github.com/flanksource/apm-hub/pkg/notification"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/logger"
+	"github.com/labstack/echo/v4"
+)
+
+// GlobalManager evaluates the alert rules.
+// It's nil when no rule is configured.
+var GlobalManager *Manager
+
+// SearchFunc returns the processed results of every backend matching the search params,
+// with the errors of the backends that couldn't be searched
+type SearchFunc func(q *logs.SearchParams) ([]logs.Result, error)
+
+// State is the outcome of the last evaluation of a rule
+type State struct {
+	Rule   string `json:"rule"`
+	Firing bool   `json:"firing"`
+	// Since is the time of the last transition between firing and resolved
+	Since          time.Time           `json:"since,omitempty"`
+	LastEvaluation time.Time           `json:"lastEvaluation,omitempty"`
+	Result         logs.LogCheckResult `json:"result"`
+	// Error is the error of the last evaluation, the rule then keeps its previous state
+	Error string `json:"error,omitempty"`
+}
+
+type rule struct {
+	logs.AlertRule
+	interval time.Duration
+}
+
+// Manager evaluates the rules on their schedule and notifies their channels
+// when they start firing and when they're resolved.
+type Manager struct {
+	rules     []rule
+	search    SearchFunc
+	notifier  *notification.Notifier
+	searchURL string
+
+	lock   sync.Mutex
+	states map[string]*State
+}
+
+func NewManager(config logs.AlertingConfig, notifier *notification.Notifier, search SearchFunc) (*Manager, error) {
+	t := &Manager{
+		search:    search,
+		notifier:  notifier,
+		searchURL: config.SearchURL,
+		states:    make(map[string]*State),
+	}
+
+	for _, r := range config.Rules {
+		if r.Name == "" {
+			return nil, fmt.Errorf("alert rules must have a name")
+		}
+		if _, ok := t.states[r.Name]; ok {
+			return nil, fmt.Errorf("duplicate alert rule %s", r.Name)
+		}
+		for _, channel := range r.Channels {
+			if !notifier.Has(channel) {
+				return nil, fmt.Errorf("alert rule %s: unknown notification channel %s", r.Name, channel)
+			}
+		}
+
+		interval := 5 * time.Minute
+		if r.Interval != "" {
+			d, err := durationUtil.ParseDuration(r.Interval)
+			if err != nil {
+				return nil, fmt.Errorf("alert rule %s: invalid interval %s: %w", r.Name, r.Interval, err)
+			}
+			interval = time.Duration(d)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("alert rule %s: interval must be greater than 0", r.Name)
+		}
+
+		t.rules = append(t.rules, rule{AlertRule: r, interval: interval})
+		t.states[r.Name] = &State{Rule: r.Name}
+	}
+	return t, nil
+}
+
+// Start evaluates every rule in the background at its interval
+func (t *Manager) Start() {
+	for _, r := range t.rules {
+		go func(r rule) {
+			ticker := time.NewTicker(r.interval)
+			defer ticker.Stop()
+			for now := range ticker.C {
+				t.evaluate(r, now)
+			}
+		}(r)
+	}
+}
+
+// Evaluate evaluates all the rules now
+func (t *Manager) Evaluate(now time.Time) {
+	for _, r := range t.rules {
+		t.evaluate(r, now)
+	}
+}
+
+func (t *Manager) evaluate(r rule, now time.Time) {
+	q := r.SearchParams
+	q.Start = now.Add(-r.interval).Format(time.RFC3339)
+	q.End = now.Format(time.RFC3339)
+	if q.Limit <= 0 {
+		q.Limit = 10000
+	}
+	q.SetDefaults()
+
+	results, err := t.search(&q)
+	if err != nil {
+		// The results of a failed backend are unknown, so the rule can neither fire nor resolve on them
+		logger.Errorf("[alert] error evaluating %s: %v", r.Name, err)
+		t.lock.Lock()
+		t.states[r.Name].Error = err.Error()
+		t.lock.Unlock()
+		return
+	}
+
+	check := r.LogCheck
+	check.SearchParams = q
+	result := check.Evaluate(results)
+
+	t.lock.Lock()
+	state := t.states[r.Name]
+	firing := !result.Pass
+	first := state.LastEvaluation.IsZero()
+	changed := first || state.Firing != firing
+	// There's nothing to resolve on the first evaluation
+	notify := changed && (firing || !first)
+	state.Firing = firing
+	state.LastEvaluation = now
+	state.Result = result
+	state.Error = ""
+	if changed {
+		state.Since = now
+	}
+	t.lock.Unlock()
+
+	if !notify {
+		return
+	}
+
+	msg := notification.Message{
+		Key:      r.Name,
+		Title:    fmt.Sprintf("[firing] %s", r.Name),
+		Text:     result.Message,
+		Samples:  result.Samples,
+		Link:     q.Link(t.searchURL),
+		Resolved: result.Pass,
+	}
+	if result.Pass {
+		msg.Title = fmt.Sprintf("[resolved] %s", r.Name)
+		msg.Samples = nil
+	}
+	logger.Infof("[alert] %s: %s", msg.Title, result.Message)
+	t.notifier.Notify(r.Channels, msg)
+}
+
+// States returns the states of the rules, sorted by name
+func (t *Manager) States() []State {
+	states := []State{}
+	if t == nil {
+		return states
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for _, state := range t.states {
+		states = append(states, *state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Rule < states[j].Rule })
+	return states
+}
+
+// Handler returns the states of the alert rules.
+// They're only returned to the admins, as their samples are searched across every tenant.
+func Handler(c echo.Context) error {
+	cc := c.(*api.Context)
+	if !auth.GlobalAuthorizer.IsAdmin(cc.User) {
+		return echo.NewHTTPError(http.StatusForbidden, "the alerts are restricted to the admins")
+	}
+	return cc.JSON(http.StatusOK, GlobalManager.States())
+}
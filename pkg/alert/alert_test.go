@@ -0,0 +1,126 @@
+package alert
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/notification"
+)
+
+type recordingChannel struct {
+	messages []notification.Message
+}
+
+func (t *recordingChannel) Send(msg notification.Message) error {
+	t.messages = append(t.messages, msg)
+	return nil
+}
+
+func TestManager(t *testing.T) {
+	channel := &recordingChannel{}
+	notifier, _ := notification.NewNotifier(nil, "", nil)
+	notifier.Register("ops", channel)
+
+	errors := 0
+	var searched logs.SearchParams
+	search := func(q *logs.SearchParams) ([]logs.Result, error) {
+		searched = *q
+		return make([]logs.Result, errors), nil
+	}
+
+	manager, err := NewManager(logs.AlertingConfig{
+		SearchURL: "https://logs.example.com",
+		Rules: []logs.AlertRule{{
+			Name:     "checkout errors",
+			Interval: "10m",
+			LogCheck: logs.LogCheck{SearchParams: logs.SearchParams{Query: "error"}},
+			Channels: []string{"ops"},
+		}},
+	}, notifier, search)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	steps := []struct {
+		errors   int
+		firing   bool
+		messages int
+	}{
+		{errors: 0, firing: false, messages: 0},
+		{errors: 3, firing: true, messages: 1},
+		{errors: 5, firing: true, messages: 1},
+		{errors: 0, firing: false, messages: 2},
+		{errors: 0, firing: false, messages: 2},
+	}
+	for i, step := range steps {
+		errors = step.errors
+		manager.Evaluate(now.Add(time.Duration(i) * 10 * time.Minute))
+
+		state := manager.States()[0]
+		if state.Firing != step.firing || len(channel.messages) != step.messages {
+			t.Fatalf("step %d: firing = %v with %d messages, want %v with %d", i, state.Firing, len(channel.messages), step.firing, step.messages)
+		}
+	}
+
+	if searched.Start != "2023-05-01T12:30:00Z" || searched.End != "2023-05-01T12:40:00Z" {
+		t.Errorf("unexpected time range %s - %s", searched.Start, searched.End)
+	}
+
+	firing, resolved := channel.messages[0], channel.messages[1]
+	if firing.Title != "[firing] checkout errors" || len(firing.Samples) != 3 || !strings.HasPrefix(firing.Link, "https://logs.example.com?") {
+		t.Errorf("unexpected firing message: %+v", firing)
+	}
+	if resolved.Title != "[resolved] checkout errors" || !resolved.Resolved {
+		t.Errorf("unexpected resolved message: %+v", resolved)
+	}
+}
+
+func TestManager_SearchError(t *testing.T) {
+	channel := &recordingChannel{}
+	notifier, _ := notification.NewNotifier(nil, "", nil)
+	notifier.Register("ops", channel)
+
+	var searchErr error
+	search := func(q *logs.SearchParams) ([]logs.Result, error) {
+		if searchErr != nil {
+			return nil, searchErr
+		}
+		return make([]logs.Result, 3), nil
+	}
+
+	manager, err := NewManager(logs.AlertingConfig{
+		Rules: []logs.AlertRule{{
+			Name:     "checkout errors",
+			LogCheck: logs.LogCheck{SearchParams: logs.SearchParams{Query: "error"}},
+			Channels: []string{"ops"},
+		}},
+	}, notifier, search)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	manager.Evaluate(now)
+	if state := manager.States()[0]; !state.Firing || len(channel.messages) != 1 {
+		t.Fatalf("firing = %v with %d messages, want a firing rule", state.Firing, len(channel.messages))
+	}
+
+	// A failed search neither resolves the rule nor notifies
+	searchErr = errors.New("connection refused")
+	manager.Evaluate(now.Add(5 * time.Minute))
+	state := manager.States()[0]
+	if !state.Firing || state.Error == "" || len(channel.messages) != 1 {
+		t.Errorf("unexpected state after a failed search: %+v with %d messages", state, len(channel.messages))
+	}
+}
+
+func TestNewManager_UnknownChannel(t *testing.T) {
+	_, err := NewManager(logs.AlertingConfig{Rules: []logs.AlertRule{{Name: "rule", Channels: []string{"missing"}}}}, nil, nil)
+	if err == nil {
+		t.Error("expected an error for an unknown channel")
+	}
+}
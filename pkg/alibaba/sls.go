@@ -0,0 +1,305 @@
+// Package alibaba searches the logstores of Alibaba Cloud Log Service (SLS) with the GetLogs api
+package alibaba
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const (
+	apiVersion = "0.6.0"
+
+	// maxLines is the maximum number of logs returned by a request
+	maxLines = 100
+
+	// defaultRange is the time range searched when the search has no start
+	defaultRange = 24 * time.Hour
+)
+
+type SLSSearch struct {
+	config *logs.AlibabaSLSBackendConfig
+	client *http.Client
+
+	scheme   string
+	endpoint string
+
+	accessKeyID     string
+	accessKeySecret string
+	securityToken   string
+
+	project  *template.Template
+	logstore *template.Template
+	query    *template.Template
+}
+
+func NewSLSSearchBackend(kClient *kommons.Client, config *logs.AlibabaSLSBackendConfig) (*SLSSearch, error) {
+	if config.Endpoint == "" || config.Project == "" || config.Logstore == "" {
+		return nil, fmt.Errorf("the endpoint, project and logstore are required")
+	}
+	if config.AccessKeyID == nil || config.AccessKeySecret == nil {
+		return nil, fmt.Errorf("the access key id and secret are required")
+	}
+
+	t := &SLSSearch{config: config, client: &http.Client{Timeout: 30 * time.Second}, scheme: "https", endpoint: config.Endpoint}
+	if scheme, endpoint, ok := strings.Cut(config.Endpoint, "://"); ok {
+		t.scheme, t.endpoint = scheme, endpoint
+	}
+	t.endpoint = strings.TrimSuffix(t.endpoint, "/")
+
+	var err error
+	if _, t.accessKeyID, err = kClient.GetEnvValue(*config.AccessKeyID, config.Namespace); err != nil {
+		return nil, fmt.Errorf("error getting the access key id: %w", err)
+	}
+	if _, t.accessKeySecret, err = kClient.GetEnvValue(*config.AccessKeySecret, config.Namespace); err != nil {
+		return nil, fmt.Errorf("error getting the access key secret: %w", err)
+	}
+	if config.SecurityToken != nil {
+		if _, t.securityToken, err = kClient.GetEnvValue(*config.SecurityToken, config.Namespace); err != nil {
+			return nil, fmt.Errorf("error getting the security token: %w", err)
+		}
+	}
+
+	if t.project, err = template.New("project").Parse(config.Project); err != nil {
+		return nil, fmt.Errorf("error parsing the project template: %w", err)
+	}
+	if t.logstore, err = template.New("logstore").Parse(config.Logstore); err != nil {
+		return nil, fmt.Errorf("error parsing the logstore template: %w", err)
+	}
+	if t.query, err = template.New("query").Parse(config.Query); err != nil {
+		return nil, fmt.Errorf("error parsing the query template: %w", err)
+	}
+	return t, nil
+}
+
+func render(tpl *template.Template, q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing the %s template: %w", tpl.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Search returns the last logs of the logstore, up to the limit of the search, chronologically.
+// The page is the offset of the logs, the next page returning the logs before them.
+func (t *SLSSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+	project, err := render(t.project, q)
+	if err != nil {
+		return results, err
+	}
+	logstore, err := render(t.logstore, q)
+	if err != nil {
+		return results, err
+	}
+	if project == "" || logstore == "" {
+		return results, fmt.Errorf("the project and logstore of the search are required")
+	}
+	query := q.Query
+	if t.config.Query != "" {
+		if query, err = render(t.query, q); err != nil {
+			return results, err
+		}
+	}
+
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+	start := end.Add(-defaultRange)
+	if s := q.GetStart(); s != nil {
+		start = *s
+	}
+	lines := int64(maxLines)
+	if q.Limit > 0 && q.Limit < maxLines {
+		lines = q.Limit
+	}
+	var offset int64
+	if q.Page != "" {
+		if offset, err = strconv.ParseInt(q.Page, 10, 64); err != nil {
+			return results, fmt.Errorf("invalid page %s: %w", q.Page, err)
+		}
+	}
+
+	params := map[string]string{
+		"type":    "log",
+		"from":    strconv.FormatInt(start.Unix(), 10),
+		"to":      strconv.FormatInt(end.Unix()+1, 10),
+		"line":    strconv.FormatInt(lines, 10),
+		"offset":  strconv.FormatInt(offset, 10),
+		"reverse": "true",
+	}
+	if query != "" {
+		params["query"] = query
+	}
+	path := "/logstores/" + logstore
+	req, err := t.request(ctx, project, path, params)
+	if err != nil {
+		return results, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return results, fmt.Errorf("error getting the logs: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			ErrorCode    string `json:"errorCode"`
+			ErrorMessage string `json:"errorMessage"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if json.Unmarshal(data, &e) == nil && e.ErrorCode != "" {
+			return results, fmt.Errorf("error getting the logs: %s %s", e.ErrorCode, e.ErrorMessage)
+		}
+		return results, fmt.Errorf("error getting the logs: %d %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var rows []map[string]any
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&rows); err != nil {
+		return results, fmt.Errorf("error decoding the logs: %w", err)
+	}
+	for i := len(rows) - 1; i >= 0; i-- {
+		results.Results = append(results.Results, t.result(rows[i]))
+	}
+	results.Total = len(results.Results)
+	if int64(len(rows)) == lines {
+		results.NextPage = strconv.FormatInt(offset+lines, 10)
+	}
+	return results, nil
+}
+
+// request returns the GET request of the path of the project, signed with the access key
+func (t *SLSSearch) request(ctx context.Context, project, path string, params map[string]string) (*http.Request, error) {
+	query := url.Values{}
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	host := project + "." + t.endpoint
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.scheme+"://"+host+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-log-apiversion", apiVersion)
+	req.Header.Set("x-log-signaturemethod", "hmac-sha1")
+	req.Header.Set("x-log-bodyrawsize", "0")
+	if t.securityToken != "" {
+		req.Header.Set("x-acs-security-token", t.securityToken)
+	}
+	req.Header.Set("Authorization", "LOG "+t.accessKeyID+":"+t.signature(req.Method, req.Header, path, params))
+	return req, nil
+}
+
+// signature returns the signature of the request, the HMAC-SHA1 of its method, content, date, log headers and resource
+func (t *SLSSearch) signature(method string, header http.Header, path string, params map[string]string) string {
+	var headers []string
+	for name := range header {
+		name = strings.ToLower(name)
+		if strings.HasPrefix(name, "x-log-") || strings.HasPrefix(name, "x-acs-") {
+			headers = append(headers, name+":"+header.Get(name))
+		}
+	}
+	sort.Strings(headers)
+
+	resource := path
+	if len(params) > 0 {
+		var pairs []string
+		for k, v := range params {
+			pairs = append(pairs, k+"="+v)
+		}
+		sort.Strings(pairs)
+		resource += "?" + strings.Join(pairs, "&")
+	}
+
+	content := strings.Join([]string{
+		method,
+		header.Get("Content-MD5"),
+		header.Get("Content-Type"),
+		header.Get("Date"),
+		strings.Join(headers, "\n"),
+		resource,
+	}, "\n")
+	mac := hmac.New(sha1.New, []byte(t.accessKeySecret))
+	mac.Write([]byte(content))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// result returns the result of the log, with its __time__ as time and its message field as message.
+// The source, topic and tags of the log are returned as the source, topic and tag labels, e.g. hostname for __tag__:__hostname__.
+func (t *SLSSearch) result(row map[string]any) logs.Result {
+	r := logs.Result{Labels: make(map[string]string, len(row)+len(t.config.Labels))}
+	fields := make(map[string]string, len(row))
+	for field, value := range row {
+		switch value := value.(type) {
+		case nil:
+		case string:
+			fields[field] = value
+		case json.Number:
+			fields[field] = value.String()
+		default:
+			data, _ := json.Marshal(value)
+			fields[field] = string(data)
+		}
+	}
+
+	if seconds, err := strconv.ParseInt(fields["__time__"], 10, 64); err == nil {
+		nanos, _ := strconv.ParseInt(fields["__time_ns_part__"], 10, 64)
+		r.Time = time.Unix(seconds, nanos).UTC().Format(time.RFC3339Nano)
+	}
+	messageFields := []string{"content", "message"}
+	if t.config.MessageField != "" {
+		messageFields = []string{t.config.MessageField}
+	}
+	for _, field := range messageFields {
+		if message, ok := fields[field]; ok {
+			r.Message = message
+			delete(fields, field)
+			break
+		}
+	}
+
+	for field, value := range fields {
+		switch {
+		case field == "__time__" || field == "__time_ns_part__" || value == "":
+		case field == "__source__":
+			r.Labels["source"] = value
+		case field == "__topic__":
+			r.Labels["topic"] = value
+		case strings.HasPrefix(field, "__tag__:"):
+			r.Labels[strings.Trim(strings.TrimPrefix(field, "__tag__:"), "_")] = value
+		default:
+			r.Labels[field] = value
+		}
+	}
+
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *SLSSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the SLS backend, the query of the search is a search statement unless the template doesn't use it
+func (t *SLSSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: t.config.Query == "" || strings.Contains(t.config.Query, ".Query"), Pagination: true}
+}
@@ -0,0 +1,101 @@
+package alibaba
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+// signature returns the signature of the GET request, from its decoded query
+func signature(r *http.Request, secret string) string {
+	var pairs []string
+	for k, v := range r.URL.Query() {
+		pairs = append(pairs, k+"="+v[0])
+	}
+	sort.Strings(pairs)
+	content := "GET\n\n\n" + r.Header.Get("Date") + "\n" +
+		"x-acs-security-token:" + r.Header.Get("x-acs-security-token") + "\n" +
+		"x-log-apiversion:0.6.0\nx-log-bodyrawsize:0\nx-log-signaturemethod:hmac-sha1\n" +
+		r.URL.Path + "?" + strings.Join(pairs, "&")
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(content))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestSLSSearch(t *testing.T) {
+	var host string
+	var query url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "LOG key-id:"+signature(r, "key-secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"errorCode": "SignatureNotMatch", "errorMessage": "signature not match"}`))
+			return
+		}
+		if r.URL.Path != "/logstores/nginx" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		host, query = r.Host, r.URL.Query()
+		_, _ = w.Write([]byte(`[
+			{"__time__": "1682942402", "__time_ns_part__": "500000000", "__source__": "10.0.0.2", "__topic__": "", "__tag__:__hostname__": "web-2", "content": "GET /cart 502", "status": "502"},
+			{"__time__": "1682942401", "__source__": "10.0.0.1", "__topic__": "access", "content": "GET / 200", "status": "200"}
+		]`))
+	}))
+	defer server.Close()
+
+	backend, err := NewSLSSearchBackend(nil, &logs.AlibabaSLSBackendConfig{
+		CommonBackend:   logs.CommonBackend{Labels: map[string]string{"cluster": "prod"}},
+		Endpoint:        "http://cn-hangzhou.log.aliyuncs.com",
+		AccessKeyID:     &kommons.EnvVar{Value: "key-id"},
+		AccessKeySecret: &kommons.EnvVar{Value: "key-secret"},
+		SecurityToken:   &kommons.EnvVar{Value: "sts-token"},
+		Project:         "k8s-log-{{index .Labels \"cluster\"}}",
+		Logstore:        `{{index .Labels "logstore"}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend.client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, server.Listener.Addr().String())
+		},
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query: "status >= 500", Labels: map[string]string{"cluster": "prod", "logstore": "nginx"},
+		Start: "2023-05-01T12:00:00Z", End: "2023-05-01T13:00:00Z", Limit: 2, Page: "4",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, NextPage: "6", Results: []logs.Result{
+		{Time: "2023-05-01T12:00:01Z", Message: "GET / 200", Labels: map[string]string{"source": "10.0.0.1", "topic": "access", "status": "200", "cluster": "prod"}},
+		{Time: "2023-05-01T12:00:02.5Z", Message: "GET /cart 502", Labels: map[string]string{"source": "10.0.0.2", "hostname": "web-2", "status": "502", "cluster": "prod"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	if host != "k8s-log-prod.cn-hangzhou.log.aliyuncs.com" {
+		t.Errorf("host = %s, want the host of the project", host)
+	}
+	wantQuery := url.Values{
+		"type": {"log"}, "from": {"1682942400"}, "to": {"1682946001"}, "line": {"2"}, "offset": {"4"}, "reverse": {"true"}, "query": {"status >= 500"},
+	}
+	if !reflect.DeepEqual(query, wantQuery) {
+		t.Errorf("query = %v, want %v", query, wantQuery)
+	}
+}
@@ -0,0 +1,46 @@
+package analytics
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"github.com/spf13/pflag"
+)
+
+// CacheTTL is how long the aggregations are cached for.
+// A zero TTL disables the cache.
+var CacheTTL = 30 * time.Second
+
+var aggregations = cache.New(CacheTTL, time.Minute)
+
+func Flags(flags *pflag.FlagSet) {
+	flags.DurationVar(&CacheTTL, "aggregate-cache-ttl", 30*time.Second, "Duration the aggregations are cached for. 0 disables the cache")
+}
+
+// CacheKey returns the key of an aggregation.
+// The scope, e.g. the user and tenant, is part of the key
+// as the same params can return different results for different users.
+func CacheKey(scope string, params any) (string, bool) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return "", false
+	}
+	return scope + "/" + string(data), true
+}
+
+// Cached returns the cached aggregation for the key
+func Cached(key string) (any, bool) {
+	if CacheTTL <= 0 {
+		return nil, false
+	}
+	return aggregations.Get(key)
+}
+
+// Cache stores the aggregation for the key
+func Cache(key string, value any) {
+	if CacheTTL <= 0 {
+		return
+	}
+	aggregations.Set(key, value, CacheTTL)
+}
@@ -0,0 +1,226 @@
+package analytics
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// Wildcard replaces the variable tokens of the templates
+const Wildcard = "<*>"
+
+const (
+	defaultSimilarity  = 0.4
+	defaultDepth       = 4
+	defaultExamples    = 3
+	defaultPatternSize = 50
+	maxChildren        = 100
+)
+
+// variableToken matches the tokens that are masked before clustering:
+// numbers, hex values, uuids, ips and anything else containing a digit.
+var variableToken = regexp.MustCompile(`\d`)
+
+// PatternParams requests the templates of the messages
+type PatternParams struct {
+	logs.SearchParams
+
+	// Similarity is the minimum ratio of identical tokens for a message to join a pattern. Defaults to 0.4.
+	Similarity float64 `json:"similarity,omitempty"`
+
+	// Depth of the parse tree, including the root and the token count layers.
+	// The messages are routed by their depth-2 leading tokens. Defaults to 4.
+	Depth int `json:"depth,omitempty"`
+
+	// Examples is the number of example messages returned per pattern. Defaults to 3.
+	Examples int `json:"examples,omitempty"`
+
+	// Size is the number of patterns returned, most frequent first. Defaults to 50.
+	Size int `json:"size,omitempty"`
+}
+
+func (p *PatternParams) Validate() error {
+	if p.Similarity == 0 {
+		p.Similarity = defaultSimilarity
+	}
+	if p.Similarity < 0 || p.Similarity > 1 {
+		return fmt.Errorf("similarity must be between 0 and 1")
+	}
+
+	if p.Depth == 0 {
+		p.Depth = defaultDepth
+	}
+	if p.Depth < 3 {
+		return fmt.Errorf("depth must be at least 3")
+	}
+	if p.Examples <= 0 {
+		p.Examples = defaultExamples
+	}
+	if p.Size <= 0 {
+		p.Size = defaultPatternSize
+	}
+	return nil
+}
+
+// Patterns is the list of templates the messages were grouped into
+type Patterns struct {
+	// Total is the number of messages
+	Total    int       `json:"total"`
+	Patterns []Pattern `json:"patterns"`
+}
+
+// Pattern is a group of similar messages
+type Pattern struct {
+	Template string   `json:"template"`
+	Count    int      `json:"count"`
+	Examples []string `json:"examples,omitempty"`
+
+	tokens []string
+}
+
+// Drain groups the messages into patterns with a fixed depth parse tree.
+// Messages are routed by their number of tokens, then by their leading tokens
+// and join the most similar pattern of the leaf, or start a new one.
+// See "Drain: An Online Log Parsing Approach with Fixed Depth Tree" (He et al., 2017).
+type Drain struct {
+	similarity float64
+	depth      int
+	examples   int
+
+	root     map[int]*node
+	patterns []*Pattern
+	total    int
+}
+
+type node struct {
+	children map[string]*node
+	patterns []*Pattern
+}
+
+func NewDrain(params PatternParams) *Drain {
+	return &Drain{
+		similarity: params.Similarity,
+		depth:      params.Depth,
+		examples:   params.Examples,
+		root:       make(map[int]*node),
+	}
+}
+
+// Add groups the messages of the results
+func (t *Drain) Add(results []logs.Result) {
+	for _, r := range results {
+		t.AddMessage(r.Message)
+	}
+}
+
+// AddMessage groups a single message
+func (t *Drain) AddMessage(message string) {
+	tokens := tokenize(message)
+	if len(tokens) == 0 {
+		return
+	}
+	t.total++
+
+	leaf := t.leaf(tokens)
+	pattern := t.match(leaf, tokens)
+	if pattern == nil {
+		pattern = &Pattern{tokens: tokens}
+		leaf.patterns = append(leaf.patterns, pattern)
+		t.patterns = append(t.patterns, pattern)
+	} else {
+		for i, token := range tokens {
+			if pattern.tokens[i] != token {
+				pattern.tokens[i] = Wildcard
+			}
+		}
+	}
+
+	pattern.Count++
+	if len(pattern.Examples) < t.examples {
+		pattern.Examples = append(pattern.Examples, message)
+	}
+}
+
+// Patterns returns the size most frequent patterns
+func (t *Drain) Patterns(size int) Patterns {
+	patterns := make([]Pattern, 0, len(t.patterns))
+	for _, p := range t.patterns {
+		pattern := *p
+		pattern.Template = strings.Join(p.tokens, " ")
+		patterns = append(patterns, pattern)
+	}
+
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return patterns[i].Count > patterns[j].Count
+	})
+	if len(patterns) > size {
+		patterns = patterns[:size]
+	}
+
+	return Patterns{Total: t.total, Patterns: patterns}
+}
+
+// leaf returns the node holding the patterns the tokens can match
+func (t *Drain) leaf(tokens []string) *node {
+	current, ok := t.root[len(tokens)]
+	if !ok {
+		current = &node{children: make(map[string]*node)}
+		t.root[len(tokens)] = current
+	}
+
+	for i := 0; i < t.depth-2 && i < len(tokens); i++ {
+		key := tokens[i]
+		next, ok := current.children[key]
+		if !ok {
+			// Bound the width of the tree, the extra tokens share the wildcard branch
+			if len(current.children) >= maxChildren {
+				key = Wildcard
+				next = current.children[key]
+			}
+			if next == nil {
+				next = &node{children: make(map[string]*node)}
+				current.children[key] = next
+			}
+		}
+		current = next
+	}
+
+	return current
+}
+
+// match returns the most similar pattern of the leaf, if similar enough
+func (t *Drain) match(leaf *node, tokens []string) *Pattern {
+	var best *Pattern
+	bestScore := -1.0
+	for _, pattern := range leaf.patterns {
+		same := 0
+		for i, token := range tokens {
+			if pattern.tokens[i] == token {
+				same++
+			}
+		}
+
+		score := float64(same) / float64(len(tokens))
+		if score > bestScore {
+			best, bestScore = pattern, score
+		}
+	}
+
+	if bestScore < t.similarity {
+		return nil
+	}
+	return best
+}
+
+func tokenize(message string) []string {
+	tokens := strings.Fields(message)
+	for i, token := range tokens {
+		if variableToken.MatchString(token) {
+			tokens[i] = Wildcard
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,61 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDrain(t *testing.T) {
+	drain := NewDrain(PatternParams{Similarity: defaultSimilarity, Depth: defaultDepth, Examples: 2})
+	for _, message := range []string{
+		"connected to 10.0.0.1 in 5ms",
+		"connected to 10.0.0.2 in 12ms",
+		"session opened for user alice",
+		"session opened for user bob",
+		"connected to 10.0.0.3 in 7ms",
+		"session opened for user carol",
+		"shutting down",
+		"",
+	} {
+		drain.AddMessage(message)
+	}
+
+	patterns := drain.Patterns(10)
+	if patterns.Total != 7 {
+		t.Errorf("Total = %d, want 7", patterns.Total)
+	}
+
+	var templates []string
+	var counts []int
+	for _, p := range patterns.Patterns {
+		templates = append(templates, p.Template)
+		counts = append(counts, p.Count)
+	}
+
+	wantTemplates := []string{"connected to <*> in <*>", "session opened for user <*>", "shutting down"}
+	if !reflect.DeepEqual(templates, wantTemplates) {
+		t.Errorf("templates = %q, want %q", templates, wantTemplates)
+	}
+	if !reflect.DeepEqual(counts, []int{3, 3, 1}) {
+		t.Errorf("counts = %v, want [3 3 1]", counts)
+	}
+
+	wantExamples := []string{"connected to 10.0.0.1 in 5ms", "connected to 10.0.0.2 in 12ms"}
+	if !reflect.DeepEqual(patterns.Patterns[0].Examples, wantExamples) {
+		t.Errorf("examples = %q, want %q", patterns.Patterns[0].Examples, wantExamples)
+	}
+
+	if got := len(drain.Patterns(1).Patterns); got != 1 {
+		t.Errorf("expected 1 pattern, got %d", got)
+	}
+}
+
+func TestDrain_Similarity(t *testing.T) {
+	drain := NewDrain(PatternParams{Similarity: 0.9, Depth: 3, Examples: 1})
+	drain.AddMessage("user alice logged in")
+	drain.AddMessage("user bob logged in")
+
+	if got := len(drain.Patterns(10).Patterns); got != 2 {
+		t.Errorf("expected 2 patterns with a high similarity, got %d", got)
+	}
+}
@@ -0,0 +1,120 @@
+package analytics
+
+import (
+	"sort"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/pipeline"
+)
+
+// SeverityUnknown counts the results without a recognized severity
+const SeverityUnknown = "unknown"
+
+// SeverityParams requests the number of results by severity
+type SeverityParams struct {
+	logs.SearchParams
+
+	// Labels holding the severity, the first one present is used. Defaults to severity and level.
+	Labels []string `json:"severityLabels,omitempty"`
+
+	// GroupBy is the label to split the counts by, e.g. pod
+	GroupBy string `json:"groupBy,omitempty"`
+}
+
+// SeverityBreakdown is the number of results by normalized severity
+type SeverityBreakdown struct {
+	Total      int             `json:"total"`
+	Severities map[string]int  `json:"severities"`
+	Groups     []SeverityGroup `json:"groups,omitempty"`
+}
+
+// SeverityGroup is the number of results by severity of a value of the group by label
+type SeverityGroup struct {
+	Value      string         `json:"value"`
+	Total      int            `json:"total"`
+	Severities map[string]int `json:"severities"`
+}
+
+// SeverityCounter counts the results streamed to it by severity
+type SeverityCounter struct {
+	labels    []string
+	groupBy   string
+	breakdown SeverityBreakdown
+	groups    map[string]*SeverityGroup
+}
+
+func NewSeverityCounter(params SeverityParams) *SeverityCounter {
+	t := &SeverityCounter{
+		labels:    params.Labels,
+		groupBy:   params.GroupBy,
+		breakdown: SeverityBreakdown{Severities: make(map[string]int)},
+		groups:    make(map[string]*SeverityGroup),
+	}
+	if len(t.labels) == 0 {
+		t.labels = []string{"severity", "level"}
+	}
+	return t
+}
+
+// Labels returns the labels holding the severity
+func (t *SeverityCounter) Labels() []string {
+	return t.labels
+}
+
+// Add counts the results
+func (t *SeverityCounter) Add(results []logs.Result) {
+	for _, r := range results {
+		severity := SeverityUnknown
+		for _, label := range t.labels {
+			if val, ok := r.Labels[label]; ok {
+				if s := pipeline.NormalizeSeverity(val); s != "" {
+					severity = s
+				}
+				break
+			}
+		}
+
+		t.add(severity, 1)
+		if t.groupBy != "" {
+			group, ok := t.groups[r.Labels[t.groupBy]]
+			if !ok {
+				group = &SeverityGroup{Value: r.Labels[t.groupBy], Severities: make(map[string]int)}
+				t.groups[group.Value] = group
+			}
+			group.Severities[severity]++
+			group.Total++
+		}
+	}
+}
+
+// AddValues counts the values of the severity label counted by a backend
+func (t *SeverityCounter) AddValues(values []logs.LabelValue) {
+	for _, v := range values {
+		severity := pipeline.NormalizeSeverity(v.Value)
+		if severity == "" {
+			severity = SeverityUnknown
+		}
+		t.add(severity, v.Count)
+	}
+}
+
+func (t *SeverityCounter) add(severity string, count int) {
+	t.breakdown.Severities[severity] += count
+	t.breakdown.Total += count
+}
+
+// Breakdown returns the counts, with the groups sorted by their number of results
+func (t *SeverityCounter) Breakdown() SeverityBreakdown {
+	breakdown := t.breakdown
+	for _, group := range t.groups {
+		breakdown.Groups = append(breakdown.Groups, *group)
+	}
+
+	sort.Slice(breakdown.Groups, func(i, j int) bool {
+		if breakdown.Groups[i].Total != breakdown.Groups[j].Total {
+			return breakdown.Groups[i].Total > breakdown.Groups[j].Total
+		}
+		return breakdown.Groups[i].Value < breakdown.Groups[j].Value
+	})
+	return breakdown
+}
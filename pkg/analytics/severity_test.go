@@ -0,0 +1,36 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestSeverityCounter(t *testing.T) {
+	results := []logs.Result{
+		{Labels: map[string]string{"level": "ERROR", "pod": "api"}},
+		{Labels: map[string]string{"level": "warn", "pod": "api"}},
+		{Labels: map[string]string{"severity": "error", "level": "info", "pod": "web"}},
+		{Labels: map[string]string{"level": "50", "pod": "api"}},
+		{Labels: map[string]string{"level": "whatever", "pod": "web"}},
+		{Labels: map[string]string{"pod": "db"}},
+	}
+
+	counter := NewSeverityCounter(SeverityParams{GroupBy: "pod"})
+	counter.Add(results)
+	counter.AddValues([]logs.LabelValue{{Value: "INFO", Count: 10}, {Value: "???", Count: 1}})
+
+	want := SeverityBreakdown{
+		Total:      17,
+		Severities: map[string]int{"error": 3, "warning": 1, "info": 10, "unknown": 3},
+		Groups: []SeverityGroup{
+			{Value: "api", Total: 3, Severities: map[string]int{"error": 2, "warning": 1}},
+			{Value: "web", Total: 2, Severities: map[string]int{"error": 1, "unknown": 1}},
+			{Value: "db", Total: 1, Severities: map[string]int{"unknown": 1}},
+		},
+	}
+	if got := counter.Breakdown(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Breakdown() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,102 @@
+package analytics
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+const (
+	// DefaultTopSize is the number of values returned when the request doesn't set a size
+	DefaultTopSize = 10
+
+	// MaxTopSize is the maximum number of values returned
+	MaxTopSize = 1000
+)
+
+// TopParams requests the most frequent values of a label
+type TopParams struct {
+	logs.SearchParams
+
+	// Label is the key of the label to count the values of
+	Label string `json:"label"`
+
+	// Size is the number of values to return. Defaults to 10.
+	Size int `json:"size,omitempty"`
+}
+
+func (p *TopParams) Validate() error {
+	if p.Label == "" {
+		return fmt.Errorf("label is required")
+	}
+
+	if p.Size <= 0 {
+		p.Size = DefaultTopSize
+	}
+	if p.Size > MaxTopSize {
+		return fmt.Errorf("size must not be greater than %d", MaxTopSize)
+	}
+	return nil
+}
+
+// TopValues is the most frequent values of a label, across all the backends
+type TopValues struct {
+	Label  string            `json:"label"`
+	Values []logs.LabelValue `json:"values"`
+}
+
+// Counter counts the values of a label in the results streamed to it
+type Counter struct {
+	label  string
+	counts map[string]int
+}
+
+func NewCounter(label string) *Counter {
+	return &Counter{label: label, counts: make(map[string]int)}
+}
+
+// Add counts the results carrying the label
+func (t *Counter) Add(results []logs.Result) {
+	for _, r := range results {
+		if val, ok := r.Labels[t.label]; ok {
+			t.counts[val]++
+		}
+	}
+}
+
+// Values returns the counted values
+func (t *Counter) Values() []logs.LabelValue {
+	values := make([]logs.LabelValue, 0, len(t.counts))
+	for val, count := range t.counts {
+		values = append(values, logs.LabelValue{Value: val, Count: count})
+	}
+	return values
+}
+
+// MergeTop sums the counts of the values and returns the size most frequent ones
+func MergeTop(size int, lists ...[]logs.LabelValue) []logs.LabelValue {
+	counts := make(map[string]int)
+	for _, list := range lists {
+		for _, v := range list {
+			counts[v.Value] += v.Count
+		}
+	}
+
+	values := make([]logs.LabelValue, 0, len(counts))
+	for val, count := range counts {
+		values = append(values, logs.LabelValue{Value: val, Count: count})
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+
+	if len(values) > size {
+		values = values[:size]
+	}
+	return values
+}
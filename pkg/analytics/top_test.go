@@ -0,0 +1,58 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestCounter(t *testing.T) {
+	counter := NewCounter("pod")
+	counter.Add([]logs.Result{
+		{Labels: map[string]string{"pod": "a"}},
+		{Labels: map[string]string{"pod": "b"}},
+		{Labels: map[string]string{"namespace": "default"}},
+	})
+	counter.Add([]logs.Result{{Labels: map[string]string{"pod": "a"}}})
+
+	want := []logs.LabelValue{{Value: "a", Count: 2}, {Value: "b", Count: 1}}
+	if got := MergeTop(10, counter.Values()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+}
+
+func TestMergeTop(t *testing.T) {
+	elastic := []logs.LabelValue{{Value: "api", Count: 10}, {Value: "web", Count: 4}}
+	files := []logs.LabelValue{{Value: "web", Count: 7}, {Value: "db", Count: 1}, {Value: "cache", Count: 1}}
+
+	tests := []struct {
+		name string
+		size int
+		want []logs.LabelValue
+	}{
+		{name: "all", size: 10, want: []logs.LabelValue{{Value: "web", Count: 11}, {Value: "api", Count: 10}, {Value: "cache", Count: 1}, {Value: "db", Count: 1}}},
+		{name: "top 2", size: 2, want: []logs.LabelValue{{Value: "web", Count: 11}, {Value: "api", Count: 10}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MergeTop(tt.size, elastic, files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MergeTop() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTopParams_Validate(t *testing.T) {
+	p := TopParams{Label: "pod"}
+	if err := p.Validate(); err != nil || p.Size != DefaultTopSize {
+		t.Errorf("Validate() = %v, size %d", err, p.Size)
+	}
+
+	for _, p := range []TopParams{{}, {Label: "pod", Size: MaxTopSize + 1}} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("expected an error for %+v", p)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package analytics
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+)
+
+const (
+	// MaxBuckets is the maximum number of intervals of a histogram
+	MaxBuckets = 1000
+
+	// DefaultLimit is the number of results fetched from the backends to compute the aggregations,
+	// when the request doesn't set a limit.
+	DefaultLimit = 10000
+
+	// MaxPages is the maximum number of pages fetched from a backend to count the values of a label
+	MaxPages = 10
+)
+
+// intervals are the candidates for the interval of a histogram when none is requested
+var intervals = []time.Duration{
+	time.Second, 5 * time.Second, 15 * time.Second, 30 * time.Second,
+	time.Minute, 5 * time.Minute, 15 * time.Minute, 30 * time.Minute,
+	time.Hour, 3 * time.Hour, 6 * time.Hour, 12 * time.Hour, 24 * time.Hour,
+}
+
+// VolumeParams requests the number of log lines per interval
+type VolumeParams struct {
+	logs.SearchParams
+
+	// Interval is the width of the buckets, e.g. "5m".
+	// Defaults to the interval giving about 60 buckets over the searched time range.
+	Interval string `json:"interval,omitempty"`
+
+	// GroupBy is the list of label keys to split the counts by, in addition to the backend
+	GroupBy []string `json:"groupBy,omitempty"`
+}
+
+// Histogram is the log volume over the searched time range
+type Histogram struct {
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Interval string    `json:"interval"`
+	Series   []Series  `json:"series"`
+}
+
+// Series is the log volume of a single backend and combination of the group by labels
+type Series struct {
+	Backend string            `json:"backend"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Total   int               `json:"total"`
+	// Counts is the number of log lines of every interval, starting at the start of the histogram
+	Counts []int `json:"counts"`
+}
+
+// Range returns the time range of the histogram and the width of its buckets
+func (p *VolumeParams) Range(now time.Time) (start, end time.Time, interval time.Duration, err error) {
+	end = now
+	if e := p.GetEnd(); e != nil {
+		end = *e
+	}
+	start = end.Add(-time.Hour)
+	if s := p.GetStart(); s != nil {
+		start = *s
+	}
+	if !start.Before(end) {
+		return start, end, 0, fmt.Errorf("start must be before end")
+	}
+
+	if p.Interval != "" {
+		d, err := durationUtil.ParseDuration(p.Interval)
+		if err != nil {
+			return start, end, 0, fmt.Errorf("invalid interval %s: %w", p.Interval, err)
+		}
+		interval = time.Duration(d)
+		if interval <= 0 {
+			return start, end, 0, fmt.Errorf("interval must be greater than 0")
+		}
+	} else {
+		interval = intervals[len(intervals)-1]
+		for _, candidate := range intervals {
+			if end.Sub(start)/candidate <= 60 {
+				interval = candidate
+				break
+			}
+		}
+	}
+
+	if end.Sub(start)/interval >= MaxBuckets {
+		return start, end, 0, fmt.Errorf("interval %s is too small for the time range, it results in more than %d buckets", interval, MaxBuckets)
+	}
+
+	// Align the buckets so that they're stable across requests
+	return start.Truncate(interval), end, interval, nil
+}
+
+// NewHistogram counts the results of every backend per interval.
+// Results without a valid timestamp or outside of the time range are ignored.
+func NewHistogram(start, end time.Time, interval time.Duration, groupBy []string, results map[string][]logs.Result) Histogram {
+	h := Histogram{
+		Start:    start,
+		End:      end,
+		Interval: interval.String(),
+		Series:   []Series{},
+	}
+
+	size := int(end.Sub(start)/interval) + 1
+	index := make(map[string]int)
+	for backend, backendResults := range results {
+		for _, r := range backendResults {
+			ts, err := time.Parse(time.RFC3339Nano, r.Time)
+			if err != nil || ts.Before(start) || ts.After(end) {
+				continue
+			}
+
+			labels := groupLabels(r.Labels, groupBy)
+			key := seriesKey(backend, labels)
+			i, ok := index[key]
+			if !ok {
+				i = len(h.Series)
+				index[key] = i
+				h.Series = append(h.Series, Series{Backend: backend, Labels: labels, Counts: make([]int, size)})
+			}
+
+			h.Series[i].Counts[int(ts.Sub(start)/interval)]++
+			h.Series[i].Total++
+		}
+	}
+
+	sort.Slice(h.Series, func(i, j int) bool {
+		if h.Series[i].Total != h.Series[j].Total {
+			return h.Series[i].Total > h.Series[j].Total
+		}
+		return seriesKey(h.Series[i].Backend, h.Series[i].Labels) < seriesKey(h.Series[j].Backend, h.Series[j].Labels)
+	})
+	return h
+}
+
+func groupLabels(labels map[string]string, groupBy []string) map[string]string {
+	if len(groupBy) == 0 {
+		return nil
+	}
+
+	grouped := make(map[string]string, len(groupBy))
+	for _, key := range groupBy {
+		grouped[key] = labels[key]
+	}
+	return grouped
+}
+
+func seriesKey(backend string, labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(backend)
+	for _, k := range keys {
+		fmt.Fprintf(&b, ",%s=%s", k, labels[k])
+	}
+	return b.String()
+}
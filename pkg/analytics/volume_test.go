@@ -0,0 +1,77 @@
+package analytics
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestVolumeParams_Range(t *testing.T) {
+	now := time.Date(2023, 5, 1, 12, 7, 30, 0, time.UTC)
+	tests := []struct {
+		name         string
+		params       VolumeParams
+		wantStart    time.Time
+		wantInterval time.Duration
+		wantErr      bool
+	}{
+		{name: "default", params: VolumeParams{SearchParams: logs.SearchParams{Start: "2023-05-01T11:07:30Z", End: "2023-05-01T12:07:30Z"}}, wantStart: time.Date(2023, 5, 1, 11, 7, 0, 0, time.UTC), wantInterval: time.Minute},
+		{name: "day", params: VolumeParams{SearchParams: logs.SearchParams{Start: "2023-04-30T12:07:30Z", End: "2023-05-01T12:07:30Z"}}, wantStart: time.Date(2023, 4, 30, 12, 0, 0, 0, time.UTC), wantInterval: 30 * time.Minute},
+		{name: "interval", params: VolumeParams{SearchParams: logs.SearchParams{Start: "2023-05-01T11:07:30Z", End: "2023-05-01T12:07:30Z"}, Interval: "5m"}, wantStart: time.Date(2023, 5, 1, 11, 5, 0, 0, time.UTC), wantInterval: 5 * time.Minute},
+		{name: "too many buckets", params: VolumeParams{SearchParams: logs.SearchParams{Start: "2023-04-01T11:07:30Z", End: "2023-05-01T12:07:30Z"}, Interval: "1s"}, wantErr: true},
+		{name: "start after end", params: VolumeParams{SearchParams: logs.SearchParams{Start: "2023-05-01T13:07:30Z", End: "2023-05-01T12:07:30Z"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, _, interval, err := tt.params.Range(now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Range() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !start.Equal(tt.wantStart) || interval != tt.wantInterval {
+				t.Errorf("Range() = %s, %s, want %s, %s", start, interval, tt.wantStart, tt.wantInterval)
+			}
+		})
+	}
+}
+
+func TestNewHistogram(t *testing.T) {
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	end := start.Add(3 * time.Minute)
+	results := map[string][]logs.Result{
+		"elastic": {
+			{Time: "2023-05-01T12:00:10Z", Labels: map[string]string{"pod": "a"}},
+			{Time: "2023-05-01T12:00:50Z", Labels: map[string]string{"pod": "b"}},
+			{Time: "2023-05-01T12:02:00.5Z", Labels: map[string]string{"pod": "a"}},
+			{Time: "invalid", Labels: map[string]string{"pod": "a"}},
+			{Time: "2023-05-01T13:00:00Z", Labels: map[string]string{"pod": "a"}},
+		},
+		"files": {
+			{Time: "2023-05-01T12:01:00Z"},
+		},
+	}
+
+	h := NewHistogram(start, end, time.Minute, nil, results)
+	want := []Series{
+		{Backend: "elastic", Total: 3, Counts: []int{2, 0, 1, 0}},
+		{Backend: "files", Total: 1, Counts: []int{0, 1, 0, 0}},
+	}
+	if !reflect.DeepEqual(h.Series, want) {
+		t.Errorf("Series = %+v, want %+v", h.Series, want)
+	}
+
+	h = NewHistogram(start, end, time.Minute, []string{"pod"}, results)
+	want = []Series{
+		{Backend: "elastic", Labels: map[string]string{"pod": "a"}, Total: 2, Counts: []int{1, 0, 1, 0}},
+		{Backend: "elastic", Labels: map[string]string{"pod": "b"}, Total: 1, Counts: []int{1, 0, 0, 0}},
+		{Backend: "files", Labels: map[string]string{"pod": ""}, Total: 1, Counts: []int{0, 1, 0, 0}},
+	}
+	if !reflect.DeepEqual(h.Series, want) {
+		t.Errorf("Series = %+v, want %+v", h.Series, want)
+	}
+}
@@ -0,0 +1,230 @@
+// Package annotation keeps the notes attached to the results of the searches,
+// so that the findings of an investigation stay linked to the logs they're about.
+package annotation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+	"github.com/labstack/echo/v4"
+)
+
+const fileName = "annotations.json"
+
+// GlobalStore keeps the annotations.
+// It's nil when the annotations aren't configured.
+var GlobalStore *Store
+
+// now is replaced in the tests
+var now = time.Now
+
+// ErrNotFound is returned when deleting an annotation that doesn't exist
+var ErrNotFound = errors.New("annotation not found")
+
+// Annotation is a note attached to a result, identified by its backend, id and timestamp
+type Annotation struct {
+	ID      string    `json:"id"`
+	Backend string    `json:"backend"`
+	Result  string    `json:"result"`
+	Time    string    `json:"timestamp"`
+	Author  string    `json:"author,omitempty"`
+	Text    string    `json:"text"`
+	Tags    []string  `json:"tags,omitempty"`
+	Tenant  string    `json:"tenant,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Filter selects the annotations to list, the empty fields match every annotation
+type Filter struct {
+	Backend string
+	Result  string
+	Tag     string
+	Tenant  string
+}
+
+func (f Filter) match(a Annotation) bool {
+	return (f.Backend == "" || f.Backend == a.Backend) &&
+		(f.Result == "" || f.Result == a.Result) &&
+		(f.Tag == "" || collections.Contains(a.Tags, f.Tag)) &&
+		f.Tenant == a.Tenant
+}
+
+// Store keeps the annotations in memory, and rewrites them to a json file in its directory on every change
+type Store struct {
+	path string
+
+	lock        sync.Mutex
+	annotations []Annotation
+}
+
+// Open loads the annotations of the directory, creating the directory if needed
+func Open(config logs.AnnotationsConfig) (*Store, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("annotations path is required")
+	}
+	if err := os.MkdirAll(config.Path, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating the annotations directory: %w", err)
+	}
+
+	s := &Store{path: filepath.Join(config.Path, fileName)}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the annotations: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.annotations); err != nil {
+		return nil, fmt.Errorf("error decoding the annotations: %w", err)
+	}
+	return s, nil
+}
+
+// Add validates the annotation, sets its id and creation time, and saves it
+func (t *Store) Add(a Annotation) (Annotation, error) {
+	if a.Backend == "" || a.Result == "" {
+		return a, fmt.Errorf("the backend and the id of the result are required")
+	}
+	if a.Text == "" {
+		return a, fmt.Errorf("the text is required")
+	}
+	if a.Time != "" {
+		if _, err := time.Parse(time.RFC3339Nano, a.Time); err != nil {
+			return a, fmt.Errorf("invalid timestamp %q: %w", a.Time, err)
+		}
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return a, err
+	}
+	a.ID = hex.EncodeToString(id)
+	a.Created = now().UTC()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if err := t.save(append(t.annotations, a)); err != nil {
+		return a, err
+	}
+	t.annotations = append(t.annotations, a)
+	return a, nil
+}
+
+// List returns the annotations matching the filter, ordered by the timestamp of their result
+func (t *Store) List(filter Filter) []Annotation {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	annotations := []Annotation{}
+	for _, a := range t.annotations {
+		if filter.match(a) {
+			annotations = append(annotations, a)
+		}
+	}
+	sort.SliceStable(annotations, func(i, j int) bool { return annotations[i].Time < annotations[j].Time })
+	return annotations
+}
+
+// Delete removes the annotation of the tenant
+func (t *Store) Delete(id, tenant string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i, a := range t.annotations {
+		if a.ID != id || a.Tenant != tenant {
+			continue
+		}
+		annotations := append(append([]Annotation{}, t.annotations[:i]...), t.annotations[i+1:]...)
+		if err := t.save(annotations); err != nil {
+			return err
+		}
+		t.annotations = annotations
+		return nil
+	}
+	return ErrNotFound
+}
+
+// save writes the annotations to a temporary file renamed over the previous one, so that a crash doesn't lose them
+func (t *Store) save(annotations []Annotation) error {
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		return fmt.Errorf("error encoding the annotations: %w", err)
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing the annotations: %w", err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return fmt.Errorf("error writing the annotations: %w", err)
+	}
+	return nil
+}
+
+// CreateHandler attaches an annotation to a result, authored by the authenticated user
+func CreateHandler(c echo.Context) error {
+	cc := c.(*api.Context)
+	if GlobalStore == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "annotations aren't configured")
+	}
+
+	var a Annotation
+	if err := c.Bind(&a); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	a.Author = ""
+	if cc.User != nil {
+		a.Author = cc.User.Name
+	}
+	a.Tenant = cc.Tenant
+
+	a, err := GlobalStore.Add(a)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusCreated, a)
+}
+
+// ListHandler returns the annotations of the tenant, filtered by the backend, result and tag query params
+func ListHandler(c echo.Context) error {
+	cc := c.(*api.Context)
+	if GlobalStore == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "annotations aren't configured")
+	}
+
+	return c.JSON(http.StatusOK, GlobalStore.List(Filter{
+		Backend: c.QueryParam("backend"),
+		Result:  c.QueryParam("result"),
+		Tag:     c.QueryParam("tag"),
+		Tenant:  cc.Tenant,
+	}))
+}
+
+// DeleteHandler removes an annotation of the tenant
+func DeleteHandler(c echo.Context) error {
+	cc := c.(*api.Context)
+	if GlobalStore == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "annotations aren't configured")
+	}
+
+	err := GlobalStore.Delete(c.Param("id"), cc.Tenant)
+	if errors.Is(err, ErrNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
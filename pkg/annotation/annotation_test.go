@@ -0,0 +1,111 @@
+package annotation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/labstack/echo/v4"
+)
+
+func TestStore(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	dir := t.TempDir()
+	s, err := Open(logs.AnnotationsConfig{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, invalid := range []Annotation{
+		{Result: "a", Text: "missing backend"},
+		{Backend: "store", Result: "a"},
+		{Backend: "store", Result: "a", Text: "invalid time", Time: "yesterday"},
+	} {
+		if _, err := s.Add(invalid); err == nil {
+			t.Errorf("Add(%+v) succeeded, want an error", invalid)
+		}
+	}
+
+	second, err := s.Add(Annotation{Backend: "store", Result: "b", Time: "2023-05-01T11:00:00Z", Author: "alice", Text: "retry storm", Tags: []string{"root-cause"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := s.Add(Annotation{Backend: "store", Result: "a", Time: "2023-05-01T10:00:00Z", Author: "bob", Text: "first timeout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.Add(Annotation{Backend: "loki", Result: "a", Text: "other tenant", Tenant: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The annotations are loaded back from the directory
+	s, err = Open(logs.AnnotationsConfig{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []Annotation
+	}{
+		{name: "all", want: []Annotation{first, second}},
+		{name: "result", filter: Filter{Backend: "store", Result: "b"}, want: []Annotation{second}},
+		{name: "tag", filter: Filter{Tag: "root-cause"}, want: []Annotation{second}},
+		{name: "tenant", filter: Filter{Tenant: "b"}, want: []Annotation{other}},
+		{name: "no match", filter: Filter{Backend: "loki"}, want: []Annotation{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.List(tt.filter); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("List() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if err := s.Delete(other.ID, ""); err != ErrNotFound {
+		t.Errorf("Delete() of another tenant's annotation error = %v, want %v", err, ErrNotFound)
+	}
+	if err := s.Delete(first.ID, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.List(Filter{}); !reflect.DeepEqual(got, []Annotation{second}) {
+		t.Errorf("List() after Delete() = %+v, want %+v", got, []Annotation{second})
+	}
+}
+
+func TestCreateHandler(t *testing.T) {
+	s, err := Open(logs.AnnotationsConfig{Path: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { GlobalStore = nil }()
+	GlobalStore = s
+
+	e := echo.New()
+	body := `{"backend":"store","result":"a","timestamp":"2023-05-01T10:00:00Z","text":"first timeout","author":"someone else","tenant":"b"}`
+	req := httptest.NewRequest(http.MethodPost, "/annotations", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := &api.Context{Context: e.NewContext(req, rec), User: &api.User{Name: "alice"}, Tenant: "a"}
+
+	if err := CreateHandler(c); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusCreated {
+		t.Errorf("CreateHandler() = %d, want %d", rec.Code, http.StatusCreated)
+	}
+
+	got := s.List(Filter{Tenant: "a"})
+	if len(got) != 1 || got[0].Author != "alice" || got[0].Text != "first timeout" {
+		t.Errorf("the annotation of the tenant = %+v, want the one authored by alice", got)
+	}
+}
@@ -0,0 +1,336 @@
+package anomaly
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/auth"
+	"github.com/flanksource/apm-hub/pkg/pipeline"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/logger"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	KindSpike      = "spike"
+	KindDrop       = "drop"
+	KindSilence    = "silence"
+	KindErrorRatio = "errorRatio"
+)
+
+const (
+	// minBaseline is the number of windows required before anomalies are reported
+	minBaseline = 3
+
+	// maxAnomalies is the number of anomalies retained in memory
+	maxAnomalies = 500
+
+	// searchLimit is the maximum number of results counted per window
+	searchLimit = 10000
+)
+
+// GlobalDetector detects the anomalies of the log volume.
+// It's nil when anomaly detection isn't configured.
+var GlobalDetector *Detector
+
+// Anomaly is a window of a watch that deviates from its baseline
+type Anomaly struct {
+	Watch string    `json:"watch"`
+	Kind  string    `json:"kind"`
+	Time  time.Time `json:"time"`
+
+	// Value is the volume, or the error ratio, of the window
+	Value float64 `json:"value"`
+	// Expected is the average of the baseline
+	Expected float64 `json:"expected"`
+	// Deviation is the number of standard deviations from the baseline
+	Deviation float64 `json:"deviation"`
+}
+
+func (t Anomaly) String() string {
+	return fmt.Sprintf("%s of %s: %.4g (expected %.4g, %.1fσ)", t.Kind, t.Watch, t.Value, t.Expected, t.Deviation)
+}
+
+// Window is the volume of a watch over an interval
+type Window struct {
+	Time   time.Time `json:"time"`
+	Count  int       `json:"count"`
+	Errors int       `json:"errors"`
+}
+
+// SearchFunc returns the processed results of every backend matching the search params,
+// with the errors of the backends that couldn't be searched
+type SearchFunc func(q *logs.SearchParams) ([]logs.Result, error)
+
+// Detector periodically measures the volume of the watches
+// and compares it to their baseline.
+type Detector struct {
+	config   logs.AnomalyConfig
+	interval time.Duration
+	search   SearchFunc
+
+	lock      sync.Mutex
+	history   map[string][]Window
+	anomalies []Anomaly
+	handlers  []func(Anomaly)
+}
+
+func NewDetector(config logs.AnomalyConfig, backends []logs.SearchBackend, search SearchFunc) (*Detector, error) {
+	t := &Detector{
+		config:   config,
+		interval: 5 * time.Minute,
+		search:   search,
+		history:  make(map[string][]Window),
+	}
+
+	if config.Interval != "" {
+		d, err := durationUtil.ParseDuration(config.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval %s: %w", config.Interval, err)
+		}
+		t.interval = time.Duration(d)
+	}
+	if t.interval <= 0 {
+		return nil, fmt.Errorf("interval must be greater than 0")
+	}
+
+	if t.config.Baseline <= 0 {
+		t.config.Baseline = 12
+	}
+	if t.config.Threshold <= 0 {
+		t.config.Threshold = 3
+	}
+	if t.config.MinVolume <= 0 {
+		t.config.MinVolume = 10
+	}
+	if len(t.config.SeverityLabels) == 0 {
+		t.config.SeverityLabels = []string{"severity", "level"}
+	}
+	if len(t.config.Watches) == 0 {
+		t.config.Watches = routeWatches(backends)
+	}
+
+	return t, nil
+}
+
+// routeWatches returns a watch for every route of the backends, but the shadow ones.
+// Route labels matching multiple or negated values can't be searched and are left out.
+func routeWatches(backends []logs.SearchBackend) []logs.AnomalyWatch {
+	var watches []logs.AnomalyWatch
+	for i, backend := range backends {
+		if backend.Shadow {
+			continue
+		}
+		name := backend.Name
+		if name == "" {
+			name = fmt.Sprintf("backend[%d]", i)
+		}
+
+		for j, route := range backend.Routes {
+			watch := logs.AnomalyWatch{
+				Name: fmt.Sprintf("%s/routes[%d]", name, j),
+				Type: route.Type,
+				Id:   route.IdPrefix,
+			}
+			for k, v := range route.Labels {
+				if strings.Contains(v, ",") || strings.HasPrefix(v, "!") {
+					continue
+				}
+				if watch.Labels == nil {
+					watch.Labels = make(map[string]string)
+				}
+				watch.Labels[k] = v
+			}
+			watches = append(watches, watch)
+		}
+	}
+	return watches
+}
+
+// OnAnomaly registers a function called with every detected anomaly
+func (t *Detector) OnAnomaly(fn func(Anomaly)) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.handlers = append(t.handlers, fn)
+}
+
+// Start runs the checks in the background at every interval
+func (t *Detector) Start() {
+	logger.Infof("watching the volume of %d searches every %s", len(t.config.Watches), t.interval)
+	go func() {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			t.Check(now)
+		}
+	}()
+}
+
+// Check measures the window of every watch ending now
+func (t *Detector) Check(now time.Time) {
+	for _, watch := range t.config.Watches {
+		q := &logs.SearchParams{
+			Type:   watch.Type,
+			Id:     watch.Id,
+			Labels: watch.Labels,
+			Query:  watch.Query,
+			Start:  now.Add(-t.interval).Format(time.RFC3339),
+			End:    now.Format(time.RFC3339),
+			Limit:  searchLimit,
+		}
+		q.SetDefaults()
+
+		results, err := t.search(q)
+		if err != nil {
+			// A partial window would read as a drop or a silence
+			logger.Errorf("error measuring the volume of %s: %v", watch.Name, err)
+			continue
+		}
+
+		window := Window{Time: now}
+		for _, r := range results {
+			window.Count++
+			if t.isError(r) {
+				window.Errors++
+			}
+		}
+
+		t.Record(watch.Name, window)
+	}
+}
+
+// Record adds the window to the history of the watch and reports its anomalies
+func (t *Detector) Record(watch string, window Window) []Anomaly {
+	t.lock.Lock()
+	history := t.history[watch]
+	anomalies := detect(watch, history, window, t.config)
+
+	history = append(history, window)
+	if len(history) > t.config.Baseline {
+		history = history[len(history)-t.config.Baseline:]
+	}
+	t.history[watch] = history
+
+	t.anomalies = append(t.anomalies, anomalies...)
+	if len(t.anomalies) > maxAnomalies {
+		t.anomalies = t.anomalies[len(t.anomalies)-maxAnomalies:]
+	}
+	handlers := t.handlers
+	t.lock.Unlock()
+
+	for _, anomaly := range anomalies {
+		logger.Warnf("[anomaly] %s", anomaly)
+		for _, handler := range handlers {
+			handler(anomaly)
+		}
+	}
+	return anomalies
+}
+
+// Anomalies returns the anomalies retained in memory, most recent first
+func (t *Detector) Anomalies() []Anomaly {
+	out := []Anomaly{}
+	if t == nil {
+		return out
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for i := len(t.anomalies) - 1; i >= 0; i-- {
+		out = append(out, t.anomalies[i])
+	}
+	return out
+}
+
+func (t *Detector) isError(r logs.Result) bool {
+	for _, label := range t.config.SeverityLabels {
+		if val, ok := r.Labels[label]; ok {
+			severity := pipeline.NormalizeSeverity(val)
+			return severity == pipeline.SeverityError || severity == pipeline.SeverityFatal
+		}
+	}
+	return false
+}
+
+// Handler returns the detected anomalies.
+// They're only returned to the admins, as the watches measure the volume of every tenant.
+func Handler(c echo.Context) error {
+	cc := c.(*api.Context)
+	if !auth.GlobalAuthorizer.IsAdmin(cc.User) {
+		return echo.NewHTTPError(http.StatusForbidden, "the anomalies are restricted to the admins")
+	}
+	return cc.JSON(http.StatusOK, GlobalDetector.Anomalies())
+}
+
+// detect compares the window to the baseline of the history.
+// The standard deviation has a floor, so that flat baselines don't turn every change into an anomaly.
+func detect(watch string, history []Window, window Window, config logs.AnomalyConfig) []Anomaly {
+	if len(history) < minBaseline {
+		return nil
+	}
+
+	var anomalies []Anomaly
+	newAnomaly := func(kind string, value, mean, stddev float64) Anomaly {
+		return Anomaly{Watch: watch, Kind: kind, Time: window.Time, Value: value, Expected: mean, Deviation: (value - mean) / stddev}
+	}
+
+	counts := make([]float64, len(history))
+	for i, w := range history {
+		counts[i] = float64(w.Count)
+	}
+	mean, stddev := meanStddev(counts)
+	stddev = math.Max(stddev, math.Max(math.Sqrt(mean), 1))
+
+	count := float64(window.Count)
+	switch {
+	case count > mean+config.Threshold*stddev:
+		anomalies = append(anomalies, newAnomaly(KindSpike, count, mean, stddev))
+	case mean >= config.MinVolume && count < mean-config.Threshold*stddev:
+		kind := KindDrop
+		if window.Count == 0 {
+			kind = KindSilence
+		}
+		anomalies = append(anomalies, newAnomaly(kind, count, mean, stddev))
+	}
+
+	if count < config.MinVolume {
+		return anomalies
+	}
+
+	var ratios []float64
+	for _, w := range history {
+		if w.Count > 0 {
+			ratios = append(ratios, float64(w.Errors)/float64(w.Count))
+		}
+	}
+	if len(ratios) < minBaseline {
+		return anomalies
+	}
+
+	ratioMean, ratioStddev := meanStddev(ratios)
+	ratioStddev = math.Max(ratioStddev, 0.01)
+	ratio := float64(window.Errors) / count
+	if ratio > ratioMean+config.Threshold*ratioStddev {
+		anomalies = append(anomalies, newAnomaly(KindErrorRatio, ratio, ratioMean, ratioStddev))
+	}
+
+	return anomalies
+}
+
+func meanStddev(values []float64) (mean, stddev float64) {
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+
+	for _, v := range values {
+		stddev += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(stddev / float64(len(values)))
+}
@@ -0,0 +1,89 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestDetect(t *testing.T) {
+	config := logs.AnomalyConfig{Threshold: 3, MinVolume: 10}
+	baseline := []Window{{Count: 100, Errors: 1}, {Count: 110, Errors: 2}, {Count: 90, Errors: 1}, {Count: 105, Errors: 1}}
+
+	tests := []struct {
+		name    string
+		history []Window
+		window  Window
+		want    []string
+	}{
+		{name: "normal", history: baseline, window: Window{Count: 98, Errors: 1}},
+		{name: "spike", history: baseline, window: Window{Count: 400, Errors: 4}, want: []string{KindSpike}},
+		{name: "drop", history: baseline, window: Window{Count: 20}, want: []string{KindDrop}},
+		{name: "silence", history: baseline, window: Window{}, want: []string{KindSilence}},
+		{name: "error ratio", history: baseline, window: Window{Count: 100, Errors: 30}, want: []string{KindErrorRatio}},
+		{name: "spike of errors", history: baseline, window: Window{Count: 500, Errors: 400}, want: []string{KindSpike, KindErrorRatio}},
+		{name: "not enough history", history: baseline[:2], window: Window{Count: 400}},
+		{name: "low volume silence", history: []Window{{Count: 2}, {Count: 0}, {Count: 1}}, window: Window{}},
+		{name: "flat baseline", history: []Window{{Count: 5}, {Count: 5}, {Count: 5}}, window: Window{Count: 9}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			anomalies := detect("watch", tt.history, tt.window, config)
+			var kinds []string
+			for _, a := range anomalies {
+				kinds = append(kinds, a.Kind)
+			}
+			if len(kinds) != len(tt.want) {
+				t.Fatalf("detect() = %v, want %v", anomalies, tt.want)
+			}
+			for i := range kinds {
+				if kinds[i] != tt.want[i] {
+					t.Errorf("detect() = %v, want %v", kinds, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDetector(t *testing.T) {
+	volume := 100
+	search := func(q *logs.SearchParams) ([]logs.Result, error) {
+		results := make([]logs.Result, volume)
+		for i := range results {
+			results[i].Labels = map[string]string{"level": "INFO"}
+		}
+		return results, nil
+	}
+
+	backends := []logs.SearchBackend{{Name: "files", Routes: logs.Routes{{Type: "File", Labels: map[string]string{"app": "api", "env": "dev,prod"}}}}}
+	detector, err := NewDetector(logs.AnomalyConfig{}, backends, search)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	watches := detector.config.Watches
+	if len(watches) != 1 || watches[0].Name != "files/routes[0]" || watches[0].Type != "File" || len(watches[0].Labels) != 1 {
+		t.Fatalf("unexpected watches: %+v", watches)
+	}
+
+	var notified []Anomaly
+	detector.OnAnomaly(func(a Anomaly) { notified = append(notified, a) })
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		detector.Check(now.Add(time.Duration(i) * time.Minute))
+	}
+	volume = 0
+	detector.Check(now.Add(5 * time.Minute))
+
+	anomalies := detector.Anomalies()
+	if len(anomalies) != 1 || anomalies[0].Kind != KindSilence || len(notified) != 1 {
+		t.Errorf("expected a silence, got %v", anomalies)
+	}
+
+	if got := (*Detector)(nil).Anomalies(); got == nil || len(got) != 0 {
+		t.Errorf("expected no anomalies for a nil detector, got %v", got)
+	}
+}
@@ -0,0 +1,257 @@
+// Package appdynamics searches the business transactions of AppDynamics with the Analytics Events api
+package appdynamics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const (
+	defaultURL = "https://analytics.api.appdynamics.com"
+
+	// maxLimit is the maximum number of events returned by a query
+	maxLimit = 10000
+)
+
+var defaultExperiences = []string{"ERROR"}
+
+// filters are the fields of the transactions filtered by the labels of the search
+var filters = [][2]string{
+	{"tier", "segments.tier"},
+	{"node", "segments.node"},
+	{"transaction", "transactionName"},
+}
+
+type AppDynamicsSearch struct {
+	config *logs.AppDynamicsBackendConfig
+	client *rest.Client
+	query  *template.Template
+}
+
+func NewAppDynamicsSearchBackend(kClient *kommons.Client, config *logs.AppDynamicsBackendConfig) (*AppDynamicsSearch, error) {
+	if config.Account == "" {
+		return nil, fmt.Errorf("the account is required")
+	}
+	if config.APIKey == nil {
+		return nil, fmt.Errorf("the api key is required")
+	}
+	query, err := template.New("query").Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the query template: %w", err)
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	conn.Headers = map[string]kommons.EnvVar{}
+	for name, header := range config.Headers {
+		conn.Headers[name] = header
+	}
+	conn.Headers["X-Events-API-AccountName"] = kommons.EnvVar{Value: config.Account}
+	conn.Headers["X-Events-API-Key"] = *config.APIKey
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &AppDynamicsSearch{config: config, client: client, query: query}, nil
+}
+
+// quote returns the ADQL string literal of the value
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// in returns the ADQL condition matching the field against the values
+func in(field string, values []string) string {
+	if len(values) == 1 {
+		return field + " = " + quote(values[0])
+	}
+	var quoted []string
+	for _, v := range values {
+		quoted = append(quoted, quote(v))
+	}
+	return field + " IN (" + strings.Join(quoted, ", ") + ")"
+}
+
+// RenderQuery renders the query template, or the query of the transactions of the experiences of the application of the id,
+// filtered by the labels of the search, with the query of the search as an ADQL condition
+func (t *AppDynamicsSearch) RenderQuery(q *logs.SearchParams) (string, error) {
+	if t.config.Query != "" {
+		var buf bytes.Buffer
+		if err := t.query.Execute(&buf, q); err != nil {
+			return "", fmt.Errorf("error executing the query template: %w", err)
+		}
+		return strings.TrimSpace(buf.String()), nil
+	}
+
+	experiences := t.config.Experiences
+	if len(experiences) == 0 {
+		experiences = defaultExperiences
+	}
+	conditions := []string{in("userExperience", experiences)}
+	if q.Id != "" {
+		conditions = append(conditions, in("application", []string{q.Id}))
+	}
+	for _, f := range filters {
+		if value, ok := q.Labels[f[0]]; ok {
+			conditions = append(conditions, in(f[1], strings.Split(value, ",")))
+		}
+	}
+	if q.Query != "" {
+		conditions = append(conditions, "("+q.Query+")")
+	}
+	return "SELECT * FROM transactions WHERE " + strings.Join(conditions, " AND "), nil
+}
+
+// events are the results of a query of the Events api, with the fields of the values of each result
+type events struct {
+	Fields []struct {
+		Field string `json:"field"`
+	} `json:"fields"`
+	Results [][]any `json:"results"`
+}
+
+// Search returns the last transactions of the query, up to the limit of the search, chronologically
+func (t *AppDynamicsSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return results, err
+	}
+
+	params := url.Values{}
+	if start := q.GetStart(); start != nil {
+		params.Set("start", strconv.FormatInt(start.UnixMilli(), 10))
+	}
+	if end := q.GetEnd(); end != nil {
+		params.Set("end", strconv.FormatInt(end.UnixMilli(), 10))
+	}
+	if q.Limit > 0 && q.Limit < maxLimit {
+		params.Set("limit", strconv.FormatInt(q.Limit, 10))
+	} else if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(maxLimit))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.client.URL("/events/query", params), strings.NewReader(query))
+	if err != nil {
+		return results, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.appd.events+text;v=2")
+	req.Header.Set("Accept", "application/vnd.appd.events+json;v=2")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return results, fmt.Errorf("error querying the events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var res []events
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&res); err != nil {
+		return results, fmt.Errorf("error decoding the events: %w", err)
+	}
+
+	for _, e := range res {
+		for _, values := range e.Results {
+			event := make(map[string]any, len(e.Fields))
+			for i, f := range e.Fields {
+				if i < len(values) {
+					event[f.Field] = values[i]
+				}
+			}
+			results.Results = append(results.Results, t.result(event))
+		}
+	}
+	sort.SliceStable(results.Results, func(i, j int) bool { return results.Results[i].Time < results.Results[j].Time })
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// segment is the part of a transaction executed by a tier
+type segment struct {
+	Tier      string   `json:"tier"`
+	Node      string   `json:"node"`
+	ErrorList []string `json:"errorList"`
+}
+
+// result returns the result of the transaction, with its name, experience and errors as message,
+// its scalar fields as labels and the tier and node of its first segment as the tier and node labels
+func (t *AppDynamicsSearch) result(event map[string]any) logs.Result {
+	r := logs.Result{Labels: make(map[string]string, len(event)+len(t.config.Labels)+2)}
+	for field, value := range event {
+		var v string
+		switch value := value.(type) {
+		case string:
+			v = value
+		case json.Number:
+			v = value.String()
+		case bool:
+			v = strconv.FormatBool(value)
+		default:
+			continue
+		}
+
+		switch field {
+		case "eventTimestamp":
+			if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				r.Time = ts.UTC().Format(time.RFC3339Nano)
+			} else if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+				r.Time = time.UnixMilli(ms).UTC().Format(time.RFC3339Nano)
+			}
+		case "requestGUID":
+			r.Id = v
+		default:
+			r.Labels[field] = v
+		}
+	}
+
+	var segments []segment
+	if data, err := json.Marshal(event["segments"]); err == nil {
+		_ = json.Unmarshal(data, &segments)
+	}
+	var errors []string
+	for i, s := range segments {
+		if i == 0 {
+			if s.Tier != "" {
+				r.Labels["tier"] = s.Tier
+			}
+			if s.Node != "" {
+				r.Labels["node"] = s.Node
+			}
+		}
+		errors = append(errors, s.ErrorList...)
+	}
+
+	r.Message = strings.TrimSpace(r.Labels["transactionName"] + " " + r.Labels["userExperience"])
+	if len(errors) > 0 {
+		r.Message += ": " + strings.Join(errors, ", ")
+	}
+
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *AppDynamicsSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the AppDynamics backend, the query of the search is an ADQL condition unless the template doesn't use it
+func (t *AppDynamicsSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: t.config.Query == "" || strings.Contains(t.config.Query, ".Query")}
+}
@@ -0,0 +1,100 @@
+package appdynamics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const eventsResponse = `[{
+	"label": "0",
+	"fields": [
+		{"label": "eventTimestamp", "field": "eventTimestamp", "type": "date"},
+		{"label": "requestGUID", "field": "requestGUID", "type": "string"},
+		{"label": "application", "field": "application", "type": "string"},
+		{"label": "transactionName", "field": "transactionName", "type": "string"},
+		{"label": "userExperience", "field": "userExperience", "type": "string"},
+		{"label": "responseTime", "field": "responseTime", "type": "integer"},
+		{"label": "segments", "field": "segments", "type": "object"}
+	],
+	"results": [
+		["2023-05-01T12:00:02.000Z", "g2", "shop", "/checkout", "ERROR", 1200,
+		 [{"tier": "web", "node": "web-1", "errorList": ["NullPointerException"]}, {"tier": "payments", "errorList": ["HTTP 500"]}]],
+		["2023-05-01T12:00:01.000Z", "g1", "shop", "/cart", "ERROR", 80, null]
+	],
+	"moreData": false,
+	"total": 2
+}]`
+
+func TestAppDynamicsSearch(t *testing.T) {
+	var query url.Values
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Events-API-AccountName") != "acme_abc" || r.Header.Get("X-Events-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/events/query" || r.Header.Get("Content-Type") != "application/vnd.appd.events+text;v=2" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query = r.URL.Query()
+		data, _ := io.ReadAll(r.Body)
+		body = string(data)
+		_, _ = w.Write([]byte(eventsResponse))
+	}))
+	defer server.Close()
+
+	backend, err := NewAppDynamicsSearchBackend(nil, &logs.AppDynamicsBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "appdynamics"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		Account:        "acme_abc",
+		APIKey:         &kommons.EnvVar{Value: "secret"},
+		Experiences:    []string{"ERROR", "STALL"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Id: "shop", Query: "responseTime > 50", Labels: map[string]string{"tier": "web"},
+		Start: "2023-05-01T12:00:00Z", End: "2023-05-01T13:00:00Z", Limit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Id: "g1", Time: "2023-05-01T12:00:01Z", Message: "/cart ERROR", Labels: map[string]string{
+			"application": "shop", "transactionName": "/cart", "userExperience": "ERROR", "responseTime": "80", "source": "appdynamics",
+		}},
+		{Id: "g2", Time: "2023-05-01T12:00:02Z", Message: "/checkout ERROR: NullPointerException, HTTP 500", Labels: map[string]string{
+			"application": "shop", "transactionName": "/checkout", "userExperience": "ERROR", "responseTime": "1200",
+			"tier": "web", "node": "web-1", "source": "appdynamics",
+		}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantBody := "SELECT * FROM transactions WHERE userExperience IN ('ERROR', 'STALL') AND application = 'shop' AND segments.tier = 'web' AND (responseTime > 50)"
+	if body != wantBody {
+		t.Errorf("body = %s, want %s", body, wantBody)
+	}
+	wantQuery := url.Values{"start": {"1682942400000"}, "end": {"1682946000000"}, "limit": {"10"}}
+	if !reflect.DeepEqual(query, wantQuery) {
+		t.Errorf("query = %v, want %v", query, wantQuery)
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got, want := quote(`O'Brien\`), `'O\'Brien\\'`; got != want {
+		t.Errorf("quote() = %s, want %s", got, want)
+	}
+}
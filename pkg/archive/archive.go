@@ -0,0 +1,439 @@
+// Package archive searches the log objects of the buckets, e.g. the cold archives of the logs after their retention expired,
+// grepping their lines.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+	"github.com/flanksource/commons/utils"
+	"github.com/jeremywohl/flatten"
+)
+
+// maxLineSize is the longest line read from the objects, the longer lines are split
+const maxLineSize = 1024 * 1024
+
+var errLimitReached = errors.New("limit reached")
+
+// Object of a bucket
+type Object struct {
+	Key      string
+	Modified time.Time
+}
+
+// Bucket lists and reads the objects of a bucket
+type Bucket interface {
+	// Name of the bucket, attached to the results as the bucket label
+	Name() string
+	// List passes the objects of the keys with the prefix to fn, until it returns an error
+	List(ctx context.Context, prefix string, fn func(o Object) error) error
+	// Open reads the object
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+const (
+	formatText = "text"
+	formatJSON = "json"
+
+	defaultTimestampField = "timestamp"
+	defaultMessageField   = "message"
+)
+
+// ArchiveSearch searches the objects of a bucket
+type ArchiveSearch struct {
+	common  logs.CommonBackend
+	objects logs.ArchiveObjects
+	bucket  Bucket
+	prefix  *template.Template
+	keyTime *keyTime
+}
+
+func NewArchiveSearch(common logs.CommonBackend, objects logs.ArchiveObjects, bucket Bucket) (*ArchiveSearch, error) {
+	switch objects.Format {
+	case "", formatText, formatJSON:
+	default:
+		return nil, fmt.Errorf("unknown format %s, expected text or json", objects.Format)
+	}
+	prefix, err := template.New("prefix").Parse(objects.Prefix)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the prefix template: %w", err)
+	}
+
+	t := &ArchiveSearch{common: common, objects: objects, bucket: bucket, prefix: prefix}
+	if objects.KeyTimeFormat != "" {
+		keyTime, err := newKeyTime(objects.KeyTimeFormat)
+		if err != nil {
+			return nil, err
+		}
+		t.keyTime = keyTime
+	}
+	return t, nil
+}
+
+// Search returns the lines matching the query, from the most recent objects
+func (t *ArchiveSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var res logs.SearchResults
+	err := t.search(ctx, q, func(r logs.Result) error {
+		res.Results = append(res.Results, r)
+		return nil
+	})
+	res.Total = len(res.Results)
+	return res, err
+}
+
+// SearchStream implements logs.SearchStreamer, sending the lines of the objects as they're read
+func (t *ArchiveSearch) SearchStream(ctx context.Context, q *logs.SearchParams, results chan<- logs.Result) error {
+	return t.search(ctx, q, func(r logs.Result) error {
+		select {
+		case results <- r:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+func (t *ArchiveSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.common.Routes.MatchRoute(q)
+}
+
+func (t *ArchiveSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Streaming: true}
+}
+
+// HealthCheck lists the first object of the bucket, with the prefix up to its first template action
+func (t *ArchiveSearch) HealthCheck(ctx context.Context) error {
+	prefix, _, _ := strings.Cut(t.objects.Prefix, "{{")
+	err := t.bucket.List(ctx, prefix, func(o Object) error {
+		return errLimitReached
+	})
+	if errors.Is(err, errLimitReached) {
+		return nil
+	}
+	return err
+}
+
+// search passes the lines matching the query to fn, until the limit of the search is reached
+func (t *ArchiveSearch) search(ctx context.Context, q *logs.SearchParams, fn func(r logs.Result) error) error {
+	var prefix bytes.Buffer
+	if err := t.prefix.Execute(&prefix, q); err != nil {
+		return fmt.Errorf("error executing the prefix template: %w", err)
+	}
+
+	start, end := q.GetStart(), q.GetEnd()
+	objects, err := t.list(ctx, prefix.String(), start, end)
+	if err != nil {
+		return err
+	}
+
+	query := strings.ToLower(q.Query)
+	var sent int64
+	for _, o := range objects {
+		err := t.read(ctx, o, func(r logs.Result, ts *time.Time) error {
+			if query != "" && !strings.Contains(strings.ToLower(r.Message), query) {
+				return nil
+			}
+			if ts != nil && ((start != nil && ts.Before(*start)) || (end != nil && ts.After(*end))) {
+				return nil
+			}
+			if err := fn(r); err != nil {
+				return err
+			}
+			if sent++; q.Limit > 0 && sent >= q.Limit {
+				return errLimitReached
+			}
+			return nil
+		})
+		if errors.Is(err, errLimitReached) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type object struct {
+	Object
+	time time.Time
+}
+
+// list returns the objects that can hold logs of the time range, the most recent first
+func (t *ArchiveSearch) list(ctx context.Context, prefix string, start, end *time.Time) ([]object, error) {
+	prefixes := []string{prefix}
+	if t.objects.KeyTimePrefix && t.keyTime != nil && start != nil {
+		to := time.Now()
+		if end != nil {
+			to = *end
+		}
+		if days, ok := t.keyTime.prefixes(*start, to); ok {
+			prefixes = prefixes[:0]
+			for _, day := range days {
+				prefixes = append(prefixes, prefix+day)
+			}
+		}
+	}
+
+	var objects []object
+	fn := func(o Object) error {
+		// The logs of an object were written before it was last modified
+		if start != nil && !o.Modified.IsZero() && o.Modified.Before(*start) {
+			return nil
+		}
+
+		ts := o.Modified
+		if t.keyTime != nil {
+			from, to, ok := t.keyTime.parse(strings.TrimPrefix(o.Key, prefix))
+			if ok {
+				if (start != nil && !to.After(*start)) || (end != nil && from.After(*end)) {
+					return nil
+				}
+				ts = from
+			}
+		}
+
+		objects = append(objects, object{Object: o, time: ts})
+		return nil
+	}
+	for _, prefix := range prefixes {
+		if err := t.bucket.List(ctx, prefix, fn); err != nil {
+			return nil, fmt.Errorf("error listing the objects of %s: %w", t.bucket.Name(), err)
+		}
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		return objects[i].time.After(objects[j].time)
+	})
+	return objects, nil
+}
+
+// read passes each line of the object to fn until it returns an error, with the time of the json objects
+// or nil when the line has no time. The gzipped objects are decompressed.
+// The time of the results is the time of the object, unless they have their own time.
+func (t *ArchiveSearch) read(ctx context.Context, o object, fn func(r logs.Result, ts *time.Time) error) error {
+	body, err := t.bucket.Open(ctx, o.Key)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", o.Key, err)
+	}
+	defer body.Close()
+
+	reader := bufio.NewReader(body)
+	var content io.Reader = reader
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("error decompressing %s: %w", o.Key, err)
+		}
+		defer gz.Close()
+		content = gz
+	}
+
+	// All lines of the same object will share these labels
+	labels := make(map[string]string, len(t.common.Labels)+2)
+	labels["bucket"] = t.bucket.Name()
+	labels["key"] = o.Key
+	for k, v := range t.common.Labels {
+		labels[k] = v
+	}
+
+	objectTime := o.time.UTC().Format(time.RFC3339)
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		message := strings.TrimSpace(scanner.Text())
+		if message == "" {
+			continue
+		}
+		r := logs.Result{
+			Id:      o.Key + ":" + strconv.Itoa(line),
+			Time:    objectTime,
+			Labels:  labels,
+			Message: message,
+		}
+		var ts *time.Time
+		if t.objects.Format == formatJSON {
+			ts = t.parseJSON(&r)
+		}
+		if err := fn(r, ts); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading %s: %w", o.Key, err)
+	}
+	return nil
+}
+
+// parseJSON sets the message, time and labels of the result from the fields of the json object of its message,
+// and returns its time, nil when it has none. The lines that aren't json objects are left as is.
+func (t *ArchiveSearch) parseJSON(r *logs.Result) (ts *time.Time) {
+	var object map[string]any
+	if err := json.Unmarshal([]byte(r.Message), &object); err != nil {
+		return nil
+	}
+	fields, err := flatten.Flatten(object, "", flatten.DotStyle)
+	if err != nil {
+		return nil
+	}
+
+	timestampField := t.objects.Fields.Timestamp
+	if timestampField == "" {
+		timestampField = defaultTimestampField
+	}
+	messageField := t.objects.Fields.Message
+	if messageField == "" {
+		messageField = defaultMessageField
+	}
+
+	labels := make(map[string]string, len(fields)+len(r.Labels))
+	for k, v := range r.Labels {
+		labels[k] = v
+	}
+	for field, value := range fields {
+		if value == nil || collections.Contains(t.objects.Fields.Exclusions, field) {
+			continue
+		}
+		v, err := utils.Stringify(value)
+		if err != nil {
+			continue
+		}
+
+		switch field {
+		case timestampField:
+			if parsed, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				ts = &parsed
+				r.Time = parsed.UTC().Format(time.RFC3339Nano)
+			}
+		case messageField:
+			r.Message = v
+		default:
+			if _, ok := t.common.Labels[field]; !ok {
+				labels[field] = v
+			}
+		}
+	}
+	r.Labels = labels
+	return ts
+}
+
+// maxPrefixes is the most days listed by their prefixes, the wider searches list every object of the prefix
+const maxPrefixes = 366
+
+// keyTime parses the time encoded in the keys of the objects
+type keyTime struct {
+	layout  string
+	pattern *regexp.Regexp
+	// period of the objects, e.g. a day when the time of the keys has no hour
+	period func(t time.Time) time.Time
+
+	// dayLayout is the layout up to the day, or the month or year when the layout has no day,
+	// and dayPeriod its period
+	dayLayout string
+	dayPeriod func(t time.Time) time.Time
+}
+
+// layoutElements of the reference time, from the most to the least significant
+var layoutElements = []struct {
+	element string
+	pattern string
+	period  func(t time.Time) time.Time
+}{
+	{"2006", `\d{4}`, func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	{"01", `\d{2}`, func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	{"02", `\d{2}`, func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"15", `\d{2}`, func(t time.Time) time.Time { return t.Add(time.Hour) }},
+	{"04", `\d{2}`, func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{"05", `\d{2}`, func(t time.Time) time.Time { return t.Add(time.Second) }},
+}
+
+func newKeyTime(layout string) (*keyTime, error) {
+	var pattern strings.Builder
+	significance, daySignificance, dayEnd := -1, -1, 0
+	for i := 0; i < len(layout); {
+		found := false
+		for j, e := range layoutElements {
+			if strings.HasPrefix(layout[i:], e.element) {
+				pattern.WriteString(e.pattern)
+				if j > significance {
+					significance = j
+				}
+				i += len(e.element)
+				if j <= 2 && j >= daySignificance {
+					daySignificance, dayEnd = j, i
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			pattern.WriteString(regexp.QuoteMeta(layout[i : i+1]))
+			i++
+		}
+	}
+	if significance < 0 {
+		return nil, fmt.Errorf("the key time format %s has no year, month, day, hour, minute or second", layout)
+	}
+
+	t := &keyTime{
+		layout:  layout,
+		pattern: regexp.MustCompile(pattern.String()),
+		period:  layoutElements[significance].period,
+	}
+	if daySignificance >= 0 {
+		t.dayLayout = layout[:dayEnd]
+		t.dayPeriod = layoutElements[daySignificance].period
+	}
+	return t, nil
+}
+
+// prefixes returns the prefixes of the keys of the days between start and end,
+// false when there are too many or the layout has no date
+func (t *keyTime) prefixes(start, end time.Time) ([]string, bool) {
+	if t.dayLayout == "" {
+		return nil, false
+	}
+	day, err := time.Parse(t.dayLayout, start.UTC().Format(t.dayLayout))
+	if err != nil {
+		return nil, false
+	}
+
+	var prefixes []string
+	for ; !day.After(end); day = t.dayPeriod(day) {
+		if len(prefixes) == maxPrefixes {
+			return nil, false
+		}
+		prefixes = append(prefixes, day.Format(t.dayLayout))
+	}
+	return prefixes, true
+}
+
+// parse returns the period of the time encoded in the key
+func (t *keyTime) parse(key string) (from, to time.Time, ok bool) {
+	match := t.pattern.FindString(key)
+	if match == "" {
+		return from, to, false
+	}
+	from, err := time.Parse(t.layout, match)
+	if err != nil {
+		return from, to, false
+	}
+	return from, t.period(from), true
+}
@@ -0,0 +1,171 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// memoryBucket holds the objects in memory, recording the objects read
+type memoryBucket struct {
+	objects  map[string]memoryObject
+	openKeys []string
+}
+
+type memoryObject struct {
+	modified time.Time
+	content  []byte
+}
+
+func (t *memoryBucket) Name() string {
+	return "archive"
+}
+
+func (t *memoryBucket) List(ctx context.Context, prefix string, fn func(o Object) error) error {
+	keys := make([]string, 0, len(t.objects))
+	for key := range t.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(Object{Key: key, Modified: t.objects[key].modified}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *memoryBucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	o, ok := t.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("%s not found", key)
+	}
+	t.openKeys = append(t.openKeys, key)
+	return io.NopCloser(bytes.NewReader(o.content)), nil
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func parseTime(t *testing.T, value string) time.Time {
+	ts, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestArchiveSearch(t *testing.T) {
+	bucket := &memoryBucket{objects: map[string]memoryObject{
+		"app/2023/04/30/api.log.gz": {modified: parseTime(t, "2023-05-01T00:05:00Z"), content: gzipped(t, "connection refused\nGET /\n")},
+		"app/2023/05/01/api.log.gz": {modified: parseTime(t, "2023-05-02T00:05:00Z"), content: gzipped(t, "GET /health\nconnection reset\n\nConnection timeout\n")},
+		"app/2023/05/02/api.log":    {modified: parseTime(t, "2023-05-02T12:00:00Z"), content: []byte("connection closed\n")},
+		"app/README":                {modified: parseTime(t, "2023-05-01T12:00:00Z"), content: []byte("connections of the api\n")},
+	}}
+	backend, err := NewArchiveSearch(logs.CommonBackend{Labels: map[string]string{"source": "archive"}}, logs.ArchiveObjects{Prefix: "app/", KeyTimeFormat: "2006/01/02"}, bucket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := func(key string) map[string]string {
+		return map[string]string{"bucket": "archive", "key": key, "source": "archive"}
+	}
+	tests := []struct {
+		name      string
+		q         logs.SearchParams
+		want      []logs.Result
+		wantOpens []string
+	}{
+		{
+			name: "time range",
+			q:    logs.SearchParams{Query: "connection", Start: "2023-05-01T10:00:00Z", End: "2023-05-01T20:00:00Z"},
+			want: []logs.Result{
+				{Id: "app/README:1", Time: "2023-05-01T12:00:00Z", Message: "connections of the api", Labels: labels("app/README")},
+				{Id: "app/2023/05/01/api.log.gz:2", Time: "2023-05-01T00:00:00Z", Message: "connection reset", Labels: labels("app/2023/05/01/api.log.gz")},
+				{Id: "app/2023/05/01/api.log.gz:4", Time: "2023-05-01T00:00:00Z", Message: "Connection timeout", Labels: labels("app/2023/05/01/api.log.gz")},
+			},
+			wantOpens: []string{"app/README", "app/2023/05/01/api.log.gz"},
+		},
+		{
+			name: "limit from the most recent objects",
+			q:    logs.SearchParams{Query: "connection", Start: "2023-04-01T00:00:00Z", Limit: 2},
+			want: []logs.Result{
+				{Id: "app/2023/05/02/api.log:1", Time: "2023-05-02T00:00:00Z", Message: "connection closed", Labels: labels("app/2023/05/02/api.log")},
+				{Id: "app/README:1", Time: "2023-05-01T12:00:00Z", Message: "connections of the api", Labels: labels("app/README")},
+			},
+			wantOpens: []string{"app/2023/05/02/api.log", "app/README"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bucket.openKeys = nil
+			res, err := backend.Search(context.Background(), &tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := logs.SearchResults{Total: len(tt.want), Results: tt.want}
+			if !reflect.DeepEqual(res, want) {
+				t.Errorf("Search() = %+v, want %+v", res, want)
+			}
+			if !reflect.DeepEqual(bucket.openKeys, tt.wantOpens) {
+				t.Errorf("objects read = %v, want %v", bucket.openKeys, tt.wantOpens)
+			}
+		})
+	}
+}
+
+func TestKeyTime(t *testing.T) {
+	tests := []struct {
+		layout   string
+		key      string
+		wantFrom string
+		wantTo   string
+	}{
+		{layout: "2006/01/02", key: "AWSLogs/123/elasticloadbalancing/us-east-1/2023/05/01/alb.log.gz", wantFrom: "2023-05-01T00:00:00Z", wantTo: "2023-05-02T00:00:00Z"},
+		{layout: "dt=2006-01-02/hour=15", key: "logs/dt=2023-05-01/hour=23/part-0.json", wantFrom: "2023-05-01T23:00:00Z", wantTo: "2023-05-02T00:00:00Z"},
+		{layout: "2006-01", key: "monthly/2023-12.log", wantFrom: "2023-12-01T00:00:00Z", wantTo: "2024-01-01T00:00:00Z"},
+		{layout: "2006/01/02", key: "latest.log"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			keyTime, err := newKeyTime(tt.layout)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, to, ok := keyTime.parse(tt.key)
+			if ok != (tt.wantFrom != "") {
+				t.Fatalf("parse() ok = %v", ok)
+			}
+			if !ok {
+				return
+			}
+			if got := from.Format(time.RFC3339); got != tt.wantFrom {
+				t.Errorf("parse() from = %s, want %s", got, tt.wantFrom)
+			}
+			if got := to.Format(time.RFC3339); got != tt.wantTo {
+				t.Errorf("parse() to = %s, want %s", got, tt.wantTo)
+			}
+		})
+	}
+
+	if _, err := newKeyTime("logs"); err == nil {
+		t.Errorf("newKeyTime() of a layout without time succeeded")
+	}
+}
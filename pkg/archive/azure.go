@@ -0,0 +1,141 @@
+package archive
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/azure"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const (
+	storageResource = "https://storage.azure.com/"
+
+	// storageVersion of the Blob service api, the bearer tokens require 2017-11-09 or later
+	storageVersion = "2021-08-06"
+)
+
+// AzureBlobBucket reads the blobs of an Azure Blob Storage container
+type AzureBlobBucket struct {
+	client    *rest.Client
+	container string
+	// sas is the query of the shared access signature
+	sas    url.Values
+	tokens *azure.TokenSource
+}
+
+func NewAzureBlobArchiveSearchBackend(kClient *kommons.Client, config *logs.AzureBlobArchiveBackendConfig) (*ArchiveSearch, error) {
+	if config.Container == "" {
+		return nil, fmt.Errorf("the container is required")
+	}
+	conn := logs.HTTPConnection{URL: config.URL, Namespace: config.Namespace}
+	if conn.URL == "" {
+		if config.Account == "" {
+			return nil, fmt.Errorf("either the account or the url is required")
+		}
+		conn.URL = "https://" + config.Account + ".blob.core.windows.net"
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	bucket := &AzureBlobBucket{client: client, container: config.Container}
+	switch {
+	case config.SASToken != nil:
+		_, token, err := kClient.GetEnvValue(*config.SASToken, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the sas token: %w", err)
+		}
+		if bucket.sas, err = url.ParseQuery(strings.TrimPrefix(token, "?")); err != nil {
+			return nil, fmt.Errorf("error parsing the sas token: %w", err)
+		}
+	case config.Auth != nil:
+		if bucket.tokens, err = azure.NewTokenSource(kClient, config.Namespace, *config.Auth, storageResource+".default"); err != nil {
+			return nil, err
+		}
+	default:
+		bucket.tokens = azure.NewManagedIdentityTokenSource(storageResource, config.ManagedIdentityClientID)
+	}
+
+	return NewArchiveSearch(config.CommonBackend, config.ArchiveObjects, bucket)
+}
+
+func (t *AzureBlobBucket) Name() string {
+	return t.container
+}
+
+type enumerationResults struct {
+	Blobs []struct {
+		Name       string `xml:"Name"`
+		Properties struct {
+			LastModified string `xml:"Last-Modified"`
+		} `xml:"Properties"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+func (t *AzureBlobBucket) List(ctx context.Context, prefix string, fn func(o Object) error) error {
+	query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {prefix}}
+	for {
+		resp, err := t.do(ctx, "/"+url.PathEscape(t.container), query)
+		if err != nil {
+			return err
+		}
+		var page enumerationResults
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("error decoding the blobs: %w", err)
+		}
+
+		for _, blob := range page.Blobs {
+			modified, _ := time.Parse(time.RFC1123, blob.Properties.LastModified)
+			if err := fn(Object{Key: blob.Name, Modified: modified}); err != nil {
+				return err
+			}
+		}
+		if page.NextMarker == "" {
+			return nil
+		}
+		query.Set("marker", page.NextMarker)
+	}
+}
+
+func (t *AzureBlobBucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	resp, err := t.do(ctx, "/"+url.PathEscape(t.container)+"/"+strings.Join(segments, "/"), url.Values{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// do sends the request with the shared access signature, or the token of the identity
+func (t *AzureBlobBucket) do(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	for k, v := range t.sas {
+		query[k] = v
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL(path, query), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-version", storageVersion)
+	if t.tokens != nil {
+		if err := t.tokens.Authorize(req); err != nil {
+			return nil, err
+		}
+	}
+	return t.client.Do(req)
+}
@@ -0,0 +1,79 @@
+package archive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestAzureBlobArchiveSearch(t *testing.T) {
+	var prefixes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("sig") != "secret" || r.URL.Query().Get("sv") != "2021-08-06" {
+			t.Errorf("the request %s isn't signed", r.URL)
+		}
+
+		switch r.URL.EscapedPath() {
+		case "/$logs":
+			prefix := r.URL.Query().Get("prefix")
+			prefixes = append(prefixes, prefix)
+			w.Header().Set("Content-Type", "application/xml")
+			switch {
+			case prefix == "blob/2023/05/01" && r.URL.Query().Get("marker") == "":
+				_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>
+					<Blob><Name>blob/2023/05/01/1000/000000.log</Name><Properties><Last-Modified>Mon, 01 May 2023 11:00:00 GMT</Last-Modified></Properties></Blob>
+				</Blobs><NextMarker>2</NextMarker></EnumerationResults>`))
+			case prefix == "blob/2023/05/01":
+				_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs>
+					<Blob><Name>blob/2023/05/01/1200/000000.log</Name><Properties><Last-Modified>Mon, 01 May 2023 13:00:00 GMT</Last-Modified></Properties></Blob>
+				</Blobs><NextMarker/></EnumerationResults>`))
+			default:
+				_, _ = w.Write([]byte(`<?xml version="1.0" encoding="utf-8"?><EnumerationResults><Blobs/><NextMarker/></EnumerationResults>`))
+			}
+		case "/$logs/blob/2023/05/01/1200/000000.log":
+			_, _ = w.Write([]byte("1.0;2023-05-01T12:00:01.0000000Z;GetBlob;Success;200\n1.0;2023-05-01T12:00:02.0000000Z;GetBlob;ClientOtherError;404\n"))
+		default:
+			t.Errorf("unexpected request %s", r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewAzureBlobArchiveSearchBackend(nil, &logs.AzureBlobArchiveBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "azure"}},
+		ArchiveObjects: logs.ArchiveObjects{Prefix: "blob/", KeyTimeFormat: "2006/01/02/1504", KeyTimePrefix: true},
+		URL:            server.URL,
+		Container:      "$logs",
+		SASToken:       &kommons.EnvVar{Value: "?sv=2021-08-06&sig=secret"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "404", Start: "2023-05-01T11:30:00Z", End: "2023-05-02T01:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "blob/2023/05/01/1200/000000.log"
+	want := logs.SearchResults{Total: 1, Results: []logs.Result{
+		{
+			Id:      key + ":2",
+			Time:    "2023-05-01T12:00:00Z",
+			Message: "1.0;2023-05-01T12:00:02.0000000Z;GetBlob;ClientOtherError;404",
+			Labels:  map[string]string{"bucket": "$logs", "key": key, "source": "azure"},
+		},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantPrefixes := []string{"blob/2023/05/01", "blob/2023/05/01", "blob/2023/05/02"}
+	if !reflect.DeepEqual(prefixes, wantPrefixes) {
+		t.Errorf("listed prefixes = %v, want %v", prefixes, wantPrefixes)
+	}
+}
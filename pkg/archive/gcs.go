@@ -0,0 +1,103 @@
+package archive
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/gcp"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+	"golang.org/x/oauth2"
+)
+
+const (
+	defaultGCSURL = "https://storage.googleapis.com"
+
+	gcsScope = "https://www.googleapis.com/auth/devstorage.read_only"
+)
+
+// GCSBucket reads the objects of a Google Cloud Storage bucket with the json api
+type GCSBucket struct {
+	client *rest.Client
+	tokens oauth2.TokenSource
+	bucket string
+}
+
+func NewGCSArchiveSearchBackend(kClient *kommons.Client, config *logs.GCSArchiveBackendConfig) (*ArchiveSearch, error) {
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultGCSURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := gcp.NewTokenSource(kClient, conn.Namespace, config.Credentials, gcsScope)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewArchiveSearch(config.CommonBackend, config.ArchiveObjects, &GCSBucket{client: client, tokens: tokens, bucket: config.Bucket})
+}
+
+func (t *GCSBucket) Name() string {
+	return t.bucket
+}
+
+func (t *GCSBucket) List(ctx context.Context, prefix string, fn func(o Object) error) error {
+	query := url.Values{"prefix": {prefix}, "fields": {"items(name,updated),nextPageToken"}}
+	for {
+		var page struct {
+			Items []struct {
+				Name    string    `json:"name"`
+				Updated time.Time `json:"updated"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		req, err := t.request(ctx, "/storage/v1/b/"+url.PathEscape(t.bucket)+"/o", query)
+		if err != nil {
+			return err
+		}
+		if err := t.client.DoJSON(req, &page); err != nil {
+			return err
+		}
+
+		for _, item := range page.Items {
+			if err := fn(Object{Key: item.Name, Modified: item.Updated}); err != nil {
+				return err
+			}
+		}
+		if page.NextPageToken == "" {
+			return nil
+		}
+		query.Set("pageToken", page.NextPageToken)
+	}
+}
+
+func (t *GCSBucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := t.request(ctx, "/storage/v1/b/"+url.PathEscape(t.bucket)+"/o/"+url.PathEscape(key), url.Values{"alt": {"media"}})
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// request returns the request of the api, with the access token of the credentials
+func (t *GCSBucket) request(ctx context.Context, path string, query url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL(path, query), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := gcp.Authorize(req, t.tokens); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
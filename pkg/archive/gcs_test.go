@@ -0,0 +1,113 @@
+package archive
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func serviceAccount(t *testing.T, tokenURL string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "acme",
+		"private_key_id": "1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"client_email":   "apm-hub@acme.iam.gserviceaccount.com",
+		"token_uri":      tokenURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestGCSArchiveSearch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`))
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Authorization = %s", r.Header.Get("Authorization"))
+		}
+
+		switch r.URL.EscapedPath() {
+		case "/storage/v1/b/archive/o":
+			if r.URL.Query().Get("prefix") != "pods/api-0/" {
+				t.Errorf("prefix = %s, want pods/api-0/", r.URL.Query().Get("prefix"))
+			}
+			if r.URL.Query().Get("pageToken") == "" {
+				_, _ = w.Write([]byte(`{"items": [{"name": "pods/api-0/dt=2023-05-01/0.json", "updated": "2023-05-02T00:00:00Z"}], "nextPageToken": "2"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"items": [{"name": "pods/api-0/dt=2023-04-30/0.json", "updated": "2023-05-01T00:00:00Z"}]}`))
+		case "/storage/v1/b/archive/o/pods%2Fapi-0%2Fdt=2023-05-01%2F0.json":
+			if r.URL.Query().Get("alt") != "media" {
+				t.Errorf("alt = %s, want media", r.URL.Query().Get("alt"))
+			}
+			_, _ = w.Write(gzipped(t, `{"timestamp": "2023-05-01T09:00:00Z", "jsonPayload": {"message": "connection refused"}, "severity": "ERROR"}
+{"timestamp": "2023-05-01T11:00:00.5Z", "jsonPayload": {"message": "connection reset", "code": 104}, "severity": "ERROR", "insertId": "a1"}
+{"timestamp": "2023-05-01T11:00:01Z", "jsonPayload": {"message": "GET /"}, "severity": "INFO"}
+not json connection
+`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.EscapedPath())
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewGCSArchiveSearchBackend(nil, &logs.GCSArchiveBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"source": "gcs"}},
+		ArchiveObjects: logs.ArchiveObjects{
+			Prefix:        "pods/{{.Id}}/",
+			KeyTimeFormat: "dt=2006-01-02",
+			Format:        "json",
+			Fields:        logs.ElasticSearchFields{Message: "jsonPayload.message", Exclusions: []string{"insertId"}},
+		},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		Credentials:    &kommons.EnvVar{Value: serviceAccount(t, server.URL+"/token")},
+		Bucket:         "archive",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Id: "api-0", Query: "connection", Start: "2023-05-01T10:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "pods/api-0/dt=2023-05-01/0.json"
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{
+			Id:      key + ":2",
+			Time:    "2023-05-01T11:00:00.5Z",
+			Message: "connection reset",
+			Labels:  map[string]string{"bucket": "archive", "key": key, "source": "gcs", "severity": "ERROR", "jsonPayload.code": "104"},
+		},
+		{
+			Id:      key + ":4",
+			Time:    "2023-05-01T00:00:00Z",
+			Message: "not json connection",
+			Labels:  map[string]string{"bucket": "archive", "key": key, "source": "gcs"},
+		},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+}
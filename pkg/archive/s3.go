@@ -0,0 +1,56 @@
+package archive
+
+import (
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// S3Bucket reads the objects of an S3 bucket
+type S3Bucket struct {
+	client *s3.Client
+	bucket string
+}
+
+// NewS3Bucket returns the bucket read with the client
+func NewS3Bucket(client *s3.Client, bucket string) *S3Bucket {
+	return &S3Bucket{client: client, bucket: bucket}
+}
+
+func NewS3ArchiveSearchBackend(config *logs.S3ArchiveBackendConfig, client *s3.Client) (*ArchiveSearch, error) {
+	return NewArchiveSearch(config.CommonBackend, config.ArchiveObjects, NewS3Bucket(client, config.Bucket))
+}
+
+func (t *S3Bucket) Name() string {
+	return t.bucket
+}
+
+func (t *S3Bucket) List(ctx context.Context, prefix string, fn func(o Object) error) error {
+	paginator := s3.NewListObjectsV2Paginator(t.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(t.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return err
+		}
+		for _, o := range page.Contents {
+			if err := fn(Object{Key: aws.ToString(o.Key), Modified: aws.ToTime(o.LastModified)}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *S3Bucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	res, err := t.client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(t.bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
@@ -0,0 +1,71 @@
+package archive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestS3ArchiveSearch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/archive":
+			if r.URL.Query().Get("prefix") != "app/" {
+				t.Errorf("prefix = %s, want app/", r.URL.Query().Get("prefix"))
+			}
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = w.Write([]byte(`<ListBucketResult>
+				<Name>archive</Name><Prefix>app/</Prefix><KeyCount>2</KeyCount><IsTruncated>false</IsTruncated>
+				<Contents><Key>app/2023/04/30/api.log.gz</Key><LastModified>2023-05-01T00:05:00.000Z</LastModified></Contents>
+				<Contents><Key>app/2023/05/01/api.log.gz</Key><LastModified>2023-05-02T00:05:00.000Z</LastModified></Contents>
+			</ListBucketResult>`))
+		case "/archive/app/2023/05/01/api.log.gz":
+			_, _ = w.Write(gzipped(t, "GET /health\nconnection reset\n"))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := s3.New(s3.Options{
+		Region:           "us-east-1",
+		Credentials:      aws.AnonymousCredentials{},
+		EndpointResolver: s3.EndpointResolverFromURL(server.URL),
+		UsePathStyle:     true,
+	})
+	backend, err := NewS3ArchiveSearchBackend(&logs.S3ArchiveBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "s3"}},
+		ArchiveObjects: logs.ArchiveObjects{Prefix: "app/", KeyTimeFormat: "2006/01/02"},
+		Bucket:         "archive",
+	}, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "connection", Start: "2023-05-01T10:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 1, Results: []logs.Result{
+		{
+			Id:      "app/2023/05/01/api.log.gz:2",
+			Time:    "2023-05-01T00:00:00Z",
+			Message: "connection reset",
+			Labels:  map[string]string{"bucket": "archive", "key": "app/2023/05/01/api.log.gz", "source": "s3"},
+		},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	if err := backend.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() = %v", err)
+	}
+}
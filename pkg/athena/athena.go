@@ -0,0 +1,290 @@
+// Package athena searches the logs stored in S3, e.g. the ALB, S3 access or CloudTrail logs, with the Athena queries
+package athena
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/aws/aws-sdk-go-v2/service/athena/types"
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+)
+
+const (
+	defaultTimestampColumn = "timestamp"
+	defaultMessageColumn   = "message"
+	defaultWorkGroup       = "primary"
+
+	// maxResults is the max number of rows of a page of the query results
+	maxResults = 1000
+)
+
+// pollInterval is the interval between the polls of the state of the queries
+var pollInterval = time.Second
+
+// timestampLayout of the timestamp columns returned by Athena
+const timestampLayout = "2006-01-02 15:04:05.999999999"
+
+func NewAthenaSearchBackend(config *logs.AthenaBackendConfig, client *athena.Client) (*athenaSearch, error) {
+	if config.Query == "" {
+		return nil, fmt.Errorf("the query is required")
+	}
+	template, err := template.New("query").Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the query template: %w", err)
+	}
+
+	timeout := 5 * time.Minute
+	if config.Timeout != "" {
+		d, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the query timeout: %w", err)
+		}
+		timeout = time.Duration(d)
+	}
+
+	return &athenaSearch{
+		client:   client,
+		config:   config,
+		template: template,
+		timeout:  timeout,
+	}, nil
+}
+
+type athenaSearch struct {
+	client   *athena.Client
+	config   *logs.AthenaBackendConfig
+	template *template.Template
+	timeout  time.Duration
+}
+
+func (t *athenaSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of Athena, the query of the search is applied when the query template uses it
+func (t *athenaSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: strings.Contains(t.config.Query, ".Query")}
+}
+
+// RenderQuery renders the query template for the given search params.
+func (t *athenaSearch) RenderQuery(q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing the query template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func (t *athenaSearch) workGroup() string {
+	if t.config.WorkGroup == "" {
+		return defaultWorkGroup
+	}
+	return t.config.WorkGroup
+}
+
+// Search runs the query, and waits for it to complete until the deadline of the search,
+// or the timeout of the backend when it's earlier. The rows are read up to the limit of the search.
+func (t *athenaSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	input := &athena.StartQueryExecutionInput{
+		QueryString: &query,
+		WorkGroup:   ptr(t.workGroup()),
+	}
+	if t.config.Catalog != "" || t.config.Database != "" {
+		input.QueryExecutionContext = &types.QueryExecutionContext{}
+		if t.config.Catalog != "" {
+			input.QueryExecutionContext.Catalog = &t.config.Catalog
+		}
+		if t.config.Database != "" {
+			input.QueryExecutionContext.Database = &t.config.Database
+		}
+	}
+	if t.config.OutputLocation != "" {
+		input.ResultConfiguration = &types.ResultConfiguration{OutputLocation: &t.config.OutputLocation}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	output, err := t.client.StartQueryExecution(ctx, input)
+	if err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error starting the query: %w", err)
+	}
+	if err := t.waitForQuery(ctx, output.QueryExecutionId); err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	var results logs.SearchResults
+	var columns []types.ColumnInfo
+	resultsInput := &athena.GetQueryResultsInput{QueryExecutionId: output.QueryExecutionId}
+	for first := true; ; first = false {
+		resultsInput.MaxResults = ptr(int32(maxResults))
+		if remaining := q.Limit - int64(len(results.Results)); q.Limit > 0 && remaining < maxResults {
+			// The header row is part of the first page
+			if first {
+				remaining++
+			}
+			resultsInput.MaxResults = ptr(int32(remaining))
+		}
+
+		page, err := t.client.GetQueryResults(ctx, resultsInput)
+		if err != nil {
+			return logs.SearchResults{}, fmt.Errorf("error getting the query results: %w", err)
+		}
+		if page.ResultSet == nil {
+			break
+		}
+		if first && page.ResultSet.ResultSetMetadata != nil {
+			columns = page.ResultSet.ResultSetMetadata.ColumnInfo
+		}
+
+		rows := page.ResultSet.Rows
+		if first && len(rows) > 0 && isHeader(columns, rows[0]) {
+			rows = rows[1:]
+		}
+		for _, row := range rows {
+			results.Results = append(results.Results, t.result(columns, row))
+		}
+
+		if page.NextToken == nil || (q.Limit > 0 && int64(len(results.Results)) >= q.Limit) {
+			break
+		}
+		resultsInput.NextToken = page.NextToken
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// waitForQuery waits for the query to succeed.
+// The query is stopped when the context is done before, e.g. when the deadline is exceeded.
+func (t *athenaSearch) waitForQuery(ctx context.Context, queryID *string) error {
+	input := &athena.GetQueryExecutionInput{QueryExecutionId: queryID}
+	for {
+		resp, err := t.client.GetQueryExecution(ctx, input)
+		if err != nil {
+			if ctx.Err() != nil {
+				return t.stopQuery(ctx, queryID)
+			}
+			return fmt.Errorf("error getting the query execution: %w", err)
+		}
+
+		var status types.QueryExecutionStatus
+		if resp.QueryExecution != nil && resp.QueryExecution.Status != nil {
+			status = *resp.QueryExecution.Status
+		}
+		switch status.State {
+		case types.QueryExecutionStateSucceeded:
+			return nil
+		case types.QueryExecutionStateFailed:
+			return fmt.Errorf("query failed: %s", deref(status.StateChangeReason))
+		case types.QueryExecutionStateCancelled:
+			return fmt.Errorf("query cancelled")
+		default:
+			// Might be queued or running.
+			// Wait before retrying.
+			select {
+			case <-time.After(pollInterval):
+			case <-ctx.Done():
+				return t.stopQuery(ctx, queryID)
+			}
+		}
+	}
+}
+
+// stopQuery stops the query once the context is done, as it keeps running (and scanning S3) in Athena otherwise,
+// and returns the error of the context
+func (t *athenaSearch) stopQuery(ctx context.Context, queryID *string) error {
+	_, _ = t.client.StopQueryExecution(context.Background(), &athena.StopQueryExecutionInput{QueryExecutionId: queryID})
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("the query didn't complete before the deadline: %w", ctx.Err())
+	}
+	return ctx.Err()
+}
+
+// isHeader returns whether the row is the header row of the results of a SELECT query, holding the names of the columns
+func isHeader(columns []types.ColumnInfo, row types.Row) bool {
+	if len(columns) == 0 || len(columns) != len(row.Data) {
+		return false
+	}
+	for i, column := range columns {
+		if deref(row.Data[i].VarCharValue) != deref(column.Name) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *athenaSearch) result(columns []types.ColumnInfo, row types.Row) logs.Result {
+	timestampColumn := t.config.TimestampColumn
+	if timestampColumn == "" {
+		timestampColumn = defaultTimestampColumn
+	}
+	messageColumn := t.config.MessageColumn
+	if messageColumn == "" {
+		messageColumn = defaultMessageColumn
+	}
+
+	r := logs.Result{Labels: make(map[string]string, len(columns)+len(t.config.Labels))}
+	for i, column := range columns {
+		if i >= len(row.Data) || row.Data[i].VarCharValue == nil {
+			continue
+		}
+		value := *row.Data[i].VarCharValue
+
+		switch deref(column.Name) {
+		case timestampColumn:
+			r.Time = toRFC3339(value)
+		case messageColumn:
+			r.Message = value
+		case t.config.IDColumn:
+			r.Id = value
+		default:
+			r.Labels[deref(column.Name)] = value
+		}
+	}
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+// toRFC3339 converts the timestamps returned by Athena, or the timestamps of the logs, e.g. of the ALB logs,
+// to the RFC3339 format. The other values are returned as is.
+func toRFC3339(value string) string {
+	for _, layout := range []string{timestampLayout, time.RFC3339Nano} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return value
+}
+
+// HealthCheck gets the work group running the queries
+func (t *athenaSearch) HealthCheck(ctx context.Context) error {
+	_, err := t.client.GetWorkGroup(ctx, &athena.GetWorkGroupInput{WorkGroup: ptr(t.workGroup())})
+	return err
+}
+
+func ptr[T any](val T) *T {
+	return &val
+}
+
+func deref[T any](val *T) T {
+	if val == nil {
+		var v T
+		return v
+	}
+
+	return *val
+}
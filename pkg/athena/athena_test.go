@@ -0,0 +1,168 @@
+package athena
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/athena"
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// fakeAthena answers the athena api, the query succeeding after the given number of polls.
+// The results are returned a row per page, after the header row.
+type fakeAthena struct {
+	polls int
+
+	lock    sync.Mutex
+	start   map[string]any
+	pages   int
+	stopped bool
+}
+
+func (t *fakeAthena) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+	columns := `"ResultSetMetadata":{"ColumnInfo":[{"Name":"time","Type":"varchar"},{"Name":"request_url","Type":"varchar"},{"Name":"elb_status_code","Type":"integer"}]}`
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "AmazonAthena.") {
+	case "StartQueryExecution":
+		t.start = body
+		_, _ = w.Write([]byte(`{"QueryExecutionId":"q1"}`))
+	case "GetQueryExecution":
+		if t.polls > 0 {
+			t.polls--
+			_, _ = w.Write([]byte(`{"QueryExecution":{"Status":{"State":"RUNNING"}}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"QueryExecution":{"Status":{"State":"SUCCEEDED"}}}`))
+	case "GetQueryResults":
+		t.pages++
+		switch body["NextToken"] {
+		case nil:
+			_, _ = w.Write([]byte(`{"NextToken":"2","ResultSet":{` + columns + `,"Rows":[
+				{"Data":[{"VarCharValue":"time"},{"VarCharValue":"request_url"},{"VarCharValue":"elb_status_code"}]},
+				{"Data":[{"VarCharValue":"2023-05-01T12:00:00.123456Z"},{"VarCharValue":"https://acme.com:443/api"},{"VarCharValue":"502"}]}
+			]}}`))
+		case "2":
+			_, _ = w.Write([]byte(`{"NextToken":"3","ResultSet":{` + columns + `,"Rows":[
+				{"Data":[{"VarCharValue":"2023-05-01T11:59:00Z"},{"VarCharValue":"https://acme.com:443/"},{}]}
+			]}}`))
+		default:
+			_, _ = w.Write([]byte(`{"ResultSet":{` + columns + `,"Rows":[
+				{"Data":[{"VarCharValue":"2023-05-01T11:58:00Z"},{"VarCharValue":"https://acme.com:443/login"},{"VarCharValue":"200"}]}
+			]}}`))
+		}
+	case "StopQueryExecution":
+		t.stopped = true
+		_, _ = w.Write([]byte(`{}`))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newTestBackend(t *testing.T, fake *fakeAthena, config *logs.AthenaBackendConfig) *athenaSearch {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := athena.New(athena.Options{
+		Region:           "us-east-1",
+		Credentials:      aws.AnonymousCredentials{},
+		EndpointResolver: athena.EndpointResolverFromURL(server.URL),
+	})
+	backend, err := NewAthenaSearchBackend(config, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestAthenaSearch_Search(t *testing.T) {
+	defer func() { pollInterval = time.Second }()
+	pollInterval = time.Millisecond
+
+	fake := &fakeAthena{polls: 2}
+	backend := newTestBackend(t, fake, &logs.AthenaBackendConfig{
+		CommonBackend:   logs.CommonBackend{Labels: map[string]string{"source": "alb"}},
+		Database:        "logs",
+		WorkGroup:       "apm-hub",
+		OutputLocation:  "s3://athena-results/apm-hub/",
+		Query:           "SELECT time, request_url, elb_status_code FROM alb_logs WHERE request_url LIKE '%{{.Query}}%' LIMIT {{.Limit}}",
+		TimestampColumn: "time",
+		MessageColumn:   "request_url",
+	})
+	if !backend.Capabilities().Query {
+		t.Errorf("Capabilities().Query = false, want true as the template uses the query")
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "acme", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	wantStart := map[string]any{
+		"QueryString":           "SELECT time, request_url, elb_status_code FROM alb_logs WHERE request_url LIKE '%acme%' LIMIT 2",
+		"QueryExecutionContext": map[string]any{"Database": "logs"},
+		"ResultConfiguration":   map[string]any{"OutputLocation": "s3://athena-results/apm-hub/"},
+		"WorkGroup":             "apm-hub",
+	}
+	delete(fake.start, "ClientRequestToken")
+	if !reflect.DeepEqual(fake.start, wantStart) {
+		t.Errorf("StartQueryExecution() = %v, want %v", fake.start, wantStart)
+	}
+	if fake.pages != 2 {
+		t.Errorf("%d pages of results were read, want 2 as the limit is reached", fake.pages)
+	}
+
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:00.123456Z", Message: "https://acme.com:443/api", Labels: map[string]string{"elb_status_code": "502", "source": "alb"}},
+		{Time: "2023-05-01T11:59:00Z", Message: "https://acme.com:443/", Labels: map[string]string{"source": "alb"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+}
+
+func TestAthenaSearch_SearchTimeout(t *testing.T) {
+	defer func() { pollInterval = time.Second }()
+	pollInterval = time.Millisecond
+
+	fake := &fakeAthena{polls: 1 << 30}
+	backend := newTestBackend(t, fake, &logs.AthenaBackendConfig{Query: "SELECT * FROM alb_logs", Timeout: "50ms"})
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{}); err == nil || !strings.Contains(err.Error(), "deadline") {
+		t.Errorf("Search() error = %v, want the deadline to be exceeded", err)
+	}
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if !fake.stopped {
+		t.Errorf("the query wasn't stopped")
+	}
+}
+
+func TestToRFC3339(t *testing.T) {
+	tests := map[string]string{
+		"2023-05-01 12:00:00.000":     "2023-05-01T12:00:00Z",
+		"2023-05-01 12:00:00.5":       "2023-05-01T12:00:00.5Z",
+		"2023-05-01T12:00:00.123456Z": "2023-05-01T12:00:00.123456Z",
+		"01/May/2023:12:00:00 +0000":  "01/May/2023:12:00:00 +0000",
+	}
+	for value, want := range tests {
+		if got := toRFC3339(value); got != want {
+			t.Errorf("toRFC3339(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
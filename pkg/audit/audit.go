@@ -0,0 +1,367 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	v8 "github.com/elastic/go-elasticsearch/v8"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/kommons"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const redacted = "[REDACTED]"
+
+// queueTimeout is how long a search waits for room in the queue when the sinks can't keep up
+const queueTimeout = 5 * time.Second
+
+var droppedEvents = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "apm_hub_audit_dropped_events_total",
+	Help: "The number of audit events dropped because the audit sinks couldn't keep up",
+})
+
+// Event is the audit record of a single search
+type Event struct {
+	Time     time.Time         `json:"time"`
+	User     string            `json:"user,omitempty"`
+	RemoteIP string            `json:"remoteIP,omitempty"`
+	Tenant   string            `json:"tenant,omitempty"`
+	Params   logs.SearchParams `json:"params"`
+	Backends []string          `json:"backends"`
+	Results  int               `json:"results"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// Sink persists the audit events
+type Sink interface {
+	Write(events []Event) error
+}
+
+// GlobalAuditor records the searches.
+// It's nil when auditing isn't configured.
+var GlobalAuditor *Auditor
+
+// Auditor redacts the events and writes them to the sinks in the background.
+type Auditor struct {
+	sinks   []Sink
+	redact  logs.AuditRedaction
+	events  chan Event
+	timeout time.Duration
+}
+
+func NewAuditor(kClient *kommons.Client, config logs.AuditConfig) (*Auditor, error) {
+	a := &Auditor{
+		redact:  config.Redact,
+		events:  make(chan Event, 1000),
+		timeout: queueTimeout,
+	}
+
+	if config.File != "" {
+		a.sinks = append(a.sinks, &fileSink{path: config.File})
+	}
+
+	if config.ElasticSearch != nil {
+		sink, err := newElasticSearchSink(kClient, config.Namespace, *config.ElasticSearch)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the elasticsearch sink: %w", err)
+		}
+		a.sinks = append(a.sinks, sink)
+	}
+
+	if config.Webhook != nil {
+		sink, err := newWebhookSink(kClient, config.Namespace, *config.Webhook)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the webhook sink: %w", err)
+		}
+		a.sinks = append(a.sinks, sink)
+	}
+
+	if len(a.sinks) == 0 {
+		return nil, fmt.Errorf("no audit sink configured")
+	}
+
+	go a.run()
+	return a, nil
+}
+
+// Record queues the event to be written to the sinks.
+// When the queue is full, it blocks until there's room in the queue or the queue timeout,
+// after which the event is dropped and counted in apm_hub_audit_dropped_events_total.
+func (t *Auditor) Record(event Event) {
+	if t == nil {
+		return
+	}
+
+	event = t.redactEvent(event)
+	select {
+	case t.events <- event:
+		return
+	default:
+	}
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	select {
+	case t.events <- event:
+	case <-timer.C:
+		droppedEvents.Inc()
+		logger.Errorf("audit queue is full, dropping the audit event of %s", event.User)
+	}
+}
+
+func (t *Auditor) redactEvent(event Event) Event {
+	event.Params = t.RedactParams(event.Params)
+	return event
+}
+
+// RedactParams masks the query and the labels of the search params configured to be redacted
+func (t *Auditor) RedactParams(params logs.SearchParams) logs.SearchParams {
+	if t == nil {
+		return params
+	}
+
+	if t.redact.Query && params.Query != "" {
+		params.Query = redacted
+	}
+
+	if len(t.redact.Labels) > 0 && len(params.Labels) > 0 {
+		labels := make(map[string]string, len(params.Labels))
+		for k, v := range params.Labels {
+			if collections.Contains(t.redact.Labels, k) {
+				v = redacted
+			}
+			labels[k] = v
+		}
+		params.Labels = labels
+	}
+
+	return params
+}
+
+// RedactText masks the values of the search params configured to be redacted wherever they appear in the text,
+// e.g. in the native query rendered from the search params
+func (t *Auditor) RedactText(params logs.SearchParams, text string) string {
+	if t == nil || text == "" {
+		return text
+	}
+
+	var values []string
+	if t.redact.Query && params.Query != "" {
+		values = append(values, params.Query)
+	}
+	for _, k := range t.redact.Labels {
+		if v := params.Labels[k]; v != "" {
+			values = append(values, v)
+		}
+	}
+
+	for _, v := range values {
+		text = strings.ReplaceAll(text, v, redacted)
+	}
+	return text
+}
+
+func (t *Auditor) run() {
+	for event := range t.events {
+		batch := []Event{event}
+		// Drain whatever is already queued to write it in a single batch
+	drain:
+		for len(batch) < 100 {
+			select {
+			case e := <-t.events:
+				batch = append(batch, e)
+			default:
+				break drain
+			}
+		}
+
+		for _, sink := range t.sinks {
+			if err := sink.Write(batch); err != nil {
+				logger.Errorf("error writing %d audit events to %T: %v", len(batch), sink, err)
+			}
+		}
+	}
+}
+
+type fileSink struct {
+	path string
+	lock sync.Mutex
+}
+
+func (t *fileSink) Write(events []Event) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	f, err := os.OpenFile(t.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", t.path, err)
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type elasticSearchSink struct {
+	client *v8.Client
+	index  string
+}
+
+func newElasticSearchSink(kClient *kommons.Client, namespace string, config logs.AuditElasticSearchSink) (*elasticSearchSink, error) {
+	cfg := v8.Config{Addresses: []string{config.Address}}
+	if config.Username != nil {
+		_, username, err := kClient.GetEnvValue(*config.Username, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the username: %w", err)
+		}
+		cfg.Username = username
+	}
+
+	if config.Password != nil {
+		_, password, err := kClient.GetEnvValue(*config.Password, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the password: %w", err)
+		}
+		cfg.Password = password
+	}
+
+	client, err := v8.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &elasticSearchSink{client: client, index: config.Index}, nil
+}
+
+func (t *elasticSearchSink) Write(events []Event) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		// Audit events are never updated, so they're created with the op_type "create"
+		// which makes the index behave as append only.
+		if err := encoder.Encode(map[string]any{"create": map[string]any{}}); err != nil {
+			return err
+		}
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+
+	res, err := t.client.Bulk(&buf,
+		t.client.Bulk.WithContext(context.Background()),
+		t.client.Bulk.WithIndex(t.index),
+	)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return fmt.Errorf("[elasticsearch] got response: %s", res.Status())
+	}
+
+	// The bulk api succeeds even when some of the documents are rejected
+	var bulk bulkResponse
+	if err := json.NewDecoder(res.Body).Decode(&bulk); err != nil {
+		return fmt.Errorf("[elasticsearch] error decoding the bulk response: %w", err)
+	}
+	return bulk.Err()
+}
+
+type bulkResponse struct {
+	Errors bool                        `json:"errors"`
+	Items  []map[string]bulkItemResult `json:"items"`
+}
+
+type bulkItemResult struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error,omitempty"`
+}
+
+// Err returns an error with the first error of the items when any of them failed
+func (t bulkResponse) Err() error {
+	if !t.Errors {
+		return nil
+	}
+
+	failed := 0
+	var firstErr json.RawMessage
+	for _, item := range t.Items {
+		for _, result := range item {
+			if result.Status < 300 {
+				continue
+			}
+			failed++
+			if firstErr == nil {
+				firstErr = result.Error
+			}
+		}
+	}
+	return fmt.Errorf("[elasticsearch] %d of %d audit events were rejected: %s", failed, len(t.Items), firstErr)
+}
+
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newWebhookSink(kClient *kommons.Client, namespace string, config logs.AuditWebhookSink) (*webhookSink, error) {
+	sink := &webhookSink{
+		url:     config.URL,
+		headers: make(map[string]string, len(config.Headers)),
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+
+	for name, envVar := range config.Headers {
+		_, value, err := kClient.GetEnvValue(envVar, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the header %s: %w", name, err)
+		}
+		sink.headers[name] = value
+	}
+
+	return sink, nil
+}
+
+func (t *webhookSink) Write(events []Event) error {
+	body, err := json.Marshal(events)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("[webhook] got response: %d", resp.StatusCode)
+	}
+	return nil
+}
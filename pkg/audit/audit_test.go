@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAuditor_RedactParams(t *testing.T) {
+	auditor := &Auditor{redact: logs.AuditRedaction{Query: true, Labels: []string{"user"}}}
+	params := logs.SearchParams{Query: "token=abc", Labels: map[string]string{"user": "alice", "namespace": "default"}}
+
+	got := auditor.RedactParams(params)
+	want := logs.SearchParams{Query: redacted, Labels: map[string]string{"user": redacted, "namespace": "default"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RedactParams() = %+v, want %+v", got, want)
+	}
+	if params.Labels["user"] != "alice" {
+		t.Errorf("RedactParams() modified the labels of the search params")
+	}
+
+	if got := auditor.RedactText(params, `{user="alice"} |= "token=abc"`); got != `{user="[REDACTED]"} |= "[REDACTED]"` {
+		t.Errorf("RedactText() = %s", got)
+	}
+
+	if got := (*Auditor)(nil).RedactParams(params); !reflect.DeepEqual(got, params) {
+		t.Errorf("RedactParams() = %+v without an auditor, want the search params", got)
+	}
+}
+
+func TestAuditor_RecordQueueFull(t *testing.T) {
+	auditor := &Auditor{events: make(chan Event, 1), timeout: 10 * time.Millisecond}
+	dropped := testutil.ToFloat64(droppedEvents)
+
+	auditor.Record(Event{User: "alice"})
+	auditor.Record(Event{User: "bob"})
+
+	if got := testutil.ToFloat64(droppedEvents) - dropped; got != 1 {
+		t.Errorf("dropped %v events, want 1", got)
+	}
+	if e := <-auditor.events; e.User != "alice" {
+		t.Errorf("queued the event of %s, want alice", e.User)
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	sink := &fileSink{path: filepath.Join(t.TempDir(), "audit.log")}
+	for _, user := range []string{"alice", "bob"} {
+		if err := sink.Write([]Event{{User: user, Results: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(sink.path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var users []string
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, event.User)
+	}
+	if !reflect.DeepEqual(users, []string{"alice", "bob"}) {
+		t.Errorf("the file has the events of %v, want them appended", users)
+	}
+}
+
+func TestElasticSearchSink(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  string
+	}{
+		{name: "created", response: `{"errors":false,"items":[{"create":{"status":201}},{"create":{"status":201}}]}`},
+		{
+			name:     "rejected",
+			response: `{"errors":true,"items":[{"create":{"status":201}},{"create":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`,
+			wantErr:  "1 of 2 audit events were rejected",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var lines []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				lines = strings.Split(strings.TrimSpace(string(body)), "\n")
+				w.Header().Set("X-Elastic-Product", "Elasticsearch")
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			sink, err := newElasticSearchSink(nil, "", logs.AuditElasticSearchSink{Address: server.URL, Index: "audit"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = sink.Write([]Event{{User: "alice"}, {User: "bob"}})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("Write() error = %v, want %s", err, tt.wantErr)
+			}
+			if len(lines) != 4 || lines[0] != `{"create":{}}` {
+				t.Errorf("the bulk request = %v, want a create action per event", lines)
+			}
+		})
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got []Event
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	sink := &webhookSink{url: server.URL, headers: map[string]string{"Authorization": "Bearer secret"}, client: server.Client()}
+	if err := sink.Write([]Event{{User: "alice"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].User != "alice" {
+		t.Errorf("the webhook received %+v", got)
+	}
+
+	status = http.StatusInternalServerError
+	if err := sink.Write([]Event{{User: "alice"}}); err == nil {
+		t.Errorf("Write() = nil, want the error of the webhook")
+	}
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+	"github.com/flanksource/kommons"
+	"github.com/labstack/echo/v4"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+// publicPaths are served without authentication.
+// The agents pushing to /ingest, systemd-journal-upload pushing to /upload and the API servers
+// pushing their audit events to /ingest/audit authenticate with the ingest tokens instead,
+// /ready is probed by the kubelet.
+var publicPaths = []string{"/", "/ingest", "/upload", "/ingest/audit", "/ready"}
+
+// Authenticator verifies the credentials of incoming requests
+// against the configured basic auth users, api keys and jwt issuer.
+type Authenticator struct {
+	// users maps the username to the password
+	users map[string]string
+
+	// apiKeys maps the key to the user using it
+	apiKeys map[string]api.User
+
+	// groups maps the basic auth username to its groups
+	groups map[string][]string
+
+	// tenants maps the basic auth username to its tenant
+	tenants map[string]string
+
+	jwt *jwtVerifier
+}
+
+// NewAuthenticator resolves the credentials in the given config.
+func NewAuthenticator(kClient *kommons.Client, config logs.AuthConfig) (*Authenticator, error) {
+	a := &Authenticator{
+		users:   make(map[string]string, len(config.Basic)),
+		apiKeys: make(map[string]api.User, len(config.APIKeys)),
+		groups:  make(map[string][]string, len(config.Basic)),
+		tenants: make(map[string]string, len(config.Basic)),
+	}
+
+	for _, user := range config.Basic {
+		_, password, err := kClient.GetEnvValue(user.Password, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the password of user %s: %w", user.Username, err)
+		}
+
+		if password == "" {
+			return nil, fmt.Errorf("password of user %s is empty", user.Username)
+		}
+		a.users[user.Username] = password
+		a.groups[user.Username] = user.Groups
+		a.tenants[user.Username] = user.Tenant
+	}
+
+	for _, key := range config.APIKeys {
+		_, value, err := kClient.GetEnvValue(key.Key, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the api key %s: %w", key.Name, err)
+		}
+
+		if value == "" {
+			return nil, fmt.Errorf("api key %s is empty", key.Name)
+		}
+		a.apiKeys[value] = api.User{Name: key.Name, Groups: key.Groups, Tenant: key.Tenant}
+	}
+
+	if config.JWT != nil {
+		verifier, err := newJWTVerifier(kClient, config.Namespace, *config.JWT)
+		if err != nil {
+			return nil, fmt.Errorf("error setting up jwt verification: %w", err)
+		}
+		a.jwt = verifier
+	}
+
+	return a, nil
+}
+
+// Authenticate returns the user the request's credentials belong to
+// or nil if the credentials are missing or invalid.
+func (t *Authenticator) Authenticate(req *http.Request) *api.User {
+	if key := req.Header.Get(apiKeyHeader); key != "" {
+		return t.authenticateAPIKey(key)
+	}
+
+	if username, password, ok := req.BasicAuth(); ok {
+		expected, found := t.users[username]
+		if !found || subtle.ConstantTimeCompare([]byte(expected), []byte(password)) != 1 {
+			return nil
+		}
+		return &api.User{Name: username, Groups: t.groups[username], Tenant: t.tenants[username]}
+	}
+
+	if token, ok := strings.CutPrefix(req.Header.Get(echo.HeaderAuthorization), "Bearer "); ok {
+		if t.jwt != nil && strings.Count(token, ".") == 2 {
+			return t.jwt.verify(token)
+		}
+		return t.authenticateAPIKey(token)
+	}
+
+	return nil
+}
+
+func (t *Authenticator) authenticateAPIKey(key string) *api.User {
+	for k, user := range t.apiKeys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			return &user
+		}
+	}
+
+	return nil
+}
+
+// Middleware rejects the requests that can't be authenticated
+// and attaches the authenticated user to the context.
+func (t *Authenticator) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if collections.Contains(publicPaths, c.Path()) {
+			return next(c)
+		}
+
+		// Already authenticated with a client certificate
+		if cc, ok := c.(*api.Context); ok && cc.User != nil {
+			return next(c)
+		}
+
+		user := t.Authenticate(c.Request())
+		if user == nil {
+			c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Basic realm="apm-hub"`)
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or missing credentials")
+		}
+
+		if cc, ok := c.(*api.Context); ok {
+			cc.User = user
+		}
+
+		return next(c)
+	}
+}
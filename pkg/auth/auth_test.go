@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	authenticator, err := NewAuthenticator(nil, logs.AuthConfig{
+		Basic:   []logs.BasicAuthUser{{Username: "admin", Password: kommons.EnvVar{Value: "secret"}}},
+		APIKeys: []logs.APIKey{{Name: "grafana", Key: kommons.EnvVar{Value: "abcd"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		basic   []string
+		want    string
+	}{
+		{name: "no credentials"},
+		{name: "basic auth", basic: []string{"admin", "secret"}, want: "admin"},
+		{name: "basic auth - wrong password", basic: []string{"admin", "wrong"}},
+		{name: "basic auth - unknown user", basic: []string{"root", "secret"}},
+		{name: "api key header", headers: map[string]string{"X-API-Key": "abcd"}, want: "grafana"},
+		{name: "api key bearer", headers: map[string]string{"Authorization": "Bearer abcd"}, want: "grafana"},
+		{name: "invalid api key", headers: map[string]string{"X-API-Key": "efgh"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/search", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if tt.basic != nil {
+				req.SetBasicAuth(tt.basic[0], tt.basic[1])
+			}
+
+			var got string
+			if user := authenticator.Authenticate(req); user != nil {
+				got = user.Name
+			}
+
+			if got != tt.want {
+				t.Errorf("Authenticate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAuthenticator_EmptyCredentials(t *testing.T) {
+	if _, err := NewAuthenticator(nil, logs.AuthConfig{Basic: []logs.BasicAuthUser{{Username: "admin"}}}); err == nil {
+		t.Errorf("NewAuthenticator() accepted a user without a password")
+	}
+	if _, err := NewAuthenticator(nil, logs.AuthConfig{APIKeys: []logs.APIKey{{Name: "grafana"}}}); err == nil {
+		t.Errorf("NewAuthenticator() accepted an empty api key")
+	}
+}
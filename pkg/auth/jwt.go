@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/kommons"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+type jwtVerifier struct {
+	config logs.JWTConfig
+	key    any
+}
+
+func newJWTVerifier(kClient *kommons.Client, namespace string, config logs.JWTConfig) (*jwtVerifier, error) {
+	v := &jwtVerifier{config: config}
+	if v.config.UsernameClaim == "" {
+		v.config.UsernameClaim = "sub"
+	}
+	if v.config.GroupsClaim == "" {
+		v.config.GroupsClaim = "groups"
+	}
+
+	switch {
+	case config.Secret != nil:
+		_, secret, err := kClient.GetEnvValue(*config.Secret, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the secret: %w", err)
+		}
+		v.key = []byte(secret)
+
+	case config.PublicKey != nil:
+		_, pem, err := kClient.GetEnvValue(*config.PublicKey, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the public key: %w", err)
+		}
+
+		if key, err := jwt.ParseRSAPublicKeyFromPEM([]byte(pem)); err == nil {
+			v.key = key
+		} else if key, err := jwt.ParseECPublicKeyFromPEM([]byte(pem)); err == nil {
+			v.key = key
+		} else {
+			return nil, fmt.Errorf("public key is neither a RSA nor an ECDSA key")
+		}
+
+	default:
+		return nil, fmt.Errorf("either a secret or a public key is required")
+	}
+
+	return v, nil
+}
+
+func (t *jwtVerifier) keyFunc(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if _, ok := t.key.([]byte); ok {
+			return t.key, nil
+		}
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+		if _, ok := t.key.([]byte); !ok {
+			return t.key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unexpected signing method %s", token.Header["alg"])
+}
+
+// verify returns the user identified by the token
+// or nil if the token isn't valid.
+func (t *jwtVerifier) verify(tokenString string) *api.User {
+	claims := jwt.MapClaims{}
+	if _, err := jwt.ParseWithClaims(tokenString, claims, t.keyFunc); err != nil {
+		logger.Debugf("invalid jwt: %v", err)
+		return nil
+	}
+
+	if t.config.Issuer != "" && !claims.VerifyIssuer(t.config.Issuer, true) {
+		logger.Debugf("invalid jwt issuer: %v", claims["iss"])
+		return nil
+	}
+
+	if t.config.Audience != "" && !claims.VerifyAudience(t.config.Audience, true) {
+		logger.Debugf("invalid jwt audience: %v", claims["aud"])
+		return nil
+	}
+
+	username, _ := claims[t.config.UsernameClaim].(string)
+	if username == "" {
+		logger.Debugf("jwt is missing the username claim %s", t.config.UsernameClaim)
+		return nil
+	}
+
+	user := &api.User{Name: username}
+	switch groups := claims[t.config.GroupsClaim].(type) {
+	case []any:
+		for _, g := range groups {
+			if group, ok := g.(string); ok {
+				user.Groups = append(user.Groups, group)
+			}
+		}
+	case string:
+		user.Groups = []string{groups}
+	}
+
+	if t.config.TenantClaim != "" {
+		user.Tenant, _ = claims[t.config.TenantClaim].(string)
+	}
+
+	return user
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+)
+
+// GlobalAuthorizer authorizes the searches.
+// It's nil when rbac isn't configured.
+var GlobalAuthorizer *Authorizer
+
+// Authorizer maps the authenticated users to the backends, types and labels
+// they're allowed to search.
+type Authorizer struct {
+	rules []logs.RBACRule
+}
+
+func NewAuthorizer(config logs.RBACConfig) *Authorizer {
+	return &Authorizer{rules: config.Rules}
+}
+
+// Grant is the access given to a search by a rbac rule
+type Grant struct {
+	rule logs.RBACRule
+}
+
+// Authorize finds the first rule that applies to the user and allows the search params.
+// The labels constrained to a single value are injected into the search params when missing.
+func (t *Authorizer) Authorize(user *api.User, q *logs.SearchParams) (*Grant, error) {
+	var lastErr error
+	for _, rule := range t.rules {
+		if !ruleAppliesTo(rule, user) {
+			continue
+		}
+
+		if err := allows(rule, q); err != nil {
+			lastErr = err
+			continue
+		}
+
+		injectLabels(rule, q)
+		return &Grant{rule: rule}, nil
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+
+	return nil, fmt.Errorf("%s is not allowed to search", userName(user))
+}
+
+// CanSearch returns true if any of the rules applying to the user allows searching the backend
+func (t *Authorizer) CanSearch(user *api.User, backend logs.SearchBackend) bool {
+	if t == nil {
+		return true
+	}
+
+	for _, rule := range t.rules {
+		if ruleAppliesTo(rule, user) && (&Grant{rule: rule}).AllowsBackend(backend) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsAdmin returns true if a rule without restrictions applies to the user,
+// i.e. the user can search every backend, type and label.
+func (t *Authorizer) IsAdmin(user *api.User) bool {
+	if t == nil {
+		return true
+	}
+
+	for _, rule := range t.rules {
+		if ruleAppliesTo(rule, user) && len(rule.Backends) == 0 && len(rule.Types) == 0 && len(rule.Labels) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsBackend returns true if the backend can be searched with this grant
+func (t *Grant) AllowsBackend(backend logs.SearchBackend) bool {
+	if t == nil || len(t.rule.Backends) == 0 {
+		return true
+	}
+
+	return collections.MatchItems(backend.Name, t.rule.Backends...)
+}
+
+// Allows returns an error if the search params aren't allowed by the grant, e.g. once a mapper changed their type
+func (t *Grant) Allows(q *logs.SearchParams) error {
+	if t == nil {
+		return nil
+	}
+	return allows(t.rule, q)
+}
+
+// FiltersResults returns true if the results must be filtered with the grant's label constraints
+func (t *Grant) FiltersResults() bool {
+	return t != nil && len(t.rule.Labels) > 0
+}
+
+// AllowsResult returns false if the result carries a label outside of the grant's label constraints.
+func (t *Grant) AllowsResult(r logs.Result) bool {
+	if t == nil {
+		return true
+	}
+
+	for k, v := range t.rule.Labels {
+		if val, ok := r.Labels[k]; ok && !collections.MatchItems(val, strings.Split(v, ",")...) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Filter removes the results that aren't allowed by the grant
+func (t *Grant) Filter(results []logs.Result) []logs.Result {
+	if t == nil || len(t.rule.Labels) == 0 {
+		return results
+	}
+
+	filtered := results[:0]
+	for _, r := range results {
+		if t.AllowsResult(r) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+func ruleAppliesTo(rule logs.RBACRule, user *api.User) bool {
+	if len(rule.Users) == 0 && len(rule.Groups) == 0 {
+		return true
+	}
+
+	if user == nil {
+		return false
+	}
+
+	if len(rule.Users) > 0 && collections.MatchItems(user.Name, rule.Users...) {
+		return true
+	}
+
+	for _, group := range user.Groups {
+		if len(rule.Groups) > 0 && collections.MatchItems(group, rule.Groups...) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func allows(rule logs.RBACRule, q *logs.SearchParams) error {
+	if len(rule.Types) > 0 && !collections.MatchItems(q.Type, rule.Types...) {
+		return fmt.Errorf("searching type %q is not allowed", q.Type)
+	}
+
+	for k, v := range rule.Labels {
+		allowed := strings.Split(v, ",")
+		val, ok := q.Labels[k]
+		if !ok {
+			if singleValue(allowed) == "" {
+				return fmt.Errorf("label %s is required", k)
+			}
+			continue
+		}
+
+		if !collections.MatchItems(val, allowed...) {
+			return fmt.Errorf("searching %s=%s is not allowed", k, val)
+		}
+	}
+
+	return nil
+}
+
+func injectLabels(rule logs.RBACRule, q *logs.SearchParams) {
+	for k, v := range rule.Labels {
+		if _, ok := q.Labels[k]; ok {
+			continue
+		}
+
+		if q.Labels == nil {
+			q.Labels = make(map[string]string)
+		}
+		q.Labels[k] = singleValue(strings.Split(v, ","))
+	}
+}
+
+// singleValue returns the only value allowed by the items
+// or an empty string if they allow more than a single value.
+func singleValue(items []string) string {
+	if len(items) != 1 || items[0] == "*" || strings.HasPrefix(items[0], "!") {
+		return ""
+	}
+	return items[0]
+}
+
+func userName(user *api.User) string {
+	if user == nil {
+		return "anonymous"
+	}
+	return user.Name
+}
@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestAuthorizer_Authorize(t *testing.T) {
+	authorizer := NewAuthorizer(logs.RBACConfig{
+		Rules: []logs.RBACRule{
+			{Groups: []string{"admins"}},
+			{Groups: []string{"team-a"}, Types: []string{"KubernetesPod"}, Labels: map[string]string{"namespace": "team-a"}},
+			{Users: []string{"auditor"}, Labels: map[string]string{"namespace": "team-a,team-b"}},
+		},
+	})
+
+	tests := []struct {
+		name       string
+		user       *api.User
+		params     logs.SearchParams
+		allowed    bool
+		wantLabels map[string]string
+	}{
+		{name: "anonymous", params: logs.SearchParams{Type: "KubernetesPod"}},
+		{name: "admin", user: &api.User{Name: "bob", Groups: []string{"admins"}}, params: logs.SearchParams{Type: "KubernetesNode"}, allowed: true},
+		{
+			name:       "team-a - label injected",
+			user:       &api.User{Name: "alice", Groups: []string{"team-a"}},
+			params:     logs.SearchParams{Type: "KubernetesPod"},
+			allowed:    true,
+			wantLabels: map[string]string{"namespace": "team-a"},
+		},
+		{
+			name:   "team-a - other namespace",
+			user:   &api.User{Name: "alice", Groups: []string{"team-a"}},
+			params: logs.SearchParams{Type: "KubernetesPod", Labels: map[string]string{"namespace": "team-b"}},
+		},
+		{name: "team-a - type not allowed", user: &api.User{Name: "alice", Groups: []string{"team-a"}}, params: logs.SearchParams{Type: "KubernetesNode"}},
+		{name: "auditor - label required", user: &api.User{Name: "auditor"}, params: logs.SearchParams{Type: "KubernetesNode"}},
+		{
+			name:       "auditor - one of the labels",
+			user:       &api.User{Name: "auditor"},
+			params:     logs.SearchParams{Labels: map[string]string{"namespace": "team-b"}},
+			allowed:    true,
+			wantLabels: map[string]string{"namespace": "team-b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := authorizer.Authorize(tt.user, &tt.params)
+			if (err == nil) != tt.allowed {
+				t.Fatalf("Authorize() error = %v, allowed %v", err, tt.allowed)
+			}
+
+			for k, v := range tt.wantLabels {
+				if tt.params.Labels[k] != v {
+					t.Errorf("expected label %s=%s, got %v", k, v, tt.params.Labels)
+				}
+			}
+		})
+	}
+}
+
+func TestAuthorizer_CanSearch(t *testing.T) {
+	authorizer := NewAuthorizer(logs.RBACConfig{
+		Rules: []logs.RBACRule{
+			{Groups: []string{"admins"}},
+			{Groups: []string{"team-a"}, Backends: []string{"team-a-pods"}},
+		},
+	})
+
+	tests := []struct {
+		name    string
+		user    *api.User
+		backend string
+		allowed bool
+	}{
+		{name: "anonymous", backend: "team-a-pods"},
+		{name: "admin", user: &api.User{Name: "bob", Groups: []string{"admins"}}, backend: "audit", allowed: true},
+		{name: "team-a", user: &api.User{Name: "alice", Groups: []string{"team-a"}}, backend: "team-a-pods", allowed: true},
+		{name: "team-a - other backend", user: &api.User{Name: "alice", Groups: []string{"team-a"}}, backend: "audit"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if allowed := authorizer.CanSearch(tt.user, logs.SearchBackend{Name: tt.backend}); allowed != tt.allowed {
+				t.Errorf("CanSearch() = %v, want %v", allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestAuthorizer_IsAdmin(t *testing.T) {
+	authorizer := NewAuthorizer(logs.RBACConfig{
+		Rules: []logs.RBACRule{
+			{Groups: []string{"admins"}},
+			{Groups: []string{"team-a"}, Labels: map[string]string{"namespace": "team-a"}},
+		},
+	})
+
+	if !authorizer.IsAdmin(&api.User{Name: "bob", Groups: []string{"admins"}}) {
+		t.Errorf("IsAdmin() = false for the admins group")
+	}
+	if authorizer.IsAdmin(&api.User{Name: "alice", Groups: []string{"team-a"}}) {
+		t.Errorf("IsAdmin() = true for a user restricted to a namespace")
+	}
+	if authorizer.IsAdmin(nil) {
+		t.Errorf("IsAdmin() = true for anonymous requests")
+	}
+	if !(*Authorizer)(nil).IsAdmin(nil) {
+		t.Errorf("IsAdmin() = false without rbac")
+	}
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+	"github.com/labstack/echo/v4"
+)
+
+const defaultTenantLabel = "tenant"
+
+// GlobalTenancy scopes the searches to the tenant of the request.
+// It's nil when tenancy isn't configured.
+var GlobalTenancy *Tenancy
+
+// Tenancy resolves the tenant of the requests and isolates their searches
+type Tenancy struct {
+	config logs.TenancyConfig
+
+	// tenants is nil when any tenant is accepted
+	tenants map[string]logs.TenantConfig
+}
+
+func NewTenancy(config logs.TenancyConfig) *Tenancy {
+	if config.Label == "" {
+		config.Label = defaultTenantLabel
+	}
+
+	t := &Tenancy{config: config}
+	if len(config.Tenants) > 0 {
+		t.tenants = make(map[string]logs.TenantConfig, len(config.Tenants))
+		for _, tenant := range config.Tenants {
+			t.tenants[tenant.Name] = tenant
+		}
+	}
+	return t
+}
+
+// Tenant returns the tenant of the request.
+// The authenticated users are scoped to the tenant bound to their credentials,
+// the header is only read when the requests aren't authenticated, i.e. behind a trusted proxy.
+func (t *Tenancy) Tenant(c echo.Context) string {
+	if cc, ok := c.(*api.Context); ok && cc.User != nil {
+		return cc.User.Tenant
+	}
+
+	if t.config.Header != "" {
+		return c.Request().Header.Get(t.config.Header)
+	}
+
+	return ""
+}
+
+// Middleware rejects the requests without a tenant, or of a tenant that isn't configured,
+// and attaches the tenant to the context.
+// It must run after the authentication.
+func (t *Tenancy) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if collections.Contains(publicPaths, c.Path()) {
+			return next(c)
+		}
+
+		tenant := t.Tenant(c)
+		if tenant == "" {
+			return echo.NewHTTPError(http.StatusForbidden, "tenant is required")
+		}
+		if _, ok := t.tenants[tenant]; t.tenants != nil && !ok {
+			return echo.NewHTTPError(http.StatusForbidden, fmt.Sprintf("unknown tenant %s", tenant))
+		}
+
+		if cc, ok := c.(*api.Context); ok {
+			cc.Tenant = tenant
+		}
+
+		return next(c)
+	}
+}
+
+// Inject sets the tenant label and the labels of the tenant on the search params,
+// overriding the values sent by the client.
+func (t *Tenancy) Inject(tenant string, q *logs.SearchParams) {
+	if t == nil {
+		return
+	}
+
+	if q.Labels == nil {
+		q.Labels = make(map[string]string)
+	}
+	for k, v := range t.tenants[tenant].Labels {
+		q.Labels[k] = v
+	}
+	q.Labels[t.config.Label] = tenant
+}
+
+// AllowsBackend returns true if the tenant can search the backend.
+// The internal searches, without a tenant, can search every backend.
+func (t *Tenancy) AllowsBackend(tenant string, backend logs.SearchBackend) bool {
+	if t == nil || tenant == "" {
+		return true
+	}
+
+	backends := t.tenants[tenant].Backends
+	return len(backends) == 0 || collections.MatchItems(backend.Name, backends...)
+}
+
+// Filter removes the results belonging to other tenants
+// and the ones carrying other values of the labels of the tenant
+func (t *Tenancy) Filter(tenant string, results []logs.Result) []logs.Result {
+	if t == nil {
+		return results
+	}
+
+	labels := t.tenants[tenant].Labels
+	filtered := results[:0]
+	for _, r := range results {
+		val, ok := r.Labels[t.config.Label]
+		if ok && val != tenant {
+			continue
+		}
+		if !ok && t.config.Strict {
+			continue
+		}
+		if !matchesLabels(r, labels) {
+			continue
+		}
+		filtered = append(filtered, r)
+	}
+	return filtered
+}
+
+// matchesLabels returns false if the result carries another value of one of the labels
+func matchesLabels(r logs.Result, labels map[string]string) bool {
+	for k, v := range labels {
+		if val, ok := r.Labels[k]; ok && val != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,143 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/labstack/echo/v4"
+)
+
+func TestTenancy_Filter(t *testing.T) {
+	results := func() []logs.Result {
+		return []logs.Result{
+			{Id: "a", Labels: map[string]string{"tenant": "acme"}},
+			{Id: "b", Labels: map[string]string{"tenant": "globex"}},
+			{Id: "c"},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		config logs.TenancyConfig
+		want   []string
+	}{
+		{name: "other tenants dropped", want: []string{"a", "c"}},
+		{name: "strict", config: logs.TenancyConfig{Strict: true}, want: []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewTenancy(tt.config).Filter("acme", results())
+			if len(got) != len(tt.want) {
+				t.Fatalf("Filter() = %v, want ids %v", got, tt.want)
+			}
+			for i, r := range got {
+				if r.Id != tt.want[i] {
+					t.Errorf("Filter()[%d] = %s, want %s", i, r.Id, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTenancy_Inject(t *testing.T) {
+	q := logs.SearchParams{Labels: map[string]string{"tenant": "globex"}}
+	NewTenancy(logs.TenancyConfig{}).Inject("acme", &q)
+	if q.Labels["tenant"] != "acme" {
+		t.Errorf("expected the tenant label to be overridden, got %v", q.Labels)
+	}
+}
+
+func TestTenancy_Tenants(t *testing.T) {
+	tenancy := NewTenancy(logs.TenancyConfig{
+		Header: "X-Scope-OrgID",
+		Tenants: []logs.TenantConfig{
+			{Name: "acme", Backends: []string{"acme-logs", "shared"}, Labels: map[string]string{"cluster": "acme-prod"}},
+			{Name: "globex", Backends: []string{"*", "!acme-logs"}},
+		},
+	})
+
+	for _, tt := range []struct {
+		tenant  string
+		backend string
+		want    bool
+	}{
+		{tenant: "acme", backend: "acme-logs", want: true},
+		{tenant: "acme", backend: "globex-logs"},
+		{tenant: "globex", backend: "shared", want: true},
+		{tenant: "globex", backend: "acme-logs"},
+		{tenant: "", backend: "acme-logs", want: true},
+	} {
+		if got := tenancy.AllowsBackend(tt.tenant, logs.SearchBackend{Name: tt.backend}); got != tt.want {
+			t.Errorf("AllowsBackend(%q, %q) = %v, want %v", tt.tenant, tt.backend, got, tt.want)
+		}
+	}
+
+	q := logs.SearchParams{Labels: map[string]string{"cluster": "globex-prod", "app": "api"}}
+	tenancy.Inject("acme", &q)
+	if want := map[string]string{"tenant": "acme", "cluster": "acme-prod", "app": "api"}; !reflect.DeepEqual(q.Labels, want) {
+		t.Errorf("Inject() labels = %v, want %v", q.Labels, want)
+	}
+
+	results := tenancy.Filter("acme", []logs.Result{
+		{Id: "a", Labels: map[string]string{"cluster": "acme-prod"}},
+		{Id: "b", Labels: map[string]string{"cluster": "globex-prod"}},
+		{Id: "c"},
+	})
+	if len(results) != 2 || results[0].Id != "a" || results[1].Id != "c" {
+		t.Errorf("Filter() = %v, want the results of the acme cluster", results)
+	}
+
+	e := echo.New()
+	handler := tenancy.Middleware(func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+	for tenant, want := range map[string]int{"acme": http.StatusNoContent, "initech": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodPost, "/search", nil)
+		req.Header.Set("X-Scope-OrgID", tenant)
+		rec := httptest.NewRecorder()
+		err := handler(e.NewContext(req, rec))
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			rec.Code = httpErr.Code
+		}
+		if rec.Code != want {
+			t.Errorf("Middleware() with tenant %s = %d, want %d", tenant, rec.Code, want)
+		}
+	}
+}
+
+func TestTenancy_AuthenticatedUser(t *testing.T) {
+	tenancy := NewTenancy(logs.TenancyConfig{Header: "X-Scope-OrgID"})
+
+	e := echo.New()
+	for _, tt := range []struct {
+		user   *api.User
+		want   string
+		status int
+	}{
+		{user: &api.User{Name: "alice", Tenant: "acme"}, want: "acme", status: http.StatusNoContent},
+		{user: &api.User{Name: "bob"}, status: http.StatusForbidden},
+		{want: "globex", status: http.StatusNoContent},
+	} {
+		// The header can't override the tenant of the authenticated users, nor give them one
+		req := httptest.NewRequest(http.MethodPost, "/search", nil)
+		req.Header.Set("X-Scope-OrgID", "globex")
+		rec := httptest.NewRecorder()
+		cc := &api.Context{Context: e.NewContext(req, rec), User: tt.user}
+
+		var tenant string
+		handler := tenancy.Middleware(func(c echo.Context) error {
+			tenant = c.(*api.Context).Tenant
+			return c.NoContent(http.StatusNoContent)
+		})
+		err := handler(cc)
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			rec.Code = httpErr.Code
+		}
+		if rec.Code != tt.status || tenant != tt.want {
+			t.Errorf("Middleware() for %+v = %d with tenant %q, want %d with tenant %q", tt.user, rec.Code, tenant, tt.status, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+	"github.com/labstack/echo/v4"
+)
+
+// NewTLSConfig creates the tls config of the server.
+// When a client CA is configured, the certificates presented by the clients must be signed by it
+// and their name must be in the allow list. The certificates are only verified when given,
+// ClientCertMiddleware requires them on the paths that aren't public, e.g. the kubelet probes /ready without one.
+func NewTLSConfig(config logs.TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading the server certificate: %w", err)
+	}
+
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if config.ClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	caPEM, err := os.ReadFile(config.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the client CA: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", config.ClientCAFile)
+	}
+
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if len(config.AllowedNames) > 0 {
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return nil
+			}
+
+			cert := state.PeerCertificates[0]
+			for _, name := range certificateNames(cert) {
+				if collections.MatchItems(name, config.AllowedNames...) {
+					return nil
+				}
+			}
+			return fmt.Errorf("client certificate %s is not allowed", cert.Subject.CommonName)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// certificateNames returns the common name and the SANs of the certificate
+func certificateNames(cert *x509.Certificate) []string {
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+	return collections.DeleteEmptyStrings(names)
+}
+
+// ClientCertMiddleware rejects the requests to the paths that aren't public without a verified client certificate
+// and attaches the identity of the certificate to the context, so that requests authenticated with mTLS don't need other credentials.
+func ClientCertMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		state := c.Request().TLS
+		if state == nil || len(state.VerifiedChains) == 0 {
+			if collections.Contains(publicPaths, c.Path()) {
+				return next(c)
+			}
+			return echo.NewHTTPError(http.StatusUnauthorized, "client certificate is required")
+		}
+
+		if cc, ok := c.(*api.Context); ok {
+			cc.User = &api.User{Name: state.VerifiedChains[0][0].Subject.CommonName}
+		}
+
+		return next(c)
+	}
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/labstack/echo/v4"
+)
+
+// testCA issues the certificates of the tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "apm-hub-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns the certificate and the key of the name, in PEM
+func (t *testCA) issue(tb testing.TB, name string, usage x509.ExtKeyUsage) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, t.cert, &key.PublicKey, t.key)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t)
+	serverCert, serverKey := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	config := logs.TLSConfig{
+		CertFile:     writeFile(t, dir, "tls.crt", serverCert),
+		KeyFile:      writeFile(t, dir, "tls.key", serverKey),
+		ClientCAFile: writeFile(t, dir, "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})),
+		AllowedNames: []string{"canary-checker"},
+	}
+
+	tlsConfig, err := NewTLSConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("ClientAuth = %v, want the client certificates verified when given", tlsConfig.ClientAuth)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	server.TLS = tlsConfig
+	server.StartTLS()
+	defer server.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(name string) error {
+		clientConfig := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+		if name != "" {
+			cert, key := ca.issue(t, name, x509.ExtKeyUsageClientAuth)
+			pair, err := tls.X509KeyPair(cert, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clientConfig.Certificates = []tls.Certificate{pair}
+		}
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	if err := get("canary-checker"); err != nil {
+		t.Errorf("the allowed client certificate was rejected: %v", err)
+	}
+	if err := get("someone-else"); err == nil {
+		t.Errorf("the client certificate outside of the allowed names was accepted")
+	}
+	if err := get(""); err != nil {
+		t.Errorf("the connection without a client certificate was rejected: %v", err)
+	}
+}
+
+func TestClientCertMiddleware(t *testing.T) {
+	ca := newTestCA(t)
+	certPEM, _ := ca.issue(t, "canary-checker", x509.ExtKeyUsageClientAuth)
+	block, _ := pem.Decode(certPEM)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		verified bool
+		wantCode int
+		wantUser string
+	}{
+		{name: "verified", path: "/search", verified: true, wantCode: http.StatusNoContent, wantUser: "canary-checker"},
+		{name: "no certificate", path: "/search", wantCode: http.StatusUnauthorized},
+		{name: "public path", path: "/ready", wantCode: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.TLS = &tls.ConnectionState{}
+			if tt.verified {
+				req.TLS.VerifiedChains = [][]*x509.Certificate{{cert, ca.cert}}
+			}
+			rec := httptest.NewRecorder()
+			c := &api.Context{Context: echo.New().NewContext(req, rec)}
+			c.SetPath(tt.path)
+
+			var user string
+			handler := ClientCertMiddleware(func(c echo.Context) error {
+				if cc := c.(*api.Context); cc.User != nil {
+					user = cc.User.Name
+				}
+				return c.NoContent(http.StatusNoContent)
+			})
+
+			code := http.StatusNoContent
+			if err := handler(c); err != nil {
+				code = err.(*echo.HTTPError).Code
+			}
+			if code != tt.wantCode || user != tt.wantUser {
+				t.Errorf("ClientCertMiddleware() = %d for %q, want %d for %q", code, user, tt.wantCode, tt.wantUser)
+			}
+		})
+	}
+}
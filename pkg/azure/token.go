@@ -0,0 +1,146 @@
+// Package azure authenticates the requests to the Azure apis with the client credentials of a service principal,
+// or a managed identity
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const defaultAuthorityURL = "https://login.microsoftonline.com"
+
+// expiryMargin is how long before their expiry the tokens are renewed
+const expiryMargin = time.Minute
+
+// imdsURL is the token endpoint of the managed identities, on the instance metadata service
+var imdsURL = "http://169.254.169.254/metadata/identity/oauth2/token"
+
+// TokenSource returns the access tokens of a scope, renewing them before they expire
+type TokenSource struct {
+	url    string
+	form   url.Values
+	client *http.Client
+
+	lock   sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewTokenSource returns the token source of the scope, e.g. https://api.loganalytics.io/.default
+func NewTokenSource(kClient *kommons.Client, namespace string, auth logs.AzureAuthentication, scope string) (*TokenSource, error) {
+	if auth.TenantID == "" || auth.ClientID == nil || auth.ClientSecret == nil {
+		return nil, fmt.Errorf("the tenant id, client id and client secret are required")
+	}
+	_, clientID, err := kClient.GetEnvValue(*auth.ClientID, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the client id: %w", err)
+	}
+	_, clientSecret, err := kClient.GetEnvValue(*auth.ClientSecret, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the client secret: %w", err)
+	}
+
+	authority := strings.TrimSuffix(auth.AuthorityURL, "/")
+	if authority == "" {
+		authority = defaultAuthorityURL
+	}
+	return &TokenSource{
+		url: authority + "/" + url.PathEscape(auth.TenantID) + "/oauth2/v2.0/token",
+		form: url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+			"scope":         {scope},
+		},
+		client: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+// NewManagedIdentityTokenSource returns the token source of the resource, e.g. https://storage.azure.com/,
+// with the managed identity of the VM or node pool. The client id selects one of its user assigned identities.
+func NewManagedIdentityTokenSource(resource, clientID string) *TokenSource {
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {resource}}
+	if clientID != "" {
+		query.Set("client_id", clientID)
+	}
+	return &TokenSource{
+		url:    imdsURL + "?" + query.Encode(),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Token returns the cached token, or requests a new one when it's about to expire
+func (t *TokenSource) Token(ctx context.Context) (string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.token != "" && time.Now().Before(t.expiry) {
+		return t.token, nil
+	}
+
+	req, err := t.request(ctx)
+	if err != nil {
+		return "", err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error requesting the token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		AccessToken      string      `json:"access_token"`
+		ExpiresIn        json.Number `json:"expires_in"` // a string in the tokens of the managed identities
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&token); err != nil {
+		return "", fmt.Errorf("error decoding the token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || token.AccessToken == "" {
+		return "", fmt.Errorf("error requesting the token: %s %s", token.Error, token.ErrorDescription)
+	}
+
+	t.token = token.AccessToken
+	expiresIn, _ := token.ExpiresIn.Int64()
+	t.expiry = time.Now().Add(time.Duration(expiresIn)*time.Second - expiryMargin)
+	return t.token, nil
+}
+
+// request returns the token request of the client credentials, or of the managed identity
+func (t *TokenSource) request(ctx context.Context) (*http.Request, error) {
+	if t.form == nil {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Metadata", "true")
+		return req, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, strings.NewReader(t.form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+// Authorize sets the token as the bearer token of the request
+func (t *TokenSource) Authorize(req *http.Request) error {
+	token, err := t.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
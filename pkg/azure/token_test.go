@@ -0,0 +1,40 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestManagedIdentityTokenSource(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Method != http.MethodGet || r.Header.Get("Metadata") != "true" {
+			t.Errorf("unexpected request %s %v", r.Method, r.Header)
+		}
+		if r.URL.Query().Get("resource") != "https://storage.azure.com/" || r.URL.Query().Get("client_id") != "identity" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		_, _ = w.Write([]byte(`{"access_token": "token", "expires_in": "86399", "token_type": "Bearer"}`))
+	}))
+	defer server.Close()
+
+	defer func(url string) { imdsURL = url }(imdsURL)
+	imdsURL = server.URL
+
+	tokens := NewManagedIdentityTokenSource("https://storage.azure.com/", "identity")
+	for i := 0; i < 2; i++ {
+		token, err := tokens.Token(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "token" {
+			t.Errorf("Token() = %s, want token", token)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("%d tokens were requested, want 1 as it's cached", requests)
+	}
+}
@@ -0,0 +1,55 @@
+package pkg
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/auth"
+	"github.com/flanksource/apm-hub/pkg/health"
+)
+
+// BackendInfo describes a backend the user can search
+type BackendInfo struct {
+	Name         string            `json:"name"`
+	Routes       logs.Routes       `json:"routes,omitempty"`
+	Capabilities logs.Capabilities `json:"capabilities"`
+	Shadow       bool              `json:"shadow,omitempty"`
+
+	// Health is nil when the health checks aren't configured
+	Health *health.Status `json:"health,omitempty"`
+}
+
+// Backends lists the backends the user and their tenant can search with their capabilities
+func Backends(c echo.Context) error {
+	cc := c.(*api.Context)
+
+	backends := []BackendInfo{}
+	for i, backend := range logs.GlobalBackends {
+		if !auth.GlobalAuthorizer.CanSearch(cc.User, backend) || !auth.GlobalTenancy.AllowsBackend(cc.Tenant, backend) {
+			continue
+		}
+
+		name := backendName(i, backend)
+		backends = append(backends, BackendInfo{
+			Name:         name,
+			Routes:       backend.Routes,
+			Capabilities: backend.API.Capabilities(),
+			Shadow:       backend.Shadow,
+			Health:       health.GlobalChecker.Status(name),
+		})
+	}
+
+	return cc.JSON(http.StatusOK, backends)
+}
+
+// NamedBackends returns the backends by name, for the health checks
+func NamedBackends() map[string]logs.SearchBackend {
+	backends := make(map[string]logs.SearchBackend, len(logs.GlobalBackends))
+	for i, backend := range logs.GlobalBackends {
+		backends[backendName(i, backend)] = backend
+	}
+	return backends
+}
@@ -0,0 +1,171 @@
+// Package betterstack searches the sources of Better Stack Logs, formerly Logtail, with its query api
+package betterstack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+	"github.com/jeremywohl/flatten"
+)
+
+const (
+	defaultURL = "https://logs.betterstack.com"
+
+	// maxBatch is the maximum number of logs returned by a query
+	maxBatch = 1000
+)
+
+type BetterStackSearch struct {
+	config  *logs.BetterStackBackendConfig
+	client  *rest.Client
+	sources *template.Template
+}
+
+func NewBetterStackSearchBackend(kClient *kommons.Client, config *logs.BetterStackBackendConfig) (*BetterStackSearch, error) {
+	sources, err := template.New("sources").Parse(config.Sources)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the sources template: %w", err)
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &BetterStackSearch{config: config, client: client, sources: sources}, nil
+}
+
+// Search queries the sources over the time range of the search, newest first.
+// The page of the search is the query of the next page link returned with the previous page.
+func (t *BetterStackSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var query url.Values
+	if q.Page != "" {
+		var err error
+		if query, err = url.ParseQuery(q.Page); err != nil {
+			return logs.SearchResults{}, fmt.Errorf("error parsing the page: %w", err)
+		}
+	} else {
+		var sources bytes.Buffer
+		if err := t.sources.Execute(&sources, q); err != nil {
+			return logs.SearchResults{}, fmt.Errorf("error executing the sources template: %w", err)
+		}
+
+		query = url.Values{"order": {"newest_first"}}
+		if ids := strings.TrimSpace(sources.String()); ids != "" {
+			query.Set("source_ids", ids)
+		}
+		if q.Query != "" {
+			query.Set("query", q.Query)
+		}
+		if start := q.GetStart(); start != nil {
+			query.Set("from", start.UTC().Format(time.RFC3339))
+		}
+		if end := q.GetEnd(); end != nil {
+			query.Set("to", end.UTC().Format(time.RFC3339))
+		}
+		if q.Limit > 0 && q.Limit < maxBatch {
+			query.Set("batch", strconv.FormatInt(q.Limit, 10))
+		} else if q.Limit > 0 {
+			query.Set("batch", strconv.Itoa(maxBatch))
+		}
+	}
+
+	var res struct {
+		Data       []map[string]any `json:"data"`
+		Pagination struct {
+			Next string `json:"next"`
+		} `json:"pagination"`
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL("/api/v1/query", query), nil)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+	defer resp.Body.Close()
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&res); err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error decoding the query results: %w", err)
+	}
+
+	results := logs.SearchResults{Total: len(res.Data)}
+	for _, row := range res.Data {
+		results.Results = append(results.Results, t.result(row))
+	}
+	if next, err := url.Parse(res.Pagination.Next); err == nil && len(res.Data) > 0 {
+		results.NextPage = next.RawQuery
+	}
+	return results, nil
+}
+
+// result renders the log, with its dt, message and id fields as the time, message and id of the result
+func (t *BetterStackSearch) result(row map[string]any) logs.Result {
+	r := logs.Result{Labels: make(map[string]string, len(row)+len(t.config.Labels))}
+	fields, _ := flatten.Flatten(row, "", flatten.DotStyle)
+	for field, value := range fields {
+		var v string
+		switch value := value.(type) {
+		case nil:
+			continue
+		case string:
+			v = value
+		case json.Number:
+			v = value.String()
+		case bool:
+			v = strconv.FormatBool(value)
+		default:
+			data, _ := json.Marshal(value)
+			v = string(data)
+		}
+
+		switch field {
+		case "dt":
+			if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				r.Time = ts.UTC().Format(time.RFC3339Nano)
+				continue
+			}
+		case "message":
+			r.Message = v
+			continue
+		case "id":
+			r.Id = v
+			continue
+		}
+		r.Labels[field] = v
+	}
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *BetterStackSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Better Stack backend, the query of the search is sent as Better Stack search syntax
+func (t *BetterStackSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck lists the sources of the team
+func (t *BetterStackSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/v1/sources", url.Values{"per_page": {"1"}}, nil)
+}
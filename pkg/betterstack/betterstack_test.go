@@ -0,0 +1,76 @@
+package betterstack
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestBetterStackSearch(t *testing.T) {
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		queries = append(queries, r.URL.Query())
+		if r.URL.Query().Get("page") == "2" {
+			_, _ = w.Write([]byte(`{"data":[],"pagination":{"next":null}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[
+			{"dt":"2023-05-01T12:00:01.500000Z","id":"r2","message":"connection timeout","kubernetes":{"pod":"api-0"},"status":504},
+			{"dt":"2023-05-01T12:00:00Z","id":"r1","message":"GET /","kubernetes":{"pod":"api-1"},"level":null}
+		],"pagination":{"next":"https://logs.betterstack.com/api/v1/query?source_ids=12&query=timeout&page=2"}}`))
+	}))
+	defer server.Close()
+
+	backend, err := NewBetterStackSearchBackend(nil, &logs.BetterStackBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "betterstack"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Token: &kommons.EnvVar{Value: "secret"}},
+		Sources:        `{{index .Labels "source_id"}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query: "timeout", Labels: map[string]string{"source_id": "12"}, Start: "2023-05-01T11:00:00Z", End: "2023-05-01T12:00:00Z", Limit: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, NextPage: "source_ids=12&query=timeout&page=2", Results: []logs.Result{
+		{Id: "r2", Time: "2023-05-01T12:00:01.5Z", Message: "connection timeout", Labels: map[string]string{"kubernetes.pod": "api-0", "status": "504", "source": "betterstack"}},
+		{Id: "r1", Time: "2023-05-01T12:00:00Z", Message: "GET /", Labels: map[string]string{"kubernetes.pod": "api-1", "source": "betterstack"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Page: res.NextPage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, logs.SearchResults{}) {
+		t.Errorf("Search() of the last page = %+v", res)
+	}
+
+	wantQueries := []url.Values{
+		{"source_ids": {"12"}, "query": {"timeout"}, "from": {"2023-05-01T11:00:00Z"}, "to": {"2023-05-01T12:00:00Z"}, "batch": {"2"}, "order": {"newest_first"}},
+		{"source_ids": {"12"}, "query": {"timeout"}, "page": {"2"}},
+	}
+	if !reflect.DeepEqual(queries, wantQueries) {
+		t.Errorf("queries = %v, want %v", queries, wantQueries)
+	}
+}
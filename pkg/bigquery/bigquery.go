@@ -0,0 +1,319 @@
+// Package bigquery searches the logs of BigQuery tables, e.g. the tables of the Cloud Logging sinks, with the jobs.query api
+package bigquery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/gcp"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+	"golang.org/x/oauth2"
+)
+
+const (
+	defaultURL             = "https://bigquery.googleapis.com"
+	defaultTimestampColumn = "timestamp"
+	defaultMessageColumn   = "textPayload"
+
+	scope = "https://www.googleapis.com/auth/bigquery"
+)
+
+// pollTimeout is how long BigQuery waits for the query to complete before each response
+var pollTimeout = 10 * time.Second
+
+// parameter matches the named parameters of the queries
+var parameter = regexp.MustCompile(`@(\w+)`)
+
+type BigQuerySearch struct {
+	config   *logs.BigQueryBackendConfig
+	client   *rest.Client
+	tokens   oauth2.TokenSource
+	template *template.Template
+}
+
+func NewBigQuerySearchBackend(kClient *kommons.Client, config *logs.BigQueryBackendConfig) (*BigQuerySearch, error) {
+	if config.Project == "" {
+		return nil, fmt.Errorf("the project is required")
+	}
+	if config.Table == "" && config.Query == "" {
+		return nil, fmt.Errorf("either the table or the query is required")
+	}
+	template, err := template.New("query").Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the query template: %w", err)
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := gcp.NewTokenSource(kClient, conn.Namespace, config.Credentials, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BigQuerySearch{config: config, client: client, tokens: tokens, template: template}, nil
+}
+
+// RenderQuery renders the query template, or the default query selecting the rows of the table within the time range of the search.
+// The partitions of the days of the time range are selected when the table has a partition column.
+func (t *BigQuerySearch) RenderQuery(q *logs.SearchParams) (string, error) {
+	if t.config.Query != "" {
+		var buf bytes.Buffer
+		if err := t.template.Execute(&buf, q); err != nil {
+			return "", fmt.Errorf("error executing the query template: %w", err)
+		}
+		return buf.String(), nil
+	}
+
+	timestamp := t.config.TimestampColumn
+	if timestamp == "" {
+		timestamp = defaultTimestampColumn
+	}
+	message := t.config.MessageColumn
+	if message == "" {
+		message = defaultMessageColumn
+	}
+
+	labels := make([]string, 0, len(t.config.LabelColumns))
+	for label := range t.config.LabelColumns {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	columns := []string{timestamp + " AS timestamp", message + " AS message"}
+	conditions := []string{timestamp + " BETWEEN @start AND @end"}
+	if t.config.PartitionColumn != "" {
+		conditions = append(conditions, t.config.PartitionColumn+" BETWEEN TIMESTAMP_TRUNC(@start, DAY) AND @end")
+	}
+	for i, label := range labels {
+		column := "CAST(" + t.config.LabelColumns[label] + " AS STRING)"
+		columns = append(columns, column+" AS `"+label+"`")
+		if _, ok := q.Labels[label]; ok {
+			conditions = append(conditions, column+" = @label_"+strconv.Itoa(i))
+		}
+	}
+	if q.Query != "" {
+		conditions = append(conditions, "CONTAINS_SUBSTR("+message+", @query)")
+	}
+
+	sql := "SELECT " + strings.Join(columns, ", ") + " FROM `" + t.config.Table + "` WHERE " + strings.Join(conditions, " AND ") + " ORDER BY timestamp DESC"
+	if q.Limit > 0 {
+		sql += " LIMIT @limit"
+	}
+	return sql, nil
+}
+
+// parameters returns the parameters used by the query, the time range of the search defaults to the last hour
+func (t *BigQuerySearch) parameters(sql string, q *logs.SearchParams) []map[string]any {
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+	start := end.Add(-time.Hour)
+	if s := q.GetStart(); s != nil {
+		start = *s
+	}
+
+	values := map[string][2]string{
+		"start": {"TIMESTAMP", start.UTC().Format(time.RFC3339Nano)},
+		"end":   {"TIMESTAMP", end.UTC().Format(time.RFC3339Nano)},
+		"limit": {"INT64", strconv.FormatInt(q.Limit, 10)},
+		"query": {"STRING", q.Query},
+	}
+	labels := make([]string, 0, len(t.config.LabelColumns))
+	for label := range t.config.LabelColumns {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for i, label := range labels {
+		values["label_"+strconv.Itoa(i)] = [2]string{"STRING", q.Labels[label]}
+	}
+
+	var parameters []map[string]any
+	seen := make(map[string]bool)
+	for _, match := range parameter.FindAllStringSubmatch(sql, -1) {
+		name := match[1]
+		value, ok := values[name]
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		parameters = append(parameters, map[string]any{
+			"name":           name,
+			"parameterType":  map[string]string{"type": value[0]},
+			"parameterValue": map[string]string{"value": value[1]},
+		})
+	}
+	return parameters
+}
+
+type field struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type queryResponse struct {
+	JobComplete  bool `json:"jobComplete"`
+	JobReference struct {
+		JobID    string `json:"jobId"`
+		Location string `json:"location"`
+	} `json:"jobReference"`
+	Schema struct {
+		Fields []field `json:"fields"`
+	} `json:"schema"`
+	Rows []struct {
+		F []struct {
+			V any `json:"v"`
+		} `json:"f"`
+	} `json:"rows"`
+}
+
+// Search runs the query, and polls its results until the query job completes
+func (t *BigQuerySearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	sql, err := t.RenderQuery(q)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	body := map[string]any{
+		"query":         sql,
+		"useLegacySql":  false,
+		"parameterMode": "NAMED",
+		"timeoutMs":     pollTimeout.Milliseconds(),
+	}
+	if parameters := t.parameters(sql, q); len(parameters) > 0 {
+		body["queryParameters"] = parameters
+	}
+	if t.config.Location != "" {
+		body["location"] = t.config.Location
+	}
+	if q.Limit > 0 {
+		body["maxResults"] = q.Limit
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error encoding the query: %w", err)
+	}
+
+	path := "/bigquery/v2/projects/" + url.PathEscape(t.config.Project) + "/queries"
+	var res queryResponse
+	if err := t.do(ctx, http.MethodPost, t.client.URL(path, nil), bytes.NewReader(data), &res); err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error running the query: %w", err)
+	}
+	for !res.JobComplete {
+		query := url.Values{"timeoutMs": {strconv.FormatInt(pollTimeout.Milliseconds(), 10)}}
+		if res.JobReference.Location != "" {
+			query.Set("location", res.JobReference.Location)
+		}
+		if q.Limit > 0 {
+			query.Set("maxResults", strconv.FormatInt(q.Limit, 10))
+		}
+		if err := t.do(ctx, http.MethodGet, t.client.URL(path+"/"+url.PathEscape(res.JobReference.JobID), query), nil, &res); err != nil {
+			return logs.SearchResults{}, fmt.Errorf("error getting the query results: %w", err)
+		}
+	}
+
+	timestamp, message := "timestamp", "message"
+	if t.config.Query != "" {
+		if timestamp = t.config.TimestampColumn; timestamp == "" {
+			timestamp = defaultTimestampColumn
+		}
+		if message = t.config.MessageColumn; message == "" {
+			message = defaultMessageColumn
+		}
+	}
+
+	results := logs.SearchResults{Total: len(res.Rows)}
+	for _, row := range res.Rows {
+		r := logs.Result{Labels: make(map[string]string, len(row.F)+len(t.config.Labels))}
+		for i, f := range res.Schema.Fields {
+			if i >= len(row.F) || row.F[i].V == nil {
+				continue
+			}
+			value := stringify(f, row.F[i].V)
+			switch f.Name {
+			case timestamp:
+				r.Time = value
+			case message:
+				r.Message = value
+			default:
+				r.Labels[f.Name] = value
+			}
+		}
+		for k, v := range t.config.Labels {
+			r.Labels[k] = v
+		}
+		results.Results = append(results.Results, r)
+	}
+	return results, nil
+}
+
+// stringify returns the value of the field, with the timestamps, sent as seconds since the epoch, in RFC3339
+func stringify(f field, value any) string {
+	s, ok := value.(string)
+	if !ok {
+		data, _ := json.Marshal(value)
+		return string(data)
+	}
+	if f.Type == "TIMESTAMP" {
+		if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+			sec, frac := math.Modf(seconds)
+			return time.Unix(int64(sec), int64(math.Round(frac*1e6))*1e3).UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return s
+}
+
+// do sends the request with the access token of the credentials
+func (t *BigQuerySearch) do(ctx context.Context, method, url string, body *bytes.Reader, out any) error {
+	var req *http.Request
+	var err error
+	if body != nil {
+		req, err = http.NewRequestWithContext(ctx, method, url, body)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, method, url, nil)
+	}
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := gcp.Authorize(req, t.tokens); err != nil {
+		return err
+	}
+	return t.client.DoJSON(req, out)
+}
+
+func (t *BigQuerySearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the BigQuery backend, the query of the search is matched against the message, or applied when the query template uses it
+func (t *BigQuerySearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: t.config.Query == "" || strings.Contains(t.config.Query, "@query") || strings.Contains(t.config.Query, ".Query")}
+}
+
+// HealthCheck lists the datasets of the project
+func (t *BigQuerySearch) HealthCheck(ctx context.Context) error {
+	path := "/bigquery/v2/projects/" + url.PathEscape(t.config.Project) + "/datasets"
+	return t.do(ctx, http.MethodGet, t.client.URL(path, url.Values{"maxResults": {"1"}}), nil, nil)
+}
@@ -0,0 +1,174 @@
+package bigquery
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func serviceAccount(t *testing.T, tokenURL string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "acme",
+		"private_key_id": "1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"client_email":   "apm-hub@acme.iam.gserviceaccount.com",
+		"token_uri":      tokenURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRenderQuery(t *testing.T) {
+	tests := []struct {
+		name   string
+		config logs.BigQueryBackendConfig
+		params logs.SearchParams
+		want   string
+	}{
+		{
+			name:   "default",
+			config: logs.BigQueryBackendConfig{Table: "acme.logs.stdout"},
+			params: logs.SearchParams{},
+			want:   "SELECT timestamp AS timestamp, textPayload AS message FROM `acme.logs.stdout` WHERE timestamp BETWEEN @start AND @end ORDER BY timestamp DESC",
+		},
+		{
+			name: "partitions and labels",
+			config: logs.BigQueryBackendConfig{
+				Table:           "acme.logs.stdout",
+				PartitionColumn: "_PARTITIONTIME",
+				MessageColumn:   "jsonPayload.message",
+				LabelColumns:    map[string]string{"pod": "resource.labels.pod_name", "namespace": "resource.labels.namespace_name"},
+			},
+			params: logs.SearchParams{Query: "timeout", Limit: 10, Labels: map[string]string{"pod": "api-0"}},
+			want: "SELECT timestamp AS timestamp, jsonPayload.message AS message, CAST(resource.labels.namespace_name AS STRING) AS `namespace`, CAST(resource.labels.pod_name AS STRING) AS `pod` FROM `acme.logs.stdout` " +
+				"WHERE timestamp BETWEEN @start AND @end AND _PARTITIONTIME BETWEEN TIMESTAMP_TRUNC(@start, DAY) AND @end AND CAST(resource.labels.pod_name AS STRING) = @label_1 AND CONTAINS_SUBSTR(jsonPayload.message, @query) " +
+				"ORDER BY timestamp DESC LIMIT @limit",
+		},
+		{
+			name:   "template",
+			config: logs.BigQueryBackendConfig{Query: "SELECT * FROM `acme.logs.{{.Id}}` WHERE timestamp > @start"},
+			params: logs.SearchParams{Id: "stderr"},
+			want:   "SELECT * FROM `acme.logs.stderr` WHERE timestamp > @start",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := NewBigQuerySearchBackend(nil, &logs.BigQueryBackendConfig{
+				Project:         "acme",
+				Credentials:     &kommons.EnvVar{Value: serviceAccount(t, "http://localhost/token")},
+				Table:           tt.config.Table,
+				PartitionColumn: tt.config.PartitionColumn,
+				MessageColumn:   tt.config.MessageColumn,
+				LabelColumns:    tt.config.LabelColumns,
+				Query:           tt.config.Query,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := backend.RenderQuery(&tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderQuery() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	var request map[string]any
+	polled := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`))
+			return
+		case "/bigquery/v2/projects/acme/queries":
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Fatal(err)
+			}
+			_, _ = w.Write([]byte(`{"jobComplete": false, "jobReference": {"jobId": "job-1", "location": "EU"}}`))
+			return
+		case "/bigquery/v2/projects/acme/queries/job-1":
+			polled = true
+			if r.URL.Query().Get("location") != "EU" {
+				t.Errorf("location = %s, want EU", r.URL.Query().Get("location"))
+			}
+		default:
+			t.Fatalf("unexpected request %s", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("Authorization = %s", r.Header.Get("Authorization"))
+		}
+		_, _ = w.Write([]byte(`{
+			"jobComplete": true,
+			"schema": {"fields": [{"name": "timestamp", "type": "TIMESTAMP"}, {"name": "message", "type": "STRING"}, {"name": "pod", "type": "STRING"}]},
+			"rows": [
+				{"f": [{"v": "1.6829394015E9"}, {"v": "connection timeout"}, {"v": "api-0"}]},
+				{"f": [{"v": "1682939400"}, {"v": null}, {"v": "api-0"}]}
+			]
+		}`))
+	}))
+	defer server.Close()
+
+	backend, err := NewBigQuerySearchBackend(nil, &logs.BigQueryBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "bigquery"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		Credentials:    &kommons.EnvVar{Value: serviceAccount(t, server.URL+"/token")},
+		Project:        "acme",
+		Location:       "EU",
+		Table:          "acme.logs.stdout",
+		LabelColumns:   map[string]string{"pod": "resource.labels.pod_name"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "timeout", Limit: 10, Start: "2023-05-01T11:00:00Z", End: "2023-05-01T12:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !polled {
+		t.Errorf("the query results weren't polled")
+	}
+
+	parameters := map[string]string{}
+	for _, p := range request["queryParameters"].([]any) {
+		p := p.(map[string]any)
+		parameters[p["name"].(string)] = p["parameterValue"].(map[string]any)["value"].(string)
+	}
+	wantParameters := map[string]string{"start": "2023-05-01T11:00:00Z", "end": "2023-05-01T12:00:00Z", "query": "timeout", "limit": "10"}
+	if !reflect.DeepEqual(parameters, wantParameters) {
+		t.Errorf("queryParameters = %v, want %v", parameters, wantParameters)
+	}
+	if request["location"] != "EU" || request["useLegacySql"] != false {
+		t.Errorf("unexpected request %v", request)
+	}
+
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Time: "2023-05-01T11:10:01.5Z", Message: "connection timeout", Labels: map[string]string{"pod": "api-0", "source": "bigquery"}},
+		{Time: "2023-05-01T11:10:00Z", Labels: map[string]string{"pod": "api-0", "source": "bigquery"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+}
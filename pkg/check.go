@@ -0,0 +1,65 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/labstack/echo/v4"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/analytics"
+	"github.com/flanksource/apm-hub/pkg/audit"
+	"github.com/flanksource/apm-hub/pkg/guardrail"
+)
+
+// Check evaluates a log based check, e.g. for canary-checker.
+// The results are counted up to the limit of the search, which defaults to analytics.DefaultLimit
+// lowered to the maximum of the guardrails.
+func Check(c echo.Context) error {
+	cc := c.(*api.Context)
+	check := new(logs.LogCheck)
+	if err := c.Bind(check); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if check.Limit <= 0 {
+		check.Limit = guardrail.GlobalGuardrails.DefaultLimit(analytics.DefaultLimit)
+	}
+	check.SetDefaults()
+
+	grant, err := authorize(cc, &check.SearchParams)
+	if err != nil {
+		return err
+	}
+
+	var results []logs.Result
+	var backends []string
+	var errs []error
+	backendResults, _ := searchBackends(cc.Request().Context(), cc.Tenant, &check.SearchParams, grant)
+	for _, backendResult := range backendResults {
+		backends = append(backends, backendResult.Backend)
+		results = append(results, backendResult.Results...)
+		if backendResult.Err != nil {
+			errs = append(errs, fmt.Errorf("error searching %s: %w", backendResult.Backend, backendResult.Err))
+		}
+	}
+
+	// The check can't pass on the results of no backend at all
+	if len(backendResults) == 0 {
+		errs = append(errs, errors.New("no backend matched the search"))
+	}
+
+	result := check.Evaluate(results)
+	// The results of a failed or skipped backend are unknown, so the check can't pass on them
+	err = errors.Join(errs...)
+	if err != nil {
+		result.Pass = false
+		result.Message = fmt.Sprintf("%s: %v", result.Message, err)
+	}
+
+	logger.Debugf("[%s] => check pass=%v: %s", check.SearchParams, result.Pass, result.Message)
+	audit.GlobalAuditor.Record(newAuditEvent(cc, &check.SearchParams, backends, result.Count, err))
+	return cc.JSON(http.StatusOK, result)
+}
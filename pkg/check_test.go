@@ -0,0 +1,73 @@
+package pkg
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/health"
+)
+
+func runCheck(t *testing.T, body string) logs.LogCheckResult {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/check", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	if err := Check(&api.Context{Context: e.NewContext(req, rec)}); err != nil {
+		t.Fatal(err)
+	}
+
+	var result logs.LogCheckResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestCheck_FailingBackend(t *testing.T) {
+	defer func(backends []logs.SearchBackend) { logs.GlobalBackends = backends }(logs.GlobalBackends)
+	logs.GlobalBackends = []logs.SearchBackend{
+		{Name: "elastic", API: &fakeSearch{err: errors.New("connection refused")}},
+	}
+
+	result := runCheck(t, `{"query":"panic","maxCount":0}`)
+	if result.Pass || !strings.Contains(result.Message, "connection refused") {
+		t.Errorf("Check() = %+v, want a failure with the error of the backend", result)
+	}
+}
+
+func TestCheck_UnhealthyBackend(t *testing.T) {
+	defer func(backends []logs.SearchBackend) { logs.GlobalBackends = backends }(logs.GlobalBackends)
+	defer func(checker *health.Checker) { health.GlobalChecker = checker }(health.GlobalChecker)
+	logs.GlobalBackends = []logs.SearchBackend{
+		{Name: "elastic", API: &fakeSearch{}},
+	}
+	checker, err := health.NewChecker(logs.HealthConfig{FailureThreshold: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker.Record("elastic", errors.New("connection refused"))
+	health.GlobalChecker = checker
+
+	result := runCheck(t, `{"query":"panic","maxCount":0}`)
+	if result.Pass || !strings.Contains(result.Message, health.ErrUnhealthy.Error()) {
+		t.Errorf("Check() = %+v, want a failure for the skipped backend", result)
+	}
+}
+
+func TestCheck_NoBackend(t *testing.T) {
+	defer func(backends []logs.SearchBackend) { logs.GlobalBackends = backends }(logs.GlobalBackends)
+	logs.GlobalBackends = nil
+
+	result := runCheck(t, `{"query":"panic","maxCount":0}`)
+	if result.Pass {
+		t.Errorf("Check() = %+v, want a failure when no backend was searched", result)
+	}
+}
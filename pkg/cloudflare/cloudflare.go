@@ -0,0 +1,298 @@
+// Package cloudflare searches the HTTP request logs of the Cloudflare zones with the Logpull api,
+// or pushed to R2 by Logpush
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/archive"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const defaultURL = "https://api.cloudflare.com/client/v4"
+
+const (
+	// maxTimeRange is the widest time range of the Logpull requests
+	maxTimeRange = time.Hour
+
+	// delay is the time the requests take to be available to the Logpull api
+	delay = time.Minute
+
+	timestampField = "EdgeStartTimestamp"
+	rayIDField     = "RayID"
+)
+
+var defaultFields = []string{
+	"ClientIP", "ClientCountry", "ClientRequestHost", "ClientRequestMethod", "ClientRequestURI", "ClientRequestUserAgent",
+	"EdgeResponseStatus", "OriginResponseStatus", "CacheCacheStatus", "EdgeStartTimestamp", "EdgeEndTimestamp", "RayID",
+}
+
+type CloudflareSearch struct {
+	config *logs.CloudflareBackendConfig
+	client *rest.Client
+	fields []string
+	// bucket is the R2 bucket of the Logpush job, nil with the Logpull api
+	bucket archive.Bucket
+}
+
+func NewCloudflareSearchBackend(kClient *kommons.Client, config *logs.CloudflareBackendConfig) (*CloudflareSearch, error) {
+	if config.ZoneID == "" {
+		return nil, fmt.Errorf("the zone id is required")
+	}
+	if config.Sample != "" {
+		if sample, err := strconv.ParseFloat(config.Sample, 64); err != nil || sample <= 0 || sample > 1 {
+			return nil, fmt.Errorf("the sample must be a fraction between 0 and 1")
+		}
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// The time and the id of the results are always needed
+	fields := append([]string{}, config.Fields...)
+	if len(fields) == 0 {
+		fields = append(fields, defaultFields...)
+	}
+	for _, required := range []string{timestampField, rayIDField} {
+		if !contains(fields, required) {
+			fields = append(fields, required)
+		}
+	}
+
+	t := &CloudflareSearch{config: config, client: client, fields: fields}
+	if config.R2 != nil {
+		if t.bucket, err = newR2Bucket(kClient, conn.Namespace, config.R2); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Search returns the requests received by the zone in the time range matching the query of the search, chronologically.
+// The end of the time range of the Logpull api is moved back to the last requests available.
+// The last requests are returned up to the limit when the requests are filtered by the query, or read from R2.
+func (t *CloudflareSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	type entry struct {
+		time   int64
+		result logs.Result
+	}
+	var entries []entry
+
+	f := parseFilter(q.Query)
+	add := func(request map[string]any) {
+		r := t.result(request)
+		if !f.match(r) {
+			return
+		}
+		written, _ := timestampOf(request[timestampField])
+		entries = append(entries, entry{time: written.UnixNano(), result: r})
+	}
+
+	var err error
+	if t.bucket != nil {
+		err = t.readR2(ctx, q, add)
+	} else {
+		err = t.pull(ctx, q, f, add)
+	}
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].time < entries[j].time })
+	if q.Limit > 0 && int64(len(entries)) > q.Limit {
+		entries = entries[int64(len(entries))-q.Limit:]
+	}
+
+	results := logs.SearchResults{Total: len(entries)}
+	for _, e := range entries {
+		results.Results = append(results.Results, e.result)
+	}
+	return results, nil
+}
+
+// pull passes the requests of the time range of the Logpull api to fn, with the fields of the filter.
+// The count of the requests is limited by the api unless they're filtered.
+func (t *CloudflareSearch) pull(ctx context.Context, q *logs.SearchParams, f filter, fn func(request map[string]any)) error {
+	end := time.Now().Add(-delay)
+	if e := q.GetEnd(); e != nil && e.Before(end) {
+		end = *e
+	}
+	start := end.Add(-maxTimeRange)
+	if s := q.GetStart(); s != nil && s.After(start) {
+		start = *s
+	}
+	if !start.Before(end) {
+		return nil
+	}
+
+	fields := append([]string{}, t.fields...)
+	for _, field := range f.fields() {
+		if !contains(fields, field) {
+			fields = append(fields, field)
+		}
+	}
+	query := url.Values{}
+	query.Set("start", start.UTC().Format(time.RFC3339))
+	query.Set("end", end.UTC().Format(time.RFC3339))
+	query.Set("fields", strings.Join(fields, ","))
+	query.Set("timestamps", "unixnano")
+	if q.Limit > 0 && f.empty() {
+		query.Set("count", strconv.FormatInt(q.Limit, 10))
+	}
+	if t.config.Sample != "" {
+		query.Set("sample", t.config.Sample)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL(t.zonePath()+"/logs/received", query), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return decode(resp.Body, fn)
+}
+
+// decode passes the requests of the newline delimited json to fn
+func decode(r io.Reader, fn func(request map[string]any)) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	for {
+		var request map[string]any
+		if err := decoder.Decode(&request); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error decoding the requests: %w", err)
+		}
+		fn(request)
+	}
+}
+
+// timestampOf returns the time of the timestamp of a request, in unix nanoseconds or seconds, or RFC3339,
+// the formats of the timestamps of the Logpush jobs
+func timestampOf(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		// The timestamps in seconds are far below the nanoseconds of 1970-01-12
+		if n < 1e15 {
+			return time.Unix(n, 0), true
+		}
+		return time.Unix(0, n), true
+	case string:
+		ts, err := time.Parse(time.RFC3339Nano, v)
+		return ts, err == nil
+	}
+	return time.Time{}, false
+}
+
+// result renders the request with its method, url and status as message, e.g. GET example.com/login 200.
+// The other fields are labels, with the timestamps formatted as RFC3339.
+func (t *CloudflareSearch) result(request map[string]any) logs.Result {
+	r := logs.Result{Labels: make(map[string]string, len(request)+len(t.config.Labels))}
+	for field, value := range request {
+		switch v := value.(type) {
+		case nil:
+			continue
+		case json.Number, string:
+			if strings.HasSuffix(field, "Timestamp") {
+				if ts, ok := timestampOf(v); ok {
+					r.Labels[field] = ts.UTC().Format(time.RFC3339Nano)
+					continue
+				}
+			}
+			r.Labels[field] = fmt.Sprint(v)
+		default:
+			data, _ := json.Marshal(v)
+			r.Labels[field] = string(data)
+		}
+	}
+
+	r.Id = r.Labels[rayIDField]
+	r.Time = r.Labels[timestampField]
+	delete(r.Labels, rayIDField)
+	delete(r.Labels, timestampField)
+
+	// The jobs of Logpush may push the path of the requests without their uri
+	uri := r.Labels["ClientRequestURI"]
+	if uri == "" {
+		uri = r.Labels["ClientRequestPath"]
+	}
+	var message []string
+	for _, part := range []string{
+		r.Labels["ClientRequestMethod"],
+		r.Labels["ClientRequestHost"] + uri,
+		r.Labels["EdgeResponseStatus"],
+	} {
+		if part != "" {
+			message = append(message, part)
+		}
+	}
+	r.Message = strings.Join(message, " ")
+
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *CloudflareSearch) zonePath() string {
+	return "/zones/" + url.PathEscape(t.config.ZoneID)
+}
+
+func (t *CloudflareSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Cloudflare backend, the Logpull api returns up to an hour of requests
+func (t *CloudflareSearch) Capabilities() logs.Capabilities {
+	if t.bucket != nil {
+		return logs.Capabilities{Query: true}
+	}
+	return logs.Capabilities{Query: true, MaxTimeRange: maxTimeRange}
+}
+
+// HealthCheck lists the fields of the requests of the zone, or the first object of the R2 bucket
+func (t *CloudflareSearch) HealthCheck(ctx context.Context) error {
+	if t.bucket != nil {
+		err := t.bucket.List(ctx, t.r2Prefix(), func(o archive.Object) error {
+			return errFound
+		})
+		if errors.Is(err, errFound) {
+			return nil
+		}
+		return err
+	}
+	return t.client.Get(ctx, t.zonePath()+"/logs/received/fields", nil, nil)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
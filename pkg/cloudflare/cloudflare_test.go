@@ -0,0 +1,168 @@
+package cloudflare
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/archive"
+	"github.com/flanksource/kommons"
+)
+
+func TestCloudflareSearch(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" || r.URL.Path != "/zones/023e105f4ecef8ad9ca31a8372d0c353/logs/received" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		query := r.URL.Query()
+		queries = append(queries, query.Get("start")+"|"+query.Get("end")+"|"+query.Get("fields")+"|"+query.Get("count"))
+		w.Write([]byte(`{"ClientRequestMethod": "POST", "ClientRequestHost": "shop.example.com", "ClientRequestURI": "/checkout", "EdgeResponseStatus": 502, "EdgeStartTimestamp": 1682942400500000000, "RayID": "7c0b4c1b1f2a2b3c"}
+{"ClientRequestMethod": "GET", "ClientRequestHost": "shop.example.com", "ClientRequestURI": "/", "EdgeResponseStatus": 200, "EdgeStartTimestamp": 1682942400000000000, "RayID": "7c0b4c1b1f2a2b3b", "ClientIP": null}
+`))
+	}))
+	defer server.Close()
+
+	backend, err := NewCloudflareSearchBackend(nil, &logs.CloudflareBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "cloudflare"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Token: &kommons.EnvVar{Value: "secret"}},
+		ZoneID:         "023e105f4ecef8ad9ca31a8372d0c353",
+		Fields:         []string{"ClientRequestMethod", "ClientRequestHost", "ClientRequestURI", "EdgeResponseStatus"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Start: "2023-05-01T11:30:00Z", End: "2023-05-01T12:30:00Z", Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Id: "7c0b4c1b1f2a2b3b", Time: "2023-05-01T12:00:00Z", Message: "GET shop.example.com/ 200", Labels: map[string]string{
+			"ClientRequestMethod": "GET", "ClientRequestHost": "shop.example.com", "ClientRequestURI": "/", "EdgeResponseStatus": "200", "source": "cloudflare",
+		}},
+		{Id: "7c0b4c1b1f2a2b3c", Time: "2023-05-01T12:00:00.5Z", Message: "POST shop.example.com/checkout 502", Labels: map[string]string{
+			"ClientRequestMethod": "POST", "ClientRequestHost": "shop.example.com", "ClientRequestURI": "/checkout", "EdgeResponseStatus": "502", "source": "cloudflare",
+		}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	// The searches are limited to an hour, ending a minute ago at the latest
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Start: "1d"}); err != nil {
+		t.Fatal(err)
+	}
+	wantFields := "ClientRequestMethod,ClientRequestHost,ClientRequestURI,EdgeResponseStatus,EdgeStartTimestamp,RayID"
+	if queries[0] != "2023-05-01T11:30:00Z|2023-05-01T12:30:00Z|"+wantFields+"|100" {
+		t.Errorf("query = %s", queries[0])
+	}
+	if len(queries) != 2 {
+		t.Fatalf("queries = %v, want 2 queries", queries)
+	}
+	parts := strings.Split(queries[1], "|")
+	start, _ := time.Parse(time.RFC3339, parts[0])
+	end, _ := time.Parse(time.RFC3339, parts[1])
+	if end.Sub(start) != time.Hour || time.Since(end) < time.Minute {
+		t.Errorf("query = %s, want the hour ending a minute ago", queries[1])
+	}
+
+	// The filtered requests aren't counted by the api, and the fields of the filter are requested
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Query: "status>=500 cache=* checkout", Start: "2023-05-01T11:30:00Z", End: "2023-05-01T12:30:00Z", Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 0 {
+		t.Errorf("Search() = %+v, want no requests without a cache status", res)
+	}
+	if queries[2] != "2023-05-01T11:30:00Z|2023-05-01T12:30:00Z|"+wantFields+",CacheCacheStatus|" {
+		t.Errorf("query = %s", queries[2])
+	}
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Query: "status>=500 path!=/api/* CHECKOUT", Start: "2023-05-01T11:30:00Z", End: "2023-05-01T12:30:00Z", Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 1 || res.Results[0].Id != "7c0b4c1b1f2a2b3c" {
+		t.Errorf("Search() = %+v, want the request failing", res)
+	}
+
+	if _, err := NewCloudflareSearchBackend(nil, &logs.CloudflareBackendConfig{ZoneID: "zone", Sample: "2"}); err == nil {
+		t.Errorf("expected an error with a sample over 1")
+	}
+}
+
+// memoryBucket is an archive.Bucket of objects in memory
+type memoryBucket map[string][]byte
+
+func (b memoryBucket) Name() string {
+	return "logpush"
+}
+
+func (b memoryBucket) List(ctx context.Context, prefix string, fn func(o archive.Object) error) error {
+	for key := range b {
+		if strings.HasPrefix(key, prefix) {
+			if err := fn(archive.Object{Key: key}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b memoryBucket) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(b[key])), nil
+}
+
+func gzipped(t *testing.T, content string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCloudflareR2Search(t *testing.T) {
+	bucket := memoryBucket{
+		"http/20230430/20230430T235500Z_20230430T235959Z_a1.log.gz": gzipped(t, `{"ClientRequestMethod": "GET", "ClientRequestPath": "/api/cart", "EdgeResponseStatus": 503, "EdgeStartTimestamp": "2023-04-30T23:59:58Z", "RayID": "a1"}
+`),
+		"http/20230501/20230501T000000Z_20230501T000459Z_b2.log.gz": gzipped(t, `{"ClientRequestMethod": "GET", "ClientRequestPath": "/api/cart", "EdgeResponseStatus": 500, "EdgeStartTimestamp": "2023-05-01T00:00:02Z", "RayID": "b2"}
+{"ClientRequestMethod": "GET", "ClientRequestPath": "/", "EdgeResponseStatus": 502, "EdgeStartTimestamp": "2023-05-01T00:00:03Z", "RayID": "b3"}
+{"ClientRequestMethod": "POST", "ClientRequestPath": "/api/pay", "EdgeResponseStatus": 504, "EdgeStartTimestamp": "2023-05-01T00:00:01Z", "RayID": "b1"}
+{"ClientRequestMethod": "GET", "ClientRequestPath": "/api/cart", "EdgeResponseStatus": 200, "EdgeStartTimestamp": "2023-05-01T00:00:04Z", "RayID": "b4"}
+`),
+		// Outside of the time range of the search
+		"http/20230501/20230501T010000Z_20230501T010459Z_c1.log.gz": []byte("not json"),
+		"http/20230501/ownership-challenge.txt":                     []byte("not json"),
+	}
+	backend := &CloudflareSearch{config: &logs.CloudflareBackendConfig{R2: &logs.CloudflareR2Config{Path: "http"}}, bucket: bucket}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query: "status>=500 path=/API/*", Start: "2023-04-30T23:59:00Z", End: "2023-05-01T00:30:00Z", Limit: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Id: "b1", Time: "2023-05-01T00:00:01Z", Message: "POST /api/pay 504", Labels: map[string]string{"ClientRequestMethod": "POST", "ClientRequestPath": "/api/pay", "EdgeResponseStatus": "504"}},
+		{Id: "b2", Time: "2023-05-01T00:00:02Z", Message: "GET /api/cart 500", Labels: map[string]string{"ClientRequestMethod": "GET", "ClientRequestPath": "/api/cart", "EdgeResponseStatus": "500"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+	if err := backend.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() = %v", err)
+	}
+}
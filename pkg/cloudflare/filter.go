@@ -0,0 +1,159 @@
+package cloudflare
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// aliases are the fields of the requests filtered by the short names of the query, e.g. status>=500
+var aliases = map[string]string{
+	"status":        "EdgeResponseStatus",
+	"origin_status": "OriginResponseStatus",
+	"path":          "ClientRequestPath",
+	"uri":           "ClientRequestURI",
+	"host":          "ClientRequestHost",
+	"method":        "ClientRequestMethod",
+	"ip":            "ClientIP",
+	"country":       "ClientCountry",
+	"user_agent":    "ClientRequestUserAgent",
+	"cache":         "CacheCacheStatus",
+	"ray":           rayIDField,
+}
+
+// conditionRegexp matches the conditions of the query, e.g. status>=500, path=/api/* or EdgeResponseStatus!=200
+var conditionRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)(!=|>=|<=|=|:|>|<)(.+)$`)
+
+type condition struct {
+	field string
+	op    string
+	value string
+	// glob matches the values with a * wildcard, case insensitively
+	glob *regexp.Regexp
+}
+
+// filter is the filter of the requests of the query of a search, its conditions on the fields of the requests
+// and its other words, matched in the messages of the requests
+type filter struct {
+	conditions []condition
+	words      []string
+}
+
+func parseFilter(query string) filter {
+	var f filter
+	for _, term := range strings.Fields(query) {
+		match := conditionRegexp.FindStringSubmatch(term)
+		if match == nil {
+			f.words = append(f.words, strings.ToLower(term))
+			continue
+		}
+
+		c := condition{field: match[1], op: match[2], value: match[3]}
+		if field, ok := aliases[strings.ToLower(c.field)]; ok {
+			c.field = field
+		}
+		if c.op == ":" {
+			c.op = "="
+		}
+		if (c.op == "=" || c.op == "!=") && strings.Contains(c.value, "*") {
+			parts := strings.Split(c.value, "*")
+			for i := range parts {
+				parts[i] = regexp.QuoteMeta(parts[i])
+			}
+			c.glob = regexp.MustCompile("(?i)^" + strings.Join(parts, ".*") + "$")
+		}
+		f.conditions = append(f.conditions, c)
+	}
+	return f
+}
+
+func (f filter) empty() bool {
+	return len(f.conditions) == 0 && len(f.words) == 0
+}
+
+// fields returns the fields of the requests of the conditions
+func (f filter) fields() []string {
+	var fields []string
+	for _, c := range f.conditions {
+		if !contains(fields, c.field) {
+			fields = append(fields, c.field)
+		}
+	}
+	return fields
+}
+
+// match returns true when the request of the result matches every condition and word of the filter
+func (f filter) match(r logs.Result) bool {
+	for _, c := range f.conditions {
+		var value string
+		var ok bool
+		switch c.field {
+		case rayIDField:
+			value, ok = r.Id, r.Id != ""
+		case timestampField:
+			value, ok = r.Time, r.Time != ""
+		default:
+			value, ok = r.Labels[c.field]
+		}
+		if !ok {
+			if c.op == "!=" {
+				continue
+			}
+			return false
+		}
+		if !c.match(value) {
+			return false
+		}
+	}
+
+	message := strings.ToLower(r.Message)
+	for _, word := range f.words {
+		if !strings.Contains(message, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c condition) match(value string) bool {
+	switch c.op {
+	case "=":
+		if c.glob != nil {
+			return c.glob.MatchString(value)
+		}
+		return strings.EqualFold(value, c.value)
+	case "!=":
+		if c.glob != nil {
+			return !c.glob.MatchString(value)
+		}
+		return !strings.EqualFold(value, c.value)
+	}
+
+	// The numbers are compared as numbers, and the other values, e.g. the timestamps, as strings
+	a, errA := strconv.ParseFloat(value, 64)
+	b, errB := strconv.ParseFloat(c.value, 64)
+	cmp := strings.Compare(value, c.value)
+	if errA == nil && errB == nil {
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		default:
+			cmp = 0
+		}
+	}
+	switch c.op {
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package cloudflare
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/archive"
+	"github.com/flanksource/kommons"
+)
+
+const (
+	// defaultR2Range is the time range read from R2 when the search has no start
+	defaultR2Range = time.Hour
+
+	// objectTimeLayout is the layout of the times of the objects pushed by Logpush
+	objectTimeLayout = "20060102T150405Z"
+)
+
+// objectRegexp matches the keys of the objects pushed by Logpush, e.g. 20230501/20230501T120000Z_20230501T120500Z_7f3a1c2d.log.gz,
+// capturing the times of their first and last requests
+var objectRegexp = regexp.MustCompile(`(\d{8}T\d{6}Z)_(\d{8}T\d{6}Z)_[^/]*$`)
+
+var errFound = errors.New("found")
+
+func newR2Bucket(kClient *kommons.Client, namespace string, config *logs.CloudflareR2Config) (archive.Bucket, error) {
+	if config.AccountID == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("the account id and bucket of R2 are required")
+	}
+	if config.AccessKeyID == nil || config.SecretAccessKey == nil {
+		return nil, fmt.Errorf("the access key id and secret access key of R2 are required")
+	}
+	_, accessKeyID, err := kClient.GetEnvValue(*config.AccessKeyID, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the access key id: %w", err)
+	}
+	_, secretAccessKey, err := kClient.GetEnvValue(*config.SecretAccessKey, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the secret access key: %w", err)
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = "https://" + config.AccountID + ".r2.cloudflarestorage.com"
+	}
+	client := s3.New(s3.Options{
+		Region:           "auto",
+		Credentials:      aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, "")),
+		EndpointResolver: s3.EndpointResolverFromURL(endpoint),
+		UsePathStyle:     true,
+	})
+	return archive.NewS3Bucket(client, config.Bucket), nil
+}
+
+// r2Prefix returns the prefix of the objects of the Logpush job
+func (t *CloudflareSearch) r2Prefix() string {
+	if t.config.R2.Path == "" {
+		return ""
+	}
+	return strings.TrimSuffix(t.config.R2.Path, "/") + "/"
+}
+
+// readR2 passes the requests of the time range of the search pushed to R2 to fn.
+// Only the objects of the days of the time range whose requests overlap it are read.
+func (t *CloudflareSearch) readR2(ctx context.Context, q *logs.SearchParams, fn func(request map[string]any)) error {
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+	start := end.Add(-defaultR2Range)
+	if s := q.GetStart(); s != nil {
+		start = *s
+	}
+	if start.After(end) {
+		return nil
+	}
+
+	var keys []string
+	for day := start.UTC().Truncate(24 * time.Hour); !day.After(end); day = day.Add(24 * time.Hour) {
+		err := t.bucket.List(ctx, t.r2Prefix()+day.Format("20060102")+"/", func(o archive.Object) error {
+			match := objectRegexp.FindStringSubmatch(o.Key)
+			if match == nil {
+				return nil
+			}
+			first, err := time.Parse(objectTimeLayout, match[1])
+			if err != nil {
+				return nil
+			}
+			last, err := time.Parse(objectTimeLayout, match[2])
+			if err != nil {
+				return nil
+			}
+			// The times of the keys are truncated to the second
+			if last.Add(time.Second).Before(start) || first.After(end) {
+				return nil
+			}
+			keys = append(keys, o.Key)
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error listing the objects of %s: %w", t.bucket.Name(), err)
+		}
+	}
+
+	for _, key := range keys {
+		err := t.readObject(ctx, key, func(request map[string]any) {
+			if written, ok := timestampOf(request[timestampField]); ok && (written.Before(start) || written.After(end)) {
+				return
+			}
+			fn(request)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readObject passes the requests of the object to fn, decompressing the gzipped objects
+func (t *CloudflareSearch) readObject(ctx context.Context, key string, fn func(request map[string]any)) error {
+	body, err := t.bucket.Open(ctx, key)
+	if err != nil {
+		return fmt.Errorf("error reading %s: %w", key, err)
+	}
+	defer body.Close()
+
+	reader := bufio.NewReader(body)
+	var content io.Reader = reader
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return fmt.Errorf("error decompressing %s: %w", key, err)
+		}
+		defer gz.Close()
+		content = gz
+	}
+	if err := decode(content, fn); err != nil {
+		return fmt.Errorf("error reading %s: %w", key, err)
+	}
+	return nil
+}
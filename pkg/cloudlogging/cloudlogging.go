@@ -0,0 +1,220 @@
+// Package cloudlogging searches the log entries of Google Cloud Logging with the entries.list api
+package cloudlogging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/gcp"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+	"golang.org/x/oauth2"
+)
+
+const (
+	defaultURL = "https://logging.googleapis.com"
+
+	scope = "https://www.googleapis.com/auth/logging.read"
+
+	// maxPageSize is the largest page of entries returned by the api
+	maxPageSize = 1000
+)
+
+type CloudLoggingSearch struct {
+	config   *logs.GoogleCloudLoggingBackendConfig
+	client   *rest.Client
+	tokens   oauth2.TokenSource
+	template *template.Template
+}
+
+func NewCloudLoggingSearchBackend(kClient *kommons.Client, config *logs.GoogleCloudLoggingBackendConfig) (*CloudLoggingSearch, error) {
+	if config.Project == "" {
+		return nil, fmt.Errorf("the project is required")
+	}
+	template, err := template.New("filter").Parse(config.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the filter template: %w", err)
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := gcp.NewTokenSource(kClient, conn.Namespace, config.Credentials, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CloudLoggingSearch{config: config, client: client, tokens: tokens, template: template}, nil
+}
+
+// RenderFilter renders the filter template for the given search params, restricted to the time range of the search
+func (t *CloudLoggingSearch) RenderFilter(q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing the filter template: %w", err)
+	}
+
+	var filters []string
+	if filter := strings.TrimSpace(buf.String()); filter != "" {
+		filters = append(filters, "("+filter+")")
+	}
+	if start := q.GetStart(); start != nil {
+		filters = append(filters, fmt.Sprintf(`timestamp>="%s"`, start.UTC().Format(time.RFC3339Nano)))
+	}
+	if end := q.GetEnd(); end != nil {
+		filters = append(filters, fmt.Sprintf(`timestamp<="%s"`, end.UTC().Format(time.RFC3339Nano)))
+	}
+	return strings.Join(filters, " AND "), nil
+}
+
+// Search lists the last entries matching the filter, up to the limit of the search, and returns them chronologically.
+// The next page lists the entries before them.
+func (t *CloudLoggingSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	filter, err := t.RenderFilter(q)
+	if err != nil {
+		return results, err
+	}
+	request := map[string]any{
+		"resourceNames": []string{"projects/" + t.config.Project},
+		"filter":        filter,
+		"orderBy":       "timestamp desc",
+	}
+	if q.Limit > 0 && q.Limit <= maxPageSize {
+		request["pageSize"] = q.Limit
+	}
+	if q.Page != "" {
+		request["pageToken"] = q.Page
+	}
+	data, err := json.Marshal(request)
+	if err != nil {
+		return results, fmt.Errorf("error encoding the request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.client.URL("/v2/entries:list", nil), bytes.NewReader(data))
+	if err != nil {
+		return results, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := gcp.Authorize(req, t.tokens); err != nil {
+		return results, err
+	}
+
+	var res struct {
+		Entries       []entry `json:"entries"`
+		NextPageToken string  `json:"nextPageToken"`
+	}
+	if err := t.client.DoJSON(req, &res); err != nil {
+		return results, fmt.Errorf("error listing the entries: %w", err)
+	}
+
+	for i := len(res.Entries) - 1; i >= 0; i-- {
+		results.Results = append(results.Results, t.result(res.Entries[i]))
+	}
+	results.Total = len(results.Results)
+	results.NextPage = res.NextPageToken
+	return results, nil
+}
+
+type entry struct {
+	LogName  string `json:"logName"`
+	InsertID string `json:"insertId"`
+	Resource struct {
+		Type   string            `json:"type"`
+		Labels map[string]string `json:"labels"`
+	} `json:"resource"`
+	Timestamp    string            `json:"timestamp"`
+	Severity     string            `json:"severity"`
+	Labels       map[string]string `json:"labels"`
+	TextPayload  string            `json:"textPayload"`
+	JSONPayload  map[string]any    `json:"jsonPayload"`
+	ProtoPayload *auditLog         `json:"protoPayload"`
+}
+
+// auditLog is the payload of the Cloud Audit Logs
+type auditLog struct {
+	MethodName         string `json:"methodName"`
+	ResourceName       string `json:"resourceName"`
+	AuthenticationInfo struct {
+		PrincipalEmail string `json:"principalEmail"`
+	} `json:"authenticationInfo"`
+	Status *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+// result returns the result of the entry, with the message of its text payload, the message field of its json payload,
+// or the principal, method and resource of its audit log
+func (t *CloudLoggingSearch) result(e entry) logs.Result {
+	r := logs.Result{Id: e.InsertID, Labels: make(map[string]string, len(e.Resource.Labels)+len(e.Labels)+len(t.config.Labels)+3)}
+	if ts, err := time.Parse(time.RFC3339Nano, e.Timestamp); err == nil {
+		r.Time = ts.UTC().Format(time.RFC3339Nano)
+	}
+
+	for k, v := range e.Resource.Labels {
+		r.Labels[k] = v
+	}
+	for k, v := range e.Labels {
+		r.Labels[k] = v
+	}
+	if e.Resource.Type != "" {
+		r.Labels["resource_type"] = e.Resource.Type
+	}
+	if e.Severity != "" {
+		r.Labels["severity"] = e.Severity
+	}
+	if _, log, ok := strings.Cut(e.LogName, "/logs/"); ok {
+		if unescaped, err := url.PathUnescape(log); err == nil {
+			log = unescaped
+		}
+		r.Labels["log"] = log
+	}
+
+	switch {
+	case e.TextPayload != "":
+		r.Message = strings.TrimRight(e.TextPayload, "\n")
+	case e.JSONPayload != nil:
+		if message, ok := e.JSONPayload["message"].(string); ok {
+			r.Message = message
+		} else {
+			data, _ := json.Marshal(e.JSONPayload)
+			r.Message = string(data)
+		}
+	case e.ProtoPayload != nil:
+		p := e.ProtoPayload
+		r.Message = strings.TrimSpace(p.AuthenticationInfo.PrincipalEmail + " " + p.MethodName + " " + p.ResourceName)
+		if p.Status != nil && p.Status.Code != 0 {
+			r.Message += fmt.Sprintf(": %d %s", p.Status.Code, p.Status.Message)
+		}
+	}
+
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *CloudLoggingSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Cloud Logging backend, the query of the search is applied when the filter template uses it
+func (t *CloudLoggingSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: strings.Contains(t.config.Filter, ".Query"), Pagination: true}
+}
@@ -0,0 +1,116 @@
+package cloudlogging
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func serviceAccount(t *testing.T, tokenURL string) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(map[string]string{
+		"type":           "service_account",
+		"project_id":     "acme",
+		"private_key_id": "1",
+		"private_key":    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+		"client_email":   "apm-hub@acme.iam.gserviceaccount.com",
+		"token_uri":      tokenURL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+const entriesResponse = `{"entries": [
+	{
+		"insertId": "2", "logName": "projects/acme/logs/cloudaudit.googleapis.com%2Factivity", "timestamp": "2023-05-01T12:00:02.5Z", "severity": "NOTICE",
+		"resource": {"type": "k8s_cluster", "labels": {"cluster_name": "prod", "location": "europe-west1"}},
+		"protoPayload": {"methodName": "io.k8s.core.v1.secrets.get", "resourceName": "core/v1/namespaces/default/secrets/db", "authenticationInfo": {"principalEmail": "alice@acme.com"}, "status": {"code": 7, "message": "PERMISSION_DENIED"}}
+	},
+	{
+		"insertId": "1", "logName": "projects/acme/logs/container.googleapis.com%2Fapiserver", "timestamp": "2023-05-01T12:00:01Z", "severity": "ERROR",
+		"resource": {"type": "k8s_control_plane_component", "labels": {"cluster_name": "prod", "component_name": "apiserver"}},
+		"labels": {"compute.googleapis.com/resource_name": "gke-prod"},
+		"jsonPayload": {"message": "etcd request timed out"}
+	}
+], "nextPageToken": "next"}`
+
+func TestCloudLoggingSearch(t *testing.T) {
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`))
+		case "/v2/entries:list":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Fatal(err)
+			}
+			_, _ = w.Write([]byte(entriesResponse))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewCloudLoggingSearchBackend(nil, &logs.GoogleCloudLoggingBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "gke"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		Credentials:    &kommons.EnvVar{Value: serviceAccount(t, server.URL+"/token")},
+		Project:        "acme",
+		Filter:         `resource.labels.cluster_name="prod"{{with .Query}} {{printf "%q" .}}{{end}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !backend.Capabilities().Query {
+		t.Error("the filter template uses the query")
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "timed out", Start: "2023-05-01T12:00:00Z", Limit: 2, Page: "page-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, NextPage: "next", Results: []logs.Result{
+		{Id: "1", Time: "2023-05-01T12:00:01Z", Message: "etcd request timed out", Labels: map[string]string{
+			"cluster_name": "prod", "component_name": "apiserver", "compute.googleapis.com/resource_name": "gke-prod",
+			"resource_type": "k8s_control_plane_component", "severity": "ERROR", "log": "container.googleapis.com/apiserver", "source": "gke",
+		}},
+		{Id: "2", Time: "2023-05-01T12:00:02.5Z", Message: "alice@acme.com io.k8s.core.v1.secrets.get core/v1/namespaces/default/secrets/db: 7 PERMISSION_DENIED", Labels: map[string]string{
+			"cluster_name": "prod", "location": "europe-west1", "resource_type": "k8s_cluster", "severity": "NOTICE",
+			"log": "cloudaudit.googleapis.com/activity", "source": "gke",
+		}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantRequest := map[string]any{
+		"resourceNames": []any{"projects/acme"},
+		"filter":        `(resource.labels.cluster_name="prod" "timed out") AND timestamp>="2023-05-01T12:00:00Z"`,
+		"orderBy":       "timestamp desc",
+		"pageSize":      float64(2),
+		"pageToken":     "page-1",
+	}
+	if !reflect.DeepEqual(request, wantRequest) {
+		t.Errorf("request = %v, want %v", request, wantRequest)
+	}
+}
@@ -1,36 +1,83 @@
 package cloudwatch
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"text/template"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
 	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
 )
 
-func NewCloudWatchSearchBackend(config *logs.CloudWatchBackendConfig, client *cloudwatchlogs.Client) *cloudWatchSearch {
-	return &cloudWatchSearch{
-		client: client,
-		config: config,
+// pollInterval is the interval between the polls of the status of the queries
+var pollInterval = time.Second
+
+func NewCloudWatchSearchBackend(config *logs.CloudWatchBackendConfig, client *cloudwatchlogs.Client) (*cloudWatchSearch, error) {
+	template, err := template.New("query").Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the query template: %w", err)
+	}
+
+	timeout := 5 * time.Minute
+	if config.Timeout != "" {
+		d, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the query timeout: %w", err)
+		}
+		timeout = time.Duration(d)
 	}
+
+	return &cloudWatchSearch{
+		client:   client,
+		config:   config,
+		template: template,
+		timeout:  timeout,
+	}, nil
 }
 
 type cloudWatchSearch struct {
-	client *cloudwatchlogs.Client
-	config *logs.CloudWatchBackendConfig
+	client   *cloudwatchlogs.Client
+	config   *logs.CloudWatchBackendConfig
+	template *template.Template
+	timeout  time.Duration
 }
 
 func (t *cloudWatchSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
 	return t.config.CommonBackend.Routes.MatchRoute(q)
 }
 
-func (t *cloudWatchSearch) Search(q *logs.SearchParams) (logs.SearchResults, error) {
+// Capabilities of CloudWatch, the query of the search is applied when the insights query template uses it
+func (t *cloudWatchSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: strings.Contains(t.config.Query, ".Query")}
+}
+
+// RenderQuery renders the insights query template for the given search params.
+func (t *cloudWatchSearch) RenderQuery(q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing the query template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// Search runs the insights query, and waits for it to complete until the deadline of the search,
+// or the timeout of the backend when it's earlier.
+func (t *cloudWatchSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+
 	logFilter := &cloudwatchlogs.StartQueryInput{
 		LogGroupName: &t.config.LogGroup,
 		Limit:        ptr(int32(q.Limit)),
-		QueryString:  &t.config.Query,
+		QueryString:  &query,
 	}
 
 	if q.GetStart() != nil {
@@ -43,13 +90,16 @@ func (t *cloudWatchSearch) Search(q *logs.SearchParams) (logs.SearchResults, err
 		logFilter.EndTime = ptr(time.Now().UnixMilli()) // end time is a required field
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
 	var result logs.SearchResults
-	queryOutput, err := t.client.StartQuery(context.Background(), logFilter)
+	queryOutput, err := t.client.StartQuery(ctx, logFilter)
 	if err != nil {
 		return result, err
 	}
 
-	queryResult, err := t.getQueryResults(queryOutput.QueryId)
+	queryResult, err := t.getQueryResults(ctx, queryOutput.QueryId)
 	if err != nil {
 		return result, err
 	}
@@ -59,7 +109,10 @@ func (t *cloudWatchSearch) Search(q *logs.SearchParams) (logs.SearchResults, err
 	result.Results = make([]logs.Result, 0, len(queryResult.Results))
 	for _, fields := range queryResult.Results {
 		var event = logs.Result{
-			Labels: t.config.Labels,
+			Labels: make(map[string]string, len(t.config.Labels)),
+		}
+		for k, v := range t.config.Labels {
+			event.Labels[k] = v
 		}
 
 		for _, field := range fields {
@@ -83,14 +136,19 @@ func (t *cloudWatchSearch) Search(q *logs.SearchParams) (logs.SearchResults, err
 	return result, nil
 }
 
-func (t *cloudWatchSearch) getQueryResults(queryID *string) (*cloudwatchlogs.GetQueryResultsOutput, error) {
+// getQueryResults waits for the query to complete.
+// The query is stopped when the context is done before, e.g. when the deadline is exceeded.
+func (t *cloudWatchSearch) getQueryResults(ctx context.Context, queryID *string) (*cloudwatchlogs.GetQueryResultsOutput, error) {
 	input := &cloudwatchlogs.GetQueryResultsInput{
 		QueryId: queryID,
 	}
 
 	for {
-		resp, err := t.client.GetQueryResults(context.Background(), input)
+		resp, err := t.client.GetQueryResults(ctx, input)
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, t.stopQuery(ctx, queryID)
+			}
 			return nil, err
 		}
 
@@ -106,11 +164,25 @@ func (t *cloudWatchSearch) getQueryResults(queryID *string) (*cloudwatchlogs.Get
 		default:
 			// Might be scheduling or running.
 			// Wait before retrying.
-			time.Sleep(time.Second)
+			select {
+			case <-time.After(pollInterval):
+			case <-ctx.Done():
+				return nil, t.stopQuery(ctx, queryID)
+			}
 		}
 	}
 }
 
+// stopQuery stops the query once the context is done, as it keeps running in Cloudwatch otherwise,
+// and returns the error of the context
+func (t *cloudWatchSearch) stopQuery(ctx context.Context, queryID *string) error {
+	_, _ = t.client.StopQuery(context.Background(), &cloudwatchlogs.StopQueryInput{QueryId: queryID})
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("the query didn't complete before the deadline: %w", ctx.Err())
+	}
+	return ctx.Err()
+}
+
 // timestamp layout returned by Cloudwatch
 const timestampLayout = "2006-01-02 15:04:05.000"
 
@@ -0,0 +1,124 @@
+package cloudwatch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// fakeCloudWatch answers the insights api, the query completing after the given number of polls
+type fakeCloudWatch struct {
+	polls int
+
+	lock    sync.Mutex
+	query   string
+	stopped bool
+}
+
+func (t *fakeCloudWatch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+
+	switch strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "Logs_20140328.") {
+	case "StartQuery":
+		t.query, _ = body["queryString"].(string)
+		_, _ = w.Write([]byte(`{"queryId":"q1"}`))
+	case "GetQueryResults":
+		if t.polls > 0 {
+			t.polls--
+			_, _ = w.Write([]byte(`{"status":"Running"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"Complete","statistics":{"recordsMatched":1},"results":[[
+			{"field":"@timestamp","value":"2023-05-01 12:00:00.000"},
+			{"field":"@message","value":"connection timeout"},
+			{"field":"@ptr","value":"p1"},
+			{"field":"level","value":"error"}
+		]]}`))
+	case "StopQuery":
+		t.stopped = true
+		_, _ = w.Write([]byte(`{"success":true}`))
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func newTestBackend(t *testing.T, fake *fakeCloudWatch, config *logs.CloudWatchBackendConfig) *cloudWatchSearch {
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client := cloudwatchlogs.New(cloudwatchlogs.Options{
+		Region:           "us-east-1",
+		Credentials:      aws.AnonymousCredentials{},
+		EndpointResolver: cloudwatchlogs.EndpointResolverFromURL(server.URL),
+	})
+	backend, err := NewCloudWatchSearchBackend(config, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backend
+}
+
+func TestCloudWatchSearch_Search(t *testing.T) {
+	defer func() { pollInterval = time.Second }()
+	pollInterval = time.Millisecond
+
+	fake := &fakeCloudWatch{polls: 2}
+	backend := newTestBackend(t, fake, &logs.CloudWatchBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"account": "prod"}},
+		LogGroup:      "/app",
+		Query:         "fields @timestamp, @message | filter @message like /{{.Query}}/",
+	})
+	if !backend.Capabilities().Query {
+		t.Errorf("Capabilities().Query = false, want true as the template uses the query")
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "timeout", Start: "1h", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.query != "fields @timestamp, @message | filter @message like /timeout/" {
+		t.Errorf("the query sent = %q", fake.query)
+	}
+
+	want := logs.SearchResults{Total: 1, Results: []logs.Result{
+		{Id: "p1", Time: "2023-05-01T12:00:00Z", Message: "connection timeout", Labels: map[string]string{"account": "prod", "level": "error"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+	if _, ok := backend.config.Labels["level"]; ok {
+		t.Errorf("the labels of the results were added to the labels of the backend")
+	}
+}
+
+func TestCloudWatchSearch_SearchTimeout(t *testing.T) {
+	defer func() { pollInterval = time.Second }()
+	pollInterval = time.Millisecond
+
+	fake := &fakeCloudWatch{polls: 1 << 30}
+	backend := newTestBackend(t, fake, &logs.CloudWatchBackendConfig{LogGroup: "/app", Query: "fields @message", Timeout: "50ms"})
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Start: "1h"}); err == nil || !strings.Contains(err.Error(), "deadline") {
+		t.Errorf("Search() error = %v, want the deadline to be exceeded", err)
+	}
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+	if !fake.stopped {
+		t.Errorf("the query wasn't stopped")
+	}
+}
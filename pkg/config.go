@@ -6,17 +6,65 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	awsathena "github.com/aws/aws-sdk-go-v2/service/athena"
 	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
 	v8 "github.com/elastic/go-elasticsearch/v8"
 	"github.com/flanksource/apm-hub/api/logs"
 	"github.com/flanksource/apm-hub/db"
+	"github.com/flanksource/apm-hub/pkg/alibaba"
+	"github.com/flanksource/apm-hub/pkg/appdynamics"
+	"github.com/flanksource/apm-hub/pkg/archive"
+	"github.com/flanksource/apm-hub/pkg/athena"
+	"github.com/flanksource/apm-hub/pkg/betterstack"
+	"github.com/flanksource/apm-hub/pkg/bigquery"
+	"github.com/flanksource/apm-hub/pkg/cloudflare"
+	"github.com/flanksource/apm-hub/pkg/cloudlogging"
 	"github.com/flanksource/apm-hub/pkg/cloudwatch"
+	"github.com/flanksource/apm-hub/pkg/controlplane"
+	"github.com/flanksource/apm-hub/pkg/coralogix"
+	"github.com/flanksource/apm-hub/pkg/cri"
+	"github.com/flanksource/apm-hub/pkg/docker"
+	"github.com/flanksource/apm-hub/pkg/dynatrace"
+	"github.com/flanksource/apm-hub/pkg/elasticapm"
 	"github.com/flanksource/apm-hub/pkg/elasticsearch"
+	"github.com/flanksource/apm-hub/pkg/eventhubs"
 	"github.com/flanksource/apm-hub/pkg/files"
+	"github.com/flanksource/apm-hub/pkg/github"
+	"github.com/flanksource/apm-hub/pkg/gitlab"
+	"github.com/flanksource/apm-hub/pkg/heroku"
+	"github.com/flanksource/apm-hub/pkg/httpjson"
+	"github.com/flanksource/apm-hub/pkg/jaeger"
+	"github.com/flanksource/apm-hub/pkg/jenkins"
+	"github.com/flanksource/apm-hub/pkg/jetstream"
+	"github.com/flanksource/apm-hub/pkg/journald"
+	"github.com/flanksource/apm-hub/pkg/kubeaudit"
 	k8s "github.com/flanksource/apm-hub/pkg/kubernetes"
+	"github.com/flanksource/apm-hub/pkg/loganalytics"
+	"github.com/flanksource/apm-hub/pkg/logscale"
+	"github.com/flanksource/apm-hub/pkg/logzio"
+	"github.com/flanksource/apm-hub/pkg/ocilogging"
 	pkgOpensearch "github.com/flanksource/apm-hub/pkg/opensearch"
+	"github.com/flanksource/apm-hub/pkg/papertrail"
+	"github.com/flanksource/apm-hub/pkg/pipeline"
+	"github.com/flanksource/apm-hub/pkg/plugin"
+	"github.com/flanksource/apm-hub/pkg/postgres"
+	"github.com/flanksource/apm-hub/pkg/prometheus"
+	"github.com/flanksource/apm-hub/pkg/ratelimit"
+	"github.com/flanksource/apm-hub/pkg/remote"
+	"github.com/flanksource/apm-hub/pkg/rollbar"
+	"github.com/flanksource/apm-hub/pkg/sentry"
+	"github.com/flanksource/apm-hub/pkg/signoz"
+	"github.com/flanksource/apm-hub/pkg/sqlsearch"
+	"github.com/flanksource/apm-hub/pkg/sshsearch"
+	"github.com/flanksource/apm-hub/pkg/store"
+	"github.com/flanksource/apm-hub/pkg/tempo"
+	"github.com/flanksource/apm-hub/pkg/wasm"
+	"github.com/flanksource/apm-hub/pkg/zincsearch"
+	"github.com/flanksource/apm-hub/pkg/zipkin"
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/kommons"
 	"github.com/opensearch-project/opensearch-go/v2"
@@ -73,41 +121,918 @@ func LoadGlobalBackends() error {
 
 var errRoutesNotProvided = fmt.Errorf("no routes provided")
 
+// newSearchBackend creates the backend from the search api
+// and the settings common to all the backends.
+func newSearchBackend(api logs.SearchAPI, config logs.CommonBackend) (logs.SearchBackend, error) {
+	backend := logs.NewSearchBackend(api)
+	backend.Name = config.Name
+	backend.Routes = config.Routes
+	backend.Shadow = config.Shadow
+
+	p, err := pipeline.New(backend.Name, config.Pipeline, config.Transform)
+	if err != nil {
+		return backend, fmt.Errorf("error creating the pipeline: %w", err)
+	}
+
+	// Avoid storing a typed nil in the interface
+	if p != nil {
+		backend.Pipeline = p
+	}
+
+	if config.Concurrency != nil {
+		limiter, err := ratelimit.NewConcurrencyLimiter(backend.Name, *config.Concurrency)
+		if err != nil {
+			return backend, err
+		}
+		backend.Limiter = limiter
+	}
+
+	return backend, nil
+}
+
 // getBackendsFromConfigs instantiates backends from the given configuration.
 //
 // A single configuration can have multiple backends.
 func getBackendsFromConfigs(kommonsClient *kommons.Client, backendConfig logs.SearchBackendConfig) ([]logs.SearchBackend, error) {
 	var backends []logs.SearchBackend
 
-	if backendConfig.Kubernetes != nil {
-		if len(backendConfig.Kubernetes.Routes) == 0 {
+	if backendConfig.Kubernetes != nil {
+		if len(backendConfig.Kubernetes.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		k8sclient, err := k8s.GetKubeClient(kommonsClient, backendConfig.Kubernetes)
+		if err != nil {
+			return nil, err
+		}
+
+		backend, err := newSearchBackend(k8s.NewKubernetesSearchBackend(k8sclient, backendConfig.Kubernetes), backendConfig.Kubernetes.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.File != nil {
+		if len(backendConfig.File.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		// If the paths are not absolute,
+		// They should be parsed with respect to the current path
+		for j, p := range backendConfig.File.Paths {
+			if !filepath.IsAbs(p) {
+				currentPath, _ := os.Getwd()
+				backendConfig.File.Paths[j] = filepath.Join(currentPath, p)
+			}
+		}
+
+		backend, err := newSearchBackend(files.NewFileSearchBackend(backendConfig.File), backendConfig.File.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Store != nil {
+		if len(backendConfig.Store.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		storeSearch, err := store.NewStoreSearchBackend(backendConfig.Store)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the store backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(storeSearch, backendConfig.Store.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Remote != nil {
+		if len(backendConfig.Remote.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		remoteSearch, err := remote.NewRemoteSearchBackend(kommonsClient, backendConfig.Remote)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the remote backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(remoteSearch, backendConfig.Remote.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Jaeger != nil {
+		if len(backendConfig.Jaeger.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		jaegerSearch, err := jaeger.NewJaegerSearchBackend(kommonsClient, backendConfig.Jaeger)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the jaeger backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(jaegerSearch, backendConfig.Jaeger.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Tempo != nil {
+		if len(backendConfig.Tempo.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		tempoSearch, err := tempo.NewTempoSearchBackend(kommonsClient, backendConfig.Tempo)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the tempo backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(tempoSearch, backendConfig.Tempo.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Zipkin != nil {
+		if len(backendConfig.Zipkin.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		zipkinSearch, err := zipkin.NewZipkinSearchBackend(kommonsClient, backendConfig.Zipkin)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the zipkin backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(zipkinSearch, backendConfig.Zipkin.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Prometheus != nil {
+		if len(backendConfig.Prometheus.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		prometheusSearch, err := prometheus.NewPrometheusSearchBackend(kommonsClient, backendConfig.Prometheus)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the prometheus backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(prometheusSearch, backendConfig.Prometheus.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Sentry != nil {
+		if len(backendConfig.Sentry.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		sentrySearch, err := sentry.NewSentrySearchBackend(kommonsClient, backendConfig.Sentry)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the sentry backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(sentrySearch, backendConfig.Sentry.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Rollbar != nil {
+		if len(backendConfig.Rollbar.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		rollbarSearch, err := rollbar.NewRollbarSearchBackend(kommonsClient, backendConfig.Rollbar)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the rollbar backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(rollbarSearch, backendConfig.Rollbar.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.EventHubs != nil {
+		if len(backendConfig.EventHubs.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		eventHubsSearch, err := eventhubs.NewEventHubsSearchBackend(kommonsClient, backendConfig.EventHubs)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the event hubs backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(eventHubsSearch, backendConfig.EventHubs.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Cloudflare != nil {
+		if len(backendConfig.Cloudflare.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		cloudflareSearch, err := cloudflare.NewCloudflareSearchBackend(kommonsClient, backendConfig.Cloudflare)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the cloudflare backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(cloudflareSearch, backendConfig.Cloudflare.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.AzureLogAnalytics != nil {
+		if len(backendConfig.AzureLogAnalytics.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		logAnalyticsSearch, err := loganalytics.NewLogAnalyticsSearchBackend(kommonsClient, backendConfig.AzureLogAnalytics)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the azure log analytics backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(logAnalyticsSearch, backendConfig.AzureLogAnalytics.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.LogScale != nil {
+		if len(backendConfig.LogScale.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		logScaleSearch, err := logscale.NewLogScaleSearchBackend(kommonsClient, backendConfig.LogScale)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the logscale backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(logScaleSearch, backendConfig.LogScale.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Coralogix != nil {
+		if len(backendConfig.Coralogix.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		coralogixSearch, err := coralogix.NewCoralogixSearchBackend(kommonsClient, backendConfig.Coralogix)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the coralogix backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(coralogixSearch, backendConfig.Coralogix.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Logzio != nil {
+		if len(backendConfig.Logzio.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		logzioSearch, err := logzio.NewLogzioSearchBackend(kommonsClient, backendConfig.Logzio)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the logzio backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(logzioSearch, backendConfig.Logzio.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.BetterStack != nil {
+		if len(backendConfig.BetterStack.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		betterStackSearch, err := betterstack.NewBetterStackSearchBackend(kommonsClient, backendConfig.BetterStack)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the better stack backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(betterStackSearch, backendConfig.BetterStack.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Papertrail != nil {
+		if len(backendConfig.Papertrail.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		papertrailSearch, err := papertrail.NewPapertrailSearchBackend(kommonsClient, backendConfig.Papertrail)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the papertrail backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(papertrailSearch, backendConfig.Papertrail.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.SigNoz != nil {
+		if len(backendConfig.SigNoz.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		sigNozSearch, err := signoz.NewSigNozSearchBackend(kommonsClient, backendConfig.SigNoz)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the signoz backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(sigNozSearch, backendConfig.SigNoz.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.ZincSearch != nil {
+		if len(backendConfig.ZincSearch.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		zincSearch, err := zincsearch.NewZincSearchBackend(kommonsClient, backendConfig.ZincSearch)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the zincsearch backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(zincSearch, backendConfig.ZincSearch.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Postgres != nil {
+		if len(backendConfig.Postgres.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		postgresSearch, err := postgres.NewPostgresSearchBackend(kommonsClient, backendConfig.Postgres)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the postgres backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(postgresSearch, backendConfig.Postgres.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.SQL != nil {
+		if len(backendConfig.SQL.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		sqlSearch, err := sqlsearch.NewSQLSearchBackend(kommonsClient, backendConfig.SQL)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the sql backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(sqlSearch, backendConfig.SQL.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.SQLite != nil {
+		if len(backendConfig.SQLite.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		// The path is relative to the current path, like the paths of the file backend
+		if !filepath.IsAbs(backendConfig.SQLite.Path) {
+			currentPath, _ := os.Getwd()
+			backendConfig.SQLite.Path = filepath.Join(currentPath, backendConfig.SQLite.Path)
+		}
+
+		sqliteSearch, err := sqlsearch.NewSQLiteSearchBackend(backendConfig.SQLite)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the sqlite backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(sqliteSearch, backendConfig.SQLite.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.BigQuery != nil {
+		if len(backendConfig.BigQuery.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		bigQuerySearch, err := bigquery.NewBigQuerySearchBackend(kommonsClient, backendConfig.BigQuery)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the bigquery backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(bigQuerySearch, backendConfig.BigQuery.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Athena != nil {
+		if len(backendConfig.Athena.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		cfg, err := loadAWSConfig(kommonsClient, backendConfig.Athena.Auth, backendConfig.Athena.Namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		athenaSearch, err := athena.NewAthenaSearchBackend(backendConfig.Athena, awsathena.NewFromConfig(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("error creating the athena backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(athenaSearch, backendConfig.Athena.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.S3Archive != nil {
+		if len(backendConfig.S3Archive.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		cfg, err := loadAWSConfig(kommonsClient, backendConfig.S3Archive.Auth, backendConfig.S3Archive.Namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+			if backendConfig.S3Archive.Endpoint != "" {
+				o.EndpointResolver = s3.EndpointResolverFromURL(backendConfig.S3Archive.Endpoint)
+			}
+			o.UsePathStyle = backendConfig.S3Archive.UsePathStyle
+		})
+		archiveSearch, err := archive.NewS3ArchiveSearchBackend(backendConfig.S3Archive, client)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the s3 archive backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(archiveSearch, backendConfig.S3Archive.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.GCSArchive != nil {
+		if len(backendConfig.GCSArchive.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		archiveSearch, err := archive.NewGCSArchiveSearchBackend(kommonsClient, backendConfig.GCSArchive)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the gcs archive backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(archiveSearch, backendConfig.GCSArchive.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.AzureBlobArchive != nil {
+		if len(backendConfig.AzureBlobArchive.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		archiveSearch, err := archive.NewAzureBlobArchiveSearchBackend(kommonsClient, backendConfig.AzureBlobArchive)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the azure blob archive backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(archiveSearch, backendConfig.AzureBlobArchive.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.NATS != nil {
+		if len(backendConfig.NATS.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		jetStreamSearch, err := jetstream.NewJetStreamSearchBackend(kommonsClient, backendConfig.NATS)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the nats backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(jetStreamSearch, backendConfig.NATS.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Journald != nil {
+		if len(backendConfig.Journald.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		journaldSearch, err := journald.NewJournaldSearchBackend(backendConfig.Journald)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the journald backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(journaldSearch, backendConfig.Journald.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Docker != nil {
+		if len(backendConfig.Docker.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		dockerSearch, err := docker.NewDockerSearchBackend(kommonsClient, backendConfig.Docker)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the docker backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(dockerSearch, backendConfig.Docker.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.CRI != nil {
+		if len(backendConfig.CRI.Routes) == 0 {
 			return nil, errRoutesNotProvided
 		}
 
-		k8sclient, err := k8s.GetKubeClient(kommonsClient, backendConfig.Kubernetes)
+		backend, err := newSearchBackend(cri.NewCRISearchBackend(backendConfig.CRI), backendConfig.CRI.CommonBackend)
 		if err != nil {
 			return nil, err
 		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.SSH != nil {
+		if len(backendConfig.SSH.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		sshSearch, err := sshsearch.NewSSHSearchBackend(kommonsClient, backendConfig.SSH)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the ssh backend: %w", err)
+		}
 
-		backend := logs.NewSearchBackend(k8s.NewKubernetesSearchBackend(k8sclient, backendConfig.Kubernetes))
+		backend, err := newSearchBackend(sshSearch, backendConfig.SSH.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
 		backends = append(backends, backend)
 	}
 
-	if backendConfig.File != nil {
-		if len(backendConfig.File.Routes) == 0 {
+	if backendConfig.HTTP != nil {
+		if len(backendConfig.HTTP.Routes) == 0 {
 			return nil, errRoutesNotProvided
 		}
 
-		// If the paths are not absolute,
-		// They should be parsed with respect to the current path
-		for j, p := range backendConfig.File.Paths {
-			if !filepath.IsAbs(p) {
-				currentPath, _ := os.Getwd()
-				backendConfig.File.Paths[j] = filepath.Join(currentPath, p)
-			}
+		httpSearch, err := httpjson.NewHTTPSearchBackend(kommonsClient, backendConfig.HTTP)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the http backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(httpSearch, backendConfig.HTTP.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Plugin != nil {
+		if len(backendConfig.Plugin.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		pluginSearch, err := plugin.NewPluginSearchBackend(kommonsClient, backendConfig.Plugin)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the plugin backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(pluginSearch, backendConfig.Plugin.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.WASM != nil {
+		if len(backendConfig.WASM.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		wasmSearch, err := wasm.NewWASMSearchBackend(backendConfig.WASM)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the wasm backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(wasmSearch, backendConfig.WASM.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.GitHubActions != nil {
+		if len(backendConfig.GitHubActions.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		githubSearch, err := github.NewGitHubActionsSearchBackend(kommonsClient, backendConfig.GitHubActions)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the github actions backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(githubSearch, backendConfig.GitHubActions.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.GitLab != nil {
+		if len(backendConfig.GitLab.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		gitlabSearch, err := gitlab.NewGitLabSearchBackend(kommonsClient, backendConfig.GitLab)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the gitlab backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(gitlabSearch, backendConfig.GitLab.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Jenkins != nil {
+		if len(backendConfig.Jenkins.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		jenkinsSearch, err := jenkins.NewJenkinsSearchBackend(kommonsClient, backendConfig.Jenkins)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the jenkins backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(jenkinsSearch, backendConfig.Jenkins.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.KubernetesAudit != nil {
+		if len(backendConfig.KubernetesAudit.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		auditSearch, err := kubeaudit.NewKubernetesAuditSearchBackend(backendConfig.KubernetesAudit)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the kubernetes audit backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(auditSearch, backendConfig.KubernetesAudit.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.KubernetesNode != nil {
+		if len(backendConfig.KubernetesNode.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		k8sclient, err := k8s.GetKubeClient(kommonsClient, &logs.KubernetesSearchBackendConfig{
+			Kubeconfig: backendConfig.KubernetesNode.Kubeconfig,
+			Namespace:  backendConfig.KubernetesNode.Namespace,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		nodeSearch, err := k8s.NewKubernetesNodeSearchBackend(k8sclient, backendConfig.KubernetesNode)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the kubernetes node backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(nodeSearch, backendConfig.KubernetesNode.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.GoogleCloudLogging != nil {
+		if len(backendConfig.GoogleCloudLogging.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		cloudLoggingSearch, err := cloudlogging.NewCloudLoggingSearchBackend(kommonsClient, backendConfig.GoogleCloudLogging)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the cloud logging backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(cloudLoggingSearch, backendConfig.GoogleCloudLogging.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.ControlPlane != nil {
+		if len(backendConfig.ControlPlane.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		preset, err := controlplane.Backend(*backendConfig.ControlPlane)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the control plane backend: %w", err)
+		}
+
+		presetBackends, err := getBackendsFromConfigs(kommonsClient, preset)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the %s control plane backend: %w", backendConfig.ControlPlane.Provider, err)
+		}
+		backends = append(backends, presetBackends...)
+	}
+
+	if backendConfig.ElasticAPM != nil {
+		if len(backendConfig.ElasticAPM.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		apm := backendConfig.ElasticAPM
+		cfg, err := getElasticConfig(kommonsClient, &logs.ElasticSearchBackendConfig{
+			Address:   apm.Address,
+			Namespace: apm.Namespace,
+			CloudID:   apm.CloudID,
+			APIKey:    apm.APIKey,
+			Username:  apm.Username,
+			Password:  apm.Password,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting the elastic apm config: %w", err)
+		}
+
+		esClient, err := v8.NewClient(*cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the elastic apm client: %w", err)
+		}
+
+		apmSearch, err := elasticapm.NewElasticAPMSearchBackend(esClient, apm)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the elastic apm backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(apmSearch, apm.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Dynatrace != nil {
+		if len(backendConfig.Dynatrace.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		dynatraceSearch, err := dynatrace.NewDynatraceSearchBackend(kommonsClient, backendConfig.Dynatrace)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the dynatrace backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(dynatraceSearch, backendConfig.Dynatrace.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.AppDynamics != nil {
+		if len(backendConfig.AppDynamics.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		appDynamicsSearch, err := appdynamics.NewAppDynamicsSearchBackend(kommonsClient, backendConfig.AppDynamics)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the appdynamics backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(appDynamicsSearch, backendConfig.AppDynamics.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.OCILogging != nil {
+		if len(backendConfig.OCILogging.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		ociLoggingSearch, err := ocilogging.NewOCILoggingSearchBackend(kommonsClient, backendConfig.OCILogging)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the oci logging backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(ociLoggingSearch, backendConfig.OCILogging.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.AlibabaSLS != nil {
+		if len(backendConfig.AlibabaSLS.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		slsSearch, err := alibaba.NewSLSSearchBackend(kommonsClient, backendConfig.AlibabaSLS)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the alibaba sls backend: %w", err)
+		}
+
+		backend, err := newSearchBackend(slsSearch, backendConfig.AlibabaSLS.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
+		backends = append(backends, backend)
+	}
+
+	if backendConfig.Heroku != nil {
+		if len(backendConfig.Heroku.Routes) == 0 {
+			return nil, errRoutesNotProvided
+		}
+
+		herokuSearch, err := heroku.NewHerokuSearchBackend(kommonsClient, backendConfig.Heroku)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the heroku backend: %w", err)
 		}
 
-		backend := logs.NewSearchBackend(files.NewFileSearchBackend(backendConfig.File))
+		backend, err := newSearchBackend(herokuSearch, backendConfig.Heroku.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
 		backends = append(backends, backend)
 	}
 
@@ -140,7 +1065,10 @@ func getBackendsFromConfigs(kommonsClient *kommons.Client, backendConfig logs.Se
 			return nil, fmt.Errorf("error creating the elastic search backend: %w", err)
 		}
 
-		backend := logs.NewSearchBackend(es)
+		backend, err := newSearchBackend(es, backendConfig.ElasticSearch.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
 		backends = append(backends, backend)
 	}
 
@@ -173,29 +1101,19 @@ func getBackendsFromConfigs(kommonsClient *kommons.Client, backendConfig logs.Se
 			return nil, fmt.Errorf("error creating the openSearch backend: %w", err)
 		}
 
-		backend := logs.NewSearchBackend(osBackend)
-		backends = append(backends, backend)
-	}
-
-	if backendConfig.CloudWatch != nil {
-		_, accessKey, err := kommonsClient.GetEnvValue(*backendConfig.CloudWatch.Auth.AccessKey, backendConfig.CloudWatch.Namespace)
+		backend, err := newSearchBackend(osBackend, backendConfig.OpenSearch.CommonBackend)
 		if err != nil {
 			return nil, err
 		}
+		backends = append(backends, backend)
+	}
 
-		_, secretKey, err := kommonsClient.GetEnvValue(*backendConfig.CloudWatch.Auth.SecretKey, backendConfig.CloudWatch.Namespace)
+	if backendConfig.CloudWatch != nil {
+		cfg, err := loadAWSConfig(kommonsClient, backendConfig.CloudWatch.Auth, backendConfig.CloudWatch.Namespace)
 		if err != nil {
 			return nil, err
 		}
 
-		cfg, err := config.LoadDefaultConfig(context.Background(),
-			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")),
-			config.WithRegion(backendConfig.CloudWatch.Auth.Region),
-		)
-		if err != nil {
-			return nil, fmt.Errorf("error creating aws config: %w", err)
-		}
-
 		client := cloudwatchlogs.NewFromConfig(cfg)
 
 		// Make a request to verify that the auth & log group is valid.
@@ -216,15 +1134,45 @@ func getBackendsFromConfigs(kommonsClient *kommons.Client, backendConfig logs.Se
 			return nil, fmt.Errorf("log group %s does not exist", backendConfig.CloudWatch.LogGroup)
 		}
 
-		cloudwatch := cloudwatch.NewCloudWatchSearchBackend(backendConfig.CloudWatch, client)
+		cloudwatch, err := cloudwatch.NewCloudWatchSearchBackend(backendConfig.CloudWatch, client)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the cloudwatch backend: %w", err)
+		}
 
-		backend := logs.NewSearchBackend(cloudwatch)
+		backend, err := newSearchBackend(cloudwatch, backendConfig.CloudWatch.CommonBackend)
+		if err != nil {
+			return nil, err
+		}
 		backends = append(backends, backend)
 	}
 
 	return backends, nil
 }
 
+// loadAWSConfig loads the aws config of the region, with the access keys when they're provided,
+// or the default credentials, e.g. of the IAM role of the service account
+func loadAWSConfig(client *kommons.Client, auth logs.AWSAuthentication, namespace string) (aws.Config, error) {
+	opts := []func(*config.LoadOptions) error{config.WithRegion(auth.Region)}
+	if auth.AccessKey != nil && auth.SecretKey != nil {
+		_, accessKey, err := client.GetEnvValue(*auth.AccessKey, namespace)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("error getting the access key: %w", err)
+		}
+
+		_, secretKey, err := client.GetEnvValue(*auth.SecretKey, namespace)
+		if err != nil {
+			return aws.Config{}, fmt.Errorf("error getting the secret key: %w", err)
+		}
+		opts = append(opts, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("error creating aws config: %w", err)
+	}
+	return cfg, nil
+}
+
 func getOpenSearchEnvVars(client *kommons.Client, conf *logs.OpenSearchBackendConfig) (username, password string, err error) {
 	if conf.Username != nil {
 		_, username, err = client.GetEnvValue(*conf.Username, conf.Namespace)
@@ -0,0 +1,134 @@
+package configdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/duty/models"
+	"github.com/google/uuid"
+	"github.com/patrickmn/go-cache"
+)
+
+// GlobalResolver resolves the config item ids of the searches.
+// It's nil when the config-db lookup isn't configured.
+var GlobalResolver *Resolver
+
+// LookupFunc returns the config item with the id, or nil if it doesn't exist
+type LookupFunc func(id uuid.UUID) (*models.ConfigItem, error)
+
+// Resolver rewrites the search params whose id is a config item
+type Resolver struct {
+	types      map[string]string
+	clusterTag string
+	lookup     LookupFunc
+	items      *cache.Cache
+}
+
+func NewResolver(config logs.ConfigDBConfig, lookup LookupFunc) (*Resolver, error) {
+	ttl := 5 * time.Minute
+	if config.CacheTTL != "" {
+		d, err := durationUtil.ParseDuration(config.CacheTTL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cacheTTL %s: %w", config.CacheTTL, err)
+		}
+		ttl = time.Duration(d)
+	}
+
+	t := &Resolver{
+		types:      config.Types,
+		clusterTag: config.ClusterTag,
+		lookup:     lookup,
+		items:      cache.New(ttl, 2*ttl),
+	}
+	if t.clusterTag == "" {
+		t.clusterTag = "cluster"
+	}
+	return t, nil
+}
+
+// Resolve replaces the config item id of the search params with the <namespace>/<name> of the item,
+// sets the search type from the config type and adds the cluster and namespace labels.
+// The type and labels already set in the search params are kept.
+// Ids that aren't config items are left untouched.
+func (t *Resolver) Resolve(q *logs.SearchParams) error {
+	if t == nil {
+		return nil
+	}
+
+	id, err := uuid.Parse(q.Id)
+	if err != nil {
+		return nil
+	}
+
+	item, err := t.get(id)
+	if err != nil {
+		return fmt.Errorf("error looking up the config item %s: %w", id, err)
+	}
+	if item == nil {
+		return nil
+	}
+
+	if q.Type == "" && item.Type != nil {
+		q.Type = t.searchType(*item.Type)
+	}
+
+	labels := make(map[string]string, len(q.Labels)+2)
+	if item.Tags != nil {
+		if cluster, ok := (*item.Tags)[t.clusterTag]; ok {
+			labels["cluster"] = cluster
+		}
+	}
+	if item.Namespace != nil && *item.Namespace != "" {
+		labels["namespace"] = *item.Namespace
+	}
+	for k, v := range q.Labels {
+		labels[k] = v
+	}
+	q.Labels = labels
+
+	if item.Name != nil {
+		q.Id = *item.Name
+		if item.Namespace != nil && *item.Namespace != "" {
+			q.Id = *item.Namespace + "/" + *item.Name
+		}
+	}
+
+	logger.Debugf("resolved the config item %s to %s", id, q)
+	return nil
+}
+
+func (t *Resolver) get(id uuid.UUID) (*models.ConfigItem, error) {
+	if item, ok := t.items.Get(id.String()); ok {
+		return item.(*models.ConfigItem), nil
+	}
+
+	item, err := t.lookup(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// The missing items are cached too, so that random ids don't hit the database
+	t.items.SetDefault(id.String(), item)
+	return item, nil
+}
+
+func (t *Resolver) searchType(configType string) string {
+	if searchType, ok := t.types[configType]; ok {
+		return searchType
+	}
+	return strings.ReplaceAll(configType, "::", "")
+}
+
+// MapSearchParams implements logs.SearchMapper, resolving a copy of the search params
+func (t *Resolver) MapSearchParams(ctx context.Context, p *logs.SearchParams) ([]logs.SearchParams, error) {
+	q := *p
+	if err := t.Resolve(&q); err != nil {
+		return nil, err
+	}
+	return []logs.SearchParams{q}, nil
+}
@@ -0,0 +1,79 @@
+package configdb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/duty/models"
+	"github.com/flanksource/duty/types"
+	"github.com/google/uuid"
+)
+
+func TestResolver(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+	pod := models.ConfigItem{
+		ID:        uuid.New(),
+		Type:      strPtr("Kubernetes::Pod"),
+		Name:      strPtr("api-7d9f"),
+		Namespace: strPtr("prod"),
+		Tags:      &types.JSONStringMap{"cluster": "eu-west"},
+	}
+	vm := models.ConfigItem{ID: uuid.New(), Type: strPtr("AWS::EC2::Instance"), Name: strPtr("i-0abc")}
+
+	lookups := 0
+	lookup := func(id uuid.UUID) (*models.ConfigItem, error) {
+		lookups++
+		for _, item := range []models.ConfigItem{pod, vm} {
+			if item.ID == id {
+				return &item, nil
+			}
+		}
+		return nil, nil
+	}
+
+	resolver, err := NewResolver(logs.ConfigDBConfig{Types: map[string]string{"AWS::EC2::Instance": "VM"}}, lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	missing := uuid.NewString()
+	tests := []struct {
+		name   string
+		params logs.SearchParams
+		want   logs.SearchParams
+	}{
+		{
+			name:   "pod",
+			params: logs.SearchParams{Id: pod.ID.String()},
+			want:   logs.SearchParams{Id: "prod/api-7d9f", Type: "KubernetesPod", Labels: map[string]string{"cluster": "eu-west", "namespace": "prod"}},
+		},
+		{
+			name:   "params are kept",
+			params: logs.SearchParams{Id: pod.ID.String(), Type: "KubernetesDeployment", Labels: map[string]string{"cluster": "us-east"}},
+			want:   logs.SearchParams{Id: "prod/api-7d9f", Type: "KubernetesDeployment", Labels: map[string]string{"cluster": "us-east", "namespace": "prod"}},
+		},
+		{
+			name:   "mapped type",
+			params: logs.SearchParams{Id: vm.ID.String()},
+			want:   logs.SearchParams{Id: "i-0abc", Type: "VM", Labels: map[string]string{}},
+		},
+		{name: "not an uuid", params: logs.SearchParams{Id: "nginx-1"}, want: logs.SearchParams{Id: "nginx-1"}},
+		{name: "missing item", params: logs.SearchParams{Id: missing}, want: logs.SearchParams{Id: missing}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := resolver.Resolve(&tt.params); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.params, tt.want) {
+				t.Errorf("Resolve() = %+v, want %+v", tt.params, tt.want)
+			}
+		})
+	}
+
+	if lookups != 3 {
+		t.Errorf("expected the config items to be cached, got %d lookups", lookups)
+	}
+}
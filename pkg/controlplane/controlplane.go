@@ -0,0 +1,140 @@
+// Package controlplane configures the backends searching the logs of the control planes of the managed clusters
+package controlplane
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// The components of the control planes, selected with the component label of the searches
+const (
+	APIServer         = "apiserver"
+	Audit             = "audit"
+	Scheduler         = "scheduler"
+	ControllerManager = "controller-manager"
+	Authenticator     = "authenticator"
+)
+
+// eksStreams match the log streams of the components in the log group of the cluster
+var eksStreams = [][2]string{
+	{APIServer, `^kube-apiserver-[0-9a-f]+$`},
+	{Audit, `^kube-apiserver-audit-`},
+	{Scheduler, `^kube-scheduler-`},
+	{ControllerManager, `^kube-controller-manager-`},
+	{Authenticator, `^authenticator-`},
+}
+
+// gkeFilters match the entries of the components, the audit logs being the Cloud Audit Logs of the cluster
+var gkeFilters = [][2]string{
+	{APIServer, `resource.type="k8s_control_plane_component" resource.labels.component_name="apiserver"`},
+	{Audit, `resource.type="k8s_cluster" logName:"cloudaudit.googleapis.com"`},
+	{Scheduler, `resource.type="k8s_control_plane_component" resource.labels.component_name="scheduler"`},
+	{ControllerManager, `resource.type="k8s_control_plane_component" resource.labels.component_name="controller-manager"`},
+}
+
+// aksCategories are the categories of the diagnostic settings of the components, guard being the Azure AD authenticator
+var aksCategories = [][2]string{
+	{APIServer, `"kube-apiserver"`},
+	{Audit, `"kube-audit", "kube-audit-admin"`},
+	{Scheduler, `"kube-scheduler"`},
+	{ControllerManager, `"kube-controller-manager"`},
+	{Authenticator, `"guard"`},
+}
+
+// Backend returns the config of the backend searching the logs of the control plane of the cluster.
+// The logs of the component of the component label are searched, or the logs of all the components.
+func Backend(config logs.ControlPlaneBackendConfig) (logs.SearchBackendConfig, error) {
+	var backend logs.SearchBackendConfig
+	if config.Cluster == "" {
+		return backend, fmt.Errorf("the cluster is required")
+	}
+
+	common := config.CommonBackend
+	common.Labels = map[string]string{"cluster": config.Cluster}
+	for k, v := range config.Labels {
+		common.Labels[k] = v
+	}
+
+	switch strings.ToLower(config.Provider) {
+	case logs.ControlPlaneEKS:
+		backend.CloudWatch = &logs.CloudWatchBackendConfig{
+			CommonBackend: common,
+			Auth:          config.AWS,
+			Namespace:     config.Namespace,
+			LogGroup:      "/aws/eks/" + config.Cluster + "/cluster",
+			Query:         eksQuery(),
+		}
+	case logs.ControlPlaneGKE:
+		if config.Project == "" {
+			return backend, fmt.Errorf("the project of the cluster is required")
+		}
+		backend.GoogleCloudLogging = &logs.GoogleCloudLoggingBackendConfig{
+			CommonBackend:  common,
+			HTTPConnection: logs.HTTPConnection{Namespace: config.Namespace},
+			Credentials:    config.Credentials,
+			Project:        config.Project,
+			Filter:         gkeFilter(config.Cluster),
+		}
+	case logs.ControlPlaneAKS:
+		if config.WorkspaceID == "" {
+			return backend, fmt.Errorf("the workspace id is required")
+		}
+		backend.AzureLogAnalytics = &logs.AzureLogAnalyticsBackendConfig{
+			CommonBackend:  common,
+			HTTPConnection: logs.HTTPConnection{Namespace: config.Namespace},
+			Auth:           config.Azure,
+			WorkspaceID:    config.WorkspaceID,
+			Query:          aksQuery(config.Cluster),
+			MessageColumn:  "log_s",
+		}
+	default:
+		return backend, fmt.Errorf("unknown control plane provider %q, expected one of eks, gke or aks", config.Provider)
+	}
+	return backend, nil
+}
+
+// components renders the template selecting the clause of the component label, or the default clause
+func components(clauses [][2]string, render func(clause string) string, otherwise string) string {
+	var b strings.Builder
+	b.WriteString(`{{- $component := index .Labels "component"}}`)
+	for i, c := range clauses {
+		if i == 0 {
+			b.WriteString(`{{- if eq $component "` + c[0] + `"}}`)
+		} else {
+			b.WriteString(`{{- else if eq $component "` + c[0] + `"}}`)
+		}
+		b.WriteString(render(c[1]))
+	}
+	b.WriteString(`{{- else}}` + otherwise + `{{- end}}`)
+	return b.String()
+}
+
+// eksQuery is the Logs Insights query of the log group of the cluster
+func eksQuery() string {
+	return "fields @timestamp, @message, @logStream" +
+		components(eksStreams, func(stream string) string { return "\n| filter @logStream like /" + stream + "/" }, "") +
+		"{{- with .Query}}\n| filter @message like /{{.}}/{{end}}\n| sort @timestamp desc"
+}
+
+// gkeFilter is the filter of the entries of the cluster
+func gkeFilter(cluster string) string {
+	return fmt.Sprintf("resource.labels.cluster_name=%q", cluster) +
+		components(gkeFilters, func(filter string) string { return "\n" + filter },
+			"\n"+`(resource.type="k8s_control_plane_component" OR (resource.type="k8s_cluster" logName:"cloudaudit.googleapis.com"))`) +
+		`{{- with .Query}} {{printf "%q" .}}{{end}}`
+}
+
+// aksQuery is the KQL query of the AzureDiagnostics table of the workspace
+func aksQuery(cluster string) string {
+	var all []string
+	for _, c := range aksCategories {
+		all = append(all, c[1])
+	}
+	return fmt.Sprintf("AzureDiagnostics\n| where Resource =~ %q", cluster) +
+		components(aksCategories, func(categories string) string { return "\n| where Category in (" + categories + ")" },
+			"\n| where Category in ("+strings.Join(all, ", ")+")") +
+		"{{- with .Query}}\n| where log_s contains {{printf \"%q\" .}}{{end}}\n" +
+		"| project TimeGenerated, Category, Resource, log_s\n| sort by TimeGenerated desc"
+}
@@ -0,0 +1,103 @@
+package controlplane
+
+import (
+	"bytes"
+	"testing"
+	"text/template"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func render(t *testing.T, text string, q logs.SearchParams) string {
+	tpl, err := template.New("query").Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, q); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestBackend(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  logs.ControlPlaneBackendConfig
+		params  logs.SearchParams
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "eks audit",
+			config: logs.ControlPlaneBackendConfig{Provider: "eks", Cluster: "prod"},
+			params: logs.SearchParams{Query: "secrets", Labels: map[string]string{"component": "audit"}},
+			want:   "fields @timestamp, @message, @logStream\n| filter @logStream like /^kube-apiserver-audit-/\n| filter @message like /secrets/\n| sort @timestamp desc",
+		},
+		{
+			name:   "eks components",
+			config: logs.ControlPlaneBackendConfig{Provider: "EKS", Cluster: "prod"},
+			want:   "fields @timestamp, @message, @logStream\n| sort @timestamp desc",
+		},
+		{
+			name:   "gke scheduler",
+			config: logs.ControlPlaneBackendConfig{Provider: "gke", Cluster: "prod", Project: "acme"},
+			params: logs.SearchParams{Query: `failed "scheduling"`, Labels: map[string]string{"component": "scheduler"}},
+			want:   `resource.labels.cluster_name="prod"` + "\n" + `resource.type="k8s_control_plane_component" resource.labels.component_name="scheduler" "failed \"scheduling\""`,
+		},
+		{
+			name:   "gke components",
+			config: logs.ControlPlaneBackendConfig{Provider: "gke", Cluster: "prod", Project: "acme"},
+			want:   `resource.labels.cluster_name="prod"` + "\n" + `(resource.type="k8s_control_plane_component" OR (resource.type="k8s_cluster" logName:"cloudaudit.googleapis.com"))`,
+		},
+		{
+			name:   "aks authenticator",
+			config: logs.ControlPlaneBackendConfig{Provider: "aks", Cluster: "prod", WorkspaceID: "ws"},
+			params: logs.SearchParams{Labels: map[string]string{"component": "authenticator"}},
+			want:   "AzureDiagnostics\n| where Resource =~ \"prod\"\n| where Category in (\"guard\")\n| project TimeGenerated, Category, Resource, log_s\n| sort by TimeGenerated desc",
+		},
+		{
+			name:   "aks components",
+			config: logs.ControlPlaneBackendConfig{Provider: "aks", Cluster: "prod", WorkspaceID: "ws"},
+			params: logs.SearchParams{Query: "timeout"},
+			want: "AzureDiagnostics\n| where Resource =~ \"prod\"\n" +
+				"| where Category in (\"kube-apiserver\", \"kube-audit\", \"kube-audit-admin\", \"kube-scheduler\", \"kube-controller-manager\", \"guard\")\n" +
+				"| where log_s contains \"timeout\"\n| project TimeGenerated, Category, Resource, log_s\n| sort by TimeGenerated desc",
+		},
+		{name: "gke without project", config: logs.ControlPlaneBackendConfig{Provider: "gke", Cluster: "prod"}, wantErr: true},
+		{name: "unknown provider", config: logs.ControlPlaneBackendConfig{Provider: "doks", Cluster: "prod"}, wantErr: true},
+		{name: "no cluster", config: logs.ControlPlaneBackendConfig{Provider: "eks"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := Backend(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Backend() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var query string
+			var labels map[string]string
+			switch {
+			case backend.CloudWatch != nil:
+				query, labels = backend.CloudWatch.Query, backend.CloudWatch.Labels
+				if backend.CloudWatch.LogGroup != "/aws/eks/prod/cluster" {
+					t.Errorf("log group = %s", backend.CloudWatch.LogGroup)
+				}
+			case backend.GoogleCloudLogging != nil:
+				query, labels = backend.GoogleCloudLogging.Filter, backend.GoogleCloudLogging.Labels
+			case backend.AzureLogAnalytics != nil:
+				query, labels = backend.AzureLogAnalytics.Query, backend.AzureLogAnalytics.Labels
+			}
+			if got := render(t, query, tt.params); got != tt.want {
+				t.Errorf("query = %q, want %q", got, tt.want)
+			}
+			if labels["cluster"] != "prod" {
+				t.Errorf("labels = %v, want the cluster label", labels)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+// Package coralogix searches the logs of Coralogix with the DataPrime query api
+package coralogix
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const (
+	syntaxDataPrime = "dataprime"
+	syntaxLucene    = "lucene"
+)
+
+// domains are the domains of the Coralogix regions
+var domains = map[string]string{
+	"EU1": "coralogix.com",
+	"EU2": "eu2.coralogix.com",
+	"US1": "coralogix.us",
+	"US2": "cx498.coralogix.com",
+	"AP1": "coralogix.in",
+	"AP2": "coralogixsg.com",
+	"AP3": "ap3.coralogix.com",
+}
+
+// labelKeys are the Coralogix labels of the application and subsystem labels, as named in DataPrime and lucene
+var labelKeys = map[string][2]string{
+	"application": {"applicationname", "coralogix.metadata.applicationName"},
+	"subsystem":   {"subsystemname", "coralogix.metadata.subsystemName"},
+}
+
+// severities are the levels of the Coralogix severities, from 1 to 6
+var severities = []string{"debug", "verbose", "info", "warning", "error", "critical"}
+
+var dataPrimeSource = regexp.MustCompile(`(?i)^\s*source\s`)
+
+type CoralogixSearch struct {
+	config   *logs.CoralogixBackendConfig
+	client   *rest.Client
+	template *template.Template
+	syntax   string
+}
+
+func NewCoralogixSearchBackend(kClient *kommons.Client, config *logs.CoralogixBackendConfig) (*CoralogixSearch, error) {
+	syntax := strings.ToLower(config.Syntax)
+	switch syntax {
+	case "":
+		syntax = syntaxDataPrime
+	case syntaxDataPrime, syntaxLucene:
+	default:
+		return nil, fmt.Errorf("unknown syntax %s, expected dataprime or lucene", config.Syntax)
+	}
+
+	template, err := template.New("query").Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the query template: %w", err)
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		region := strings.ToUpper(config.Region)
+		if region == "" {
+			region = "EU1"
+		}
+		domain, ok := domains[region]
+		if !ok {
+			return nil, fmt.Errorf("unknown region %s", config.Region)
+		}
+		conn.URL = "https://ng-api-http." + domain
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &CoralogixSearch{config: config, client: client, template: template, syntax: syntax}, nil
+}
+
+// RenderQuery renders the query template, with the query and the application and subsystem labels of the search
+func (t *CoralogixSearch) RenderQuery(q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing the query template: %w", err)
+	}
+	query := strings.TrimSpace(buf.String())
+	usesQuery := strings.Contains(t.config.Query, ".Query")
+
+	if t.syntax == syntaxLucene {
+		var terms []string
+		if query != "" {
+			terms = append(terms, "("+query+")")
+		}
+		if q.Query != "" && !usesQuery {
+			terms = append(terms, "("+q.Query+")")
+		}
+		for _, label := range []string{"application", "subsystem"} {
+			if v, ok := q.Labels[label]; ok {
+				terms = append(terms, labelKeys[label][1]+":"+strconv.Quote(v))
+			}
+		}
+		return strings.Join(terms, " AND "), nil
+	}
+
+	if query == "" {
+		query = "source logs"
+	}
+	if q.Query != "" && !usesQuery {
+		if dataPrimeSource.MatchString(q.Query) {
+			query = q.Query
+		} else {
+			query += " | lucene " + quote(q.Query)
+		}
+	}
+	for _, label := range []string{"application", "subsystem"} {
+		if v, ok := q.Labels[label]; ok {
+			query += " | filter $l." + labelKeys[label][0] + " == " + quote(v)
+		}
+	}
+	return query, nil
+}
+
+// quote returns the DataPrime string literal of the value
+func quote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
+
+// Search runs the query over the time range of the search, limited to the limit of the search
+func (t *CoralogixSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	metadata := map[string]any{
+		"tier":          "TIER_FREQUENT_SEARCH",
+		"syntax":        "QUERY_SYNTAX_" + strings.ToUpper(t.syntax),
+		"defaultSource": "logs",
+	}
+	if start := q.GetStart(); start != nil {
+		metadata["startDate"] = start.UTC().Format(time.RFC3339Nano)
+	}
+	if end := q.GetEnd(); end != nil {
+		metadata["endDate"] = end.UTC().Format(time.RFC3339Nano)
+	}
+	if q.Limit > 0 {
+		metadata["limit"] = q.Limit
+	}
+	rows, err := t.query(ctx, query, metadata)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	results := logs.SearchResults{Total: len(rows)}
+	for _, row := range rows {
+		results.Results = append(results.Results, row.result(t.config.Labels))
+	}
+	sort.SliceStable(results.Results, func(i, j int) bool { return results.Results[i].Time < results.Results[j].Time })
+	return results, nil
+}
+
+// query runs the query and returns its logs. The results are streamed back as newline delimited json,
+// each line holding either a batch of logs, a warning or an error.
+func (t *CoralogixSearch) query(ctx context.Context, query string, metadata map[string]any) ([]row, error) {
+	data, err := json.Marshal(map[string]any{"query": query, "metadata": metadata})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding the query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.client.URL("/api/v1/dataprime/query", nil), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var rows []row
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Result *struct {
+				Results []row `json:"results"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := decoder.Decode(&message); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("error decoding the query results: %w", err)
+		}
+
+		if message.Error != nil {
+			return nil, fmt.Errorf("error running the query: %s", message.Error.Message)
+		}
+		if message.Result != nil {
+			rows = append(rows, message.Result.Results...)
+		}
+	}
+	return rows, nil
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type row struct {
+	Metadata []keyValue `json:"metadata"`
+	Labels   []keyValue `json:"labels"`
+	UserData string     `json:"userData"`
+}
+
+// result renders the log, with the message field of its json data as message, or the whole data otherwise.
+// The other top level fields of the data and the labels of the log are returned as labels.
+func (r row) result(labels map[string]string) logs.Result {
+	res := logs.Result{Labels: make(map[string]string, len(r.Labels)+len(labels))}
+
+	for _, m := range r.Metadata {
+		switch m.Key {
+		case "timestamp":
+			res.Time = parseTime(m.Value)
+		case "logid":
+			res.Id = m.Value
+		case "severity":
+			if severity, err := strconv.Atoi(m.Value); err == nil && severity >= 1 && severity <= len(severities) {
+				res.Labels["level"] = severities[severity-1]
+			}
+		}
+	}
+	for _, l := range r.Labels {
+		switch l.Key {
+		case labelKeys["application"][0]:
+			res.Labels["application"] = l.Value
+		case labelKeys["subsystem"][0]:
+			res.Labels["subsystem"] = l.Value
+		default:
+			res.Labels[l.Key] = l.Value
+		}
+	}
+
+	res.Message = r.UserData
+	decoder := json.NewDecoder(strings.NewReader(r.UserData))
+	decoder.UseNumber()
+	var data map[string]any
+	if err := decoder.Decode(&data); err == nil {
+		for _, field := range []string{"message", "log", "msg"} {
+			if msg, ok := data[field].(string); ok {
+				res.Message = msg
+				delete(data, field)
+				for k, v := range data {
+					switch v := v.(type) {
+					case string:
+						res.Labels[k] = v
+					case json.Number:
+						res.Labels[k] = v.String()
+					case bool:
+						res.Labels[k] = strconv.FormatBool(v)
+					}
+				}
+				break
+			}
+		}
+	}
+
+	for k, v := range labels {
+		res.Labels[k] = v
+	}
+	return res
+}
+
+// parseTime parses the timestamps of the logs, returned without a time zone in UTC
+func parseTime(value string) string {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return value
+}
+
+func (t *CoralogixSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Coralogix backend, the query of the search is sent as DataPrime or lucene
+func (t *CoralogixSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true}
+}
+
+// HealthCheck runs a DataPrime query over the last minute, limited to a single log
+func (t *CoralogixSearch) HealthCheck(ctx context.Context) error {
+	now := time.Now().UTC()
+	_, err := t.query(ctx, "source logs | limit 1", map[string]any{
+		"tier":      "TIER_FREQUENT_SEARCH",
+		"syntax":    "QUERY_SYNTAX_DATAPRIME",
+		"startDate": now.Add(-time.Minute).Format(time.RFC3339Nano),
+		"endDate":   now.Format(time.RFC3339Nano),
+	})
+	return err
+}
@@ -0,0 +1,138 @@
+package coralogix
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestCoralogixSearch_RenderQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		syntax   string
+		template string
+		query    string
+		labels   map[string]string
+		want     string
+	}{
+		{name: "default", want: `source logs`},
+		{name: "lucene filter", query: `status:504 AND "it's down"`, want: `source logs | lucene 'status:504 AND "it\'s down"'`},
+		{name: "pass-through", template: `source logs | filter $d.kubernetes.namespace == 'shop'`, query: `source spans | limit 5`, want: `source spans | limit 5`},
+		{name: "template with the query", template: `source logs | filter $d.message ~ '{{.Query}}'`, query: `timeout`, want: `source logs | filter $d.message ~ 'timeout'`},
+		{
+			name:   "labels",
+			query:  "timeout",
+			labels: map[string]string{"application": "shop", "subsystem": "api", "namespace": "default"},
+			want:   `source logs | lucene 'timeout' | filter $l.applicationname == 'shop' | filter $l.subsystemname == 'api'`,
+		},
+		{
+			name:     "lucene",
+			syntax:   "lucene",
+			template: `kubernetes.namespace:shop`,
+			query:    "timeout OR refused",
+			labels:   map[string]string{"application": "shop"},
+			want:     `(kubernetes.namespace:shop) AND (timeout OR refused) AND coralogix.metadata.applicationName:"shop"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := NewCoralogixSearchBackend(nil, &logs.CoralogixBackendConfig{Syntax: tt.syntax, Query: tt.template})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := backend.RenderQuery(&logs.SearchParams{Query: tt.query, Labels: tt.labels})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoralogixSearch(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/dataprime/query" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"queryId":{"queryId":"q1"}}
+{"warning":{"numberOfResultsLimitWarning":{"numberOfResultsLimit":2}}}
+{"result":{"results":[
+	{"metadata":[{"key":"timestamp","value":"2023-05-01T12:00:01.5"},{"key":"severity","value":"5"},{"key":"logid","value":"l2"}],"labels":[{"key":"applicationname","value":"shop"},{"key":"subsystemname","value":"api"}],"userData":"{\"message\":\"connection timeout\",\"status\":504,\"pod\":\"api-0\",\"kubernetes\":{\"namespace\":\"shop\"}}"},
+	{"metadata":[{"key":"timestamp","value":"2023-05-01T12:00:00Z"},{"key":"severity","value":"3"},{"key":"logid","value":"l1"}],"labels":[{"key":"applicationname","value":"shop"},{"key":"subsystemname","value":"web"}],"userData":"GET / 200"}
+]}}
+`))
+	}))
+	defer server.Close()
+
+	backend, err := NewCoralogixSearchBackend(nil, &logs.CoralogixBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "coralogix"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Token: &kommons.EnvVar{Value: "secret"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Labels: map[string]string{"application": "shop"}, Start: "2023-05-01T11:00:00Z", End: "2023-05-01T12:00:00Z", Limit: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Id: "l1", Time: "2023-05-01T12:00:00Z", Message: "GET / 200", Labels: map[string]string{"level": "info", "application": "shop", "subsystem": "web", "source": "coralogix"}},
+		{Id: "l2", Time: "2023-05-01T12:00:01.5Z", Message: "connection timeout", Labels: map[string]string{
+			"level": "error", "application": "shop", "subsystem": "api", "status": "504", "pod": "api-0", "source": "coralogix",
+		}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantBody := map[string]any{
+		"query": "source logs | filter $l.applicationname == 'shop'",
+		"metadata": map[string]any{
+			"tier":          "TIER_FREQUENT_SEARCH",
+			"syntax":        "QUERY_SYNTAX_DATAPRIME",
+			"defaultSource": "logs",
+			"startDate":     "2023-05-01T11:00:00Z",
+			"endDate":       "2023-05-01T12:00:00Z",
+			"limit":         float64(2),
+		},
+	}
+	if !reflect.DeepEqual(body, wantBody) {
+		t.Errorf("query = %v, want %v", body, wantBody)
+	}
+}
+
+func TestCoralogixSearch_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"queryId":{"queryId":"q1"}}
+{"error":{"message":"unknown keyword sourc"}}
+`))
+	}))
+	defer server.Close()
+
+	backend, err := NewCoralogixSearchBackend(nil, &logs.CoralogixBackendConfig{HTTPConnection: logs.HTTPConnection{URL: server.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Query: "sourc logs"}); err == nil || err.Error() != "error running the query: unknown keyword sourc" {
+		t.Errorf("Search() error = %v", err)
+	}
+}
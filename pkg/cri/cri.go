@@ -0,0 +1,269 @@
+// Package cri searches the log files written by the container runtime on the node apm-hub runs on
+package cri
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/logger"
+)
+
+const defaultPath = "/var/log/containers"
+
+func NewCRISearchBackend(config *logs.CRIBackendConfig) *CRISearch {
+	path := config.Path
+	if path == "" {
+		path = defaultPath
+	}
+	nodeName := config.NodeName
+	if nodeName == "" {
+		nodeName = os.Getenv("NODE_NAME")
+	}
+	return &CRISearch{config: config, path: path, nodeName: nodeName}
+}
+
+type CRISearch struct {
+	config   *logs.CRIBackendConfig
+	path     string
+	nodeName string
+}
+
+// logFile is the symlink to the log file of a container, named <pod>_<namespace>_<container>-<id>.log
+type logFile struct {
+	path      string
+	pod       string
+	namespace string
+	container string
+}
+
+func parseLogFile(dir, name string) (logFile, bool) {
+	base, ok := strings.CutSuffix(name, ".log")
+	if !ok {
+		return logFile{}, false
+	}
+	parts := strings.SplitN(base, "_", 3)
+	if len(parts) != 3 {
+		return logFile{}, false
+	}
+	i := strings.LastIndex(parts[2], "-")
+	if i <= 0 {
+		return logFile{}, false
+	}
+	return logFile{path: filepath.Join(dir, name), pod: parts[0], namespace: parts[1], container: parts[2][:i]}, true
+}
+
+// Search returns the last lines of the containers of the pods, up to the limit per item of the search.
+// The KubernetesNode searches return the logs of every pod, when the node is the node of apm-hub.
+func (t *CRISearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	var namespace, name string
+	if strings.Contains(strings.ToLower(q.Type), "kubernetesnode") {
+		if q.Id != "" && q.Id != t.nodeName {
+			return results, nil
+		}
+	} else if ns, n, ok := strings.Cut(q.Id, "/"); ok {
+		namespace, name = ns, n
+	} else {
+		namespace, name = q.Labels["namespace"], q.Id
+	}
+
+	entries, err := os.ReadDir(t.path)
+	if err != nil {
+		return results, fmt.Errorf("error reading the log files: %w", err)
+	}
+
+	for _, entry := range entries {
+		f, ok := parseLogFile(t.path, entry.Name())
+		if !ok || (namespace != "" && f.namespace != namespace) || (name != "" && f.pod != name) {
+			continue
+		}
+		if container, ok := q.Labels["containerName"]; ok && f.container != container {
+			continue
+		}
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+
+		lines, err := t.read(q, f)
+		if err != nil {
+			logger.Errorf("error reading the logs of %s/%s: %v", f.namespace, f.pod, err)
+			continue
+		}
+		results.Results = append(results.Results, lines...)
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// read returns the lines of the log file of the container, and of its rotated files, in the time range of the search
+func (t *CRISearch) read(q *logs.SearchParams, f logFile) ([]logs.Result, error) {
+	files, err := rotatedFiles(f.path, q.GetStart())
+	if err != nil {
+		return nil, err
+	}
+
+	limit := q.LimitPerItem
+	if limit == 0 {
+		limit = q.Limit
+	}
+	start, end := q.GetStart(), q.GetEnd()
+
+	labels := map[string]string{
+		"pod":           f.pod,
+		"namespace":     f.namespace,
+		"containerName": f.container,
+		"nodeName":      t.nodeName,
+	}
+	for k, v := range t.config.Labels {
+		labels[k] = v
+	}
+
+	var results []logs.Result
+	for _, path := range files {
+		err := readFile(path, func(ts time.Time, stream, message string) {
+			if (start != nil && ts.Before(*start)) || (end != nil && ts.After(*end)) {
+				return
+			}
+
+			lineLabels := make(map[string]string, len(labels)+1)
+			for k, v := range labels {
+				lineLabels[k] = v
+			}
+			lineLabels["stream"] = stream
+			results = append(results, logs.Result{Time: ts.UTC().Format(time.RFC3339Nano), Message: message, Labels: lineLabels})
+
+			// Only the last lines are kept
+			if limit > 0 && int64(len(results)) >= 2*limit {
+				results = append(results[:0], results[int64(len(results))-limit:]...)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if limit > 0 && int64(len(results)) > limit {
+		results = results[int64(len(results))-limit:]
+	}
+	return results, nil
+}
+
+// rotatedFiles returns the log file the symlink points to, after its rotated files, e.g. 0.log.20230501-120000.gz,
+// from the oldest. The files last written before the start are skipped.
+func rotatedFiles(symlink string, start *time.Time) ([]string, error) {
+	target, err := filepath.EvalSymlinks(symlink)
+	if err != nil {
+		return nil, err
+	}
+	dir, current := filepath.Dir(target), filepath.Base(target)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type file struct {
+		path     string
+		modified time.Time
+	}
+	var files []file
+	for _, entry := range entries {
+		if entry.Name() != current && !strings.HasPrefix(entry.Name(), current+".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		if start != nil && entry.Name() != current && info.ModTime().Before(*start) {
+			continue
+		}
+		files = append(files, file{path: filepath.Join(dir, entry.Name()), modified: info.ModTime()})
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].modified.Before(files[j].modified) })
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+	return paths, nil
+}
+
+// readFile calls fn with the lines of a log file of the CRI format, <time> <stream> <P|F> <message>,
+// the partial lines (P) being joined with the lines completing them (F)
+func readFile(path string, fn func(ts time.Time, stream, message string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := io.Reader(file)
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", path, err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	type partial struct {
+		ts      time.Time
+		message strings.Builder
+	}
+	partials := map[string]*partial{}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 4)
+		if len(fields) < 3 {
+			continue
+		}
+		ts, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			continue
+		}
+		stream, tag := fields[1], fields[2]
+		var message string
+		if len(fields) == 4 {
+			message = fields[3]
+		}
+
+		p := partials[stream]
+		if tag == "P" {
+			if p == nil {
+				p = &partial{ts: ts}
+				partials[stream] = p
+			}
+			p.message.WriteString(message)
+			continue
+		}
+		if p != nil {
+			p.message.WriteString(message)
+			ts, message = p.ts, p.message.String()
+			delete(partials, stream)
+		}
+		fn(ts, stream, message)
+	}
+	return scanner.Err()
+}
+
+func (t *CRISearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+func (t *CRISearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
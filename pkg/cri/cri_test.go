@@ -0,0 +1,123 @@
+package cri
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// writeLogs writes the log files of a container, and the symlink to its current file
+func writeLogs(t *testing.T, root, pod, namespace, container string, files map[string]string) {
+	dir := filepath.Join(root, "pods", namespace+"_"+pod+"_uid", container)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, name := range []string{"0.log.20230501-110000.gz", "0.log.20230501-120000", "0.log"} {
+		content, ok := files[name]
+		if !ok {
+			continue
+		}
+		data := []byte(content)
+		if filepath.Ext(name) == ".gz" {
+			var b bytes.Buffer
+			gz := gzip.NewWriter(&b)
+			_, _ = gz.Write(data)
+			_ = gz.Close()
+			data = b.Bytes()
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		modified = modified.Add(time.Hour)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	link := filepath.Join(root, "containers", pod+"_"+namespace+"_"+container+"-4f66ad9a.log")
+	if err := os.Symlink(filepath.Join(dir, "0.log"), link); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCRISearch(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "containers"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeLogs(t, root, "api-0", "shop", "api", map[string]string{
+		"0.log.20230501-110000.gz": "2023-05-01T10:59:00Z stdout F too old\n",
+		"0.log.20230501-120000":    "2023-05-01T12:00:01Z stdout F rotated\n",
+		"0.log": "2023-05-01T12:30:00.5Z stderr P panic: \n" +
+			"2023-05-01T12:30:00.6Z stdout F GET /health 200\n" +
+			"2023-05-01T12:30:00.7Z stderr F nil map\n" +
+			"2023-05-01T12:31:00Z stdout F \n",
+	})
+	writeLogs(t, root, "api-0", "shop", "istio-proxy", map[string]string{"0.log": "2023-05-01T12:30:00Z stdout F proxy\n"})
+	writeLogs(t, root, "db-0", "shop", "postgres", map[string]string{"0.log": "2023-05-01T12:30:00Z stdout F ready\n"})
+
+	backend := NewCRISearchBackend(&logs.CRIBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"cluster": "prod"}},
+		Path:          filepath.Join(root, "containers"),
+		NodeName:      "node-1",
+	})
+
+	labels := func(stream string) map[string]string {
+		return map[string]string{"pod": "api-0", "namespace": "shop", "containerName": "api", "nodeName": "node-1", "cluster": "prod", "stream": stream}
+	}
+	tests := []struct {
+		name string
+		q    logs.SearchParams
+		want []logs.Result
+	}{
+		{
+			name: "pod",
+			q:    logs.SearchParams{Type: "KubernetesPod", Id: "shop/api-0", Start: "2023-05-01T12:00:00Z", LimitPerItem: 3, Labels: map[string]string{"containerName": "api"}},
+			want: []logs.Result{
+				{Time: "2023-05-01T12:30:00.6Z", Message: "GET /health 200", Labels: labels("stdout")},
+				{Time: "2023-05-01T12:30:00.5Z", Message: "panic: nil map", Labels: labels("stderr")},
+				{Time: "2023-05-01T12:31:00Z", Message: "", Labels: labels("stdout")},
+			},
+		},
+		{
+			name: "rotated",
+			q:    logs.SearchParams{Type: "KubernetesPod", Id: "api-0", End: "2023-05-01T12:10:00Z", Labels: map[string]string{"namespace": "shop", "containerName": "api"}},
+			want: []logs.Result{
+				{Time: "2023-05-01T10:59:00Z", Message: "too old", Labels: labels("stdout")},
+				{Time: "2023-05-01T12:00:01Z", Message: "rotated", Labels: labels("stdout")},
+			},
+		},
+		{
+			name: "other node",
+			q:    logs.SearchParams{Type: "KubernetesNode", Id: "node-2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := backend.Search(context.Background(), &tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(res.Results, tt.want) {
+				t.Errorf("Search() = %+v, want %+v", res.Results, tt.want)
+			}
+		})
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Type: "KubernetesNode", Id: "node-1", Start: "2023-05-01T12:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 6 {
+		t.Errorf("Search() of the node returned %d results, want 6: %+v", res.Total, res.Results)
+	}
+}
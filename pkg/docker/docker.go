@@ -0,0 +1,249 @@
+// Package docker searches the logs of the containers of the Docker Engine api
+package docker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/kommons"
+)
+
+const defaultURL = "unix:///var/run/docker.sock"
+
+// streams are the names of the streams of the multiplexed logs, by the first byte of their frame header
+var streams = []string{"stdin", "stdout", "stderr"}
+
+type DockerSearch struct {
+	config *logs.DockerBackendConfig
+	client *rest.Client
+}
+
+func NewDockerSearchBackend(kClient *kommons.Client, config *logs.DockerBackendConfig) (*DockerSearch, error) {
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &DockerSearch{config: config, client: client}, nil
+}
+
+type container struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Image  string            `json:"Image"`
+	Labels map[string]string `json:"Labels"`
+}
+
+// name returns the name of the container, without its leading slash
+func (c container) name() string {
+	if len(c.Names) == 0 {
+		return ""
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+// matches returns true when the id of the search is the name of the container, or a prefix of its id
+func (c container) matches(id string) bool {
+	if id == "" || strings.HasPrefix(c.ID, id) {
+		return true
+	}
+	for _, name := range c.Names {
+		if strings.TrimPrefix(name, "/") == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Search returns the logs of the containers, the last lines of each container up to the limit of the search
+func (t *DockerSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	containers, err := t.containers(ctx, q)
+	if err != nil {
+		return results, err
+	}
+
+	for _, c := range containers {
+		if ctx.Err() != nil {
+			return results, ctx.Err()
+		}
+		lines, err := t.containerLogs(ctx, q, c)
+		if err != nil {
+			logger.Errorf("error fetching the logs of the container %s: %v", c.name(), err)
+			continue
+		}
+		results.Results = append(results.Results, lines...)
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// containers returns the containers matching the id and the labels of the search
+func (t *DockerSearch) containers(ctx context.Context, q *logs.SearchParams) ([]container, error) {
+	query := url.Values{}
+	if t.config.All {
+		query.Set("all", "true")
+	}
+	if len(q.Labels) > 0 {
+		var labels []string
+		for k, v := range q.Labels {
+			labels = append(labels, k+"="+v)
+		}
+		sort.Strings(labels)
+		filters, err := json.Marshal(map[string][]string{"label": labels})
+		if err != nil {
+			return nil, err
+		}
+		query.Set("filters", string(filters))
+	}
+
+	var containers []container
+	if err := t.client.Get(ctx, "/containers/json", query, &containers); err != nil {
+		return nil, fmt.Errorf("error listing the containers: %w", err)
+	}
+
+	var matching []container
+	for _, c := range containers {
+		if c.matches(q.Id) {
+			matching = append(matching, c)
+		}
+	}
+	return matching, nil
+}
+
+// containerLogs returns the lines logged by the container in the time range of the search
+func (t *DockerSearch) containerLogs(ctx context.Context, q *logs.SearchParams, c container) ([]logs.Result, error) {
+	query := url.Values{"stdout": {"true"}, "stderr": {"true"}, "timestamps": {"true"}}
+	if q.LimitPerItem > 0 {
+		query.Set("tail", strconv.FormatInt(q.LimitPerItem, 10))
+	} else if q.Limit > 0 {
+		query.Set("tail", strconv.FormatInt(q.Limit, 10))
+	}
+	if start := q.GetStart(); start != nil {
+		query.Set("since", unixTimestamp(*start))
+	}
+	if end := q.GetEnd(); end != nil {
+		query.Set("until", unixTimestamp(*end))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL("/containers/"+url.PathEscape(c.ID)+"/logs", query), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	labels := map[string]string{"container": c.name(), "container_id": shortID(c.ID), "image": c.Image}
+	for k, v := range c.Labels {
+		labels[k] = v
+	}
+	for k, v := range t.config.Labels {
+		labels[k] = v
+	}
+
+	var results []logs.Result
+	err = readLogs(resp.Body, func(stream, line string) {
+		lineLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			lineLabels[k] = v
+		}
+		if stream != "" {
+			lineLabels["stream"] = stream
+		}
+		results = append(results, logs.Result{Message: line, Labels: lineLabels}.Process())
+	})
+	return results, err
+}
+
+// readLogs calls fn with the lines of the logs, and their stream.
+// The logs of the containers without a tty are multiplexed, each frame having a header of the stream, 3 empty bytes
+// and the big endian uint32 size of the frame. The logs of the containers with a tty are raw, without stream.
+func readLogs(body io.Reader, fn func(stream, line string)) error {
+	reader := bufio.NewReader(body)
+	header, err := reader.Peek(8)
+	if len(header) == 0 {
+		return nil
+	}
+
+	// The raw lines start with their timestamp
+	if err != nil || header[0] > 2 || header[1] != 0 || header[2] != 0 || header[3] != 0 {
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			fn("", scanner.Text())
+		}
+		return scanner.Err()
+	}
+
+	for {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(reader, header); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("error reading the frame header: %w", err)
+		}
+		if header[0] > 2 {
+			return fmt.Errorf("invalid stream %d", header[0])
+		}
+
+		frame := make([]byte, binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			return fmt.Errorf("error reading the frame: %w", err)
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(frame), "\n"), "\n") {
+			fn(streams[header[0]], line)
+		}
+	}
+}
+
+func unixTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func (t *DockerSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+func (t *DockerSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
+
+// HealthCheck pings the daemon
+func (t *DockerSearch) HealthCheck(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL("/_ping", nil), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
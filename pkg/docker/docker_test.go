@@ -0,0 +1,94 @@
+package docker
+
+import (
+	"context"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// frame returns the multiplexed frame of the stream
+func frame(stream byte, data string) []byte {
+	header := []byte{stream, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+func TestDockerSearch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/json":
+			if got := r.URL.Query().Get("filters"); got != `{"label":["com.docker.compose.project=shop"]}` {
+				t.Errorf("unexpected filters %s", got)
+			}
+			_, _ = w.Write([]byte(`[
+				{"Id": "4f66ad9a0b2e1c3d", "Names": ["/shop-api-1"], "Image": "shop/api:1.2", "Labels": {"com.docker.compose.project": "shop"}},
+				{"Id": "9c1e0d7b5a4f3e2d", "Names": ["/shop-db-1"], "Image": "postgres:15", "Labels": {"com.docker.compose.project": "shop"}}
+			]`))
+		case "/containers/4f66ad9a0b2e1c3d/logs":
+			query := r.URL.Query()
+			if query.Get("since") != "1682942400.000000000" || query.Get("tail") != "100" || query.Get("timestamps") != "true" {
+				t.Errorf("unexpected query %s", r.URL.RawQuery)
+			}
+			_, _ = w.Write(frame(1, "2023-05-01T12:00:01.000000001Z GET /health 200\n"))
+			_, _ = w.Write(frame(2, "2023-05-01T12:00:02Z panic: nil map\n2023-05-01T12:00:02Z goroutine 1 [running]\n"))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := &logs.DockerBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"host": "vm-1"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+	}
+	backend, err := NewDockerSearchBackend(nil, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Id:           "shop-api-1",
+		Start:        "2023-05-01T12:00:00Z",
+		LimitPerItem: 100,
+		Labels:       map[string]string{"com.docker.compose.project": "shop"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := func(stream string) map[string]string {
+		return map[string]string{
+			"container": "shop-api-1", "container_id": "4f66ad9a0b2e", "image": "shop/api:1.2",
+			"com.docker.compose.project": "shop", "host": "vm-1", "stream": stream,
+		}
+	}
+	want := logs.SearchResults{Total: 3, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:01.000000001Z", Message: "GET /health 200", Labels: labels("stdout")},
+		{Time: "2023-05-01T12:00:02Z", Message: "panic: nil map", Labels: labels("stderr")},
+		{Time: "2023-05-01T12:00:02Z", Message: "goroutine 1 [running]", Labels: labels("stderr")},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+}
+
+func TestReadLogs(t *testing.T) {
+	var lines []string
+	err := readLogs(strings.NewReader("2023-05-01T12:00:01Z starting\r\n2023-05-01T12:00:02Z ready\n"), func(stream, line string) {
+		lines = append(lines, stream+"|"+line)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"|2023-05-01T12:00:01Z starting", "|2023-05-01T12:00:02Z ready"}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("readLogs() of a tty = %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,386 @@
+// Package dynatrace searches the logs of Dynatrace with the Logs api v2, or with DQL from Grail
+package dynatrace
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+	"github.com/jeremywohl/flatten"
+)
+
+const (
+	// maxLimit is the maximum number of records returned by a search of the Logs api v2
+	maxLimit = 1000
+
+	// pollTimeout is the time the DQL queries are waited for by a request
+	pollTimeout = 10 * time.Second
+)
+
+// defaultEntities are the types of the entities of the labels of the searches, by label
+var defaultEntities = map[string]string{
+	"host":          "HOST",
+	"process_group": "PROCESS_GROUP",
+	"service":       "SERVICE",
+}
+
+type DynatraceSearch struct {
+	config   *logs.DynatraceBackendConfig
+	client   *rest.Client
+	query    *template.Template
+	dql      *template.Template
+	entities map[string]string
+}
+
+func NewDynatraceSearchBackend(kClient *kommons.Client, config *logs.DynatraceBackendConfig) (*DynatraceSearch, error) {
+	if config.APIToken == nil {
+		return nil, fmt.Errorf("the api token is required")
+	}
+	query, err := template.New("query").Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the query template: %w", err)
+	}
+	dql, err := template.New("dql").Parse(config.DQL)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the dql template: %w", err)
+	}
+
+	_, token, err := kClient.GetEnvValue(*config.APIToken, config.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the api token: %w", err)
+	}
+	scheme := "Api-Token "
+	if config.DQL != "" {
+		scheme = "Bearer "
+	}
+	conn := config.HTTPConnection
+	conn.Headers = map[string]kommons.EnvVar{}
+	for name, header := range config.Headers {
+		conn.Headers[name] = header
+	}
+	conn.Headers["Authorization"] = kommons.EnvVar{Value: scheme + token}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	entities := config.Entities
+	if len(entities) == 0 {
+		entities = defaultEntities
+	}
+	return &DynatraceSearch{config: config, client: client, query: query, dql: dql, entities: entities}, nil
+}
+
+// entitySelector returns the entity selector of the entities of the type, named by the comma separated names
+func entitySelector(entityType, names string) string {
+	var quoted []string
+	for _, name := range strings.Split(names, ",") {
+		quoted = append(quoted, strconv.Quote(name))
+	}
+	return fmt.Sprintf("type(%q),entityName.in(%s)", entityType, strings.Join(quoted, ","))
+}
+
+// entityField returns the field of the logs holding the id of the entities of the type, e.g. dt.entity.host
+func entityField(entityType string) string {
+	return "dt.entity." + strings.ToLower(entityType)
+}
+
+// labels returns the labels of the search that are entities, sorted
+func (t *DynatraceSearch) labels(q *logs.SearchParams) []string {
+	var labels []string
+	for label := range t.entities {
+		if _, ok := q.Labels[label]; ok {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// RenderQuery renders the filter of the Logs api v2, with the query of the search when the template doesn't use it,
+// restricted to the ids of the entities of the labels of the search. It returns false when an entity of the labels doesn't exist, and no log can match.
+func (t *DynatraceSearch) RenderQuery(ctx context.Context, q *logs.SearchParams) (string, bool, error) {
+	var filters []string
+	if t.config.Query == "" {
+		if q.Query != "" {
+			filters = append(filters, q.Query)
+		}
+	} else {
+		var buf bytes.Buffer
+		if err := t.query.Execute(&buf, q); err != nil {
+			return "", false, fmt.Errorf("error executing the query template: %w", err)
+		}
+		if query := strings.TrimSpace(buf.String()); query != "" {
+			filters = append(filters, query)
+		}
+		if q.Query != "" && !strings.Contains(t.config.Query, ".Query") {
+			filters = append(filters, q.Query)
+		}
+	}
+
+	for _, label := range t.labels(q) {
+		ids, err := t.entityIDs(ctx, entitySelector(t.entities[label], q.Labels[label]))
+		if err != nil {
+			return "", false, err
+		}
+		if len(ids) == 0 {
+			return "", false, nil
+		}
+		var matches []string
+		for _, id := range ids {
+			matches = append(matches, fmt.Sprintf("%s=%q", entityField(t.entities[label]), id))
+		}
+		filters = append(filters, strings.Join(matches, " OR "))
+	}
+
+	if len(filters) == 1 {
+		return filters[0], true, nil
+	}
+	for i := range filters {
+		filters[i] = "(" + filters[i] + ")"
+	}
+	return strings.Join(filters, " AND "), true, nil
+}
+
+// entityIDs returns the ids of the entities of the entity selector
+func (t *DynatraceSearch) entityIDs(ctx context.Context, selector string) ([]string, error) {
+	var ids []string
+	query := url.Values{"entitySelector": {selector}, "pageSize": {"500"}}
+	for {
+		var res struct {
+			Entities []struct {
+				EntityID string `json:"entityId"`
+			} `json:"entities"`
+			NextPageKey string `json:"nextPageKey"`
+		}
+		if err := t.client.Get(ctx, "/api/v2/entities", query, &res); err != nil {
+			return nil, fmt.Errorf("error listing the entities of %s: %w", selector, err)
+		}
+		for _, e := range res.Entities {
+			ids = append(ids, e.EntityID)
+		}
+		if res.NextPageKey == "" {
+			return ids, nil
+		}
+		query = url.Values{"nextPageKey": {res.NextPageKey}}
+	}
+}
+
+// RenderDQL renders the DQL query, with the entities of the labels of the search selected by their entity selector,
+// the query of the search matched against the content when the template doesn't use it, and the last logs first
+func (t *DynatraceSearch) RenderDQL(q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
+	if err := t.dql.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing the dql template: %w", err)
+	}
+	query := strings.TrimSpace(buf.String())
+
+	for _, label := range t.labels(q) {
+		entityType := t.entities[label]
+		query += fmt.Sprintf("\n| filter in(%s, classicEntitySelector(%q))", entityField(entityType), entitySelector(entityType, q.Labels[label]))
+	}
+	if q.Query != "" && !strings.Contains(t.config.DQL, ".Query") {
+		query += fmt.Sprintf("\n| filter contains(content, %q)", q.Query)
+	}
+	query += "\n| sort timestamp desc"
+	if q.Limit > 0 {
+		query += fmt.Sprintf("\n| limit %d", q.Limit)
+	}
+	return query, nil
+}
+
+// Search returns the last logs, up to the limit of the search, chronologically.
+// The next page of the Logs api v2 returns the logs before them.
+func (t *DynatraceSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	if t.config.DQL != "" {
+		return t.searchDQL(ctx, q)
+	}
+
+	var results logs.SearchResults
+	query := url.Values{}
+	if q.Page != "" {
+		query.Set("nextPageKey", q.Page)
+	} else {
+		filter, ok, err := t.RenderQuery(ctx, q)
+		if err != nil || !ok {
+			return results, err
+		}
+		if filter != "" {
+			query.Set("query", filter)
+		}
+		if start := q.GetStart(); start != nil {
+			query.Set("from", start.UTC().Format(time.RFC3339Nano))
+		}
+		if end := q.GetEnd(); end != nil {
+			query.Set("to", end.UTC().Format(time.RFC3339Nano))
+		}
+		if q.Limit > 0 && q.Limit < maxLimit {
+			query.Set("limit", strconv.FormatInt(q.Limit, 10))
+		} else if q.Limit > 0 {
+			query.Set("limit", strconv.Itoa(maxLimit))
+		}
+		query.Set("sort", "-timestamp")
+	}
+
+	var res struct {
+		Results     []map[string]any `json:"results"`
+		NextPageKey string           `json:"nextPageKey"`
+	}
+	if err := t.client.Get(ctx, "/api/v2/logs/search", query, &res); err != nil {
+		return results, fmt.Errorf("error searching the logs: %w", err)
+	}
+
+	for i := len(res.Results) - 1; i >= 0; i-- {
+		results.Results = append(results.Results, t.result(res.Results[i]))
+	}
+	results.Total = len(results.Results)
+	results.NextPage = res.NextPageKey
+	return results, nil
+}
+
+// queryResponse is the response of the execution and polling of the DQL queries
+type queryResponse struct {
+	State        string `json:"state"`
+	RequestToken string `json:"requestToken"`
+	Result       *struct {
+		Records []map[string]any `json:"records"`
+	} `json:"result"`
+}
+
+// searchDQL executes the DQL query over the time range of the search and polls it until it succeeds
+func (t *DynatraceSearch) searchDQL(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+	query, err := t.RenderDQL(q)
+	if err != nil {
+		return results, err
+	}
+
+	body := map[string]any{
+		"query":                      query,
+		"requestTimeoutMilliseconds": pollTimeout.Milliseconds(),
+	}
+	if start := q.GetStart(); start != nil {
+		body["defaultTimeframeStart"] = start.UTC().Format(time.RFC3339Nano)
+	}
+	if end := q.GetEnd(); end != nil {
+		body["defaultTimeframeEnd"] = end.UTC().Format(time.RFC3339Nano)
+	}
+	if q.Limit > 0 {
+		body["maxResultRecords"] = q.Limit
+	}
+
+	var res queryResponse
+	if err := t.client.Post(ctx, "/platform/storage/query/v1/query:execute", nil, body, &res); err != nil {
+		return results, fmt.Errorf("error executing the query: %w", err)
+	}
+	for res.State == "NOT_STARTED" || res.State == "RUNNING" {
+		poll := url.Values{
+			"request-token":                {res.RequestToken},
+			"request-timeout-milliseconds": {strconv.FormatInt(pollTimeout.Milliseconds(), 10)},
+		}
+		res = queryResponse{}
+		if err := t.client.Get(ctx, "/platform/storage/query/v1/query:poll", poll, &res); err != nil {
+			return results, fmt.Errorf("error polling the query: %w", err)
+		}
+	}
+	if res.State != "SUCCEEDED" || res.Result == nil {
+		return results, fmt.Errorf("the query %s", strings.ToLower(res.State))
+	}
+
+	records := res.Result.Records
+	for i := len(records) - 1; i >= 0; i-- {
+		results.Results = append(results.Results, t.result(records[i]))
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// result returns the result of the log record, with its timestamp and content as the time and message of the result,
+// and its other fields, e.g. its status and the additional columns of the Logs api v2, as labels
+func (t *DynatraceSearch) result(record map[string]any) logs.Result {
+	r := logs.Result{Labels: make(map[string]string, len(record)+len(t.config.Labels))}
+	if columns, ok := record["additionalColumns"].(map[string]any); ok {
+		delete(record, "additionalColumns")
+		for column, value := range columns {
+			record[column] = value
+		}
+	}
+
+	for field, value := range record {
+		var v string
+		switch value := value.(type) {
+		case nil:
+			continue
+		case string:
+			v = value
+		case float64:
+			v = strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			v = strconv.FormatBool(value)
+		case []any:
+			var values []string
+			for _, value := range value {
+				values = append(values, fmt.Sprint(value))
+			}
+			v = strings.Join(values, ",")
+		case map[string]any:
+			fields, _ := flatten.Flatten(value, field+".", flatten.DotStyle)
+			for k, value := range fields {
+				if value != nil {
+					r.Labels[k] = fmt.Sprint(value)
+				}
+			}
+			continue
+		default:
+			v = fmt.Sprint(value)
+		}
+
+		switch field {
+		case "timestamp":
+			r.Time = timestamp(value)
+		case "content":
+			r.Message = v
+		default:
+			r.Labels[field] = v
+		}
+	}
+
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+// timestamp returns the time of the timestamp of the record, epoch milliseconds with the Logs api v2 and RFC3339 with DQL
+func timestamp(value any) string {
+	switch value := value.(type) {
+	case float64:
+		return time.UnixMilli(int64(value)).UTC().Format(time.RFC3339Nano)
+	case string:
+		if ts, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return ts.UTC().Format(time.RFC3339Nano)
+		}
+		if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.UnixMilli(ms).UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return ""
+}
+
+func (t *DynatraceSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Dynatrace backend, the DQL queries aren't paginated
+func (t *DynatraceSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: t.config.DQL == ""}
+}
@@ -0,0 +1,157 @@
+package dynatrace
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestDynatraceSearch(t *testing.T) {
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Api-Token secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v2/entities":
+			if r.URL.Query().Get("nextPageKey") == "" {
+				_, _ = w.Write([]byte(`{"entities": [{"entityId": "HOST-1", "displayName": "web-1"}], "nextPageKey": "more"}`))
+			} else {
+				_, _ = w.Write([]byte(`{"entities": [{"entityId": "HOST-2", "displayName": "web-2"}]}`))
+			}
+		case "/api/v2/logs/search":
+			queries = append(queries, r.URL.Query())
+			_, _ = w.Write([]byte(`{"results": [
+				{"timestamp": 1682942402500, "content": "connection refused", "status": "ERROR", "event.type": "LOG",
+				 "additionalColumns": {"dt.entity.host": ["HOST-2"], "k8s.pod.name": ["web-2"]}},
+				{"timestamp": 1682942401000, "content": "retrying", "status": "WARN", "event.type": "LOG",
+				 "additionalColumns": {"dt.entity.host": ["HOST-1"]}}
+			], "nextPageKey": "next"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewDynatraceSearchBackend(nil, &logs.DynatraceBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "dynatrace"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		APIToken:       &kommons.EnvVar{Value: "secret"},
+		Query:          `status="ERROR"`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query: `content="refused"`, Labels: map[string]string{"host": "web-1,web-2"}, Start: "2023-05-01T12:00:00Z", Limit: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, NextPage: "next", Results: []logs.Result{
+		{Time: "2023-05-01T12:00:01Z", Message: "retrying", Labels: map[string]string{
+			"status": "WARN", "event.type": "LOG", "dt.entity.host": "HOST-1", "source": "dynatrace",
+		}},
+		{Time: "2023-05-01T12:00:02.5Z", Message: "connection refused", Labels: map[string]string{
+			"status": "ERROR", "event.type": "LOG", "dt.entity.host": "HOST-2", "k8s.pod.name": "web-2", "source": "dynatrace",
+		}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Page: "next"}); err != nil {
+		t.Fatal(err)
+	}
+
+	wantQueries := []url.Values{
+		{
+			"query": {`(status="ERROR") AND (content="refused") AND (dt.entity.host="HOST-1" OR dt.entity.host="HOST-2")`},
+			"from":  {"2023-05-01T12:00:00Z"}, "limit": {"2"}, "sort": {"-timestamp"},
+		},
+		{"nextPageKey": {"next"}},
+	}
+	if !reflect.DeepEqual(queries, wantQueries) {
+		t.Errorf("queries = %v, want %v", queries, wantQueries)
+	}
+}
+
+func TestDynatraceSearchDQL(t *testing.T) {
+	var request map[string]any
+	var polls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/platform/storage/query/v1/query:execute":
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Fatal(err)
+			}
+			_, _ = w.Write([]byte(`{"state": "RUNNING", "requestToken": "token"}`))
+		case "/platform/storage/query/v1/query:poll":
+			polls++
+			if r.URL.Query().Get("request-token") != "token" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"state": "SUCCEEDED", "result": {"records": [
+				{"timestamp": "2023-05-01T12:00:02.000000000Z", "content": "OOMKilled", "loglevel": "ERROR", "k8s": {"namespace": {"name": "shop"}}}
+			]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewDynatraceSearchBackend(nil, &logs.DynatraceBackendConfig{
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		APIToken:       &kommons.EnvVar{Value: "secret"},
+		DQL:            `fetch logs | filter k8s.namespace.name == "{{index .Labels "namespace"}}"`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.Capabilities().Pagination {
+		t.Error("the DQL queries aren't paginated")
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query: "OOM", Labels: map[string]string{"namespace": "shop", "service": "checkout"}, Start: "2023-05-01T12:00:00Z", Limit: 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 1, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:02Z", Message: "OOMKilled", Labels: map[string]string{"loglevel": "ERROR", "k8s.namespace.name": "shop"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+	if polls != 1 {
+		t.Errorf("polls = %d, want 1", polls)
+	}
+
+	wantRequest := map[string]any{
+		"query": `fetch logs | filter k8s.namespace.name == "shop"` +
+			"\n" + `| filter in(dt.entity.service, classicEntitySelector("type(\"SERVICE\"),entityName.in(\"checkout\")"))` +
+			"\n" + `| filter contains(content, "OOM")` +
+			"\n| sort timestamp desc\n| limit 10",
+		"requestTimeoutMilliseconds": float64(10000),
+		"defaultTimeframeStart":      "2023-05-01T12:00:00Z",
+		"maxResultRecords":           float64(10),
+	}
+	if !reflect.DeepEqual(request, wantRequest) {
+		t.Errorf("request = %v, want %v", request, wantRequest)
+	}
+}
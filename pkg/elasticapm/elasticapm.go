@@ -0,0 +1,304 @@
+// Package elasticapm searches the errors and transactions of Elastic APM
+package elasticapm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
+	"github.com/flanksource/apm-hub/api/logs"
+	pkgElasticsearch "github.com/flanksource/apm-hub/external/elasticsearch"
+)
+
+const (
+	defaultErrorIndex       = "logs-apm.error-*,apm-*-error*"
+	defaultTransactionIndex = "traces-apm*,apm-*-transaction*"
+
+	transactionsFailure = "failure"
+	transactionsAll     = "all"
+	transactionsNone    = "none"
+)
+
+// filters are the fields of the APM documents filtered by the labels of the search
+var filters = [][2]string{
+	{"environment", "service.environment"},
+	{"transaction", "transaction.name"},
+	{"trace_id", "trace.id"},
+	{"event", "processor.event"},
+	{"outcome", "event.outcome"},
+}
+
+// queryFields are the fields of the APM documents searched by the query of the search
+var queryFields = []string{"error.exception.message", "error.log.message", "error.culprit", "transaction.name"}
+
+type ElasticAPMSearch struct {
+	client  *elasticsearch.Client
+	config  *logs.ElasticAPMBackendConfig
+	indices string
+}
+
+func NewElasticAPMSearchBackend(client *elasticsearch.Client, config *logs.ElasticAPMBackendConfig) (*ElasticAPMSearch, error) {
+	if client == nil {
+		return nil, fmt.Errorf("client is nil")
+	}
+
+	switch config.Transactions {
+	case "", transactionsFailure, transactionsAll, transactionsNone:
+	default:
+		return nil, fmt.Errorf("invalid transactions %q, expected one of failure, all or none", config.Transactions)
+	}
+
+	indices := config.ErrorIndex
+	if indices == "" {
+		indices = defaultErrorIndex
+	}
+	if config.Transactions != transactionsNone {
+		transactionIndex := config.TransactionIndex
+		if transactionIndex == "" {
+			transactionIndex = defaultTransactionIndex
+		}
+		indices += "," + transactionIndex
+	}
+
+	return &ElasticAPMSearch{client: client, config: config, indices: indices}, nil
+}
+
+// Query returns the search body of the errors and transactions of the service of the id, the last ones first.
+// The page is the sort values of the last document of the previous page.
+func (t *ElasticAPMSearch) Query(q *logs.SearchParams) (map[string]any, error) {
+	events := []any{
+		map[string]any{"term": map[string]any{"processor.event": "error"}},
+	}
+	switch t.config.Transactions {
+	case transactionsAll:
+		events = append(events, map[string]any{"term": map[string]any{"processor.event": "transaction"}})
+	case "", transactionsFailure:
+		events = append(events, map[string]any{"bool": map[string]any{"filter": []any{
+			map[string]any{"term": map[string]any{"processor.event": "transaction"}},
+			map[string]any{"term": map[string]any{"event.outcome": "failure"}},
+		}}})
+	}
+
+	filter := []any{
+		map[string]any{"bool": map[string]any{"should": events, "minimum_should_match": 1}},
+	}
+	if q.Id != "" {
+		filter = append(filter, map[string]any{"term": map[string]any{"service.name": q.Id}})
+	}
+	timeRange := map[string]any{}
+	if start := q.GetStart(); start != nil {
+		timeRange["gte"] = start.UTC().Format(time.RFC3339Nano)
+	}
+	if end := q.GetEnd(); end != nil {
+		timeRange["lte"] = end.UTC().Format(time.RFC3339Nano)
+	}
+	if len(timeRange) > 0 {
+		filter = append(filter, map[string]any{"range": map[string]any{"@timestamp": timeRange}})
+	}
+	for _, f := range filters {
+		if value, ok := q.Labels[f[0]]; ok {
+			filter = append(filter, map[string]any{"terms": map[string]any{f[1]: strings.Split(value, ",")}})
+		}
+	}
+
+	query := map[string]any{"filter": filter}
+	if q.Query != "" {
+		query["must"] = []any{map[string]any{"simple_query_string": map[string]any{
+			"query":            q.Query,
+			"fields":           queryFields,
+			"default_operator": "and",
+		}}}
+	}
+
+	body := map[string]any{
+		"query": map[string]any{"bool": query},
+		"sort":  []any{map[string]any{"@timestamp": "desc"}},
+	}
+	if q.Page != "" {
+		var after []any
+		if err := json.Unmarshal([]byte(q.Page), &after); err != nil {
+			return nil, fmt.Errorf("invalid page %s: %w", q.Page, err)
+		}
+		body["search_after"] = after
+	}
+	return body, nil
+}
+
+// Search returns the last errors and transactions, up to the limit of the search, chronologically.
+// The next page returns the ones before them.
+func (t *ElasticAPMSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var result logs.SearchResults
+	body, err := t.Query(q)
+	if err != nil {
+		return result, err
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return result, fmt.Errorf("error encoding the query: %w", err)
+	}
+
+	opts := []func(*esapi.SearchRequest){
+		t.client.Search.WithContext(ctx),
+		t.client.Search.WithIndex(strings.Split(t.indices, ",")...),
+		t.client.Search.WithBody(bytes.NewReader(data)),
+		t.client.Search.WithIgnoreUnavailable(true),
+	}
+	if q.Limit > 0 {
+		opts = append(opts, t.client.Search.WithSize(int(q.Limit+1)))
+	}
+	res, err := t.client.Search(opts...)
+	if err != nil {
+		return result, fmt.Errorf("error searching: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return result, fmt.Errorf("error searching: %s", res.String())
+	}
+
+	var r pkgElasticsearch.SearchResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return result, fmt.Errorf("error parsing the response body: %w", err)
+	}
+
+	hits := r.Hits.Hits
+	if q.Limit > 0 {
+		result.NextPage = r.Hits.NextPage(int(q.Limit))
+		if int64(len(hits)) > q.Limit {
+			hits = hits[:q.Limit]
+		}
+	}
+	for i := len(hits) - 1; i >= 0; i-- {
+		result.Results = append(result.Results, t.result(hits[i]))
+	}
+	result.Total = int(r.Hits.Total.Value)
+	return result, nil
+}
+
+// document holds the fields of the errors and transactions of the APM documents
+type document struct {
+	Timestamp string `json:"@timestamp"`
+	Processor struct {
+		Event string `json:"event"`
+	} `json:"processor"`
+	Service struct {
+		Name        string `json:"name"`
+		Environment string `json:"environment"`
+		Node        struct {
+			Name string `json:"name"`
+		} `json:"node"`
+	} `json:"service"`
+	Event struct {
+		Outcome string `json:"outcome"`
+	} `json:"event"`
+	Trace struct {
+		ID string `json:"id"`
+	} `json:"trace"`
+	Transaction struct {
+		ID       string `json:"id"`
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		Result   string `json:"result"`
+		Duration struct {
+			US int64 `json:"us"`
+		} `json:"duration"`
+	} `json:"transaction"`
+	Error struct {
+		GroupingKey string `json:"grouping_key"`
+		Culprit     string `json:"culprit"`
+		Exception   []struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"exception"`
+		Log struct {
+			Message string `json:"message"`
+			Level   string `json:"level"`
+		} `json:"log"`
+	} `json:"error"`
+}
+
+// result returns the result of the error, with the message of its exception or log,
+// or of the transaction, with its name, result and duration
+func (t *ElasticAPMSearch) result(hit pkgElasticsearch.SearchHit) logs.Result {
+	r := logs.Result{Id: hit.ID, Labels: map[string]string{}}
+
+	var doc document
+	if data, err := json.Marshal(hit.Source); err == nil {
+		_ = json.Unmarshal(data, &doc)
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, doc.Timestamp); err == nil {
+		r.Time = ts.UTC().Format(time.RFC3339Nano)
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			r.Labels[key] = value
+		}
+	}
+	set("event", doc.Processor.Event)
+	set("service", doc.Service.Name)
+	set("environment", doc.Service.Environment)
+	set("node", doc.Service.Node.Name)
+	set("outcome", doc.Event.Outcome)
+	set("trace_id", doc.Trace.ID)
+	set("transaction", doc.Transaction.Name)
+	set("transaction_id", doc.Transaction.ID)
+
+	switch doc.Processor.Event {
+	case "error":
+		r.Labels["level"] = "error"
+		set("grouping_key", doc.Error.GroupingKey)
+		set("culprit", doc.Error.Culprit)
+		if len(doc.Error.Exception) > 0 {
+			e := doc.Error.Exception[0]
+			set("error_type", e.Type)
+			r.Message = e.Message
+			if e.Type != "" {
+				r.Message = strings.TrimSuffix(e.Type+": "+e.Message, ": ")
+			}
+		} else {
+			r.Message = doc.Error.Log.Message
+			set("level", doc.Error.Log.Level)
+		}
+	case "transaction":
+		if doc.Event.Outcome == "failure" {
+			r.Labels["level"] = "error"
+		}
+		set("transaction_type", doc.Transaction.Type)
+		set("result", doc.Transaction.Result)
+		duration := time.Duration(doc.Transaction.Duration.US) * time.Microsecond
+		set("duration_ms", strconv.FormatInt(duration.Milliseconds(), 10))
+		r.Message = strings.TrimSpace(doc.Transaction.Name + " " + doc.Transaction.Result + " " + duration.String())
+	}
+
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *ElasticAPMSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+func (t *ElasticAPMSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck pings the cluster
+func (t *ElasticAPMSearch) HealthCheck(ctx context.Context) error {
+	res, err := t.client.Ping(t.client.Ping.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error pinging the cluster: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("error pinging the cluster: %s", res.Status())
+	}
+	return nil
+}
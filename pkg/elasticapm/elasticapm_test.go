@@ -0,0 +1,136 @@
+package elasticapm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+const searchResponse = `{"hits": {"total": {"value": 12}, "hits": [
+	{
+		"_id": "e2", "sort": [1682942403000],
+		"_source": {
+			"@timestamp": "2023-05-01T12:00:03Z", "processor": {"event": "error"},
+			"service": {"name": "checkout", "environment": "production", "node": {"name": "checkout-7d9f"}},
+			"trace": {"id": "t1"}, "transaction": {"id": "tx1", "name": "POST /orders"},
+			"error": {"grouping_key": "abc", "culprit": "orders.create", "exception": [{"type": "TimeoutError", "message": "database timed out"}]}
+		}
+	},
+	{
+		"_id": "tx1", "sort": [1682942402000],
+		"_source": {
+			"@timestamp": "2023-05-01T12:00:02Z", "processor": {"event": "transaction"},
+			"service": {"name": "checkout", "environment": "production"}, "event": {"outcome": "failure"},
+			"trace": {"id": "t1"}, "transaction": {"id": "tx1", "name": "POST /orders", "type": "request", "result": "HTTP 5xx", "duration": {"us": 1500000}}
+		}
+	},
+	{
+		"_id": "e1", "sort": [1682942401000],
+		"_source": {"@timestamp": "2023-05-01T12:00:01Z", "processor": {"event": "error"}, "service": {"name": "checkout"}, "error": {"log": {"message": "retrying", "level": "warning"}}}
+	}
+]}}`
+
+func TestElasticAPMSearch(t *testing.T) {
+	var path string
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Elastic-Product", "Elasticsearch")
+		w.Header().Set("Content-Type", "application/json")
+		path = r.URL.Path
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(searchResponse))
+	}))
+	defer server.Close()
+
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{server.URL}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewElasticAPMSearchBackend(client, &logs.ElasticAPMBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"source": "apm"}},
+		ErrorIndex:    "apm-error",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Id: "checkout", Query: "timed out", Start: "2023-05-01T12:00:00Z", Limit: 2, Page: "[1682942404000]",
+		Labels: map[string]string{"environment": "production,staging", "pod": "checkout-7d9f"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 12, NextPage: "[1682942402000]", Results: []logs.Result{
+		{Id: "tx1", Time: "2023-05-01T12:00:02Z", Message: "POST /orders HTTP 5xx 1.5s", Labels: map[string]string{
+			"event": "transaction", "service": "checkout", "environment": "production", "outcome": "failure", "trace_id": "t1",
+			"transaction": "POST /orders", "transaction_id": "tx1", "level": "error", "transaction_type": "request",
+			"result": "HTTP 5xx", "duration_ms": "1500", "source": "apm",
+		}},
+		{Id: "e2", Time: "2023-05-01T12:00:03Z", Message: "TimeoutError: database timed out", Labels: map[string]string{
+			"event": "error", "service": "checkout", "environment": "production", "node": "checkout-7d9f", "trace_id": "t1",
+			"transaction": "POST /orders", "transaction_id": "tx1", "level": "error", "grouping_key": "abc",
+			"culprit": "orders.create", "error_type": "TimeoutError", "source": "apm",
+		}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	if wantPath := "/apm-error,traces-apm*,apm-*-transaction*/_search"; path != wantPath {
+		t.Errorf("path = %s, want %s", path, wantPath)
+	}
+	wantRequest := map[string]any{
+		"query": map[string]any{"bool": map[string]any{
+			"filter": []any{
+				map[string]any{"bool": map[string]any{"minimum_should_match": float64(1), "should": []any{
+					map[string]any{"term": map[string]any{"processor.event": "error"}},
+					map[string]any{"bool": map[string]any{"filter": []any{
+						map[string]any{"term": map[string]any{"processor.event": "transaction"}},
+						map[string]any{"term": map[string]any{"event.outcome": "failure"}},
+					}}},
+				}}},
+				map[string]any{"term": map[string]any{"service.name": "checkout"}},
+				map[string]any{"range": map[string]any{"@timestamp": map[string]any{"gte": "2023-05-01T12:00:00Z"}}},
+				map[string]any{"terms": map[string]any{"service.environment": []any{"production", "staging"}}},
+			},
+			"must": []any{map[string]any{"simple_query_string": map[string]any{
+				"query":            "timed out",
+				"fields":           []any{"error.exception.message", "error.log.message", "error.culprit", "transaction.name"},
+				"default_operator": "and",
+			}}},
+		}},
+		"sort":         []any{map[string]any{"@timestamp": "desc"}},
+		"search_after": []any{float64(1682942404000)},
+	}
+	if !reflect.DeepEqual(request, wantRequest) {
+		t.Errorf("request = %v, want %v", request, wantRequest)
+	}
+}
+
+func TestNewElasticAPMSearchBackend(t *testing.T) {
+	client, err := elasticsearch.NewClient(elasticsearch.Config{Addresses: []string{"http://localhost:9200"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err := NewElasticAPMSearchBackend(client, &logs.ElasticAPMBackendConfig{Transactions: "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backend.indices != defaultErrorIndex {
+		t.Errorf("indices = %s, want %s", backend.indices, defaultErrorIndex)
+	}
+
+	if _, err := NewElasticAPMSearchBackend(client, &logs.ElasticAPMBackendConfig{Transactions: "some"}); err == nil {
+		t.Error("expected an error for invalid transactions")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"text/template"
 
 	"github.com/elastic/go-elasticsearch/v8"
@@ -47,18 +48,44 @@ func (t *ElasticSearchBackend) MatchRoute(q *logs.SearchParams) (match bool, isA
 	return t.config.CommonBackend.Routes.MatchRoute(q)
 }
 
-func (t *ElasticSearchBackend) Search(q *logs.SearchParams) (logs.SearchResults, error) {
-	var result logs.SearchResults
-	var buf bytes.Buffer
+func (t *ElasticSearchBackend) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true, Aggregations: true}
+}
+
+// HealthCheck pings the cluster
+func (t *ElasticSearchBackend) HealthCheck(ctx context.Context) error {
+	res, err := t.client.Ping(t.client.Ping.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error pinging the cluster: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return fmt.Errorf("error pinging the cluster: %s", res.Status())
+	}
+	return nil
+}
 
+// RenderQuery renders the query template for the given search params.
+func (t *ElasticSearchBackend) RenderQuery(q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
 	if err := t.template.Execute(&buf, q); err != nil {
-		return result, fmt.Errorf("error executing template: %w", err)
+		return "", fmt.Errorf("error executing template: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+func (t *ElasticSearchBackend) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var result logs.SearchResults
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return result, err
 	}
 
 	res, err := t.client.Search(
-		t.client.Search.WithContext(context.Background()),
+		t.client.Search.WithContext(ctx),
 		t.client.Search.WithIndex(t.index),
-		t.client.Search.WithBody(&buf),
+		t.client.Search.WithBody(strings.NewReader(query)),
 		t.client.Search.WithSize(int(q.Limit+1)),
 		t.client.Search.WithErrorTrace(),
 	)
@@ -77,3 +104,37 @@ func (t *ElasticSearchBackend) Search(q *logs.SearchParams) (logs.SearchResults,
 	result.NextPage = r.Hits.NextPage(int(q.Limit))
 	return result, nil
 }
+
+// TopValues counts the most frequent values of the label with a terms aggregation
+func (t *ElasticSearchBackend) TopValues(ctx context.Context, q *logs.SearchParams, label string, size int) ([]logs.LabelValue, error) {
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err = pkgElasticsearch.TermsAggregationQuery(query, label, size)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.client.Search(
+		t.client.Search.WithContext(ctx),
+		t.client.Search.WithIndex(t.index),
+		t.client.Search.WithBody(strings.NewReader(query)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error searching: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.IsError() {
+		return nil, fmt.Errorf("error aggregating: %s", res.String())
+	}
+
+	var r pkgElasticsearch.TermsAggregationResponse
+	if err := json.NewDecoder(res.Body).Decode(&r); err != nil {
+		return nil, fmt.Errorf("error parsing the response body: %w", err)
+	}
+
+	return r.LabelValues(), nil
+}
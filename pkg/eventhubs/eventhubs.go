@@ -0,0 +1,306 @@
+// Package eventhubs replays the events of an Azure event hub enqueued within the time range of the searches
+package eventhubs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azeventhubs"
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/kommons"
+)
+
+// batchSize is the maximum number of events received at once
+const batchSize = 100
+
+// partitionReader receives the events of a partition, from its start position
+type partitionReader interface {
+	ReceiveEvents(ctx context.Context, count int, options *azeventhubs.ReceiveEventsOptions) ([]*azeventhubs.ReceivedEventData, error)
+	Close(ctx context.Context) error
+}
+
+// consumer reads the partitions of the event hub
+type consumer interface {
+	GetEventHubProperties(ctx context.Context, options *azeventhubs.GetEventHubPropertiesOptions) (azeventhubs.EventHubProperties, error)
+	GetPartitionProperties(ctx context.Context, partitionID string, options *azeventhubs.GetPartitionPropertiesOptions) (azeventhubs.PartitionProperties, error)
+	NewPartitionReader(partitionID string, options *azeventhubs.PartitionClientOptions) (partitionReader, error)
+}
+
+type consumerClient struct {
+	*azeventhubs.ConsumerClient
+}
+
+func (t consumerClient) NewPartitionReader(partitionID string, options *azeventhubs.PartitionClientOptions) (partitionReader, error) {
+	return t.NewPartitionClient(partitionID, options)
+}
+
+type EventHubsSearch struct {
+	config  *logs.EventHubsBackendConfig
+	client  consumer
+	timeout time.Duration
+}
+
+func NewEventHubsSearchBackend(kClient *kommons.Client, config *logs.EventHubsBackendConfig) (*EventHubsSearch, error) {
+	timeout := 30 * time.Second
+	if config.Timeout != "" {
+		d, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the timeout: %w", err)
+		}
+		timeout = time.Duration(d)
+	}
+
+	consumerGroup := config.ConsumerGroup
+	if consumerGroup == "" {
+		consumerGroup = azeventhubs.DefaultConsumerGroup
+	}
+
+	var client *azeventhubs.ConsumerClient
+	if config.ConnectionString != nil {
+		_, connectionString, err := kClient.GetEnvValue(*config.ConnectionString, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the connection string: %w", err)
+		}
+		if client, err = azeventhubs.NewConsumerClientFromConnectionString(connectionString, config.EventHub, consumerGroup, nil); err != nil {
+			return nil, fmt.Errorf("error creating the consumer client: %w", err)
+		}
+	} else {
+		if config.FullyQualifiedNamespace == "" || config.EventHub == "" {
+			return nil, fmt.Errorf("the connection string, or the fully qualified namespace and the event hub are required")
+		}
+		credential, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the azure credentials: %w", err)
+		}
+		if client, err = azeventhubs.NewConsumerClient(config.FullyQualifiedNamespace, config.EventHub, consumerGroup, credential, nil); err != nil {
+			return nil, fmt.Errorf("error creating the consumer client: %w", err)
+		}
+	}
+
+	return &EventHubsSearch{config: config, client: consumerClient{client}, timeout: timeout}, nil
+}
+
+// event is a result with the position of its event
+type event struct {
+	partition string
+	sequence  int64
+	time      time.Time
+	result    logs.Result
+}
+
+// Search replays the events of every partition enqueued from the start of the search, or from the sequence numbers of the page,
+// until the end of the search, the last event enqueued before the search or the limit of the search.
+// The next page holds the sequence number of the next event of the partitions that weren't read to the end.
+func (t *EventHubsSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	page, err := parsePage(q.Page)
+	if err != nil {
+		return results, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	partitions := t.config.Partitions
+	if page != nil {
+		partitions = make([]string, 0, len(page))
+		for id := range page {
+			partitions = append(partitions, id)
+		}
+		sort.Strings(partitions)
+	} else if len(partitions) == 0 {
+		props, err := t.client.GetEventHubProperties(ctx, nil)
+		if err != nil {
+			return results, fmt.Errorf("error getting the partitions of the event hub: %w", err)
+		}
+		partitions = props.PartitionIDs
+	}
+
+	var events []event
+	next := make(map[string]int64)
+	for _, id := range partitions {
+		read, nextSequence, err := t.readPartition(ctx, id, q, page)
+		if err != nil {
+			return results, fmt.Errorf("error reading partition %s: %w", id, err)
+		}
+		events = append(events, read...)
+		if nextSequence >= 0 {
+			next[id] = nextSequence
+		}
+	}
+
+	// The events of a partition are in order, those past the limit are read again from the next page
+	sort.SliceStable(events, func(i, j int) bool { return events[i].time.Before(events[j].time) })
+	if q.Limit > 0 && int64(len(events)) > q.Limit {
+		for _, e := range events[q.Limit:] {
+			if seq, ok := next[e.partition]; !ok || e.sequence < seq {
+				next[e.partition] = e.sequence
+			}
+		}
+		events = events[:q.Limit]
+	}
+
+	for _, e := range events {
+		results.Results = append(results.Results, e.result)
+	}
+	results.Total = len(results.Results)
+	results.NextPage = formatPage(next)
+	return results, nil
+}
+
+// readPartition returns the events of the partition matching the search, and the sequence number of its next event,
+// or -1 once the partition was read to the end of the search.
+func (t *EventHubsSearch) readPartition(ctx context.Context, id string, q *logs.SearchParams, page map[string]int64) ([]event, int64, error) {
+	props, err := t.client.GetPartitionProperties(ctx, id, nil)
+	if err != nil {
+		return nil, -1, fmt.Errorf("error getting the partition properties: %w", err)
+	}
+	if props.IsEmpty {
+		return nil, -1, nil
+	}
+
+	// The reader waits for the next event once it's read the last one, so the events enqueued after it are never requested
+	last := props.LastEnqueuedSequenceNumber
+	position := azeventhubs.StartPosition{Inclusive: true}
+	if seq, ok := page[id]; ok {
+		if seq > last {
+			return nil, -1, nil
+		}
+		position.SequenceNumber = &seq
+	} else if start := q.GetStart(); start != nil {
+		if props.LastEnqueuedOn.Before(*start) {
+			return nil, -1, nil
+		}
+		position.EnqueuedTime = start
+	} else {
+		earliest := true
+		position.Earliest = &earliest
+	}
+
+	reader, err := t.client.NewPartitionReader(id, &azeventhubs.PartitionClientOptions{StartPosition: position})
+	if err != nil {
+		return nil, -1, fmt.Errorf("error creating the partition client: %w", err)
+	}
+	defer func() { _ = reader.Close(ctx) }()
+
+	var events []event
+	end := q.GetEnd()
+	query := strings.ToLower(q.Query)
+	count := 1
+	for {
+		received, err := reader.ReceiveEvents(ctx, count, nil)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, -1, fmt.Errorf("the search didn't complete before the deadline: %w", err)
+			}
+			return nil, -1, err
+		}
+
+		for _, e := range received {
+			enqueued := time.Time{}
+			if e.EnqueuedTime != nil {
+				enqueued = *e.EnqueuedTime
+			}
+			if end != nil && enqueued.After(*end) {
+				return events, -1, nil
+			}
+
+			if r, ok := t.result(id, e, enqueued, q.Labels, query); ok {
+				events = append(events, event{partition: id, sequence: e.SequenceNumber, time: enqueued, result: r})
+				if q.Limit > 0 && int64(len(events)) >= q.Limit && e.SequenceNumber < last {
+					return events, e.SequenceNumber + 1, nil
+				}
+			}
+			if e.SequenceNumber >= last {
+				return events, -1, nil
+			}
+		}
+
+		count = batchSize
+		if remaining := last - received[len(received)-1].SequenceNumber; remaining < batchSize {
+			count = int(remaining)
+		}
+	}
+}
+
+// result returns the result of the event, false when it doesn't match the labels or the query of the search.
+// The application properties of the event are labels.
+func (t *EventHubsSearch) result(partition string, e *azeventhubs.ReceivedEventData, enqueued time.Time, labels map[string]string, query string) (logs.Result, bool) {
+	message := strings.TrimSpace(string(e.Body))
+	if query != "" && !strings.Contains(strings.ToLower(message), query) {
+		return logs.Result{}, false
+	}
+
+	r := logs.Result{
+		Id:      partition + "/" + strconv.FormatInt(e.SequenceNumber, 10),
+		Time:    enqueued.UTC().Format(time.RFC3339Nano),
+		Message: message,
+		Labels:  map[string]string{"partition": partition},
+	}
+	for name, value := range e.Properties {
+		r.Labels[name] = fmt.Sprint(value)
+	}
+	for k, v := range labels {
+		if value, ok := r.Labels[k]; ok && value != v {
+			return logs.Result{}, false
+		}
+	}
+
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r, true
+}
+
+// parsePage returns the sequence numbers of the page, by partition
+func parsePage(page string) (map[string]int64, error) {
+	if page == "" {
+		return nil, nil
+	}
+
+	sequences := make(map[string]int64)
+	for _, position := range strings.Split(page, ",") {
+		id, seq, ok := strings.Cut(position, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid page %s", page)
+		}
+		n, err := strconv.ParseInt(seq, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid page %s: %w", page, err)
+		}
+		sequences[id] = n
+	}
+	return sequences, nil
+}
+
+// formatPage returns the page of the sequence numbers, e.g. 0:42,1:7
+func formatPage(sequences map[string]int64) string {
+	positions := make([]string, 0, len(sequences))
+	for id, seq := range sequences {
+		positions = append(positions, id+":"+strconv.FormatInt(seq, 10))
+	}
+	sort.Strings(positions)
+	return strings.Join(positions, ",")
+}
+
+func (t *EventHubsSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+func (t *EventHubsSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck gets the properties of the event hub
+func (t *EventHubsSearch) HealthCheck(ctx context.Context) error {
+	_, err := t.client.GetEventHubProperties(ctx, nil)
+	return err
+}
@@ -0,0 +1,156 @@
+package eventhubs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azeventhubs"
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// fakeConsumer serves the events of its partitions, in order of sequence number
+type fakeConsumer struct {
+	partitions map[string][]*azeventhubs.ReceivedEventData
+}
+
+func (t *fakeConsumer) GetEventHubProperties(ctx context.Context, options *azeventhubs.GetEventHubPropertiesOptions) (azeventhubs.EventHubProperties, error) {
+	return azeventhubs.EventHubProperties{PartitionIDs: []string{"0", "1"}}, nil
+}
+
+func (t *fakeConsumer) GetPartitionProperties(ctx context.Context, partitionID string, options *azeventhubs.GetPartitionPropertiesOptions) (azeventhubs.PartitionProperties, error) {
+	events := t.partitions[partitionID]
+	if len(events) == 0 {
+		return azeventhubs.PartitionProperties{PartitionID: partitionID, IsEmpty: true}, nil
+	}
+	last := events[len(events)-1]
+	return azeventhubs.PartitionProperties{PartitionID: partitionID, LastEnqueuedSequenceNumber: last.SequenceNumber, LastEnqueuedOn: *last.EnqueuedTime}, nil
+}
+
+func (t *fakeConsumer) NewPartitionReader(partitionID string, options *azeventhubs.PartitionClientOptions) (partitionReader, error) {
+	var events []*azeventhubs.ReceivedEventData
+	for _, e := range t.partitions[partitionID] {
+		position := options.StartPosition
+		if (position.SequenceNumber != nil && e.SequenceNumber < *position.SequenceNumber) ||
+			(position.EnqueuedTime != nil && e.EnqueuedTime.Before(*position.EnqueuedTime)) {
+			continue
+		}
+		events = append(events, e)
+	}
+	return &fakeReader{events: events}, nil
+}
+
+type fakeReader struct {
+	events []*azeventhubs.ReceivedEventData
+}
+
+// ReceiveEvents waits for the context to be done when there are no more events, as the partition clients do
+func (t *fakeReader) ReceiveEvents(ctx context.Context, count int, options *azeventhubs.ReceiveEventsOptions) ([]*azeventhubs.ReceivedEventData, error) {
+	if len(t.events) == 0 {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	if count > len(t.events) {
+		count = len(t.events)
+	}
+	events := t.events[:count]
+	t.events = t.events[count:]
+	return events, nil
+}
+
+func (t *fakeReader) Close(ctx context.Context) error {
+	return nil
+}
+
+func newEvent(seq int64, minute int, body string, properties map[string]any) *azeventhubs.ReceivedEventData {
+	enqueued := time.Date(2023, 5, 1, 12, minute, 0, 0, time.UTC)
+	return &azeventhubs.ReceivedEventData{
+		EventData:      azeventhubs.EventData{Body: []byte(body), Properties: properties},
+		EnqueuedTime:   &enqueued,
+		SequenceNumber: seq,
+	}
+}
+
+func TestSearch(t *testing.T) {
+	backend := &EventHubsSearch{
+		config: &logs.EventHubsBackendConfig{CommonBackend: logs.CommonBackend{Labels: map[string]string{"source": "eventhubs"}}},
+		client: &fakeConsumer{partitions: map[string][]*azeventhubs.ReceivedEventData{
+			"0": {
+				newEvent(10, 0, "connection refused", map[string]any{"category": "AppServiceHTTPLogs"}),
+				newEvent(11, 2, "connection timeout", map[string]any{"category": "AppServiceConsoleLogs"}),
+				newEvent(12, 4, "disk full", nil),
+				newEvent(13, 9, "connection reset", nil),
+			},
+			"1": {
+				newEvent(5, 1, "connection timeout\n", map[string]any{"category": "AppServiceConsoleLogs", "retries": 3}),
+				newEvent(6, 3, "connection closed", nil),
+			},
+		}},
+		timeout: time.Second,
+	}
+
+	ids := func(results logs.SearchResults) []string {
+		var ids []string
+		for _, r := range results.Results {
+			ids = append(ids, r.Id)
+		}
+		return ids
+	}
+
+	tests := []struct {
+		name     string
+		params   logs.SearchParams
+		wantIDs  []string
+		wantNext string
+	}{
+		{
+			name:    "time range",
+			params:  logs.SearchParams{Query: "connection", Start: "2023-05-01T12:01:00Z", End: "2023-05-01T12:05:00Z"},
+			wantIDs: []string{"1/5", "0/11", "1/6"},
+		},
+		{
+			name:     "limit",
+			params:   logs.SearchParams{Query: "connection", Limit: 2},
+			wantIDs:  []string{"0/10", "1/5"},
+			wantNext: "0:11,1:6",
+		},
+		{
+			name:     "next page",
+			params:   logs.SearchParams{Query: "connection", Limit: 2, Page: "0:11,1:6"},
+			wantIDs:  []string{"0/11", "1/6"},
+			wantNext: "0:13",
+		},
+		{
+			name:    "labels",
+			params:  logs.SearchParams{Labels: map[string]string{"category": "AppServiceConsoleLogs"}, End: "2023-05-01T12:05:00Z"},
+			wantIDs: []string{"1/5", "0/11", "1/6", "0/12"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := backend.Search(context.Background(), &tt.params)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+			if !reflect.DeepEqual(ids(got), tt.wantIDs) || got.NextPage != tt.wantNext {
+				t.Errorf("Search() = %v, next page %q, want %v, next page %q", ids(got), got.NextPage, tt.wantIDs, tt.wantNext)
+			}
+		})
+	}
+
+	got, err := backend.Search(context.Background(), &logs.SearchParams{Query: "timeout", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.Result{
+		Id:      "1/5",
+		Time:    "2023-05-01T12:01:00Z",
+		Message: "connection timeout",
+		Labels:  map[string]string{"partition": "1", "category": "AppServiceConsoleLogs", "retries": "3", "source": "eventhubs"},
+	}
+	if len(got.Results) != 1 || !reflect.DeepEqual(got.Results[0], want) {
+		t.Errorf("Search() = %+v, want %+v", got.Results, want)
+	}
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/flanksource/commons/logger"
+	"github.com/labstack/echo/v4"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/audit"
+	"github.com/flanksource/apm-hub/pkg/auth"
+	"github.com/flanksource/apm-hub/pkg/export"
+	"github.com/flanksource/apm-hub/pkg/guardrail"
+)
+
+// exportPageSize is the number of results requested from the backends per page of an export
+const exportPageSize = 1000
+
+// Export writes the results of every matching backend to the export bucket
+// and returns a presigned url to download them
+func Export(c echo.Context) error {
+	cc := c.(*api.Context)
+	if export.GlobalExporter == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "export isn't configured")
+	}
+
+	params := new(export.Params)
+	if err := c.Bind(params); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if params.Limit <= 0 || params.Limit > export.GlobalExporter.MaxResults() {
+		params.Limit = guardrail.GlobalGuardrails.DefaultLimit(export.GlobalExporter.MaxResults())
+	}
+	params.SetDefaults()
+	if err := params.Validate(); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	grant, err := authorize(cc, &params.SearchParams)
+	if err != nil {
+		return err
+	}
+
+	var backends []string
+	result, err := export.GlobalExporter.Export(cc.Request().Context(), params.Format, func(w *export.Writer) error {
+		var exportErr error
+		backends = eachBackend(cc.Request().Context(), cc.Tenant, &params.SearchParams, grant, func(i int, backend logs.SearchBackend, q logs.SearchParams) {
+			if exportErr != nil {
+				return
+			}
+			if err := exportBackend(cc.Request().Context(), cc.Tenant, backend, grant, q, w); err != nil {
+				logger.Errorf("error exporting the results of backend[%d]: %v", i, err)
+			}
+			exportErr = cc.Request().Context().Err()
+		})
+		return exportErr
+	})
+	if err != nil {
+		audit.GlobalAuditor.Record(newAuditEvent(cc, &params.SearchParams, backends, 0, err))
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+
+	audit.GlobalAuditor.Record(newAuditEvent(cc, &params.SearchParams, backends, int(result.Count), nil))
+	return cc.JSON(http.StatusCreated, result)
+}
+
+// exportBackend writes the results of the backend page by page, or as they're streamed
+// by the streaming backends, until the limit of the export is reached
+func exportBackend(ctx context.Context, tenant string, backend logs.SearchBackend, grant *auth.Grant, q logs.SearchParams, w *export.Writer) error {
+	limit := q.Limit
+	if backend.API.Capabilities().Streaming {
+		// The results dropped by the pipelines don't count, so the stream is stopped once the export is full
+		q.Limit = 0
+		var writeErr error
+		err := streamBackend(ctx, backend, q, exportPageSize, func(results []logs.Result) bool {
+			results = processResults(tenant, backend, grant, results)
+			if remaining := limit - w.Count(); int64(len(results)) > remaining {
+				results = results[:remaining]
+			}
+			if writeErr = w.Write(results); writeErr != nil {
+				return false
+			}
+			return w.Count() < limit
+		})
+		if writeErr != nil {
+			return writeErr
+		}
+		return err
+	}
+
+	for w.Count() < limit {
+		q.Limit = limit - w.Count()
+		if q.Limit > exportPageSize {
+			q.Limit = exportPageSize
+		}
+
+		result, err := searchBackend(ctx, backend, q)
+		if err != nil {
+			return err
+		}
+
+		results := processResults(tenant, backend, grant, result.Results)
+		if remaining := limit - w.Count(); int64(len(results)) > remaining {
+			results = results[:remaining]
+		}
+		if err := w.Write(results); err != nil {
+			return err
+		}
+
+		if result.NextPage == "" || result.NextPage == q.Page {
+			return nil
+		}
+		q.Page = result.NextPage
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+// Package export writes the results of large searches to a bucket
+// and returns a presigned url to download them.
+package export
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/kommons"
+)
+
+// The formats of the exported objects
+const (
+	FormatNDJSONGzip = "ndjson.gz"
+	FormatNDJSON     = "ndjson"
+)
+
+// GlobalExporter is nil when exports aren't configured
+var GlobalExporter *Exporter
+
+// Params is the body of the /export requests
+type Params struct {
+	logs.SearchParams `json:",inline"`
+
+	// Format of the exported object, ndjson.gz (default) or ndjson
+	Format string `json:"format,omitempty"`
+}
+
+func (p *Params) Validate() error {
+	switch p.Format {
+	case "":
+		p.Format = FormatNDJSONGzip
+	case FormatNDJSONGzip, FormatNDJSON:
+	default:
+		return fmt.Errorf("unsupported format %s, expected %s or %s", p.Format, FormatNDJSONGzip, FormatNDJSON)
+	}
+	return nil
+}
+
+// Export is the exported object
+type Export struct {
+	Bucket  string    `json:"bucket"`
+	Key     string    `json:"key"`
+	Format  string    `json:"format"`
+	Count   int64     `json:"count"`
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
+// Exporter uploads the exports to the bucket
+type Exporter struct {
+	bucket     string
+	prefix     string
+	expiry     time.Duration
+	maxResults int64
+	uploader   *manager.Uploader
+	presign    *s3.PresignClient
+}
+
+func NewExporter(kClient *kommons.Client, conf logs.ExportConfig) (*Exporter, error) {
+	if conf.Bucket == "" {
+		return nil, fmt.Errorf("export bucket is required")
+	}
+
+	t := &Exporter{
+		bucket:     conf.Bucket,
+		prefix:     conf.Prefix,
+		expiry:     time.Hour,
+		maxResults: conf.MaxResults,
+	}
+	if t.maxResults <= 0 {
+		t.maxResults = 1000000
+	}
+	if conf.Expiry != "" {
+		expiry, err := durationUtil.ParseDuration(conf.Expiry)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the export expiry: %w", err)
+		}
+		t.expiry = time.Duration(expiry)
+	}
+
+	options := []func(*config.LoadOptions) error{config.WithRegion(conf.Auth.Region)}
+	if conf.Auth.AccessKey != nil && conf.Auth.SecretKey != nil {
+		_, accessKey, err := kClient.GetEnvValue(*conf.Auth.AccessKey, conf.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the access key: %w", err)
+		}
+		_, secretKey, err := kClient.GetEnvValue(*conf.Auth.SecretKey, conf.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the secret key: %w", err)
+		}
+		options = append(options, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")))
+	}
+
+	cfg, err := config.LoadDefaultConfig(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating aws config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if conf.Endpoint != "" {
+			o.EndpointResolver = s3.EndpointResolverFromURL(conf.Endpoint)
+		}
+		o.UsePathStyle = conf.UsePathStyle
+	})
+	t.uploader = manager.NewUploader(client)
+	t.presign = s3.NewPresignClient(client, s3.WithPresignExpires(t.expiry))
+	return t, nil
+}
+
+// MaxResults is the maximum number of results of an export
+func (t *Exporter) MaxResults() int64 {
+	return t.maxResults
+}
+
+// Export streams the results written by fn to a new object in the bucket
+// and presigns its download url.
+func (t *Exporter) Export(ctx context.Context, format string, fn func(w *Writer) error) (*Export, error) {
+	key, err := t.newKey(format)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, pipe := io.Pipe()
+	writer := NewWriter(pipe, format)
+	go func() {
+		err := fn(writer)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+		pipe.CloseWithError(err)
+	}()
+
+	contentType := "application/x-ndjson"
+	var contentEncoding *string
+	if format == FormatNDJSONGzip {
+		contentEncoding = aws.String("gzip")
+	}
+
+	_, err = t.uploader.Upload(ctx, &s3.PutObjectInput{
+		Bucket:          aws.String(t.bucket),
+		Key:             aws.String(key),
+		Body:            reader,
+		ContentType:     aws.String(contentType),
+		ContentEncoding: contentEncoding,
+	})
+	// Unblock the writer if the upload failed
+	reader.CloseWithError(err)
+	if err != nil {
+		return nil, fmt.Errorf("error uploading the export: %w", err)
+	}
+
+	presigned, err := t.presign.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(t.bucket), Key: aws.String(key)})
+	if err != nil {
+		return nil, fmt.Errorf("error presigning the export url: %w", err)
+	}
+
+	return &Export{
+		Bucket:  t.bucket,
+		Key:     key,
+		Format:  format,
+		Count:   writer.Count(),
+		URL:     presigned.URL,
+		Expires: time.Now().Add(t.expiry).UTC(),
+	}, nil
+}
+
+func (t *Exporter) newKey(format string) (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("error generating the export key: %w", err)
+	}
+	return fmt.Sprintf("%s%s-%s.%s", t.prefix, time.Now().UTC().Format("2006/01/02/150405"), hex.EncodeToString(id), format), nil
+}
+
+// Writer encodes the results as json lines, optionally gzip compressed
+type Writer struct {
+	gz      *gzip.Writer
+	encoder *json.Encoder
+	count   int64
+}
+
+func NewWriter(w io.Writer, format string) *Writer {
+	t := &Writer{}
+	if format == FormatNDJSONGzip {
+		t.gz = gzip.NewWriter(w)
+		w = t.gz
+	}
+	t.encoder = json.NewEncoder(w)
+	return t
+}
+
+func (t *Writer) Write(results []logs.Result) error {
+	for _, r := range results {
+		if err := t.encoder.Encode(r); err != nil {
+			return err
+		}
+		t.count++
+	}
+	return nil
+}
+
+// Count is the number of results written
+func (t *Writer) Count() int64 {
+	return t.count
+}
+
+func (t *Writer) Close() error {
+	if t.gz != nil {
+		return t.gz.Close()
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestParams_Validate(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{format: "", want: FormatNDJSONGzip},
+		{format: FormatNDJSON, want: FormatNDJSON},
+		{format: "parquet", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			p := Params{Format: tt.format}
+			if err := p.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && p.Format != tt.want {
+				t.Errorf("Validate() format = %s, want %s", p.Format, tt.want)
+			}
+		})
+	}
+}
+
+func TestExporter_Export(t *testing.T) {
+	objects := make(map[string][]byte)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		objects[r.URL.Path] = body
+		w.Header().Set("ETag", `"etag"`)
+	}))
+	defer server.Close()
+
+	exporter, err := NewExporter(nil, logs.ExportConfig{
+		Bucket:       "exports",
+		Prefix:       "apm-hub/",
+		Endpoint:     server.URL,
+		UsePathStyle: true,
+		Auth: logs.AWSAuthentication{
+			Region:    "us-east-1",
+			AccessKey: &kommons.EnvVar{Value: "access"},
+			SecretKey: &kommons.EnvVar{Value: "secret"},
+		},
+		Expiry: "15m",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := []logs.Result{{Message: "a"}, {Message: "b"}, {Message: "c"}}
+	export, err := exporter.Export(context.Background(), FormatNDJSONGzip, func(w *Writer) error {
+		return w.Write(results)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if export.Count != 3 || !strings.HasPrefix(export.Key, "apm-hub/") || !strings.HasSuffix(export.Key, ".ndjson.gz") {
+		t.Errorf("unexpected export %+v", export)
+	}
+	if !strings.Contains(export.URL, "/exports/"+export.Key) || !strings.Contains(export.URL, "X-Amz-Expires=900") {
+		t.Errorf("unexpected presigned url %s", export.URL)
+	}
+
+	body, ok := objects["/exports/"+export.Key]
+	if !ok {
+		t.Fatalf("object %s wasn't uploaded, got %v", export.Key, objects)
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := 0
+	for scanner := bufio.NewScanner(gz); scanner.Scan(); lines++ {
+	}
+	if lines != 3 {
+		t.Errorf("expected 3 lines, got %d", lines)
+	}
+
+	if _, err := exporter.Export(context.Background(), FormatNDJSON, func(w *Writer) error {
+		return errors.New("search failed")
+	}); err == nil {
+		t.Errorf("expected the export to fail with the search")
+	}
+}
@@ -2,6 +2,7 @@ package files
 
 import (
 	"bufio"
+	"context"
 	"os"
 	"path/filepath"
 	"strings"
@@ -22,7 +23,7 @@ type FileSearch struct {
 	config *logs.FileSearchBackendConfig
 }
 
-func (t *FileSearch) Search(q *logs.SearchParams) (r logs.SearchResults, err error) {
+func (t *FileSearch) Search(ctx context.Context, q *logs.SearchParams) (r logs.SearchResults, err error) {
 	var res logs.SearchResults
 	lines := readFilesLines(t.config.Paths, collections.MergeMap(t.config.Labels, q.Labels))
 	for _, content := range lines {
@@ -32,10 +33,34 @@ func (t *FileSearch) Search(q *logs.SearchParams) (r logs.SearchResults, err err
 	return res, nil
 }
 
+// SearchStream implements logs.SearchStreamer, sending the lines of the files one by one
+func (t *FileSearch) SearchStream(ctx context.Context, q *logs.SearchParams, results chan<- logs.Result) error {
+	labels := collections.MergeMap(t.config.Labels, q.Labels)
+	for _, path := range unfoldGlobs(t.config.Paths) {
+		err := readFileLines(path, labels, func(r logs.Result) error {
+			select {
+			case results <- r:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (t *FileSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
 	return t.config.CommonBackend.Routes.MatchRoute(q)
 }
 
+func (t *FileSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Streaming: true}
+}
+
 type logsPerFile map[string][]logs.Result
 
 // readFilesLines takes a list of file paths and returns each lines of those files.
@@ -43,32 +68,47 @@ type logsPerFile map[string][]logs.Result
 func readFilesLines(paths []string, labelsToAttach map[string]string) logsPerFile {
 	fileContents := make(logsPerFile, len(paths))
 	for _, path := range unfoldGlobs(paths) {
-		fInfo, err := os.Stat(path)
-		if err != nil {
-			logger.Warnf("error get file stat. path=%s; %w", path, err)
-			continue
-		}
+		_ = readFileLines(path, labelsToAttach, func(r logs.Result) error {
+			fileContents[path] = append(fileContents[path], r)
+			return nil
+		})
+	}
 
-		file, err := os.Open(path)
-		if err != nil {
-			logger.Warnf("error opening file. path=%s; %w", path, err)
-			continue
-		}
+	return fileContents
+}
 
-		// All lines of the same file will share these labels
-		labels := collections.MergeMap(map[string]string{"path": path}, labelsToAttach)
+// readFileLines passes each line of the file to fn, until it returns an error.
+// The files that can't be read are skipped.
+func readFileLines(path string, labelsToAttach map[string]string, fn func(r logs.Result) error) error {
+	fInfo, err := os.Stat(path)
+	if err != nil {
+		logger.Warnf("error get file stat. path=%s; %w", path, err)
+		return nil
+	}
 
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			fileContents[path] = append(fileContents[path], logs.Result{
-				Time:    fInfo.ModTime().Format(time.RFC3339),
-				Labels:  labels,
-				Message: strings.TrimSpace(scanner.Text()),
-			})
+	file, err := os.Open(path)
+	if err != nil {
+		logger.Warnf("error opening file. path=%s; %w", path, err)
+		return nil
+	}
+	defer file.Close()
+
+	// All lines of the same file will share these labels
+	labels := collections.MergeMap(map[string]string{"path": path}, labelsToAttach)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		err := fn(logs.Result{
+			Time:    fInfo.ModTime().Format(time.RFC3339),
+			Labels:  labels,
+			Message: strings.TrimSpace(scanner.Text()),
+		})
+		if err != nil {
+			return err
 		}
 	}
 
-	return fileContents
+	return nil
 }
 
 func unfoldGlobs(paths []string) []string {
@@ -0,0 +1,235 @@
+package forward
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/kommons"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var forwardedLines = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "apm_hub_forwarded_lines_total",
+	Help: "Number of results posted by the forwarders, by status (sent or dropped)",
+}, []string{"forwarder", "status"})
+
+// sleep is replaced in the tests
+var sleep = time.Sleep
+
+// SearchFunc returns the processed results of every page of every backend matching the search params,
+// with the errors of the backends that couldn't be searched
+type SearchFunc func(q *logs.SearchParams) ([]logs.Result, error)
+
+// Batch is the body posted to the webhook
+type Batch struct {
+	Forwarder string        `json:"forwarder"`
+	Results   []logs.Result `json:"results"`
+}
+
+// Forwarder polls a search and posts its new results to a webhook
+type Forwarder struct {
+	name       string
+	params     logs.SearchParams
+	interval   time.Duration
+	overlap    time.Duration
+	url        string
+	headers    map[string]string
+	batchSize  int
+	maxRetries int
+	backoff    time.Duration
+	search     SearchFunc
+	client     *http.Client
+
+	// last is the end of the previous poll
+	last time.Time
+	// seen are the times of the results already posted that can be returned again, i.e. the ones
+	// in the overlap of the polls and the ones of the previous poll, for the backends ignoring the time range
+	seen map[string]time.Time
+}
+
+func NewForwarder(kClient *kommons.Client, config logs.ForwarderConfig, search SearchFunc) (*Forwarder, error) {
+	if config.Name == "" {
+		return nil, fmt.Errorf("forwarders must have a name")
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("forwarder %s: url is required", config.Name)
+	}
+
+	t := &Forwarder{
+		name:       config.Name,
+		params:     config.SearchParams,
+		url:        config.URL,
+		headers:    make(map[string]string, len(config.Headers)),
+		batchSize:  config.BatchSize,
+		maxRetries: config.MaxRetries,
+		search:     search,
+		client:     &http.Client{Timeout: 30 * time.Second},
+		seen:       make(map[string]time.Time),
+	}
+	if t.batchSize <= 0 {
+		t.batchSize = 100
+	}
+	if t.maxRetries <= 0 {
+		t.maxRetries = 5
+	}
+
+	var err error
+	if t.interval, err = parseDuration(config.Interval, time.Minute); err != nil {
+		return nil, fmt.Errorf("forwarder %s: invalid interval: %w", config.Name, err)
+	}
+	if t.overlap, err = parseDuration(config.Overlap, t.interval); err != nil {
+		return nil, fmt.Errorf("forwarder %s: invalid overlap: %w", config.Name, err)
+	}
+	if t.backoff, err = parseDuration(config.Backoff, time.Second); err != nil {
+		return nil, fmt.Errorf("forwarder %s: invalid backoff: %w", config.Name, err)
+	}
+
+	for name, envVar := range config.Headers {
+		_, value, err := kClient.GetEnvValue(envVar, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("forwarder %s: error getting the header %s: %w", config.Name, name, err)
+		}
+		t.headers[name] = value
+	}
+
+	return t, nil
+}
+
+func parseDuration(value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := durationUtil.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be greater than 0", value)
+	}
+	return time.Duration(d), nil
+}
+
+// Start polls the search in the background, starting with the results from now on
+func (t *Forwarder) Start() {
+	logger.Infof("forwarding the results of [%s] to %s every %s", t.params, t.url, t.interval)
+	t.last = time.Now()
+	go func() {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			t.Poll(now)
+		}
+	}()
+}
+
+// Poll searches the results since the previous poll, minus the overlap, and posts the new ones.
+// When a backend fails, nothing is posted and the next poll searches again since the previous one.
+func (t *Forwarder) Poll(now time.Time) {
+	q := t.params
+	if t.last.IsZero() {
+		t.last = now.Add(-t.interval)
+	}
+	q.Start = t.last.Add(-t.overlap).Format(time.RFC3339)
+	q.End = now.Format(time.RFC3339)
+	q.SetDefaults()
+
+	all, err := t.search(&q)
+	if err != nil {
+		logger.Errorf("[forwarder] %s: error searching the results since %s: %v", t.name, q.Start, err)
+		return
+	}
+	t.last = now
+
+	// The next poll starts at now minus the overlap, the results before it won't be returned again
+	next := now.Add(-t.overlap)
+	var results []logs.Result
+	seen := make(map[string]time.Time)
+	for key, ts := range t.seen {
+		if !ts.Before(next) {
+			seen[key] = ts
+		}
+	}
+	for _, r := range all {
+		key := resultKey(r)
+		_, posted := t.seen[key]
+		if _, duplicate := seen[key]; !posted && !duplicate {
+			results = append(results, r)
+		}
+		ts, _ := time.Parse(time.RFC3339Nano, r.Time)
+		seen[key] = ts
+	}
+	t.seen = seen
+
+	for len(results) > 0 {
+		size := t.batchSize
+		if len(results) < size {
+			size = len(results)
+		}
+		t.send(results[:size])
+		results = results[size:]
+	}
+}
+
+// send posts the batch, retrying with an exponential backoff
+func (t *Forwarder) send(results []logs.Result) {
+	backoff := t.backoff
+	for attempt := 0; ; attempt++ {
+		err := t.post(results)
+		if err == nil {
+			forwardedLines.WithLabelValues(t.name, "sent").Add(float64(len(results)))
+			return
+		}
+
+		if attempt >= t.maxRetries {
+			logger.Errorf("[forwarder] %s: dropping %d results after %d retries: %v", t.name, len(results), attempt, err)
+			forwardedLines.WithLabelValues(t.name, "dropped").Add(float64(len(results)))
+			return
+		}
+
+		logger.Warnf("[forwarder] %s: retrying in %s: %v", t.name, backoff, err)
+		sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (t *Forwarder) post(results []logs.Result) error {
+	body, err := json.Marshal(Batch{Forwarder: t.name, Results: results})
+	if err != nil {
+		return fmt.Errorf("error marshalling the results: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("got response: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// resultKey identifies a result across the polls
+func resultKey(r logs.Result) string {
+	if r.Id != "" {
+		return r.Id
+	}
+	return r.Time + "\x00" + r.Message
+}
@@ -0,0 +1,180 @@
+package forward
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestForwarder(t *testing.T) {
+	var sleeps []time.Duration
+	sleep = func(d time.Duration) { sleeps = append(sleeps, d) }
+
+	failures := 2
+	var batches []Batch
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var batch Batch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+		batches = append(batches, batch)
+	}))
+	defer server.Close()
+
+	var results []logs.Result
+	var searched logs.SearchParams
+	search := func(q *logs.SearchParams) ([]logs.Result, error) {
+		searched = *q
+		return results, nil
+	}
+
+	forwarder, err := NewForwarder(nil, logs.ForwarderConfig{
+		Name:         "errors",
+		SearchParams: logs.SearchParams{Query: "error"},
+		URL:          server.URL,
+		Headers:      map[string]kommons.EnvVar{"X-Token": {Value: "secret"}},
+		BatchSize:    2,
+	}, search)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		results = append(results, logs.Result{Id: fmt.Sprint(i), Message: "error"})
+	}
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	forwarder.Poll(now)
+
+	// The overlap defaults to the interval
+	if searched.Start != "2023-05-01T11:58:00Z" || searched.End != "2023-05-01T12:00:00Z" {
+		t.Errorf("unexpected time range %s - %s", searched.Start, searched.End)
+	}
+	if len(batches) != 2 || len(batches[0].Results) != 2 || len(batches[1].Results) != 1 || batches[0].Forwarder != "errors" {
+		t.Fatalf("unexpected batches: %+v", batches)
+	}
+	if len(sleeps) != 2 || sleeps[0] != time.Second || sleeps[1] != 2*time.Second {
+		t.Errorf("expected an exponential backoff, got %v", sleeps)
+	}
+
+	// Only the new results are posted
+	results = append(results[2:], logs.Result{Id: "3", Message: "error"})
+	forwarder.Poll(now.Add(time.Minute))
+	if searched.Start != "2023-05-01T11:59:00Z" {
+		t.Errorf("expected the search to start at the previous poll minus the overlap, got %s", searched.Start)
+	}
+	if len(batches) != 3 || len(batches[2].Results) != 1 || batches[2].Results[0].Id != "3" {
+		t.Fatalf("expected only the new result to be posted, got %+v", batches)
+	}
+}
+
+func TestForwarder_Drop(t *testing.T) {
+	sleep = func(time.Duration) {}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	forwarder, err := NewForwarder(nil, logs.ForwarderConfig{Name: "errors", URL: server.URL, MaxRetries: 3}, func(q *logs.SearchParams) ([]logs.Result, error) {
+		return []logs.Result{{Message: "error"}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forwarder.Poll(time.Now())
+	if requests != 4 {
+		t.Errorf("expected 1 request and 3 retries, got %d", requests)
+	}
+}
+
+func TestForwarder_Overlap(t *testing.T) {
+	var posted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch Batch
+		if err := json.NewDecoder(r.Body).Decode(&batch); err != nil {
+			t.Error(err)
+		}
+		for _, r := range batch.Results {
+			posted = append(posted, r.Id)
+		}
+	}))
+	defer server.Close()
+
+	var results []logs.Result
+	forwarder, err := NewForwarder(nil, logs.ForwarderConfig{Name: "errors", URL: server.URL, Interval: "1m", Overlap: "5m"}, func(q *logs.SearchParams) ([]logs.Result, error) {
+		return results, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	results = []logs.Result{{Id: "a", Time: "2023-05-01T11:59:30Z"}}
+	forwarder.Poll(now)
+
+	// b was indexed late, after the previous poll, and a is still in the overlap
+	results = []logs.Result{{Id: "a", Time: "2023-05-01T11:59:30Z"}, {Id: "b", Time: "2023-05-01T11:59:50Z"}}
+	forwarder.Poll(now.Add(time.Minute))
+
+	// a is in the overlap but isn't returned anymore, e.g. beyond the limit of the search
+	results = []logs.Result{{Id: "b", Time: "2023-05-01T11:59:50Z"}}
+	forwarder.Poll(now.Add(2 * time.Minute))
+	results = []logs.Result{{Id: "a", Time: "2023-05-01T11:59:30Z"}}
+	forwarder.Poll(now.Add(3 * time.Minute))
+
+	if fmt.Sprint(posted) != "[a b]" {
+		t.Errorf("posted %v, want every result posted once", posted)
+	}
+}
+
+func TestForwarder_SearchError(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer server.Close()
+
+	var searched logs.SearchParams
+	var searchErr error
+	forwarder, err := NewForwarder(nil, logs.ForwarderConfig{Name: "errors", URL: server.URL, Interval: "1m", Overlap: "1m"}, func(q *logs.SearchParams) ([]logs.Result, error) {
+		searched = *q
+		return []logs.Result{{Id: "a"}}, searchErr
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	forwarder.Poll(now)
+
+	// The failed poll posts nothing, and the next one searches again since the previous one
+	searchErr = errors.New("connection refused")
+	forwarder.Poll(now.Add(time.Minute))
+	if requests != 1 {
+		t.Errorf("expected nothing to be posted on a failed search, got %d requests", requests)
+	}
+
+	searchErr = nil
+	forwarder.Poll(now.Add(2 * time.Minute))
+	if searched.Start != "2023-05-01T11:59:00Z" {
+		t.Errorf("expected the search to start at the last successful poll minus the overlap, got %s", searched.Start)
+	}
+}
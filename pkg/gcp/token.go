@@ -0,0 +1,45 @@
+// Package gcp authenticates the requests to the Google Cloud apis with the json key of a service account,
+// or the application default credentials
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/flanksource/kommons"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// NewTokenSource returns the token source of the scope, e.g. https://www.googleapis.com/auth/bigquery.
+// The application default credentials, e.g. of the workload identity, are used when no key is provided.
+func NewTokenSource(kClient *kommons.Client, namespace string, credentials *kommons.EnvVar, scope string) (oauth2.TokenSource, error) {
+	if credentials == nil {
+		tokens, err := google.DefaultTokenSource(context.Background(), scope)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the default credentials: %w", err)
+		}
+		return tokens, nil
+	}
+
+	_, key, err := kClient.GetEnvValue(*credentials, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the credentials: %w", err)
+	}
+	creds, err := google.CredentialsFromJSON(context.Background(), []byte(key), scope)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the credentials: %w", err)
+	}
+	return creds.TokenSource, nil
+}
+
+// Authorize sets the access token of the token source on the request
+func Authorize(req *http.Request, tokens oauth2.TokenSource) error {
+	token, err := tokens.Token()
+	if err != nil {
+		return fmt.Errorf("error getting the access token: %w", err)
+	}
+	token.SetAuthHeader(req)
+	return nil
+}
@@ -0,0 +1,270 @@
+// Package github searches the logs of the jobs of GitHub Actions
+package github
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/kommons"
+)
+
+const defaultURL = "https://api.github.com"
+
+var runIDPattern = regexp.MustCompile(`^[0-9]+$`)
+
+type GitHubActionsSearch struct {
+	config *logs.GitHubActionsBackendConfig
+	client *rest.Client
+}
+
+func NewGitHubActionsSearchBackend(kClient *kommons.Client, config *logs.GitHubActionsBackendConfig) (*GitHubActionsSearch, error) {
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &GitHubActionsSearch{config: config, client: client}, nil
+}
+
+// Search returns the last lines of the logs of the jobs of the run, up to the limit per item of the search
+func (t *GitHubActionsSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	repo, err := t.repository(q)
+	if err != nil {
+		return results, err
+	}
+
+	var jobs []job
+	if jobID := q.Labels["job_id"]; jobID != "" {
+		var j job
+		if err := t.client.Get(ctx, repoPath(repo)+"/actions/jobs/"+url.PathEscape(jobID), nil, &j); err != nil {
+			return results, fmt.Errorf("error getting the job %s: %w", jobID, err)
+		}
+		jobs = append(jobs, j)
+	} else {
+		runID := q.Labels["run_id"]
+		if runID == "" && runIDPattern.MatchString(q.Id) {
+			runID = q.Id
+		}
+		if runID == "" {
+			if runID, err = t.lastRun(ctx, repo, q); err != nil || runID == "" {
+				return results, err
+			}
+		}
+
+		var res struct {
+			Jobs []job `json:"jobs"`
+		}
+		if err := t.client.Get(ctx, repoPath(repo)+"/actions/runs/"+url.PathEscape(runID)+"/jobs", url.Values{"per_page": {"100"}}, &res); err != nil {
+			return results, fmt.Errorf("error listing the jobs of the run %s: %w", runID, err)
+		}
+		jobs = res.Jobs
+	}
+
+	for _, j := range jobs {
+		if name, ok := q.Labels["job"]; ok && j.Name != name {
+			continue
+		}
+		// The jobs that didn't start have no logs yet
+		if j.StartedAt == nil || j.Status == "queued" {
+			continue
+		}
+
+		lines, err := t.logs(ctx, repo, j, q)
+		if err != nil {
+			logger.Errorf("error getting the logs of the job %s of %s: %v", j.Name, repo, err)
+			continue
+		}
+		results.Results = append(results.Results, lines...)
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// repository returns the owner/name of the repository of the search
+func (t *GitHubActionsSearch) repository(q *logs.SearchParams) (string, error) {
+	repo := q.Labels["repository"]
+	if repo == "" {
+		repo = t.config.Repository
+	}
+	if repo == "" {
+		return "", fmt.Errorf("the search requires a repository label")
+	}
+	if !strings.Contains(repo, "/") {
+		if t.config.Owner == "" {
+			return "", fmt.Errorf("the repository %s requires an owner", repo)
+		}
+		repo = t.config.Owner + "/" + repo
+	}
+	return repo, nil
+}
+
+func repoPath(repo string) string {
+	owner, name, _ := strings.Cut(repo, "/")
+	return "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name)
+}
+
+// lastRun returns the id of the last run created in the time range, of the workflow and the branch of the search,
+// empty without runs
+func (t *GitHubActionsSearch) lastRun(ctx context.Context, repo string, q *logs.SearchParams) (string, error) {
+	path := repoPath(repo) + "/actions/runs"
+	if workflow := q.Labels["workflow"]; workflow != "" {
+		path = repoPath(repo) + "/actions/workflows/" + url.PathEscape(workflow) + "/runs"
+	}
+
+	query := url.Values{"per_page": {"1"}}
+	if branch := q.Labels["branch"]; branch != "" {
+		query.Set("branch", branch)
+	}
+	if start := q.GetStart(); start != nil {
+		end := "*"
+		if e := q.GetEnd(); e != nil {
+			end = e.UTC().Format(time.RFC3339)
+		}
+		query.Set("created", start.UTC().Format(time.RFC3339)+".."+end)
+	}
+
+	var res struct {
+		WorkflowRuns []struct {
+			ID int64 `json:"id"`
+		} `json:"workflow_runs"`
+	}
+	if err := t.client.Get(ctx, path, query, &res); err != nil {
+		return "", fmt.Errorf("error listing the runs of %s: %w", repo, err)
+	}
+	if len(res.WorkflowRuns) == 0 {
+		return "", nil
+	}
+	return strconv.FormatInt(res.WorkflowRuns[0].ID, 10), nil
+}
+
+// logs returns the lines of the logs of the job in the time range, the last ones up to the limit per item
+func (t *GitHubActionsSearch) logs(ctx context.Context, repo string, j job, q *logs.SearchParams) ([]logs.Result, error) {
+	// The logs are redirected to a temporary url of the storage of the logs
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL(repoPath(repo)+"/actions/jobs/"+strconv.FormatInt(j.ID, 10)+"/logs", nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	limit := q.LimitPerItem
+	if limit == 0 {
+		limit = q.Limit
+	}
+	start, end := q.GetStart(), q.GetEnd()
+
+	labels := map[string]string{
+		"repository": repo,
+		"run_id":     strconv.FormatInt(j.RunID, 10),
+		"job":        j.Name,
+		"job_id":     strconv.FormatInt(j.ID, 10),
+	}
+	for k, v := range map[string]string{"workflow": j.WorkflowName, "branch": j.HeadBranch, "conclusion": j.Conclusion} {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+	for k, v := range t.config.Labels {
+		labels[k] = v
+	}
+
+	var results []logs.Result
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// Each line starts with its timestamp, e.g. 2023-05-01T12:00:00.1234567Z ##[group]Run actions/checkout@v3,
+		// the first line after a byte order mark
+		ts, message, _ := strings.Cut(strings.TrimPrefix(scanner.Text(), "\ufeff"), " ")
+		written, err := time.Parse(time.RFC3339Nano, ts)
+		if err != nil {
+			continue
+		}
+		if (start != nil && written.Before(*start)) || (end != nil && written.After(*end)) {
+			continue
+		}
+
+		lineLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			lineLabels[k] = v
+		}
+		if step := j.step(written); step != "" {
+			lineLabels["step"] = step
+		}
+		results = append(results, logs.Result{Time: written.UTC().Format(time.RFC3339Nano), Message: message, Labels: lineLabels})
+
+		// Only the last lines are kept
+		if limit > 0 && int64(len(results)) >= 2*limit {
+			results = append(results[:0], results[int64(len(results))-limit:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && int64(len(results)) > limit {
+		results = results[int64(len(results))-limit:]
+	}
+	return results, nil
+}
+
+func (t *GitHubActionsSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the GitHub Actions backend, the lines are filtered by the query once returned
+func (t *GitHubActionsSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
+
+// HealthCheck gets the rate limit of the token, which doesn't count against it
+func (t *GitHubActionsSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/rate_limit", nil, nil)
+}
+
+type job struct {
+	ID           int64      `json:"id"`
+	RunID        int64      `json:"run_id"`
+	Name         string     `json:"name"`
+	WorkflowName string     `json:"workflow_name"`
+	HeadBranch   string     `json:"head_branch"`
+	Status       string     `json:"status"`
+	Conclusion   string     `json:"conclusion"`
+	StartedAt    *time.Time `json:"started_at"`
+	Steps        []struct {
+		Name      string     `json:"name"`
+		Number    int        `json:"number"`
+		StartedAt *time.Time `json:"started_at"`
+	} `json:"steps"`
+}
+
+// step returns the name of the last step started before the time, the steps being ordered.
+// The times of the steps are truncated to the second.
+func (j job) step(ts time.Time) string {
+	ts = ts.Truncate(time.Second)
+	var name string
+	for _, step := range j.Steps {
+		if step.StartedAt == nil || step.StartedAt.After(ts) {
+			break
+		}
+		name = step.Name
+	}
+	return name
+}
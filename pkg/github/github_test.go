@@ -0,0 +1,115 @@
+package github
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const jobsResponse = `{"total_count": 2, "jobs": [
+	{
+		"id": 301, "run_id": 42, "name": "deploy", "workflow_name": "Deploy", "head_branch": "main",
+		"status": "completed", "conclusion": "failure", "started_at": "2023-05-01T12:00:00Z",
+		"steps": [
+			{"name": "Set up job", "number": 1, "started_at": "2023-05-01T12:00:00Z"},
+			{"name": "Deploy", "number": 2, "started_at": "2023-05-01T12:00:02Z"},
+			{"name": "Post Deploy", "number": 3, "started_at": null}
+		]
+	},
+	{"id": 302, "run_id": 42, "name": "notify", "status": "queued", "started_at": null, "steps": []}
+]}`
+
+const jobLogs = "\ufeff2023-05-01T12:00:00.1000000Z Current runner version: '2.304.0'\n" +
+	"2023-05-01T12:00:02.5000000Z ##[group]Run ./deploy.sh\n" +
+	"not a line of the log\n" +
+	"2023-05-01T12:00:03.0000000Z ##[error]Process completed with exit code 1.\n"
+
+func TestGitHubActionsSearch(t *testing.T) {
+	// The logs are served by the storage of the logs, on another host without the credentials of the api
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("the credentials were sent to the storage of the logs")
+		}
+		_, _ = w.Write([]byte(jobLogs))
+	}))
+	defer storage.Close()
+
+	var runQueries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/repos/acme/shop/actions/workflows/deploy.yml/runs":
+			runQueries = append(runQueries, r.URL.Query().Get("branch")+"|"+r.URL.Query().Get("created"))
+			_, _ = w.Write([]byte(`{"total_count": 1, "workflow_runs": [{"id": 42}]}`))
+		case "/repos/acme/shop/actions/runs/42/jobs":
+			_, _ = w.Write([]byte(jobsResponse))
+		case "/repos/acme/shop/actions/jobs/301/logs":
+			http.Redirect(w, r, strings.Replace(storage.URL, "127.0.0.1", "localhost", 1)+"/jobs/301.txt", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewGitHubActionsSearchBackend(nil, &logs.GitHubActionsBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "github"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Token: &kommons.EnvVar{Value: "secret"}},
+		Owner:          "acme",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := func(step string) map[string]string {
+		return map[string]string{
+			"repository": "acme/shop", "run_id": "42", "job": "deploy", "job_id": "301", "workflow": "Deploy",
+			"branch": "main", "conclusion": "failure", "step": step, "source": "github",
+		}
+	}
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:02.5Z", Message: "##[group]Run ./deploy.sh", Labels: labels("Deploy")},
+		{Time: "2023-05-01T12:00:03Z", Message: "##[error]Process completed with exit code 1.", Labels: labels("Deploy")},
+	}}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Labels:       map[string]string{"repository": "shop", "workflow": "deploy.yml", "branch": "main"},
+		Start:        "2023-05-01T12:00:00Z",
+		End:          "2023-05-01T13:00:00Z",
+		LimitPerItem: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+	wantQueries := []string{"main|2023-05-01T12:00:00Z..2023-05-01T13:00:00Z"}
+	if !reflect.DeepEqual(runQueries, wantQueries) {
+		t.Errorf("runs queries = %v, want %v", runQueries, wantQueries)
+	}
+
+	// The run of the id
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Id: "42", Labels: map[string]string{"repository": "acme/shop"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 3 || res.Results[0].Labels["step"] != "Set up job" {
+		t.Errorf("Search() of the run = %+v, want the 3 lines of the job", res)
+	}
+	if len(runQueries) != 1 {
+		t.Errorf("the runs were listed for the search of a run")
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Id: "42"}); err == nil {
+		t.Error("Search() without repository succeeded")
+	}
+}
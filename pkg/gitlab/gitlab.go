@@ -0,0 +1,257 @@
+// Package gitlab searches the logs of the jobs of GitLab CI
+package gitlab
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/kommons"
+)
+
+const defaultURL = "https://gitlab.com"
+
+var (
+	pipelineIDPattern = regexp.MustCompile(`^[0-9]+$`)
+
+	// streamPattern matches the stream of the timestamped lines followed by a space, or by + for the continued lines, e.g. 01E+
+	streamPattern = regexp.MustCompile(`^[0-9a-f]{2}[OE][ +]$`)
+
+	// sectionPattern matches the markers of the sections, e.g. section_start:1683000000:step_script[collapsed=true]\r\x1b[0K
+	sectionPattern = regexp.MustCompile(`section_(start|end):([0-9]+):([^\[\r\s]+)(?:\[[^\]]*\])?\r\x1b\[0K`)
+
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+)
+
+type GitLabSearch struct {
+	config *logs.GitLabBackendConfig
+	client *rest.Client
+}
+
+func NewGitLabSearchBackend(kClient *kommons.Client, config *logs.GitLabBackendConfig) (*GitLabSearch, error) {
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &GitLabSearch{config: config, client: client}, nil
+}
+
+// Search returns the last lines of the logs of the jobs of the pipeline, up to the limit per item of the search
+func (t *GitLabSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	project := q.Labels["project"]
+	if project == "" {
+		project = t.config.Project
+	}
+	if project == "" {
+		return results, fmt.Errorf("the search requires a project label")
+	}
+	projectPath := "/api/v4/projects/" + url.PathEscape(project)
+
+	var jobs []job
+	if jobID := q.Labels["job_id"]; jobID != "" {
+		var j job
+		if err := t.client.Get(ctx, projectPath+"/jobs/"+url.PathEscape(jobID), nil, &j); err != nil {
+			return results, fmt.Errorf("error getting the job %s: %w", jobID, err)
+		}
+		jobs = append(jobs, j)
+	} else {
+		pipelineID := q.Labels["pipeline_id"]
+		if pipelineID == "" && pipelineIDPattern.MatchString(q.Id) {
+			pipelineID = q.Id
+		}
+		if pipelineID == "" {
+			var err error
+			if pipelineID, err = t.lastPipeline(ctx, projectPath, q); err != nil || pipelineID == "" {
+				return results, err
+			}
+		}
+
+		query := url.Values{"per_page": {"100"}}
+		if err := t.client.Get(ctx, projectPath+"/pipelines/"+url.PathEscape(pipelineID)+"/jobs", query, &jobs); err != nil {
+			return results, fmt.Errorf("error listing the jobs of the pipeline %s: %w", pipelineID, err)
+		}
+	}
+
+	for _, j := range jobs {
+		if name, ok := q.Labels["job"]; ok && j.Name != name {
+			continue
+		}
+		// The jobs that didn't start, e.g. the manual jobs, have no logs
+		if j.StartedAt == nil {
+			continue
+		}
+
+		lines, err := t.trace(ctx, projectPath, project, j, q)
+		if err != nil {
+			logger.Errorf("error getting the logs of the job %s of %s: %v", j.Name, project, err)
+			continue
+		}
+		results.Results = append(results.Results, lines...)
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// lastPipeline returns the id of the last pipeline updated in the time range, of the ref of the search,
+// empty without pipelines
+func (t *GitLabSearch) lastPipeline(ctx context.Context, projectPath string, q *logs.SearchParams) (string, error) {
+	query := url.Values{"per_page": {"1"}, "order_by": {"updated_at"}, "sort": {"desc"}}
+	if ref := q.Labels["ref"]; ref != "" {
+		query.Set("ref", ref)
+	}
+	if start := q.GetStart(); start != nil {
+		query.Set("updated_after", start.UTC().Format(time.RFC3339))
+	}
+	if end := q.GetEnd(); end != nil {
+		query.Set("updated_before", end.UTC().Format(time.RFC3339))
+	}
+
+	var pipelines []struct {
+		ID int64 `json:"id"`
+	}
+	if err := t.client.Get(ctx, projectPath+"/pipelines", query, &pipelines); err != nil {
+		return "", fmt.Errorf("error listing the pipelines: %w", err)
+	}
+	if len(pipelines) == 0 {
+		return "", nil
+	}
+	return strconv.FormatInt(pipelines[0].ID, 10), nil
+}
+
+// trace returns the lines of the logs of the job in the time range, the last ones up to the limit per item.
+// The lines are timestamped by the runners with the FF_TIMESTAMPS feature, otherwise the lines take the time
+// of the start of their section, or of the job.
+func (t *GitLabSearch) trace(ctx context.Context, projectPath, project string, j job, q *logs.SearchParams) ([]logs.Result, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL(projectPath+"/jobs/"+strconv.FormatInt(j.ID, 10)+"/trace", nil), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	limit := q.LimitPerItem
+	if limit == 0 {
+		limit = q.Limit
+	}
+	start, end := q.GetStart(), q.GetEnd()
+
+	labels := map[string]string{
+		"project":     project,
+		"pipeline_id": strconv.FormatInt(j.Pipeline.ID, 10),
+		"job":         j.Name,
+		"job_id":      strconv.FormatInt(j.ID, 10),
+	}
+	for k, v := range map[string]string{"stage": j.Stage, "ref": j.Ref, "status": j.Status} {
+		if v != "" {
+			labels[k] = v
+		}
+	}
+	for k, v := range t.config.Labels {
+		labels[k] = v
+	}
+
+	var results []logs.Result
+	section, sectionTime := "", *j.StartedAt
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		var written time.Time
+		if ts, rest, ok := strings.Cut(line, " "); ok {
+			if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				if len(rest) >= 4 && streamPattern.MatchString(rest[:4]) {
+					written, line = parsed, rest[4:]
+				}
+			}
+		}
+
+		for _, marker := range sectionPattern.FindAllStringSubmatch(line, -1) {
+			if unix, err := strconv.ParseInt(marker[2], 10, 64); err == nil {
+				sectionTime = time.Unix(unix, 0)
+			}
+			if marker[1] == "start" {
+				section = marker[3]
+			} else {
+				section = ""
+			}
+		}
+		message := ansiPattern.ReplaceAllString(sectionPattern.ReplaceAllString(line, ""), "")
+		if strings.TrimSpace(message) == "" {
+			continue
+		}
+
+		if written.IsZero() {
+			written = sectionTime
+		}
+		if (start != nil && written.Before(*start)) || (end != nil && written.After(*end)) {
+			continue
+		}
+
+		lineLabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			lineLabels[k] = v
+		}
+		if section != "" {
+			lineLabels["section"] = section
+		}
+		results = append(results, logs.Result{Time: written.UTC().Format(time.RFC3339Nano), Message: message, Labels: lineLabels})
+
+		// Only the last lines are kept
+		if limit > 0 && int64(len(results)) >= 2*limit {
+			results = append(results[:0], results[int64(len(results))-limit:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && int64(len(results)) > limit {
+		results = results[int64(len(results))-limit:]
+	}
+	return results, nil
+}
+
+func (t *GitLabSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the GitLab backend, the lines are filtered by the query once returned
+func (t *GitLabSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
+
+// HealthCheck gets the version of GitLab
+func (t *GitLabSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/v4/version", nil, nil)
+}
+
+type job struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	Stage     string     `json:"stage"`
+	Status    string     `json:"status"`
+	Ref       string     `json:"ref"`
+	StartedAt *time.Time `json:"started_at"`
+	Pipeline  struct {
+		ID int64 `json:"id"`
+	} `json:"pipeline"`
+}
@@ -0,0 +1,106 @@
+package gitlab
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const jobsResponse = `[
+	{"id": 501, "name": "deploy", "stage": "deploy", "status": "failed", "ref": "main", "started_at": "2023-05-01T12:00:00Z", "pipeline": {"id": 77}},
+	{"id": 502, "name": "approve", "stage": "deploy", "status": "manual", "ref": "main", "started_at": null, "pipeline": {"id": 77}}
+]`
+
+// trace is the trace of a runner without timestamps
+const trace = "\x1b[0KRunning with gitlab-runner 15.11.0\x1b[0;m\n" +
+	"section_start:1682942410:step_script\r\x1b[0K\x1b[0K\x1b[36;1mExecuting \"step_script\" stage of the job script\x1b[0;m\n" +
+	"$ ./deploy.sh\n" +
+	"\n" +
+	"section_end:1682942420:step_script\r\x1b[0K\x1b[31;1mERROR: Job failed: exit code 1\x1b[0;m\n"
+
+// timestampedTrace is the trace of a runner with the FF_TIMESTAMPS feature
+const timestampedTrace = "2023-05-01T12:00:00.100000Z 00O Running with gitlab-runner 16.6.0\n" +
+	"2023-05-01T12:00:05.200000Z 00O section_start:1682942405:step_script[collapsed=true]\r\x1b[0K$ ./deploy.sh\n" +
+	"2023-05-01T12:00:06.300000Z 01E+deploy failed\n"
+
+func TestGitLabSearch(t *testing.T) {
+	var pipelineQueries []string
+	traceResponse := trace
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/acme%2Fshop/pipelines":
+			pipelineQueries = append(pipelineQueries, r.URL.Query().Get("ref")+"|"+r.URL.Query().Get("updated_after"))
+			_, _ = w.Write([]byte(`[{"id": 77}]`))
+		case "/api/v4/projects/acme%2Fshop/pipelines/77/jobs":
+			_, _ = w.Write([]byte(jobsResponse))
+		case "/api/v4/projects/acme%2Fshop/jobs/501/trace":
+			_, _ = w.Write([]byte(traceResponse))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewGitLabSearchBackend(nil, &logs.GitLabBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "gitlab"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Token: &kommons.EnvVar{Value: "secret"}},
+		Project:        "acme/shop",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := func(section string) map[string]string {
+		l := map[string]string{
+			"project": "acme/shop", "pipeline_id": "77", "job": "deploy", "job_id": "501", "stage": "deploy",
+			"ref": "main", "status": "failed", "source": "gitlab",
+		}
+		if section != "" {
+			l["section"] = section
+		}
+		return l
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Labels: map[string]string{"ref": "main"}, Start: "2023-05-01T11:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 4, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:00Z", Message: "Running with gitlab-runner 15.11.0", Labels: labels("")},
+		{Time: "2023-05-01T12:00:10Z", Message: `Executing "step_script" stage of the job script`, Labels: labels("step_script")},
+		{Time: "2023-05-01T12:00:10Z", Message: "$ ./deploy.sh", Labels: labels("step_script")},
+		{Time: "2023-05-01T12:00:20Z", Message: "ERROR: Job failed: exit code 1", Labels: labels("")},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+	wantQueries := []string{"main|2023-05-01T11:00:00Z"}
+	if !reflect.DeepEqual(pipelineQueries, wantQueries) {
+		t.Errorf("pipelines queries = %v, want %v", pipelineQueries, wantQueries)
+	}
+
+	traceResponse = timestampedTrace
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Id: "77", Start: "2023-05-01T12:00:01Z", LimitPerItem: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:05.2Z", Message: "$ ./deploy.sh", Labels: labels("step_script")},
+		{Time: "2023-05-01T12:00:06.3Z", Message: "deploy failed", Labels: labels("step_script")},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() of the pipeline = %+v, want %+v", res, want)
+	}
+	if len(pipelineQueries) != 1 {
+		t.Errorf("the pipelines were listed for the search of a pipeline")
+	}
+}
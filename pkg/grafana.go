@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/audit"
+	"github.com/flanksource/apm-hub/pkg/grafana"
+	"github.com/flanksource/apm-hub/pkg/guardrail"
+)
+
+// maxAnnotations is the number of results returned as annotations of a graph
+const maxAnnotations = 100
+
+// GrafanaHealth answers the connection test of the Grafana datasources
+func GrafanaHealth(c echo.Context) error {
+	return c.NoContent(http.StatusOK)
+}
+
+// GrafanaSearch lists the metrics that can be charted
+func GrafanaSearch(c echo.Context) error {
+	req := new(grafana.SearchRequest)
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	return c.JSON(http.StatusOK, grafana.Metrics(req.Target))
+}
+
+// GrafanaQuery returns the time series of the targets over the dashboard's time range
+func GrafanaQuery(c echo.Context) error {
+	cc := c.(*api.Context)
+	req := new(grafana.QueryRequest)
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	series := []grafana.TimeSeries{}
+	for _, target := range req.Targets {
+		if target.Hide {
+			continue
+		}
+
+		params, groupBy, err := req.SearchParams(target)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		params.Limit = guardrail.GlobalGuardrails.DefaultLimit(params.Limit)
+
+		grant, err := authorize(cc, &params)
+		if err != nil {
+			return err
+		}
+
+		results, total, backends := aggregateResults(cc, &params, grant)
+		var all []logs.Result
+		for _, r := range results {
+			all = append(all, r...)
+		}
+
+		targetSeries, err := req.Series(target.Target, groupBy, all)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		audit.GlobalAuditor.Record(newAuditEvent(cc, &params, backends, total, nil))
+		series = append(series, targetSeries...)
+	}
+
+	return cc.JSON(http.StatusOK, series)
+}
+
+// GrafanaAnnotations returns the results matching the annotation query as graph annotations
+func GrafanaAnnotations(c echo.Context) error {
+	cc := c.(*api.Context)
+	req := new(grafana.AnnotationRequest)
+	if err := c.Bind(req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	query := grafana.QueryRequest{Range: req.Range}
+	params, _, err := query.SearchParams(grafana.Target{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	params.Query = req.Annotation.Query
+	params.Limit = guardrail.GlobalGuardrails.DefaultLimit(maxAnnotations)
+
+	grant, err := authorize(cc, &params)
+	if err != nil {
+		return err
+	}
+
+	results, total, backends := aggregateResults(cc, &params, grant)
+	var all []logs.Result
+	for _, r := range results {
+		all = append(all, r...)
+	}
+	if len(all) > maxAnnotations {
+		all = all[:maxAnnotations]
+	}
+	audit.GlobalAuditor.Record(newAuditEvent(cc, &params, backends, total, nil))
+
+	return cc.JSON(http.StatusOK, grafana.Annotations(req.Annotation, all))
+}
@@ -0,0 +1,256 @@
+// Package grafana implements the contract of the Grafana JSON datasources
+// (simple-json, simpod-json and infinity) on top of the apm-hub searches.
+package grafana
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/analytics"
+	"github.com/flanksource/apm-hub/pkg/pipeline"
+)
+
+// The metrics that can be charted
+const (
+	// MetricVolume is the number of results
+	MetricVolume = "volume"
+	// MetricErrors is the number of results with the error or fatal severity
+	MetricErrors = "errors"
+	// MetricSeverity is the number of results by severity
+	MetricSeverity = "severity"
+)
+
+// Metrics returns the metrics starting with the prefix, for the metric pickers
+func Metrics(prefix string) []string {
+	var metrics []string
+	for _, metric := range []string{MetricVolume, MetricErrors, MetricSeverity} {
+		if strings.HasPrefix(metric, prefix) {
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics
+}
+
+type Range struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// SearchRequest is the body of the /search requests listing the metrics
+type SearchRequest struct {
+	Target string `json:"target"`
+}
+
+// QueryRequest is the body of the /query requests
+type QueryRequest struct {
+	Range         Range         `json:"range"`
+	IntervalMs    int64         `json:"intervalMs"`
+	MaxDataPoints int           `json:"maxDataPoints"`
+	Targets       []Target      `json:"targets"`
+	AdhocFilters  []AdhocFilter `json:"adhocFilters,omitempty"`
+}
+
+// Target is a single metric to chart.
+// The payload is either a json object with the search params or the free text query.
+type Target struct {
+	Target  string          `json:"target"`
+	RefID   string          `json:"refId,omitempty"`
+	Hide    bool            `json:"hide,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Payload narrows the search of a target
+type Payload struct {
+	Query  string            `json:"query,omitempty"`
+	Type   string            `json:"type,omitempty"`
+	Id     string            `json:"id,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"`
+	// GroupBy splits the series by the values of the label
+	GroupBy string `json:"groupBy,omitempty"`
+}
+
+type AdhocFilter struct {
+	Key      string `json:"key"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+}
+
+// TimeSeries is a series of [value, unix milliseconds] data points
+type TimeSeries struct {
+	Target     string       `json:"target"`
+	Datapoints [][2]float64 `json:"datapoints"`
+}
+
+// AnnotationRequest is the body of the /annotations requests
+type AnnotationRequest struct {
+	Range      Range      `json:"range"`
+	Annotation Annotation `json:"annotation"`
+}
+
+// Annotation is the annotation query configured in the dashboard
+type Annotation struct {
+	Name   string `json:"name"`
+	Query  string `json:"query"`
+	Enable bool   `json:"enable"`
+}
+
+// AnnotationEvent is a result displayed on the graphs
+type AnnotationEvent struct {
+	Annotation Annotation `json:"annotation"`
+	Time       int64      `json:"time"`
+	Title      string     `json:"title"`
+	Text       string     `json:"text"`
+	Tags       []string   `json:"tags,omitempty"`
+}
+
+// SearchParams returns the search of the target over the range of the request
+func (t QueryRequest) SearchParams(target Target) (logs.SearchParams, string, error) {
+	var payload Payload
+	if len(target.Payload) > 0 && string(target.Payload) != "null" {
+		if err := json.Unmarshal(target.Payload, &payload); err != nil {
+			// The older datasources only send a string
+			var query string
+			if json.Unmarshal(target.Payload, &query) != nil {
+				return logs.SearchParams{}, "", fmt.Errorf("invalid payload: %w", err)
+			}
+			payload.Query = query
+		}
+	}
+
+	q := logs.SearchParams{
+		Query:  payload.Query,
+		Type:   payload.Type,
+		Id:     payload.Id,
+		Labels: payload.Labels,
+		Start:  t.Range.From.UTC().Format(time.RFC3339),
+		End:    t.Range.To.UTC().Format(time.RFC3339),
+		Limit:  analytics.DefaultLimit,
+	}
+
+	for _, filter := range t.AdhocFilters {
+		if filter.Operator != "=" {
+			continue
+		}
+		if q.Labels == nil {
+			q.Labels = make(map[string]string)
+		}
+		q.Labels[filter.Key] = filter.Value
+	}
+
+	q.SetDefaults()
+	return q, payload.GroupBy, nil
+}
+
+// Interval returns the width of the buckets, bounded by analytics.MaxBuckets
+func (t QueryRequest) Interval() time.Duration {
+	interval := time.Duration(t.IntervalMs) * time.Millisecond
+	span := t.Range.To.Sub(t.Range.From)
+	if min := span / (analytics.MaxBuckets - 1); interval < min {
+		interval = min
+	}
+	if interval < time.Second {
+		interval = time.Second
+	}
+	return interval.Truncate(time.Second)
+}
+
+// Series counts the results of the metric per interval.
+// With a group by label, there is one series per value of the label.
+func (t QueryRequest) Series(metric, groupBy string, results []logs.Result) ([]TimeSeries, error) {
+	var key func(r logs.Result) (string, bool)
+	switch metric {
+	case MetricVolume:
+		key = func(r logs.Result) (string, bool) { return metric, true }
+	case MetricErrors:
+		key = func(r logs.Result) (string, bool) {
+			s := severity(r)
+			return metric, s == pipeline.SeverityError || s == pipeline.SeverityFatal
+		}
+	case MetricSeverity:
+		key = func(r logs.Result) (string, bool) {
+			if s := severity(r); s != "" {
+				return s, true
+			}
+			return analytics.SeverityUnknown, true
+		}
+	default:
+		return nil, fmt.Errorf("unknown metric %s, expected one of %s", metric, strings.Join(Metrics(""), ", "))
+	}
+
+	interval := t.Interval()
+	start := t.Range.From.Truncate(interval)
+	size := int(t.Range.To.Sub(start)/interval) + 1
+
+	counts := make(map[string][]int)
+	for _, r := range results {
+		ts, err := time.Parse(time.RFC3339Nano, r.Time)
+		if err != nil || ts.Before(start) || ts.After(t.Range.To) {
+			continue
+		}
+
+		name, ok := key(r)
+		if !ok {
+			continue
+		}
+		if groupBy != "" {
+			name = fmt.Sprintf("%s{%s=%q}", name, groupBy, r.Labels[groupBy])
+		}
+		if counts[name] == nil {
+			counts[name] = make([]int, size)
+		}
+		counts[name][int(ts.Sub(start)/interval)]++
+	}
+
+	// Always return the series without a group, so that the panels show a flat line
+	if len(counts) == 0 && groupBy == "" && metric != MetricSeverity {
+		counts[metric] = make([]int, size)
+	}
+
+	series := make([]TimeSeries, 0, len(counts))
+	for name, buckets := range counts {
+		datapoints := make([][2]float64, len(buckets))
+		for i, count := range buckets {
+			datapoints[i] = [2]float64{float64(count), float64(start.Add(time.Duration(i) * interval).UnixMilli())}
+		}
+		series = append(series, TimeSeries{Target: name, Datapoints: datapoints})
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Target < series[j].Target })
+	return series, nil
+}
+
+// Annotations turns the results into annotation events tagged with their labels
+func Annotations(annotation Annotation, results []logs.Result) []AnnotationEvent {
+	events := []AnnotationEvent{}
+	for _, r := range results {
+		ts, err := time.Parse(time.RFC3339Nano, r.Time)
+		if err != nil {
+			continue
+		}
+
+		event := AnnotationEvent{
+			Annotation: annotation,
+			Time:       ts.UnixMilli(),
+			Title:      annotation.Name,
+			Text:       r.Message,
+		}
+		for k, v := range r.Labels {
+			event.Tags = append(event.Tags, k+":"+v)
+		}
+		sort.Strings(event.Tags)
+		events = append(events, event)
+	}
+	return events
+}
+
+func severity(r logs.Result) string {
+	for _, label := range []string{"severity", "level"} {
+		if val, ok := r.Labels[label]; ok {
+			return pipeline.NormalizeSeverity(val)
+		}
+	}
+	return ""
+}
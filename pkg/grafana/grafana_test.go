@@ -0,0 +1,152 @@
+package grafana
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestQueryRequest_SearchParams(t *testing.T) {
+	req := QueryRequest{
+		Range: Range{
+			From: time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC),
+			To:   time.Date(2023, 5, 1, 13, 0, 0, 0, time.UTC),
+		},
+		AdhocFilters: []AdhocFilter{{Key: "namespace", Operator: "=", Value: "default"}, {Key: "pod", Operator: "!=", Value: "a"}},
+	}
+
+	tests := []struct {
+		name        string
+		payload     string
+		wantQuery   string
+		wantLabels  map[string]string
+		wantGroupBy string
+		wantErr     bool
+	}{
+		{name: "empty", wantLabels: map[string]string{"namespace": "default"}},
+		{name: "object", payload: `{"query":"error","labels":{"app":"api"},"groupBy":"pod"}`, wantQuery: "error", wantLabels: map[string]string{"app": "api", "namespace": "default"}, wantGroupBy: "pod"},
+		{name: "string", payload: `"timeout"`, wantQuery: "timeout", wantLabels: map[string]string{"namespace": "default"}},
+		{name: "invalid", payload: `[1]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, groupBy, err := req.SearchParams(Target{Target: MetricVolume, Payload: json.RawMessage(tt.payload)})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SearchParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if params.Query != tt.wantQuery || groupBy != tt.wantGroupBy || !reflect.DeepEqual(params.Labels, tt.wantLabels) {
+				t.Errorf("SearchParams() = %q %v %q, want %q %v %q", params.Query, params.Labels, groupBy, tt.wantQuery, tt.wantLabels, tt.wantGroupBy)
+			}
+			if params.Start != "2023-05-01T12:00:00Z" || params.End != "2023-05-01T13:00:00Z" {
+				t.Errorf("SearchParams() range = %s - %s", params.Start, params.End)
+			}
+		})
+	}
+}
+
+func TestQueryRequest_Interval(t *testing.T) {
+	from := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		span       time.Duration
+		intervalMs int64
+		want       time.Duration
+	}{
+		{name: "grafana interval", span: time.Hour, intervalMs: 60000, want: time.Minute},
+		{name: "at least a second", span: time.Minute, intervalMs: 20, want: time.Second},
+		{name: "bounded buckets", span: 30 * 24 * time.Hour, intervalMs: 1000, want: 2594 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := QueryRequest{Range: Range{From: from, To: from.Add(tt.span)}, IntervalMs: tt.intervalMs}
+			if got := req.Interval(); got != tt.want {
+				t.Errorf("Interval() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryRequest_Series(t *testing.T) {
+	from := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	req := QueryRequest{Range: Range{From: from, To: from.Add(2 * time.Minute)}, IntervalMs: 60000}
+	results := []logs.Result{
+		{Time: "2023-05-01T12:00:10Z", Labels: map[string]string{"pod": "a", "level": "ERROR"}},
+		{Time: "2023-05-01T12:00:50Z", Labels: map[string]string{"pod": "b", "level": "info"}},
+		{Time: "2023-05-01T12:01:30Z", Labels: map[string]string{"pod": "a"}},
+		{Time: "2023-05-01T13:00:00Z", Labels: map[string]string{"pod": "a"}},
+	}
+	ms := func(minutes int) float64 { return float64(from.Add(time.Duration(minutes) * time.Minute).UnixMilli()) }
+
+	tests := []struct {
+		name    string
+		metric  string
+		groupBy string
+		results []logs.Result
+		want    []TimeSeries
+		wantErr bool
+	}{
+		{name: "volume", metric: MetricVolume, results: results, want: []TimeSeries{
+			{Target: "volume", Datapoints: [][2]float64{{2, ms(0)}, {1, ms(1)}, {0, ms(2)}}},
+		}},
+		{name: "grouped", metric: MetricVolume, groupBy: "pod", results: results, want: []TimeSeries{
+			{Target: `volume{pod="a"}`, Datapoints: [][2]float64{{1, ms(0)}, {1, ms(1)}, {0, ms(2)}}},
+			{Target: `volume{pod="b"}`, Datapoints: [][2]float64{{1, ms(0)}, {0, ms(1)}, {0, ms(2)}}},
+		}},
+		{name: "errors", metric: MetricErrors, results: results, want: []TimeSeries{
+			{Target: "errors", Datapoints: [][2]float64{{1, ms(0)}, {0, ms(1)}, {0, ms(2)}}},
+		}},
+		{name: "no errors", metric: MetricErrors, want: []TimeSeries{
+			{Target: "errors", Datapoints: [][2]float64{{0, ms(0)}, {0, ms(1)}, {0, ms(2)}}},
+		}},
+		{name: "severity", metric: MetricSeverity, results: results, want: []TimeSeries{
+			{Target: "error", Datapoints: [][2]float64{{1, ms(0)}, {0, ms(1)}, {0, ms(2)}}},
+			{Target: "info", Datapoints: [][2]float64{{1, ms(0)}, {0, ms(1)}, {0, ms(2)}}},
+			{Target: "unknown", Datapoints: [][2]float64{{0, ms(0)}, {1, ms(1)}, {0, ms(2)}}},
+		}},
+		{name: "unknown metric", metric: "latency", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := req.Series(tt.metric, tt.groupBy, tt.results)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Series() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Series() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	annotation := Annotation{Name: "deploys", Query: "deployed"}
+	results := []logs.Result{
+		{Time: "2023-05-01T12:00:10Z", Message: "deployed v2", Labels: map[string]string{"pod": "a", "app": "api"}},
+		{Time: "invalid", Message: "deployed v1"},
+	}
+
+	want := []AnnotationEvent{
+		{Annotation: annotation, Time: time.Date(2023, 5, 1, 12, 0, 10, 0, time.UTC).UnixMilli(), Title: "deploys", Text: "deployed v2", Tags: []string{"app:api", "pod:a"}},
+	}
+	if got := Annotations(annotation, results); !reflect.DeepEqual(got, want) {
+		t.Errorf("Annotations() = %v, want %v", got, want)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	if got := Metrics("e"); !reflect.DeepEqual(got, []string{MetricErrors}) {
+		t.Errorf("Metrics() = %v", got)
+	}
+	if got := Metrics(""); len(got) != 3 {
+		t.Errorf("Metrics() = %v", got)
+	}
+}
@@ -0,0 +1,110 @@
+// Package guardrail rejects, or clamps, the searches that are obviously expensive
+// before they're sent to the backends.
+package guardrail
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+)
+
+// GlobalGuardrails is nil when the guardrails aren't configured, every search is then allowed
+var GlobalGuardrails *Guardrails
+
+type Guardrails struct {
+	maxTimeRange    time.Duration
+	maxLimit        int64
+	requireSelector bool
+	clamp           bool
+}
+
+func New(config logs.GuardrailsConfig) (*Guardrails, error) {
+	t := &Guardrails{
+		maxLimit:        config.MaxLimit,
+		requireSelector: config.RequireSelector,
+		clamp:           config.Clamp,
+	}
+
+	if config.MaxTimeRange != "" {
+		maxTimeRange, err := durationUtil.ParseDuration(config.MaxTimeRange)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the max time range: %w", err)
+		}
+		if maxTimeRange <= 0 {
+			return nil, fmt.Errorf("the max time range must be positive")
+		}
+		t.maxTimeRange = time.Duration(maxTimeRange)
+	}
+	if t.maxLimit < 0 {
+		return nil, fmt.Errorf("the max limit can't be negative")
+	}
+
+	return t, nil
+}
+
+// Check returns an error telling how to narrow the search when it selects no logs
+// or spans a time range wider than the maximum. Wider time ranges are narrowed instead when clamping.
+func (t *Guardrails) Check(q *logs.SearchParams) error {
+	if t == nil {
+		return nil
+	}
+
+	if t.requireSelector && q.Type == "" && q.Id == "" && len(q.Labels) == 0 {
+		return fmt.Errorf("the search has no type, id or labels and would scan every log: set at least one of them")
+	}
+
+	if t.maxTimeRange <= 0 {
+		return nil
+	}
+
+	start := q.GetStart()
+	if start == nil {
+		return fmt.Errorf("the start %q isn't a valid RFC3339 time or age, e.g. 1h", q.Start)
+	}
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+
+	if timeRange := end.Sub(*start); timeRange > t.maxTimeRange {
+		if !t.clamp {
+			return fmt.Errorf("the search spans %s, more than the maximum of %s: narrow the time range with start and end", humanize(timeRange), humanize(t.maxTimeRange))
+		}
+		q.SetStart(end.Add(-t.maxTimeRange))
+	}
+	return nil
+}
+
+// CheckLimit returns an error when the search returns more results at once than the maximum.
+// The limit is lowered instead when clamping.
+func (t *Guardrails) CheckLimit(q *logs.SearchParams) error {
+	if t == nil || t.maxLimit <= 0 || q.Limit <= t.maxLimit {
+		return nil
+	}
+
+	if !t.clamp {
+		return fmt.Errorf("the limit of %d is more than the maximum of %d: lower it and fetch the next results with the page token", q.Limit, t.maxLimit)
+	}
+	q.Limit = t.maxLimit
+	return nil
+}
+
+// DefaultLimit returns the default limit of a handler lowered to the maximum,
+// so that the searches that don't set a limit aren't rejected
+func (t *Guardrails) DefaultLimit(limit int64) int64 {
+	if t == nil || t.maxLimit <= 0 || limit <= t.maxLimit {
+		return limit
+	}
+	return t.maxLimit
+}
+
+// humanize formats the whole days as such, e.g. 30d instead of 720h0m0s
+func humanize(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
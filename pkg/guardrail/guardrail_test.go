@@ -0,0 +1,111 @@
+package guardrail
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestGuardrails_Check(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    logs.GuardrailsConfig
+		q         logs.SearchParams
+		wantErr   string
+		wantStart string
+	}{
+		{
+			name:   "within the limits",
+			config: logs.GuardrailsConfig{MaxTimeRange: "7d", RequireSelector: true},
+			q:      logs.SearchParams{Type: "KubernetesPod", Start: "2d"},
+		},
+		{
+			name:    "no selector",
+			config:  logs.GuardrailsConfig{RequireSelector: true},
+			q:       logs.SearchParams{Query: "error", Start: "1h"},
+			wantErr: "no type, id or labels",
+		},
+		{
+			name:   "labels are a selector",
+			config: logs.GuardrailsConfig{RequireSelector: true},
+			q:      logs.SearchParams{Labels: map[string]string{"app": "api"}, Start: "1h"},
+		},
+		{
+			name:    "time range too wide",
+			config:  logs.GuardrailsConfig{MaxTimeRange: "7d"},
+			q:       logs.SearchParams{Start: "2023-01-01T00:00:00Z", End: "2023-01-31T00:00:00Z"},
+			wantErr: "the search spans 30d, more than the maximum of 7d",
+		},
+		{
+			name:      "time range clamped",
+			config:    logs.GuardrailsConfig{MaxTimeRange: "7d", Clamp: true},
+			q:         logs.SearchParams{Start: "2023-01-01T00:00:00Z", End: "2023-01-31T00:00:00Z"},
+			wantStart: "2023-01-24T00:00:00Z",
+		},
+		{
+			name:    "invalid start",
+			config:  logs.GuardrailsConfig{MaxTimeRange: "7d"},
+			q:       logs.SearchParams{Start: "yesterday"},
+			wantErr: "isn't a valid RFC3339 time or age",
+		},
+		{
+			name:    "no selector isn't clamped",
+			config:  logs.GuardrailsConfig{RequireSelector: true, Clamp: true},
+			q:       logs.SearchParams{Start: "1h"},
+			wantErr: "no type, id or labels",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guardrails, err := New(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = guardrails.Check(&tt.q)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Check() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Check() error = %v", err)
+			}
+			if tt.wantStart != "" && tt.q.GetStart().UTC().Format(time.RFC3339) != tt.wantStart {
+				t.Errorf("Check() start = %s, want %s", tt.q.Start, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestGuardrails_CheckLimit(t *testing.T) {
+	guardrails, _ := New(logs.GuardrailsConfig{MaxLimit: 1000})
+	if err := guardrails.CheckLimit(&logs.SearchParams{Limit: 1000}); err != nil {
+		t.Errorf("CheckLimit() error = %v", err)
+	}
+	if err := guardrails.CheckLimit(&logs.SearchParams{Limit: 5000}); err == nil || !strings.Contains(err.Error(), "page token") {
+		t.Errorf("CheckLimit() error = %v, want the limit to be rejected", err)
+	}
+
+	clamping, _ := New(logs.GuardrailsConfig{MaxLimit: 1000, Clamp: true})
+	q := logs.SearchParams{Limit: 5000}
+	if err := clamping.CheckLimit(&q); err != nil || q.Limit != 1000 {
+		t.Errorf("CheckLimit() = %v with limit %d, want the limit clamped to 1000", err, q.Limit)
+	}
+
+	var disabled *Guardrails
+	if err := disabled.CheckLimit(&logs.SearchParams{Limit: 5000}); err != nil {
+		t.Errorf("CheckLimit() error = %v without guardrails", err)
+	}
+
+	if limit := guardrails.DefaultLimit(10000); limit != 1000 {
+		t.Errorf("DefaultLimit() = %d, want the default lowered to 1000", limit)
+	}
+	if limit := disabled.DefaultLimit(10000); limit != 10000 {
+		t.Errorf("DefaultLimit() = %d without guardrails, want 10000", limit)
+	}
+}
@@ -0,0 +1,259 @@
+// Package health probes the backends in the background and tracks their health,
+// so that the searches skip the failing backends instead of waiting for them to time out.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var backendHealthy = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "apm_hub_backend_healthy",
+	Help: "Whether the backend is healthy (1) or skipped by the searches (0)",
+}, []string{"backend"})
+
+// ErrUnhealthy is the error of the backends skipped by the searches
+var ErrUnhealthy = errors.New("the backend is unhealthy")
+
+// GlobalChecker is nil when the health checks aren't configured, every backend is then searched
+var GlobalChecker *Checker
+
+// Status is the health of a backend
+type Status struct {
+	Healthy bool `json:"healthy"`
+
+	// Failures is the number of consecutive failed probes and searches
+	Failures  int    `json:"failures,omitempty"`
+	LastError string `json:"lastError,omitempty"`
+
+	// LastProbe is the time of the last probe, nil for the backends that can't be probed
+	LastProbe *time.Time `json:"lastProbe,omitempty"`
+
+	// Since is the time the backend became healthy or unhealthy
+	Since time.Time `json:"since"`
+}
+
+// Checker keeps the health of the backends, by name.
+// Its methods can be called on a nil checker, every backend is then healthy.
+type Checker struct {
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+
+	lock     sync.Mutex
+	statuses map[string]*Status
+}
+
+func NewChecker(config logs.HealthConfig) (*Checker, error) {
+	t := &Checker{
+		interval:  30 * time.Second,
+		timeout:   10 * time.Second,
+		threshold: config.FailureThreshold,
+		statuses:  make(map[string]*Status),
+	}
+	if t.threshold <= 0 {
+		t.threshold = 3
+	}
+
+	if config.Interval != "" {
+		interval, err := durationUtil.ParseDuration(config.Interval)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the health check interval: %w", err)
+		}
+		t.interval = time.Duration(interval)
+	}
+	if config.Timeout != "" {
+		timeout, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the health check timeout: %w", err)
+		}
+		t.timeout = time.Duration(timeout)
+	}
+	if t.interval <= 0 {
+		return nil, fmt.Errorf("the health check interval must be positive")
+	}
+
+	return t, nil
+}
+
+// Start probes the backends returned by the function, by name, right away and then after every interval
+func (t *Checker) Start(backends func() map[string]logs.SearchBackend) {
+	go func() {
+		ticker := time.NewTicker(t.interval)
+		defer ticker.Stop()
+		for {
+			t.Probe(context.Background(), backends())
+			<-ticker.C
+		}
+	}()
+}
+
+// Probe checks the backends that implement logs.HealthChecker and gives another chance to the
+// unhealthy backends that don't. The statuses of the backends no longer configured are removed.
+func (t *Checker) Probe(ctx context.Context, backends map[string]logs.SearchBackend) {
+	var wg sync.WaitGroup
+	for name, backend := range backends {
+		checker, ok := backend.API.(logs.HealthChecker)
+		if !ok {
+			t.readmit(name)
+			continue
+		}
+
+		wg.Add(1)
+		go func(name string, checker logs.HealthChecker) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, t.timeout)
+			defer cancel()
+
+			err := checker.HealthCheck(ctx)
+			t.record(name, err, true)
+		}(name, checker)
+	}
+	wg.Wait()
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	for name := range t.statuses {
+		if _, ok := backends[name]; !ok {
+			delete(t.statuses, name)
+			backendHealthy.DeleteLabelValues(name)
+		}
+	}
+}
+
+// Allow returns false while the backend is unhealthy
+func (t *Checker) Allow(name string) bool {
+	if t == nil {
+		return true
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	status, ok := t.statuses[name]
+	return !ok || status.Healthy
+}
+
+// Record counts the failed searches of the backend towards the failure threshold,
+// a successful search resets the count
+func (t *Checker) Record(name string, err error) {
+	if t == nil {
+		return
+	}
+	t.record(name, err, false)
+}
+
+func (t *Checker) record(name string, err error, probe bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	now := time.Now()
+	status := t.status(name, now)
+	if probe {
+		status.LastProbe = &now
+	}
+
+	if err == nil {
+		status.Failures = 0
+		status.LastError = ""
+		if !status.Healthy {
+			logger.Infof("backend %s is healthy again", name)
+			status.Healthy = true
+			status.Since = now
+		}
+		backendHealthy.WithLabelValues(name).Set(1)
+		return
+	}
+
+	status.Failures++
+	status.LastError = err.Error()
+	if status.Healthy && status.Failures >= t.threshold {
+		logger.Warnf("backend %s is unhealthy after %d failures, skipping it until it recovers: %v", name, status.Failures, err)
+		status.Healthy = false
+		status.Since = now
+		backendHealthy.WithLabelValues(name).Set(0)
+	}
+}
+
+// readmit makes an unhealthy backend healthy again, short of one failure
+// from the threshold, so that the next search tells if it recovered
+func (t *Checker) readmit(name string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	status := t.status(name, time.Now())
+	if status.Healthy {
+		return
+	}
+	logger.Infof("giving backend %s another chance", name)
+	status.Healthy = true
+	status.Failures = t.threshold - 1
+	status.Since = time.Now()
+	backendHealthy.WithLabelValues(name).Set(1)
+}
+
+// status returns the status of the backend, healthy when it's first seen
+func (t *Checker) status(name string, now time.Time) *Status {
+	status, ok := t.statuses[name]
+	if !ok {
+		status = &Status{Healthy: true, Since: now}
+		t.statuses[name] = status
+		backendHealthy.WithLabelValues(name).Set(1)
+	}
+	return status
+}
+
+// Status returns a copy of the status of the backend, nil when it's unknown
+func (t *Checker) Status(name string) *Status {
+	if t == nil {
+		return nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	status, ok := t.statuses[name]
+	if !ok {
+		return nil
+	}
+	copied := *status
+	return &copied
+}
+
+// Ready returns false while every known backend is unhealthy, along with the names of the unhealthy backends
+func (t *Checker) Ready() (bool, []string) {
+	if t == nil {
+		return true, nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	var unhealthy []string
+	for name, status := range t.statuses {
+		if !status.Healthy {
+			unhealthy = append(unhealthy, name)
+		}
+	}
+	sort.Strings(unhealthy)
+	return len(t.statuses) == 0 || len(unhealthy) < len(t.statuses), unhealthy
+}
+
+// ReadyHandler fails while every backend is unhealthy, as the server can't answer any search
+func ReadyHandler(c echo.Context) error {
+	ready, unhealthy := GlobalChecker.Ready()
+	status := http.StatusOK
+	if !ready {
+		status = http.StatusServiceUnavailable
+	}
+	return c.JSON(status, map[string]any{"ready": ready, "unhealthy": unhealthy})
+}
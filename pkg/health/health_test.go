@@ -0,0 +1,107 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+type fakeBackend struct {
+	err error
+}
+
+func (fakeBackend) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	return logs.SearchResults{}, nil
+}
+
+func (fakeBackend) MatchRoute(q *logs.SearchParams) (bool, bool) {
+	return true, false
+}
+
+func (fakeBackend) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
+
+// probedBackend implements logs.HealthChecker
+type probedBackend struct {
+	fakeBackend
+}
+
+func (t *probedBackend) HealthCheck(ctx context.Context) error {
+	return t.err
+}
+
+func TestChecker(t *testing.T) {
+	checker, err := NewChecker(logs.HealthConfig{FailureThreshold: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probed := &probedBackend{fakeBackend{err: errors.New("connection refused")}}
+	backends := map[string]logs.SearchBackend{
+		"elastic": {API: probed},
+		"files":   {API: fakeBackend{}},
+	}
+
+	// The failed searches count towards the threshold
+	checker.Record("files", errors.New("timeout"))
+	if !checker.Allow("files") {
+		t.Fatalf("expected files to be healthy below the threshold")
+	}
+	checker.Record("files", errors.New("timeout"))
+	if checker.Allow("files") {
+		t.Fatalf("expected files to be unhealthy at the threshold")
+	}
+
+	checker.Probe(context.Background(), backends)
+	checker.Probe(context.Background(), backends)
+	if checker.Allow("elastic") {
+		t.Errorf("expected elastic to be unhealthy after 2 failed probes")
+	}
+	if status := checker.Status("elastic"); status == nil || status.LastError != "connection refused" || status.LastProbe == nil {
+		t.Errorf("Status() = %+v, want the last probe error", status)
+	}
+
+	// The backends that can't be probed are given another chance, that a single failure takes back
+	if !checker.Allow("files") {
+		t.Errorf("expected files to be readmitted by the probe")
+	}
+	checker.Record("files", errors.New("timeout"))
+	if checker.Allow("files") {
+		t.Errorf("expected files to be unhealthy again after a failure")
+	}
+
+	if ready, unhealthy := checker.Ready(); ready || !reflect.DeepEqual(unhealthy, []string{"elastic", "files"}) {
+		t.Errorf("Ready() = %v, %v, want false with both backends unhealthy", ready, unhealthy)
+	}
+
+	// A successful probe readmits the backend
+	probed.err = nil
+	checker.Probe(context.Background(), backends)
+	if !checker.Allow("elastic") {
+		t.Errorf("expected elastic to be healthy once the probe succeeds")
+	}
+	if ready, _ := checker.Ready(); !ready {
+		t.Errorf("expected the checker to be ready with a healthy backend")
+	}
+
+	// The backends no longer configured are forgotten
+	checker.Probe(context.Background(), map[string]logs.SearchBackend{"elastic": {API: probed}})
+	if checker.Status("files") != nil {
+		t.Errorf("expected the status of files to be removed")
+	}
+}
+
+func TestNilChecker(t *testing.T) {
+	var checker *Checker
+	checker.Record("elastic", errors.New("timeout"))
+	if !checker.Allow("elastic") || checker.Status("elastic") != nil {
+		t.Errorf("expected a nil checker to allow every backend")
+	}
+	if ready, _ := checker.Ready(); !ready {
+		t.Errorf("expected a nil checker to be ready")
+	}
+}
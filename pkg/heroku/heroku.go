@@ -0,0 +1,190 @@
+// Package heroku reads the recent logs of Heroku apps with the log sessions of the platform api
+package heroku
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const (
+	defaultURL = "https://api.heroku.com"
+
+	// maxLines is the number of lines retained by Logplex, the maximum of a log session
+	maxLines = 1500
+)
+
+// lineRegexp matches the lines of Logplex, e.g. 2023-05-01T12:00:00.123456+00:00 app[web.1]: message
+var lineRegexp = regexp.MustCompile(`^(\S+) ([\w-]+)\[([^\]]+)\]: ?(.*)$`)
+
+type HerokuSearch struct {
+	config *logs.HerokuBackendConfig
+	client *rest.Client
+	// logplex reads the log sessions, whose urls are authenticated by their token and not by the api key
+	logplex *http.Client
+
+	app    *template.Template
+	dyno   *template.Template
+	source *template.Template
+}
+
+func NewHerokuSearchBackend(kClient *kommons.Client, config *logs.HerokuBackendConfig) (*HerokuSearch, error) {
+	app := config.App
+	if app == "" {
+		app = "{{.Id}}"
+	}
+	t := &HerokuSearch{config: config, logplex: &http.Client{Timeout: time.Minute}}
+	var err error
+	if t.app, err = template.New("app").Parse(app); err != nil {
+		return nil, fmt.Errorf("error parsing the app template: %w", err)
+	}
+	if t.dyno, err = template.New("dyno").Parse(config.Dyno); err != nil {
+		return nil, fmt.Errorf("error parsing the dyno template: %w", err)
+	}
+	if t.source, err = template.New("source").Parse(config.Source); err != nil {
+		return nil, fmt.Errorf("error parsing the source template: %w", err)
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	conn.Headers = map[string]kommons.EnvVar{}
+	for name, header := range config.Headers {
+		conn.Headers[name] = header
+	}
+	conn.Headers["Accept"] = kommons.EnvVar{Value: "application/vnd.heroku+json; version=3"}
+	if t.client, err = rest.NewClient(kClient, conn); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func render(tpl *template.Template, q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing the %s template: %w", tpl.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Search reads the last lines of the app in the time range of the search, up to the limit per item
+func (t *HerokuSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+	app, err := render(t.app, q)
+	if err != nil {
+		return results, err
+	}
+	if app == "" {
+		return results, fmt.Errorf("the app of the search is empty")
+	}
+	dyno, err := render(t.dyno, q)
+	if err != nil {
+		return results, err
+	}
+	source, err := render(t.source, q)
+	if err != nil {
+		return results, err
+	}
+
+	limit := q.LimitPerItem
+	if limit == 0 {
+		limit = q.Limit
+	}
+	session := map[string]any{"lines": maxLines, "tail": false}
+	if limit > 0 && limit < maxLines && q.GetStart() == nil && q.GetEnd() == nil {
+		session["lines"] = limit
+	}
+	if dyno != "" {
+		session["dyno_name"] = dyno
+	}
+	if source != "" {
+		session["source"] = source
+	}
+	var res struct {
+		LogplexURL string `json:"logplex_url"`
+	}
+	if err := t.client.Post(ctx, "/apps/"+url.PathEscape(app)+"/log-sessions", nil, session, &res); err != nil {
+		return results, fmt.Errorf("error creating the log session of %s: %w", app, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, res.LogplexURL, nil)
+	if err != nil {
+		return results, err
+	}
+	resp, err := t.logplex.Do(req)
+	if err != nil {
+		return results, fmt.Errorf("error reading the log session of %s: %w", app, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return results, fmt.Errorf("error reading the log session of %s: %s", app, resp.Status)
+	}
+
+	start, end := q.GetStart(), q.GetEnd()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		r, written, ok := t.result(app, scanner.Text())
+		if !ok {
+			continue
+		}
+		if (start != nil && written.Before(*start)) || (end != nil && written.After(*end)) {
+			continue
+		}
+		results.Results = append(results.Results, r)
+		if limit > 0 && int64(len(results.Results)) >= 2*limit {
+			results.Results = append(results.Results[:0], results.Results[int64(len(results.Results))-limit:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return results, fmt.Errorf("error reading the log session of %s: %w", app, err)
+	}
+	if limit > 0 && int64(len(results.Results)) > limit {
+		results.Results = results.Results[int64(len(results.Results))-limit:]
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// result returns the result of the line, with the source and dyno of the line as labels
+func (t *HerokuSearch) result(app, line string) (logs.Result, time.Time, bool) {
+	match := lineRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return logs.Result{}, time.Time{}, false
+	}
+	written, err := time.Parse(time.RFC3339Nano, match[1])
+	if err != nil {
+		return logs.Result{}, time.Time{}, false
+	}
+
+	r := logs.Result{
+		Time:    written.UTC().Format(time.RFC3339Nano),
+		Message: match[4],
+		Labels:  map[string]string{"app": app, "source": match[2], "dyno": match[3]},
+	}
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r, written, true
+}
+
+func (t *HerokuSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Heroku backend, the query of the search is applied to the lines afterwards
+func (t *HerokuSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
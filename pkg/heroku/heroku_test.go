@@ -0,0 +1,85 @@
+package heroku
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const sessionLines = `2023-05-01T11:59:59.000000+00:00 app[web.1]: before the search
+2023-05-01T12:00:01.250000+00:00 heroku[router]: at=error code=H12 desc="Request timeout" path="/cart"
+not a logplex line
+2023-05-01T12:00:02.000000+00:00 app[web.1]: checkout failed
+2023-05-01T12:00:03+00:00 app[worker.1]: retrying
+`
+
+func TestHerokuSearch(t *testing.T) {
+	var session map[string]any
+	var logplexAuthorization string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/apps/shop/log-sessions":
+			if r.Header.Get("Authorization") != "Bearer secret" || r.Header.Get("Accept") != "application/vnd.heroku+json; version=3" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if err := json.NewDecoder(r.Body).Decode(&session); err != nil {
+				t.Fatal(err)
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprintf(w, `{"id": "s1", "logplex_url": "%s/sessions/s1?srv=1"}`, server.URL)
+		case "/sessions/s1":
+			logplexAuthorization = r.Header.Get("Authorization")
+			_, _ = w.Write([]byte(sessionLines))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewHerokuSearchBackend(nil, &logs.HerokuBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"platform": "heroku"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Token: &kommons.EnvVar{Value: "secret"}},
+		Dyno:           `{{index .Labels "dyno"}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Id: "shop", Start: "2023-05-01T12:00:00Z", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:02Z", Message: "checkout failed", Labels: map[string]string{"app": "shop", "source": "app", "dyno": "web.1", "platform": "heroku"}},
+		{Time: "2023-05-01T12:00:03Z", Message: "retrying", Labels: map[string]string{"app": "shop", "source": "app", "dyno": "worker.1", "platform": "heroku"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+	if wantSession := map[string]any{"lines": float64(1500), "tail": false}; !reflect.DeepEqual(session, wantSession) {
+		t.Errorf("session = %v, want %v", session, wantSession)
+	}
+	if logplexAuthorization != "" {
+		t.Errorf("the api key was sent to logplex")
+	}
+
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Id: "shop", Labels: map[string]string{"dyno": "router"}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 1 || res.Results[0].Message != "retrying" {
+		t.Errorf("Search() = %+v, want the last line", res)
+	}
+	if wantSession := map[string]any{"lines": float64(1), "tail": false, "dyno_name": "router"}; !reflect.DeepEqual(session, wantSession) {
+		t.Errorf("session = %v, want %v", session, wantSession)
+	}
+}
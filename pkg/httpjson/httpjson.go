@@ -0,0 +1,255 @@
+// Package httpjson searches the json apis described by the templates of their requests and the JSONPath of their results
+package httpjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// funcs are the functions of the templates, in addition to the builtin functions, e.g. urlquery
+var funcs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+type HTTPSearch struct {
+	config *logs.HTTPBackendConfig
+	client *rest.Client
+
+	path *template.Template
+	// body is nil when the requests have no body
+	body *template.Template
+
+	results   *jsonpath.JSONPath
+	nextPage  *jsonpath.JSONPath
+	timestamp *jsonpath.JSONPath
+	message   *jsonpath.JSONPath
+	id        *jsonpath.JSONPath
+	labels    map[string]*jsonpath.JSONPath
+
+	timestamps *logs.TimestampParser
+}
+
+func NewHTTPSearchBackend(kClient *kommons.Client, config *logs.HTTPBackendConfig) (*HTTPSearch, error) {
+	client, err := rest.NewClient(kClient, config.HTTPConnection)
+	if err != nil {
+		return nil, err
+	}
+	t := &HTTPSearch{config: config, client: client, labels: make(map[string]*jsonpath.JSONPath, len(config.Fields.Labels))}
+
+	if t.path, err = template.New("path").Funcs(funcs).Parse(config.Path); err != nil {
+		return nil, fmt.Errorf("error parsing the path template: %w", err)
+	}
+	if config.Body != "" {
+		if t.body, err = template.New("body").Funcs(funcs).Parse(config.Body); err != nil {
+			return nil, fmt.Errorf("error parsing the body template: %w", err)
+		}
+	}
+
+	paths := map[string]**jsonpath.JSONPath{
+		"results":   &t.results,
+		"nextPage":  &t.nextPage,
+		"timestamp": &t.timestamp,
+		"message":   &t.message,
+		"id":        &t.id,
+	}
+	values := map[string]string{
+		"results":   config.Results,
+		"nextPage":  config.NextPage,
+		"timestamp": config.Fields.Timestamp,
+		"message":   config.Fields.Message,
+		"id":        config.Fields.ID,
+	}
+	for name, path := range paths {
+		if *path, err = parsePath(name, values[name]); err != nil {
+			return nil, err
+		}
+	}
+	for label, path := range config.Fields.Labels {
+		if t.labels[label], err = parsePath(label, path); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.timestamps, err = logs.NewTimestampParser(nil, ""); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// parsePath parses the JSONPath, the braces being optional, e.g. .data.logs. It's nil when empty.
+func parsePath(name, path string) (*jsonpath.JSONPath, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "{") {
+		path = "{" + path + "}"
+	}
+
+	p := jsonpath.New(name).AllowMissingKeys(true)
+	if err := p.Parse(path); err != nil {
+		return nil, fmt.Errorf("error parsing the JSONPath of %s: %w", name, err)
+	}
+	return p, nil
+}
+
+// Search sends the request rendered with the search params, and returns the results of the response
+func (t *HTTPSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	var path bytes.Buffer
+	if err := t.path.Execute(&path, q); err != nil {
+		return results, fmt.Errorf("error executing the path template: %w", err)
+	}
+
+	method := t.config.Method
+	var body io.Reader
+	if t.body != nil {
+		var buf bytes.Buffer
+		if err := t.body.Execute(&buf, q); err != nil {
+			return results, fmt.Errorf("error executing the body template: %w", err)
+		}
+		body = &buf
+		if method == "" {
+			method = http.MethodPost
+		}
+	}
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, t.client.URL(path.String(), nil), body)
+	if err != nil {
+		return results, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return results, err
+	}
+	defer resp.Body.Close()
+
+	var data any
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&data); err != nil {
+		return results, fmt.Errorf("error decoding the response: %w", err)
+	}
+
+	items := []any{data}
+	if t.results != nil {
+		items = find(t.results, data)
+	}
+	// The path of the results is either the array of results, or each result, e.g. {.data.logs[*]}
+	if len(items) == 1 {
+		if array, ok := items[0].([]any); ok {
+			items = array
+		}
+	}
+
+	for _, item := range items {
+		results.Results = append(results.Results, t.result(item))
+	}
+	results.Total = len(results.Results)
+	if t.nextPage != nil {
+		results.NextPage, _ = first(t.nextPage, data)
+	}
+	return results, nil
+}
+
+// result returns the result of an item of the response, its message being the item when the message path isn't set
+func (t *HTTPSearch) result(item any) logs.Result {
+	var r logs.Result
+	if t.message != nil {
+		r.Message, _ = first(t.message, item)
+	} else {
+		r.Message = stringify(item)
+	}
+	if t.id != nil {
+		r.Id, _ = first(t.id, item)
+	}
+	if t.timestamp != nil {
+		if value, ok := first(t.timestamp, item); ok {
+			if ts, ok := t.timestamps.Parse(value); ok {
+				r.Time = ts.UTC().Format(time.RFC3339Nano)
+			}
+		}
+	}
+
+	r.Labels = make(map[string]string, len(t.labels)+len(t.config.Labels))
+	for label, path := range t.labels {
+		if value, ok := first(path, item); ok {
+			r.Labels[label] = value
+		}
+	}
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+// find returns the values of the path in data
+func find(path *jsonpath.JSONPath, data any) []any {
+	found, err := path.FindResults(data)
+	if err != nil {
+		return nil
+	}
+
+	var values []any
+	for _, result := range found {
+		for _, v := range result {
+			values = append(values, v.Interface())
+		}
+	}
+	return values
+}
+
+// first returns the first value of the path in data, false when it's missing or null
+func first(path *jsonpath.JSONPath, data any) (string, bool) {
+	values := find(path, data)
+	if len(values) == 0 || values[0] == nil {
+		return "", false
+	}
+	return stringify(values[0]), true
+}
+
+func stringify(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+func (t *HTTPSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the api, the query and the page of the search are applied when the templates use them
+func (t *HTTPSearch) Capabilities() logs.Capabilities {
+	templates := t.config.Path + t.config.Body
+	return logs.Capabilities{
+		Query:      strings.Contains(templates, ".Query"),
+		Pagination: t.nextPage != nil && strings.Contains(templates, ".Page"),
+	}
+}
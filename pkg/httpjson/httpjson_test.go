@@ -0,0 +1,103 @@
+package httpjson
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestHTTPSearch(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/search" || r.URL.Query().Get("app") != "shop api" || r.Header.Get("X-API-Key") != "abcd" {
+			http.Error(w, "unexpected request "+r.Method+" "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`{"data": {"logs": [
+			{"id": 1, "ts": 1682942400000, "msg": "started", "attributes": {"level": "info"}},
+			{"id": 2, "ts": "2023-05-01T12:00:01Z", "msg": "it's \"failing\"", "attributes": {"level": "error"}}
+		]}, "cursor": "next"}`))
+	}))
+	defer server.Close()
+
+	backend, err := NewHTTPSearchBackend(nil, &logs.HTTPBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "api"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Headers: map[string]kommons.EnvVar{"X-API-Key": {Value: "abcd"}}},
+		Path:           `/api/search?app={{.Id | urlquery}}`,
+		Body:           `{"query": {{json .Query}}, "from": {{json .GetStartISO}}, "limit": {{.Limit}}, "cursor": {{json .Page}}}`,
+		Results:        ".data.logs",
+		NextPage:       "{.cursor}",
+		Fields: logs.HTTPFields{
+			Timestamp: ".ts",
+			Message:   ".msg",
+			ID:        ".id",
+			Labels:    map[string]string{"level": ".attributes.level", "missing": ".attributes.missing"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Id: "shop api", Query: `"failing"`, Start: "2023-05-01T12:00:00Z", Limit: 2, Page: "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, NextPage: "next", Results: []logs.Result{
+		{Id: "1", Time: "2023-05-01T12:00:00Z", Message: "started", Labels: map[string]string{"level": "info", "source": "api"}},
+		{Id: "2", Time: "2023-05-01T12:00:01Z", Message: `it's "failing"`, Labels: map[string]string{"level": "error", "source": "api"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantBody := map[string]any{"query": `"failing"`, "from": "2023-05-01T12:00:00.000Z", "limit": float64(2), "cursor": "1"}
+	if !reflect.DeepEqual(body, wantBody) {
+		t.Errorf("body = %v, want %v", body, wantBody)
+	}
+
+	if c := backend.Capabilities(); !c.Query || !c.Pagination {
+		t.Errorf("Capabilities() = %+v, want the query and the pagination", c)
+	}
+}
+
+func TestHTTPSearch_Array(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "unexpected method "+r.Method, http.StatusMethodNotAllowed)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"line": "started"}]`))
+	}))
+	defer server.Close()
+
+	backend, err := NewHTTPSearchBackend(nil, &logs.HTTPBackendConfig{HTTPConnection: logs.HTTPConnection{URL: server.URL}, Path: "/logs"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 1, Results: []logs.Result{{Message: `{"line":"started"}`, Labels: map[string]string{}}}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+}
+
+func TestNewHTTPSearchBackend(t *testing.T) {
+	_, err := NewHTTPSearchBackend(nil, &logs.HTTPBackendConfig{HTTPConnection: logs.HTTPConnection{URL: "http://localhost"}, Results: "{.data[}"})
+	if err == nil {
+		t.Error("NewHTTPSearchBackend() with an invalid JSONPath succeeded")
+	}
+}
@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/audit"
+	"github.com/flanksource/apm-hub/pkg/missioncontrol"
+)
+
+// AttachSnapshot searches the logs and attaches the first results
+// to the Mission Control incident, along with a link to the search
+func AttachSnapshot(c echo.Context) error {
+	cc := c.(*api.Context)
+	if missioncontrol.GlobalClient == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "mission control isn't configured")
+	}
+
+	searchParams := new(logs.SearchParams)
+	if err := c.Bind(searchParams); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	searchParams.SetDefaults()
+
+	grant, err := authorize(cc, searchParams)
+	if err != nil {
+		return err
+	}
+
+	backendResults, _ := searchBackends(cc.Request().Context(), cc.Tenant, searchParams, grant)
+	var backends []string
+	for _, backendResult := range backendResults {
+		backends = append(backends, backendResult.Backend)
+	}
+	results := mergeResults(backendResults)
+	audit.GlobalAuditor.Record(newAuditEvent(cc, searchParams, backends, results.Total, nil))
+
+	var user string
+	if cc.User != nil {
+		user = cc.User.Name
+	}
+	snapshot := missioncontrol.GlobalClient.NewSnapshot(c.Param("id"), user, *searchParams, results)
+	if err := missioncontrol.GlobalClient.Attach(snapshot); err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+
+	return cc.JSON(http.StatusCreated, snapshot)
+}
@@ -0,0 +1,593 @@
+package ingest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The access log service of envoy/service/accesslog/v3/als.proto.
+// The messages are decoded with protowire, reading only the fields promoted to labels,
+// so that the Envoy protos aren't a dependency.
+const alsService = "envoy.service.accesslog.v3.AccessLogService"
+
+var (
+	httpMethods   = []string{"", "GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH"}
+	httpProtocols = []string{"", "HTTP/1.0", "HTTP/1.1", "HTTP/2", "HTTP/3"}
+
+	// responseFlags are the short names of the fields of envoy.data.accesslog.v3.ResponseFlags, by number
+	responseFlags = []string{"", "LH", "UH", "UT", "LR", "UR", "UF", "UC", "UO", "NR", "DI", "FI", "RL", "UAEX", "RLSE", "DC", "URX", "SI", "IH", "DPE", "UMSDR"}
+)
+
+// rawCodec passes the messages as bytes, to be decoded with protowire
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	return *(v.(*[]byte)), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*(v.(*[]byte)) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+type accessLogService interface {
+	streamAccessLogs(stream grpc.ServerStream) error
+}
+
+// ServeALS listens for the access logs streamed by Envoy in the background.
+// The access logs and the ingest tokens are received over TLS when the tls config isn't nil.
+func (t *Ingester) ServeALS(config logs.ALSConfig, tlsConfig *tls.Config) error {
+	address := config.Address
+	if address == "" {
+		address = ":9001"
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", address, err)
+	}
+
+	server := t.alsServer(tlsConfig)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			logger.Errorf("error serving the envoy access log service: %v", err)
+		}
+	}()
+	return nil
+}
+
+func (t *Ingester) alsServer(tlsConfig *tls.Config) *grpc.Server {
+	opts := []grpc.ServerOption{grpc.ForceServerCodec(rawCodec{})}
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+
+	server := grpc.NewServer(opts...)
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: alsService,
+		HandlerType: (*accessLogService)(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "StreamAccessLogs",
+			ClientStreams: true,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				return srv.(accessLogService).streamAccessLogs(stream)
+			},
+		}},
+	}, t)
+	return server
+}
+
+// streamAccessLogs writes the access logs of the stream to the store.
+// The proxy identifies itself in the first message of the stream only.
+func (t *Ingester) streamAccessLogs(stream grpc.ServerStream) error {
+	tok := t.authenticateStream(stream.Context())
+	if tok == nil {
+		return status.Error(codes.Unauthenticated, "invalid or missing ingest token")
+	}
+
+	var identity map[string]string
+	for {
+		var msg []byte
+		if err := stream.RecvMsg(&msg); errors.Is(err, io.EOF) {
+			return stream.SendMsg(&[]byte{})
+		} else if err != nil {
+			return err
+		}
+
+		results, id, err := decodeAccessLogs(msg)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid access logs: %v", err)
+		}
+		if id != nil {
+			identity = id
+		}
+
+		for i := range results {
+			for k, v := range identity {
+				results[i].Labels[k] = v
+			}
+			for k, v := range tok.labels {
+				results[i].Labels[k] = v
+			}
+		}
+
+		if err := t.write(results); errors.Is(err, ErrWALFull) {
+			return status.Error(codes.ResourceExhausted, err.Error())
+		} else if errors.Is(err, ErrBatchTooLarge) {
+			return status.Error(codes.InvalidArgument, err.Error())
+		} else if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		ingestedLines.WithLabelValues(tok.name).Add(float64(len(results)))
+	}
+}
+
+func (t *Ingester) authenticateStream(ctx context.Context) *token {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, authorization := range md.Get("authorization") {
+		if tok := t.lookup(authorization); tok != nil {
+			return tok
+		}
+	}
+	return nil
+}
+
+// decodeAccessLogs decodes a StreamAccessLogsMessage into a result per entry.
+// The labels identifying the proxy are returned when the message has an identifier.
+func decodeAccessLogs(msg []byte) ([]logs.Result, map[string]string, error) {
+	var results []logs.Result
+	var identity map[string]string
+
+	err := walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		decode := decodeHTTPEntry
+		switch num {
+		case 1:
+			identity = decodeIdentifier(v)
+			return nil
+		case 2:
+		case 3:
+			decode = decodeTCPEntry
+		default:
+			return nil
+		}
+
+		// The entries are the repeated field 1 of HTTPAccessLogEntries and TCPAccessLogEntries
+		return walk(v, func(num protowire.Number, typ protowire.Type, entry []byte) error {
+			if num != 1 || typ != protowire.BytesType {
+				return nil
+			}
+			r, err := decode(entry)
+			if err != nil {
+				return err
+			}
+			results = append(results, r)
+			return nil
+		})
+	})
+	return results, identity, err
+}
+
+// decodeIdentifier returns the labels of the node of the proxy and of the name of the log.
+// The pod and namespace of the Istio sidecars are read from the node id, e.g. sidecar~10.0.0.1~api-0.shop~shop.svc.cluster.local
+func decodeIdentifier(msg []byte) map[string]string {
+	labels := map[string]string{}
+	_ = walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			_ = walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if typ != protowire.BytesType {
+					return nil
+				}
+				switch num {
+				case 1:
+					labels["node"] = string(v)
+				case 2:
+					labels["node_cluster"] = string(v)
+				}
+				return nil
+			})
+		case num == 2 && typ == protowire.BytesType:
+			labels["log_name"] = string(v)
+		}
+		return nil
+	})
+
+	if parts := strings.Split(labels["node"], "~"); len(parts) == 4 {
+		if pod, namespace, ok := cut(parts[2]); ok {
+			labels["pod"] = pod
+			labels["namespace"] = namespace
+		}
+	}
+	return labels
+}
+
+// cut splits the pod name and the namespace at the last dot
+func cut(s string) (string, string, bool) {
+	i := strings.LastIndex(s, ".")
+	if i <= 0 || i == len(s)-1 {
+		return "", "", false
+	}
+	return s[:i], s[i+1:], true
+}
+
+// decodeHTTPEntry decodes an HTTPAccessLogEntry, with the message summarizing the request,
+// e.g. POST shop.example.com/checkout HTTP/1.1 503 UF
+func decodeHTTPEntry(msg []byte) (logs.Result, error) {
+	r := logs.Result{Labels: map[string]string{}}
+	var start time.Time
+
+	err := walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			start = decodeCommon(v, r.Labels)
+		case num == 2 && typ == protowire.VarintType:
+			if p := varint(v); p < uint64(len(httpProtocols)) {
+				setLabel(r.Labels, "protocol", httpProtocols[p])
+			}
+		case num == 3 && typ == protowire.BytesType:
+			decodeRequest(v, r.Labels)
+		case num == 4 && typ == protowire.BytesType:
+			decodeResponse(v, r.Labels)
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+
+	var message []string
+	for _, part := range []string{
+		r.Labels["method"],
+		r.Labels["authority"] + r.Labels["path"],
+		r.Labels["protocol"],
+		r.Labels["status"],
+		r.Labels["response_flags"],
+	} {
+		if part != "" {
+			message = append(message, part)
+		}
+	}
+	r.Message = strings.Join(message, " ")
+	r.Time = timeOf(start)
+	return r, nil
+}
+
+// decodeTCPEntry decodes a TCPAccessLogEntry, with the message summarizing the connection,
+// e.g. TCP 10.0.0.1:48000 -> postgres received 1024 sent 2048 bytes
+func decodeTCPEntry(msg []byte) (logs.Result, error) {
+	r := logs.Result{Labels: map[string]string{}}
+	var start time.Time
+	var received, sent uint64
+
+	err := walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			start = decodeCommon(v, r.Labels)
+		case num == 2 && typ == protowire.BytesType:
+			_ = walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if typ != protowire.VarintType {
+					return nil
+				}
+				switch num {
+				case 1:
+					received = varint(v)
+				case 2:
+					sent = varint(v)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+
+	r.Labels["received_bytes"] = strconv.FormatUint(received, 10)
+	r.Labels["sent_bytes"] = strconv.FormatUint(sent, 10)
+	upstream := r.Labels["upstream_cluster"]
+	if upstream == "" {
+		upstream = r.Labels["upstream_host"]
+	}
+	r.Message = "TCP"
+	if downstream := r.Labels["downstream_address"]; downstream != "" {
+		r.Message += " " + downstream
+	}
+	if upstream != "" {
+		r.Message += " -> " + upstream
+	}
+	r.Message += fmt.Sprintf(" received %d sent %d bytes", received, sent)
+	if flags := r.Labels["response_flags"]; flags != "" {
+		r.Message += " " + flags
+	}
+	r.Time = timeOf(start)
+	return r, nil
+}
+
+// decodeCommon adds the labels of an AccessLogCommon and returns the start time of the request
+func decodeCommon(msg []byte, labels map[string]string) time.Time {
+	var start time.Time
+	var duration, lastDownstreamTxByte time.Duration
+
+	_ = walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case 2:
+			setLabel(labels, "downstream_address", decodeAddress(v))
+		case 5:
+			start = decodeTimestamp(v)
+		case 12:
+			lastDownstreamTxByte = decodeDuration(v)
+		case 13:
+			setLabel(labels, "upstream_host", decodeAddress(v))
+		case 15:
+			setLabel(labels, "upstream_cluster", string(v))
+		case 16:
+			setLabel(labels, "response_flags", decodeResponseFlags(v))
+		case 18:
+			setLabel(labels, "upstream_transport_failure_reason", string(v))
+		case 19:
+			setLabel(labels, "route", string(v))
+		case 22:
+			for k, value := range decodeMapEntry(v) {
+				labels[k] = value
+			}
+		case 23:
+			duration = decodeDuration(v)
+		}
+		return nil
+	})
+
+	// The duration was added in Envoy 1.25, the time of the last byte sent downstream is the duration of the older versions
+	if duration == 0 {
+		duration = lastDownstreamTxByte
+	}
+	if duration > 0 {
+		labels["duration"] = duration.String()
+	}
+	return start
+}
+
+func decodeRequest(msg []byte, labels map[string]string) {
+	_ = walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ == protowire.VarintType {
+			switch num {
+			case 1:
+				if m := varint(v); m < uint64(len(httpMethods)) {
+					setLabel(labels, "method", httpMethods[m])
+				}
+			case 12:
+				labels["request_bytes"] = strconv.FormatUint(varint(v), 10)
+			}
+			return nil
+		}
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case 2:
+			setLabel(labels, "scheme", string(v))
+		case 3:
+			setLabel(labels, "authority", string(v))
+		case 5:
+			setLabel(labels, "path", string(v))
+		case 6:
+			setLabel(labels, "user_agent", string(v))
+		case 7:
+			setLabel(labels, "referer", string(v))
+		case 8:
+			setLabel(labels, "forwarded_for", string(v))
+		case 9:
+			setLabel(labels, "request_id", string(v))
+		case 13:
+			for k, value := range decodeMapEntry(v) {
+				labels["request."+strings.ToLower(k)] = value
+			}
+		}
+		return nil
+	})
+}
+
+func decodeResponse(msg []byte, labels map[string]string) {
+	_ = walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ == protowire.VarintType {
+			if num == 3 {
+				labels["response_bytes"] = strconv.FormatUint(varint(v), 10)
+			}
+			return nil
+		}
+		if typ != protowire.BytesType {
+			return nil
+		}
+
+		switch num {
+		case 1:
+			// The status code is a google.protobuf.UInt32Value
+			_ = walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if num == 1 && typ == protowire.VarintType {
+					labels["status"] = strconv.FormatUint(varint(v), 10)
+				}
+				return nil
+			})
+		case 4:
+			for k, value := range decodeMapEntry(v) {
+				labels["response."+strings.ToLower(k)] = value
+			}
+		case 6:
+			setLabel(labels, "response_code_details", string(v))
+		}
+		return nil
+	})
+}
+
+// decodeResponseFlags returns the short names of the flags set, e.g. UF,URX
+func decodeResponseFlags(msg []byte) string {
+	var flags []string
+	_ = walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if num <= 0 || int(num) >= len(responseFlags) {
+			return nil
+		}
+		// The unauthorized details are a message, the other flags are booleans
+		if (typ == protowire.VarintType && varint(v) != 0) || typ == protowire.BytesType {
+			flags = append(flags, responseFlags[num])
+		}
+		return nil
+	})
+	return strings.Join(flags, ",")
+}
+
+// decodeAddress returns the ip and port of a socket address, or the path of a pipe
+func decodeAddress(msg []byte) string {
+	var address string
+	_ = walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			var ip string
+			var port uint64
+			_ = walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				switch {
+				case num == 2 && typ == protowire.BytesType:
+					ip = string(v)
+				case num == 3 && typ == protowire.VarintType:
+					port = varint(v)
+				}
+				return nil
+			})
+			address = net.JoinHostPort(ip, strconv.FormatUint(port, 10))
+		case 2:
+			_ = walk(v, func(num protowire.Number, typ protowire.Type, v []byte) error {
+				if num == 1 && typ == protowire.BytesType {
+					address = string(v)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return address
+}
+
+// decodeTimestamp decodes a google.protobuf.Timestamp
+func decodeTimestamp(msg []byte) time.Time {
+	seconds, nanos := decodeSecondsNanos(msg)
+	return time.Unix(seconds, nanos)
+}
+
+// decodeDuration decodes a google.protobuf.Duration
+func decodeDuration(msg []byte) time.Duration {
+	seconds, nanos := decodeSecondsNanos(msg)
+	return time.Duration(seconds)*time.Second + time.Duration(nanos)
+}
+
+func decodeSecondsNanos(msg []byte) (seconds int64, nanos int64) {
+	_ = walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.VarintType {
+			return nil
+		}
+		switch num {
+		case 1:
+			seconds = int64(varint(v))
+		case 2:
+			nanos = int64(int32(varint(v)))
+		}
+		return nil
+	})
+	return seconds, nanos
+}
+
+// decodeMapEntry decodes an entry of a map<string, string>
+func decodeMapEntry(msg []byte) map[string]string {
+	var key, value string
+	_ = walk(msg, func(num protowire.Number, typ protowire.Type, v []byte) error {
+		if typ != protowire.BytesType {
+			return nil
+		}
+		switch num {
+		case 1:
+			key = string(v)
+		case 2:
+			value = string(v)
+		}
+		return nil
+	})
+	if key == "" {
+		return nil
+	}
+	return map[string]string{key: value}
+}
+
+// walk calls fn with the fields of the message, the values of the length delimited fields
+// are passed without their length and the varints are passed encoded
+func walk(msg []byte, fn func(num protowire.Number, typ protowire.Type, v []byte) error) error {
+	for len(msg) > 0 {
+		num, typ, n := protowire.ConsumeTag(msg)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		msg = msg[n:]
+
+		m := protowire.ConsumeFieldValue(num, typ, msg)
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		v := msg[:m]
+		if typ == protowire.BytesType {
+			v, _ = protowire.ConsumeBytes(v)
+		}
+		if err := fn(num, typ, v); err != nil {
+			return err
+		}
+		msg = msg[m:]
+	}
+	return nil
+}
+
+func varint(v []byte) uint64 {
+	value, _ := protowire.ConsumeVarint(v)
+	return value
+}
+
+func setLabel(labels map[string]string, key, value string) {
+	if value != "" {
+		labels[key] = value
+	}
+}
+
+// timeOf formats the start of the request, the entries without one are received now
+func timeOf(start time.Time) string {
+	if start.IsZero() || start.Unix() == 0 {
+		start = now()
+	}
+	return start.UTC().Format(time.RFC3339Nano)
+}
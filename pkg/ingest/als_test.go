@@ -0,0 +1,213 @@
+package ingest
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// message encodes the fields of a message, the []byte values being nested messages or strings
+func message(fields ...any) []byte {
+	var b []byte
+	for i := 0; i < len(fields); i += 2 {
+		num := protowire.Number(fields[i].(int))
+		switch v := fields[i+1].(type) {
+		case int:
+			b = protowire.AppendTag(b, num, protowire.VarintType)
+			b = protowire.AppendVarint(b, uint64(v))
+		case string:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendString(b, v)
+		case []byte:
+			b = protowire.AppendTag(b, num, protowire.BytesType)
+			b = protowire.AppendBytes(b, v)
+		}
+	}
+	return b
+}
+
+func TestIngester_ServeALS(t *testing.T) {
+	ingester, err := NewIngester(nil, logs.IngestConfig{
+		Path:   t.TempDir(),
+		Tokens: []logs.IngestToken{{Name: "istio", Token: kommons.EnvVar{Value: "secret"}, Labels: map[string]string{"tenant": "a"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ingester.store.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := ingester.alsServer(nil)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	address := func(ip string, port int) []byte { return message(1, message(2, ip, 3, port)) }
+	common := message(
+		2, address("10.0.0.7", 48000),
+		5, message(1, 1682942400, 2, 250000000),
+		13, address("10.0.1.12", 8080),
+		15, "outbound|8080||checkout.shop.svc.cluster.local",
+		16, message(6, 1),
+		22, message(1, "canary", 2, "true"),
+		23, message(2, 12000000),
+	)
+	httpEntry := message(
+		1, common,
+		2, 2,
+		3, message(1, 3, 2, "https", 3, "shop.example.com", 5, "/checkout", 9, "c1d2"),
+		4, message(1, message(1, 503), 3, 91, 6, "upstream_reset_before_response_started"),
+	)
+	first := message(
+		1, message(1, message(1, "sidecar~10.0.0.7~frontend-5d8f.shop~shop.svc.cluster.local", 2, "frontend.shop"), 2, "als"),
+		2, message(1, httpEntry),
+	)
+	tcpEntry := message(1, message(2, address("10.0.0.7", 48010), 5, message(1, 1682942401), 15, "postgres"), 2, message(1, 1024, 2, 2048))
+	second := message(3, message(1, tcpEntry))
+
+	send := func(token string, messages ...[]byte) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+		stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, "/"+alsService+"/StreamAccessLogs", grpc.ForceCodec(rawCodec{}))
+		if err != nil {
+			return err
+		}
+		for _, msg := range messages {
+			msg := msg
+			if err := stream.SendMsg(&msg); err != nil {
+				break
+			}
+		}
+		if err := stream.CloseSend(); err != nil {
+			return err
+		}
+		var res []byte
+		return stream.RecvMsg(&res)
+	}
+
+	if err := send("other", first); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("send() with an invalid token error = %v, want Unauthenticated", err)
+	}
+	if err := send("secret", first, second); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ingester.store.Search(&logs.SearchParams{Labels: map[string]string{"tenant": "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	identity := map[string]string{
+		"node": "sidecar~10.0.0.7~frontend-5d8f.shop~shop.svc.cluster.local", "node_cluster": "frontend.shop", "log_name": "als",
+		"pod": "frontend-5d8f", "namespace": "shop", "tenant": "a",
+	}
+	want := []logs.Result{
+		{Time: "2023-05-01T12:00:00.25Z", Message: "POST shop.example.com/checkout HTTP/1.1 503 UF", Labels: map[string]string{
+			"downstream_address": "10.0.0.7:48000", "upstream_host": "10.0.1.12:8080", "upstream_cluster": "outbound|8080||checkout.shop.svc.cluster.local",
+			"response_flags": "UF", "canary": "true", "duration": "12ms", "protocol": "HTTP/1.1", "method": "POST", "scheme": "https",
+			"authority": "shop.example.com", "path": "/checkout", "request_id": "c1d2", "status": "503", "response_bytes": "91",
+			"response_code_details": "upstream_reset_before_response_started",
+		}},
+		{Time: "2023-05-01T12:00:01Z", Message: "TCP 10.0.0.7:48010 -> postgres received 1024 sent 2048 bytes", Labels: map[string]string{
+			"downstream_address": "10.0.0.7:48010", "upstream_cluster": "postgres", "received_bytes": "1024", "sent_bytes": "2048",
+		}},
+	}
+	for _, r := range want {
+		for k, v := range identity {
+			r.Labels[k] = v
+		}
+	}
+
+	if len(res.Results) != len(want) {
+		t.Fatalf("the store has %d results, want %d: %+v", len(res.Results), len(want), res.Results)
+	}
+	for _, r := range want {
+		found := false
+		for _, got := range res.Results {
+			if got.Time == r.Time {
+				found = true
+				if !reflect.DeepEqual(got.Message, r.Message) || !reflect.DeepEqual(got.Labels, r.Labels) {
+					t.Errorf("result = %+v, want %+v", got, r)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("result %+v wasn't stored", r)
+		}
+	}
+}
+
+func TestIngester_ServeALSTLS(t *testing.T) {
+	ingester, err := NewIngester(nil, logs.IngestConfig{
+		Path:   t.TempDir(),
+		Tokens: []logs.IngestToken{{Name: "istio", Token: kommons.EnvVar{Value: "secret"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ingester.store.Close()
+
+	// The certificate of the test server is valid for 127.0.0.1
+	https := httptest.NewTLSServer(http.NotFoundHandler())
+	defer https.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(https.Certificate())
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := ingester.alsServer(https.TLS)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	send := func(creds credentials.TransportCredentials) error {
+		conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer secret")
+		stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, "/"+alsService+"/StreamAccessLogs", grpc.ForceCodec(rawCodec{}))
+		if err != nil {
+			return err
+		}
+		if err := stream.CloseSend(); err != nil {
+			return err
+		}
+		var res []byte
+		return stream.RecvMsg(&res)
+	}
+
+	if err := send(insecure.NewCredentials()); err == nil {
+		t.Errorf("send() in plaintext succeeded, want the connection rejected")
+	}
+	if err := send(credentials.NewTLS(&tls.Config{RootCAs: roots})); err != nil {
+		t.Errorf("send() over tls error = %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/labstack/echo/v4"
+)
+
+// AuditEvent is the audit.k8s.io/v1 Event of the Kubernetes API server, with the fields kept by the results
+type AuditEvent struct {
+	Level      string `json:"level"`
+	AuditID    string `json:"auditID"`
+	Stage      string `json:"stage"`
+	RequestURI string `json:"requestURI"`
+	Verb       string `json:"verb"`
+	User       struct {
+		Username string   `json:"username"`
+		Groups   []string `json:"groups"`
+	} `json:"user"`
+	ImpersonatedUser *struct {
+		Username string `json:"username"`
+	} `json:"impersonatedUser"`
+	SourceIPs []string `json:"sourceIPs"`
+	UserAgent string   `json:"userAgent"`
+	ObjectRef *struct {
+		Resource    string `json:"resource"`
+		Subresource string `json:"subresource"`
+		Namespace   string `json:"namespace"`
+		Name        string `json:"name"`
+		APIGroup    string `json:"apiGroup"`
+	} `json:"objectRef"`
+	ResponseStatus *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Reason  string `json:"reason"`
+	} `json:"responseStatus"`
+	RequestReceivedTimestamp time.Time         `json:"requestReceivedTimestamp"`
+	StageTimestamp           time.Time         `json:"stageTimestamp"`
+	Annotations              map[string]string `json:"annotations"`
+}
+
+// AuditHandler accepts the audit.k8s.io/v1 EventList sent by the webhook audit backend of the API server,
+// configured with a kubeconfig sending the token, e.g.
+//
+//	users:
+//	  - name: apm-hub
+//	    user:
+//	      token: <token>
+func (t *Ingester) AuditHandler(c echo.Context) error {
+	tok := t.authenticate(c.Request())
+	if tok == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid or missing ingest token")
+	}
+
+	var list struct {
+		Items []AuditEvent `json:"items"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(c.Response(), c.Request().Body, MaxBodySize)).Decode(&list); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid event list: %v", err))
+	}
+	if len(list.Items) == 0 {
+		return c.NoContent(http.StatusOK)
+	}
+
+	results := make([]logs.Result, 0, len(list.Items))
+	for _, event := range list.Items {
+		results = append(results, AuditResult(event, tok.labels))
+	}
+
+	err := t.write(results)
+	if errors.Is(err, ErrWALFull) {
+		c.Response().Header().Set("Retry-After", "5")
+		return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+	}
+	if errors.Is(err, ErrBatchTooLarge) {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	ingestedLines.WithLabelValues(tok.name).Add(float64(len(results)))
+
+	return c.NoContent(http.StatusOK)
+}
+
+// AuditResult returns the result of the audit event, e.g. "alice delete pods default/web-0: 200",
+// with the user, verb and object of the request as labels
+func AuditResult(event AuditEvent, labels map[string]string) logs.Result {
+	r := logs.Result{Id: event.AuditID, Labels: map[string]string{}}
+
+	ts := event.StageTimestamp
+	if ts.IsZero() {
+		ts = event.RequestReceivedTimestamp
+	}
+	r.Time = ts.UTC().Format(time.RFC3339Nano)
+
+	set := func(key, value string) {
+		if value != "" {
+			r.Labels[key] = value
+		}
+	}
+	set("verb", event.Verb)
+	set("user", event.User.Username)
+	set("groups", strings.Join(event.User.Groups, ","))
+	set("stage", event.Stage)
+	set("audit_level", event.Level)
+	set("user_agent", event.UserAgent)
+	set("decision", event.Annotations["authorization.k8s.io/decision"])
+	if len(event.SourceIPs) > 0 {
+		set("source_ip", event.SourceIPs[0])
+	}
+	if event.ImpersonatedUser != nil {
+		set("impersonated_user", event.ImpersonatedUser.Username)
+	}
+
+	object := event.RequestURI
+	if ref := event.ObjectRef; ref != nil {
+		set("resource", ref.Resource)
+		set("subresource", ref.Subresource)
+		set("namespace", ref.Namespace)
+		set("name", ref.Name)
+		set("api_group", ref.APIGroup)
+
+		object = ref.Resource
+		if ref.Subresource != "" {
+			object += "/" + ref.Subresource
+		}
+		switch {
+		case ref.Namespace != "" && ref.Name != "":
+			object += " " + ref.Namespace + "/" + ref.Name
+		case ref.Name != "":
+			object += " " + ref.Name
+		case ref.Namespace != "":
+			object += " -n " + ref.Namespace
+		}
+	}
+
+	r.Message = strings.TrimSpace(event.User.Username + " " + event.Verb + " " + object)
+	if status := event.ResponseStatus; status != nil {
+		set("code", strconv.Itoa(status.Code))
+		r.Message += ": " + strconv.Itoa(status.Code)
+		if status.Message != "" {
+			r.Message += " " + status.Message
+		}
+	}
+
+	for k, v := range labels {
+		r.Labels[k] = v
+	}
+	return r
+}
@@ -0,0 +1,122 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+	"github.com/labstack/echo/v4"
+)
+
+const auditEventList = `{"kind": "EventList", "apiVersion": "audit.k8s.io/v1", "items": [
+	{
+		"level": "Metadata", "auditID": "1f0c6d2e", "stage": "ResponseComplete", "requestURI": "/api/v1/namespaces/default/pods/web-0",
+		"verb": "delete", "user": {"username": "alice", "groups": ["dev", "system:authenticated"]},
+		"sourceIPs": ["10.0.0.12"], "userAgent": "kubectl/v1.27.1",
+		"objectRef": {"resource": "pods", "namespace": "default", "name": "web-0", "apiVersion": "v1"},
+		"responseStatus": {"metadata": {}, "code": 200},
+		"requestReceivedTimestamp": "2023-05-01T12:00:00.100000Z", "stageTimestamp": "2023-05-01T12:00:00.250000Z",
+		"annotations": {"authorization.k8s.io/decision": "allow"}
+	},
+	{
+		"level": "Metadata", "auditID": "7a9b", "stage": "ResponseComplete", "requestURI": "/api/v1/nodes",
+		"verb": "list", "user": {"username": "bob"}, "impersonatedUser": {"username": "system:admin"},
+		"objectRef": {"resource": "nodes", "apiVersion": "v1"},
+		"responseStatus": {"metadata": {}, "status": "Failure", "message": "nodes is forbidden", "reason": "Forbidden", "code": 403},
+		"requestReceivedTimestamp": "2023-05-01T12:00:01Z", "stageTimestamp": "2023-05-01T12:00:01Z"
+	}
+]}`
+
+func TestAuditResult(t *testing.T) {
+	var list struct {
+		Items []AuditEvent `json:"items"`
+	}
+	if err := json.Unmarshal([]byte(auditEventList), &list); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		want logs.Result
+	}{
+		{
+			name: "namespaced object",
+			want: logs.Result{Id: "1f0c6d2e", Time: "2023-05-01T12:00:00.25Z", Message: "alice delete pods default/web-0: 200", Labels: map[string]string{
+				"verb": "delete", "user": "alice", "groups": "dev,system:authenticated", "stage": "ResponseComplete", "audit_level": "Metadata",
+				"user_agent": "kubectl/v1.27.1", "decision": "allow", "source_ip": "10.0.0.12", "resource": "pods", "namespace": "default",
+				"name": "web-0", "code": "200", "cluster": "prod",
+			}},
+		},
+		{
+			name: "forbidden list",
+			want: logs.Result{Id: "7a9b", Time: "2023-05-01T12:00:01Z", Message: "bob list nodes: 403 nodes is forbidden", Labels: map[string]string{
+				"verb": "list", "user": "bob", "impersonated_user": "system:admin", "stage": "ResponseComplete", "audit_level": "Metadata",
+				"resource": "nodes", "code": "403", "cluster": "prod",
+			}},
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AuditResult(list.Items[i], map[string]string{"cluster": "prod"}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AuditResult() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIngester_AuditHandler(t *testing.T) {
+	ingester, err := NewIngester(nil, logs.IngestConfig{
+		Path:   t.TempDir(),
+		Tokens: []logs.IngestToken{{Name: "apiserver", Token: kommons.EnvVar{Value: "secret"}, Labels: map[string]string{"cluster": "prod"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ingester.store.Close()
+
+	tests := []struct {
+		name  string
+		token string
+		body  string
+		want  int
+	}{
+		{name: "no token", body: auditEventList, want: http.StatusUnauthorized},
+		{name: "invalid body", token: "secret", body: `{"items": "a"}`, want: http.StatusBadRequest},
+		{name: "empty list", token: "secret", body: `{"kind": "EventList", "items": []}`, want: http.StatusOK},
+		{name: "events", token: "secret", body: auditEventList, want: http.StatusOK},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/ingest/audit", bytes.NewReader([]byte(tt.body)))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+
+			err := ingester.AuditHandler(e.NewContext(req, rec))
+			status := rec.Code
+			if he, ok := err.(*echo.HTTPError); ok {
+				status = he.Code
+			}
+			if status != tt.want {
+				t.Errorf("AuditHandler() = %d, want %d", status, tt.want)
+			}
+		})
+	}
+
+	res, err := ingester.store.Search(&logs.SearchParams{Labels: map[string]string{"cluster": "prod", "verb": "delete,patch"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 || res.Results[0].Message != "alice delete pods default/web-0: 200" {
+		t.Errorf("store search = %+v, want the deletion of the pod", res)
+	}
+}
@@ -0,0 +1,460 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/logger"
+	"github.com/ugorji/go/codec"
+)
+
+// The forward protocol of Fluentd, see https://github.com/fluent/fluentd/wiki/Forward-Protocol-Specification-v1.
+// The agents send arrays of a tag and its entries in one of the modes:
+//
+//	Message:       [tag, time, record, option]
+//	Forward:       [tag, [[time, record], ...], option]
+//	PackedForward: [tag, msgpack stream of [time, record], option], gzip compressed when the option has compressed: gzip
+var msgpackHandle = newMsgpackHandle()
+
+var (
+	// forwardHandshakeTimeout is how long the agents have to authenticate once connected
+	forwardHandshakeTimeout = 10 * time.Second
+
+	// forwardIdleTimeout is how long the connections are kept open without receiving a message
+	forwardIdleTimeout = 5 * time.Minute
+
+	errMessageTooLarge = fmt.Errorf("the forward message is larger than %d bytes", MaxBodySize)
+)
+
+// eventTime is the EventTime extension (type 0) of the forward protocol, the seconds and nanoseconds as big-endian uint32
+type eventTime time.Time
+
+type eventTimeExt struct{}
+
+func (eventTimeExt) WriteExt(v any) []byte {
+	var ts time.Time
+	switch v := v.(type) {
+	case eventTime:
+		ts = time.Time(v)
+	case *eventTime:
+		ts = time.Time(*v)
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, uint32(ts.Unix()))
+	binary.BigEndian.PutUint32(b[4:], uint32(ts.Nanosecond()))
+	return b
+}
+
+func (eventTimeExt) ReadExt(dst any, src []byte) {
+	if len(src) != 8 {
+		return
+	}
+	*(dst.(*eventTime)) = eventTime(time.Unix(int64(binary.BigEndian.Uint32(src)), int64(binary.BigEndian.Uint32(src[4:]))))
+}
+
+func newMsgpackHandle() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	h.SignedInteger = true
+	h.MapType = reflect.TypeOf(map[string]any(nil))
+	if err := h.SetBytesExt(reflect.TypeOf(eventTime{}), 0, eventTimeExt{}); err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// ServeForward listens for the logs of the agents speaking the forward protocol in the background.
+// The logs and the shared keys are received over TLS when the tls config isn't nil.
+func (t *Ingester) ServeForward(config logs.ForwardConfig, tlsConfig *tls.Config) error {
+	address := config.Address
+	if address == "" {
+		address = ":24224"
+	}
+
+	hostname := config.Hostname
+	if hostname == "" {
+		var err error
+		if hostname, err = os.Hostname(); err != nil {
+			return fmt.Errorf("error getting the hostname: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", address, err)
+	}
+	if tlsConfig != nil {
+		listener = tls.NewListener(listener, tlsConfig)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if errors.Is(err, net.ErrClosed) {
+				return
+			} else if err != nil {
+				logger.Errorf("error accepting a forward connection: %v", err)
+				continue
+			}
+
+			go func() {
+				defer conn.Close()
+				if err := t.handleForward(conn, hostname); err != nil && !errors.Is(err, io.EOF) {
+					logger.Warnf("error reading the forward connection of %s: %v", conn.RemoteAddr(), err)
+				}
+			}()
+		}
+	}()
+	return nil
+}
+
+// handleForward authenticates the agent with the shared key handshake, then writes the entries it sends to the store.
+// The entries are acknowledged once written when the agent asks for it, with the chunk option.
+// The connection is closed when the agent doesn't authenticate in time, stays idle or sends a message larger than MaxBodySize.
+func (t *Ingester) handleForward(conn net.Conn, hostname string) error {
+	reader := bufio.NewReader(conn)
+	enc := codec.NewEncoder(conn, msgpackHandle)
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	if err := enc.Encode([]any{"HELO", map[string]any{"nonce": nonce, "auth": "", "keepalive": true}}); err != nil {
+		return err
+	}
+
+	// The deadlines can only fail to be set on closed connections, whose reads fail anyway
+	var ping []any
+	_ = conn.SetReadDeadline(time.Now().Add(forwardHandshakeTimeout))
+	if err := readForwardMessage(reader, &ping); err != nil {
+		return err
+	}
+	tok, salt, err := t.authenticatePing(ping, nonce)
+	if err != nil {
+		_ = enc.Encode([]any{"PONG", false, err.Error(), hostname, ""})
+		return err
+	}
+	if err := enc.Encode([]any{"PONG", true, "", hostname, sharedKeyDigest(salt, hostname, nonce, tok.value)}); err != nil {
+		return err
+	}
+
+	for {
+		var msg []any
+		_ = conn.SetReadDeadline(time.Now().Add(forwardIdleTimeout))
+		if err := readForwardMessage(reader, &msg); err != nil {
+			return err
+		}
+
+		results, chunk, err := decodeForward(msg, tok.labels)
+		if err != nil {
+			return fmt.Errorf("invalid forward message: %w", err)
+		}
+
+		// The entries that aren't acknowledged are sent again by the agent
+		if err := t.write(results); err != nil {
+			return err
+		}
+		ingestedLines.WithLabelValues(tok.name).Add(float64(len(results)))
+
+		if chunk != "" {
+			if err := enc.Encode(map[string]any{"ack": chunk}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readForwardMessage reads the next msgpack message of the connection and decodes it.
+// The message is read whole before it's decoded, so that the lengths it declares can't exceed MaxBodySize.
+func readForwardMessage(r *bufio.Reader, v any) error {
+	msg, err := readMsgpack(r, MaxBodySize)
+	if err != nil {
+		return err
+	}
+	return codec.NewDecoderBytes(msg, msgpackHandle).Decode(v)
+}
+
+// readMsgpack returns the bytes of the next msgpack value, an error when it's larger than max
+func readMsgpack(r *bufio.Reader, max int) ([]byte, error) {
+	var msg []byte
+	read := func(n int) ([]byte, error) {
+		if len(msg)+n > max {
+			return nil, errMessageTooLarge
+		}
+		start := len(msg)
+		msg = append(msg, make([]byte, n)...)
+		if _, err := io.ReadFull(r, msg[start:]); err != nil {
+			// The connection is only closed cleanly between the messages
+			if errors.Is(err, io.EOF) && start > 0 {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		return msg[start:], nil
+	}
+	readLen := func(size int) (int, error) {
+		b, err := read(size)
+		if err != nil {
+			return 0, err
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		if n > uint64(max) {
+			return 0, errMessageTooLarge
+		}
+		return int(n), nil
+	}
+
+	// The values left to read, the elements of the arrays and maps are added to them
+	for pending := 1; pending > 0; pending-- {
+		b, err := read(1)
+		if err != nil {
+			return nil, err
+		}
+
+		var payload, elements int
+		switch c := b[0]; {
+		case c <= 0x7f, c >= 0xe0, c == 0xc0, c == 0xc2, c == 0xc3:
+		case c <= 0x8f:
+			elements = 2 * int(c&0x0f)
+		case c <= 0x9f:
+			elements = int(c & 0x0f)
+		case c <= 0xbf:
+			payload = int(c & 0x1f)
+		case c == 0xc4, c == 0xd9:
+			payload, err = readLen(1)
+		case c == 0xc5, c == 0xda:
+			payload, err = readLen(2)
+		case c == 0xc6, c == 0xdb:
+			payload, err = readLen(4)
+		case c == 0xc7, c == 0xc8, c == 0xc9:
+			// The length of the ext is followed by its type
+			payload, err = readLen(1 << (c - 0xc7))
+			payload++
+		case c == 0xca:
+			payload = 4
+		case c == 0xcb:
+			payload = 8
+		case c >= 0xcc && c <= 0xcf:
+			payload = 1 << (c - 0xcc)
+		case c >= 0xd0 && c <= 0xd3:
+			payload = 1 << (c - 0xd0)
+		case c >= 0xd4 && c <= 0xd8:
+			payload = 1 + 1<<(c-0xd4)
+		case c == 0xdc:
+			elements, err = readLen(2)
+		case c == 0xdd:
+			elements, err = readLen(4)
+		case c == 0xde:
+			elements, err = readLen(2)
+			elements *= 2
+		case c == 0xdf:
+			elements, err = readLen(4)
+			elements *= 2
+		default:
+			return nil, fmt.Errorf("invalid msgpack type 0x%x", c)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if _, err := read(payload); err != nil {
+			return nil, err
+		}
+		// Every element takes at least a byte
+		if len(msg)+pending+elements > max {
+			return nil, errMessageTooLarge
+		}
+		pending += elements
+	}
+	return msg, nil
+}
+
+// authenticatePing returns the token used as the shared key of the PING message, with the salt of the agent.
+// The PING message is ["PING", hostname, salt, sha512_hex(salt + hostname + nonce + shared_key), username, password].
+func (t *Ingester) authenticatePing(ping []any, nonce []byte) (*token, string, error) {
+	if len(ping) < 4 || ping[0] != "PING" {
+		return nil, "", fmt.Errorf("expected a PING message")
+	}
+	hostname, _ := ping[1].(string)
+	salt, _ := ping[2].(string)
+	digest, _ := ping[3].(string)
+
+	for i, tok := range t.tokens {
+		if subtle.ConstantTimeCompare([]byte(sharedKeyDigest(salt, hostname, nonce, tok.value)), []byte(digest)) == 1 {
+			return &t.tokens[i], salt, nil
+		}
+	}
+	return nil, "", fmt.Errorf("shared key mismatch")
+}
+
+func sharedKeyDigest(salt, hostname string, nonce []byte, key string) string {
+	h := sha512.New()
+	h.Write([]byte(salt))
+	h.Write([]byte(hostname))
+	h.Write(nonce)
+	h.Write([]byte(key))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// decodeForward returns the results of the entries of a message, and the chunk to acknowledge.
+// The tag of the message is the tag label.
+func decodeForward(msg []any, labels map[string]string) ([]logs.Result, string, error) {
+	if len(msg) < 2 {
+		return nil, "", fmt.Errorf("expected a tag and its entries")
+	}
+	tag, ok := msg[0].(string)
+	if !ok {
+		return nil, "", fmt.Errorf("expected a tag, got %T", msg[0])
+	}
+
+	entryLabels := map[string]string{"tag": tag}
+	for k, v := range labels {
+		entryLabels[k] = v
+	}
+
+	var entries [][]any
+	optionIndex := 2
+	switch v := msg[1].(type) {
+	case []any:
+		for _, e := range v {
+			entry, ok := e.([]any)
+			if !ok {
+				return nil, "", fmt.Errorf("expected an entry, got %T", e)
+			}
+			entries = append(entries, entry)
+		}
+	case string:
+		option, _ := optionAt(msg, optionIndex)
+		var err error
+		if entries, err = decodePackedEntries([]byte(v), option["compressed"] == "gzip"); err != nil {
+			return nil, "", err
+		}
+	default:
+		if len(msg) < 3 {
+			return nil, "", fmt.Errorf("expected a time and a record")
+		}
+		entries = [][]any{msg[1:3]}
+		optionIndex = 3
+	}
+
+	results := make([]logs.Result, 0, len(entries))
+	for _, entry := range entries {
+		if len(entry) < 2 {
+			return nil, "", fmt.Errorf("expected a time and a record")
+		}
+		record, ok := entry[1].(map[string]any)
+		if !ok {
+			return nil, "", fmt.Errorf("expected a record, got %T", entry[1])
+		}
+
+		r := ToResult(normalize(record).(map[string]any), entryLabels)
+		if ts, ok := forwardTime(entry[0]); ok {
+			r.Time = ts.UTC().Format(time.RFC3339Nano)
+		}
+		results = append(results, r)
+	}
+
+	option, _ := optionAt(msg, optionIndex)
+	chunk, _ := option["chunk"].(string)
+	return results, chunk, nil
+}
+
+func optionAt(msg []any, i int) (map[string]any, bool) {
+	if i >= len(msg) {
+		return nil, false
+	}
+	option, ok := msg[i].(map[string]any)
+	return option, ok
+}
+
+// decodePackedEntries decodes the msgpack stream of entries of the PackedForward mode
+func decodePackedEntries(data []byte, compressed bool) ([][]any, error) {
+	if compressed {
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip entries: %w", err)
+		}
+		defer gz.Close()
+		if data, err = io.ReadAll(io.LimitReader(gz, MaxBodySize)); err != nil {
+			return nil, fmt.Errorf("invalid gzip entries: %w", err)
+		}
+	}
+
+	var entries [][]any
+	if len(data) == 0 {
+		return entries, nil
+	}
+	dec := codec.NewDecoderBytes(data, msgpackHandle)
+	for {
+		var entry []any
+		if err := dec.Decode(&entry); errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid entry %d: %w", len(entries), err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// forwardTime returns the time of an entry, an EventTime or epoch seconds
+func forwardTime(v any) (time.Time, bool) {
+	switch v := v.(type) {
+	case eventTime:
+		return time.Time(v), true
+	case int64:
+		return time.Unix(v, 0), true
+	case float64:
+		sec, frac := math.Modf(v)
+		return time.Unix(int64(sec), int64(frac*1e9)).Round(time.Microsecond), true
+	}
+	return time.Time{}, false
+}
+
+// normalize converts the msgpack values of a record to the values decoded from json, see ToResult
+func normalize(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = normalize(e)
+		}
+		return v
+	case []any:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+		return v
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float32:
+		return json.Number(strconv.FormatFloat(float64(v), 'f', -1, 32))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'f', -1, 64))
+	case []byte:
+		return string(v)
+	case eventTime:
+		return time.Time(v).UTC().Format(time.RFC3339Nano)
+	}
+	return v
+}
@@ -0,0 +1,205 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+	"github.com/ugorji/go/codec"
+)
+
+// forwardClient is the agent side of a forward connection, after the HELO message
+type forwardClient struct {
+	conn  net.Conn
+	enc   *codec.Encoder
+	dec   *codec.Decoder
+	nonce []byte
+	done  chan error
+}
+
+func dialForward(t *testing.T, ingester *Ingester) *forwardClient {
+	server, conn := net.Pipe()
+	t.Cleanup(func() { conn.Close() })
+
+	c := &forwardClient{conn: conn, enc: codec.NewEncoder(conn, msgpackHandle), dec: codec.NewDecoder(conn, msgpackHandle), done: make(chan error, 1)}
+	go func() {
+		defer server.Close()
+		c.done <- ingester.handleForward(server, "apm-hub")
+	}()
+
+	var helo []any
+	if err := c.dec.Decode(&helo); err != nil {
+		t.Fatal(err)
+	}
+	options, _ := helo[1].(map[string]any)
+	if helo[0] != "HELO" || options["nonce"] == nil {
+		t.Fatalf("unexpected HELO message %v", helo)
+	}
+	c.nonce = []byte(options["nonce"].(string))
+	return c
+}
+
+func (c *forwardClient) send(t *testing.T, msg any) {
+	if err := c.enc.Encode(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (c *forwardClient) receive(t *testing.T, v any) {
+	if err := c.dec.Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIngester_HandleForward(t *testing.T) {
+	ingester, err := NewIngester(nil, logs.IngestConfig{
+		Path:   t.TempDir(),
+		Tokens: []logs.IngestToken{{Name: "fluent-bit", Token: kommons.EnvVar{Value: "secret"}, Labels: map[string]string{"tenant": "a"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ingester.store.Close()
+
+	invalid := dialForward(t, ingester)
+	invalid.send(t, []any{"PING", "node-1", "salt", sharedKeyDigest("salt", "node-1", invalid.nonce, "other"), "", ""})
+	var pong []any
+	invalid.receive(t, &pong)
+	if pong[0] != "PONG" || pong[1] != false {
+		t.Errorf("PONG with an invalid shared key = %v, want an authentication failure", pong)
+	}
+	if err := <-invalid.done; err == nil {
+		t.Error("handleForward() with an invalid shared key succeeded")
+	}
+
+	client := dialForward(t, ingester)
+	client.send(t, []any{"PING", "node-1", "salt", sharedKeyDigest("salt", "node-1", client.nonce, "secret"), "", ""})
+	client.receive(t, &pong)
+	if pong[1] != true || pong[3] != "apm-hub" || pong[4] != sharedKeyDigest("salt", "apm-hub", client.nonce, "secret") {
+		t.Fatalf("PONG = %v, want an authentication success", pong)
+	}
+
+	// Message mode
+	var ack map[string]any
+	client.send(t, []any{
+		"kube.api-0",
+		eventTime(time.Date(2023, 5, 1, 12, 0, 0, 500000000, time.UTC)),
+		map[string]any{"log": "GET /health 200\n", "stream": "stdout", "kubernetes": map[string]any{"pod_name": "api-0", "restarts": 2}},
+		map[string]any{"chunk": "c1"},
+	})
+	client.receive(t, &ack)
+	if ack["ack"] != "c1" {
+		t.Errorf("ack = %v, want c1", ack)
+	}
+
+	// Forward mode, without acknowledgment
+	client.send(t, []any{"kube.api-0", []any{[]any{int64(1682942401), map[string]any{"message": "connection refused", "tenant": "b"}}}})
+
+	// Compressed PackedForward mode
+	var packed bytes.Buffer
+	gz := gzip.NewWriter(&packed)
+	enc := codec.NewEncoder(gz, msgpackHandle)
+	for i, msg := range []string{"first", "second"} {
+		if err := enc.Encode([]any{eventTime(time.Date(2023, 5, 1, 12, 0, 2+i, 0, time.UTC)), map[string]any{"msg": msg}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	client.send(t, []any{"syslog", packed.Bytes(), map[string]any{"compressed": "gzip", "chunk": "c2", "size": 2}})
+	client.receive(t, &ack)
+	if ack["ack"] != "c2" {
+		t.Errorf("ack = %v, want c2", ack)
+	}
+
+	client.conn.Close()
+	if err := <-client.done; !errors.Is(err, io.EOF) {
+		t.Errorf("handleForward() error = %v, want EOF once the agent disconnects", err)
+	}
+
+	res, err := ingester.store.Search(&logs.SearchParams{Labels: map[string]string{"tenant": "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(res.Results, func(i, j int) bool { return res.Results[i].Time < res.Results[j].Time })
+	want := []logs.Result{
+		{Time: "2023-05-01T12:00:00.5Z", Message: "GET /health 200", Labels: map[string]string{"tag": "kube.api-0", "tenant": "a", "stream": "stdout", "kubernetes.pod_name": "api-0", "kubernetes.restarts": "2"}},
+		{Time: "2023-05-01T12:00:01Z", Message: "connection refused", Labels: map[string]string{"tag": "kube.api-0", "tenant": "a"}},
+		{Time: "2023-05-01T12:00:02Z", Message: "first", Labels: map[string]string{"tag": "syslog", "tenant": "a"}},
+		{Time: "2023-05-01T12:00:03Z", Message: "second", Labels: map[string]string{"tag": "syslog", "tenant": "a"}},
+	}
+	if len(res.Results) != len(want) {
+		t.Fatalf("the store has %d results, want %d: %+v", len(res.Results), len(want), res.Results)
+	}
+	for i, r := range want {
+		got := res.Results[i]
+		if got.Time != r.Time || got.Message != r.Message || !reflect.DeepEqual(got.Labels, r.Labels) {
+			t.Errorf("result = %+v, want %+v", got, r)
+		}
+	}
+}
+
+func TestDecodePackedEntries(t *testing.T) {
+	entries, err := decodePackedEntries(nil, false)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("decodePackedEntries() = %v, %v, want no entries", entries, err)
+	}
+	if _, err := decodePackedEntries([]byte("plain"), true); err == nil {
+		t.Error("decodePackedEntries() of invalid gzip entries succeeded")
+	}
+}
+
+func TestReadMsgpack(t *testing.T) {
+	var stream bytes.Buffer
+	var want [][]byte
+	for _, v := range []any{
+		[]any{"kube.api-0", eventTime(time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)), map[string]any{"log": "GET /", "size": int64(-40000), "ratio": 0.5, "ok": true, "trace": nil}},
+		[]any{"syslog", bytes.Repeat([]byte("x"), 300), strings.Repeat("y", 70000), []any{uint64(1 << 40), int64(-1)}},
+	} {
+		var msg bytes.Buffer
+		if err := codec.NewEncoder(&msg, msgpackHandle).Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, msg.Bytes())
+		stream.Write(msg.Bytes())
+	}
+
+	r := bufio.NewReader(&stream)
+	for i := range want {
+		got, err := readMsgpack(r, MaxBodySize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want[i]) {
+			t.Errorf("readMsgpack() = %x, want the message %d %x", got, i, want[i])
+		}
+	}
+	if _, err := readMsgpack(r, MaxBodySize); !errors.Is(err, io.EOF) {
+		t.Errorf("readMsgpack() error = %v, want EOF after the last message", err)
+	}
+
+	for name, tt := range map[string]struct {
+		msg  []byte
+		want error
+	}{
+		// A str32 and an array32 declaring 2GiB
+		"large string": {msg: []byte{0x91, 0xdb, 0x80, 0x00, 0x00, 0x00}, want: errMessageTooLarge},
+		"large array":  {msg: []byte{0xdd, 0x80, 0x00, 0x00, 0x00, 0x01}, want: errMessageTooLarge},
+		"truncated":    {msg: []byte{0x92, 0xa3, 'a', 'b'}, want: io.ErrUnexpectedEOF},
+	} {
+		if _, err := readMsgpack(bufio.NewReader(bytes.NewReader(tt.msg)), MaxBodySize); !errors.Is(err, tt.want) {
+			t.Errorf("readMsgpack() of a %s = %v, want %v", name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+// Package ingest receives the logs pushed by agents such as fluent-bit and Vector
+// and writes them to the local store.
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/store"
+	"github.com/flanksource/kommons"
+	"github.com/labstack/echo/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// MaxBodySize is the maximum size of a batch, after decompression
+const MaxBodySize = 16 * 1024 * 1024
+
+var ingestedLines = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "apm_hub_ingested_lines_total",
+	Help: "Number of log lines pushed to the /ingest endpoint, by token",
+}, []string{"token"})
+
+var (
+	// messageFields are the fields holding the log line, in order of preference
+	messageFields = []string{"message", "log", "msg"}
+
+	// timeFields are the fields holding the time of the log line, in order of preference.
+	// fluent-bit sends "date" as epoch seconds, Vector sends "timestamp" as RFC3339.
+	timeFields = []string{"timestamp", "@timestamp", "time", "date"}
+)
+
+// now is replaced in the tests
+var now = time.Now
+
+type token struct {
+	name   string
+	value  string
+	labels map[string]string
+}
+
+// Ingester writes the batches pushed with a valid token to the store
+type Ingester struct {
+	store     *store.Store
+	tokens    []token
+	retention *store.Retention
+	// wal is nil when the batches are written to the store directly
+	wal *WAL
+}
+
+func NewIngester(kClient *kommons.Client, config logs.IngestConfig) (*Ingester, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("ingest path is required")
+	}
+	if len(config.Tokens) == 0 {
+		return nil, fmt.Errorf("ingest requires at least one token")
+	}
+
+	s, err := store.Open(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the store: %w", err)
+	}
+
+	t := &Ingester{store: s}
+	if config.Retention != nil {
+		if t.retention, err = store.NewRetention(*config.Retention); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.WAL != nil {
+		if t.wal, err = openWAL(config.Path, *config.WAL, s.Append); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, tok := range config.Tokens {
+		_, value, err := kClient.GetEnvValue(tok.Token, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the ingest token %s: %w", tok.Name, err)
+		}
+		if value == "" {
+			return nil, fmt.Errorf("ingest token %s is empty", tok.Name)
+		}
+		t.tokens = append(t.tokens, token{name: tok.Name, value: value, labels: tok.Labels})
+	}
+
+	return t, nil
+}
+
+func openWAL(storePath string, config logs.WALConfig, sink func([]logs.Result) error) (*WAL, error) {
+	dir := config.Path
+	if dir == "" {
+		dir = filepath.Join(storePath, "wal")
+	}
+
+	maxSize := int64(1024 * 1024 * 1024)
+	if config.MaxSize != "" {
+		size, err := resource.ParseQuantity(config.MaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the wal max size: %w", err)
+		}
+		maxSize = size.Value()
+	}
+
+	wal, err := OpenWAL(dir, maxSize, sink)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the wal: %w", err)
+	}
+	return wal, nil
+}
+
+// Start applies the retention of the store and drains the wal in the background
+func (t *Ingester) Start() {
+	if t.retention != nil {
+		t.store.StartRetention(t.retention)
+	}
+	if t.wal != nil {
+		t.wal.Start()
+	}
+}
+
+// authenticate returns the token of the request, sent as a bearer token
+func (t *Ingester) authenticate(req *http.Request) *token {
+	return t.lookup(req.Header.Get(echo.HeaderAuthorization))
+}
+
+// lookup returns the token of the value of the authorization header
+func (t *Ingester) lookup(authorization string) *token {
+	value, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return nil
+	}
+
+	for i, tok := range t.tokens {
+		if subtle.ConstantTimeCompare([]byte(tok.value), []byte(value)) == 1 {
+			return &t.tokens[i]
+		}
+	}
+	return nil
+}
+
+// Handler accepts a batch of json records, either as a json array, newline delimited json or a single object.
+// The body can be gzip compressed.
+func (t *Ingester) Handler(c echo.Context) error {
+	tok := t.authenticate(c.Request())
+	if tok == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid or missing ingest token")
+	}
+
+	body := io.Reader(http.MaxBytesReader(c.Response(), c.Request().Body, MaxBodySize))
+	if strings.EqualFold(c.Request().Header.Get(echo.HeaderContentEncoding), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid gzip body: %v", err))
+		}
+		defer gz.Close()
+		body = io.LimitReader(gz, MaxBodySize)
+	}
+
+	records, err := Decode(body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	results := make([]logs.Result, 0, len(records))
+	for _, record := range records {
+		results = append(results, ToResult(record, tok.labels))
+	}
+
+	err = t.write(results)
+	if errors.Is(err, ErrWALFull) {
+		c.Response().Header().Set("Retry-After", "5")
+		return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+	}
+	if errors.Is(err, ErrBatchTooLarge) {
+		return echo.NewHTTPError(http.StatusRequestEntityTooLarge, err.Error())
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	ingestedLines.WithLabelValues(tok.name).Add(float64(len(results)))
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// write appends the results to the wal, or to the store directly
+func (t *Ingester) write(results []logs.Result) error {
+	if t.wal != nil {
+		return t.wal.Write(results)
+	}
+	return t.store.Append(results)
+}
+
+// Decode reads the records of a json array, newline delimited json or a single json object
+func Decode(body io.Reader) ([]map[string]any, error) {
+	reader := bufio.NewReader(body)
+	first, err := peekNonSpace(reader)
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the body: %w", err)
+	}
+
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	if first == '[' {
+		var records []map[string]any
+		if err := decoder.Decode(&records); err != nil {
+			return nil, fmt.Errorf("invalid json array: %w", err)
+		}
+		return records, nil
+	}
+
+	var records []map[string]any
+	for {
+		var record map[string]any
+		if err := decoder.Decode(&record); err == io.EOF {
+			return records, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("invalid json record %d: %w", len(records), err)
+		}
+		records = append(records, record)
+	}
+}
+
+func peekNonSpace(reader *bufio.Reader) (byte, error) {
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.ContainsRune([]byte(" \t\r\n"), rune(b)) {
+			return b, reader.UnreadByte()
+		}
+	}
+}
+
+// ToResult converts a record to a result.
+// The fields other than the message and the time become labels, nested objects being flattened with dots.
+// The labels override the fields of the record.
+func ToResult(record map[string]any, labels map[string]string) logs.Result {
+	var r logs.Result
+
+	for _, field := range messageFields {
+		if msg, ok := record[field].(string); ok {
+			r.Message = strings.TrimRight(msg, "\r\n")
+			delete(record, field)
+			break
+		}
+	}
+
+	ts := now()
+	for _, field := range timeFields {
+		if parsed, ok := parseTime(record[field]); ok {
+			ts = parsed
+			delete(record, field)
+			break
+		}
+	}
+	r.Time = ts.UTC().Format(time.RFC3339Nano)
+
+	// Records without a known message field are kept whole
+	if r.Message == "" {
+		if data, err := json.Marshal(record); err == nil && len(record) > 0 {
+			r.Message = string(data)
+		}
+	}
+
+	r.Labels = make(map[string]string, len(record)+len(labels))
+	flatten("", record, r.Labels)
+	for k, v := range labels {
+		r.Labels[k] = v
+	}
+	if len(r.Labels) == 0 {
+		r.Labels = nil
+	}
+
+	return r
+}
+
+func flatten(prefix string, record map[string]any, labels map[string]string) {
+	keys := make([]string, 0, len(record))
+	for k := range record {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		key := prefix + k
+		switch v := record[k].(type) {
+		case nil:
+		case map[string]any:
+			flatten(key+".", v, labels)
+		case string:
+			labels[key] = v
+		case json.Number:
+			labels[key] = v.String()
+		case bool:
+			labels[key] = strconv.FormatBool(v)
+		default:
+			if data, err := json.Marshal(v); err == nil {
+				labels[key] = string(data)
+			}
+		}
+	}
+}
+
+// parseTime parses RFC3339 timestamps and epoch seconds, milliseconds or nanoseconds
+func parseTime(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return ts, true
+		}
+		return parseEpoch(json.Number(v))
+	case json.Number:
+		return parseEpoch(v)
+	case float64:
+		return parseEpoch(json.Number(strconv.FormatFloat(v, 'f', -1, 64)))
+	}
+	return time.Time{}, false
+}
+
+func parseEpoch(v json.Number) (time.Time, bool) {
+	f, err := v.Float64()
+	if err != nil || f <= 0 {
+		return time.Time{}, false
+	}
+
+	switch {
+	case f > 1e17:
+		return time.Unix(0, int64(f)), true
+	case f > 1e11:
+		return time.UnixMilli(int64(f)), true
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).Round(time.Microsecond), true
+}
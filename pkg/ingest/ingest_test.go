@@ -0,0 +1,152 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+	"github.com/labstack/echo/v4"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    int
+		wantErr bool
+	}{
+		{name: "empty", body: "  ", want: 0},
+		{name: "array", body: `[{"log":"a"},{"log":"b"}]`, want: 2},
+		{name: "ndjson", body: "{\"log\":\"a\"}\n{\"log\":\"b\"}\n{\"log\":\"c\"}\n", want: 3},
+		{name: "object", body: `{"message":"a"}`, want: 1},
+		{name: "invalid", body: `{"message":`, wantErr: true},
+		{name: "not objects", body: `[1, 2]`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := Decode(strings.NewReader(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(records) != tt.want {
+				t.Errorf("Decode() = %d records, want %d", len(records), tt.want)
+			}
+		})
+	}
+}
+
+func TestToResult(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		body   string
+		labels map[string]string
+		want   logs.Result
+	}{
+		{
+			name: "fluent-bit",
+			body: `{"date":1682942410.25,"log":"GET /healthz\n","stream":"stdout","kubernetes":{"pod_name":"api-0","labels":{"app":"api"}}}`,
+			want: logs.Result{Time: "2023-05-01T12:00:10.25Z", Message: "GET /healthz", Labels: map[string]string{"stream": "stdout", "kubernetes.pod_name": "api-0", "kubernetes.labels.app": "api"}},
+		},
+		{
+			name:   "vector",
+			body:   `{"timestamp":"2023-05-01T14:00:10+02:00","message":"connection refused","host":"node-1","tenant":"b"}`,
+			labels: map[string]string{"tenant": "a"},
+			want:   logs.Result{Time: "2023-05-01T12:00:10Z", Message: "connection refused", Labels: map[string]string{"host": "node-1", "tenant": "a"}},
+		},
+		{
+			name: "epoch millis",
+			body: `{"time":1682942410250,"msg":"ok","status":200,"ok":true}`,
+			want: logs.Result{Time: "2023-05-01T12:00:10.25Z", Message: "ok", Labels: map[string]string{"status": "200", "ok": "true"}},
+		},
+		{
+			name: "no message",
+			body: `{"level":"info","tags":["a"]}`,
+			want: logs.Result{Time: "2023-05-01T12:00:00Z", Message: `{"level":"info","tags":["a"]}`, Labels: map[string]string{"level": "info", "tags": `["a"]`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := Decode(strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ToResult(records[0], tt.labels); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ToResult() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIngester_Handler(t *testing.T) {
+	dir := t.TempDir()
+	ingester, err := NewIngester(nil, logs.IngestConfig{
+		Path:   dir,
+		Tokens: []logs.IngestToken{{Name: "fluent-bit", Token: kommons.EnvVar{Value: "secret"}, Labels: map[string]string{"tenant": "a"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ingester.store.Close()
+
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	_, _ = gz.Write([]byte(`[{"log":"compressed"}]`))
+	_ = gz.Close()
+
+	tests := []struct {
+		name     string
+		token    string
+		encoding string
+		body     []byte
+		want     int
+	}{
+		{name: "no token", body: []byte(`{"log":"a"}`), want: http.StatusUnauthorized},
+		{name: "invalid token", token: "other", body: []byte(`{"log":"a"}`), want: http.StatusUnauthorized},
+		{name: "invalid body", token: "secret", body: []byte(`{"log":`), want: http.StatusBadRequest},
+		{name: "ndjson", token: "secret", body: []byte("{\"log\":\"a\"}\n{\"log\":\"b\"}\n"), want: http.StatusNoContent},
+		{name: "gzip", token: "secret", encoding: "gzip", body: gzipped.Bytes(), want: http.StatusNoContent},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			if tt.encoding != "" {
+				req.Header.Set(echo.HeaderContentEncoding, tt.encoding)
+			}
+			rec := httptest.NewRecorder()
+
+			err := ingester.Handler(e.NewContext(req, rec))
+			status := rec.Code
+			if he, ok := err.(*echo.HTTPError); ok {
+				status = he.Code
+			}
+			if status != tt.want {
+				t.Errorf("Handler() = %d, want %d", status, tt.want)
+			}
+		})
+	}
+
+	res, err := ingester.store.Search(&logs.SearchParams{Labels: map[string]string{"tenant": "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 3 {
+		t.Errorf("expected 3 ingested lines, got %d", res.Total)
+	}
+}
@@ -0,0 +1,215 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// journalContentType is the content type of the journal export format sent by systemd-journal-upload
+	journalContentType = "application/vnd.fdo.journal"
+
+	// journalBatchSize is the number of entries written at once, the uploads streaming the journal for as long as they follow it
+	journalBatchSize = 1000
+)
+
+// JournalLabels are the journal fields kept as labels, the other trusted fields (prefixed with _) are dropped
+var JournalLabels = map[string]string{
+	"_SYSTEMD_UNIT":      "unit",
+	"_SYSTEMD_USER_UNIT": "user_unit",
+	"_HOSTNAME":          "hostname",
+	"_PID":               "pid",
+	"_COMM":              "comm",
+	"_TRANSPORT":         "transport",
+	"_BOOT_ID":           "boot_id",
+	"_MACHINE_ID":        "machine_id",
+	"SYSLOG_IDENTIFIER":  "identifier",
+	"PRIORITY":           "priority",
+}
+
+// journalLevels are the levels of the syslog priorities
+var journalLevels = []string{"emerg", "alert", "crit", "error", "warning", "notice", "info", "debug"}
+
+// UploadHandler accepts the entries of the journal export format, as uploaded by systemd-journal-upload, e.g.
+//
+//	systemd-journal-upload --url=https://journal:<token>@apm-hub:8080
+//
+// systemd-journal-upload can't send headers, so the token is also accepted as the password of the basic auth.
+func (t *Ingester) UploadHandler(c echo.Context) error {
+	req := c.Request()
+	tok := t.authenticate(req)
+	if _, password, ok := req.BasicAuth(); tok == nil && ok {
+		tok = t.lookup("Bearer " + password)
+	}
+	if tok == nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "invalid or missing ingest token")
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType)); mediaType != journalContentType {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, "content type must be "+journalContentType)
+	}
+	if encoding := req.Header.Get(echo.HeaderContentEncoding); encoding != "" && encoding != "identity" {
+		return echo.NewHTTPError(http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content encoding %s", encoding))
+	}
+
+	var results []logs.Result
+	flush := func() error {
+		if len(results) == 0 {
+			return nil
+		}
+		err := t.write(results)
+		if errors.Is(err, ErrWALFull) {
+			c.Response().Header().Set("Retry-After", "5")
+			return echo.NewHTTPError(http.StatusTooManyRequests, err.Error())
+		}
+		if errors.Is(err, ErrBatchTooLarge) {
+			return echo.NewHTTPError(http.StatusRequestEntityTooLarge, err.Error())
+		}
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		ingestedLines.WithLabelValues(tok.name).Add(float64(len(results)))
+		results = results[:0]
+		return nil
+	}
+
+	err := DecodeJournal(req.Body, func(entry map[string]string) error {
+		results = append(results, JournalResult(entry, tok.labels))
+		if len(results) < journalBatchSize {
+			return nil
+		}
+		return flush()
+	})
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr
+	}
+	if err != nil {
+		// The entries decoded before the error are kept, systemd-journal-upload resumes from the last cursor it sent
+		if err := flush(); err != nil {
+			return err
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	return c.String(http.StatusAccepted, "OK.\n")
+}
+
+// DecodeJournal calls fn with the fields of each entry of the journal export format.
+// The entries are separated by an empty line, and the fields are either KEY=value lines,
+// or the binary KEY line followed by the little endian 64 bit size of the value, the value and a newline.
+func DecodeJournal(body io.Reader, fn func(map[string]string) error) error {
+	reader := bufio.NewReader(body)
+	entry := map[string]string{}
+	for {
+		line, err := readJournalLine(reader)
+		if err == io.EOF {
+			if len(entry) > 0 {
+				return fn(entry)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(line) == 0 {
+			if len(entry) > 0 {
+				if err := fn(entry); err != nil {
+					return err
+				}
+				entry = map[string]string{}
+			}
+			continue
+		}
+
+		if key, value, ok := bytes.Cut(line, []byte("=")); ok {
+			entry[string(key)] = string(value)
+			continue
+		}
+
+		var size uint64
+		if err := binary.Read(reader, binary.LittleEndian, &size); err != nil {
+			return fmt.Errorf("error reading the size of the field %s: %w", line, err)
+		}
+		if size > MaxBodySize {
+			return fmt.Errorf("the field %s is %d bytes, larger than %d", line, size, MaxBodySize)
+		}
+		value := make([]byte, size+1)
+		if _, err := io.ReadFull(reader, value); err != nil {
+			return fmt.Errorf("error reading the field %s: %w", line, err)
+		}
+		if value[size] != '\n' {
+			return fmt.Errorf("the field %s isn't followed by a newline", line)
+		}
+		entry[string(line)] = string(value[:size])
+	}
+}
+
+// readJournalLine reads a line without its newline, up to MaxBodySize
+func readJournalLine(reader *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if len(line) > MaxBodySize {
+			return nil, fmt.Errorf("line larger than %d bytes", MaxBodySize)
+		}
+		switch {
+		case err == nil:
+			return line[:len(line)-1], nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case err == io.EOF && len(line) > 0:
+			return nil, io.ErrUnexpectedEOF
+		default:
+			return nil, err
+		}
+	}
+}
+
+// JournalResult converts a journal entry to a result, with the realtime timestamp as time.
+// The unit, hostname and priority, with its level, are kept as labels with the fields of the entry,
+// the other trusted fields and the address fields (prefixed with __) are dropped.
+func JournalResult(entry map[string]string, labels map[string]string) logs.Result {
+	r := logs.Result{Message: strings.TrimRight(entry["MESSAGE"], "\r\n"), Labels: make(map[string]string, len(entry)+len(labels))}
+
+	ts := now()
+	if usec, err := strconv.ParseInt(entry["__REALTIME_TIMESTAMP"], 10, 64); err == nil {
+		ts = time.UnixMicro(usec)
+	}
+	r.Time = ts.UTC().Format(time.RFC3339Nano)
+
+	for key, value := range entry {
+		if label, ok := JournalLabels[key]; ok {
+			r.Labels[label] = value
+			continue
+		}
+		if key == "MESSAGE" || strings.HasPrefix(key, "_") {
+			continue
+		}
+		r.Labels[strings.ToLower(key)] = value
+	}
+	if priority, err := strconv.Atoi(entry["PRIORITY"]); err == nil && priority >= 0 && priority < len(journalLevels) {
+		r.Labels["level"] = journalLevels[priority]
+	}
+
+	for k, v := range labels {
+		r.Labels[k] = v
+	}
+	return r
+}
@@ -0,0 +1,139 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+	"github.com/labstack/echo/v4"
+)
+
+// binaryField encodes a field of the journal export format with its size, as done for the values with newlines
+func binaryField(key, value string) string {
+	size := make([]byte, 8)
+	binary.LittleEndian.PutUint64(size, uint64(len(value)))
+	return key + "\n" + string(size) + value + "\n"
+}
+
+var journalExport = "__CURSOR=s=1;i=1\n__REALTIME_TIMESTAMP=1682942400250000\n_HOSTNAME=vm-1\n_SYSTEMD_UNIT=nginx.service\n_PID=812\n_UID=0\nPRIORITY=3\nSYSLOG_IDENTIFIER=nginx\nMESSAGE=connect() failed\n\n" +
+	"__REALTIME_TIMESTAMP=1682942401000000\n_HOSTNAME=vm-1\n_SYSTEMD_UNIT=app.service\nPRIORITY=6\nCODE_FILE=main.go\n" + binaryField("MESSAGE", "panic: oops\ngoroutine 1") + "\n"
+
+func TestDecodeJournal(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []map[string]string
+		wantErr bool
+	}{
+		{name: "empty", body: ""},
+		{
+			name: "entries",
+			body: journalExport,
+			want: []map[string]string{
+				{"__CURSOR": "s=1;i=1", "__REALTIME_TIMESTAMP": "1682942400250000", "_HOSTNAME": "vm-1", "_SYSTEMD_UNIT": "nginx.service", "_PID": "812", "_UID": "0", "PRIORITY": "3", "SYSLOG_IDENTIFIER": "nginx", "MESSAGE": "connect() failed"},
+				{"__REALTIME_TIMESTAMP": "1682942401000000", "_HOSTNAME": "vm-1", "_SYSTEMD_UNIT": "app.service", "PRIORITY": "6", "CODE_FILE": "main.go", "MESSAGE": "panic: oops\ngoroutine 1"},
+			},
+		},
+		{name: "last entry without an empty line", body: "MESSAGE=a\n", want: []map[string]string{{"MESSAGE": "a"}}},
+		{name: "truncated line", body: "MESSAGE=a\n\nMESSAGE=b", want: []map[string]string{{"MESSAGE": "a"}}, wantErr: true},
+		{name: "truncated binary field", body: "MESSAGE\n\x10\x00\x00\x00\x00\x00\x00\x00abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []map[string]string
+			err := DecodeJournal(strings.NewReader(tt.body), func(entry map[string]string) error {
+				got = append(got, entry)
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeJournal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeJournal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIngester_UploadHandler(t *testing.T) {
+	ingester, err := NewIngester(nil, logs.IngestConfig{
+		Path:   t.TempDir(),
+		Tokens: []logs.IngestToken{{Name: "vms", Token: kommons.EnvVar{Value: "secret"}, Labels: map[string]string{"tenant": "a"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ingester.store.Close()
+
+	tests := []struct {
+		name        string
+		password    string
+		token       string
+		contentType string
+		want        int
+	}{
+		{name: "no token", contentType: journalContentType, want: http.StatusUnauthorized},
+		{name: "invalid password", password: "other", contentType: journalContentType, want: http.StatusUnauthorized},
+		{name: "json", token: "secret", contentType: echo.MIMEApplicationJSON, want: http.StatusUnsupportedMediaType},
+		{name: "bearer token", token: "secret", contentType: journalContentType, want: http.StatusAccepted},
+		{name: "basic auth", password: "secret", contentType: journalContentType, want: http.StatusAccepted},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/upload", bytes.NewReader([]byte(journalExport)))
+			req.Header.Set(echo.HeaderContentType, tt.contentType)
+			if tt.token != "" {
+				req.Header.Set(echo.HeaderAuthorization, "Bearer "+tt.token)
+			}
+			if tt.password != "" {
+				req.SetBasicAuth("journal", tt.password)
+			}
+			rec := httptest.NewRecorder()
+
+			err := ingester.UploadHandler(e.NewContext(req, rec))
+			status := rec.Code
+			if he, ok := err.(*echo.HTTPError); ok {
+				status = he.Code
+			}
+			if status != tt.want {
+				t.Errorf("UploadHandler() = %d, want %d", status, tt.want)
+			}
+		})
+	}
+
+	res, err := ingester.store.Search(&logs.SearchParams{Labels: map[string]string{"tenant": "a", "unit": "nginx.service"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.Result{Time: "2023-05-01T12:00:00.25Z", Message: "connect() failed", Labels: map[string]string{
+		"hostname": "vm-1", "unit": "nginx.service", "pid": "812", "priority": "3", "level": "error", "identifier": "nginx", "tenant": "a",
+	}}
+	if res.Total != 2 {
+		t.Fatalf("expected the 2 uploads of the entry, got %d", res.Total)
+	}
+	if got := res.Results[0]; got.Time != want.Time || got.Message != want.Message || !reflect.DeepEqual(got.Labels, want.Labels) {
+		t.Errorf("result = %+v, want %+v", got, want)
+	}
+}
+
+func TestJournalResult(t *testing.T) {
+	got := JournalResult(map[string]string{
+		"__REALTIME_TIMESTAMP": "1682942401000000", "_HOSTNAME": "vm-1", "_SYSTEMD_UNIT": "app.service", "_UID": "0",
+		"PRIORITY": "6", "CODE_FILE": "main.go", "MESSAGE": "panic: oops\n",
+	}, map[string]string{"hostname": "override"})
+	want := logs.Result{Time: "2023-05-01T12:00:01Z", Message: "panic: oops", Labels: map[string]string{
+		"hostname": "override", "unit": "app.service", "priority": "6", "level": "info", "code_file": "main.go",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JournalResult() = %+v, want %+v", got, want)
+	}
+}
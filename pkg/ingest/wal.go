@@ -0,0 +1,315 @@
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const walExt = ".wal"
+
+var (
+	walSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "apm_hub_ingest_wal_bytes",
+		Help: "Size of the batches waiting in the write-ahead log",
+	})
+
+	rejectedBatches = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "apm_hub_ingest_rejected_batches_total",
+		Help: "Number of batches rejected because the write-ahead log was full or too small for them",
+	})
+)
+
+var (
+	// ErrWALFull is returned when the batch doesn't fit in the log
+	ErrWALFull = errors.New("the ingest buffer is full")
+
+	// ErrBatchTooLarge is returned when the batch is larger than the whole log, it would never fit
+	ErrBatchTooLarge = errors.New("the batch is larger than the ingest buffer")
+
+	// walFileSize is the size after which the writes go to a new file
+	walFileSize int64 = 16 * 1024 * 1024
+
+	// maxBackoff is the longest delay between the retries of a failed drain
+	maxBackoff = time.Minute
+)
+
+// WAL is a write-ahead log of the ingested batches.
+// The batches are appended to numbered files, that are written to the sink
+// and deleted from the oldest once they are no longer written to.
+//
+// The delivery is at-least-once: a crash between writing a file to the sink
+// and deleting it writes its batches again on restart.
+type WAL struct {
+	dir     string
+	maxSize int64
+	sink    func([]logs.Result) error
+	wake    chan struct{}
+
+	lock sync.Mutex
+	// size is the size of all the files, waiting to be drained
+	size       int64
+	active     *os.File
+	activeSeq  uint64
+	activeSize int64
+}
+
+// OpenWAL opens the log in the directory, with the files left by a previous run waiting to be drained
+func OpenWAL(dir string, maxSize int64, sink func([]logs.Result) error) (*WAL, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating the wal directory: %w", err)
+	}
+
+	t := &WAL{dir: dir, maxSize: maxSize, sink: sink, wake: make(chan struct{}, 1)}
+	files, err := t.files()
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		t.size += f.size
+		t.activeSeq = f.seq
+	}
+	walSize.Set(float64(t.size))
+	return t, nil
+}
+
+// Write appends the results to the log and syncs it to disk
+func (t *WAL) Write(results []logs.Result) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, r := range results {
+		if err := encoder.Encode(r); err != nil {
+			return fmt.Errorf("error encoding the result: %w", err)
+		}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	size := int64(buf.Len())
+	if size > t.maxSize {
+		rejectedBatches.Inc()
+		return ErrBatchTooLarge
+	}
+	if t.size+size > t.maxSize {
+		rejectedBatches.Inc()
+		return ErrWALFull
+	}
+
+	if t.active == nil || t.activeSize+size > walFileSize {
+		if err := t.roll(); err != nil {
+			return err
+		}
+	}
+
+	n, err := t.active.Write(buf.Bytes())
+	if err != nil {
+		// The batch isn't acknowledged, so its partial line is cut off, or left at the end of a file that is no longer written to
+		if truncErr := t.active.Truncate(t.activeSize); truncErr != nil {
+			t.activeSize += int64(n)
+			t.size += int64(n)
+			walSize.Set(float64(t.size))
+			t.active.Close()
+			t.active = nil
+		}
+		return fmt.Errorf("error writing to the wal: %w", err)
+	}
+	t.activeSize += int64(n)
+	t.size += int64(n)
+	walSize.Set(float64(t.size))
+	if err := t.active.Sync(); err != nil {
+		return fmt.Errorf("error syncing the wal: %w", err)
+	}
+
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// roll closes the active file and starts the next one
+func (t *WAL) roll() error {
+	if t.active != nil {
+		if err := t.active.Close(); err != nil {
+			return fmt.Errorf("error closing the wal file: %w", err)
+		}
+		t.active = nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(t.dir, walName(t.activeSeq+1)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("error creating the wal file: %w", err)
+	}
+	t.activeSeq++
+	t.active = f
+	t.activeSize = 0
+	return nil
+}
+
+// Start drains the log in the background, whenever a batch is written
+// and at least every second. Failed drains are retried with an exponential backoff.
+func (t *WAL) Start() {
+	go func() {
+		backoff := time.Second
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-t.wake:
+			case <-ticker.C:
+			}
+
+			if err := t.Drain(); err != nil {
+				logger.Errorf("error draining the ingest wal, retrying in %s: %v", backoff, err)
+				time.Sleep(backoff)
+				if backoff *= 2; backoff > maxBackoff {
+					backoff = maxBackoff
+				}
+				continue
+			}
+			backoff = time.Second
+		}
+	}()
+}
+
+// Drain writes the files to the sink, from the oldest, and deletes them.
+// The active file is closed first, so that its batches don't wait for it to be full.
+func (t *WAL) Drain() error {
+	t.lock.Lock()
+	if t.activeSize > 0 {
+		if err := t.roll(); err != nil {
+			t.lock.Unlock()
+			return err
+		}
+	}
+	// The files created from now on are written to
+	sealed := t.activeSeq
+	if t.active == nil {
+		sealed++
+	}
+	t.lock.Unlock()
+
+	files, err := t.files()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if f.seq >= sealed {
+			break
+		}
+
+		results, err := readWALFile(f.path)
+		if err != nil {
+			return err
+		}
+		if len(results) > 0 {
+			if err := t.sink(results); err != nil {
+				return err
+			}
+		}
+		if err := os.Remove(f.path); err != nil {
+			return fmt.Errorf("error deleting the wal file: %w", err)
+		}
+
+		t.lock.Lock()
+		t.size -= f.size
+		walSize.Set(float64(t.size))
+		t.lock.Unlock()
+	}
+	return nil
+}
+
+// Close closes the active file
+func (t *WAL) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.active == nil {
+		return nil
+	}
+	err := t.active.Close()
+	t.active = nil
+	return err
+}
+
+type walFile struct {
+	path string
+	seq  uint64
+	size int64
+}
+
+// files returns the files of the log from the oldest
+func (t *WAL) files() ([]walFile, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error listing the wal files: %w", err)
+	}
+
+	var files []walFile
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), walExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), walExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("error getting the size of the wal file: %w", err)
+		}
+		files = append(files, walFile{path: filepath.Join(t.dir, entry.Name()), seq: seq, size: info.Size()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].seq < files[j].seq })
+	return files, nil
+}
+
+// readWALFile returns the results of the complete lines of the file.
+// A partial last line was cut by a crash and never acknowledged.
+func readWALFile(path string) ([]logs.Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the wal file: %w", err)
+	}
+	defer f.Close()
+
+	var results []logs.Result
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			var r logs.Result
+			if json.Unmarshal(line, &r) == nil {
+				results = append(results, r)
+			}
+		}
+		if err == io.EOF {
+			return results, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading the wal file: %w", err)
+		}
+	}
+}
+
+func walName(seq uint64) string {
+	return fmt.Sprintf("%020d%s", seq, walExt)
+}
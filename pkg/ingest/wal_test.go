@@ -0,0 +1,172 @@
+package ingest
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+	"github.com/labstack/echo/v4"
+)
+
+func TestWAL(t *testing.T) {
+	dir := t.TempDir()
+
+	var drained []logs.Result
+	var sinkErr error
+	sink := func(results []logs.Result) error {
+		if sinkErr != nil {
+			return sinkErr
+		}
+		drained = append(drained, results...)
+		return nil
+	}
+
+	wal, err := OpenWAL(dir, 200, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wal.Write([]logs.Result{{Message: "a"}, {Message: "b"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The store is stalled, the batches stay in the log until it's full
+	sinkErr = errors.New("disk full")
+	if err := wal.Drain(); err == nil {
+		t.Fatal("expected the drain to fail")
+	}
+	for err == nil {
+		err = wal.Write([]logs.Result{{Message: "c"}})
+	}
+	if !errors.Is(err, ErrWALFull) {
+		t.Fatalf("expected the wal to be full, got %v", err)
+	}
+
+	sinkErr = nil
+	if err := wal.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if len(drained) < 3 || drained[0].Message != "a" || drained[len(drained)-1].Message != "c" {
+		t.Errorf("unexpected drained results %v", drained)
+	}
+	if err := wal.Write([]logs.Result{{Message: "d"}}); err != nil {
+		t.Errorf("expected the drained wal to accept writes, got %v", err)
+	}
+	if err := wal.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash cut the last line, the batches left are drained on restart
+	files, _ := filepath.Glob(filepath.Join(dir, "*"+walExt))
+	f, err := os.OpenFile(files[len(files)-1], os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"message":"cut`)
+	f.Close()
+
+	drained = nil
+	reopened, err := OpenWAL(dir, 200, sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := reopened.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if len(drained) != 1 || drained[0].Message != "d" {
+		t.Errorf("expected the batch left by the previous run, got %v", drained)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+walExt)); len(files) != 0 {
+		t.Errorf("expected the drained files to be deleted, got %v", files)
+	}
+}
+
+func TestIngester_Backpressure(t *testing.T) {
+	dir := t.TempDir()
+	ingester, err := NewIngester(nil, logs.IngestConfig{
+		Path:   dir,
+		Tokens: []logs.IngestToken{{Name: "vector", Token: kommons.EnvVar{Value: "secret"}}},
+		WAL:    &logs.WALConfig{MaxSize: "100"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ingester.store.Close()
+	defer ingester.wal.Close()
+
+	post := func(body string) (int, http.Header) {
+		req := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body))
+		req.Header.Set(echo.HeaderAuthorization, "Bearer secret")
+		rec := httptest.NewRecorder()
+		err := ingester.Handler(echo.New().NewContext(req, rec))
+		if he, ok := err.(*echo.HTTPError); ok {
+			return he.Code, rec.Header()
+		}
+		return rec.Code, rec.Header()
+	}
+
+	if status, _ := post(`{"message":"a","timestamp":"2023-05-01T12:00:00Z"}`); status != http.StatusNoContent {
+		t.Fatalf("expected the batch to be accepted, got %d", status)
+	}
+	status, header := post(`{"message":"b","timestamp":"2023-05-01T12:00:00Z"}`)
+	if status != http.StatusTooManyRequests || header.Get("Retry-After") == "" {
+		t.Errorf("expected the batch to be rejected with a 429, got %d", status)
+	}
+
+	if err := ingester.wal.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	res, err := ingester.store.Search(&logs.SearchParams{Start: "2023-05-01T00:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 {
+		t.Errorf("expected the accepted batch in the store, got %v", res.Results)
+	}
+	if status, _ := post(`{"message":"b"}`); status != http.StatusNoContent {
+		t.Errorf("expected the batch to be accepted once drained, got %d", status)
+	}
+	if status, _ := post(`{"message":"` + strings.Repeat("x", 100) + `"}`); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected the batch larger than the wal to be rejected with a 413, got %d", status)
+	}
+}
+
+func TestWAL_FailedWrite(t *testing.T) {
+	var drained []logs.Result
+	wal, err := OpenWAL(t.TempDir(), 1000, func(results []logs.Result) error {
+		drained = append(drained, results...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wal.Write([]logs.Result{{Message: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	size := wal.size
+
+	// The failed batch isn't counted, and the next batches go to a new file
+	wal.active.Close()
+	if err := wal.Write([]logs.Result{{Message: "b"}}); err == nil {
+		t.Fatal("expected the write to fail")
+	}
+	if wal.size != size {
+		t.Errorf("expected the failed batch not to be counted, got %d bytes instead of %d", wal.size, size)
+	}
+	if err := wal.Write([]logs.Result{{Message: "c"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := wal.Drain(); err != nil {
+		t.Fatal(err)
+	}
+	if len(drained) != 2 || drained[0].Message != "a" || drained[1].Message != "c" {
+		t.Errorf("unexpected drained results %v", drained)
+	}
+}
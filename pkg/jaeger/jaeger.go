@@ -0,0 +1,193 @@
+// Package jaeger searches the traces of the Jaeger query service
+package jaeger
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/apm-hub/pkg/traces"
+	"github.com/flanksource/kommons"
+)
+
+type JaegerSearch struct {
+	config *logs.JaegerBackendConfig
+	client *rest.Client
+}
+
+func NewJaegerSearchBackend(kClient *kommons.Client, config *logs.JaegerBackendConfig) (*JaegerSearch, error) {
+	client, err := rest.NewClient(kClient, config.HTTPConnection)
+	if err != nil {
+		return nil, err
+	}
+	return &JaegerSearch{config: config, client: client}, nil
+}
+
+// Search returns the spans of the trace of the search, see traces.TraceID,
+// otherwise the spans of the traces of the service in the time range
+func (t *JaegerSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var res response
+
+	traceID := traces.TraceID(q)
+
+	if traceID != "" {
+		if err := t.client.Get(ctx, "/api/traces/"+url.PathEscape(traceID), nil, &res); err != nil {
+			return logs.SearchResults{}, err
+		}
+	} else {
+		query, err := t.query(q)
+		if err != nil {
+			return logs.SearchResults{}, err
+		}
+		if err := t.client.Get(ctx, "/api/traces", query, &res); err != nil {
+			return logs.SearchResults{}, err
+		}
+	}
+
+	if len(res.Errors) > 0 && len(res.Data) == 0 {
+		return logs.SearchResults{}, fmt.Errorf("error searching the traces: %s", res.Errors[0].Msg)
+	}
+
+	var spans []traces.Span
+	for _, trace := range res.Data {
+		for _, s := range trace.Spans {
+			spans = append(spans, s.span(trace.Processes[s.ProcessID]))
+		}
+	}
+	return traces.Results(spans, q.Limit, t.config.Labels), nil
+}
+
+// query returns the params of the search of the traces of the service
+func (t *JaegerSearch) query(q *logs.SearchParams) (url.Values, error) {
+	service := q.Labels["service"]
+	if service == "" {
+		service = q.Id
+	}
+	if service == "" {
+		service = t.config.Service
+	}
+	if service == "" {
+		return nil, fmt.Errorf("the search requires a trace id or a service")
+	}
+
+	query := url.Values{}
+	query.Set("service", service)
+	if operation := q.Labels["operation"]; operation != "" {
+		query.Set("operation", operation)
+	}
+
+	tags := map[string]string{}
+	for _, tag := range t.config.Tags {
+		if v, ok := q.Labels[tag]; ok {
+			tags[tag] = v
+		}
+	}
+	if len(tags) > 0 {
+		data, err := json.Marshal(tags)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("tags", string(data))
+	}
+
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+	if start := q.GetStart(); start != nil {
+		query.Set("start", strconv.FormatInt(start.UnixMicro(), 10))
+	}
+	query.Set("end", strconv.FormatInt(end.UnixMicro(), 10))
+	if q.Limit > 0 {
+		query.Set("limit", strconv.FormatInt(q.Limit, 10))
+	}
+	return query, nil
+}
+
+func (t *JaegerSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Jaeger backend, the spans are filtered by the query once returned
+func (t *JaegerSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
+
+// HealthCheck lists the services
+func (t *JaegerSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/services", nil, nil)
+}
+
+type response struct {
+	Data   []trace `json:"data"`
+	Errors []struct {
+		Msg string `json:"msg"`
+	} `json:"errors"`
+}
+
+type trace struct {
+	TraceID   string             `json:"traceID"`
+	Spans     []span             `json:"spans"`
+	Processes map[string]process `json:"processes"`
+}
+
+type process struct {
+	ServiceName string `json:"serviceName"`
+}
+
+type span struct {
+	TraceID       string `json:"traceID"`
+	SpanID        string `json:"spanID"`
+	OperationName string `json:"operationName"`
+	References    []struct {
+		RefType string `json:"refType"`
+		SpanID  string `json:"spanID"`
+	} `json:"references"`
+	// StartTime and Duration are in microseconds
+	StartTime int64      `json:"startTime"`
+	Duration  int64      `json:"duration"`
+	Tags      []keyValue `json:"tags"`
+	ProcessID string     `json:"processID"`
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+func (s span) span(p process) traces.Span {
+	result := traces.Span{
+		TraceID:   s.TraceID,
+		SpanID:    s.SpanID,
+		Service:   p.ServiceName,
+		Operation: s.OperationName,
+		Start:     time.UnixMicro(s.StartTime),
+		Duration:  time.Duration(s.Duration) * time.Microsecond,
+		Tags:      make(map[string]string, len(s.Tags)),
+	}
+
+	for _, ref := range s.References {
+		if ref.RefType == "CHILD_OF" {
+			result.ParentID = ref.SpanID
+			break
+		}
+	}
+
+	for _, tag := range s.Tags {
+		value := fmt.Sprint(tag.Value)
+		if f, ok := tag.Value.(float64); ok {
+			value = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		result.Tags[tag.Key] = value
+		if (tag.Key == "error" && value == "true") || (tag.Key == "otel.status_code" && strings.EqualFold(value, "error")) {
+			result.Error = true
+		}
+	}
+	return result
+}
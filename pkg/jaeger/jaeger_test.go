@@ -0,0 +1,82 @@
+package jaeger
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const traceResponse = `{"data": [{
+	"traceID": "4bf92f3577b34da6",
+	"spans": [
+		{"traceID": "4bf92f3577b34da6", "spanID": "b", "operationName": "SELECT orders", "processID": "p2",
+		 "references": [{"refType": "CHILD_OF", "spanID": "a"}], "startTime": 1682942400100000, "duration": 2500,
+		 "tags": [{"key": "db.system", "type": "string", "value": "postgresql"}, {"key": "error", "type": "bool", "value": true}]},
+		{"traceID": "4bf92f3577b34da6", "spanID": "a", "operationName": "GET /orders", "processID": "p1",
+		 "startTime": 1682942400000000, "duration": 120000,
+		 "tags": [{"key": "http.status_code", "type": "int64", "value": 500}]}
+	],
+	"processes": {"p1": {"serviceName": "frontend"}, "p2": {"serviceName": "orders-db"}}
+}]}`
+
+func TestJaegerSearch(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.URL.Path+"?"+r.URL.Query().Get("service")+"|"+r.URL.Query().Get("operation")+"|"+r.URL.Query().Get("tags"))
+		w.Write([]byte(traceResponse))
+	}))
+	defer server.Close()
+
+	backend, err := NewJaegerSearchBackend(nil, &logs.JaegerBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"cluster": "prod"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Token: &kommons.EnvVar{Value: "secret"}},
+		Tags:           []string{"http.status_code"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Id: "4bf92f3577b34da6", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []logs.Result{
+		{Id: "a", Time: "2023-05-01T12:00:00Z", Message: "frontend GET /orders 120ms", Labels: map[string]string{
+			"trace_id": "4bf92f3577b34da6", "span_id": "a", "service": "frontend", "operation": "GET /orders",
+			"duration": "120ms", "status": "ok", "http.status_code": "500", "cluster": "prod",
+		}},
+		{Id: "b", Time: "2023-05-01T12:00:00.1Z", Message: "orders-db SELECT orders 2.5ms error", Labels: map[string]string{
+			"trace_id": "4bf92f3577b34da6", "span_id": "b", "parent_span_id": "a", "service": "orders-db", "operation": "SELECT orders",
+			"duration": "2.5ms", "status": "error", "db.system": "postgresql", "error": "true", "cluster": "prod",
+		}},
+	}
+	if !reflect.DeepEqual(res.Results, want) {
+		t.Errorf("Search() = %+v, want %+v", res.Results, want)
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{
+		Id:     "frontend",
+		Labels: map[string]string{"operation": "GET /orders", "http.status_code": "500", "namespace": "shop"},
+		Start:  "1h",
+		Limit:  1,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	wantRequests := []string{"/api/traces/4bf92f3577b34da6?||", `/api/traces?frontend|GET /orders|{"http.status_code":"500"}`}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Errorf("requests = %v, want %v", requests, wantRequests)
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{}); err == nil {
+		t.Errorf("expected an error without a trace id or a service")
+	}
+}
@@ -0,0 +1,168 @@
+// Package jenkins searches the console output of the builds of Jenkins
+package jenkins
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+var (
+	// timestampPattern matches the timestamps of the pipelines with the timestamps option, e.g. [2023-05-01T12:00:00.123Z]
+	timestampPattern = regexp.MustCompile(`^\[([0-9]{4}-[0-9]{2}-[0-9]{2}T[^\]]+)\] `)
+
+	ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[A-Za-z]`)
+)
+
+type JenkinsSearch struct {
+	config *logs.JenkinsBackendConfig
+	client *rest.Client
+}
+
+func NewJenkinsSearchBackend(kClient *kommons.Client, config *logs.JenkinsBackendConfig) (*JenkinsSearch, error) {
+	client, err := rest.NewClient(kClient, config.HTTPConnection)
+	if err != nil {
+		return nil, err
+	}
+	return &JenkinsSearch{config: config, client: client}, nil
+}
+
+// Search returns the lines of the console output of the build from the offset of the page, up to the limit.
+// The lines take the time of their timestamp, or the start of the build. Only the timestamped lines are filtered
+// by the time range.
+func (t *JenkinsSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	name := q.Id
+	if name == "" {
+		name = t.config.Job
+	}
+	if name == "" {
+		return results, fmt.Errorf("the search requires the name of a job")
+	}
+	build := q.Labels["build"]
+	if build == "" {
+		build = "lastBuild"
+	}
+	path := jobPath(name) + "/" + url.PathEscape(build)
+
+	var b struct {
+		Number    int64  `json:"number"`
+		Result    string `json:"result"`
+		Timestamp int64  `json:"timestamp"`
+	}
+	if err := t.client.Get(ctx, path+"/api/json", url.Values{"tree": {"number,result,timestamp"}}, &b); err != nil {
+		return results, fmt.Errorf("error getting the build %s of %s: %w", build, name, err)
+	}
+
+	var offset int64
+	if q.Page != "" {
+		var err error
+		if offset, err = strconv.ParseInt(q.Page, 10, 64); err != nil {
+			return results, fmt.Errorf("invalid page %s: %w", q.Page, err)
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL(path+"/logText/progressiveText", url.Values{"start": {strconv.FormatInt(offset, 10)}}), nil)
+	if err != nil {
+		return results, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return results, fmt.Errorf("error getting the console output of the build %d of %s: %w", b.Number, name, err)
+	}
+	defer resp.Body.Close()
+
+	labels := map[string]string{"job": name, "build": strconv.FormatInt(b.Number, 10)}
+	if b.Result != "" {
+		labels["result"] = b.Result
+	}
+	for k, v := range t.config.Labels {
+		labels[k] = v
+	}
+
+	started := time.UnixMilli(b.Timestamp)
+	start, end := q.GetStart(), q.GetEnd()
+	reader := bufio.NewReaderSize(resp.Body, 64*1024)
+	for {
+		if q.Limit > 0 && int64(len(results.Results)) >= q.Limit {
+			// The next page starts after the last line read, when there's more text
+			if _, err := reader.Peek(1); err == nil {
+				results.NextPage = strconv.FormatInt(offset, 10)
+			}
+			break
+		}
+
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return results, err
+		}
+		// The last line is incomplete while the build is running, it's read by the next page
+		if line == "" || (err == io.EOF && resp.Header.Get("X-More-Data") == "true") {
+			break
+		}
+		offset += int64(len(line))
+
+		message := ansiPattern.ReplaceAllString(strings.TrimRight(line, "\r\n"), "")
+		written := started
+		if match := timestampPattern.FindStringSubmatch(message); match != nil {
+			if ts, err := time.Parse(time.RFC3339Nano, match[1]); err == nil {
+				written, message = ts, message[len(match[0]):]
+				if (start != nil && ts.Before(*start)) || (end != nil && ts.After(*end)) {
+					continue
+				}
+			}
+		}
+
+		lineLabels := make(map[string]string, len(labels))
+		for k, v := range labels {
+			lineLabels[k] = v
+		}
+		results.Results = append(results.Results, logs.Result{Time: written.UTC().Format(time.RFC3339Nano), Message: message, Labels: lineLabels})
+
+		if err == io.EOF {
+			break
+		}
+	}
+
+	// The running builds are followed from the offset of the text read
+	if results.NextPage == "" && resp.Header.Get("X-More-Data") == "true" {
+		results.NextPage = strconv.FormatInt(offset, 10)
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// jobPath returns the path of the job of the full name, e.g. /job/folder/job/deploy
+func jobPath(name string) string {
+	var path strings.Builder
+	for _, segment := range strings.Split(strings.Trim(name, "/"), "/") {
+		path.WriteString("/job/" + url.PathEscape(segment))
+	}
+	return path.String()
+}
+
+func (t *JenkinsSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Jenkins backend, the lines are filtered by the query once returned
+func (t *JenkinsSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Pagination: true}
+}
+
+// HealthCheck gets the mode of Jenkins
+func (t *JenkinsSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/json", url.Values{"tree": {"mode"}}, nil)
+}
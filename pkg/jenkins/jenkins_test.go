@@ -0,0 +1,100 @@
+package jenkins
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const consoleText = "Started by user admin\n" +
+	"[2023-05-01T12:00:01.000Z] \x1b[32m+ ./deploy.sh\x1b[0m\n" +
+	"[2023-05-01T12:00:05.000Z] deploy failed\n" +
+	"[2023-05-01T12:00:06.000Z] Finished: FAIL"
+
+func TestJenkinsSearch(t *testing.T) {
+	running := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, _ := r.BasicAuth(); user != "admin" || password != "api-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/job/shop/job/deploy/lastBuild/api/json", "/job/shop/job/deploy/12/api/json":
+			_, _ = w.Write([]byte(`{"number": 12, "result": "FAILURE", "timestamp": 1682942400000}`))
+		case "/job/shop/job/deploy/12/logText/progressiveText", "/job/shop/job/deploy/lastBuild/logText/progressiveText":
+			start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+			w.Header().Set("X-Text-Size", strconv.Itoa(len(consoleText)))
+			if running {
+				w.Header().Set("X-More-Data", "true")
+			}
+			_, _ = w.Write([]byte(consoleText[start:]))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewJenkinsSearchBackend(nil, &logs.JenkinsBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"source": "jenkins"}},
+		HTTPConnection: logs.HTTPConnection{
+			URL:      server.URL,
+			Username: &kommons.EnvVar{Value: "admin"},
+			Password: &kommons.EnvVar{Value: "api-token"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := map[string]string{"job": "shop/deploy", "build": "12", "result": "FAILURE", "source": "jenkins"}
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Id: "shop/deploy", Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, NextPage: "72", Results: []logs.Result{
+		{Time: "2023-05-01T12:00:00Z", Message: "Started by user admin", Labels: labels},
+		{Time: "2023-05-01T12:00:01Z", Message: "+ ./deploy.sh", Labels: labels},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	// The next page, in the time range
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Id: "shop/deploy", Labels: map[string]string{"build": "12"}, Page: res.NextPage, End: "2023-05-01T12:00:05Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = logs.SearchResults{Total: 1, Results: []logs.Result{{Time: "2023-05-01T12:00:05Z", Message: "deploy failed", Labels: labels}}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() of the next page = %+v, want %+v", res, want)
+	}
+
+	// The incomplete line of a running build is read by the next page
+	running = true
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Id: "shop/deploy", Page: "72"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 || res.NextPage != "113" {
+		t.Errorf("Search() of a running build = %+v, want a line and the offset of the incomplete line", res)
+	}
+}
+
+func TestJobPath(t *testing.T) {
+	for name, want := range map[string]string{
+		"deploy":               "/job/deploy",
+		"/shop/deploy/":        "/job/shop/job/deploy",
+		"shop/deploy prod":     "/job/shop/job/deploy%20prod",
+		"shop/feature%2Flogin": "/job/shop/job/feature%252Flogin",
+	} {
+		if got := jobPath(name); got != want {
+			t.Errorf("jobPath(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Package jetstream searches the messages of a NATS JetStream stream with ordered consumers
+package jetstream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/kommons"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+func NewJetStreamSearchBackend(kClient *kommons.Client, config *logs.NATSBackendConfig) (*JetStreamSearch, error) {
+	if config.Stream == "" {
+		return nil, fmt.Errorf("the stream is required")
+	}
+	subject, err := template.New("subject").Parse(config.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the subject template: %w", err)
+	}
+
+	timeout := 30 * time.Second
+	if config.Timeout != "" {
+		d, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the timeout: %w", err)
+		}
+		timeout = time.Duration(d)
+	}
+
+	opts, err := connectOptions(kClient, config)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := nats.Connect(config.URL, append(opts, nats.Name("apm-hub"), nats.Timeout(timeout))...)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", config.URL, err)
+	}
+	js, err := conn.JetStream()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("error getting the jetstream context: %w", err)
+	}
+
+	return &JetStreamSearch{config: config, conn: conn, js: js, subject: subject, timeout: timeout}, nil
+}
+
+// connectOptions returns the options authenticating with the credentials, the token or the username and password
+func connectOptions(kClient *kommons.Client, config *logs.NATSBackendConfig) ([]nats.Option, error) {
+	switch {
+	case config.Credentials != nil:
+		_, creds, err := kClient.GetEnvValue(*config.Credentials, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the credentials: %w", err)
+		}
+		jwt, err := nkeys.ParseDecoratedJWT([]byte(creds))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the user jwt: %w", err)
+		}
+		key, err := nkeys.ParseDecoratedUserNKey([]byte(creds))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the user nkey: %w", err)
+		}
+		return []nats.Option{nats.UserJWT(
+			func() (string, error) { return jwt, nil },
+			func(nonce []byte) ([]byte, error) { return key.Sign(nonce) },
+		)}, nil
+	case config.Token != nil:
+		_, token, err := kClient.GetEnvValue(*config.Token, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the token: %w", err)
+		}
+		return []nats.Option{nats.Token(token)}, nil
+	case config.Username != nil && config.Password != nil:
+		_, username, err := kClient.GetEnvValue(*config.Username, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the username: %w", err)
+		}
+		_, password, err := kClient.GetEnvValue(*config.Password, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the password: %w", err)
+		}
+		return []nats.Option{nats.UserInfo(username, password)}, nil
+	}
+	return nil, nil
+}
+
+type JetStreamSearch struct {
+	config  *logs.NATSBackendConfig
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	subject *template.Template
+	timeout time.Duration
+}
+
+// Search reads the messages of the stream from the start of the search, or from the sequence of the page,
+// until the end of the search, the last message of the stream or the limit of the search.
+// The next page is the sequence of the message following the last result.
+func (t *JetStreamSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	var subject bytes.Buffer
+	if err := t.subject.Execute(&subject, q); err != nil {
+		return results, fmt.Errorf("error executing the subject template: %w", err)
+	}
+
+	opts := []nats.SubOpt{nats.BindStream(t.config.Stream), nats.OrderedConsumer()}
+	if q.Page != "" {
+		seq, err := strconv.ParseUint(q.Page, 10, 64)
+		if err != nil {
+			return results, fmt.Errorf("invalid page %s: %w", q.Page, err)
+		}
+		opts = append(opts, nats.StartSequence(seq))
+	} else if start := q.GetStart(); start != nil {
+		opts = append(opts, nats.StartTime(*start))
+	} else {
+		opts = append(opts, nats.DeliverAll())
+	}
+
+	sub, err := t.js.SubscribeSync(subject.String(), opts...)
+	if err != nil {
+		return results, fmt.Errorf("error subscribing to %s: %w", t.config.Stream, err)
+	}
+	defer func() { _ = sub.Unsubscribe() }()
+
+	// The consumer waits for the next message when there's none to deliver
+	info, err := sub.ConsumerInfo()
+	if err != nil {
+		return results, fmt.Errorf("error getting the consumer info: %w", err)
+	}
+	if info.NumPending == 0 && info.Delivered.Consumer == 0 {
+		return results, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	end := q.GetEnd()
+	query := strings.ToLower(q.Query)
+	for {
+		msg, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				return results, fmt.Errorf("the search didn't complete before the deadline: %w", err)
+			}
+			return results, err
+		}
+		meta, err := msg.Metadata()
+		if err != nil {
+			return results, fmt.Errorf("error getting the metadata of the message: %w", err)
+		}
+		if end != nil && meta.Timestamp.After(*end) {
+			break
+		}
+
+		if r, ok := t.result(msg, meta, q.Labels, query); ok {
+			results.Results = append(results.Results, r)
+			if q.Limit > 0 && int64(len(results.Results)) >= q.Limit {
+				if meta.NumPending > 0 {
+					results.NextPage = strconv.FormatUint(meta.Sequence.Stream+1, 10)
+				}
+				break
+			}
+		}
+		if meta.NumPending == 0 {
+			break
+		}
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// result returns the result of the message, false when it doesn't match the labels of its subject or the query.
+// The headers of the message are labels.
+func (t *JetStreamSearch) result(msg *nats.Msg, meta *nats.MsgMetadata, labels map[string]string, query string) (logs.Result, bool) {
+	message := strings.TrimSpace(string(msg.Data))
+	if query != "" && !strings.Contains(strings.ToLower(message), query) {
+		return logs.Result{}, false
+	}
+
+	r := logs.Result{
+		Id:      strconv.FormatUint(meta.Sequence.Stream, 10),
+		Time:    meta.Timestamp.UTC().Format(time.RFC3339Nano),
+		Message: message,
+		Labels:  map[string]string{"subject": msg.Subject},
+	}
+	for name, values := range msg.Header {
+		if len(values) > 0 {
+			r.Labels[name] = values[0]
+		}
+	}
+
+	tokens := strings.Split(msg.Subject, ".")
+	for i, label := range t.config.SubjectLabels {
+		if label == "" {
+			continue
+		}
+		var value string
+		if i < len(tokens) {
+			value = tokens[i]
+		}
+		if v, ok := labels[label]; ok && v != value {
+			return logs.Result{}, false
+		}
+		r.Labels[label] = value
+	}
+
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r, true
+}
+
+func (t *JetStreamSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+func (t *JetStreamSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck gets the info of the stream
+func (t *JetStreamSearch) HealthCheck(ctx context.Context) error {
+	_, err := t.js.StreamInfo(t.config.Stream, nats.Context(ctx))
+	return err
+}
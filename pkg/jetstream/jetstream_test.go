@@ -0,0 +1,60 @@
+package jetstream
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/nats-io/nats.go"
+)
+
+func TestResult(t *testing.T) {
+	backend := &JetStreamSearch{config: &logs.NATSBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"source": "nats"}},
+		SubjectLabels: []string{"", "namespace", "pod"},
+	}}
+	meta := &nats.MsgMetadata{
+		Sequence:  nats.SequencePair{Stream: 42, Consumer: 1},
+		Timestamp: time.Date(2023, 5, 1, 12, 0, 0, 500000000, time.UTC),
+	}
+	msg := &nats.Msg{
+		Subject: "logs.default.api-0",
+		Header:  nats.Header{"Level": {"error"}},
+		Data:    []byte("connection timeout\n"),
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		query  string
+		want   logs.Result
+		wantOk bool
+	}{
+		{
+			name:   "match",
+			labels: map[string]string{"namespace": "default", "cluster": "prod"},
+			query:  "timeout",
+			want: logs.Result{
+				Id:      "42",
+				Time:    "2023-05-01T12:00:00.5Z",
+				Message: "connection timeout",
+				Labels:  map[string]string{"subject": "logs.default.api-0", "Level": "error", "namespace": "default", "pod": "api-0", "source": "nats"},
+			},
+			wantOk: true,
+		},
+		{name: "subject label", labels: map[string]string{"pod": "api-1"}},
+		{name: "query", query: "refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := backend.result(msg, meta, tt.labels, tt.query)
+			if ok != tt.wantOk {
+				t.Fatalf("result() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
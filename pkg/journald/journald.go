@@ -0,0 +1,189 @@
+// Package journald searches the systemd journal with journalctl
+package journald
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/ingest"
+	durationUtil "github.com/flanksource/commons/duration"
+)
+
+// flags are the labels filtering the entries with a flag of journalctl instead of a match
+var flags = map[string]string{
+	"unit":       "--unit",
+	"user_unit":  "--user-unit",
+	"identifier": "--identifier",
+	"priority":   "--priority",
+}
+
+func NewJournaldSearchBackend(config *logs.JournaldBackendConfig) (*JournaldSearch, error) {
+	command := config.Command
+	if command == "" {
+		command = "journalctl"
+	}
+
+	timeout := 30 * time.Second
+	if config.Timeout != "" {
+		d, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the timeout: %w", err)
+		}
+		timeout = time.Duration(d)
+	}
+
+	return &JournaldSearch{config: config, command: command, timeout: timeout}, nil
+}
+
+type JournaldSearch struct {
+	config  *logs.JournaldBackendConfig
+	command string
+	timeout time.Duration
+}
+
+// Search reads the entries of the journal from the start of the search, or after the cursor of the page,
+// until the end of the search or the limit of the search.
+func (t *JournaldSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, t.command, t.args(q)...)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return results, err
+	}
+	if err := cmd.Start(); err != nil {
+		return results, fmt.Errorf("error running %s: %w", t.command, err)
+	}
+
+	query := strings.ToLower(q.Query)
+	decoder := json.NewDecoder(stdout)
+	for {
+		var fields map[string]any
+		if err := decoder.Decode(&fields); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			cancel()
+			_ = cmd.Wait()
+			return results, fmt.Errorf("error decoding the entries: %w", err)
+		}
+
+		entry := journalEntry(fields)
+		if query != "" && !strings.Contains(strings.ToLower(entry["MESSAGE"]), query) {
+			continue
+		}
+
+		// The next page starts after the last result, when there's another entry
+		if q.Limit > 0 && int64(len(results.Results)) >= q.Limit {
+			results.NextPage = results.Results[len(results.Results)-1].Id
+			break
+		}
+
+		r := ingest.JournalResult(entry, t.config.Labels)
+		r.Id = entry["__CURSOR"]
+		results.Results = append(results.Results, r)
+	}
+
+	stopped := results.NextPage != ""
+	cancel()
+	if err := cmd.Wait(); err != nil && !stopped {
+		if ctx.Err() == context.DeadlineExceeded {
+			return results, fmt.Errorf("the search didn't complete before the deadline: %w", ctx.Err())
+		}
+		return results, fmt.Errorf("error running %s: %w: %s", t.command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// args returns the arguments of journalctl for the search
+func (t *JournaldSearch) args(q *logs.SearchParams) []string {
+	args := []string{"--output=json", "--no-pager", "--quiet"}
+	if t.config.Directory != "" {
+		args = append(args, "--directory="+t.config.Directory)
+	}
+
+	if q.Page != "" {
+		args = append(args, "--after-cursor="+q.Page)
+	} else if start := q.GetStart(); start != nil {
+		args = append(args, "--since=@"+strconv.FormatInt(start.Unix(), 10))
+	}
+	if end := q.GetEnd(); end != nil {
+		args = append(args, "--until=@"+strconv.FormatInt(int64(math.Ceil(float64(end.UnixNano())/1e9)), 10))
+	}
+
+	fields := make(map[string]string, len(ingest.JournalLabels))
+	for field, label := range ingest.JournalLabels {
+		fields[label] = field
+	}
+
+	labels := make([]string, 0, len(q.Labels))
+	for label := range q.Labels {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	// The matches of the same field are alternatives, the matches of different fields must all match
+	for _, label := range labels {
+		for _, value := range strings.Split(q.Labels[label], ",") {
+			if flag, ok := flags[label]; ok {
+				args = append(args, flag+"="+value)
+			} else if field, ok := fields[label]; ok {
+				args = append(args, field+"="+value)
+			}
+		}
+	}
+	return args
+}
+
+// journalEntry returns the fields of an entry of the json output.
+// The binary values are arrays of bytes, and the fields with several values are arrays of their values, the first one being kept.
+func journalEntry(fields map[string]any) map[string]string {
+	entry := make(map[string]string, len(fields))
+	for key, value := range fields {
+		switch v := value.(type) {
+		case string:
+			entry[key] = v
+		case []any:
+			if len(v) == 0 {
+				continue
+			}
+			if s, ok := v[0].(string); ok {
+				entry[key] = s
+				continue
+			}
+			b := make([]byte, 0, len(v))
+			for _, n := range v {
+				if f, ok := n.(float64); ok {
+					b = append(b, byte(f))
+				}
+			}
+			entry[key] = string(b)
+		}
+	}
+	return entry
+}
+
+func (t *JournaldSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+func (t *JournaldSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
@@ -0,0 +1,96 @@
+package journald
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// journalctl writes a fake journalctl printing the entries, and the file its arguments are written to
+func journalctl(t *testing.T, entries string) (string, string) {
+	dir := t.TempDir()
+	command := filepath.Join(dir, "journalctl")
+	args := filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" > " + args + "\ncat <<'EOF'\n" + entries + "EOF\n"
+	if err := os.WriteFile(command, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return command, args
+}
+
+func TestJournaldSearch(t *testing.T) {
+	command, argsFile := journalctl(t, `{"__CURSOR":"s=1;i=1","__REALTIME_TIMESTAMP":"1682942400000000","_SYSTEMD_UNIT":"nginx.service","PRIORITY":"6","_HOSTNAME":"vm-1","MESSAGE":"started"}
+{"__CURSOR":"s=1;i=2","__REALTIME_TIMESTAMP":"1682942401500000","_SYSTEMD_UNIT":"nginx.service","PRIORITY":"3","_HOSTNAME":"vm-1","MESSAGE":[99,111,110,110,101,99,116,32,102,97,105,108,101,100,10]}
+{"__CURSOR":"s=1;i=3","__REALTIME_TIMESTAMP":"1682942402000000","_SYSTEMD_UNIT":"nginx.service","PRIORITY":"3","_HOSTNAME":"vm-1","MESSAGE":"upstream failed"}
+`)
+
+	backend, err := NewJournaldSearchBackend(&logs.JournaldBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"source": "journald"}},
+		Command:       command,
+		Directory:     "/host/var/log/journal",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query:  "FAILED",
+		Limit:  1,
+		Start:  "2023-05-01T12:00:00Z",
+		End:    "2023-05-01T13:00:00.5Z",
+		Labels: map[string]string{"unit": "nginx.service,php-fpm.service", "priority": "err", "hostname": "vm-1", "cluster": "prod"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 1, NextPage: "s=1;i=2", Results: []logs.Result{
+		{
+			Id:      "s=1;i=2",
+			Time:    "2023-05-01T12:00:01.5Z",
+			Message: "connect failed",
+			Labels:  map[string]string{"unit": "nginx.service", "priority": "3", "level": "error", "hostname": "vm-1", "source": "journald"},
+		},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantArgs := "--output=json --no-pager --quiet --directory=/host/var/log/journal --since=@1682942400 --until=@1682946001 " +
+		"_HOSTNAME=vm-1 --priority=err --unit=nginx.service --unit=php-fpm.service"
+	if got := strings.TrimSpace(string(args)); got != wantArgs {
+		t.Errorf("journalctl args = %s, want %s", got, wantArgs)
+	}
+}
+
+func TestJournaldSearch_Page(t *testing.T) {
+	command, argsFile := journalctl(t, "")
+	backend, err := NewJournaldSearchBackend(&logs.JournaldBackendConfig{Command: command})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Page: "s=1;i=2", Start: "2023-05-01T12:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 0 || res.NextPage != "" {
+		t.Errorf("Search() = %+v, want no results", res)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(args)); got != "--output=json --no-pager --quiet --after-cursor=s=1;i=2" {
+		t.Errorf("journalctl args = %s", got)
+	}
+}
@@ -0,0 +1,175 @@
+// Package kubeaudit searches the audit logs of the Kubernetes API servers
+package kubeaudit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/ingest"
+	"github.com/flanksource/commons/collections"
+	"github.com/flanksource/commons/logger"
+)
+
+// filters are the labels of the audit events the search labels must match, the events without the label
+// don't match, e.g. the cluster scoped events for a namespace
+var filters = []string{
+	"verb", "user", "resource", "subresource", "namespace", "name", "api_group", "code", "stage",
+	"decision", "source_ip", "impersonated_user",
+}
+
+// maxEventSize is the size of the largest event read, the events with the request and response bodies can be large
+const maxEventSize = 16 * 1024 * 1024
+
+func NewKubernetesAuditSearchBackend(config *logs.KubernetesAuditBackendConfig) (*KubernetesAuditSearch, error) {
+	if len(config.Paths) == 0 {
+		return nil, fmt.Errorf("the paths of the audit logs are required")
+	}
+	for _, path := range config.Paths {
+		if _, err := filepath.Match(path, ""); err != nil {
+			return nil, fmt.Errorf("invalid path %s: %w", path, err)
+		}
+	}
+	return &KubernetesAuditSearch{config: config}, nil
+}
+
+type KubernetesAuditSearch struct {
+	config *logs.KubernetesAuditBackendConfig
+}
+
+// Search returns the last events of the audit logs in the time range, up to the limit. The search labels
+// filter the events by verb, user, resource, namespace, name... with comma separated alternatives,
+// and the id of the search is the name of the object.
+func (t *KubernetesAuditSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+
+	start, end := q.GetStart(), q.GetEnd()
+	for _, file := range t.files(start) {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		err := readEvents(file, func(event ingest.AuditEvent) {
+			r := ingest.AuditResult(event, t.config.Labels)
+			if !match(r, q, start, end) {
+				return
+			}
+			results.Results = append(results.Results, r)
+
+			// Only the last events are kept
+			if q.Limit > 0 && int64(len(results.Results)) >= 2*q.Limit {
+				results.Results = append(results.Results[:0], results.Results[int64(len(results.Results))-q.Limit:]...)
+			}
+		})
+		if err != nil {
+			logger.Warnf("error reading the audit log %s: %v", file, err)
+		}
+	}
+
+	if q.Limit > 0 && int64(len(results.Results)) > q.Limit {
+		results.Results = results.Results[int64(len(results.Results))-q.Limit:]
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// files returns the audit logs written after the start, from the least recently written, i.e. the rotated files first
+func (t *KubernetesAuditSearch) files(start *time.Time) []string {
+	type file struct {
+		path    string
+		modTime time.Time
+	}
+
+	var files []file
+	for _, pattern := range t.config.Paths {
+		matches, _ := filepath.Glob(pattern)
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			if start != nil && info.ModTime().Before(*start) {
+				continue
+			}
+			files = append(files, file{path: path, modTime: info.ModTime()})
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.path)
+	}
+	return paths
+}
+
+// readEvents calls fn with the events of the json lines of the file, gzip compressed when ending with .gz.
+// The lines that aren't events are skipped.
+func readEvents(path string, fn func(ingest.AuditEvent)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := io.Reader(f)
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	for scanner.Scan() {
+		var event ingest.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || event.AuditID == "" {
+			continue
+		}
+		fn(event)
+	}
+	return scanner.Err()
+}
+
+func match(r logs.Result, q *logs.SearchParams, start, end *time.Time) bool {
+	if start != nil || end != nil {
+		ts, err := time.Parse(time.RFC3339Nano, r.Time)
+		if err != nil || (start != nil && ts.Before(*start)) || (end != nil && ts.After(*end)) {
+			return false
+		}
+	}
+	if q.Id != "" && r.Labels["name"] != q.Id {
+		return false
+	}
+
+	for k, v := range q.Labels {
+		val, ok := r.Labels[k]
+		if !ok && !collections.Contains(filters, k) {
+			continue
+		}
+		if !collections.MatchItems(val, strings.Split(v, ",")...) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *KubernetesAuditSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the audit backend, the events are filtered by the query once returned
+func (t *KubernetesAuditSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
@@ -0,0 +1,79 @@
+package kubeaudit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+const rotatedLog = `{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"a1","stage":"ResponseComplete","requestURI":"/api/v1/namespaces/default/pods/web-0","verb":"delete","user":{"username":"alice"},"objectRef":{"resource":"pods","namespace":"default","name":"web-0"},"responseStatus":{"code":200},"stageTimestamp":"2023-05-01T11:00:00Z"}
+`
+
+const auditLog = `{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"b1","stage":"ResponseComplete","requestURI":"/api/v1/namespaces/default/pods/web-1","verb":"delete","user":{"username":"alice"},"objectRef":{"resource":"pods","namespace":"default","name":"web-1"},"responseStatus":{"code":200},"stageTimestamp":"2023-05-01T12:00:00Z"}
+not an event
+{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"b2","stage":"ResponseComplete","requestURI":"/api/v1/nodes","verb":"list","user":{"username":"bob"},"objectRef":{"resource":"nodes"},"responseStatus":{"code":200},"stageTimestamp":"2023-05-01T12:00:01Z"}
+{"kind":"Event","apiVersion":"audit.k8s.io/v1","level":"Metadata","auditID":"b3","stage":"ResponseComplete","requestURI":"/api/v1/namespaces/default/secrets/db","verb":"get","user":{"username":"bob"},"objectRef":{"resource":"secrets","namespace":"default","name":"db"},"responseStatus":{"code":403},"stageTimestamp":"2023-05-01T12:00:02Z"}
+`
+
+func TestKubernetesAuditSearch(t *testing.T) {
+	dir := t.TempDir()
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte(rotatedLog))
+	_ = w.Close()
+	if err := os.WriteFile(filepath.Join(dir, "audit-2023-05-01T11-30-00.000.log.gz"), gz.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "audit.log"), []byte(auditLog), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rotated := time.Date(2023, 5, 1, 11, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "audit-2023-05-01T11-30-00.000.log.gz"), rotated, rotated); err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err := NewKubernetesAuditSearchBackend(&logs.KubernetesAuditBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"cluster": "prod"}},
+		Paths:         []string{filepath.Join(dir, "audit*.log*")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		params logs.SearchParams
+		want   []string
+	}{
+		{name: "all", want: []string{"a1", "b1", "b2", "b3"}},
+		{name: "limit", params: logs.SearchParams{Limit: 2}, want: []string{"b2", "b3"}},
+		{name: "verbs", params: logs.SearchParams{Labels: map[string]string{"verb": "delete,get"}}, want: []string{"a1", "b1", "b3"}},
+		{name: "namespace", params: logs.SearchParams{Labels: map[string]string{"namespace": "default", "user": "bob"}}, want: []string{"b3"}},
+		{name: "code", params: logs.SearchParams{Labels: map[string]string{"code": "403", "cluster": "prod", "other": "x"}}, want: []string{"b3"}},
+		{name: "name", params: logs.SearchParams{Id: "web-0"}, want: []string{"a1"}},
+		{name: "rotated file before the start", params: logs.SearchParams{Start: "2023-05-01T11:45:00Z", Labels: map[string]string{"verb": "delete"}}, want: []string{"b1"}},
+		{name: "end", params: logs.SearchParams{End: "2023-05-01T12:00:00Z"}, want: []string{"a1", "b1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := backend.Search(context.Background(), &tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, r := range res.Results {
+				ids = append(ids, r.Id)
+			}
+			if !reflect.DeepEqual(ids, tt.want) || res.Total != len(tt.want) {
+				t.Errorf("Search() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
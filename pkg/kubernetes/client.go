@@ -35,7 +35,7 @@ func GetKubeClient(kommonsClient *kommons.Client, kubernetesSeachBackend *logs.K
 	return &Client{kommonsClient}, nil
 }
 
-func (c *Client) GetAllPodsForNode(nodeName string, labels map[string]string) (pods *v1.PodList, err error) {
+func (c *Client) GetAllPodsForNode(ctx context.Context, nodeName string, labels map[string]string) (pods *v1.PodList, err error) {
 	client, err := c.GetClientset()
 	if err != nil {
 		return nil, err
@@ -43,12 +43,12 @@ func (c *Client) GetAllPodsForNode(nodeName string, labels map[string]string) (p
 	labelsString := GetLabelString(labels)
 
 	if nodeName != "" {
-		pods, err = client.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{
+		pods, err = client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
 			FieldSelector: "spec.nodeName=" + nodeName,
 			LabelSelector: labelsString,
 		})
 	} else {
-		pods, err = client.CoreV1().Pods("").List(context.TODO(), metav1.ListOptions{
+		pods, err = client.CoreV1().Pods("").List(ctx, metav1.ListOptions{
 			LabelSelector: labelsString,
 		})
 	}
@@ -62,19 +62,19 @@ func (c *Client) GetAllPodsForNode(nodeName string, labels map[string]string) (p
 }
 
 // empty name will fetch all pods with the specified labels and if labels are nil will fetch the pods with the specified name
-func (c *Client) GetPodsWithNameAndLabels(name, namespace string, labels map[string]string) (pods *v1.PodList, err error) {
+func (c *Client) GetPodsWithNameAndLabels(ctx context.Context, name, namespace string, labels map[string]string) (pods *v1.PodList, err error) {
 	client, err := c.GetClientset()
 	if err != nil {
 		return nil, err
 	}
 	labelsString := GetLabelString(labels)
 	if name != "" {
-		pods, err = client.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		pods, err = client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
 			FieldSelector: "metadata.name=" + name,
 			LabelSelector: labelsString,
 		})
 	} else {
-		pods, err = client.CoreV1().Pods(namespace).List(context.TODO(), metav1.ListOptions{
+		pods, err = client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
 			LabelSelector: labelsString,
 		})
 	}
@@ -87,7 +87,7 @@ func (c *Client) GetPodsWithNameAndLabels(name, namespace string, labels map[str
 	return nil, nil
 }
 
-func (c *Client) GetPodsForDeployment(name, namespace string, labels map[string]string) (pods *v1.PodList, err error) {
+func (c *Client) GetPodsForDeployment(ctx context.Context, name, namespace string, labels map[string]string) (pods *v1.PodList, err error) {
 	client, err := c.GetClientset()
 	if err != nil {
 		return nil, err
@@ -97,12 +97,12 @@ func (c *Client) GetPodsForDeployment(name, namespace string, labels map[string]
 
 	var deployments *appsv1.DeploymentList
 	if name != "" {
-		deployments, err = client.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{
+		deployments, err = client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{
 			LabelSelector: labelsString,
 			FieldSelector: "metadata.name=" + name,
 		})
 	} else {
-		deployments, err = client.AppsV1().Deployments(namespace).List(context.TODO(), metav1.ListOptions{
+		deployments, err = client.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{
 			LabelSelector: labelsString,
 		})
 	}
@@ -114,7 +114,7 @@ func (c *Client) GetPodsForDeployment(name, namespace string, labels map[string]
 		Items: []v1.Pod{},
 	}
 	for _, deployment := range deployments.Items {
-		deploymentPod, err = client.CoreV1().Pods(deployment.GetNamespace()).List(context.TODO(), metav1.ListOptions{
+		deploymentPod, err = client.CoreV1().Pods(deployment.GetNamespace()).List(ctx, metav1.ListOptions{
 			LabelSelector: GetLabelString(deployment.Spec.Template.Labels),
 		})
 		if err != nil {
@@ -126,7 +126,7 @@ func (c *Client) GetPodsForDeployment(name, namespace string, labels map[string]
 	return
 }
 
-func (c *Client) GetPodsForService(name, namespace string, labels map[string]string) (pods *v1.PodList, err error) {
+func (c *Client) GetPodsForService(ctx context.Context, name, namespace string, labels map[string]string) (pods *v1.PodList, err error) {
 	client, err := c.GetClientset()
 	if err != nil {
 		return nil, err
@@ -136,12 +136,12 @@ func (c *Client) GetPodsForService(name, namespace string, labels map[string]str
 
 	var services *v1.ServiceList
 	if name != "" {
-		services, err = client.CoreV1().Services(namespace).List(context.TODO(), metav1.ListOptions{
+		services, err = client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{
 			LabelSelector: labelsString,
 			FieldSelector: "metadata.name=" + name,
 		})
 	} else {
-		services, err = client.CoreV1().Services(namespace).List(context.TODO(), metav1.ListOptions{
+		services, err = client.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{
 			LabelSelector: labelsString,
 		})
 	}
@@ -152,7 +152,7 @@ func (c *Client) GetPodsForService(name, namespace string, labels map[string]str
 		Items: []v1.Pod{},
 	}
 	for _, service := range services.Items {
-		servicePods, err := client.CoreV1().Pods(service.GetNamespace()).List(context.TODO(), metav1.ListOptions{
+		servicePods, err := client.CoreV1().Pods(service.GetNamespace()).List(ctx, metav1.ListOptions{
 			LabelSelector: GetLabelString(service.Spec.Selector),
 		})
 		if err != nil {
@@ -172,7 +172,7 @@ func getLogResult(line string) logs.Result {
 	}
 }
 
-func (c *Client) GetLogsForPod(q *logs.SearchParams, pod v1.Pod) (map[string][]logs.Result, error) {
+func (c *Client) GetLogsForPod(ctx context.Context, q *logs.SearchParams, pod v1.Pod) (map[string][]logs.Result, error) {
 	containerLogs := make(map[string][]logs.Result)
 	client, err := c.GetClientset()
 	if err != nil {
@@ -202,7 +202,7 @@ func (c *Client) GetLogsForPod(q *logs.SearchParams, pod v1.Pod) (map[string][]l
 			options.SinceTime = &metav1.Time{Time: *start}
 		}
 
-		podLogs, err := pods.GetLogs(pod.Name, options).Do(context.TODO()).Raw()
+		podLogs, err := pods.GetLogs(pod.Name, options).Do(ctx).Raw()
 		if err != nil {
 			logger.Tracef("failed to begin streaming %s/%s: %s", pod.Name, container.Name, err)
 			continue
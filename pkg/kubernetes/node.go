@@ -0,0 +1,166 @@
+package kubernetes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/logger"
+)
+
+// defaultServices are the logs of the nodes searched by default
+var defaultServices = []string{"kubelet", "containerd", "/kern.log"}
+
+// identifierPattern matches the host and the identifier following the timestamps of the journal and syslog, e.g. node-1 kubelet[812]:
+var identifierPattern = regexp.MustCompile(`^\s*\S+ [^\s:]+: ?`)
+
+func NewKubernetesNodeSearchBackend(client *Client, config *logs.KubernetesNodeBackendConfig) (*KubernetesNodeSearch, error) {
+	parser, err := logs.NewTimestampParser([]string{logs.TimestampRFC3339, logs.TimestampSyslog}, "")
+	if err != nil {
+		return nil, err
+	}
+	return &KubernetesNodeSearch{client: client, config: config, parser: parser}, nil
+}
+
+// KubernetesNodeSearch searches the logs of the services of the nodes with the /logs endpoint of the kubelet
+type KubernetesNodeSearch struct {
+	client *Client
+	config *logs.KubernetesNodeBackendConfig
+	parser *logs.TimestampParser
+}
+
+// Search returns the last lines of the logs of the services of the node of the id, up to the limit per item.
+// The services are the comma separated services of the service label, or of the config.
+func (t *KubernetesNodeSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+	if q.Id == "" {
+		return results, fmt.Errorf("the search requires the name of a node")
+	}
+
+	services := t.config.Services
+	if service := q.Labels["service"]; service != "" {
+		services = strings.Split(service, ",")
+	}
+	if len(services) == 0 {
+		services = defaultServices
+	}
+
+	for _, service := range services {
+		lines, err := t.serviceLogs(ctx, q.Id, strings.TrimSpace(service), q)
+		if err != nil {
+			logger.Errorf("error getting the logs of %s of the node %s: %v", service, q.Id, err)
+			continue
+		}
+		results.Results = append(results.Results, lines...)
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// serviceLogs returns the lines of the service in the time range, the last ones up to the limit per item.
+// The services are queried with the node log query, filtering the journal by time and tailing it on the node,
+// while the files of /var/log are read as a whole.
+func (t *KubernetesNodeSearch) serviceLogs(ctx context.Context, node, service string, q *logs.SearchParams) ([]logs.Result, error) {
+	clientset, err := t.client.GetClientset()
+	if err != nil {
+		return nil, err
+	}
+
+	limit := q.LimitPerItem
+	if limit == 0 {
+		limit = q.Limit
+	}
+	start, end := q.GetStart(), q.GetEnd()
+
+	path := "/api/v1/nodes/" + url.PathEscape(node) + "/proxy/logs/"
+	if isLogFile(service) {
+		path += strings.TrimPrefix(service, "/")
+	}
+	req := clientset.CoreV1().RESTClient().Get().AbsPath(path)
+	if !isLogFile(service) {
+		req = req.Param("query", service)
+		if limit > 0 {
+			req = req.Param("tailLines", strconv.FormatInt(limit, 10))
+		}
+		if start != nil {
+			req = req.Param("sinceTime", start.UTC().Format(time.RFC3339))
+		}
+		if end != nil {
+			req = req.Param("untilTime", end.UTC().Format(time.RFC3339))
+		}
+	}
+
+	stream, err := req.Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	labels := map[string]string{"node": node, "service": service}
+	for k, v := range t.config.Labels {
+		labels[k] = v
+	}
+
+	var results []logs.Result
+	var written time.Time
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		// The markers of journalctl, e.g. -- No entries --
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "-- ") {
+			continue
+		}
+
+		message := line
+		if ts, rest, ok := t.parser.ParsePrefix(line); ok {
+			written, message = ts, identifierPattern.ReplaceAllString(rest, "")
+		} else if written.IsZero() {
+			// The other lines take the time of the previous line, e.g. the lines of the stack traces
+			continue
+		}
+		if (start != nil && written.Before(*start)) || (end != nil && written.After(*end)) {
+			continue
+		}
+
+		lineLabels := make(map[string]string, len(labels))
+		for k, v := range labels {
+			lineLabels[k] = v
+		}
+		results = append(results, logs.Result{Time: written.UTC().Format(time.RFC3339Nano), Message: message, Labels: lineLabels})
+
+		// Only the last lines are kept
+		if limit > 0 && int64(len(results)) >= 2*limit {
+			results = append(results[:0], results[int64(len(results))-limit:]...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && int64(len(results)) > limit {
+		results = results[int64(len(results))-limit:]
+	}
+	return results, nil
+}
+
+// isLogFile returns whether the service is a file of /var/log, e.g. /kern.log, rather than a systemd service.
+// The files are prefixed with / as in the node log query.
+func isLogFile(service string) bool {
+	return strings.HasPrefix(service, "/")
+}
+
+func (t *KubernetesNodeSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the node backend, the lines are filtered by the query once returned
+func (t *KubernetesNodeSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
@@ -0,0 +1,101 @@
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const kubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+  - name: test
+    cluster:
+      server: %s
+users:
+  - name: test
+contexts:
+  - name: test
+    context:
+      cluster: test
+      user: test
+current-context: test
+`
+
+func TestKubernetesNodeSearch(t *testing.T) {
+	ts := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	journal := fmt.Sprintf("%s node-1 kubelet[812]: I0501 Started kubelet\n"+
+		"-- No entries --\n"+
+		"%s node-1 kubelet[812]: E0501 failed to pull image\n", ts.Format(time.StampMicro), ts.Add(time.Second).Format(time.StampMicro))
+	kernLog := fmt.Sprintf("%s node-1 kernel: [ 1.000] Linux version 6.1\n"+
+		"%s node-1 kernel: [ 9.000] Out of memory: Killed process 812\n", ts.Format(time.Stamp), ts.Add(2*time.Second).Format(time.Stamp))
+
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/nodes/node-1/proxy/logs/":
+			queries = append(queries, r.URL.Query().Get("query")+"|"+r.URL.Query().Get("tailLines")+"|"+r.URL.Query().Get("sinceTime"))
+			if r.URL.Query().Get("query") != "kubelet" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(journal))
+		case "/api/v1/nodes/node-1/proxy/logs/kern.log":
+			_, _ = w.Write([]byte(kernLog))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	kommonsClient, err := kommons.NewClientFromBytes([]byte(fmt.Sprintf(kubeconfig, server.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewKubernetesNodeSearchBackend(&Client{kommonsClient}, &logs.KubernetesNodeBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"cluster": "prod"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := func(service string) map[string]string {
+		return map[string]string{"node": "node-1", "service": service, "cluster": "prod"}
+	}
+	start := ts.Add(500 * time.Millisecond)
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Type: "KubernetesNode", Id: "node-1", Start: start.Format(time.RFC3339Nano), LimitPerItem: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Time: ts.Add(time.Second).Format(time.RFC3339Nano), Message: "E0501 failed to pull image", Labels: labels("kubelet")},
+		{Time: ts.Add(2 * time.Second).Format(time.RFC3339Nano), Message: "[ 9.000] Out of memory: Killed process 812", Labels: labels("/kern.log")},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+	wantQueries := []string{"kubelet|5|" + start.Format(time.RFC3339), "containerd|5|" + start.Format(time.RFC3339)}
+	if !reflect.DeepEqual(queries, wantQueries) {
+		t.Errorf("node log queries = %v, want %v", queries, wantQueries)
+	}
+
+	// The services of the service label
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Type: "KubernetesNode", Id: "node-1", Labels: map[string]string{"service": "/kern.log"}, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 || res.Results[0].Message != "[ 9.000] Out of memory: Killed process 812" {
+		t.Errorf("Search() of the kernel logs = %+v, want the last line", res)
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Type: "KubernetesNode"}); err == nil {
+		t.Error("Search() without node succeeded")
+	}
+}
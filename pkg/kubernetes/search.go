@@ -1,6 +1,7 @@
 package kubernetes
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -34,7 +35,11 @@ func (t *KubernetesSearch) MatchRoute(q *logs.SearchParams) (match bool, isAddit
 	return t.config.CommonBackend.Routes.MatchRoute(q)
 }
 
-func (s *KubernetesSearch) Search(q *logs.SearchParams) (r logs.SearchResults, err error) {
+func (t *KubernetesSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
+
+func (s *KubernetesSearch) Search(ctx context.Context, q *logs.SearchParams) (r logs.SearchResults, err error) {
 	var resultLabels = make(map[string]string)
 	namespace, name := s.GetNameNamespace(q)
 
@@ -42,18 +47,18 @@ func (s *KubernetesSearch) Search(q *logs.SearchParams) (r logs.SearchResults, e
 	var pods *v1.PodList
 	switch {
 	case strings.Contains(strings.ToLower(q.Type), "kubernetespod"):
-		pods, err = s.client.GetPodsWithNameAndLabels(name, namespace, q.Labels)
+		pods, err = s.client.GetPodsWithNameAndLabels(ctx, name, namespace, q.Labels)
 
 	case strings.Contains(strings.ToLower(q.Type), "kubernetesnode"):
-		pods, err = s.client.GetAllPodsForNode(q.Id, q.Labels)
+		pods, err = s.client.GetAllPodsForNode(ctx, q.Id, q.Labels)
 
 	case strings.Contains(strings.ToLower(q.Type), "kubernetesdeployment"):
-		pods, err = s.client.GetPodsForDeployment(name, namespace, q.Labels)
+		pods, err = s.client.GetPodsForDeployment(ctx, name, namespace, q.Labels)
 		resultLabels = map[string]string{
 			"deployment": q.Id,
 		}
 	case strings.Contains(strings.ToLower(q.Type), "kubernetesservice"):
-		pods, err = s.client.GetPodsForService(name, namespace, q.Labels)
+		pods, err = s.client.GetPodsForService(ctx, name, namespace, q.Labels)
 		resultLabels = map[string]string{
 			"service": q.Id,
 		}
@@ -67,15 +72,18 @@ func (s *KubernetesSearch) Search(q *logs.SearchParams) (r logs.SearchResults, e
 		return r, nil
 	}
 	logger.Tracef("[%s] searching in pods %s ", q, podNames(pods))
-	r.Results = s.getLogResultsForPods(q, pods, collections.MergeMap(s.config.CommonBackend.Labels, resultLabels))
+	r.Results = s.getLogResultsForPods(ctx, q, pods, collections.MergeMap(s.config.CommonBackend.Labels, resultLabels))
 	r.Total = len(r.Results)
 	return r, nil
 }
 
-func (s *KubernetesSearch) getLogResultsForPods(q *logs.SearchParams, pods *v1.PodList, resultLabels map[string]string) []logs.Result {
+func (s *KubernetesSearch) getLogResultsForPods(ctx context.Context, q *logs.SearchParams, pods *v1.PodList, resultLabels map[string]string) []logs.Result {
 	var results []logs.Result
 	for _, pod := range pods.Items {
-		podLogs, err := s.client.GetLogsForPod(q, pod)
+		if ctx.Err() != nil {
+			break
+		}
+		podLogs, err := s.client.GetLogsForPod(ctx, q, pod)
 		if err != nil {
 			logger.Errorf("error fetching logs for pod: %v in namespace: %v, err: ", pod.Name, pod.Namespace, err)
 			continue
@@ -0,0 +1,200 @@
+// Package link shares the searches with short links.
+// The search params are saved with an absolute time range under a short token, so that the link
+// pasted into an incident keeps showing what was seen, instead of the last hour of logs.
+package link
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/labstack/echo/v4"
+)
+
+const fileName = "links.json"
+
+// GlobalStore keeps the links.
+// It's nil when the links aren't configured.
+var GlobalStore *Store
+
+// now is replaced in the tests
+var now = time.Now
+
+// ErrNotFound is returned when resolving a token that doesn't exist
+var ErrNotFound = errors.New("link not found")
+
+// Link is a search saved under a short token
+type Link struct {
+	Token   string            `json:"token"`
+	Params  logs.SearchParams `json:"params"`
+	Author  string            `json:"author,omitempty"`
+	Tenant  string            `json:"tenant,omitempty"`
+	Created time.Time         `json:"created"`
+}
+
+// Store keeps the links in memory, and rewrites them to a json file in its directory when one is added
+type Store struct {
+	path string
+
+	lock  sync.Mutex
+	links map[string]Link
+}
+
+// Open loads the links of the directory, creating the directory if needed
+func Open(config logs.LinksConfig) (*Store, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("links path is required")
+	}
+	if err := os.MkdirAll(config.Path, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating the links directory: %w", err)
+	}
+
+	s := &Store{path: filepath.Join(config.Path, fileName), links: make(map[string]Link)}
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading the links: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.links); err != nil {
+		return nil, fmt.Errorf("error decoding the links: %w", err)
+	}
+	return s, nil
+}
+
+// Create saves the search params under a new token, with the relative time range converted to timestamps
+func (t *Store) Create(params logs.SearchParams, author, tenant string) (Link, error) {
+	token, err := newToken()
+	if err != nil {
+		return Link{}, err
+	}
+	link := Link{Token: token, Params: Freeze(params), Author: author, Tenant: tenant, Created: now().UTC()}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if _, exists := t.links[token]; exists {
+		return Link{}, fmt.Errorf("token %s already exists", token)
+	}
+
+	t.links[token] = link
+	if err := t.save(); err != nil {
+		delete(t.links, token)
+		return Link{}, err
+	}
+	return link, nil
+}
+
+// Get returns the link of the token, when it was created by the tenant
+func (t *Store) Get(token, tenant string) (Link, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	link, ok := t.links[token]
+	if !ok || link.Tenant != tenant {
+		return Link{}, ErrNotFound
+	}
+	return link, nil
+}
+
+// save writes the links to a temporary file renamed over the previous one, so that a crash doesn't lose them
+func (t *Store) save() error {
+	data, err := json.Marshal(t.links)
+	if err != nil {
+		return fmt.Errorf("error encoding the links: %w", err)
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error writing the links: %w", err)
+	}
+	if err := os.Rename(tmp, t.path); err != nil {
+		return fmt.Errorf("error writing the links: %w", err)
+	}
+	return nil
+}
+
+// Freeze returns the search params with the start and the end as RFC3339 timestamps.
+// The start defaults to an hour ago, and the end to now. The page is dropped, as it's only valid for a while.
+func Freeze(params logs.SearchParams) logs.SearchParams {
+	if params.Start == "" {
+		params.Start = "1h"
+	}
+	end := now()
+	if e := params.GetEnd(); e != nil {
+		end = *e
+	}
+	start := end.Add(-time.Hour)
+	if s := params.GetStart(); s != nil {
+		start = *s
+	}
+
+	frozen := logs.SearchParams{
+		Limit:             params.Limit,
+		LimitBytes:        params.LimitBytes,
+		Labels:            params.Labels,
+		Query:             params.Query,
+		Type:              params.Type,
+		Id:                params.Id,
+		LimitPerItem:      params.LimitPerItem,
+		LimitBytesPerItem: params.LimitBytesPerItem,
+		End:               end.UTC().Format(time.RFC3339),
+	}
+	frozen.SetStart(start.UTC())
+	return frozen
+}
+
+// newToken returns a random url safe token of 11 characters
+func newToken() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CreateHandler saves the search params of the body, and returns the link
+func CreateHandler(c echo.Context) error {
+	cc := c.(*api.Context)
+	if GlobalStore == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "links aren't configured")
+	}
+
+	var params logs.SearchParams
+	if err := c.Bind(&params); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var author string
+	if cc.User != nil {
+		author = cc.User.Name
+	}
+	link, err := GlobalStore.Create(params, author, cc.Tenant)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusCreated, link)
+}
+
+// GetHandler resolves the token to the link, whose params can be posted to /search
+func GetHandler(c echo.Context) error {
+	cc := c.(*api.Context)
+	if GlobalStore == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "links aren't configured")
+	}
+
+	link, err := GlobalStore.Get(c.Param("token"), cc.Tenant)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return c.JSON(http.StatusOK, link)
+}
@@ -0,0 +1,99 @@
+package link
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestFreeze(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name   string
+		params logs.SearchParams
+		want   logs.SearchParams
+	}{
+		{
+			name:   "absolute",
+			params: logs.SearchParams{Query: "timeout", Labels: map[string]string{"app": "api"}, Start: "2023-05-01T10:00:00+02:00", End: "2023-05-01T09:00:00Z", Page: "2", Limit: 100},
+			want:   logs.SearchParams{Query: "timeout", Labels: map[string]string{"app": "api"}, Start: "2023-05-01T08:00:00Z", End: "2023-05-01T09:00:00Z", Limit: 100},
+		},
+		{
+			name:   "no end",
+			params: logs.SearchParams{Id: "api-0", Start: "2023-05-01T11:30:00Z"},
+			want:   logs.SearchParams{Id: "api-0", Start: "2023-05-01T11:30:00Z", End: "2023-05-01T12:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Freeze(tt.params)
+			if got.Start != tt.want.Start || got.End != tt.want.End {
+				t.Errorf("Freeze() = %s - %s, want %s - %s", got.Start, got.End, tt.want.Start, tt.want.End)
+			}
+			// Freeze keeps the parsed start, as SetStart does
+			tt.want.GetStart()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Freeze() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFreeze_Relative(t *testing.T) {
+	got := Freeze(logs.SearchParams{Start: "2h"})
+	start, err := time.Parse(time.RFC3339, got.Start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end, err := time.Parse(time.RFC3339, got.End)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := end.Sub(start); d < 2*time.Hour-time.Second || d > 2*time.Hour+time.Second {
+		t.Errorf("Freeze() = %s - %s, want a 2h time range", got.Start, got.End)
+	}
+	if time.Since(end) > time.Minute {
+		t.Errorf("Freeze() ends at %s, want now", got.End)
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(logs.LinksConfig{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	link, err := s.Create(logs.SearchParams{Query: "timeout", Start: "2023-05-01T10:00:00Z", End: "2023-05-01T11:00:00Z"}, "alice", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(link.Token) != 11 {
+		t.Errorf("token %q isn't 11 characters", link.Token)
+	}
+
+	// The links are loaded back from the directory
+	s, err = Open(logs.LinksConfig{Path: dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Get(link.Token, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Params.Query != "timeout" || got.Params.Start != "2023-05-01T10:00:00Z" || got.Author != "alice" {
+		t.Errorf("Get() = %+v, want %+v", got, link)
+	}
+
+	if _, err := s.Get(link.Token, "b"); err != ErrNotFound {
+		t.Errorf("Get() of another tenant error = %v, want %v", err, ErrNotFound)
+	}
+	if _, err := s.Get("unknown", "a"); err != ErrNotFound {
+		t.Errorf("Get() of an unknown token error = %v, want %v", err, ErrNotFound)
+	}
+}
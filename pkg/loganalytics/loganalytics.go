@@ -0,0 +1,219 @@
+// Package loganalytics runs KQL queries against the Azure Monitor Log Analytics workspaces
+package loganalytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/azure"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const (
+	defaultURL             = "https://api.loganalytics.io"
+	defaultTimestampColumn = "TimeGenerated"
+	defaultMessageColumn   = "Message"
+)
+
+type LogAnalyticsSearch struct {
+	config   *logs.AzureLogAnalyticsBackendConfig
+	client   *rest.Client
+	tokens   *azure.TokenSource
+	template *template.Template
+}
+
+func NewLogAnalyticsSearchBackend(kClient *kommons.Client, config *logs.AzureLogAnalyticsBackendConfig) (*LogAnalyticsSearch, error) {
+	if config.WorkspaceID == "" {
+		return nil, fmt.Errorf("the workspace id is required")
+	}
+	if config.Query == "" {
+		return nil, fmt.Errorf("the query is required")
+	}
+	template, err := template.New("query").Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the query template: %w", err)
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	tokens, err := azure.NewTokenSource(kClient, conn.Namespace, config.Auth, strings.TrimSuffix(conn.URL, "/")+"/.default")
+	if err != nil {
+		return nil, err
+	}
+
+	return &LogAnalyticsSearch{config: config, client: client, tokens: tokens, template: template}, nil
+}
+
+// RenderQuery renders the KQL query template for the given search params, limited to the limit of the search
+func (t *LogAnalyticsSearch) RenderQuery(q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing the query template: %w", err)
+	}
+	if q.Limit > 0 {
+		fmt.Fprintf(&buf, "\n| take %d", q.Limit)
+	}
+	return buf.String(), nil
+}
+
+// Search runs the query over the time range of the search, and returns the rows of its first table
+func (t *LogAnalyticsSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	body := map[string]string{"query": query}
+	if start := q.GetStart(); start != nil {
+		end := time.Now()
+		if e := q.GetEnd(); e != nil {
+			end = *e
+		}
+		body["timespan"] = start.UTC().Format(time.RFC3339) + "/" + end.UTC().Format(time.RFC3339)
+	}
+
+	tables, err := t.query(ctx, body)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+	if len(tables) == 0 {
+		return logs.SearchResults{}, nil
+	}
+
+	results := logs.SearchResults{Total: len(tables[0].Rows)}
+	for _, row := range tables[0].Rows {
+		results.Results = append(results.Results, t.result(tables[0].Columns, row))
+	}
+	sort.SliceStable(results.Results, func(i, j int) bool { return results.Results[i].Time < results.Results[j].Time })
+	return results, nil
+}
+
+type table struct {
+	Name    string   `json:"name"`
+	Columns []column `json:"columns"`
+	Rows    [][]any  `json:"rows"`
+}
+
+type column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func (t *LogAnalyticsSearch) query(ctx context.Context, body map[string]string) ([]table, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding the query: %w", err)
+	}
+	path := "/v1/workspaces/" + url.PathEscape(t.config.WorkspaceID) + "/query"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.client.URL(path, nil), bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := t.tokens.Authorize(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		Tables []table `json:"tables"`
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&res); err != nil {
+		return nil, fmt.Errorf("error decoding the query results: %w", err)
+	}
+	return res.Tables, nil
+}
+
+// result renders a row, with the timestamp, message and id columns as the time, message and id of the result
+// and the other columns as labels
+func (t *LogAnalyticsSearch) result(columns []column, row []any) logs.Result {
+	timestampColumn := t.config.TimestampColumn
+	if timestampColumn == "" {
+		timestampColumn = defaultTimestampColumn
+	}
+	messageColumn := t.config.MessageColumn
+	if messageColumn == "" {
+		messageColumn = defaultMessageColumn
+	}
+
+	r := logs.Result{Labels: make(map[string]string, len(columns)+len(t.config.Labels))}
+	for i, c := range columns {
+		if i >= len(row) || row[i] == nil {
+			continue
+		}
+
+		var value string
+		switch v := row[i].(type) {
+		case string:
+			value = v
+		case json.Number:
+			value = v.String()
+		case bool:
+			value = strconv.FormatBool(v)
+		default:
+			data, _ := json.Marshal(v)
+			value = string(data)
+		}
+
+		switch c.Name {
+		case timestampColumn:
+			if ts, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				r.Time = ts.UTC().Format(time.RFC3339Nano)
+				continue
+			}
+		case messageColumn:
+			r.Message = value
+			continue
+		case t.config.IDColumn:
+			r.Id = value
+			continue
+		}
+		if value != "" {
+			r.Labels[c.Name] = value
+		}
+	}
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *LogAnalyticsSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Log Analytics backend, the query of the search is applied when the KQL template uses it
+func (t *LogAnalyticsSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: strings.Contains(t.config.Query, ".Query")}
+}
+
+// HealthCheck runs a query that doesn't read any table
+func (t *LogAnalyticsSearch) HealthCheck(ctx context.Context) error {
+	_, err := t.query(ctx, map[string]string{"query": "print 1"})
+	return err
+}
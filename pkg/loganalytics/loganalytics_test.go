@@ -0,0 +1,93 @@
+package loganalytics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestLogAnalyticsSearch(t *testing.T) {
+	var tokens int
+	var queries []map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/tenant/oauth2/v2.0/token":
+			_ = r.ParseForm()
+			if r.Form.Get("client_secret") != "secret" || r.Form.Get("scope") != "http://"+r.Host+"/.default" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+				return
+			}
+			tokens++
+			_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3599}`))
+		case "/v1/workspaces/ws/query":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			queries = append(queries, body)
+			_, _ = w.Write([]byte(`{"tables":[{"name":"PrimaryResult","columns":[
+				{"name":"TimeGenerated","type":"datetime"},{"name":"LogMessage","type":"dynamic"},{"name":"PodName","type":"string"},{"name":"ContainerName","type":"string"},{"name":"Count","type":"long"}
+			],"rows":[
+				["2023-05-01T12:00:01.5Z","connection timeout","api-0",null,2],
+				["2023-05-01T12:00:00Z",{"level":"info"},"api-1","api",1]
+			]}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewLogAnalyticsSearchBackend(nil, &logs.AzureLogAnalyticsBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "azure"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		Auth: logs.AzureAuthentication{
+			TenantID:     "tenant",
+			ClientID:     &kommons.EnvVar{Value: "client"},
+			ClientSecret: &kommons.EnvVar{Value: "secret"},
+			AuthorityURL: server.URL,
+		},
+		WorkspaceID:   "ws",
+		Query:         `ContainerLogV2 | where LogMessage contains "{{.Query}}"`,
+		MessageColumn: "LogMessage",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "timeout", Start: "2023-05-01T11:00:00Z", End: "2023-05-01T12:30:00Z", Limit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:00Z", Message: `{"level":"info"}`, Labels: map[string]string{"PodName": "api-1", "ContainerName": "api", "Count": "1", "source": "azure"}},
+		{Time: "2023-05-01T12:00:01.5Z", Message: "connection timeout", Labels: map[string]string{"PodName": "api-0", "Count": "2", "source": "azure"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantQuery := map[string]string{
+		"query":    "ContainerLogV2 | where LogMessage contains \"timeout\"\n| take 50",
+		"timespan": "2023-05-01T11:00:00Z/2023-05-01T12:30:00Z",
+	}
+	if len(queries) != 1 || !reflect.DeepEqual(queries[0], wantQuery) {
+		t.Errorf("queries = %v, want %v", queries, wantQuery)
+	}
+
+	// The token is reused until it expires
+	if err := backend.HealthCheck(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if tokens != 1 {
+		t.Errorf("requested %d tokens, want 1", tokens)
+	}
+}
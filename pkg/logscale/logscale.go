@@ -0,0 +1,233 @@
+// Package logscale searches the repositories of Falcon LogScale, formerly Humio, with the Query Jobs api
+package logscale
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const defaultURL = "https://cloud.humio.com"
+
+// pollInterval is the interval between the polls of the query jobs, when LogScale doesn't tell when to poll again
+var pollInterval = time.Second
+
+type LogScaleSearch struct {
+	config     *logs.LogScaleBackendConfig
+	client     *rest.Client
+	repository *template.Template
+	query      *template.Template
+}
+
+func NewLogScaleSearchBackend(kClient *kommons.Client, config *logs.LogScaleBackendConfig) (*LogScaleSearch, error) {
+	if config.Repository == "" {
+		return nil, fmt.Errorf("the repository is required")
+	}
+	repository, err := template.New("repository").Parse(config.Repository)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the repository template: %w", err)
+	}
+	query, err := template.New("query").Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the query template: %w", err)
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &LogScaleSearch{config: config, client: client, repository: repository, query: query}, nil
+}
+
+// RenderQuery renders the query template, with the query of the search piped after it when the template doesn't use it
+func (t *LogScaleSearch) RenderQuery(q *logs.SearchParams) (string, error) {
+	if t.config.Query == "" {
+		return q.Query, nil
+	}
+
+	var buf bytes.Buffer
+	if err := t.query.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing the query template: %w", err)
+	}
+	if q.Query != "" && !strings.Contains(t.config.Query, ".Query") {
+		buf.WriteString(" | " + q.Query)
+	}
+	return buf.String(), nil
+}
+
+// Search starts a query job over the time range of the search, and polls it until it's done.
+// The most recent events are returned, up to the limit.
+func (t *LogScaleSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var repository bytes.Buffer
+	if err := t.repository.Execute(&repository, q); err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error executing the repository template: %w", err)
+	}
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	job := map[string]any{"queryString": query, "isLive": false}
+	if start := q.GetStart(); start != nil {
+		job["start"] = start.UnixMilli()
+	}
+	if end := q.GetEnd(); end != nil {
+		job["end"] = end.UnixMilli()
+	}
+
+	path := "/api/v1/repositories/" + url.PathEscape(repository.String()) + "/queryjobs"
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := t.client.Post(ctx, path, nil, job, &created); err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error starting the query job: %w", err)
+	}
+
+	events, err := t.poll(ctx, path+"/"+url.PathEscape(created.ID))
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	results := logs.SearchResults{Total: len(events)}
+	for _, event := range events {
+		results.Results = append(results.Results, t.result(event))
+	}
+	sort.SliceStable(results.Results, func(i, j int) bool { return results.Results[i].Time < results.Results[j].Time })
+	if q.Limit > 0 && int64(len(results.Results)) > q.Limit {
+		results.Results = results.Results[int64(len(results.Results))-q.Limit:]
+	}
+	return results, nil
+}
+
+// poll gets the query job until it's done, and deletes it when the context is done before
+func (t *LogScaleSearch) poll(ctx context.Context, path string) ([]map[string]any, error) {
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL(path, nil), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		resp, err := t.client.Do(req)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, t.cancel(ctx, path)
+			}
+			return nil, fmt.Errorf("error polling the query job: %w", err)
+		}
+
+		var job struct {
+			Done      bool             `json:"done"`
+			Cancelled bool             `json:"cancelled"`
+			Events    []map[string]any `json:"events"`
+			MetaData  struct {
+				PollAfter int64 `json:"pollAfter"`
+			} `json:"metaData"`
+		}
+		decoder := json.NewDecoder(resp.Body)
+		decoder.UseNumber()
+		err = decoder.Decode(&job)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error decoding the query job: %w", err)
+		}
+
+		switch {
+		case job.Cancelled:
+			return nil, fmt.Errorf("the query job was cancelled")
+		case job.Done:
+			return job.Events, nil
+		}
+
+		wait := pollInterval
+		if job.MetaData.PollAfter > 0 {
+			wait = time.Duration(job.MetaData.PollAfter) * time.Millisecond
+		}
+		select {
+		case <-ctx.Done():
+			return nil, t.cancel(ctx, path)
+		case <-time.After(wait):
+		}
+	}
+}
+
+// cancel deletes the query job, as it keeps running in LogScale otherwise, and returns the error of the context
+func (t *LogScaleSearch) cancel(ctx context.Context, path string) error {
+	if req, err := http.NewRequest(http.MethodDelete, t.client.URL(path, nil), nil); err == nil {
+		if resp, err := t.client.Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}
+	return ctx.Err()
+}
+
+// result renders the event with its raw string as message. The fields of the event are labels,
+// the tags without their # prefix, and the metadata fields prefixed with @ are left out.
+func (t *LogScaleSearch) result(event map[string]any) logs.Result {
+	r := logs.Result{Labels: make(map[string]string, len(event)+len(t.config.Labels))}
+	for field, value := range event {
+		var v string
+		switch value := value.(type) {
+		case nil:
+			continue
+		case string:
+			v = value
+		case json.Number:
+			v = value.String()
+		case bool:
+			v = strconv.FormatBool(value)
+		default:
+			data, _ := json.Marshal(value)
+			v = string(data)
+		}
+
+		switch {
+		case field == "@timestamp":
+			if ms, err := strconv.ParseInt(v, 10, 64); err == nil {
+				r.Time = time.UnixMilli(ms).UTC().Format(time.RFC3339Nano)
+			}
+		case field == "@rawstring":
+			r.Message = v
+		case field == "@id":
+			r.Id = v
+		case strings.HasPrefix(field, "@"):
+		case strings.HasPrefix(field, "#"):
+			r.Labels[strings.TrimPrefix(field, "#")] = v
+		default:
+			r.Labels[field] = v
+		}
+	}
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *LogScaleSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the LogScale backend, the query of the search is sent as LogScale query language
+func (t *LogScaleSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true}
+}
+
+// HealthCheck gets the status of the LogScale cluster
+func (t *LogScaleSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/v1/status", nil, nil)
+}
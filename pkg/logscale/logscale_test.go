@@ -0,0 +1,104 @@
+package logscale
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestLogScaleSearch_RenderQuery(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		query    string
+		want     string
+	}{
+		{name: "pass-through", query: `#kind=error | count()`, want: `#kind=error | count()`},
+		{name: "template", template: `#namespace={{index .Labels "namespace"}}`, want: `#namespace=shop`},
+		{name: "piped", template: `#namespace={{index .Labels "namespace"}}`, query: `timeout`, want: `#namespace=shop | timeout`},
+		{name: "template with the query", template: `#namespace=shop "{{.Query}}"`, query: `timeout`, want: `#namespace=shop "timeout"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := NewLogScaleSearchBackend(nil, &logs.LogScaleBackendConfig{Repository: "logs", Query: tt.template})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := backend.RenderQuery(&logs.SearchParams{Query: tt.query, Labels: map[string]string{"namespace": "shop"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("RenderQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogScaleSearch(t *testing.T) {
+	defer func() { pollInterval = time.Second }()
+	pollInterval = time.Millisecond
+
+	var job map[string]any
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v1/repositories/logs-prod/queryjobs":
+			_ = json.NewDecoder(r.Body).Decode(&job)
+			_, _ = w.Write([]byte(`{"id":"P1-abc"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/repositories/logs-prod/queryjobs/P1-abc":
+			if polls++; polls == 1 {
+				_, _ = w.Write([]byte(`{"done":false,"events":[],"metaData":{"pollAfter":1}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"done":true,"events":[
+				{"@timestamp":1682942401500,"@rawstring":"connection timeout","@id":"e2","@timezone":"Z","#repo":"logs-prod","pod":"api-0","status":504},
+				{"@timestamp":1682942400000,"@rawstring":"GET /","@id":"e1","pod":"api-1"},
+				{"@timestamp":1682942399000,"@rawstring":"started","@id":"e0","pod":"api-1"}
+			],"metaData":{"eventCount":3}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewLogScaleSearchBackend(nil, &logs.LogScaleBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "logscale"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Token: &kommons.EnvVar{Value: "secret"}},
+		Repository:     `logs-{{index .Labels "cluster"}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query: "timeout OR GET", Labels: map[string]string{"cluster": "prod"}, Start: "2023-05-01T11:00:00Z", End: "2023-05-01T12:00:00Z", Limit: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 3, Results: []logs.Result{
+		{Id: "e1", Time: "2023-05-01T12:00:00Z", Message: "GET /", Labels: map[string]string{"pod": "api-1", "source": "logscale"}},
+		{Id: "e2", Time: "2023-05-01T12:00:01.5Z", Message: "connection timeout", Labels: map[string]string{"repo": "logs-prod", "pod": "api-0", "status": "504", "source": "logscale"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantJob := map[string]any{"queryString": "timeout OR GET", "isLive": false, "start": float64(1682938800000), "end": float64(1682942400000)}
+	if !reflect.DeepEqual(job, wantJob) {
+		t.Errorf("query job = %v, want %v", job, wantJob)
+	}
+}
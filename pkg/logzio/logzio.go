@@ -0,0 +1,234 @@
+// Package logzio searches the logs of Logz.io with its Elasticsearch compatible search api
+package logzio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	pkgElasticsearch "github.com/flanksource/apm-hub/external/elasticsearch"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const (
+	defaultMessageField   = "message"
+	defaultTimestampField = "@timestamp"
+
+	// accountLabel is the label of the searches selecting the sub account searched
+	accountLabel = "account"
+)
+
+// hosts are the hosts of the api of the Logz.io regions
+var hosts = map[string]string{
+	"us": "api.logz.io",
+	"eu": "api-eu.logz.io",
+	"uk": "api-uk.logz.io",
+	"au": "api-au.logz.io",
+	"ca": "api-ca.logz.io",
+	"wa": "api-wa.logz.io",
+}
+
+type LogzioSearch struct {
+	config   *logs.LogzioBackendConfig
+	client   *rest.Client
+	template *template.Template
+	fields   logs.ElasticSearchFields
+	token    string
+	accounts map[string]string
+}
+
+func NewLogzioSearchBackend(kClient *kommons.Client, config *logs.LogzioBackendConfig) (*LogzioSearch, error) {
+	template, err := template.New("query").Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the query template: %w", err)
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		region := strings.ToLower(config.Region)
+		if region == "" {
+			region = "us"
+		}
+		host, ok := hosts[region]
+		if !ok {
+			return nil, fmt.Errorf("unknown region %s", config.Region)
+		}
+		conn.URL = "https://" + host
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &LogzioSearch{config: config, client: client, template: template, fields: config.Fields, accounts: make(map[string]string, len(config.Accounts))}
+	if t.fields.Message == "" {
+		t.fields.Message = defaultMessageField
+	}
+	if t.fields.Timestamp == "" {
+		t.fields.Timestamp = defaultTimestampField
+	}
+
+	if config.APIToken != nil {
+		if _, t.token, err = kClient.GetEnvValue(*config.APIToken, conn.Namespace); err != nil {
+			return nil, fmt.Errorf("error getting the api token: %w", err)
+		}
+	}
+	for account, token := range config.Accounts {
+		_, value, err := kClient.GetEnvValue(token, conn.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the api token of the account %s: %w", account, err)
+		}
+		t.accounts[account] = value
+	}
+	if t.token == "" && len(t.accounts) == 0 {
+		return nil, fmt.Errorf("the api token is required")
+	}
+	return t, nil
+}
+
+// RenderQuery renders the search body, limited to one more hit than the limit of the search to tell if there's a next page
+func (t *LogzioSearch) RenderQuery(q *logs.SearchParams) (string, error) {
+	body := make(map[string]any)
+	if t.config.Query != "" {
+		var buf bytes.Buffer
+		if err := t.template.Execute(&buf, q); err != nil {
+			return "", fmt.Errorf("error executing the query template: %w", err)
+		}
+		if err := json.Unmarshal(buf.Bytes(), &body); err != nil {
+			return "", fmt.Errorf("error parsing the query: %w", err)
+		}
+	} else if err := t.defaultQuery(q, body); err != nil {
+		return "", err
+	}
+
+	body["size"] = q.Limit + 1
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("error encoding the query: %w", err)
+	}
+	return string(data), nil
+}
+
+// defaultQuery searches the query of the search over its time range, newest first
+func (t *LogzioSearch) defaultQuery(q *logs.SearchParams, body map[string]any) error {
+	var must, filter []any
+	if q.Query != "" {
+		must = append(must, map[string]any{"query_string": map[string]any{"query": q.Query}})
+	}
+	timeRange := map[string]any{"format": "strict_date_optional_time"}
+	if start := q.GetStart(); start != nil {
+		timeRange["gte"] = start.UTC().Format(time.RFC3339Nano)
+	}
+	if end := q.GetEnd(); end != nil {
+		timeRange["lte"] = end.UTC().Format(time.RFC3339Nano)
+	}
+	if len(timeRange) > 1 {
+		filter = append(filter, map[string]any{"range": map[string]any{t.fields.Timestamp: timeRange}})
+	}
+
+	boolQuery := make(map[string]any)
+	if len(must) > 0 {
+		boolQuery["must"] = must
+	}
+	if len(filter) > 0 {
+		boolQuery["filter"] = filter
+	}
+	body["query"] = map[string]any{"bool": boolQuery}
+	body["sort"] = []any{map[string]any{t.fields.Timestamp: map[string]any{"order": "desc"}}}
+
+	if q.Page != "" {
+		var searchAfter []any
+		if err := json.Unmarshal([]byte(q.Page), &searchAfter); err != nil {
+			return fmt.Errorf("error parsing the page: %w", err)
+		}
+		body["search_after"] = searchAfter
+	}
+	return nil
+}
+
+// accountToken returns the api token of the account of the search, or of the main account without an account label
+func (t *LogzioSearch) accountToken(q *logs.SearchParams) (string, error) {
+	account, ok := q.Labels[accountLabel]
+	if !ok {
+		if t.token == "" {
+			return "", fmt.Errorf("the %s label is required, as no api token is configured for the main account", accountLabel)
+		}
+		return t.token, nil
+	}
+	token, ok := t.accounts[account]
+	if !ok {
+		return "", fmt.Errorf("unknown account %s", account)
+	}
+	return token, nil
+}
+
+func (t *LogzioSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var result logs.SearchResults
+	token, err := t.accountToken(q)
+	if err != nil {
+		return result, err
+	}
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return result, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.client.URL("/v1/search", nil), strings.NewReader(query))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-TOKEN", token)
+
+	var r pkgElasticsearch.SearchResponse
+	if err := t.client.DoJSON(req, &r); err != nil {
+		return result, fmt.Errorf("error searching: %w", err)
+	}
+
+	labels := t.config.Labels
+	if account, ok := q.Labels[accountLabel]; ok {
+		labels = make(map[string]string, len(t.config.Labels)+1)
+		for k, v := range t.config.Labels {
+			labels[k] = v
+		}
+		labels[accountLabel] = account
+	}
+	result.Results = r.Hits.GetResultsFromHits(q.Limit, t.fields.Message, t.fields.Timestamp, labels, t.fields.Exclusions...)
+	result.Total = int(r.Hits.Total.Value)
+	result.NextPage = r.Hits.NextPage(int(q.Limit))
+	return result, nil
+}
+
+func (t *LogzioSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Logz.io backend. The default query searches the query of the search as lucene,
+// and the pages follow the sort values of the last hit.
+func (t *LogzioSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: t.config.Query == "" || strings.Contains(t.config.Query, ".Query"), Pagination: true}
+}
+
+// HealthCheck gets the account of the api token
+func (t *LogzioSearch) HealthCheck(ctx context.Context) error {
+	token := t.token
+	if token == "" {
+		for _, accountToken := range t.accounts {
+			token = accountToken
+			break
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL("/v1/whoami", nil), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-API-TOKEN", token)
+	return t.client.DoJSON(req, nil)
+}
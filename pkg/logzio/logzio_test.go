@@ -0,0 +1,102 @@
+package logzio
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestLogzioSearch(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/search" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		switch r.Header.Get("X-API-TOKEN") {
+		case "main":
+			_, _ = w.Write([]byte(`{"hits":{"total":{"value":0},"hits":[]}}`))
+		case "shop":
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			_, _ = w.Write([]byte(`{"hits":{"total":{"value":5},"hits":[
+				{"_id":"h3","sort":[1682942402000],"_source":{"@timestamp":"2023-05-01T12:00:02Z","message":"connection timeout","kubernetes":{"pod":"api-0"}}},
+				{"_id":"h2","sort":[1682942401000],"_source":{"@timestamp":"2023-05-01T12:00:01Z","message":"GET /","kubernetes":{"pod":"api-1"}}},
+				{"_id":"h1","sort":[1682942400000],"_source":{"@timestamp":"2023-05-01T12:00:00Z","message":"started","kubernetes":{"pod":"api-1"}}}
+			]}}`))
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewLogzioSearchBackend(nil, &logs.LogzioBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "logzio"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		APIToken:       &kommons.EnvVar{Value: "main"},
+		Accounts:       map[string]kommons.EnvVar{"shop": {Value: "shop"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query: "timeout OR GET", Labels: map[string]string{"account": "shop"}, Start: "2023-05-01T11:00:00Z", End: "2023-05-01T12:00:00Z", Limit: 2, Page: "[1682942403000]",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 5, NextPage: "[1682942401000]", Results: []logs.Result{
+		{Id: "h3", Time: "2023-05-01T12:00:02Z", Message: "connection timeout", Labels: map[string]string{"kubernetes.pod": "api-0", "account": "shop", "source": "logzio"}},
+		{Id: "h2", Time: "2023-05-01T12:00:01Z", Message: "GET /", Labels: map[string]string{"kubernetes.pod": "api-1", "account": "shop", "source": "logzio"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantBody := map[string]any{
+		"size": float64(3),
+		"query": map[string]any{"bool": map[string]any{
+			"must": []any{map[string]any{"query_string": map[string]any{"query": "timeout OR GET"}}},
+			"filter": []any{map[string]any{"range": map[string]any{"@timestamp": map[string]any{
+				"gte": "2023-05-01T11:00:00Z", "lte": "2023-05-01T12:00:00Z", "format": "strict_date_optional_time",
+			}}}},
+		}},
+		"sort":         []any{map[string]any{"@timestamp": map[string]any{"order": "desc"}}},
+		"search_after": []any{float64(1682942403000)},
+	}
+	if !reflect.DeepEqual(body, wantBody) {
+		t.Errorf("query = %v, want %v", body, wantBody)
+	}
+
+	// The searches without an account label search the main account
+	if res, err := backend.Search(context.Background(), &logs.SearchParams{Limit: 2}); err != nil || res.Total != 0 {
+		t.Errorf("Search() = %+v, %v", res, err)
+	}
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Labels: map[string]string{"account": "billing"}}); err == nil {
+		t.Errorf("Search() of an unknown account didn't fail")
+	}
+}
+
+func TestLogzioSearch_RenderQuery(t *testing.T) {
+	backend, err := NewLogzioSearchBackend(nil, &logs.LogzioBackendConfig{
+		HTTPConnection: logs.HTTPConnection{URL: "http://localhost"},
+		APIToken:       &kommons.EnvVar{Value: "main"},
+		Query:          `{"query": {"match_phrase": {"kubernetes.namespace": "{{index .Labels "namespace"}}"}}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := backend.RenderQuery(&logs.SearchParams{Labels: map[string]string{"namespace": "shop"}, Limit: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"query":{"match_phrase":{"kubernetes.namespace":"shop"}},"size":51}`; got != want {
+		t.Errorf("RenderQuery() = %s, want %s", got, want)
+	}
+}
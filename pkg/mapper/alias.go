@@ -0,0 +1,60 @@
+package mapper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// AliasMapper replaces the searches of an alias with the searches of its targets
+type AliasMapper struct {
+	aliases []logs.SearchAlias
+}
+
+func NewAliasMapper(aliases []logs.SearchAlias) (*AliasMapper, error) {
+	for i, alias := range aliases {
+		if alias.Id == "" {
+			return nil, fmt.Errorf("alias[%d] has no id", i)
+		}
+		if len(alias.Targets) == 0 {
+			return nil, fmt.Errorf("alias %s has no targets", alias.Id)
+		}
+	}
+	return &AliasMapper{aliases: aliases}, nil
+}
+
+// MapSearchParams implements logs.SearchMapper.
+// The labels of the search take precedence over the labels of the targets.
+func (t *AliasMapper) MapSearchParams(ctx context.Context, p *logs.SearchParams) ([]logs.SearchParams, error) {
+	for _, alias := range t.aliases {
+		if alias.Id != p.Id || (alias.Type != "" && alias.Type != p.Type) {
+			continue
+		}
+
+		searches := make([]logs.SearchParams, 0, len(alias.Targets))
+		for _, target := range alias.Targets {
+			q := *p
+			if target.Type != "" {
+				q.Type = target.Type
+			}
+			if target.Id != "" {
+				q.Id = target.Id
+			}
+
+			if len(target.Labels) > 0 {
+				q.Labels = make(map[string]string, len(target.Labels)+len(p.Labels))
+				for k, v := range target.Labels {
+					q.Labels[k] = v
+				}
+				for k, v := range p.Labels {
+					q.Labels[k] = v
+				}
+			}
+			searches = append(searches, q)
+		}
+		return searches, nil
+	}
+
+	return []logs.SearchParams{*p}, nil
+}
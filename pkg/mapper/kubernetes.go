@@ -0,0 +1,142 @@
+package mapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// The workload types expanded by default
+var workloadTypes = []string{
+	"KubernetesDeployment",
+	"KubernetesStatefulSet",
+	"KubernetesDaemonSet",
+	"KubernetesReplicaSet",
+	"KubernetesJob",
+}
+
+// KubernetesMapper expands the searches of the workloads into the searches of their pods
+type KubernetesMapper struct {
+	client kubernetes.Interface
+	types  map[string]bool
+}
+
+func NewKubernetesMapper(kClient *kommons.Client, config logs.KubernetesMapperConfig) (*KubernetesMapper, error) {
+	if config.Kubeconfig != nil {
+		if kClient == nil {
+			return nil, fmt.Errorf("default client is nil and kubeconfig is not set")
+		}
+		_, value, err := kClient.GetEnvValue(*config.Kubeconfig, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the kubeconfig: %w", err)
+		}
+		if kClient, err = kommons.NewClientFromBytes([]byte(value)); err != nil {
+			return nil, fmt.Errorf("error creating the kubernetes client: %w", err)
+		}
+	}
+	if kClient == nil {
+		return nil, fmt.Errorf("kubernetes client is not configured")
+	}
+
+	clientset, err := kClient.GetClientset()
+	if err != nil {
+		return nil, fmt.Errorf("error creating the kubernetes clientset: %w", err)
+	}
+	return newKubernetesMapper(clientset, config.Types), nil
+}
+
+func newKubernetesMapper(client kubernetes.Interface, types []string) *KubernetesMapper {
+	if len(types) == 0 {
+		types = workloadTypes
+	}
+
+	t := &KubernetesMapper{client: client, types: make(map[string]bool, len(types))}
+	for _, searchType := range types {
+		t.types[searchType] = true
+	}
+	return t
+}
+
+// MapSearchParams implements logs.SearchMapper.
+// The workloads without pods and the ids that aren't <namespace>/<name> are searched as is.
+func (t *KubernetesMapper) MapSearchParams(ctx context.Context, p *logs.SearchParams) ([]logs.SearchParams, error) {
+	namespace, name, ok := strings.Cut(p.Id, "/")
+	if !t.types[p.Type] || !ok {
+		return []logs.SearchParams{*p}, nil
+	}
+
+	selector, err := t.selector(ctx, p.Type, namespace, name)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the selector of %s: %w", p.Id, err)
+	}
+
+	pods, err := t.client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, fmt.Errorf("error listing the pods of %s: %w", p.Id, err)
+	}
+	if len(pods.Items) == 0 {
+		return []logs.SearchParams{*p}, nil
+	}
+
+	searches := make([]logs.SearchParams, 0, len(pods.Items))
+	for _, pod := range pods.Items {
+		q := *p
+		q.Type = "KubernetesPod"
+		q.Id = namespace + "/" + pod.Name
+		searches = append(searches, q)
+	}
+	return searches, nil
+}
+
+// selector returns the pod selector of the workload
+func (t *KubernetesMapper) selector(ctx context.Context, searchType, namespace, name string) (string, error) {
+	var selector *metav1.LabelSelector
+	switch strings.TrimPrefix(searchType, "Kubernetes") {
+	case "Deployment":
+		workload, err := t.client.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = workload.Spec.Selector
+	case "StatefulSet":
+		workload, err := t.client.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = workload.Spec.Selector
+	case "DaemonSet":
+		workload, err := t.client.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = workload.Spec.Selector
+	case "ReplicaSet":
+		workload, err := t.client.AppsV1().ReplicaSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = workload.Spec.Selector
+	case "Job":
+		workload, err := t.client.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return "", err
+		}
+		selector = workload.Spec.Selector
+	default:
+		return "", fmt.Errorf("unsupported workload type %s", searchType)
+	}
+
+	if selector == nil {
+		return "", fmt.Errorf("the workload has no selector")
+	}
+	parsed, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return "", err
+	}
+	return parsed.String(), nil
+}
@@ -0,0 +1,83 @@
+package mapper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func pod(namespace, name string, labels map[string]string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+}
+
+func TestKubernetesMapper(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "postgres"},
+			Spec:       appsv1.StatefulSetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "postgres"}}},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "db", Name: "idle"},
+			Spec:       appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "idle"}}},
+		},
+		pod("db", "postgres-0", map[string]string{"app": "postgres"}),
+		pod("db", "postgres-1", map[string]string{"app": "postgres"}),
+		pod("db", "pgbouncer", map[string]string{"app": "pgbouncer"}),
+		pod("other", "postgres-0", map[string]string{"app": "postgres"}),
+	)
+	mapper := newKubernetesMapper(client, nil)
+
+	tests := []struct {
+		name    string
+		params  logs.SearchParams
+		want    []logs.SearchParams
+		wantErr bool
+	}{
+		{
+			name:   "statefulset",
+			params: logs.SearchParams{Type: "KubernetesStatefulSet", Id: "db/postgres", Query: "error"},
+			want: []logs.SearchParams{
+				{Type: "KubernetesPod", Id: "db/postgres-0", Query: "error"},
+				{Type: "KubernetesPod", Id: "db/postgres-1", Query: "error"},
+			},
+		},
+		{
+			name:   "without pods",
+			params: logs.SearchParams{Type: "KubernetesDeployment", Id: "db/idle"},
+			want:   []logs.SearchParams{{Type: "KubernetesDeployment", Id: "db/idle"}},
+		},
+		{
+			name:   "not a workload",
+			params: logs.SearchParams{Type: "KubernetesPod", Id: "db/postgres-0"},
+			want:   []logs.SearchParams{{Type: "KubernetesPod", Id: "db/postgres-0"}},
+		},
+		{
+			name:   "without namespace",
+			params: logs.SearchParams{Type: "KubernetesStatefulSet", Id: "postgres"},
+			want:   []logs.SearchParams{{Type: "KubernetesStatefulSet", Id: "postgres"}},
+		},
+		{
+			name:    "missing workload",
+			params:  logs.SearchParams{Type: "KubernetesDaemonSet", Id: "db/missing"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mapper.MapSearchParams(context.Background(), &tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MapSearchParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MapSearchParams() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Package mapper expands the logical searches, e.g. of a workload or an alias,
+// into the searches of their concrete sources before they're routed to the backends.
+package mapper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/configdb"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/kommons"
+)
+
+// MaxSearches is the maximum number of searches a search is expanded into
+const MaxSearches = 100
+
+// GlobalChain is empty when no mappers are configured
+var GlobalChain Chain
+
+// Chain applies the mappers in order, every search returned by a mapper going through the next ones
+type Chain []logs.SearchMapper
+
+func NewChain(kClient *kommons.Client, configs []logs.MapperConfig, lookup configdb.LookupFunc) (Chain, error) {
+	var chain Chain
+	for i, config := range configs {
+		mapper, err := newMapper(kClient, config, lookup)
+		if err != nil {
+			return nil, fmt.Errorf("error creating mapper[%d]: %w", i, err)
+		}
+		chain = append(chain, mapper)
+	}
+	return chain, nil
+}
+
+func newMapper(kClient *kommons.Client, config logs.MapperConfig, lookup configdb.LookupFunc) (logs.SearchMapper, error) {
+	switch {
+	case config.ConfigDB != nil:
+		return configdb.NewResolver(*config.ConfigDB, lookup)
+	case config.Kubernetes != nil:
+		return NewKubernetesMapper(kClient, *config.Kubernetes)
+	case len(config.Aliases) > 0:
+		return NewAliasMapper(config.Aliases)
+	}
+	return nil, fmt.Errorf("no mapper configured")
+}
+
+// Map returns the searches the search params expand to.
+// The searches a mapper fails to expand are kept unchanged.
+func (t Chain) Map(ctx context.Context, q logs.SearchParams) []logs.SearchParams {
+	searches := []logs.SearchParams{q}
+	for _, mapper := range t {
+		var mapped []logs.SearchParams
+		for i := range searches {
+			expanded, err := mapper.MapSearchParams(ctx, &searches[i])
+			if err != nil {
+				logger.Errorf("error mapping the search %s: %v", searches[i], err)
+				expanded = searches[i : i+1]
+			}
+			mapped = append(mapped, expanded...)
+		}
+
+		if len(mapped) > MaxSearches {
+			logger.Warnf("the search %s expands to %d searches, only the first %d are kept", q, len(mapped), MaxSearches)
+			mapped = mapped[:MaxSearches]
+		}
+		searches = mapped
+	}
+	return searches
+}
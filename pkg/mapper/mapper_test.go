@@ -0,0 +1,94 @@
+package mapper
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+type failingMapper struct{}
+
+func (failingMapper) MapSearchParams(ctx context.Context, p *logs.SearchParams) ([]logs.SearchParams, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestChain_Map(t *testing.T) {
+	aliases, err := NewAliasMapper([]logs.SearchAlias{
+		{
+			Id: "checkout",
+			Targets: []logs.SearchAliasTarget{
+				{Type: "KubernetesDeployment", Id: "shop/checkout"},
+				{Type: "VM", Id: "checkout-db", Labels: map[string]string{"role": "db", "env": "prod"}},
+			},
+		},
+		{Id: "payments", Type: "Service", Targets: []logs.SearchAliasTarget{{Id: "shop/payments"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		chain  Chain
+		params logs.SearchParams
+		want   []logs.SearchParams
+	}{
+		{
+			name:   "empty chain",
+			params: logs.SearchParams{Id: "checkout"},
+			want:   []logs.SearchParams{{Id: "checkout"}},
+		},
+		{
+			name:   "alias",
+			chain:  Chain{aliases},
+			params: logs.SearchParams{Id: "checkout", Query: "error", Labels: map[string]string{"env": "staging"}},
+			want: []logs.SearchParams{
+				{Type: "KubernetesDeployment", Id: "shop/checkout", Query: "error", Labels: map[string]string{"env": "staging"}},
+				{Type: "VM", Id: "checkout-db", Query: "error", Labels: map[string]string{"role": "db", "env": "staging"}},
+			},
+		},
+		{
+			name:   "alias of another type",
+			chain:  Chain{aliases},
+			params: logs.SearchParams{Type: "VM", Id: "payments"},
+			want:   []logs.SearchParams{{Type: "VM", Id: "payments"}},
+		},
+		{
+			name:   "alias keeps the type",
+			chain:  Chain{aliases},
+			params: logs.SearchParams{Type: "Service", Id: "payments"},
+			want:   []logs.SearchParams{{Type: "Service", Id: "shop/payments"}},
+		},
+		{
+			name:   "failing mapper",
+			chain:  Chain{failingMapper{}, aliases},
+			params: logs.SearchParams{Id: "checkout"},
+			want: []logs.SearchParams{
+				{Type: "KubernetesDeployment", Id: "shop/checkout"},
+				{Type: "VM", Id: "checkout-db", Labels: map[string]string{"role": "db", "env": "prod"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.chain.Map(context.Background(), tt.params); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Map() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAliasMapper(t *testing.T) {
+	for _, aliases := range [][]logs.SearchAlias{
+		{{Targets: []logs.SearchAliasTarget{{Id: "a"}}}},
+		{{Id: "a"}},
+	} {
+		if _, err := NewAliasMapper(aliases); err == nil {
+			t.Errorf("NewAliasMapper(%+v) expected an error", aliases)
+		}
+	}
+}
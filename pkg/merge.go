@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"sort"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// mergeResults collates the results of the backends in the canonical order, so that
+// identical searches return identically ordered pages:
+//
+//   - chronologically, the results without a valid RFC3339 timestamp first
+//   - then by the name of the backend they come from
+//   - then by id and finally by message, for the backends that don't provide ids
+//
+// Results equal on all of the above keep the order the backend returned them in.
+func mergeResults(backendResults []backendResult) logs.SearchResults {
+	type keyed struct {
+		time    time.Time
+		backend string
+		result  logs.Result
+	}
+
+	var merged logs.SearchResults
+	var entries []keyed
+	for _, backendResult := range backendResults {
+		for _, r := range backendResult.Results {
+			ts, _ := time.Parse(time.RFC3339Nano, r.Time)
+			entries = append(entries, keyed{time: ts, backend: backendResult.Backend, result: r})
+		}
+		merged.Total += backendResult.Total
+		merged.NextPage = backendResult.NextPage
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if !a.time.Equal(b.time) {
+			return a.time.Before(b.time)
+		}
+		if a.backend != b.backend {
+			return a.backend < b.backend
+		}
+		if a.result.Id != b.result.Id {
+			return a.result.Id < b.result.Id
+		}
+		return a.result.Message < b.result.Message
+	})
+
+	if len(entries) > 0 {
+		merged.Results = make([]logs.Result, len(entries))
+		for i, e := range entries {
+			merged.Results[i] = e.result
+		}
+	}
+	return merged
+}
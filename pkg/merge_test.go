@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestMergeResults(t *testing.T) {
+	backendResults := []backendResult{
+		{Backend: "loki", SearchResults: logs.SearchResults{Total: 3, NextPage: "2", Results: []logs.Result{
+			{Id: "b", Time: "2023-01-01T00:00:02Z", Message: "loki b"},
+			{Id: "a", Time: "2023-01-01T00:00:02Z", Message: "loki a"},
+			{Time: "2023-01-01T00:00:01Z", Message: "loki"},
+		}}},
+		{Backend: "elastic", SearchResults: logs.SearchResults{Total: 2, Results: []logs.Result{
+			{Id: "z", Time: "2023-01-01T01:00:02+01:00", Message: "elastic"},
+			{Message: "no timestamp"},
+		}}},
+		{Backend: "failed"},
+	}
+
+	want := []string{"no timestamp", "loki", "elastic", "loki a", "loki b"}
+
+	merged := mergeResults(backendResults)
+	var got []string
+	for _, r := range merged.Results {
+		got = append(got, r.Message)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeResults() = %v, want %v", got, want)
+	}
+	if merged.Total != 5 {
+		t.Errorf("mergeResults() total = %d, want 5", merged.Total)
+	}
+
+	// The order doesn't depend on the order of the backends
+	reversed := mergeResults([]backendResult{backendResults[2], backendResults[1], backendResults[0]})
+	if !reflect.DeepEqual(reversed.Results, merged.Results) {
+		t.Errorf("mergeResults() depends on the order of the backends: %v", reversed.Results)
+	}
+}
@@ -0,0 +1,156 @@
+package missioncontrol
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+// GlobalClient attaches the search snapshots to the incidents.
+// It's nil when Mission Control isn't configured.
+var GlobalClient *Client
+
+// Snapshot is the state of a search at a point in time
+type Snapshot struct {
+	Incident string            `json:"incident"`
+	Time     time.Time         `json:"time"`
+	User     string            `json:"user,omitempty"`
+	Params   logs.SearchParams `json:"params"`
+	Total    int               `json:"total"`
+	Results  []logs.Result     `json:"results"`
+	Link     string            `json:"link,omitempty"`
+}
+
+// Client posts the snapshots as comments of the incidents
+type Client struct {
+	url       string
+	headers   map[string]string
+	createdBy string
+	searchURL string
+	results   int
+	client    *http.Client
+}
+
+func NewClient(kClient *kommons.Client, config logs.MissionControlConfig) (*Client, error) {
+	if config.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+
+	t := &Client{
+		url:       strings.TrimSuffix(config.URL, "/"),
+		headers:   make(map[string]string),
+		createdBy: config.CreatedBy,
+		searchURL: config.SearchURL,
+		results:   config.Results,
+		client:    &http.Client{Timeout: 30 * time.Second},
+	}
+	if t.results <= 0 {
+		t.results = 10
+	}
+
+	if config.Token != nil {
+		_, token, err := kClient.GetEnvValue(*config.Token, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the token: %w", err)
+		}
+		t.headers["Authorization"] = "Bearer " + token
+	} else if config.Username != nil && config.Password != nil {
+		_, username, err := kClient.GetEnvValue(*config.Username, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the username: %w", err)
+		}
+		_, password, err := kClient.GetEnvValue(*config.Password, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the password: %w", err)
+		}
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(username, password)
+		t.headers["Authorization"] = req.Header.Get("Authorization")
+	}
+
+	return t, nil
+}
+
+// NewSnapshot keeps the first results of the search and links to the search
+func (t *Client) NewSnapshot(incident, user string, params logs.SearchParams, results logs.SearchResults) Snapshot {
+	snapshot := Snapshot{
+		Incident: incident,
+		Time:     time.Now(),
+		User:     user,
+		Params:   params,
+		Total:    results.Total,
+		Results:  results.Results,
+		Link:     params.Link(t.searchURL),
+	}
+	if len(snapshot.Results) > t.results {
+		snapshot.Results = snapshot.Results[:t.results]
+	}
+	return snapshot
+}
+
+// Attach adds the snapshot as a comment of its incident
+func (t *Client) Attach(snapshot Snapshot) error {
+	comment := map[string]string{
+		"incident_id": snapshot.Incident,
+		"comment":     Comment(snapshot),
+	}
+	if t.createdBy != "" {
+		comment["created_by"] = t.createdBy
+	}
+
+	body, err := json.Marshal(comment)
+	if err != nil {
+		return fmt.Errorf("error marshalling the comment: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, t.url+"/db/comments", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error posting the comment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error posting the comment: got response %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Comment renders the snapshot as markdown
+func Comment(snapshot Snapshot) string {
+	var b strings.Builder
+	b.WriteString("**Log snapshot**")
+	if snapshot.User != "" {
+		fmt.Fprintf(&b, " by %s", snapshot.User)
+	}
+	fmt.Fprintf(&b, " at %s\n\n", snapshot.Time.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Search: `%s`\n\n", strings.TrimSpace(snapshot.Params.String()))
+	fmt.Fprintf(&b, "%d of %d results:\n\n```\n", len(snapshot.Results), snapshot.Total)
+	for _, r := range snapshot.Results {
+		if r.Time != "" {
+			b.WriteString(r.Time + " ")
+		}
+		// Keep the results from closing the code block
+		b.WriteString(strings.ReplaceAll(r.Message, "```", "'''") + "\n")
+	}
+	b.WriteString("```\n")
+
+	if snapshot.Link != "" {
+		fmt.Fprintf(&b, "\n[View the search](%s)\n", snapshot.Link)
+	}
+	return b.String()
+}
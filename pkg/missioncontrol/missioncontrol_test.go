@@ -0,0 +1,65 @@
+package missioncontrol
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestAttach(t *testing.T) {
+	var got map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/db/comments" || r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(nil, logs.MissionControlConfig{
+		URL:       server.URL + "/",
+		Token:     &kommons.EnvVar{Value: "secret"},
+		CreatedBy: "person-1",
+		SearchURL: "https://logs.example.com/search",
+		Results:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	params := logs.SearchParams{Query: "timeout", Start: "1h", Labels: map[string]string{"app": "api"}}
+	results := logs.SearchResults{Total: 3, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:00Z", Message: "request timeout"},
+		{Message: "```injected"},
+		{Message: "third"},
+	}}
+	snapshot := client.NewSnapshot("incident-1", "alice", params, results)
+	if len(snapshot.Results) != 2 {
+		t.Errorf("expected 2 results in the snapshot, got %d", len(snapshot.Results))
+	}
+	if want := "https://logs.example.com/search?labels=app%3Dapi&query=timeout&start=1h"; snapshot.Link != want {
+		t.Errorf("link = %s, want %s", snapshot.Link, want)
+	}
+
+	if err := client.Attach(snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	if got["incident_id"] != "incident-1" || got["created_by"] != "person-1" {
+		t.Errorf("unexpected comment: %v", got)
+	}
+	for _, want := range []string{"by alice", "2 of 3 results", "2023-05-01T12:00:00Z request timeout", "'''injected", "[View the search](https://logs.example.com/search?"} {
+		if !strings.Contains(got["comment"], want) {
+			t.Errorf("comment doesn't contain %q:\n%s", want, got["comment"])
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package notification
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/kommons"
+)
+
+// GlobalNotifier sends the notifications to the configured channels.
+// It's nil when no channel is configured.
+var GlobalNotifier *Notifier
+
+// Message is a notification, with sample log lines and a link to the search
+type Message struct {
+	// Key identifies the alert, so that the incident channels deduplicate its notifications.
+	// It defaults to the title.
+	Key   string
+	Title string
+	Text  string
+	// Resolved marks the notifications of recovered alerts
+	Resolved bool
+	Samples  []logs.Result
+	Link     string
+}
+
+// Channel delivers the notifications
+type Channel interface {
+	Send(msg Message) error
+}
+
+// Notifier sends the notifications to the channels by name
+type Notifier struct {
+	channels map[string]Channel
+}
+
+func NewNotifier(kClient *kommons.Client, namespace string, configs []logs.NotificationChannel) (*Notifier, error) {
+	t := &Notifier{channels: make(map[string]Channel, len(configs))}
+	for _, config := range configs {
+		if config.Name == "" {
+			return nil, fmt.Errorf("notification channels must have a name")
+		}
+		if _, ok := t.channels[config.Name]; ok {
+			return nil, fmt.Errorf("duplicate notification channel %s", config.Name)
+		}
+
+		var channel Channel
+		var err error
+		switch {
+		case config.Slack != nil:
+			channel, err = newSlackChannel(kClient, namespace, *config.Slack)
+		case config.Teams != nil:
+			channel, err = newTeamsChannel(kClient, namespace, *config.Teams)
+		case config.PagerDuty != nil:
+			channel, err = newPagerDutyChannel(kClient, namespace, *config.PagerDuty)
+		case config.Opsgenie != nil:
+			channel, err = newOpsgenieChannel(kClient, namespace, *config.Opsgenie)
+		default:
+			err = fmt.Errorf("no channel type configured")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error creating the notification channel %s: %w", config.Name, err)
+		}
+		t.channels[config.Name] = channel
+	}
+	return t, nil
+}
+
+// Register adds the channel, replacing any channel with the same name
+func (t *Notifier) Register(name string, channel Channel) {
+	t.channels[name] = channel
+}
+
+// Has returns true if the channel exists
+func (t *Notifier) Has(name string) bool {
+	if t == nil {
+		return false
+	}
+	_, ok := t.channels[name]
+	return ok
+}
+
+// Notify sends the message to the channels.
+// The errors are logged, so that a failing channel doesn't prevent the others from being notified.
+func (t *Notifier) Notify(channels []string, msg Message) {
+	if t == nil {
+		return
+	}
+
+	for _, name := range channels {
+		channel, ok := t.channels[name]
+		if !ok {
+			logger.Errorf("unknown notification channel %s", name)
+			continue
+		}
+		if err := channel.Send(msg); err != nil {
+			logger.Errorf("error notifying %s: %v", name, err)
+		}
+	}
+}
+
+// samples renders the sample log lines, one per line
+func samples(results []logs.Result) string {
+	var b strings.Builder
+	for _, r := range results {
+		if r.Time != "" {
+			b.WriteString(r.Time + " ")
+		}
+		b.WriteString(strings.ReplaceAll(r.Message, "```", "'''") + "\n")
+	}
+	return b.String()
+}
+
+// key returns the deduplication key of the message
+func (t Message) key() string {
+	if t.Key != "" {
+		return t.Key
+	}
+	return t.Title
+}
+
+// truncate shortens the text to the maximum length accepted by the apis
+func truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	cut := max - 3
+	for cut > 0 && !utf8.RuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + "..."
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// post sends the body as json and checks the status of the response
+func post(url string, headers map[string]string, body any) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling the message: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("got response: %d", resp.StatusCode)
+	}
+	return resp, nil
+}
+
+func getEnvValue(kClient *kommons.Client, namespace string, envVar *kommons.EnvVar) (string, error) {
+	if envVar == nil {
+		return "", nil
+	}
+	_, value, err := kClient.GetEnvValue(*envVar, namespace)
+	return value, err
+}
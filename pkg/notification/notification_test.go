@@ -0,0 +1,220 @@
+package notification
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+// recorder records the json bodies posted to it
+type recorder struct {
+	paths  []string
+	bodies []map[string]any
+	auth   string
+}
+
+func (t *recorder) server(response string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		t.paths = append(t.paths, r.URL.Path)
+		t.bodies = append(t.bodies, body)
+		t.auth = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(response))
+	}))
+}
+
+var message = Message{
+	Title:   "errors in checkout",
+	Text:    "found 12 results, expected at most 0",
+	Samples: []logs.Result{{Time: "2023-05-01T12:00:00Z", Message: "payment <failed>"}},
+	Link:    "https://logs.example.com/search?query=error",
+}
+
+func TestSlack(t *testing.T) {
+	var webhook, api recorder
+	webhookServer := webhook.server("ok")
+	defer webhookServer.Close()
+	apiServer := api.server(`{"ok": true}`)
+	defer apiServer.Close()
+	slackAPI = apiServer.URL
+
+	notifier, err := NewNotifier(nil, "", []logs.NotificationChannel{
+		{Name: "webhook", Slack: &logs.SlackChannel{WebhookURL: &kommons.EnvVar{Value: webhookServer.URL}}},
+		{Name: "app", Slack: &logs.SlackChannel{Token: &kommons.EnvVar{Value: "xoxb-1"}, Channel: "#alerts"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	notifier.Notify([]string{"webhook", "app", "missing"}, message)
+
+	if len(webhook.bodies) != 1 || len(api.bodies) != 1 {
+		t.Fatalf("expected a message on each channel, got %d and %d", len(webhook.bodies), len(api.bodies))
+	}
+	text, _ := webhook.bodies[0]["text"].(string)
+	for _, want := range []string{":rotating_light: *errors in checkout*", "payment &lt;failed&gt;", "<https://logs.example.com/search?query=error|View the search>"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text doesn't contain %q:\n%s", want, text)
+		}
+	}
+
+	if api.paths[0] != "/chat.postMessage" || api.auth != "Bearer xoxb-1" || api.bodies[0]["channel"] != "#alerts" {
+		t.Errorf("unexpected request to the slack api: %s %s %v", api.paths[0], api.auth, api.bodies[0])
+	}
+}
+
+func TestSlack_APIError(t *testing.T) {
+	var api recorder
+	apiServer := api.server(`{"ok": false, "error": "channel_not_found"}`)
+	defer apiServer.Close()
+	slackAPI = apiServer.URL
+
+	channel, err := newSlackChannel(nil, "", logs.SlackChannel{Token: &kommons.EnvVar{Value: "xoxb-1"}, Channel: "#missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := channel.Send(message); err == nil || !strings.Contains(err.Error(), "channel_not_found") {
+		t.Errorf("expected the slack error, got %v", err)
+	}
+}
+
+func TestTeams(t *testing.T) {
+	var webhook, graph recorder
+	webhookServer := webhook.server("1")
+	defer webhookServer.Close()
+	graphServer := graph.server("{}")
+	defer graphServer.Close()
+	graphAPI = graphServer.URL
+
+	notifier, err := NewNotifier(nil, "", []logs.NotificationChannel{
+		{Name: "webhook", Teams: &logs.TeamsChannel{WebhookURL: &kommons.EnvVar{Value: webhookServer.URL}}},
+		{Name: "app", Teams: &logs.TeamsChannel{Token: &kommons.EnvVar{Value: "token"}, Team: "team-1", Channel: "19:abc@thread.tacv2"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved := message
+	resolved.Resolved = true
+	notifier.Notify([]string{"webhook", "app"}, resolved)
+
+	if len(webhook.bodies) != 1 || len(graph.bodies) != 1 {
+		t.Fatalf("expected a message on each channel, got %d and %d", len(webhook.bodies), len(graph.bodies))
+	}
+	card := webhook.bodies[0]
+	if card["@type"] != "MessageCard" || card["themeColor"] != "2EB886" || !strings.Contains(card["text"].(string), "payment &lt;failed&gt;") {
+		t.Errorf("unexpected card: %v", card)
+	}
+
+	if graph.paths[0] != "/teams/team-1/channels/19:abc@thread.tacv2/messages" || graph.auth != "Bearer token" {
+		t.Errorf("unexpected request to the graph api: %s %s", graph.paths[0], graph.auth)
+	}
+}
+
+func TestPagerDuty(t *testing.T) {
+	var api recorder
+	apiServer := api.server(`{"status": "success"}`)
+	defer apiServer.Close()
+	pagerDutyAPI = apiServer.URL
+
+	channel, err := newPagerDutyChannel(nil, "", logs.PagerDutyChannel{RoutingKey: kommons.EnvVar{Value: "R0UT1NG"}, Severity: "critical"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firing := message
+	firing.Key = "checkout-errors"
+	resolved := firing
+	resolved.Resolved = true
+	for _, msg := range []Message{firing, resolved} {
+		if err := channel.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	trigger, resolve := api.bodies[0], api.bodies[1]
+	if trigger["event_action"] != "trigger" || trigger["dedup_key"] != "checkout-errors" || trigger["routing_key"] != "R0UT1NG" {
+		t.Errorf("unexpected trigger event: %v", trigger)
+	}
+	payload, _ := trigger["payload"].(map[string]any)
+	if payload["summary"] != "errors in checkout" || payload["severity"] != "critical" {
+		t.Errorf("unexpected payload: %v", payload)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != "checkout-errors" || resolve["payload"] != nil {
+		t.Errorf("unexpected resolve event: %v", resolve)
+	}
+}
+
+func TestOpsgenie(t *testing.T) {
+	var api recorder
+	apiServer := api.server(`{"result": "Request will be processed"}`)
+	defer apiServer.Close()
+
+	channel, err := newOpsgenieChannel(nil, "", logs.OpsgenieChannel{APIKey: kommons.EnvVar{Value: "key"}, URL: apiServer.URL, Teams: []string{"payments"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved := message
+	resolved.Resolved = true
+	for _, msg := range []Message{message, resolved} {
+		if err := channel.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if api.paths[0] != "/v2/alerts" || api.auth != "GenieKey key" {
+		t.Errorf("unexpected request to create the alert: %s %s", api.paths[0], api.auth)
+	}
+	alert := api.bodies[0]
+	if alert["alias"] != "errors in checkout" || alert["priority"] != "P3" || !strings.Contains(alert["description"].(string), "payment <failed>") {
+		t.Errorf("unexpected alert: %v", alert)
+	}
+	if api.paths[1] != "/v2/alerts/errors in checkout/close" {
+		t.Errorf("unexpected request to close the alert: %s", api.paths[1])
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{text: "short", max: 10, want: "short"},
+		{text: "a longer text", max: 10, want: "a longe..."},
+		{text: "ééééé", max: 8, want: "éé..."},
+	}
+
+	for _, tt := range tests {
+		if got := truncate(tt.text, tt.max); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestNewNotifier_Errors(t *testing.T) {
+	for _, configs := range [][]logs.NotificationChannel{
+		{{Name: "empty"}},
+		{{Slack: &logs.SlackChannel{WebhookURL: &kommons.EnvVar{Value: "http://example.com"}}}},
+		{{Name: "no channel", Slack: &logs.SlackChannel{Token: &kommons.EnvVar{Value: "xoxb-1"}}}},
+		{{Name: "no team", Teams: &logs.TeamsChannel{Token: &kommons.EnvVar{Value: "token"}, Channel: "1"}}},
+		{{Name: "no routing key", PagerDuty: &logs.PagerDutyChannel{}}},
+		{{Name: "severity", PagerDuty: &logs.PagerDutyChannel{RoutingKey: kommons.EnvVar{Value: "key"}, Severity: "high"}}},
+		{{Name: "priority", Opsgenie: &logs.OpsgenieChannel{APIKey: kommons.EnvVar{Value: "key"}, Priority: "P0"}}},
+		{
+			{Name: "dup", Slack: &logs.SlackChannel{WebhookURL: &kommons.EnvVar{Value: "http://example.com"}}},
+			{Name: "dup", Slack: &logs.SlackChannel{WebhookURL: &kommons.EnvVar{Value: "http://example.com"}}},
+		},
+	} {
+		if _, err := NewNotifier(nil, "", configs); err == nil {
+			t.Errorf("expected an error for %+v", configs)
+		}
+	}
+}
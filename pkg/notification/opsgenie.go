@@ -0,0 +1,100 @@
+package notification
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const opsgenieAPI = "https://api.opsgenie.com"
+
+type opsgenieChannel struct {
+	apiKey   string
+	url      string
+	priority string
+	tags     []string
+	teams    []string
+}
+
+func newOpsgenieChannel(kClient *kommons.Client, namespace string, config logs.OpsgenieChannel) (*opsgenieChannel, error) {
+	apiKey, err := getEnvValue(kClient, namespace, &config.APIKey)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the api key: %w", err)
+	}
+	if apiKey == "" {
+		return nil, fmt.Errorf("the api key is required")
+	}
+
+	t := &opsgenieChannel{
+		apiKey:   apiKey,
+		url:      strings.TrimSuffix(config.URL, "/"),
+		priority: config.Priority,
+		tags:     config.Tags,
+		teams:    config.Teams,
+	}
+	if t.url == "" {
+		t.url = opsgenieAPI
+	}
+	if t.priority == "" {
+		t.priority = "P3"
+	}
+	switch t.priority {
+	case "P1", "P2", "P3", "P4", "P5":
+	default:
+		return nil, fmt.Errorf("invalid priority %s, expected P1 to P5", t.priority)
+	}
+
+	return t, nil
+}
+
+func (t *opsgenieChannel) Send(msg Message) error {
+	headers := map[string]string{"Authorization": "GenieKey " + t.apiKey}
+
+	// The alerts are aliased by the message key, which closes the alert it created
+	if msg.Resolved {
+		endpoint := fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", t.url, url.PathEscape(truncate(msg.key(), 512)))
+		resp, err := post(endpoint, headers, map[string]string{"source": "apm-hub", "note": msg.Text})
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	description := msg.Text
+	if len(msg.Samples) > 0 {
+		description += "\n\n" + samples(msg.Samples)
+	}
+	if msg.Link != "" {
+		description += "\n" + msg.Link
+	}
+
+	alert := map[string]any{
+		"message":     truncate(msg.Title, 130),
+		"alias":       truncate(msg.key(), 512),
+		"description": truncate(description, 15000),
+		"priority":    t.priority,
+		"source":      "apm-hub",
+	}
+	if len(t.tags) > 0 {
+		alert["tags"] = t.tags
+	}
+	if len(t.teams) > 0 {
+		var responders []map[string]string
+		for _, team := range t.teams {
+			responders = append(responders, map[string]string{"name": team, "type": "team"})
+		}
+		alert["responders"] = responders
+	}
+	if msg.Link != "" {
+		alert["details"] = map[string]string{"search": msg.Link}
+	}
+
+	resp, err := post(t.url+"/v2/alerts", headers, alert)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
@@ -0,0 +1,75 @@
+package notification
+
+import (
+	"fmt"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+	"github.com/flanksource/kommons"
+)
+
+// pagerDutyAPI is the url of the Events v2 api
+var pagerDutyAPI = "https://events.pagerduty.com/v2/enqueue"
+
+var pagerDutySeverities = []string{"critical", "error", "warning", "info"}
+
+type pagerDutyChannel struct {
+	routingKey string
+	severity   string
+}
+
+func newPagerDutyChannel(kClient *kommons.Client, namespace string, config logs.PagerDutyChannel) (*pagerDutyChannel, error) {
+	routingKey, err := getEnvValue(kClient, namespace, &config.RoutingKey)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the routing key: %w", err)
+	}
+	if routingKey == "" {
+		return nil, fmt.Errorf("the routing key is required")
+	}
+
+	severity := config.Severity
+	if severity == "" {
+		severity = "error"
+	}
+	if !collections.Contains(pagerDutySeverities, severity) {
+		return nil, fmt.Errorf("invalid severity %s, expected one of %v", severity, pagerDutySeverities)
+	}
+
+	return &pagerDutyChannel{routingKey: routingKey, severity: severity}, nil
+}
+
+func (t *pagerDutyChannel) Send(msg Message) error {
+	event := map[string]any{
+		"routing_key":  t.routingKey,
+		"event_action": "trigger",
+		"dedup_key":    msg.key(),
+	}
+
+	if msg.Resolved {
+		event["event_action"] = "resolve"
+	} else {
+		details := map[string]any{}
+		if msg.Text != "" {
+			details["message"] = msg.Text
+		}
+		if len(msg.Samples) > 0 {
+			details["samples"] = samples(msg.Samples)
+		}
+
+		event["payload"] = map[string]any{
+			"summary":        truncate(msg.Title, 1024),
+			"source":         "apm-hub",
+			"severity":       t.severity,
+			"custom_details": details,
+		}
+		if msg.Link != "" {
+			event["links"] = []map[string]string{{"href": msg.Link, "text": "View the search"}}
+		}
+	}
+
+	resp, err := post(pagerDutyAPI, nil, event)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
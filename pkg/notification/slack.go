@@ -0,0 +1,95 @@
+package notification
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+// slackAPI is the url of the Slack web api
+var slackAPI = "https://slack.com/api"
+
+type slackChannel struct {
+	webhookURL string
+	token      string
+	channel    string
+}
+
+func newSlackChannel(kClient *kommons.Client, namespace string, config logs.SlackChannel) (*slackChannel, error) {
+	webhookURL, err := getEnvValue(kClient, namespace, config.WebhookURL)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the webhook url: %w", err)
+	}
+	token, err := getEnvValue(kClient, namespace, config.Token)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the token: %w", err)
+	}
+
+	switch {
+	case webhookURL == "" && token == "":
+		return nil, fmt.Errorf("either the webhook url or the token is required")
+	case token != "" && config.Channel == "":
+		return nil, fmt.Errorf("the channel is required with the token")
+	}
+
+	return &slackChannel{webhookURL: webhookURL, token: token, channel: config.Channel}, nil
+}
+
+func (t *slackChannel) Send(msg Message) error {
+	body := map[string]any{"text": slackText(msg)}
+	if t.webhookURL != "" {
+		resp, err := post(t.webhookURL, nil, body)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	body["channel"] = t.channel
+	resp, err := post(slackAPI+"/chat.postMessage", map[string]string{"Authorization": "Bearer " + t.token}, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// The web api returns errors with a 200 status
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("error parsing the response: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("slack error: %s", result.Error)
+	}
+	return nil
+}
+
+// slackText renders the message with the Slack mrkdwn syntax
+func slackText(msg Message) string {
+	var b strings.Builder
+	icon := ":rotating_light:"
+	if msg.Resolved {
+		icon = ":white_check_mark:"
+	}
+	fmt.Fprintf(&b, "%s *%s*\n", icon, slackEscape(msg.Title))
+	if msg.Text != "" {
+		b.WriteString(slackEscape(msg.Text) + "\n")
+	}
+	if len(msg.Samples) > 0 {
+		b.WriteString("```\n" + slackEscape(samples(msg.Samples)) + "```\n")
+	}
+	if msg.Link != "" {
+		fmt.Fprintf(&b, "<%s|View the search>\n", msg.Link)
+	}
+	return b.String()
+}
+
+// slackEscape escapes the control characters of mrkdwn
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
@@ -0,0 +1,107 @@
+package notification
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+// graphAPI is the url of the Microsoft Graph api
+var graphAPI = "https://graph.microsoft.com/v1.0"
+
+type teamsChannel struct {
+	webhookURL string
+	token      string
+	team       string
+	channel    string
+}
+
+func newTeamsChannel(kClient *kommons.Client, namespace string, config logs.TeamsChannel) (*teamsChannel, error) {
+	webhookURL, err := getEnvValue(kClient, namespace, config.WebhookURL)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the webhook url: %w", err)
+	}
+	token, err := getEnvValue(kClient, namespace, config.Token)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the token: %w", err)
+	}
+
+	switch {
+	case webhookURL == "" && token == "":
+		return nil, fmt.Errorf("either the webhook url or the token is required")
+	case token != "" && (config.Team == "" || config.Channel == ""):
+		return nil, fmt.Errorf("the team and channel are required with the token")
+	}
+
+	return &teamsChannel{webhookURL: webhookURL, token: token, team: config.Team, channel: config.Channel}, nil
+}
+
+func (t *teamsChannel) Send(msg Message) error {
+	if t.webhookURL != "" {
+		resp, err := post(t.webhookURL, nil, messageCard(msg))
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	endpoint := fmt.Sprintf("%s/teams/%s/channels/%s/messages", graphAPI, url.PathEscape(t.team), url.PathEscape(t.channel))
+	body := map[string]any{
+		"subject": msg.Title,
+		"body":    map[string]string{"contentType": "html", "content": teamsHTML(msg)},
+	}
+	resp, err := post(endpoint, map[string]string{"Authorization": "Bearer " + t.token}, body)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// messageCard renders the message as the legacy card accepted by the incoming webhooks
+func messageCard(msg Message) map[string]any {
+	color := "D70000"
+	if msg.Resolved {
+		color = "2EB886"
+	}
+
+	text := msg.Text
+	if len(msg.Samples) > 0 {
+		text += "\n\n<pre>" + html.EscapeString(samples(msg.Samples)) + "</pre>"
+	}
+
+	card := map[string]any{
+		"@type":      "MessageCard",
+		"@context":   "http://schema.org/extensions",
+		"summary":    msg.Title,
+		"title":      msg.Title,
+		"themeColor": color,
+		"text":       text,
+	}
+	if msg.Link != "" {
+		card["potentialAction"] = []map[string]any{{
+			"@type":   "OpenUri",
+			"name":    "View the search",
+			"targets": []map[string]string{{"os": "default", "uri": msg.Link}},
+		}}
+	}
+	return card
+}
+
+func teamsHTML(msg Message) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<p><b>%s</b></p>", html.EscapeString(msg.Title))
+	if msg.Text != "" {
+		fmt.Fprintf(&b, "<p>%s</p>", html.EscapeString(msg.Text))
+	}
+	if len(msg.Samples) > 0 {
+		fmt.Fprintf(&b, "<pre>%s</pre>", html.EscapeString(samples(msg.Samples)))
+	}
+	if msg.Link != "" {
+		fmt.Fprintf(&b, `<p><a href="%s">View the search</a></p>`, html.EscapeString(msg.Link))
+	}
+	return b.String()
+}
@@ -0,0 +1,331 @@
+// Package oci signs the requests to the Oracle Cloud apis with the api signing key of a user,
+// or with the instance principal of the compute instance
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+// expiryMargin is how long before their expiry the security tokens of the instance principals are renewed
+const expiryMargin = 5 * time.Minute
+
+// metadataURL is the instance metadata service, serving the certificates of the instance principal
+var metadataURL = "http://169.254.169.254/opc/v2"
+
+// authURL returns the url of the auth service of the region, federating the instance principals
+var authURL = func(region string) string {
+	return "https://auth." + region + ".oraclecloud.com"
+}
+
+// Signer signs the requests to the Oracle Cloud apis
+type Signer interface {
+	Sign(req *http.Request) error
+}
+
+// NewSigner returns the signer of the api signing key of the user, or of the instance principal when no key is provided
+func NewSigner(kClient *kommons.Client, namespace string, auth logs.OCIAuthentication, region string) (Signer, error) {
+	if auth.PrivateKey == nil {
+		if region == "" {
+			return nil, fmt.Errorf("the region is required with the instance principal")
+		}
+		return &instancePrincipal{region: region, client: &http.Client{Timeout: 30 * time.Second}}, nil
+	}
+
+	if auth.Tenancy == "" || auth.User == "" || auth.Fingerprint == "" {
+		return nil, fmt.Errorf("the tenancy, user and fingerprint of the private key are required")
+	}
+	_, data, err := kClient.GetEnvValue(*auth.PrivateKey, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the private key: %w", err)
+	}
+	var passphrase string
+	if auth.Passphrase != nil {
+		if _, passphrase, err = kClient.GetEnvValue(*auth.Passphrase, namespace); err != nil {
+			return nil, fmt.Errorf("error getting the passphrase: %w", err)
+		}
+	}
+	key, err := parsePrivateKey([]byte(data), passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return &keySigner{keyID: auth.Tenancy + "/" + auth.User + "/" + auth.Fingerprint, key: key}, nil
+}
+
+// parsePrivateKey parses the PEM encoded PKCS1 or PKCS8 RSA key, decrypting it with the passphrase
+func parsePrivateKey(data []byte, passphrase string) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("the private key isn't PEM encoded")
+	}
+	der := block.Bytes
+	// The encrypted api signing keys use the legacy PEM encryption
+	if x509.IsEncryptedPEMBlock(block) {
+		var err error
+		if der, err = x509.DecryptPEMBlock(block, []byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("error decrypting the private key: %w", err)
+		}
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key isn't an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// keySigner signs the requests with a key
+type keySigner struct {
+	keyID string
+	key   *rsa.PrivateKey
+}
+
+func (t *keySigner) Sign(req *http.Request) error {
+	return sign(req, t.keyID, t.key)
+}
+
+// sign signs the date, target and host of the request, and the digest of the body of the requests with a body,
+// with the draft-cavage http signature of the Oracle Cloud apis
+func sign(req *http.Request, keyID string, key *rsa.PrivateKey) error {
+	if req.Header.Get("Date") == "" {
+		req.Header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	}
+	headers := []string{"date", "(request-target)", "host"}
+	if req.Method == http.MethodPost || req.Method == http.MethodPut || req.Method == http.MethodPatch {
+		var body []byte
+		if req.Body != nil {
+			var err error
+			if body, err = io.ReadAll(req.Body); err != nil {
+				return fmt.Errorf("error reading the body of the request: %w", err)
+			}
+			req.Body.Close()
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		digest := sha256.Sum256(body)
+		req.Header.Set("X-Content-Sha256", base64.StdEncoding.EncodeToString(digest[:]))
+		req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		headers = append(headers, "content-length", "content-type", "x-content-sha256")
+	}
+
+	lines := make([]string, len(headers))
+	for i, h := range headers {
+		switch h {
+		case "(request-target)":
+			lines[i] = h + ": " + strings.ToLower(req.Method) + " " + req.URL.RequestURI()
+		case "host":
+			lines[i] = h + ": " + req.URL.Host
+		default:
+			lines[i] = h + ": " + req.Header.Get(h)
+		}
+	}
+	hashed := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	if err != nil {
+		return fmt.Errorf("error signing the request: %w", err)
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf(`Signature version="1",keyId="%s",algorithm="rsa-sha256",headers="%s",signature="%s"`,
+		keyID, strings.Join(headers, " "), base64.StdEncoding.EncodeToString(signature)))
+	return nil
+}
+
+// instancePrincipal signs the requests with a security token of the instance principal, and the session key of the token.
+// The tokens are federated by the auth service with the certificate of the instance from the metadata service.
+type instancePrincipal struct {
+	region string
+	client *http.Client
+
+	lock   sync.Mutex
+	token  string
+	key    *rsa.PrivateKey
+	expiry time.Time
+}
+
+func (t *instancePrincipal) Sign(req *http.Request) error {
+	token, key, err := t.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	return sign(req, "ST$"+token, key)
+}
+
+// Token returns the cached security token and its session key, or federates a new one when it's about to expire
+func (t *instancePrincipal) Token(ctx context.Context) (string, *rsa.PrivateKey, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.token != "" && time.Now().Before(t.expiry) {
+		return t.token, t.key, nil
+	}
+
+	leafPEM, err := t.metadata(ctx, "/identity/cert.pem")
+	if err != nil {
+		return "", nil, err
+	}
+	intermediatePEM, err := t.metadata(ctx, "/identity/intermediate.pem")
+	if err != nil {
+		return "", nil, err
+	}
+	keyPEM, err := t.metadata(ctx, "/identity/key.pem")
+	if err != nil {
+		return "", nil, err
+	}
+
+	leaf, err := parseCertificate(leafPEM)
+	if err != nil {
+		return "", nil, err
+	}
+	intermediate, err := parseCertificate(intermediatePEM)
+	if err != nil {
+		return "", nil, err
+	}
+	instanceKey, err := parsePrivateKey(keyPEM, "")
+	if err != nil {
+		return "", nil, err
+	}
+	tenancy := tenancyOf(leaf)
+	if tenancy == "" {
+		return "", nil, fmt.Errorf("the certificate of the instance has no tenancy")
+	}
+
+	sessionKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return "", nil, fmt.Errorf("error generating the session key: %w", err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&sessionKey.PublicKey)
+	if err != nil {
+		return "", nil, fmt.Errorf("error encoding the session key: %w", err)
+	}
+	body, err := json.Marshal(map[string]any{
+		"certificate":              base64.StdEncoding.EncodeToString(leaf.Raw),
+		"publicKey":                base64.StdEncoding.EncodeToString(publicKey),
+		"intermediateCertificates": []string{base64.StdEncoding.EncodeToString(intermediate.Raw)},
+		"purpose":                  "DEFAULT",
+		"fingerprintAlgorithm":     "SHA256",
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("error encoding the federation request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authURL(t.region)+"/v1/x509", bytes.NewReader(body))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := sign(req, tenancy+"/fed-x509-sha256/"+fingerprint(leaf), instanceKey); err != nil {
+		return "", nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("error federating the instance principal: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", nil, fmt.Errorf("error federating the instance principal: %d %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	var res struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return "", nil, fmt.Errorf("error decoding the security token: %w", err)
+	}
+
+	t.token, t.key = res.Token, sessionKey
+	t.expiry = expiryOf(res.Token).Add(-expiryMargin)
+	return t.token, t.key, nil
+}
+
+// metadata returns the content of the path of the metadata service
+func (t *instancePrincipal) metadata(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer Oracle")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s from the metadata service: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting %s from the metadata service: %d", path, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+}
+
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("the certificate isn't PEM encoded")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the certificate: %w", err)
+	}
+	return cert, nil
+}
+
+// tenancyOf returns the OCID of the tenancy of the certificate of the instance, in its opc-tenant organizational unit
+func tenancyOf(cert *x509.Certificate) string {
+	for _, unit := range cert.Subject.OrganizationalUnit {
+		for _, prefix := range []string{"opc-tenant:", "opctenant:"} {
+			if tenancy, ok := strings.CutPrefix(unit, prefix); ok {
+				return tenancy
+			}
+		}
+	}
+	return ""
+}
+
+// fingerprint returns the colon separated SHA256 fingerprint of the certificate
+func fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(hex, ":")
+}
+
+// expiryOf returns the expiry of the claims of the JWT security token, or in a quarter of an hour when it can't be read
+func expiryOf(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) == 3 {
+		var claims struct {
+			Exp int64 `json:"exp"`
+		}
+		if data, err := base64.RawURLEncoding.DecodeString(parts[1]); err == nil && json.Unmarshal(data, &claims) == nil && claims.Exp > 0 {
+			return time.Unix(claims.Exp, 0)
+		}
+	}
+	return time.Now().Add(15 * time.Minute)
+}
@@ -0,0 +1,193 @@
+package oci
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+var signatureRegexp = regexp.MustCompile(`^Signature version="1",keyId="([^"]+)",algorithm="rsa-sha256",headers="([^"]+)",signature="([^"]+)"$`)
+
+// verify verifies the signature of the request with the public key, and returns its key id
+func verify(t *testing.T, r *http.Request, key *rsa.PublicKey) string {
+	t.Helper()
+	match := signatureRegexp.FindStringSubmatch(r.Header.Get("Authorization"))
+	if match == nil {
+		t.Fatalf("unexpected authorization %s", r.Header.Get("Authorization"))
+	}
+	var lines []string
+	for _, h := range strings.Split(match[2], " ") {
+		switch h {
+		case "(request-target)":
+			lines = append(lines, h+": "+strings.ToLower(r.Method)+" "+r.URL.RequestURI())
+		case "host":
+			lines = append(lines, h+": "+r.Host)
+		default:
+			lines = append(lines, h+": "+r.Header.Get(h))
+		}
+	}
+	signature, err := base64.StdEncoding.DecodeString(match[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashed := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hashed[:], signature); err != nil {
+		t.Errorf("invalid signature of %s: %v", match[2], err)
+	}
+	return match[1]
+}
+
+func generateKey(t *testing.T) (*rsa.PrivateKey, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+}
+
+func TestKeySigner(t *testing.T) {
+	key, keyPEM := generateKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if keyID := verify(t, r, &key.PublicKey); keyID != "ocid1.tenancy.oc1..t/ocid1.user.oc1..u/aa:bb" {
+			t.Errorf("keyId = %s", keyID)
+		}
+		if r.Method == http.MethodPost && r.Header.Get("X-Content-Sha256") != "RBNvo1WzZ4oRRq0W9+hknpT7T8If536DEMBg9hyq/4o=" {
+			t.Errorf("X-Content-Sha256 = %s", r.Header.Get("X-Content-Sha256"))
+		}
+	}))
+	defer server.Close()
+
+	signer, err := NewSigner(nil, "", logs.OCIAuthentication{
+		Tenancy:     "ocid1.tenancy.oc1..t",
+		User:        "ocid1.user.oc1..u",
+		Fingerprint: "aa:bb",
+		PrivateKey:  &kommons.EnvVar{Value: keyPEM},
+	}, "us-ashburn-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		req, err := http.NewRequest(method, server.URL+"/20190909/search-logs?limit=10", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signer.Sign(req); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestInstancePrincipal(t *testing.T) {
+	instanceKey, instanceKeyPEM := generateKey(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "ocid1.instance.oc1..i", OrganizationalUnit: []string{"opc-instance:ocid1.instance.oc1..i", "opc-tenant:ocid1.tenancy.oc1..t"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &instanceKey.PublicKey, instanceKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, _ := json.Marshal(map[string]any{"exp": time.Now().Add(time.Hour).Unix()})
+	token := "header." + base64.RawURLEncoding.EncodeToString(claims) + ".signature"
+
+	var sessionKey *rsa.PublicKey
+	federations := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/opc/v2/identity/cert.pem", "/opc/v2/identity/intermediate.pem":
+			_, _ = w.Write([]byte(certPEM))
+		case "/opc/v2/identity/key.pem":
+			if r.Header.Get("Authorization") != "Bearer Oracle" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			_, _ = w.Write([]byte(instanceKeyPEM))
+		case "/v1/x509":
+			federations++
+			if keyID := verify(t, r, &instanceKey.PublicKey); keyID != "ocid1.tenancy.oc1..t/fed-x509-sha256/"+fingerprint(cert) {
+				t.Errorf("keyId = %s", keyID)
+			}
+			var body struct {
+				PublicKey string `json:"publicKey"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Fatal(err)
+			}
+			data, _ := base64.StdEncoding.DecodeString(body.PublicKey)
+			key, err := x509.ParsePKIXPublicKey(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sessionKey = key.(*rsa.PublicKey)
+			_, _ = fmt.Fprintf(w, `{"token": %q}`, token)
+		default:
+			if keyID := verify(t, r, sessionKey); keyID != "ST$"+token {
+				t.Errorf("keyId = %s", keyID)
+			}
+		}
+	}))
+	defer server.Close()
+
+	defer func(url string, auth func(string) string) { metadataURL, authURL = url, auth }(metadataURL, authURL)
+	metadataURL = server.URL + "/opc/v2"
+	authURL = func(region string) string {
+		if region != "us-ashburn-1" {
+			t.Errorf("region = %s", region)
+		}
+		return server.URL
+	}
+
+	signer, err := NewSigner(nil, "", logs.OCIAuthentication{}, "us-ashburn-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL+"/logs", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := signer.Sign(req); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if federations != 1 {
+		t.Errorf("%d tokens were federated, want 1 as it's cached", federations)
+	}
+}
@@ -0,0 +1,276 @@
+// Package ocilogging searches the logs of Oracle Cloud Infrastructure Logging with the Logging Search api
+package ocilogging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/oci"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+	"github.com/jeremywohl/flatten"
+)
+
+const (
+	// maxLimit is the maximum number of logs returned by a search
+	maxLimit = 1000
+
+	// defaultRange is the time range searched when the search has no start
+	defaultRange = 24 * time.Hour
+)
+
+type OCILoggingSearch struct {
+	config *logs.OCILoggingBackendConfig
+	client *rest.Client
+	signer oci.Signer
+
+	compartment *template.Template
+	logGroup    *template.Template
+	log         *template.Template
+	query       *template.Template
+}
+
+func NewOCILoggingSearchBackend(kClient *kommons.Client, config *logs.OCILoggingBackendConfig) (*OCILoggingSearch, error) {
+	if config.Compartment == "" {
+		return nil, fmt.Errorf("the compartment is required")
+	}
+	t := &OCILoggingSearch{config: config}
+	for _, tpl := range []struct {
+		name  string
+		text  string
+		field **template.Template
+	}{
+		{"compartment", config.Compartment, &t.compartment},
+		{"logGroup", config.LogGroup, &t.logGroup},
+		{"log", config.Log, &t.log},
+		{"query", config.Query, &t.query},
+	} {
+		parsed, err := template.New(tpl.name).Parse(tpl.text)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the %s template: %w", tpl.name, err)
+		}
+		*tpl.field = parsed
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		if config.Region == "" {
+			return nil, fmt.Errorf("the region or the url is required")
+		}
+		conn.URL = "https://logging." + config.Region + ".oci.oraclecloud.com"
+	}
+	var err error
+	if t.client, err = rest.NewClient(kClient, conn); err != nil {
+		return nil, err
+	}
+	if t.signer, err = oci.NewSigner(kClient, conn.Namespace, config.Auth, config.Region); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func render(tpl *template.Template, q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing the %s template: %w", tpl.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// RenderQuery renders the search of the logs of the compartment, log group or log of the search params,
+// piped to the clauses of the query template, or of the query of the search, and sorted by their time, the last ones first
+func (t *OCILoggingSearch) RenderQuery(q *logs.SearchParams) (string, error) {
+	compartment, err := render(t.compartment, q)
+	if err != nil {
+		return "", err
+	}
+	if compartment == "" {
+		return "", fmt.Errorf("the compartment of the search is empty")
+	}
+	scope := compartment
+	logGroup, err := render(t.logGroup, q)
+	if err != nil {
+		return "", err
+	}
+	if logGroup != "" {
+		scope += "/" + logGroup
+		log, err := render(t.log, q)
+		if err != nil {
+			return "", err
+		}
+		if log != "" {
+			scope += "/" + log
+		}
+	}
+
+	query := fmt.Sprintf("search %q", scope)
+	clauses, err := render(t.query, q)
+	if err != nil {
+		return "", err
+	}
+	if clauses != "" {
+		query += " | " + clauses
+	}
+	if q.Query != "" && !strings.Contains(t.config.Query, ".Query") {
+		query += " | " + q.Query
+	}
+	return query + " | sort by datetime desc", nil
+}
+
+// Search returns the last logs of the query, up to the limit of the search, chronologically.
+// The next page returns the logs before them.
+func (t *OCILoggingSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return results, err
+	}
+
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+	start := end.Add(-defaultRange)
+	if s := q.GetStart(); s != nil {
+		start = *s
+	}
+	data, err := json.Marshal(map[string]any{
+		"timeStart":         start.UTC().Format(time.RFC3339Nano),
+		"timeEnd":           end.UTC().Format(time.RFC3339Nano),
+		"searchQuery":       query,
+		"isReturnFieldInfo": false,
+	})
+	if err != nil {
+		return results, fmt.Errorf("error encoding the request: %w", err)
+	}
+
+	params := url.Values{}
+	if q.Limit > 0 && q.Limit < maxLimit {
+		params.Set("limit", strconv.FormatInt(q.Limit, 10))
+	} else if q.Limit > 0 {
+		params.Set("limit", strconv.Itoa(maxLimit))
+	}
+	if q.Page != "" {
+		params.Set("page", q.Page)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.client.URL("/20190909/search-logs", params), bytes.NewReader(data))
+	if err != nil {
+		return results, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if err := t.signer.Sign(req); err != nil {
+		return results, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return results, fmt.Errorf("error searching the logs: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		Results []struct {
+			Data struct {
+				Datetime   int64      `json:"datetime"`
+				LogContent logContent `json:"logContent"`
+			} `json:"data"`
+		} `json:"results"`
+	}
+	decoder := json.NewDecoder(resp.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&res); err != nil {
+		return results, fmt.Errorf("error decoding the logs: %w", err)
+	}
+
+	for i := len(res.Results) - 1; i >= 0; i-- {
+		results.Results = append(results.Results, t.result(res.Results[i].Data.Datetime, res.Results[i].Data.LogContent))
+	}
+	results.Total = len(results.Results)
+	results.NextPage = resp.Header.Get("opc-next-page")
+	return results, nil
+}
+
+// logContent is the CloudEvents envelope of the logs
+type logContent struct {
+	ID      string `json:"id"`
+	Time    string `json:"time"`
+	Source  string `json:"source"`
+	Type    string `json:"type"`
+	Subject string `json:"subject"`
+	Data    any    `json:"data"`
+	Oracle  struct {
+		CompartmentID string `json:"compartmentid"`
+		LogGroupID    string `json:"loggroupid"`
+		LogID         string `json:"logid"`
+	} `json:"oracle"`
+}
+
+// result returns the result of the log, with the message or msg field of its data as message,
+// and the other fields of its data as labels prefixed with data.
+func (t *OCILoggingSearch) result(datetime int64, c logContent) logs.Result {
+	r := logs.Result{Id: c.ID, Labels: map[string]string{}}
+	if ts, err := time.Parse(time.RFC3339Nano, c.Time); err == nil {
+		r.Time = ts.UTC().Format(time.RFC3339Nano)
+	} else if datetime > 0 {
+		r.Time = time.UnixMilli(datetime).UTC().Format(time.RFC3339Nano)
+	}
+
+	for label, value := range map[string]string{
+		"source":         c.Source,
+		"type":           c.Type,
+		"subject":        c.Subject,
+		"compartment_id": c.Oracle.CompartmentID,
+		"log_group_id":   c.Oracle.LogGroupID,
+		"log_id":         c.Oracle.LogID,
+	} {
+		if value != "" {
+			r.Labels[label] = value
+		}
+	}
+
+	switch data := c.Data.(type) {
+	case string:
+		r.Message = data
+	case map[string]any:
+		for _, field := range []string{"message", "msg"} {
+			if message, ok := data[field].(string); ok {
+				r.Message = message
+				delete(data, field)
+				break
+			}
+		}
+		fields, _ := flatten.Flatten(data, "data.", flatten.DotStyle)
+		for field, value := range fields {
+			if value != nil {
+				r.Labels[field] = fmt.Sprint(value)
+			}
+		}
+		if r.Message == "" {
+			message, _ := json.Marshal(data)
+			r.Message = string(message)
+		}
+	}
+
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *OCILoggingSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the OCI Logging backend, the query of the search is piped as a clause of the query
+func (t *OCILoggingSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
@@ -0,0 +1,109 @@
+package ocilogging
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const searchResponse = `{"results": [
+	{"data": {"datetime": 1682942402000, "logContent": {
+		"id": "l2", "time": "2023-05-01T12:00:02.000Z", "source": "web-1", "type": "com.oraclecloud.logging.custom.application",
+		"data": {"message": "connection refused", "level": "ERROR", "http": {"status": 502}},
+		"oracle": {"compartmentid": "ocid1.compartment.oc1..c", "loggroupid": "ocid1.loggroup.oc1..g", "logid": "ocid1.log.oc1..l"}
+	}}},
+	{"data": {"datetime": 1682942401000, "logContent": {
+		"id": "l1", "source": "web-1", "data": "plain text",
+		"oracle": {"compartmentid": "ocid1.compartment.oc1..c", "loggroupid": "ocid1.loggroup.oc1..g", "logid": "ocid1.log.oc1..l"}
+	}}}
+], "summary": {"resultCount": 2, "fieldCount": 0}}`
+
+func TestOCILoggingSearch(t *testing.T) {
+	var query url.Values
+	var request map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), `Signature version="1",keyId="ocid1.tenancy.oc1..t/ocid1.user.oc1..u/aa:bb"`) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/20190909/search-logs" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		query = r.URL.Query()
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("opc-next-page", "next")
+		_, _ = w.Write([]byte(searchResponse))
+	}))
+	defer server.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewOCILoggingSearchBackend(nil, &logs.OCILoggingBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "oci"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		Region:         "us-ashburn-1",
+		Auth: logs.OCIAuthentication{
+			Tenancy:     "ocid1.tenancy.oc1..t",
+			User:        "ocid1.user.oc1..u",
+			Fingerprint: "aa:bb",
+			PrivateKey:  &kommons.EnvVar{Value: string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))},
+		},
+		Compartment: "ocid1.compartment.oc1..c",
+		LogGroup:    `{{index .Labels "log_group"}}`,
+		Log:         `{{index .Labels "log"}}`,
+		Query:       `where data.level = 'ERROR'`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query: "where data.message = 'connection refused'", Labels: map[string]string{"log_group": "ocid1.loggroup.oc1..g"},
+		Start: "2023-05-01T12:00:00Z", End: "2023-05-01T13:00:00Z", Limit: 2, Page: "page-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, NextPage: "next", Results: []logs.Result{
+		{Id: "l1", Time: "2023-05-01T12:00:01Z", Message: "plain text", Labels: map[string]string{
+			"source": "oci", "compartment_id": "ocid1.compartment.oc1..c", "log_group_id": "ocid1.loggroup.oc1..g", "log_id": "ocid1.log.oc1..l",
+		}},
+		{Id: "l2", Time: "2023-05-01T12:00:02Z", Message: "connection refused", Labels: map[string]string{
+			"source": "oci", "type": "com.oraclecloud.logging.custom.application", "data.level": "ERROR", "data.http.status": "502",
+			"compartment_id": "ocid1.compartment.oc1..c", "log_group_id": "ocid1.loggroup.oc1..g", "log_id": "ocid1.log.oc1..l",
+		}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	if wantQuery := (url.Values{"limit": {"2"}, "page": {"page-1"}}); !reflect.DeepEqual(query, wantQuery) {
+		t.Errorf("query = %v, want %v", query, wantQuery)
+	}
+	wantRequest := map[string]any{
+		"timeStart":         "2023-05-01T12:00:00Z",
+		"timeEnd":           "2023-05-01T13:00:00Z",
+		"searchQuery":       `search "ocid1.compartment.oc1..c/ocid1.loggroup.oc1..g" | where data.level = 'ERROR' | where data.message = 'connection refused' | sort by datetime desc`,
+		"isReturnFieldInfo": false,
+	}
+	if !reflect.DeepEqual(request, wantRequest) {
+		t.Errorf("request = %v, want %v", request, wantRequest)
+	}
+}
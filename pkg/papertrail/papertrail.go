@@ -0,0 +1,156 @@
+// Package papertrail searches the events of Papertrail with its search api
+package papertrail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const (
+	defaultURL = "https://papertrailapp.com"
+
+	// maxLimit is the maximum number of events returned by a search
+	maxLimit = 10000
+)
+
+type PapertrailSearch struct {
+	config *logs.PapertrailBackendConfig
+	client *rest.Client
+	system *template.Template
+	group  *template.Template
+}
+
+func NewPapertrailSearchBackend(kClient *kommons.Client, config *logs.PapertrailBackendConfig) (*PapertrailSearch, error) {
+	if config.APIToken == nil {
+		return nil, fmt.Errorf("the api token is required")
+	}
+	system, err := template.New("system").Parse(config.System)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the system template: %w", err)
+	}
+	group, err := template.New("group").Parse(config.Group)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the group template: %w", err)
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	conn.Headers = make(map[string]kommons.EnvVar, len(config.Headers)+1)
+	for name, header := range config.Headers {
+		conn.Headers[name] = header
+	}
+	conn.Headers["X-Papertrail-Token"] = *config.APIToken
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &PapertrailSearch{config: config, client: client, system: system, group: group}, nil
+}
+
+type event struct {
+	ID         string `json:"id"`
+	ReceivedAt string `json:"received_at"`
+	SourceName string `json:"source_name"`
+	SourceIP   string `json:"source_ip"`
+	Hostname   string `json:"hostname"`
+	Program    string `json:"program"`
+	Severity   string `json:"severity"`
+	Facility   string `json:"facility"`
+	Message    string `json:"message"`
+}
+
+// Search gets the most recent events of the time range of the search, up to the limit.
+// The page of the search is the max_id of the older events, i.e. the min_id of the previous page.
+func (t *PapertrailSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	query := url.Values{}
+	if q.Query != "" {
+		query.Set("q", q.Query)
+	}
+	for name, tpl := range map[string]*template.Template{"system_id": t.system, "group_id": t.group} {
+		var buf bytes.Buffer
+		if err := tpl.Execute(&buf, q); err != nil {
+			return logs.SearchResults{}, fmt.Errorf("error executing the %s template: %w", tpl.Name(), err)
+		}
+		if value := strings.TrimSpace(buf.String()); value != "" {
+			query.Set(name, value)
+		}
+	}
+	if start := q.GetStart(); start != nil {
+		query.Set("min_time", strconv.FormatInt(start.Unix(), 10))
+	}
+	if end := q.GetEnd(); end != nil {
+		query.Set("max_time", strconv.FormatInt(end.Unix(), 10))
+	}
+	if q.Limit > 0 && q.Limit < maxLimit {
+		query.Set("limit", strconv.FormatInt(q.Limit, 10))
+	} else if q.Limit > 0 {
+		query.Set("limit", strconv.Itoa(maxLimit))
+	}
+	if q.Page != "" {
+		query.Set("max_id", q.Page)
+	}
+
+	var res struct {
+		Events           []event `json:"events"`
+		MinID            string  `json:"min_id"`
+		ReachedBeginning bool    `json:"reached_beginning"`
+		ReachedTimeLimit bool    `json:"reached_time_limit"`
+	}
+	if err := t.client.Get(ctx, "/api/v1/events/search.json", query, &res); err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	results := logs.SearchResults{Total: len(res.Events)}
+	for _, e := range res.Events {
+		results.Results = append(results.Results, t.result(e))
+	}
+	if len(res.Events) > 0 && !res.ReachedBeginning && !res.ReachedTimeLimit {
+		results.NextPage = res.MinID
+	}
+	return results, nil
+}
+
+func (t *PapertrailSearch) result(e event) logs.Result {
+	r := logs.Result{Id: e.ID, Message: e.Message, Labels: make(map[string]string, 6+len(t.config.Labels))}
+	if ts, err := time.Parse(time.RFC3339, e.ReceivedAt); err == nil {
+		r.Time = ts.UTC().Format(time.RFC3339Nano)
+	}
+	for label, value := range map[string]string{
+		"hostname": e.Hostname, "program": e.Program, "severity": e.Severity, "facility": e.Facility,
+		"source_name": e.SourceName, "source_ip": e.SourceIP,
+	} {
+		if value != "" {
+			r.Labels[label] = value
+		}
+	}
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *PapertrailSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Papertrail backend, the query of the search is sent as Papertrail search syntax
+func (t *PapertrailSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck lists the systems of the user
+func (t *PapertrailSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/v1/systems.json", nil, nil)
+}
@@ -0,0 +1,78 @@
+package papertrail
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestPapertrailSearch(t *testing.T) {
+	var queries []url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Papertrail-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/events/search.json" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		queries = append(queries, r.URL.Query())
+		if r.URL.Query().Get("max_id") != "" {
+			_, _ = w.Write([]byte(`{"events":[
+				{"id":"100","received_at":"2023-05-01T13:59:59+02:00","hostname":"web-1","program":"nginx","message":"GET /"}
+			],"min_id":"100","max_id":"100","reached_beginning":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"events":[
+			{"id":"101","received_at":"2023-05-01T14:00:00+02:00","source_name":"web-1","source_ip":"10.0.0.1","hostname":"web-1","program":"nginx","severity":"Info","facility":"User","message":"GET /"},
+			{"id":"102","received_at":"2023-05-01T14:00:01+02:00","hostname":"web-1","program":"api","severity":"Error","message":"connection timeout"}
+		],"min_id":"101","max_id":"102","reached_record_limit":true}`))
+	}))
+	defer server.Close()
+
+	backend, err := NewPapertrailSearchBackend(nil, &logs.PapertrailBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "papertrail"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		APIToken:       &kommons.EnvVar{Value: "secret"},
+		System:         `{{index .Labels "hostname"}}`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := &logs.SearchParams{Query: "nginx OR api", Labels: map[string]string{"hostname": "web-1"}, Start: "2023-05-01T11:00:00Z", End: "2023-05-01T12:00:00Z", Limit: 2}
+	res, err := backend.Search(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, NextPage: "101", Results: []logs.Result{
+		{Id: "101", Time: "2023-05-01T12:00:00Z", Message: "GET /", Labels: map[string]string{
+			"hostname": "web-1", "program": "nginx", "severity": "Info", "facility": "User", "source_name": "web-1", "source_ip": "10.0.0.1", "source": "papertrail",
+		}},
+		{Id: "102", Time: "2023-05-01T12:00:01Z", Message: "connection timeout", Labels: map[string]string{"hostname": "web-1", "program": "api", "severity": "Error", "source": "papertrail"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	q.Page = res.NextPage
+	res, err = backend.Search(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.NextPage != "" || len(res.Results) != 1 {
+		t.Errorf("Search() of the last page = %+v", res)
+	}
+
+	wantQuery := url.Values{"q": {"nginx OR api"}, "system_id": {"web-1"}, "min_time": {"1682938800"}, "max_time": {"1682942400"}, "limit": {"2"}}
+	if len(queries) != 2 || !reflect.DeepEqual(queries[0], wantQuery) || queries[1].Get("max_id") != "101" {
+		t.Errorf("queries = %v, want %v and the max_id of the next page", queries, wantQuery)
+	}
+}
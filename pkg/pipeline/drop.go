@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// DropStage drops the results matching the drop rules or not matching the keep rules
+type DropStage struct {
+	drop []filterRule
+	keep []filterRule
+}
+
+type filterRule struct {
+	message *regexp.Regexp
+	labels  map[string]string
+}
+
+func (t filterRule) match(r *logs.Result) bool {
+	if t.message != nil && !t.message.MatchString(r.Message) {
+		return false
+	}
+	return matchLabels(r.Labels, t.labels)
+}
+
+func NewDropStage(config logs.DropStep) (*DropStage, error) {
+	if len(config.Rules) == 0 && len(config.Keep) == 0 {
+		return nil, fmt.Errorf("either drop or keep rules are required")
+	}
+
+	drop, err := newFilterRules(config.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	keep, err := newFilterRules(config.Keep)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DropStage{drop: drop, keep: keep}, nil
+}
+
+func newFilterRules(rules []logs.FilterRule) ([]filterRule, error) {
+	var filters []filterRule
+	for _, rule := range rules {
+		if rule.Message == "" && len(rule.Labels) == 0 {
+			return nil, fmt.Errorf("filter rule without any condition")
+		}
+
+		filter := filterRule{labels: rule.Labels}
+		if rule.Message != "" {
+			re, err := regexp.Compile(rule.Message)
+			if err != nil {
+				return nil, fmt.Errorf("error compiling filter pattern %q: %w", rule.Message, err)
+			}
+			filter.message = re
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
+
+func (t *DropStage) Process(r *logs.Result) bool {
+	for _, rule := range t.drop {
+		if rule.match(r) {
+			return false
+		}
+	}
+
+	if len(t.keep) == 0 {
+		return true
+	}
+
+	for _, rule := range t.keep {
+		if rule.match(r) {
+			return true
+		}
+	}
+	return false
+}
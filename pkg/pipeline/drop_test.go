@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestDropStage(t *testing.T) {
+	tests := []struct {
+		name   string
+		config logs.DropStep
+		result logs.Result
+		keep   bool
+	}{
+		{name: "health check", config: logs.DropStep{Rules: []logs.FilterRule{{Message: `GET /healthz`}}}, result: logs.Result{Message: `"GET /healthz HTTP/1.1" 200`}},
+		{name: "other request", config: logs.DropStep{Rules: []logs.FilterRule{{Message: `GET /healthz`}}}, result: logs.Result{Message: `"GET /api HTTP/1.1" 200`}, keep: true},
+		{name: "label equality", config: logs.DropStep{Rules: []logs.FilterRule{{Labels: map[string]string{"level": "debug,trace"}}}}, result: logs.Result{Labels: map[string]string{"level": "trace"}}},
+		{
+			name:   "all conditions must match",
+			config: logs.DropStep{Rules: []logs.FilterRule{{Message: "TLS handshake", Labels: map[string]string{"app": "ingress"}}}},
+			result: logs.Result{Message: "TLS handshake error", Labels: map[string]string{"app": "api"}},
+			keep:   true,
+		},
+		{name: "keep rules", config: logs.DropStep{Keep: []logs.FilterRule{{Labels: map[string]string{"level": "error"}}}}, result: logs.Result{Labels: map[string]string{"level": "info"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := NewDropStage(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if keep := stage.Process(&tt.result); keep != tt.keep {
+				t.Errorf("Process() = %v, want %v", keep, tt.keep)
+			}
+		})
+	}
+}
+
+func TestPipeline_CountsDroppedLines(t *testing.T) {
+	p, err := New("drop-test", []logs.PipelineStep{{Drop: &logs.DropStep{Rules: []logs.FilterRule{{Message: "noise"}}}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := p.Process([]logs.Result{{Message: "noise"}, {Message: "signal"}, {Message: "more noise"}})
+	if len(results) != 1 {
+		t.Errorf("expected 1 result, got %v", results)
+	}
+
+	if dropped := testutil.ToFloat64(droppedLines.WithLabelValues("drop-test", "drop")); dropped != 2 {
+		t.Errorf("expected 2 dropped lines, got %v", dropped)
+	}
+}
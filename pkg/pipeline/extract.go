@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// ExtractStage adds the named captures of the first matching regular expression as labels
+type ExtractStage struct {
+	patterns []*regexp.Regexp
+	source   string
+	selector map[string]string
+}
+
+func NewExtractStage(config logs.ExtractStep) (*ExtractStage, error) {
+	if len(config.Patterns) == 0 {
+		return nil, fmt.Errorf("extract patterns are required")
+	}
+
+	stage := &ExtractStage{source: config.Source, selector: config.Labels}
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling extract pattern %q: %w", pattern, err)
+		}
+
+		if !hasNamedGroup(re) {
+			return nil, fmt.Errorf("extract pattern %q has no named capture group", pattern)
+		}
+		stage.patterns = append(stage.patterns, re)
+	}
+
+	return stage, nil
+}
+
+func (t *ExtractStage) Process(r *logs.Result) bool {
+	if !matchLabels(r.Labels, t.selector) {
+		return true
+	}
+
+	text := r.Message
+	if t.source != "" {
+		text = r.Labels[t.source]
+	}
+
+	for _, re := range t.patterns {
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+
+		for i, name := range re.SubexpNames() {
+			if name == "" || match[i] == "" {
+				continue
+			}
+			if r.Labels == nil {
+				r.Labels = make(map[string]string)
+			}
+			r.Labels[name] = match[i]
+		}
+		break
+	}
+
+	return true
+}
+
+func hasNamedGroup(re *regexp.Regexp) bool {
+	for _, name := range re.SubexpNames() {
+		if name != "" {
+			return true
+		}
+	}
+	return false
+}
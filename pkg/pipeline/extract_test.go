@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestExtractStage(t *testing.T) {
+	accessLog := `status=(?P<status>\d+) latency=(?P<latency_ms>\d+)ms`
+	tests := []struct {
+		name   string
+		config logs.ExtractStep
+		result logs.Result
+		want   map[string]string
+	}{
+		{
+			name:   "named captures",
+			config: logs.ExtractStep{Patterns: []string{accessLog}},
+			result: logs.Result{Message: "GET / status=200 latency=12ms"},
+			want:   map[string]string{"status": "200", "latency_ms": "12"},
+		},
+		{
+			name:   "source label",
+			config: logs.ExtractStep{Patterns: []string{`^(?P<app>[a-z]+)-[0-9a-f]+$`}, Source: "pod"},
+			result: logs.Result{Labels: map[string]string{"pod": "nginx-7f9c"}},
+			want:   map[string]string{"pod": "nginx-7f9c", "app": "nginx"},
+		},
+		{
+			name:   "label selector not matching",
+			config: logs.ExtractStep{Patterns: []string{accessLog}, Labels: map[string]string{"type": "access"}},
+			result: logs.Result{Message: "status=500 latency=1ms", Labels: map[string]string{"type": "error"}},
+			want:   map[string]string{"type": "error"},
+		},
+		{
+			name:   "label selector matching",
+			config: logs.ExtractStep{Patterns: []string{accessLog}, Labels: map[string]string{"type": "access,proxy"}},
+			result: logs.Result{Message: "status=500 latency=1ms", Labels: map[string]string{"type": "proxy"}},
+			want:   map[string]string{"type": "proxy", "status": "500", "latency_ms": "1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := NewExtractStage(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stage.Process(&tt.result)
+			if !reflect.DeepEqual(tt.result.Labels, tt.want) {
+				t.Errorf("labels = %v, want %v", tt.result.Labels, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewExtractStage(logs.ExtractStep{Patterns: []string{`status=\d+`}}); err == nil {
+		t.Errorf("expected an error for a pattern without named groups")
+	}
+}
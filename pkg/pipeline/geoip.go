@@ -0,0 +1,133 @@
+package pipeline
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// ipAddress finds the candidate IPv4 and IPv6 addresses, validated with net.ParseIP
+var ipAddress = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|(?:[0-9a-fA-F]{0,4}:){2,7}[0-9a-fA-F]{0,4}`)
+
+// geoIPReaders caches the opened databases since they're shared between the backends
+var geoIPReaders = struct {
+	sync.Mutex
+	readers map[string]*geoip2.Reader
+}{readers: make(map[string]*geoip2.Reader)}
+
+func openGeoIPDatabase(path string) (*geoip2.Reader, error) {
+	geoIPReaders.Lock()
+	defer geoIPReaders.Unlock()
+
+	if reader, ok := geoIPReaders.readers[path]; ok {
+		return reader, nil
+	}
+
+	reader, err := geoip2.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening the geoip database %s: %w", path, err)
+	}
+	geoIPReaders.readers[path] = reader
+	return reader, nil
+}
+
+// GeoIPStage adds the location and the autonomous system of the IP address in the results
+type GeoIPStage struct {
+	city    *geoip2.Reader
+	asn     *geoip2.Reader
+	sources []string
+	prefix  string
+}
+
+func NewGeoIPStage(config logs.GeoIPStep) (*GeoIPStage, error) {
+	if config.Database == "" && config.ASNDatabase == "" {
+		return nil, fmt.Errorf("either a database or an asn database is required")
+	}
+
+	stage := &GeoIPStage{sources: config.Sources, prefix: config.Prefix}
+	if stage.prefix == "" {
+		stage.prefix = "geo_"
+	}
+
+	var err error
+	if config.Database != "" {
+		if stage.city, err = openGeoIPDatabase(config.Database); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.ASNDatabase != "" {
+		if stage.asn, err = openGeoIPDatabase(config.ASNDatabase); err != nil {
+			return nil, err
+		}
+	}
+
+	return stage, nil
+}
+
+func (t *GeoIPStage) Process(r *logs.Result) bool {
+	ip := findIP(r, t.sources)
+	if ip == nil {
+		return true
+	}
+
+	labels := make(map[string]string)
+	if t.city != nil {
+		if city, err := t.city.City(ip); err == nil {
+			labels["country"] = city.Country.IsoCode
+			labels["city"] = city.City.Names["en"]
+		}
+	}
+
+	if t.asn != nil {
+		if asn, err := t.asn.ASN(ip); err == nil && asn.AutonomousSystemNumber != 0 {
+			labels["asn"] = strconv.FormatUint(uint64(asn.AutonomousSystemNumber), 10)
+			labels["as_org"] = asn.AutonomousSystemOrganization
+		}
+	}
+
+	for k, v := range labels {
+		if v == "" {
+			continue
+		}
+		if r.Labels == nil {
+			r.Labels = make(map[string]string)
+		}
+		r.Labels[t.prefix+k] = v
+	}
+
+	return true
+}
+
+// findIP returns the first public IP address in the source labels
+// or in the message when no sources are given.
+func findIP(r *logs.Result, sources []string) net.IP {
+	if len(sources) == 0 {
+		for _, candidate := range ipAddress.FindAllString(r.Message, -1) {
+			if ip := publicIP(candidate); ip != nil {
+				return ip
+			}
+		}
+		return nil
+	}
+
+	for _, source := range sources {
+		if ip := publicIP(r.Labels[source]); ip != nil {
+			return ip
+		}
+	}
+	return nil
+}
+
+func publicIP(s string) net.IP {
+	ip := net.ParseIP(s)
+	if ip == nil || ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return nil
+	}
+	return ip
+}
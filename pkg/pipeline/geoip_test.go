@@ -0,0 +1,34 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestFindIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		result  logs.Result
+		sources []string
+		want    string
+	}{
+		{name: "message", result: logs.Result{Message: `81.2.69.142 - - "GET / HTTP/1.1" 200`}, want: "81.2.69.142"},
+		{name: "private addresses skipped", result: logs.Result{Message: "proxy 10.0.0.1 forwarded 127.0.0.1 for 2001:db8::1"}, want: "2001:db8::1"},
+		{name: "no public address", result: logs.Result{Message: "from 192.168.1.10"}},
+		{name: "source label", result: logs.Result{Message: "81.2.69.142", Labels: map[string]string{"client": "89.160.20.112"}}, sources: []string{"client"}, want: "89.160.20.112"},
+		{name: "time is not an ip", result: logs.Result{Message: "at 12:30:45 from 10.1.1.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if ip := findIP(&tt.result, tt.sources); ip != nil {
+				got = ip.String()
+			}
+			if got != tt.want {
+				t.Errorf("findIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
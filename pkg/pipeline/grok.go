@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/vjeantet/grok"
+)
+
+// GrokStage adds the named captures of the first matching grok pattern as labels
+type GrokStage struct {
+	grok     *grok.Grok
+	patterns []string
+	source   string
+}
+
+func NewGrokStage(config logs.GrokStep) (*GrokStage, error) {
+	if len(config.Patterns) == 0 {
+		return nil, fmt.Errorf("grok patterns are required")
+	}
+
+	g, err := grok.NewWithConfig(&grok.Config{
+		NamedCapturesOnly: true,
+		RemoveEmptyValues: true,
+		Patterns:          config.Definitions,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading the grok definitions: %w", err)
+	}
+
+	// Compile the patterns upfront to catch the invalid ones
+	for _, pattern := range config.Patterns {
+		if _, err := g.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("error compiling grok pattern %q: %w", pattern, err)
+		}
+	}
+
+	return &GrokStage{grok: g, patterns: config.Patterns, source: config.Source}, nil
+}
+
+func (t *GrokStage) Process(r *logs.Result) bool {
+	text := r.Message
+	if t.source != "" {
+		text = r.Labels[t.source]
+	}
+
+	for _, pattern := range t.patterns {
+		captures, err := t.grok.Parse(pattern, text)
+		if err != nil || len(captures) == 0 {
+			continue
+		}
+
+		if r.Labels == nil {
+			r.Labels = make(map[string]string, len(captures))
+		}
+		for k, v := range captures {
+			r.Labels[k] = v
+		}
+		break
+	}
+
+	return true
+}
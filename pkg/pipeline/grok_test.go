@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestGrokStage(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  logs.GrokStep
+		message string
+		want    map[string]string
+	}{
+		{
+			name:    "standard pattern",
+			config:  logs.GrokStep{Patterns: []string{`%{IPORHOST:client} %{WORD:method} %{URIPATHPARAM:path} %{NUMBER:status:int}`}},
+			message: "10.0.0.1 GET /index.html 200",
+			want:    map[string]string{"client": "10.0.0.1", "method": "GET", "path": "/index.html", "status": "200"},
+		},
+		{
+			name: "first matching pattern",
+			config: logs.GrokStep{Patterns: []string{
+				`^%{LOGLEVEL:level}: %{GREEDYDATA:msg}`,
+				`^\[%{LOGLEVEL:level}\] %{GREEDYDATA:msg}`,
+			}},
+			message: "[ERROR] disk full",
+			want:    map[string]string{"level": "ERROR", "msg": "disk full"},
+		},
+		{
+			name: "custom definitions",
+			config: logs.GrokStep{
+				Patterns:    []string{`order %{ORDERID:order}`},
+				Definitions: map[string]string{"ORDERID": `ORD-[0-9]+`},
+			},
+			message: "placed order ORD-42",
+			want:    map[string]string{"order": "ORD-42"},
+		},
+		{
+			name:    "no match",
+			config:  logs.GrokStep{Patterns: []string{`^%{IP:client}$`}},
+			message: "not an ip",
+			want:    map[string]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := NewGrokStage(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := logs.Result{Message: tt.message, Labels: map[string]string{}}
+			stage.Process(&r)
+			if !reflect.DeepEqual(r.Labels, tt.want) {
+				t.Errorf("labels = %v, want %v", r.Labels, tt.want)
+			}
+		})
+	}
+}
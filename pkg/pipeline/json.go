@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// JSONStage lifts the fields of JSON messages into labels
+type JSONStage struct {
+	keys    []string
+	message string
+}
+
+func NewJSONStage(config logs.JSONStep) *JSONStage {
+	return &JSONStage{keys: config.Keys, message: config.Message}
+}
+
+func (t *JSONStage) Process(r *logs.Result) bool {
+	trimmed := strings.TrimSpace(r.Message)
+	if !strings.HasPrefix(trimmed, "{") {
+		return true
+	}
+
+	var fields map[string]any
+	decoder := json.NewDecoder(strings.NewReader(trimmed))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return true
+	}
+
+	if r.Labels == nil {
+		r.Labels = make(map[string]string)
+	}
+
+	if len(t.keys) == 0 {
+		for k, v := range fields {
+			r.Labels[k] = stringify(v)
+		}
+	}
+
+	for _, key := range t.keys {
+		if v, ok := lookup(fields, key); ok {
+			r.Labels[key] = stringify(v)
+		}
+	}
+
+	if t.message != "" {
+		if v, ok := lookup(fields, t.message); ok {
+			r.Message = stringify(v)
+		}
+	}
+
+	return true
+}
+
+// lookup returns the value of the dot separated path
+func lookup(fields map[string]any, path string) (any, bool) {
+	// Keys containing dots (e.g. "log.level" in ECS logs) take precedence over nested fields
+	if v, ok := fields[path]; ok {
+		return v, true
+	}
+
+	head, tail, found := strings.Cut(path, ".")
+	if !found {
+		return nil, false
+	}
+
+	nested, ok := fields[head].(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	return lookup(nested, tail)
+}
+
+func stringify(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return fmt.Sprint(v)
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
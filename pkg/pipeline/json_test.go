@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestJSONStage(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      logs.JSONStep
+		message     string
+		wantMessage string
+		wantLabels  map[string]string
+	}{
+		{
+			name:        "configured keys",
+			config:      logs.JSONStep{Keys: []string{"level", "logger", "trace.id"}, Message: "msg"},
+			message:     `{"level":"info","logger":"http","msg":"request served","trace":{"id":"abc"},"status":200}`,
+			wantMessage: "request served",
+			wantLabels:  map[string]string{"level": "info", "logger": "http", "trace.id": "abc"},
+		},
+		{
+			name:        "all top level fields",
+			message:     `{"level":"warn","status":503,"ok":false,"tags":["a"]}`,
+			wantMessage: `{"level":"warn","status":503,"ok":false,"tags":["a"]}`,
+			wantLabels:  map[string]string{"level": "warn", "status": "503", "ok": "false", "tags": `["a"]`},
+		},
+		{
+			name:        "dotted key",
+			config:      logs.JSONStep{Keys: []string{"log.level"}},
+			message:     `{"log.level":"error"}`,
+			wantMessage: `{"log.level":"error"}`,
+			wantLabels:  map[string]string{"log.level": "error"},
+		},
+		{
+			name:        "not json",
+			config:      logs.JSONStep{Keys: []string{"level"}, Message: "msg"},
+			message:     "level=info msg=hello",
+			wantMessage: "level=info msg=hello",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := logs.Result{Message: tt.message}
+			NewJSONStage(tt.config).Process(&r)
+			if r.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", r.Message, tt.wantMessage)
+			}
+			if !reflect.DeepEqual(r.Labels, tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", r.Labels, tt.wantLabels)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package pipeline
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// LabelMapStage renames and filters the labels of the results
+type LabelMapStage struct {
+	rename map[string]string
+	drop   []string
+	keep   []string
+}
+
+func NewLabelMapStage(config logs.LabelMapStep) (*LabelMapStage, error) {
+	for _, pattern := range append(append([]string{}, config.Drop...), config.Keep...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid label pattern %q: %w", pattern, err)
+		}
+	}
+
+	return &LabelMapStage{rename: config.Rename, drop: config.Drop, keep: config.Keep}, nil
+}
+
+func (t *LabelMapStage) Process(r *logs.Result) bool {
+	if len(r.Labels) == 0 {
+		return true
+	}
+
+	labels := make(map[string]string, len(r.Labels))
+	for k, v := range r.Labels {
+		if renamed, ok := t.rename[k]; ok {
+			k = renamed
+		} else if _, ok := labels[k]; ok {
+			// Don't override the value of a renamed label
+			continue
+		}
+
+		if matchGlob(k, t.drop) {
+			continue
+		}
+
+		if len(t.keep) > 0 && !matchGlob(k, t.keep) {
+			continue
+		}
+
+		labels[k] = v
+	}
+
+	r.Labels = labels
+	return true
+}
+
+func matchGlob(s string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestLabelMapStage(t *testing.T) {
+	tests := []struct {
+		name   string
+		config logs.LabelMapStep
+		labels map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "rename",
+			config: logs.LabelMapStep{Rename: map[string]string{"kubernetes.pod_name": "pod", "kubernetes.namespace_name": "namespace"}},
+			labels: map[string]string{"kubernetes.pod_name": "nginx", "kubernetes.namespace_name": "default", "host": "node-1"},
+			want:   map[string]string{"pod": "nginx", "namespace": "default", "host": "node-1"},
+		},
+		{
+			name:   "renamed label wins over an existing one",
+			config: logs.LabelMapStep{Rename: map[string]string{"kubernetes.pod_name": "pod"}},
+			labels: map[string]string{"kubernetes.pod_name": "nginx", "pod": "stale"},
+			want:   map[string]string{"pod": "nginx"},
+		},
+		{
+			name:   "drop",
+			config: logs.LabelMapStep{Drop: []string{"agent.*", "ecs.version"}},
+			labels: map[string]string{"agent.name": "filebeat", "agent.version": "8", "ecs.version": "1", "pod": "nginx"},
+			want:   map[string]string{"pod": "nginx"},
+		},
+		{
+			name:   "keep after rename",
+			config: logs.LabelMapStep{Rename: map[string]string{"k8s.pod": "pod"}, Keep: []string{"pod", "namespace"}},
+			labels: map[string]string{"k8s.pod": "nginx", "namespace": "default", "stream": "stdout"},
+			want:   map[string]string{"pod": "nginx", "namespace": "default"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := NewLabelMapStage(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := logs.Result{Labels: tt.labels}
+			stage.Process(&r)
+			if !reflect.DeepEqual(r.Labels, tt.want) {
+				t.Errorf("labels = %v, want %v", r.Labels, tt.want)
+			}
+		})
+	}
+}
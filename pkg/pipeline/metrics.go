@@ -0,0 +1,11 @@
+package pipeline
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var droppedLines = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "apm_hub_pipeline_dropped_lines_total",
+	Help: "The number of results dropped by the processing pipelines",
+}, []string{"backend", "stage"})
@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+)
+
+// GlobalPipeline is applied to the results of every backend after their own pipeline.
+// It's nil when no global pipeline is configured.
+var GlobalPipeline *Pipeline
+
+// Stage processes a single result in place.
+// It returns false when the result must be dropped.
+type Stage interface {
+	Process(r *logs.Result) bool
+}
+
+// Pipeline runs the results of a backend through its stages in order.
+type Pipeline struct {
+	// name of the backend, used in the metrics
+	name   string
+	stages []namedStage
+}
+
+type namedStage struct {
+	name string
+	Stage
+}
+
+// New creates the pipeline from the backend's pipeline steps followed by its transforms.
+// It returns nil when no steps are configured.
+func New(name string, steps []logs.PipelineStep, transforms []logs.TransformStep) (*Pipeline, error) {
+	if len(steps) == 0 && len(transforms) == 0 {
+		return nil, nil
+	}
+
+	p := &Pipeline{name: name}
+	for i, step := range steps {
+		stage, err := newStage(step)
+		if err != nil {
+			return nil, fmt.Errorf("error creating pipeline step[%d]: %w", i, err)
+		}
+		p.stages = append(p.stages, namedStage{name: stepName(step), Stage: stage})
+	}
+
+	for i, transform := range transforms {
+		stage, err := NewTransformStage(transform)
+		if err != nil {
+			return nil, fmt.Errorf("error creating transform[%d]: %w", i, err)
+		}
+
+		name := "template"
+		if transform.CEL != "" {
+			name = "cel"
+		}
+		p.stages = append(p.stages, namedStage{name: name, Stage: stage})
+	}
+
+	return p, nil
+}
+
+func newStage(step logs.PipelineStep) (Stage, error) {
+	switch {
+	case step.Redact != nil:
+		return NewRedactStage(*step.Redact)
+	case step.Grok != nil:
+		return NewGrokStage(*step.Grok)
+	case step.Extract != nil:
+		return NewExtractStage(*step.Extract)
+	case step.JSON != nil:
+		return NewJSONStage(*step.JSON), nil
+	case step.Severity != nil:
+		return NewSeverityStage(*step.Severity)
+	case step.Timestamp != nil:
+		return NewTimestampStage(*step.Timestamp)
+	case step.GeoIP != nil:
+		return NewGeoIPStage(*step.GeoIP)
+	case step.Truncate != nil:
+		return NewTruncateStage(*step.Truncate)
+	case step.LabelMap != nil:
+		return NewLabelMapStage(*step.LabelMap)
+	case step.Drop != nil:
+		return NewDropStage(*step.Drop)
+	case step.Sanitize != nil:
+		return NewSanitizeStage(*step.Sanitize)
+	case step.WASM != nil:
+		return NewWASMStage(*step.WASM)
+	}
+
+	return nil, fmt.Errorf("no step configured")
+}
+
+// stepName returns the name of the step set in the pipeline step
+func stepName(step logs.PipelineStep) string {
+	v := reflect.ValueOf(step)
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Field(i).IsNil() {
+			name, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+			return name
+		}
+	}
+	return ""
+}
+
+// Process implements logs.Processor
+func (t *Pipeline) Process(results []logs.Result) []logs.Result {
+	if t == nil {
+		return results
+	}
+
+	processed := results[:0]
+	for _, r := range results {
+		// Backends may share the same labels map across results
+		// so the stages get their own copy to modify.
+		r.Labels = cloneLabels(r.Labels)
+
+		keep := true
+		for _, stage := range t.stages {
+			if keep = stage.Process(&r); !keep {
+				droppedLines.WithLabelValues(t.name, stage.name).Inc()
+				break
+			}
+		}
+
+		if keep {
+			processed = append(processed, r)
+		}
+	}
+
+	return processed
+}
+
+// matchLabels returns true if the labels match the selector.
+// Selector values are comma separated lists, same as the route labels.
+func matchLabels(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		val, ok := labels[k]
+		if !ok || !collections.MatchItems(val, strings.Split(v, ",")...) {
+			return false
+		}
+	}
+	return true
+}
+
+func cloneLabels(labels map[string]string) map[string]string {
+	if labels == nil {
+		return nil
+	}
+
+	clone := make(map[string]string, len(labels))
+	for k, v := range labels {
+		clone[k] = v
+	}
+	return clone
+}
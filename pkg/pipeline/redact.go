@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+const defaultReplacement = "[REDACTED]"
+
+// builtinPatterns are the sensitive data patterns shipped with apm-hub
+var builtinPatterns = map[string]*regexp.Regexp{
+	"creditCard":  regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+	"bearerToken": regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9\-._~+/]+=*`),
+	"awsKey":      regexp.MustCompile(`\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[0-9A-Z]{16}\b|(?i)aws_secret_access_key\s*[:=]\s*["']?[A-Za-z0-9/+=]{40}["']?`),
+	"email":       regexp.MustCompile(`\b[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}\b`),
+}
+
+// RedactStage masks the sensitive data in the message and the labels of the results
+type RedactStage struct {
+	patterns    []redactPattern
+	replacement string
+}
+
+type redactPattern struct {
+	regexp *regexp.Regexp
+
+	// luhn only masks the matches passing the luhn checksum
+	// to avoid masking ids and timestamps that look like card numbers.
+	luhn bool
+}
+
+func NewRedactStage(config logs.RedactStep) (*RedactStage, error) {
+	stage := &RedactStage{replacement: config.Replacement}
+	if stage.replacement == "" {
+		stage.replacement = defaultReplacement
+	}
+
+	builtin := config.Builtin
+	if len(builtin) == 0 && len(config.Patterns) == 0 {
+		builtin = []string{"bearerToken", "awsKey", "email", "creditCard"}
+	}
+
+	for _, name := range builtin {
+		re, ok := builtinPatterns[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown builtin redaction pattern %q", name)
+		}
+		stage.patterns = append(stage.patterns, redactPattern{regexp: re, luhn: name == "creditCard"})
+	}
+
+	for _, pattern := range config.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error compiling redaction pattern %q: %w", pattern, err)
+		}
+		stage.patterns = append(stage.patterns, redactPattern{regexp: re})
+	}
+
+	return stage, nil
+}
+
+func (t *RedactStage) Process(r *logs.Result) bool {
+	r.Message = t.redact(r.Message)
+	for k, v := range r.Labels {
+		r.Labels[k] = t.redact(v)
+	}
+	return true
+}
+
+func (t *RedactStage) redact(s string) string {
+	for _, p := range t.patterns {
+		if p.luhn {
+			s = p.regexp.ReplaceAllStringFunc(s, func(match string) string {
+				if !luhnValid(match) {
+					return match
+				}
+				return t.replacement
+			})
+			continue
+		}
+		s = p.regexp.ReplaceAllLiteralString(s, t.replacement)
+	}
+	return s
+}
+
+func luhnValid(number string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, number)
+
+	var sum int
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,71 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// ansiEscape matches the CSI (colors, cursor movements) and OSC (titles, hyperlinks) sequences
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// SanitizeStage strips the terminal escapes and non-printable characters from the messages
+type SanitizeStage struct {
+	ansi        bool
+	control     bool
+	invalidUTF8 bool
+	replacement string
+}
+
+func NewSanitizeStage(config logs.SanitizeStep) (*SanitizeStage, error) {
+	stage := &SanitizeStage{replacement: config.Replacement}
+	if len(config.Strip) == 0 {
+		config.Strip = []string{"ansi", "control", "invalidUTF8"}
+	}
+
+	for _, strip := range config.Strip {
+		switch strip {
+		case "ansi":
+			stage.ansi = true
+		case "control":
+			stage.control = true
+		case "invalidUTF8":
+			stage.invalidUTF8 = true
+		default:
+			return nil, fmt.Errorf("unknown strip option: %s", strip)
+		}
+	}
+
+	return stage, nil
+}
+
+func (t *SanitizeStage) Process(r *logs.Result) bool {
+	// ANSI sequences are removed before the control characters as they start with ESC
+	if t.ansi && strings.IndexByte(r.Message, '\x1b') >= 0 {
+		r.Message = ansiEscape.ReplaceAllString(r.Message, "")
+	}
+	if t.invalidUTF8 && !utf8.ValidString(r.Message) {
+		r.Message = strings.ToValidUTF8(r.Message, t.replacement)
+	}
+	if t.control && strings.IndexFunc(r.Message, isControl) >= 0 {
+		var b strings.Builder
+		b.Grow(len(r.Message))
+		for _, c := range r.Message {
+			if isControl(c) {
+				b.WriteString(t.replacement)
+			} else {
+				b.WriteRune(c)
+			}
+		}
+		r.Message = b.String()
+	}
+	return true
+}
+
+func isControl(c rune) bool {
+	return unicode.IsControl(c) && c != '\t' && c != '\n'
+}
@@ -0,0 +1,44 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestSanitizeStage(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  logs.SanitizeStep
+		message string
+		want    string
+	}{
+		{name: "colors", message: "\x1b[1;31mERROR\x1b[0m failed", want: "ERROR failed"},
+		{name: "cursor and title", message: "\x1b]0;title\x07\x1b[2Kdone", want: "done"},
+		{name: "control characters", message: "a\x00b\x08c\td\ne\r", want: "abc\td\ne"},
+		{name: "invalid utf8", message: "a\xffb", want: "ab"},
+		{name: "replacement", config: logs.SanitizeStep{Replacement: "?"}, message: "a\x00b\xffc", want: "a?b?c"},
+		{name: "only ansi", config: logs.SanitizeStep{Strip: []string{"ansi"}}, message: "\x1b[32mok\x1b[0m\x00", want: "ok\x00"},
+		{name: "only control", config: logs.SanitizeStep{Strip: []string{"control"}}, message: "\x1b[32mok", want: "[32mok"},
+		{name: "unicode is kept", message: "héllo ✓ �", want: "héllo ✓ �"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := NewSanitizeStage(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := logs.Result{Message: tt.message}
+			stage.Process(&r)
+			if r.Message != tt.want {
+				t.Errorf("message = %q, want %q", r.Message, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewSanitizeStage(logs.SanitizeStep{Strip: []string{"emoji"}}); err == nil {
+		t.Error("expected an error for an unknown strip option")
+	}
+}
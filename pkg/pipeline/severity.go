@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+)
+
+// The canonical severities
+const (
+	SeverityTrace   = "trace"
+	SeverityDebug   = "debug"
+	SeverityInfo    = "info"
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+	SeverityFatal   = "fatal"
+)
+
+var severities = []string{SeverityTrace, SeverityDebug, SeverityInfo, SeverityWarning, SeverityError, SeverityFatal}
+
+var defaultSeveritySources = []string{"severity", "level", "lvl", "loglevel", "log.level", "priority"}
+
+// severitySpellings maps the lower cased spellings to the canonical severities
+var severitySpellings = map[string]string{
+	"t": SeverityTrace, "trc": SeverityTrace, "trace": SeverityTrace, "verbose": SeverityTrace, "finest": SeverityTrace,
+	"d": SeverityDebug, "dbg": SeverityDebug, "debug": SeverityDebug, "fine": SeverityDebug,
+	"i": SeverityInfo, "inf": SeverityInfo, "info": SeverityInfo, "information": SeverityInfo, "informational": SeverityInfo, "notice": SeverityInfo,
+	"w": SeverityWarning, "wrn": SeverityWarning, "warn": SeverityWarning, "warning": SeverityWarning,
+	"e": SeverityError, "err": SeverityError, "eror": SeverityError, "error": SeverityError,
+	"f": SeverityFatal, "c": SeverityFatal, "crit": SeverityFatal, "critical": SeverityFatal, "fatal": SeverityFatal,
+	"alert": SeverityFatal, "emerg": SeverityFatal, "emergency": SeverityFatal, "panic": SeverityFatal,
+}
+
+var (
+	messageSeverity = regexp.MustCompile(`(?i)^\s*[\[<(]?(trace|debug|info|notice|warn|warning|error|err|crit|critical|fatal|panic)\b`)
+	klogSeverity    = regexp.MustCompile(`^([IWEF])\d{4} `)
+)
+
+// SeverityStage writes the canonical severity of the results to a label
+type SeverityStage struct {
+	sources     []string
+	label       string
+	mapping     map[string]string
+	fromMessage bool
+}
+
+func NewSeverityStage(config logs.SeverityStep) (*SeverityStage, error) {
+	stage := &SeverityStage{
+		sources:     config.Sources,
+		label:       config.Label,
+		mapping:     make(map[string]string, len(config.Mapping)),
+		fromMessage: config.FromMessage,
+	}
+	if len(stage.sources) == 0 {
+		stage.sources = defaultSeveritySources
+	}
+	if stage.label == "" {
+		stage.label = "severity"
+	}
+
+	for k, v := range config.Mapping {
+		if !collections.Contains(severities, v) {
+			return nil, fmt.Errorf("%q is not one of the severities %s", v, strings.Join(severities, ", "))
+		}
+		stage.mapping[strings.ToLower(k)] = v
+	}
+
+	return stage, nil
+}
+
+func (t *SeverityStage) Process(r *logs.Result) bool {
+	var severity string
+	for _, source := range t.sources {
+		if val, ok := r.Labels[source]; ok && val != "" {
+			severity = t.normalize(val)
+			break
+		}
+	}
+
+	if severity == "" && t.fromMessage {
+		if match := klogSeverity.FindStringSubmatch(r.Message); match != nil {
+			severity = t.normalize(match[1])
+		} else if match := messageSeverity.FindStringSubmatch(r.Message); match != nil {
+			severity = t.normalize(match[1])
+		}
+	}
+
+	if severity == "" {
+		return true
+	}
+
+	if r.Labels == nil {
+		r.Labels = make(map[string]string)
+	}
+	r.Labels[t.label] = severity
+	return true
+}
+
+// normalize returns the canonical severity of the value
+// or an empty string if it isn't recognized.
+func (t *SeverityStage) normalize(value string) string {
+	if s, ok := t.mapping[strings.ToLower(strings.TrimSpace(value))]; ok {
+		return s
+	}
+	return NormalizeSeverity(value)
+}
+
+// NormalizeSeverity returns the canonical severity of one of the common spellings
+// or an empty string if it isn't recognized.
+func NormalizeSeverity(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if s, ok := severitySpellings[value]; ok {
+		return s
+	}
+
+	if n, err := strconv.Atoi(value); err == nil {
+		return numericSeverity(n)
+	}
+
+	return ""
+}
+
+// numericSeverity maps the syslog (0-7) and the bunyan/pino (10-60) levels
+func numericSeverity(n int) string {
+	switch {
+	case n < 0:
+		return ""
+	case n <= 2:
+		return SeverityFatal
+	case n == 3:
+		return SeverityError
+	case n == 4:
+		return SeverityWarning
+	case n <= 6:
+		return SeverityInfo
+	case n == 7:
+		return SeverityDebug
+	case n < 10:
+		return ""
+	case n < 20:
+		return SeverityTrace
+	case n < 30:
+		return SeverityDebug
+	case n < 40:
+		return SeverityInfo
+	case n < 50:
+		return SeverityWarning
+	case n < 60:
+		return SeverityError
+	default:
+		return SeverityFatal
+	}
+}
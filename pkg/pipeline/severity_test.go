@@ -0,0 +1,42 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestSeverityStage(t *testing.T) {
+	tests := []struct {
+		name   string
+		config logs.SeverityStep
+		result logs.Result
+		want   string
+	}{
+		{name: "upper case", result: logs.Result{Labels: map[string]string{"level": "WARN"}}, want: SeverityWarning},
+		{name: "spelled out", result: logs.Result{Labels: map[string]string{"severity": "Warning"}}, want: SeverityWarning},
+		{name: "single letter", result: logs.Result{Labels: map[string]string{"lvl": "E"}}, want: SeverityError},
+		{name: "pino level", result: logs.Result{Labels: map[string]string{"level": "30"}}, want: SeverityInfo},
+		{name: "syslog priority", result: logs.Result{Labels: map[string]string{"priority": "3"}}, want: SeverityError},
+		{name: "custom mapping", config: logs.SeverityStep{Mapping: map[string]string{"oops": "error"}}, result: logs.Result{Labels: map[string]string{"level": "OOPS"}}, want: SeverityError},
+		{name: "custom source", config: logs.SeverityStep{Sources: []string{"status"}}, result: logs.Result{Labels: map[string]string{"status": "fatal"}}, want: SeverityFatal},
+		{name: "unknown", result: logs.Result{Labels: map[string]string{"level": "whatever"}}},
+		{name: "from message", config: logs.SeverityStep{FromMessage: true}, result: logs.Result{Message: "[ERROR] connection refused"}, want: SeverityError},
+		{name: "from klog message", config: logs.SeverityStep{FromMessage: true}, result: logs.Result{Message: "W0208 16:51:50.123 main.go:12] slow"}, want: SeverityWarning},
+		{name: "message ignored by default", result: logs.Result{Message: "ERROR connection refused"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := NewSeverityStage(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			stage.Process(&tt.result)
+			if got := tt.result.Labels["severity"]; got != tt.want {
+				t.Errorf("severity = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package pipeline
+
+import (
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// TimestampStage sets the time of the results from their message or a label
+type TimestampStage struct {
+	parser      *logs.TimestampParser
+	source      string
+	keepMessage bool
+}
+
+func NewTimestampStage(config logs.TimestampStep) (*TimestampStage, error) {
+	parser, err := logs.NewTimestampParser(config.Layouts, config.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TimestampStage{parser: parser, source: config.Source, keepMessage: config.KeepMessage}, nil
+}
+
+func (t *TimestampStage) Process(r *logs.Result) bool {
+	if t.source != "" {
+		if ts, ok := t.parser.Parse(r.Labels[t.source]); ok {
+			r.Time = ts.Format(time.RFC3339Nano)
+		}
+		return true
+	}
+
+	processed := r.ProcessTimestamp(t.parser)
+	r.Time = processed.Time
+	if !t.keepMessage {
+		r.Message = processed.Message
+	}
+	return true
+}
@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/logger"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/traits"
+	"github.com/google/cel-go/ext"
+)
+
+var celEnv *cel.Env
+
+func init() {
+	env, err := cel.NewEnv(
+		cel.Variable("id", cel.StringType),
+		cel.Variable("time", cel.StringType),
+		cel.Variable("message", cel.StringType),
+		cel.Variable("labels", cel.MapType(cel.StringType, cel.StringType)),
+		ext.Strings(),
+	)
+	if err != nil {
+		logger.Fatalf("error creating the cel environment: %v", err)
+	}
+	celEnv = env
+}
+
+func NewTransformStage(config logs.TransformStep) (Stage, error) {
+	switch {
+	case config.CEL != "" && config.Template != nil:
+		return nil, fmt.Errorf("only one of cel or template must be set")
+	case config.CEL != "":
+		return newCELStage(config.CEL)
+	case config.Template != nil:
+		return newTemplateStage(*config.Template)
+	}
+
+	return nil, fmt.Errorf("either cel or template is required")
+}
+
+// CELStage rewrites or drops the results with a CEL expression
+type CELStage struct {
+	expression string
+	program    cel.Program
+}
+
+func newCELStage(expression string) (*CELStage, error) {
+	ast, issues := celEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("error compiling cel expression %q: %w", expression, issues.Err())
+	}
+
+	program, err := celEnv.Program(ast)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cel program %q: %w", expression, err)
+	}
+
+	return &CELStage{expression: expression, program: program}, nil
+}
+
+func (t *CELStage) Process(r *logs.Result) bool {
+	labels := r.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	out, _, err := t.program.Eval(map[string]any{
+		"id":      r.Id,
+		"time":    r.Time,
+		"message": r.Message,
+		"labels":  labels,
+	})
+	if err != nil {
+		logger.Debugf("error evaluating cel expression %q: %v", t.expression, err)
+		return true
+	}
+
+	switch v := out.Value().(type) {
+	case bool:
+		return v
+	case string:
+		r.Message = v
+	default:
+		fields, ok := out.(traits.Mapper)
+		if !ok {
+			logger.Debugf("cel expression %q returned an unsupported %s", t.expression, out.Type())
+			return true
+		}
+		return applyCELResult(r, fields)
+	}
+
+	return true
+}
+
+func applyCELResult(r *logs.Result, fields traits.Mapper) bool {
+	if drop, found := fields.Find(types.String("drop")); found && drop == types.True {
+		return false
+	}
+
+	if message, found := fields.Find(types.String("message")); found {
+		if message, ok := message.Value().(string); ok {
+			r.Message = message
+		}
+	}
+
+	if labels, found := fields.Find(types.String("labels")); found {
+		native, err := labels.ConvertToNative(reflect.TypeOf(map[string]string{}))
+		if err != nil {
+			logger.Debugf("cel labels must be a map of strings: %v", err)
+			return true
+		}
+		r.Labels = native.(map[string]string)
+	}
+
+	return true
+}
+
+// TemplateStage rewrites or drops the results with go templates
+type TemplateStage struct {
+	message *template.Template
+	labels  map[string]*template.Template
+	drop    *template.Template
+}
+
+func newTemplateStage(config logs.TransformTemplate) (*TemplateStage, error) {
+	stage := &TemplateStage{labels: make(map[string]*template.Template, len(config.Labels))}
+
+	var err error
+	if stage.message, err = parseTemplate("message", config.Message); err != nil {
+		return nil, err
+	}
+
+	if stage.drop, err = parseTemplate("drop", config.Drop); err != nil {
+		return nil, err
+	}
+
+	for label, text := range config.Labels {
+		if stage.labels[label], err = parseTemplate(label, text); err != nil {
+			return nil, err
+		}
+	}
+
+	return stage, nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tpl, err := template.New(name).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the %s template: %w", name, err)
+	}
+	return tpl, nil
+}
+
+func (t *TemplateStage) Process(r *logs.Result) bool {
+	// All the templates are rendered against the original result
+	original := *r
+	original.Labels = cloneLabels(r.Labels)
+	if t.drop != nil && strings.TrimSpace(render(t.drop, original)) == "true" {
+		return false
+	}
+
+	if t.message != nil {
+		r.Message = render(t.message, original)
+	}
+
+	for label, tpl := range t.labels {
+		var value string
+		if tpl != nil {
+			value = render(tpl, original)
+		}
+
+		if value == "" {
+			delete(r.Labels, label)
+			continue
+		}
+
+		if r.Labels == nil {
+			r.Labels = make(map[string]string)
+		}
+		r.Labels[label] = value
+	}
+
+	return true
+}
+
+func render(tpl *template.Template, r logs.Result) string {
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, r); err != nil {
+		logger.Debugf("error rendering the %s template: %v", tpl.Name(), err)
+	}
+	return buf.String()
+}
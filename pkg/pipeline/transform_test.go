@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestTransformStage(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      logs.TransformStep
+		result      logs.Result
+		wantDropped bool
+		wantMessage string
+		wantLabels  map[string]string
+	}{
+		{
+			name:        "cel drop",
+			config:      logs.TransformStep{CEL: `!message.contains("/healthz")`},
+			result:      logs.Result{Message: "GET /healthz 200"},
+			wantDropped: true,
+		},
+		{
+			name:        "cel keep",
+			config:      logs.TransformStep{CEL: `!message.contains("/healthz")`},
+			result:      logs.Result{Message: "GET /api 200"},
+			wantMessage: "GET /api 200",
+		},
+		{
+			name:        "cel message",
+			config:      logs.TransformStep{CEL: `labels["pod"] + ": " + message`},
+			result:      logs.Result{Message: "started", Labels: map[string]string{"pod": "nginx"}},
+			wantMessage: "nginx: started",
+			wantLabels:  map[string]string{"pod": "nginx"},
+		},
+		{
+			name:        "cel map",
+			config:      logs.TransformStep{CEL: `{"message": message.upperAscii(), "labels": {"app": labels["pod"]}}`},
+			result:      logs.Result{Message: "started", Labels: map[string]string{"pod": "nginx"}},
+			wantMessage: "STARTED",
+			wantLabels:  map[string]string{"app": "nginx"},
+		},
+		{
+			name:        "template",
+			config:      logs.TransformStep{Template: &logs.TransformTemplate{Message: "[{{.Labels.pod}}] {{.Message}}", Labels: map[string]string{"app": "{{.Labels.pod}}", "pod": ""}}},
+			result:      logs.Result{Message: "started", Labels: map[string]string{"pod": "nginx"}},
+			wantMessage: "[nginx] started",
+			wantLabels:  map[string]string{"app": "nginx"},
+		},
+		{
+			name:        "template drop",
+			config:      logs.TransformStep{Template: &logs.TransformTemplate{Drop: `{{eq .Labels.level "debug"}}`}},
+			result:      logs.Result{Message: "verbose", Labels: map[string]string{"level": "debug"}},
+			wantDropped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := NewTransformStage(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keep := stage.Process(&tt.result)
+			if keep == tt.wantDropped {
+				t.Fatalf("kept = %v, want dropped %v", keep, tt.wantDropped)
+			}
+			if tt.wantDropped {
+				return
+			}
+
+			if tt.result.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", tt.result.Message, tt.wantMessage)
+			}
+			if !reflect.DeepEqual(tt.result.Labels, tt.wantLabels) {
+				t.Errorf("labels = %v, want %v", tt.result.Labels, tt.wantLabels)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package pipeline
+
+import (
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// TruncateStage cuts the messages longer than the max length
+type TruncateStage struct {
+	maxLength int
+	marker    string
+	label     string
+}
+
+func NewTruncateStage(config logs.TruncateStep) (*TruncateStage, error) {
+	if config.MaxLength <= 0 {
+		return nil, fmt.Errorf("maxLength must be greater than 0")
+	}
+
+	stage := &TruncateStage{maxLength: config.MaxLength, marker: config.Marker, label: config.Label}
+	if stage.marker == "" {
+		stage.marker = "...[truncated]"
+	}
+	if stage.label == "" {
+		stage.label = "original_size"
+	}
+
+	return stage, nil
+}
+
+func (t *TruncateStage) Process(r *logs.Result) bool {
+	size := len(r.Message)
+	if size <= t.maxLength {
+		return true
+	}
+
+	// Don't cut a multi-byte character in half
+	cut := t.maxLength
+	for cut > 0 && !utf8.RuneStart(r.Message[cut]) {
+		cut--
+	}
+
+	r.Message = r.Message[:cut] + t.marker
+	if r.Labels == nil {
+		r.Labels = make(map[string]string)
+	}
+	r.Labels[t.label] = strconv.Itoa(size)
+	return true
+}
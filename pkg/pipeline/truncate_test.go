@@ -0,0 +1,45 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestTruncateStage(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      logs.TruncateStep
+		message     string
+		wantMessage string
+		wantSize    string
+	}{
+		{name: "short message", config: logs.TruncateStep{MaxLength: 10}, message: "hello", wantMessage: "hello"},
+		{name: "long message", config: logs.TruncateStep{MaxLength: 5}, message: "hello world", wantMessage: "hello...[truncated]", wantSize: "11"},
+		{name: "custom marker and label", config: logs.TruncateStep{MaxLength: 5, Marker: "…", Label: "size"}, message: "hello world", wantMessage: "hello…", wantSize: "11"},
+		{name: "multi-byte character", config: logs.TruncateStep{MaxLength: 2, Marker: "|"}, message: "aéb", wantMessage: "a|", wantSize: "4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stage, err := NewTruncateStage(tt.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := logs.Result{Message: tt.message}
+			stage.Process(&r)
+			if r.Message != tt.wantMessage {
+				t.Errorf("message = %q, want %q", r.Message, tt.wantMessage)
+			}
+
+			label := tt.config.Label
+			if label == "" {
+				label = "original_size"
+			}
+			if r.Labels[label] != tt.wantSize {
+				t.Errorf("size = %q, want %q", r.Labels[label], tt.wantSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/logger"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// WASMStage processes the results with the process function of a WebAssembly module
+type WASMStage struct {
+	path     string
+	memory   api.Memory
+	allocate api.Function
+	process  api.Function
+
+	// lock serializes the calls, a module instance isn't safe for concurrent use
+	lock sync.Mutex
+}
+
+func NewWASMStage(config logs.WASMStep) (*WASMStage, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("wasm module path is required")
+	}
+
+	code, err := os.ReadFile(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the wasm module: %w", err)
+	}
+
+	ctx := context.Background()
+	runtime := wazero.NewRuntime(ctx)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		return nil, fmt.Errorf("error instantiating wasi: %w", err)
+	}
+
+	moduleConfig := wazero.NewModuleConfig().
+		WithName(config.Path).
+		WithStartFunctions("_initialize").
+		WithStdout(os.Stderr).
+		WithStderr(os.Stderr)
+	for k, v := range config.Env {
+		moduleConfig = moduleConfig.WithEnv(k, v)
+	}
+
+	module, err := runtime.InstantiateWithConfig(ctx, code, moduleConfig)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("error instantiating the wasm module %s: %w", config.Path, err)
+	}
+
+	stage := &WASMStage{
+		path:     config.Path,
+		memory:   module.Memory(),
+		allocate: module.ExportedFunction("allocate"),
+		process:  module.ExportedFunction("process"),
+	}
+	switch {
+	case stage.memory == nil:
+		err = fmt.Errorf("wasm module %s doesn't export its memory", config.Path)
+	case stage.allocate == nil:
+		err = fmt.Errorf("wasm module %s doesn't export an allocate function", config.Path)
+	case stage.process == nil:
+		err = fmt.Errorf("wasm module %s doesn't export a process function", config.Path)
+	}
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+
+	return stage, nil
+}
+
+// Process leaves the result unchanged when the module fails
+func (t *WASMStage) Process(r *logs.Result) bool {
+	input, err := json.Marshal(r)
+	if err != nil {
+		logger.Debugf("error encoding the result for wasm module %s: %v", t.path, err)
+		return true
+	}
+
+	output, keep, err := t.call(input)
+	if err != nil {
+		logger.Debugf("error calling wasm module %s: %v", t.path, err)
+		return true
+	}
+	if !keep {
+		return false
+	}
+
+	var processed logs.Result
+	if err := json.Unmarshal(output, &processed); err != nil {
+		logger.Debugf("wasm module %s returned an invalid result: %v", t.path, err)
+		return true
+	}
+	*r = processed
+	return true
+}
+
+// call passes the input to the process function and returns a copy of its output.
+// keep is false when the module dropped the result.
+func (t *WASMStage) call(input []byte) (output []byte, keep bool, err error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	ctx := context.Background()
+	allocated, err := t.allocate.Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, false, fmt.Errorf("error allocating the input: %w", err)
+	}
+	ptr := uint32(allocated[0])
+	if !t.memory.Write(ptr, input) {
+		return nil, false, fmt.Errorf("allocated buffer %d is out of the memory", ptr)
+	}
+
+	returned, err := t.process.Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, false, err
+	}
+	if returned[0] == 0 {
+		return nil, false, nil
+	}
+
+	outPtr, outLen := uint32(returned[0]>>32), uint32(returned[0])
+	view, ok := t.memory.Read(outPtr, outLen)
+	if !ok {
+		return nil, false, fmt.Errorf("output %d+%d is out of the memory", outPtr, outLen)
+	}
+	// The view is only valid until the next call
+	return append([]byte(nil), view...), true, nil
+}
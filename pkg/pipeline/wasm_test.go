@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// wasmModule assembles a module exporting its memory, allocate returning a buffer at 1024
+// and process with the given body. The data is written at the start of the memory.
+func wasmModule(process []byte, data []byte) []byte {
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+	// (i32) -> i32 and (i32, i32) -> i64
+	module = append(module, wasmSection(1, []byte{0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e})...)
+	module = append(module, wasmSection(3, []byte{0x02, 0x00, 0x01})...)
+	module = append(module, wasmSection(5, []byte{0x01, 0x00, 0x01})...)
+
+	exports := []byte{0x03}
+	exports = append(exports, wasmName("memory", 0x02, 0)...)
+	exports = append(exports, wasmName("allocate", 0x00, 0)...)
+	exports = append(exports, wasmName("process", 0x00, 1)...)
+	module = append(module, wasmSection(7, exports)...)
+
+	allocate := []byte{0x00, 0x41, 0x80, 0x08, 0x0b}
+	code := []byte{0x02}
+	code = append(code, wasmVector(allocate)...)
+	code = append(code, wasmVector(process)...)
+	module = append(module, wasmSection(10, code)...)
+
+	if len(data) > 0 {
+		segment := []byte{0x01, 0x00, 0x41, 0x00, 0x0b}
+		segment = append(segment, wasmVector(data)...)
+		module = append(module, wasmSection(11, segment)...)
+	}
+	return module
+}
+
+func wasmSection(id byte, content []byte) []byte {
+	return append([]byte{id}, wasmVector(content)...)
+}
+
+func wasmVector(content []byte) []byte {
+	return append(uleb128(uint64(len(content))), content...)
+}
+
+func wasmName(name string, kind, index byte) []byte {
+	return append(wasmVector([]byte(name)), kind, index)
+}
+
+func uleb128(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		if v >>= 7; v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+var (
+	// process returns its input: ptr<<32 | len
+	wasmIdentity = []byte{0x00, 0x20, 0x00, 0xad, 0x42, 0x20, 0x86, 0x20, 0x01, 0xad, 0x84, 0x0b}
+
+	// process returns 0
+	wasmDrop = []byte{0x00, 0x42, 0x00, 0x0b}
+)
+
+// wasmConstant returns a process body returning the data written at the start of the memory
+func wasmConstant(data string) []byte {
+	// The length is below 64, so its signed leb128 encoding is a single byte
+	return []byte{0x00, 0x42, byte(len(data)), 0x0b}
+}
+
+func TestWASMStage(t *testing.T) {
+	replaced := `{"message":"replaced","labels":{"wasm":"true"}}`
+
+	tests := []struct {
+		name        string
+		module      []byte
+		wantDropped bool
+		want        logs.Result
+	}{
+		{
+			name:   "identity",
+			module: wasmModule(wasmIdentity, nil),
+			want:   logs.Result{Id: "1", Time: "2023-01-01T00:00:00Z", Message: "hello", Labels: map[string]string{"pod": "nginx"}},
+		},
+		{
+			name:        "drop",
+			module:      wasmModule(wasmDrop, nil),
+			wantDropped: true,
+		},
+		{
+			name:   "replace",
+			module: wasmModule(wasmConstant(replaced), []byte(replaced)),
+			want:   logs.Result{Message: "replaced", Labels: map[string]string{"wasm": "true"}},
+		},
+		{
+			name:   "invalid output",
+			module: wasmModule(wasmConstant("not json"), []byte("not json")),
+			want:   logs.Result{Id: "1", Time: "2023-01-01T00:00:00Z", Message: "hello", Labels: map[string]string{"pod": "nginx"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "module.wasm")
+			if err := os.WriteFile(path, tt.module, 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			stage, err := NewWASMStage(logs.WASMStep{Path: path})
+			if err != nil {
+				t.Fatalf("NewWASMStage() error = %v", err)
+			}
+
+			r := logs.Result{Id: "1", Time: "2023-01-01T00:00:00Z", Message: "hello", Labels: map[string]string{"pod": "nginx"}}
+			if keep := stage.Process(&r); keep == tt.wantDropped {
+				t.Fatalf("Process() = %v, want dropped %v", keep, tt.wantDropped)
+			}
+			if tt.wantDropped {
+				return
+			}
+			if !reflect.DeepEqual(r, tt.want) {
+				t.Errorf("Process() = %+v, want %+v", r, tt.want)
+			}
+		})
+	}
+}
+
+func TestWASMStageInvalid(t *testing.T) {
+	dir := t.TempDir()
+	noExports := filepath.Join(dir, "empty.wasm")
+	if err := os.WriteFile(noExports, []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, config := range []logs.WASMStep{{}, {Path: filepath.Join(dir, "missing.wasm")}, {Path: noExports}} {
+		if _, err := NewWASMStage(config); err == nil {
+			t.Errorf("NewWASMStage(%+v) expected an error", config)
+		}
+	}
+}
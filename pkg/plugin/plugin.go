@@ -0,0 +1,284 @@
+// Package plugin runs the backends shipped as executables, so that they can be written without forking apm-hub.
+//
+// apm-hub starts the executable with the magic cookie in its environment. The plugin serves the gRPC service
+// of pkg/remote, and announces its address with a handshake, the first line of its stdout:
+//
+//	<protocol version>|<network>|<address>|grpc, e.g. 1|unix|/tmp/plugin/plugin.sock|grpc
+//
+// The plugins written in Go call Serve, which does both. The other lines of stdout and stderr are logged.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/remote"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/kommons"
+	"google.golang.org/grpc"
+)
+
+const (
+	// ProtocolVersion is the version of the handshake and of the service, incremented on breaking changes
+	ProtocolVersion = 1
+
+	// MagicCookieKey and MagicCookieValue tell the executables they're started as plugins.
+	// It's not a security measure, only a guard against running the plugins by hand.
+	MagicCookieKey   = "APM_HUB_PLUGIN"
+	MagicCookieValue = "a4b2c3e1f0d9465c8b7a6e5d4c3b2a19"
+
+	// tokenEnv is the token the plugins require from the searches, so that only apm-hub can call them
+	tokenEnv = "APM_HUB_PLUGIN_TOKEN"
+)
+
+// Serve serves the search api of the plugin on a unix socket, after the handshake, until it's interrupted or terminated
+func Serve(api logs.SearchAPI) error {
+	if os.Getenv(MagicCookieKey) != MagicCookieValue {
+		return fmt.Errorf("this executable is an apm-hub plugin, it's started by apm-hub")
+	}
+
+	dir, err := os.MkdirTemp("", "apm-hub-plugin")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	lis, err := net.Listen("unix", filepath.Join(dir, "plugin.sock"))
+	if err != nil {
+		return fmt.Errorf("error listening on the socket: %w", err)
+	}
+
+	server := grpc.NewServer()
+	remote.RegisterSearchBackendServer(server, api, os.Getenv(tokenEnv))
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		server.GracefulStop()
+	}()
+
+	fmt.Printf("%d|unix|%s|grpc\n", ProtocolVersion, lis.Addr())
+	return server.Serve(lis)
+}
+
+func NewPluginSearchBackend(kClient *kommons.Client, config *logs.PluginBackendConfig) (*PluginSearch, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+
+	t := &PluginSearch{config: config, timeout: 30 * time.Second}
+	if config.Timeout != "" {
+		timeout, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the timeout: %w", err)
+		}
+		t.timeout = time.Duration(timeout)
+	}
+
+	for name, env := range config.Env {
+		_, value, err := kClient.GetEnvValue(env, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the env %s: %w", name, err)
+		}
+		t.env = append(t.env, name+"="+value)
+	}
+
+	// The plugin is started right away, so that a missing or broken executable fails the config
+	if _, err := t.client(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// PluginSearch implements the SearchAPI with the plugin, restarted when it exits
+type PluginSearch struct {
+	config  *logs.PluginBackendConfig
+	env     []string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	remote *remote.RemoteSearch
+	// exited is closed when the process of the plugin exits
+	exited chan struct{}
+}
+
+func (t *PluginSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	client, err := t.client()
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+	return client.Search(ctx, q)
+}
+
+// client returns the client of the plugin, starting the plugin when it isn't running
+func (t *PluginSearch) client() (*remote.RemoteSearch, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.remote != nil {
+		select {
+		case <-t.exited:
+			logger.Warnf("the plugin %s exited, restarting it", t.config.Path)
+			t.remote.Close()
+			t.remote = nil
+		default:
+			return t.remote, nil
+		}
+	}
+
+	client, exited, err := t.start()
+	if err != nil {
+		return nil, fmt.Errorf("error starting the plugin %s: %w", t.config.Path, err)
+	}
+	t.remote, t.exited = client, exited
+	return client, nil
+}
+
+// start starts the plugin and connects to the address of its handshake
+func (t *PluginSearch) start() (*remote.RemoteSearch, chan struct{}, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cmd := exec.Command(t.config.Path, t.config.Args...)
+	cmd.Env = append(os.Environ(), t.env...)
+	cmd.Env = append(cmd.Env, MagicCookieKey+"="+MagicCookieValue, tokenEnv+"="+token)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, err
+	}
+
+	handshake := make(chan string, 1)
+	exited := make(chan struct{})
+	var output sync.WaitGroup
+	output.Add(2)
+	go func() {
+		defer output.Done()
+		scanner := bufio.NewScanner(stdout)
+		if scanner.Scan() {
+			handshake <- scanner.Text()
+		}
+		t.log(scanner)
+	}()
+	go func() {
+		defer output.Done()
+		t.log(bufio.NewScanner(stderr))
+	}()
+	go func() {
+		// The output is read to the end before waiting, as Wait closes the pipes
+		output.Wait()
+		if err := cmd.Wait(); err != nil {
+			logger.Errorf("the plugin %s exited: %v", t.config.Path, err)
+		}
+		close(exited)
+	}()
+
+	var line string
+	select {
+	case line = <-handshake:
+	case <-exited:
+		return nil, nil, fmt.Errorf("the plugin exited before its handshake")
+	case <-time.After(t.timeout):
+		_ = cmd.Process.Kill()
+		return nil, nil, fmt.Errorf("the plugin didn't send its handshake within %s", t.timeout)
+	}
+
+	address, err := parseHandshake(line)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return nil, nil, err
+	}
+
+	client, err := remote.NewRemoteSearchBackend(nil, &logs.RemoteBackendConfig{
+		CommonBackend: t.config.CommonBackend,
+		Address:       address,
+		Insecure:      true,
+		Token:         &kommons.EnvVar{Value: token},
+		Timeout:       t.config.Timeout,
+	})
+	if err != nil {
+		_ = cmd.Process.Kill()
+		return nil, nil, err
+	}
+	return client, exited, nil
+}
+
+// log logs the lines of the output of the plugin
+func (t *PluginSearch) log(scanner *bufio.Scanner) {
+	for scanner.Scan() {
+		logger.Infof("[%s] %s", filepath.Base(t.config.Path), scanner.Text())
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		logger.Debugf("error reading the output of the plugin %s: %v", t.config.Path, err)
+	}
+}
+
+// parseHandshake returns the gRPC address of the handshake
+func parseHandshake(line string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(line), "|")
+	if len(parts) != 4 || parts[3] != "grpc" {
+		return "", fmt.Errorf("invalid handshake %q, expected <protocol version>|<network>|<address>|grpc", line)
+	}
+	if version, err := strconv.Atoi(parts[0]); err != nil || version != ProtocolVersion {
+		return "", fmt.Errorf("the plugin speaks the protocol %s, apm-hub the protocol %d", parts[0], ProtocolVersion)
+	}
+
+	switch parts[1] {
+	case "unix":
+		return "unix://" + parts[2], nil
+	case "tcp":
+		return parts[2], nil
+	}
+	return "", fmt.Errorf("unsupported network %s", parts[1])
+}
+
+func randomToken() (string, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+func (t *PluginSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the plugin, the plugin receives the full search params
+func (t *PluginSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck waits for the connection to the plugin to be ready, restarting the plugin when it exited
+func (t *PluginSearch) HealthCheck(ctx context.Context) error {
+	client, err := t.client()
+	if err != nil {
+		return err
+	}
+	return client.HealthCheck(ctx)
+}
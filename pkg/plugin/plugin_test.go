@@ -0,0 +1,122 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+// fakeBackend returns the query and the env of the plugin as its single result, and exits on the query exit
+type fakeBackend struct{}
+
+func (fakeBackend) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	if q.Query == "exit" {
+		os.Exit(1)
+	}
+	return logs.SearchResults{Total: 1, Results: []logs.Result{{Message: q.Query, Labels: map[string]string{"region": os.Getenv("REGION")}}}}, nil
+}
+
+func (fakeBackend) MatchRoute(q *logs.SearchParams) (bool, bool) {
+	return true, false
+}
+
+func (fakeBackend) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true}
+}
+
+// TestMain runs the test binary as the plugin when it's started by the tests
+func TestMain(m *testing.M) {
+	if os.Getenv(MagicCookieKey) != "" {
+		if err := Serve(fakeBackend{}); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestPluginSearch(t *testing.T) {
+	backend, err := NewPluginSearchBackend(nil, &logs.PluginBackendConfig{
+		Path:    os.Args[0],
+		Env:     map[string]kommons.EnvVar{"REGION": {Value: "eu-west-1"}},
+		Timeout: "10s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := logs.SearchResults{Total: 1, Results: []logs.Result{{Message: "error", Labels: map[string]string{"region": "eu-west-1"}}}}
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Query: "exit"}); err == nil {
+		t.Error("Search() of an exiting plugin succeeded")
+	}
+	<-backend.exited
+
+	// The plugin is restarted by the next search
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Query: "error"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() after a restart = %+v, want %+v", res, want)
+	}
+	if err := backend.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() = %v", err)
+	}
+}
+
+func TestNewPluginSearchBackend(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "plugin.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\necho '2|unix|/tmp/plugin.sock|grpc'\nexec sleep 5\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		config logs.PluginBackendConfig
+	}{
+		{name: "missing path", config: logs.PluginBackendConfig{}},
+		{name: "missing executable", config: logs.PluginBackendConfig{Path: filepath.Join(dir, "missing")}},
+		{name: "other protocol", config: logs.PluginBackendConfig{Path: script}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewPluginSearchBackend(nil, &tt.config); err == nil {
+				t.Error("NewPluginSearchBackend() succeeded")
+			}
+		})
+	}
+}
+
+func TestParseHandshake(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    string
+		wantErr bool
+	}{
+		{line: "1|unix|/tmp/plugin/plugin.sock|grpc\n", want: "unix:///tmp/plugin/plugin.sock"},
+		{line: "1|tcp|127.0.0.1:1234|grpc", want: "127.0.0.1:1234"},
+		{line: "1|udp|127.0.0.1:1234|grpc", wantErr: true},
+		{line: "1|tcp|127.0.0.1:1234|netrpc", wantErr: true},
+		{line: "listening on :1234", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseHandshake(tt.line)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseHandshake(%q) = %q, %v, want %q", tt.line, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Package postgres searches the logs stored in a Postgres table
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	defaultTimestampColumn = "timestamp"
+	defaultMessageColumn   = "message"
+	defaultLabelsColumn    = "labels"
+)
+
+type PostgresSearch struct {
+	config *logs.PostgresBackendConfig
+	pool   *pgxpool.Pool
+
+	// the quoted identifiers of the table and its columns
+	table, timestamp, message, labels, id string
+}
+
+func NewPostgresSearchBackend(kClient *kommons.Client, config *logs.PostgresBackendConfig) (*PostgresSearch, error) {
+	if config.Table == "" {
+		return nil, fmt.Errorf("the table is required")
+	}
+	_, connection, err := kClient.GetEnvValue(config.Connection, config.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the connection string: %w", err)
+	}
+
+	// The connections are opened with the first search
+	pool, err := pgxpool.New(context.Background(), connection)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the connection string: %w", err)
+	}
+
+	t := newPostgresSearch(config)
+	t.pool = pool
+	return t, nil
+}
+
+func newPostgresSearch(config *logs.PostgresBackendConfig) *PostgresSearch {
+	column := func(name, defaultName string) string {
+		if name == "" {
+			name = defaultName
+		}
+		return pgx.Identifier{name}.Sanitize()
+	}
+
+	t := &PostgresSearch{
+		config:    config,
+		table:     pgx.Identifier(strings.Split(config.Table, ".")).Sanitize(),
+		timestamp: column(config.TimestampColumn, defaultTimestampColumn),
+		message:   column(config.MessageColumn, defaultMessageColumn),
+		labels:    column(config.LabelsColumn, defaultLabelsColumn),
+	}
+	if config.IDColumn != "" {
+		t.id = pgx.Identifier{config.IDColumn}.Sanitize()
+	}
+	return t
+}
+
+// query returns the SQL query of the search and its arguments. The rows are filtered by the time range,
+// the labels and the query of the search, newest first and with one more row than the limit to tell if there's a next page.
+// The page of the search is the offset of the rows.
+func (t *PostgresSearch) query(q *logs.SearchParams) (string, []any, error) {
+	var conditions []string
+	var args []any
+	arg := func(value any) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	if start := q.GetStart(); start != nil {
+		conditions = append(conditions, t.timestamp+" >= "+arg(*start))
+	}
+	if end := q.GetEnd(); end != nil {
+		conditions = append(conditions, t.timestamp+" <= "+arg(*end))
+	}
+
+	keys := t.config.LabelKeys
+	if len(keys) == 0 {
+		for key := range q.Labels {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+	}
+	for _, key := range keys {
+		if value, ok := q.Labels[key]; ok {
+			conditions = append(conditions, t.labels+" ->> "+arg(key)+" = "+arg(value))
+		}
+	}
+
+	if q.Query != "" {
+		pattern := "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q.Query) + "%"
+		conditions = append(conditions, t.message+" ILIKE "+arg(pattern))
+	}
+
+	columns := []string{t.timestamp, t.message, t.labels}
+	if t.id != "" {
+		columns = append(columns, t.id+"::text")
+	}
+	sql := "SELECT " + strings.Join(columns, ", ") + " FROM " + t.table
+	if len(conditions) > 0 {
+		sql += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	sql += " ORDER BY " + t.timestamp + " DESC"
+
+	if q.Limit > 0 {
+		sql += " LIMIT " + arg(q.Limit+1)
+	}
+	if q.Page != "" {
+		offset, err := strconv.ParseInt(q.Page, 10, 64)
+		if err != nil {
+			return "", nil, fmt.Errorf("error parsing the page: %w", err)
+		}
+		sql += " OFFSET " + arg(offset)
+	}
+	return sql, args, nil
+}
+
+func (t *PostgresSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	sql, args, err := t.query(q)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	rows, err := t.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error querying the logs: %w", err)
+	}
+	defer rows.Close()
+
+	var results logs.SearchResults
+	for rows.Next() {
+		var timestamp time.Time
+		var message, id *string
+		var labels map[string]any
+		dest := []any{&timestamp, &message, &labels}
+		if t.id != "" {
+			dest = append(dest, &id)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return logs.SearchResults{}, fmt.Errorf("error scanning the logs: %w", err)
+		}
+		results.Results = append(results.Results, t.result(timestamp, message, id, labels))
+	}
+	if err := rows.Err(); err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error querying the logs: %w", err)
+	}
+
+	if q.Limit > 0 && int64(len(results.Results)) > q.Limit {
+		results.Results = results.Results[:q.Limit]
+		offset, _ := strconv.ParseInt(q.Page, 10, 64)
+		results.NextPage = strconv.FormatInt(offset+q.Limit, 10)
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+func (t *PostgresSearch) result(timestamp time.Time, message, id *string, labels map[string]any) logs.Result {
+	r := logs.Result{Time: timestamp.UTC().Format(time.RFC3339Nano), Labels: make(map[string]string, len(labels)+len(t.config.Labels))}
+	if message != nil {
+		r.Message = *message
+	}
+	if id != nil {
+		r.Id = *id
+	}
+	for k, v := range labels {
+		switch v := v.(type) {
+		case nil:
+		case string:
+			r.Labels[k] = v
+		default:
+			data, _ := json.Marshal(v)
+			r.Labels[k] = string(data)
+		}
+	}
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *PostgresSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Postgres backend, the messages are matched against the text of the query
+func (t *PostgresSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck pings the database
+func (t *PostgresSearch) HealthCheck(ctx context.Context) error {
+	return t.pool.Ping(ctx)
+}
@@ -0,0 +1,76 @@
+package postgres
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestPostgresSearch_query(t *testing.T) {
+	start := time.Date(2023, 5, 1, 11, 0, 0, 0, time.UTC)
+	end := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		config   logs.PostgresBackendConfig
+		params   logs.SearchParams
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:    "defaults",
+			config:  logs.PostgresBackendConfig{Table: "logs"},
+			params:  logs.SearchParams{},
+			wantSQL: `SELECT "timestamp", "message", "labels" FROM "logs" ORDER BY "timestamp" DESC`,
+		},
+		{
+			name:   "filters",
+			config: logs.PostgresBackendConfig{Table: "audit.events", TimestampColumn: "created_at", MessageColumn: "text", LabelsColumn: "tags", IDColumn: "id"},
+			params: logs.SearchParams{
+				Start: start.Format(time.RFC3339), End: end.Format(time.RFC3339), Query: "100% done_",
+				Labels: map[string]string{"pod": "api-0", "namespace": "shop"}, Limit: 50, Page: "100",
+			},
+			wantSQL: `SELECT "created_at", "text", "tags", "id"::text FROM "audit"."events" WHERE "created_at" >= $1 AND "created_at" <= $2` +
+				` AND "tags" ->> $3 = $4 AND "tags" ->> $5 = $6 AND "text" ILIKE $7 ORDER BY "created_at" DESC LIMIT $8 OFFSET $9`,
+			wantArgs: []any{start, end, "namespace", "shop", "pod", "api-0", `%100\% done\_%`, int64(51), int64(100)},
+		},
+		{
+			name:     "label keys",
+			config:   logs.PostgresBackendConfig{Table: "logs", LabelKeys: []string{"namespace", "app"}},
+			params:   logs.SearchParams{Labels: map[string]string{"pod": "api-0", "namespace": "shop"}},
+			wantSQL:  `SELECT "timestamp", "message", "labels" FROM "logs" WHERE "labels" ->> $1 = $2 ORDER BY "timestamp" DESC`,
+			wantArgs: []any{"namespace", "shop"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args, err := newPostgresSearch(&tt.config).query(&tt.params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if sql != tt.wantSQL {
+				t.Errorf("query() = %s, want %s", sql, tt.wantSQL)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("query() args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestPostgresSearch_result(t *testing.T) {
+	message, id := "connection timeout", "42"
+	backend := newPostgresSearch(&logs.PostgresBackendConfig{Table: "logs", CommonBackend: logs.CommonBackend{Labels: map[string]string{"source": "postgres"}}})
+	got := backend.result(time.Date(2023, 5, 1, 14, 0, 0, 500, time.FixedZone("CEST", 7200)), &message, &id, map[string]any{
+		"pod": "api-0", "status": float64(504), "retry": true, "owner": nil, "tags": []any{"a"},
+	})
+	want := logs.Result{Id: "42", Time: "2023-05-01T12:00:00.0000005Z", Message: "connection timeout", Labels: map[string]string{
+		"pod": "api-0", "status": "504", "retry": "true", "tags": `["a"]`, "source": "postgres",
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("result() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,215 @@
+// Package prometheus runs PromQL queries, returning the samples as results
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/kommons"
+)
+
+// maxPoints is the number of points per series of the range queries without a step
+const maxPoints = 250
+
+// minStep is the minimum step of the range queries without a step
+const minStep = 15 * time.Second
+
+type PrometheusSearch struct {
+	config *logs.PrometheusBackendConfig
+	client *rest.Client
+	step   time.Duration
+}
+
+func NewPrometheusSearchBackend(kClient *kommons.Client, config *logs.PrometheusBackendConfig) (*PrometheusSearch, error) {
+	client, err := rest.NewClient(kClient, config.HTTPConnection)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &PrometheusSearch{config: config, client: client}
+	if config.Step != "" {
+		step, err := durationUtil.ParseDuration(config.Step)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the step: %w", err)
+		}
+		if step <= 0 {
+			return nil, fmt.Errorf("the step must be positive")
+		}
+		t.step = time.Duration(step)
+	}
+	return t, nil
+}
+
+// Search runs the PromQL query of the search, returning a result per sample in chronological order
+func (t *PrometheusSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	promQL := q.Query
+	if promQL == "" {
+		promQL = t.config.Query
+	}
+	if promQL == "" {
+		return logs.SearchResults{}, fmt.Errorf("the search requires a PromQL query")
+	}
+
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+
+	query := url.Values{}
+	query.Set("query", promQL)
+	path := "/api/v1/query"
+	if start := q.GetStart(); start != nil && !t.config.Instant {
+		path = "/api/v1/query_range"
+		query.Set("start", formatTime(*start))
+		query.Set("end", formatTime(end))
+		query.Set("step", strconv.FormatFloat(t.stepOf(end.Sub(*start)).Seconds(), 'f', -1, 64))
+	} else {
+		query.Set("time", formatTime(end))
+	}
+
+	var res response
+	if err := t.client.Get(ctx, path, query, &res); err != nil {
+		return logs.SearchResults{}, err
+	}
+	if res.Status != "success" {
+		return logs.SearchResults{}, fmt.Errorf("error running the query: %s", res.Error)
+	}
+
+	points, err := res.Data.points()
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].time.Before(points[j].time) })
+
+	results := logs.SearchResults{Total: len(points)}
+	for _, p := range points {
+		if q.Limit > 0 && int64(len(results.Results)) >= q.Limit {
+			break
+		}
+		results.Results = append(results.Results, p.result(t.config.Labels))
+	}
+	return results, nil
+}
+
+// stepOf returns the configured step, otherwise a step returning up to maxPoints per series
+func (t *PrometheusSearch) stepOf(timeRange time.Duration) time.Duration {
+	if t.step > 0 {
+		return t.step
+	}
+	step := (timeRange / maxPoints).Truncate(time.Second)
+	if step < minStep {
+		return minStep
+	}
+	return step
+}
+
+func (t *PrometheusSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Prometheus backend, the query is sent as PromQL
+func (t *PrometheusSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true}
+}
+
+// HealthCheck runs a query that doesn't select any series
+func (t *PrometheusSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/v1/query", url.Values{"query": {"1"}}, nil)
+}
+
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
+
+type response struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   data   `json:"data"`
+}
+
+type data struct {
+	// ResultType is either matrix, vector, scalar or string
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+type series struct {
+	Metric map[string]string `json:"metric"`
+	Value  sample            `json:"value"`
+	Values []sample          `json:"values"`
+}
+
+// sample is the timestamp in seconds and the value of a sample
+type sample [2]any
+
+// point is a sample of a series
+type point struct {
+	time   time.Time
+	value  string
+	metric map[string]string
+}
+
+func (s sample) point(metric map[string]string) point {
+	seconds, _ := s[0].(float64)
+	value, _ := s[1].(string)
+	sec, frac := math.Modf(seconds)
+	return point{
+		time:   time.Unix(int64(sec), int64(math.Round(frac*1000))*int64(time.Millisecond)),
+		value:  value,
+		metric: metric,
+	}
+}
+
+// result renders the point with its value as message, and the labels of the series followed by the given labels
+func (p point) result(labels map[string]string) logs.Result {
+	r := logs.Result{
+		Time:    p.time.UTC().Format(time.RFC3339Nano),
+		Message: p.value,
+		Labels:  make(map[string]string, len(p.metric)+len(labels)),
+	}
+	for k, v := range p.metric {
+		r.Labels[k] = v
+	}
+	for k, v := range labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t data) points() ([]point, error) {
+	switch t.ResultType {
+	case "matrix", "vector":
+		var all []series
+		if err := json.Unmarshal(t.Result, &all); err != nil {
+			return nil, fmt.Errorf("error decoding the %s: %w", t.ResultType, err)
+		}
+		var points []point
+		for _, s := range all {
+			if t.ResultType == "vector" {
+				points = append(points, s.Value.point(s.Metric))
+				continue
+			}
+			for _, v := range s.Values {
+				points = append(points, v.point(s.Metric))
+			}
+		}
+		return points, nil
+
+	case "scalar", "string":
+		var s sample
+		if err := json.Unmarshal(t.Result, &s); err != nil {
+			return nil, fmt.Errorf("error decoding the %s: %w", t.ResultType, err)
+		}
+		return []point{s.point(nil)}, nil
+	}
+	return nil, fmt.Errorf("unsupported result type %q", t.ResultType)
+}
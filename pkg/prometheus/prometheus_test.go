@@ -0,0 +1,78 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestPrometheusSearch(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		requests = append(requests, r.URL.Path+"?"+query.Get("query")+"|"+query.Get("start")+"|"+query.Get("end")+"|"+query.Get("step")+"|"+query.Get("time"))
+		switch query.Get("query") {
+		case "up{job=\"api\"}":
+			w.Write([]byte(`{"status": "success", "data": {"resultType": "matrix", "result": [
+				{"metric": {"__name__": "up", "job": "api", "instance": "a"}, "values": [[1682942400, "1"], [1682942460.5, "0"]]},
+				{"metric": {"__name__": "up", "job": "api", "instance": "b"}, "values": [[1682942430, "1"]]}
+			]}}`))
+		case "scalar(up)":
+			w.Write([]byte(`{"status": "success", "data": {"resultType": "scalar", "result": [1682946000, "2"]}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status": "error", "errorType": "bad_data", "error": "parse error"}`))
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewPrometheusSearchBackend(nil, &logs.PrometheusBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"cluster": "prod"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		Query:          "scalar(up)",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query: `up{job="api"}`,
+		Start: "2023-05-01T11:00:00Z",
+		End:   "2023-05-01T12:00:00Z",
+		Limit: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 3, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:00Z", Message: "1", Labels: map[string]string{"__name__": "up", "job": "api", "instance": "a", "cluster": "prod"}},
+		{Time: "2023-05-01T12:00:30Z", Message: "1", Labels: map[string]string{"__name__": "up", "job": "api", "instance": "b", "cluster": "prod"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Start: "2023-05-01T11:00:00Z", End: "2023-05-01T13:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 1 || res.Results[0].Message != "2" || res.Results[0].Time != "2023-05-01T13:00:00Z" {
+		t.Errorf("Search() = %+v, want the scalar of the configured query", res)
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Query: "up{"}); err == nil {
+		t.Errorf("expected the error of the invalid query")
+	}
+
+	wantRequests := []string{
+		`/api/v1/query_range?up{job="api"}|1682938800|1682942400|15|`,
+		`/api/v1/query_range?scalar(up)|1682938800|1682946000|28|`,
+	}
+	if !reflect.DeepEqual(requests[:2], wantRequests) {
+		t.Errorf("requests = %v, want %v", requests[:2], wantRequests)
+	}
+}
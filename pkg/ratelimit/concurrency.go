@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrBackendBusy is returned when a search is rejected by the concurrency limit of the backend
+var ErrBackendBusy = errors.New("the backend is busy")
+
+var (
+	inflightSearches = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "apm_hub_backend_inflight_searches",
+		Help: "Number of searches running against the backends with a concurrency limit",
+	}, []string{"backend"})
+
+	rejectedSearches = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "apm_hub_backend_rejected_searches_total",
+		Help: "Number of searches rejected by the concurrency limit of the backends",
+	}, []string{"backend"})
+)
+
+// ConcurrencyLimiter is a semaphore bounding the searches running at the same time against a backend,
+// with a bounded queue of searches waiting for a slot.
+type ConcurrencyLimiter struct {
+	name    string
+	slots   chan struct{}
+	queue   int32
+	waiting atomic.Int32
+	timeout time.Duration
+}
+
+func NewConcurrencyLimiter(name string, config logs.ConcurrencyConfig) (*ConcurrencyLimiter, error) {
+	if config.Max <= 0 {
+		return nil, fmt.Errorf("the concurrency limit must be positive")
+	}
+	if config.Queue < 0 {
+		return nil, fmt.Errorf("the concurrency queue can't be negative")
+	}
+
+	t := &ConcurrencyLimiter{
+		name:    name,
+		slots:   make(chan struct{}, config.Max),
+		queue:   int32(config.Queue),
+		timeout: 30 * time.Second,
+	}
+	if config.Timeout != "" {
+		timeout, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the concurrency timeout: %w", err)
+		}
+		t.timeout = time.Duration(timeout)
+	}
+	return t, nil
+}
+
+// Acquire takes a slot, waiting in the queue when they're all taken.
+// It fails with ErrBackendBusy when the queue is full or the wait times out.
+func (t *ConcurrencyLimiter) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case t.slots <- struct{}{}:
+		return t.acquired(), nil
+	default:
+	}
+
+	if t.waiting.Add(1) > t.queue {
+		t.waiting.Add(-1)
+		rejectedSearches.WithLabelValues(t.name).Inc()
+		return nil, fmt.Errorf("%w: %d searches are running and %d waiting", ErrBackendBusy, cap(t.slots), t.queue)
+	}
+	defer t.waiting.Add(-1)
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	select {
+	case t.slots <- struct{}{}:
+		return t.acquired(), nil
+	case <-timer.C:
+		rejectedSearches.WithLabelValues(t.name).Inc()
+		return nil, fmt.Errorf("%w: no search completed in %s", ErrBackendBusy, t.timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// acquired returns the function releasing the slot, that can be called more than once
+func (t *ConcurrencyLimiter) acquired() func() {
+	inflightSearches.WithLabelValues(t.name).Inc()
+	var released atomic.Bool
+	return func() {
+		if released.CompareAndSwap(false, true) {
+			inflightSearches.WithLabelValues(t.name).Dec()
+			<-t.slots
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestConcurrencyLimiter(t *testing.T) {
+	limiter, err := NewConcurrencyLimiter("elastic", logs.ConcurrencyConfig{Max: 2, Queue: 1, Timeout: "50ms"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	first, err := limiter.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	second, err := limiter.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	// The queued search gets the slot released while it waits
+	acquired := make(chan error)
+	go func() {
+		release, err := limiter.Acquire(ctx)
+		if err == nil {
+			defer release()
+		}
+		acquired <- err
+	}()
+	for limiter.waiting.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The queue is full
+	if _, err := limiter.Acquire(ctx); !errors.Is(err, ErrBackendBusy) {
+		t.Errorf("Acquire() error = %v, want ErrBackendBusy with a full queue", err)
+	}
+
+	first()
+	first()
+	if err := <-acquired; err != nil {
+		t.Errorf("queued Acquire() error = %v", err)
+	}
+
+	// The queued search times out while the slots are taken
+	third, err := limiter.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if _, err := limiter.Acquire(ctx); !errors.Is(err, ErrBackendBusy) {
+		t.Errorf("Acquire() error = %v, want ErrBackendBusy after the timeout", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := limiter.Acquire(cancelled); !errors.Is(err, context.Canceled) {
+		t.Errorf("Acquire() error = %v, want the context error", err)
+	}
+
+	second()
+	third()
+	if len(limiter.slots) != 0 {
+		t.Errorf("expected every slot to be released, %d taken", len(limiter.slots))
+	}
+}
+
+func TestNewConcurrencyLimiter(t *testing.T) {
+	for _, config := range []logs.ConcurrencyConfig{{}, {Max: 1, Queue: -1}, {Max: 1, Timeout: "soon"}} {
+		if _, err := NewConcurrencyLimiter("elastic", config); err == nil {
+			t.Errorf("NewConcurrencyLimiter(%+v) expected an error", config)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/labstack/echo/v4"
+	"golang.org/x/time/rate"
+)
+
+// idleTimeout is how long the bucket of a client is kept after its last request
+const idleTimeout = 10 * time.Minute
+
+// Limiter keeps a token bucket per client
+type Limiter struct {
+	config  logs.RateLimitConfig
+	lock    sync.Mutex
+	clients map[string]*client
+}
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func NewLimiter(config logs.RateLimitConfig) *Limiter {
+	l := &Limiter{
+		config:  config,
+		clients: make(map[string]*client),
+	}
+	go l.cleanup()
+	return l
+}
+
+// Allow consumes a token from the client's bucket.
+// When the bucket is empty, it returns false along with the time until the next token.
+func (t *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	t.lock.Lock()
+	c, ok := t.clients[key]
+	if !ok {
+		c = &client{limiter: t.newLimiter(key)}
+		t.clients[key] = c
+	}
+	c.lastSeen = now
+	t.lock.Unlock()
+
+	reservation := c.limiter.ReserveN(now, 1)
+	if !reservation.OK() {
+		return false, time.Second
+	}
+
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+
+	return true, 0
+}
+
+func (t *Limiter) newLimiter(key string) *rate.Limiter {
+	rps, burst := t.config.RequestsPerSecond, t.config.Burst
+	for _, c := range t.config.Clients {
+		if c.Name == key {
+			rps, burst = c.RequestsPerSecond, c.Burst
+			break
+		}
+	}
+
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(rps)))
+	}
+
+	return rate.NewLimiter(rate.Limit(rps), burst)
+}
+
+func (t *Limiter) cleanup() {
+	for range time.Tick(time.Minute) {
+		t.lock.Lock()
+		for key, c := range t.clients {
+			if time.Since(c.lastSeen) > idleTimeout {
+				delete(t.clients, key)
+			}
+		}
+		t.lock.Unlock()
+	}
+}
+
+// NewIPExtractor returns the extractor of the IP of the clients.
+// The X-Forwarded-For header is only read when it's set by one of the trusted proxies,
+// otherwise any client could evade its limit by rotating the header.
+func NewIPExtractor(trustedProxies []string) (echo.IPExtractor, error) {
+	if len(trustedProxies) == 0 {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, cidr := range trustedProxies {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", cidr, err)
+		}
+		options = append(options, echo.TrustIPRange(ipNet))
+	}
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
+// Middleware rejects the requests of the clients that exceeded their rate with a 429.
+// It must run after the authentication so the clients are identified by their username.
+func (t *Limiter) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.RealIP()
+		if cc, ok := c.(*api.Context); ok && cc.User != nil {
+			key = cc.User.Name
+		}
+		return t.limit(c, key, next)
+	}
+}
+
+// IPMiddleware rejects the requests of the IP addresses that exceeded their rate with a 429.
+// It must run before the authentication, so that the credentials can't be guessed at an unlimited rate.
+func (t *Limiter) IPMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		return t.limit(c, c.RealIP(), next)
+	}
+}
+
+func (t *Limiter) limit(c echo.Context, key string, next echo.HandlerFunc) error {
+	if ok, retryAfter := t.Allow(key); !ok {
+		c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		return echo.NewHTTPError(http.StatusTooManyRequests, "rate limit exceeded")
+	}
+	return next(c)
+}
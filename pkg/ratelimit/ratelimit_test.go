@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/labstack/echo/v4"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	limiter := NewLimiter(logs.RateLimitConfig{
+		RequestsPerSecond: 1,
+		Burst:             2,
+		Clients:           []logs.ClientRateLimit{{Name: "grafana", RequestsPerSecond: 1, Burst: 5}},
+	})
+
+	tests := []struct {
+		name    string
+		key     string
+		allowed int
+	}{
+		{name: "default limit", key: "10.0.0.1", allowed: 2},
+		{name: "other client has its own bucket", key: "10.0.0.2", allowed: 2},
+		{name: "client override", key: "grafana", allowed: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < tt.allowed; i++ {
+				if ok, _ := limiter.Allow(tt.key); !ok {
+					t.Fatalf("request %d should be allowed", i+1)
+				}
+			}
+
+			ok, retryAfter := limiter.Allow(tt.key)
+			if ok {
+				t.Fatalf("request %d should be limited", tt.allowed+1)
+			}
+			if retryAfter <= 0 {
+				t.Errorf("expected a positive retry after, got %s", retryAfter)
+			}
+		})
+	}
+}
+
+func TestNewIPExtractor(t *testing.T) {
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		want           string
+	}{
+		{name: "no trusted proxies", remoteAddr: "10.0.0.1:4000", want: "10.0.0.1"},
+		{name: "trusted proxy", trustedProxies: []string{"10.0.0.0/24"}, remoteAddr: "10.0.0.1:4000", want: "203.0.113.7"},
+		{name: "untrusted proxy", trustedProxies: []string{"10.0.0.0/24"}, remoteAddr: "10.0.1.1:4000", want: "10.0.1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extract, err := NewIPExtractor(tt.trustedProxies)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/search", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set(echo.HeaderXForwardedFor, "203.0.113.7")
+			req.Header.Set(echo.HeaderXRealIP, "198.51.100.1")
+			if got := extract(req); got != tt.want {
+				t.Errorf("the IP = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewIPExtractor([]string{"10.0.0.1"}); err == nil {
+		t.Errorf("NewIPExtractor() accepted an invalid CIDR")
+	}
+}
+
+func TestLimiter_IPMiddleware(t *testing.T) {
+	limiter := NewLimiter(logs.RateLimitConfig{RequestsPerSecond: 1, Burst: 2})
+	handler := limiter.IPMiddleware(func(c echo.Context) error { return c.NoContent(http.StatusNoContent) })
+
+	// The requests are limited by IP address whatever the username they claim
+	e := echo.New()
+	for i, want := range []int{http.StatusNoContent, http.StatusNoContent, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodPost, "/search", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		rec := httptest.NewRecorder()
+		err := handler(&api.Context{Context: e.NewContext(req, rec), User: &api.User{Name: fmt.Sprintf("user-%d", i)}})
+		if httpErr, ok := err.(*echo.HTTPError); ok {
+			rec.Code = httpErr.Code
+		}
+		if rec.Code != want {
+			t.Errorf("request %d = %d, want %d", i+1, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+// Package remote searches the backends implemented out of process, behind a gRPC server.
+//
+// The service, defined in backend.proto, exchanges the json representations of the search params
+// and results as google.protobuf.Struct, so that the plugins can be written in any language
+// without generating code from apm-hub types.
+package remote
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/kommons"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const searchMethod = "/apmhub.plugin.v1.SearchBackend/Search"
+
+func NewRemoteSearchBackend(kClient *kommons.Client, config *logs.RemoteBackendConfig) (*RemoteSearch, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+
+	t := &RemoteSearch{config: config, timeout: 30 * time.Second}
+	if config.Timeout != "" {
+		timeout, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the timeout: %w", err)
+		}
+		t.timeout = time.Duration(timeout)
+	}
+
+	if config.Token != nil {
+		_, token, err := kClient.GetEnvValue(*config.Token, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the token: %w", err)
+		}
+		t.token = token
+	}
+
+	creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+	if config.Insecure {
+		creds = insecure.NewCredentials()
+	}
+
+	// The connection is established lazily, so that a plugin starting after apm-hub isn't an error
+	conn, err := grpc.Dial(config.Address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", config.Address, err)
+	}
+	t.conn = conn
+	return t, nil
+}
+
+// RemoteSearch implements the SearchAPI with the remote backend
+type RemoteSearch struct {
+	config  *logs.RemoteBackendConfig
+	conn    *grpc.ClientConn
+	token   string
+	timeout time.Duration
+}
+
+func (t *RemoteSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var res logs.SearchResults
+
+	req, err := toStruct(q)
+	if err != nil {
+		return res, fmt.Errorf("error encoding the search params: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	if t.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+t.token)
+	}
+
+	resp := new(structpb.Struct)
+	if err := t.conn.Invoke(ctx, searchMethod, req, resp); err != nil {
+		return res, fmt.Errorf("error searching %s: %w", t.config.Address, err)
+	}
+
+	if err := fromStruct(resp, &res); err != nil {
+		return res, fmt.Errorf("error decoding the search results: %w", err)
+	}
+	return res, nil
+}
+
+func (t *RemoteSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the remote backend, the plugin receives the full search params
+func (t *RemoteSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck waits for the connection to the remote backend to be ready
+func (t *RemoteSearch) HealthCheck(ctx context.Context) error {
+	t.conn.Connect()
+	for {
+		state := t.conn.GetState()
+		if state == connectivity.Ready {
+			return nil
+		}
+		if !t.conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("connection to %s is %s: %w", t.config.Address, state, ctx.Err())
+		}
+	}
+}
+
+// Close closes the connection to the remote backend
+func (t *RemoteSearch) Close() error {
+	return t.conn.Close()
+}
+
+// RegisterSearchBackendServer serves the search api on the gRPC server, for the plugins written in Go.
+// When a token is set, the calls must send it as a bearer token.
+func RegisterSearchBackendServer(s *grpc.Server, api logs.SearchAPI, token string) {
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "apmhub.plugin.v1.SearchBackend",
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Search",
+			Handler: func(_ any, ctx context.Context, dec func(any) error, _ grpc.UnaryServerInterceptor) (any, error) {
+				if token != "" && !authorized(ctx, token) {
+					return nil, status.Error(codes.Unauthenticated, "invalid or missing token")
+				}
+
+				req := new(structpb.Struct)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+
+				var q logs.SearchParams
+				if err := fromStruct(req, &q); err != nil {
+					return nil, status.Errorf(codes.InvalidArgument, "invalid search params: %v", err)
+				}
+
+				res, err := api.Search(ctx, &q)
+				if err != nil {
+					return nil, status.Error(codes.Internal, err.Error())
+				}
+				return toStruct(res)
+			},
+		}},
+		Metadata: "backend.proto",
+	}, struct{}{})
+}
+
+func authorized(ctx context.Context, token string) bool {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, value := range md.Get("authorization") {
+		if strings.TrimPrefix(value, "Bearer ") == token {
+			return true
+		}
+	}
+	return false
+}
+
+// toStruct converts the value to a Struct through its json representation
+func toStruct(v any) (*structpb.Struct, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(fields)
+}
+
+func fromStruct(s *structpb.Struct, v any) error {
+	data, err := s.MarshalJSON()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
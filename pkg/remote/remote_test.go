@@ -0,0 +1,109 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+	"google.golang.org/grpc"
+)
+
+// fakeBackend returns the query as the message of its single result
+type fakeBackend struct{}
+
+func (fakeBackend) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	if q.Query == "fail" {
+		return logs.SearchResults{}, errors.New("backend unavailable")
+	}
+	return logs.SearchResults{
+		Total:    1,
+		Results:  []logs.Result{{Time: "2023-05-01T12:00:00Z", Message: q.Query, Labels: q.Labels}},
+		NextPage: "2",
+	}, nil
+}
+
+func (fakeBackend) MatchRoute(q *logs.SearchParams) (bool, bool) {
+	return true, false
+}
+
+func (fakeBackend) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true}
+}
+
+func TestRemoteSearch(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	RegisterSearchBackendServer(server, fakeBackend{}, "secret")
+	go func() { _ = server.Serve(lis) }()
+	defer server.Stop()
+
+	newBackend := func(token string) *RemoteSearch {
+		backend, err := NewRemoteSearchBackend(nil, &logs.RemoteBackendConfig{
+			CommonBackend: logs.CommonBackend{Routes: logs.Routes{{Type: "Proprietary"}}},
+			Address:       lis.Addr().String(),
+			Insecure:      true,
+			Token:         &kommons.EnvVar{Value: token},
+			Timeout:       "5s",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { backend.Close() })
+		return backend
+	}
+
+	backend := newBackend("secret")
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "timeout", Labels: map[string]string{"app": "api"}, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{
+		Total:    1,
+		Results:  []logs.Result{{Time: "2023-05-01T12:00:00Z", Message: "timeout", Labels: map[string]string{"app": "api"}}},
+		NextPage: "2",
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Query: "fail"}); err == nil || !strings.Contains(err.Error(), "backend unavailable") {
+		t.Errorf("expected the backend error, got %v", err)
+	}
+
+	if _, err := newBackend("wrong").Search(context.Background(), &logs.SearchParams{}); err == nil || !strings.Contains(err.Error(), "Unauthenticated") {
+		t.Errorf("expected an authentication error, got %v", err)
+	}
+
+	if match, _ := backend.MatchRoute(&logs.SearchParams{Type: "Proprietary"}); !match {
+		t.Errorf("expected the route to match")
+	}
+
+	if err := backend.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() error = %v", err)
+	}
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+	unreachable, err := NewRemoteSearchBackend(nil, &logs.RemoteBackendConfig{Address: closed.Addr().String(), Insecure: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unreachable.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := unreachable.HealthCheck(ctx); err == nil {
+		t.Errorf("HealthCheck() expected an error for an unreachable backend")
+	}
+}
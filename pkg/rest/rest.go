@@ -0,0 +1,175 @@
+// Package rest is the json client of the http apis searched by the backends
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/kommons"
+)
+
+// maxErrorBody is the size of the response body included in the errors
+const maxErrorBody = 512
+
+// Client sends the requests to the api with the credentials of the connection
+type Client struct {
+	url     string
+	headers http.Header
+	client  *http.Client
+}
+
+func NewClient(kClient *kommons.Client, conn logs.HTTPConnection) (*Client, error) {
+	if conn.URL == "" {
+		return nil, fmt.Errorf("url is required")
+	}
+
+	t := &Client{
+		url:     strings.TrimSuffix(conn.URL, "/"),
+		headers: http.Header{},
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+
+	// The apis listening on a unix socket, e.g. unix:///var/run/docker.sock, are called with the localhost host
+	if socket, ok := strings.CutPrefix(conn.URL, "unix://"); ok {
+		t.url = "http://localhost"
+		t.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+	}
+
+	if conn.Timeout != "" {
+		timeout, err := durationUtil.ParseDuration(conn.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the timeout: %w", err)
+		}
+		t.client.Timeout = time.Duration(timeout)
+	}
+
+	if conn.Token != nil {
+		_, token, err := kClient.GetEnvValue(*conn.Token, conn.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the token: %w", err)
+		}
+		t.headers.Set("Authorization", "Bearer "+token)
+	} else if conn.Username != nil && conn.Password != nil {
+		_, username, err := kClient.GetEnvValue(*conn.Username, conn.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the username: %w", err)
+		}
+		_, password, err := kClient.GetEnvValue(*conn.Password, conn.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the password: %w", err)
+		}
+		req := http.Request{Header: http.Header{}}
+		req.SetBasicAuth(username, password)
+		t.headers.Set("Authorization", req.Header.Get("Authorization"))
+	}
+
+	for name, header := range conn.Headers {
+		_, value, err := kClient.GetEnvValue(header, conn.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the header %s: %w", name, err)
+		}
+		t.headers.Set(name, value)
+	}
+
+	return t, nil
+}
+
+// URL returns the url of the path, relative to the url of the api
+func (t *Client) URL(path string, query url.Values) string {
+	u := t.url + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// Get decodes the json response of the path into out
+func (t *Client) Get(ctx context.Context, path string, query url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.URL(path, query), nil)
+	if err != nil {
+		return err
+	}
+	return t.DoJSON(req, out)
+}
+
+// Post sends the body as json and decodes the json response into out
+func (t *Client) Post(ctx context.Context, path string, query url.Values, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("error encoding the request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.URL(path, query), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return t.DoJSON(req, out)
+}
+
+// Do sends the request with the headers of the connection.
+// The responses that aren't successful are returned as errors.
+func (t *Client) Do(req *http.Request) (*http.Response, error) {
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling %s: %w", req.URL.Path, err)
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &Error{Path: req.URL.Path, StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return resp, nil
+}
+
+// DoJSON sends the request and decodes the json response into out, unless out is nil
+func (t *Client) DoJSON(req *http.Request, out any) error {
+	req.Header.Set("Accept", "application/json")
+	resp, err := t.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("error decoding the response of %s: %w", req.URL.Path, err)
+	}
+	return nil
+}
+
+// Error is the response of an unsuccessful request
+type Error struct {
+	Path       string
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s returned %d", e.Path, e.StatusCode)
+	}
+	return fmt.Sprintf("%s returned %d: %s", e.Path, e.StatusCode, e.Body)
+}
@@ -0,0 +1,87 @@
+package rest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		if user != "admin" || password != "secret" || r.Header.Get("X-Api-Key") != "abcd" {
+			http.Error(w, "invalid credentials", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"path": "` + r.URL.Path + `", "query": "` + r.URL.RawQuery + `"}`))
+	}))
+	defer server.Close()
+
+	client, err := NewClient(nil, logs.HTTPConnection{
+		URL:      server.URL + "/",
+		Username: &kommons.EnvVar{Value: "admin"},
+		Password: &kommons.EnvVar{Value: "secret"},
+		Headers:  map[string]kommons.EnvVar{"X-API-Key": {Value: "abcd"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Path  string `json:"path"`
+		Query string `json:"query"`
+	}
+	if err := client.Get(context.Background(), "/api/search", map[string][]string{"q": {"error"}}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Path != "/api/search" || out.Query != "q=error" {
+		t.Errorf("Get() = %+v", out)
+	}
+
+	unauthenticated, _ := NewClient(nil, logs.HTTPConnection{URL: server.URL})
+	err = unauthenticated.Post(context.Background(), "/api/search", nil, map[string]string{}, &out)
+	var restErr *Error
+	if !errors.As(err, &restErr) || restErr.StatusCode != http.StatusUnauthorized || restErr.Body != "invalid credentials" {
+		t.Errorf("Post() error = %v, want the unauthorized response", err)
+	}
+
+	if _, err := NewClient(nil, logs.HTTPConnection{}); err == nil {
+		t.Errorf("NewClient() expected an error without url")
+	}
+}
+
+func TestClient_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "api.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"path": "` + r.URL.Path + `"}`))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	client, err := NewClient(nil, logs.HTTPConnection{URL: "unix://" + socket})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out struct {
+		Path string `json:"path"`
+	}
+	if err := client.Get(context.Background(), "/v1.41/version", nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Path != "/v1.41/version" {
+		t.Errorf("Get() = %+v", out)
+	}
+}
@@ -0,0 +1,258 @@
+// Package rollbar searches the items and the occurrences of the Rollbar api
+package rollbar
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const defaultURL = "https://api.rollbar.com"
+
+// pageSize is the number of items of the pages of the item search
+const pageSize = 100
+
+// pollInterval is the interval between the polls of the status of the RQL jobs
+var pollInterval = time.Second
+
+var rqlPattern = regexp.MustCompile(`(?i)^\s*select\s`)
+
+type RollbarSearch struct {
+	config *logs.RollbarBackendConfig
+	client *rest.Client
+}
+
+func NewRollbarSearchBackend(kClient *kommons.Client, config *logs.RollbarBackendConfig) (*RollbarSearch, error) {
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	if config.AccessToken != nil {
+		conn.Headers = map[string]kommons.EnvVar{"X-Rollbar-Access-Token": *config.AccessToken}
+		for name, header := range config.Headers {
+			conn.Headers[name] = header
+		}
+	}
+
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &RollbarSearch{config: config, client: client}, nil
+}
+
+// Search runs the RQL query of the search, otherwise it searches the items last seen in the time range
+func (t *RollbarSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	if rqlPattern.MatchString(q.Query) {
+		return t.rql(ctx, q)
+	}
+	return t.items(ctx, q)
+}
+
+func (t *RollbarSearch) items(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	page := 1
+	if q.Page != "" {
+		var err error
+		if page, err = strconv.Atoi(q.Page); err != nil || page < 1 {
+			return logs.SearchResults{}, fmt.Errorf("invalid page %q", q.Page)
+		}
+	}
+
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	if q.Query != "" {
+		query.Set("query", q.Query)
+	}
+	environment := q.Labels["environment"]
+	if environment == "" {
+		environment = t.config.Environment
+	}
+	if environment != "" {
+		query.Set("environment", environment)
+	}
+	if level := q.Labels["level"]; level != "" {
+		query.Set("level", level)
+	}
+	if t.config.Status != "" {
+		query.Set("status", t.config.Status)
+	}
+
+	var res struct {
+		Err     int    `json:"err"`
+		Message string `json:"message"`
+		Result  struct {
+			Items []item `json:"items"`
+		} `json:"result"`
+	}
+	if err := t.client.Get(ctx, "/api/1/items", query, &res); err != nil {
+		return logs.SearchResults{}, err
+	}
+	if res.Err != 0 {
+		return logs.SearchResults{}, fmt.Errorf("error searching the items: %s", res.Message)
+	}
+
+	start, end := q.GetStart(), q.GetEnd()
+	var results logs.SearchResults
+	for _, item := range res.Result.Items {
+		if start != nil && item.LastOccurrence < start.Unix() {
+			continue
+		}
+		if end != nil && item.FirstOccurrence > end.Unix() {
+			continue
+		}
+		results.Total++
+		if q.Limit <= 0 || int64(len(results.Results)) < q.Limit {
+			results.Results = append(results.Results, item.result(t.config.Labels))
+		}
+	}
+	if len(res.Result.Items) == pageSize {
+		results.NextPage = strconv.Itoa(page + 1)
+	}
+	return results, nil
+}
+
+// rql runs the RQL query as a job, and returns its rows once it succeeds
+func (t *RollbarSearch) rql(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var job struct {
+		Err     int    `json:"err"`
+		Message string `json:"message"`
+		Result  struct {
+			ID     int64  `json:"id"`
+			Status string `json:"status"`
+			Result struct {
+				Columns []string `json:"columns"`
+				Rows    [][]any  `json:"rows"`
+				Errors  []string `json:"errors"`
+			} `json:"result"`
+		} `json:"result"`
+	}
+	if err := t.client.Post(ctx, "/api/1/rql/jobs", nil, map[string]any{"query_string": q.Query, "force_refresh": true}, &job); err != nil {
+		return logs.SearchResults{}, err
+	}
+	if job.Err != 0 {
+		return logs.SearchResults{}, fmt.Errorf("error running the RQL query: %s", job.Message)
+	}
+
+	path := fmt.Sprintf("/api/1/rql/job/%d", job.Result.ID)
+	for job.Result.Status != "success" {
+		switch job.Result.Status {
+		case "failed", "cancelled", "timed_out":
+			return logs.SearchResults{}, fmt.Errorf("the RQL query %s: %v", job.Result.Status, job.Result.Result.Errors)
+		}
+
+		select {
+		case <-ctx.Done():
+			return logs.SearchResults{}, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+		if err := t.client.Get(ctx, path, url.Values{"expand": {"result"}}, &job); err != nil {
+			return logs.SearchResults{}, err
+		}
+	}
+
+	results := logs.SearchResults{Total: len(job.Result.Result.Rows)}
+	for _, row := range job.Result.Result.Rows {
+		results.Results = append(results.Results, rowResult(job.Result.Result.Columns, row, t.config.Labels))
+	}
+	sort.SliceStable(results.Results, func(i, j int) bool { return results.Results[i].Time < results.Results[j].Time })
+	if q.Limit > 0 && int64(len(results.Results)) > q.Limit {
+		results.Results = results.Results[:q.Limit]
+	}
+	return results, nil
+}
+
+func (t *RollbarSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Rollbar backend, the query is sent as RQL or as the text of the item search
+func (t *RollbarSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck gets the first page of the items
+func (t *RollbarSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/1/items", nil, nil)
+}
+
+type item struct {
+	ID               int64  `json:"id"`
+	Counter          int64  `json:"counter"`
+	Title            string `json:"title"`
+	Level            string `json:"level"`
+	Status           string `json:"status"`
+	Environment      string `json:"environment"`
+	Framework        string `json:"framework"`
+	TotalOccurrences int64  `json:"total_occurrences"`
+	// LastOccurrence and FirstOccurrence are unix timestamps in seconds
+	LastOccurrence  int64 `json:"last_occurrence_timestamp"`
+	FirstOccurrence int64 `json:"first_occurrence_timestamp"`
+}
+
+func (i item) result(labels map[string]string) logs.Result {
+	r := logs.Result{
+		Id:      strconv.FormatInt(i.ID, 10),
+		Time:    time.Unix(i.LastOccurrence, 0).UTC().Format(time.RFC3339),
+		Message: i.Title,
+		Labels: map[string]string{
+			"counter":     strconv.FormatInt(i.Counter, 10),
+			"level":       i.Level,
+			"status":      i.Status,
+			"environment": i.Environment,
+			"occurrences": strconv.FormatInt(i.TotalOccurrences, 10),
+			"first_seen":  time.Unix(i.FirstOccurrence, 0).UTC().Format(time.RFC3339),
+		},
+	}
+	if i.Framework != "" {
+		r.Labels["framework"] = i.Framework
+	}
+	for k, v := range labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+// rowResult renders a row of an RQL query, with the columns as labels.
+// The timestamp column is the time of the result and the body, title or message column its message.
+func rowResult(columns []string, row []any, labels map[string]string) logs.Result {
+	r := logs.Result{Labels: make(map[string]string, len(columns)+len(labels))}
+	for i, column := range columns {
+		if i >= len(row) || row[i] == nil {
+			continue
+		}
+
+		value := fmt.Sprint(row[i])
+		if f, ok := row[i].(float64); ok {
+			value = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+
+		switch column {
+		case "timestamp":
+			if f, ok := row[i].(float64); ok {
+				r.Time = time.Unix(int64(f), 0).UTC().Format(time.RFC3339)
+				continue
+			}
+		case "occurrence_id", "id":
+			r.Id = value
+			continue
+		case "body", "item.title", "title", "message":
+			if r.Message == "" {
+				r.Message = value
+				continue
+			}
+		}
+		r.Labels[column] = value
+	}
+	for k, v := range labels {
+		r.Labels[k] = v
+	}
+	return r
+}
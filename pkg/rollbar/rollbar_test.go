@@ -0,0 +1,105 @@
+package rollbar
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestRollbarSearch(t *testing.T) {
+	pollInterval = time.Millisecond
+
+	var requests []string
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Rollbar-Access-Token") != "read-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery)
+		switch r.URL.Path {
+		case "/api/1/items":
+			w.Write([]byte(`{"err": 0, "result": {"page": 1, "items": [
+				{"id": 272505123, "counter": 42, "title": "TypeError: cart is undefined", "level": "error", "status": "active",
+				 "environment": "production", "framework": "browser-js", "total_occurrences": 17,
+				 "first_occurrence_timestamp": 1682899200, "last_occurrence_timestamp": 1682942400},
+				{"id": 272505000, "counter": 7, "title": "old error", "level": "error", "status": "active",
+				 "environment": "production", "total_occurrences": 1,
+				 "first_occurrence_timestamp": 1672531200, "last_occurrence_timestamp": 1672531200}
+			]}}`))
+		case "/api/1/rql/jobs":
+			w.Write([]byte(`{"err": 0, "result": {"id": 9, "status": "new"}}`))
+		case "/api/1/rql/job/9":
+			polls++
+			if polls < 2 {
+				w.Write([]byte(`{"err": 0, "result": {"id": 9, "status": "running"}}`))
+				return
+			}
+			w.Write([]byte(`{"err": 0, "result": {"id": 9, "status": "success", "result": {
+				"columns": ["occurrence_id", "timestamp", "body", "level"],
+				"rows": [[1002, 1682942460, "cart is undefined", "error"], [1001, 1682942400, "cart is undefined", "error"]]
+			}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewRollbarSearchBackend(nil, &logs.RollbarBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "rollbar"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		AccessToken:    &kommons.EnvVar{Value: "read-token"},
+		Environment:    "production",
+		Status:         "active",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "cart", Start: "2023-05-01T00:00:00Z", End: "2023-05-02T00:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 1, Results: []logs.Result{{
+		Id:      "272505123",
+		Time:    "2023-05-01T12:00:00Z",
+		Message: "TypeError: cart is undefined",
+		Labels: map[string]string{
+			"counter": "42", "level": "error", "status": "active", "environment": "production", "occurrences": "17",
+			"first_seen": "2023-05-01T00:00:00Z", "framework": "browser-js", "source": "rollbar",
+		},
+	}}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Query: "SELECT * FROM item_occurrence WHERE item.counter = 42", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = logs.SearchResults{Total: 2, Results: []logs.Result{{
+		Id:      "1001",
+		Time:    "2023-05-01T12:00:00Z",
+		Message: "cart is undefined",
+		Labels:  map[string]string{"level": "error", "source": "rollbar"},
+	}}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantRequests := []string{
+		"GET /api/1/items?environment=production&page=1&query=cart&status=active",
+		"POST /api/1/rql/jobs?",
+		"GET /api/1/rql/job/9?expand=result",
+		"GET /api/1/rql/job/9?expand=result",
+	}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Errorf("requests = %v, want %v", requests, wantRequests)
+	}
+}
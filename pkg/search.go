@@ -1,16 +1,33 @@
 package pkg
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/flanksource/commons/logger"
 	"github.com/flanksource/commons/timer"
 
 	"github.com/flanksource/apm-hub/api"
 	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/audit"
+	"github.com/flanksource/apm-hub/pkg/auth"
+	"github.com/flanksource/apm-hub/pkg/configdb"
+	"github.com/flanksource/apm-hub/pkg/guardrail"
+	"github.com/flanksource/apm-hub/pkg/health"
+	"github.com/flanksource/apm-hub/pkg/mapper"
+	"github.com/flanksource/apm-hub/pkg/pipeline"
+	"github.com/flanksource/apm-hub/pkg/ratelimit"
+	"github.com/flanksource/apm-hub/pkg/slowquery"
 	"github.com/labstack/echo/v4"
 )
 
+// maxPages is the number of pages of a backend followed by SearchAllPages
+const maxPages = 100
+
 // Search and collate logs
 func Search(c echo.Context) error {
 	cc := c.(*api.Context)
@@ -21,33 +38,382 @@ func Search(c echo.Context) error {
 	}
 	searchParams.SetDefaults()
 
+	grant, err := authorize(cc, searchParams)
+	if err != nil {
+		return err
+	}
+
 	timer := timer.NewTimer()
-	results := &logs.SearchResults{}
-	for i, backend := range logs.GlobalBackends {
-		matched, isAdditive := backend.API.MatchRoute(searchParams)
-		if !matched {
-			logger.Debugf("backend[%d] did not match any routes", i)
+	start := time.Now()
+	backendResults, backendQueries := searchBackends(cc.Request().Context(), cc.Tenant, searchParams, grant)
+	var matchedBackends []string
+	for _, backendResult := range backendResults {
+		matchedBackends = append(matchedBackends, backendResult.Backend)
+	}
+	results := mergeResults(backendResults)
+
+	logger.Infof("[%s] => %d results in %s", searchParams, results.Total, timer)
+	slowquery.Record(cc.Tenant, *searchParams, time.Since(start), results.Total, backendQueries)
+	audit.GlobalAuditor.Record(newAuditEvent(cc, searchParams, matchedBackends, results.Total, nil))
+
+	return cc.JSON(http.StatusOK, results)
+}
+
+// SearchAll returns the processed results of every backend matching the search params,
+// with the errors of the backends that failed or were skipped.
+// It's meant for the background jobs, as it searches without the restrictions of a user.
+func SearchAll(searchParams *logs.SearchParams) ([]logs.Result, error) {
+	backendResults, _ := searchBackends(context.Background(), "", searchParams, nil)
+	var errs []error
+	for _, backendResult := range backendResults {
+		if backendResult.Err != nil {
+			errs = append(errs, fmt.Errorf("error searching %s: %w", backendResult.Backend, backendResult.Err))
+		}
+	}
+	return mergeResults(backendResults).Results, errors.Join(errs...)
+}
+
+// SearchAllPages returns the processed results of every page of every backend matching the search params,
+// following the next page of each backend until it's exhausted or maxPages are returned,
+// with the errors of the backends that failed. Like SearchAll, it searches without the restrictions of a user.
+func SearchAllPages(searchParams *logs.SearchParams) ([]logs.Result, error) {
+	ctx := context.Background()
+	var results []logs.Result
+	var errs []error
+	eachBackend(ctx, "", searchParams, nil, func(i int, backend logs.SearchBackend, q logs.SearchParams) {
+		for page := 1; ; page++ {
+			result, err := searchBackend(ctx, backend, q)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("error searching %s: %w", backendName(i, backend), err))
+				return
+			}
+			results = append(results, processResults("", backend, nil, result.Results)...)
+
+			if len(result.Results) == 0 || result.NextPage == "" || result.NextPage == q.Page {
+				return
+			}
+			// The page tokens of a backend can cycle
+			if page >= maxPages {
+				logger.Warnf("backend[%d] returned more than %d pages, skipping the next ones", i, maxPages)
+				return
+			}
+			q.Page = result.NextPage
+		}
+	})
+	return results, errors.Join(errs...)
+}
+
+// authorize checks the search params and their limit against the guardrails, resolves the config item of the search,
+// scopes the search params to the tenant of the request and checks them against the rbac rules
+func authorize(cc *api.Context, searchParams *logs.SearchParams) (*auth.Grant, error) {
+	if err := guardrail.GlobalGuardrails.Check(searchParams); err != nil {
+		audit.GlobalAuditor.Record(newAuditEvent(cc, searchParams, nil, 0, err))
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err := guardrail.GlobalGuardrails.CheckLimit(searchParams); err != nil {
+		audit.GlobalAuditor.Record(newAuditEvent(cc, searchParams, nil, 0, err))
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err := configdb.GlobalResolver.Resolve(searchParams); err != nil {
+		logger.Errorf("error resolving the search id: %v", err)
+	}
+
+	auth.GlobalTenancy.Inject(cc.Tenant, searchParams)
+	if auth.GlobalAuthorizer == nil {
+		return nil, nil
+	}
+
+	grant, err := auth.GlobalAuthorizer.Authorize(cc.User, searchParams)
+	if err != nil {
+		audit.GlobalAuditor.Record(newAuditEvent(cc, searchParams, nil, 0, err))
+		return nil, echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	return grant, nil
+}
+
+// backendResult is the processed search result of a single backend
+type backendResult struct {
+	Backend string
+	logs.SearchResults
+	// Err is the error of the search, the results are empty when set
+	Err error
+}
+
+// searchBackends searches every healthy backend allowed by the grant and the tenant whose routes match the searches
+// the search params expand to. The results of the backends are filtered and run through the pipelines.
+func searchBackends(ctx context.Context, tenant string, searchParams *logs.SearchParams, grant *auth.Grant) ([]backendResult, []slowquery.BackendQuery) {
+	var results []backendResult
+	var backendQueries []slowquery.BackendQuery
+	for _, q := range mapSearches(ctx, searchParams, grant) {
+		// The shadow backends are searched once the others are, to compare them to their results
+		var shadows []int
+		var primary shadowComparison
+		for i, backend := range logs.GlobalBackends {
+			if !grant.AllowsBackend(backend) {
+				logger.Debugf("backend[%d] is not allowed for the user", i)
+				continue
+			}
+			if !auth.GlobalTenancy.AllowsBackend(tenant, backend) {
+				logger.Debugf("backend[%d] is not allowed for the tenant", i)
+				continue
+			}
+
+			matched, isAdditive := backend.API.MatchRoute(&q)
+			if !matched {
+				logger.Debugf("backend[%d] did not match any routes", i)
+				continue
+			}
+
+			name := backendName(i, backend)
+			if !health.GlobalChecker.Allow(name) {
+				logger.Debugf("backend[%d] is unhealthy", i)
+				// The results of the skipped backend are missing, unlike those of a shadow backend
+				if !backend.Shadow {
+					results = append(results, backendResult{Backend: name, Err: health.ErrUnhealthy})
+				}
+				continue
+			}
+			if backend.Shadow {
+				shadows = append(shadows, i)
+				continue
+			}
+
+			backendStart := time.Now()
+			searchResult, err := searchBackend(ctx, backend, q)
+			primary.Duration += time.Since(backendStart)
+			primary.Results += len(searchResult.Results)
+			backendQueries = append(backendQueries, newBackendQuery(i, backend, &q, time.Since(backendStart), len(searchResult.Results), err))
+			// The searches cancelled by the client or rejected by the concurrency limit don't tell anything about the backend
+			if ctx.Err() == nil && !errors.Is(err, ratelimit.ErrBackendBusy) {
+				health.GlobalChecker.Record(name, err)
+			}
+			if err != nil {
+				logger.Errorf("error searching backend[%d]: %v", i, err)
+				results = append(results, backendResult{Backend: name, Err: err})
+				continue
+			}
+			searchResult.Results = processResults(tenant, backend, grant, searchResult.Results)
+			results = append(results, backendResult{Backend: name, SearchResults: searchResult})
+
+			// If the route is additive, the search stops at this backend.
+			if isAdditive {
+				logger.Infof("additive route matched. discarding previous results and exiting early")
+				break
+			}
+		}
+
+		for _, i := range shadows {
+			go searchShadow(i, logs.GlobalBackends[i], q, primary)
+		}
+	}
+
+	return results, backendQueries
+}
+
+// mapSearches returns the searches the search params expand to that the grant allows.
+// The mappers can change the type and the labels of a search once it was authorized, so the expanded searches are checked again.
+func mapSearches(ctx context.Context, searchParams *logs.SearchParams, grant *auth.Grant) []logs.SearchParams {
+	var searches []logs.SearchParams
+	for _, q := range mapper.GlobalChain.Map(ctx, *searchParams) {
+		if err := grant.Allows(&q); err != nil {
+			logger.Warnf("skipping the search %s expanded from %s: %v", q, searchParams, err)
 			continue
 		}
+		searches = append(searches, q)
+	}
+	return searches
+}
 
-		searchResult, err := backend.API.Search(searchParams)
-		if err != nil {
-			logger.Errorf("error searching backend[%d]: %v", i, err)
+// searchBackend searches the backend with the search params adapted to its capabilities:
+// the time range is narrowed to the widest one it supports
+// and the results are filtered by the query when it doesn't apply it.
+func searchBackend(ctx context.Context, backend logs.SearchBackend, q logs.SearchParams) (logs.SearchResults, error) {
+	capabilities := backend.API.Capabilities()
+	narrowTimeRange(capabilities, &q)
+
+	release, err := acquire(ctx, backend)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+	result, err := backend.API.Search(ctx, &q)
+	release()
+	if err != nil || capabilities.Query || q.Query == "" {
+		return result, err
+	}
+
+	matched := filterQuery(result.Results, q.Query)
+	if result.Total -= len(result.Results) - len(matched); result.Total < 0 {
+		result.Total = 0
+	}
+	result.Results = matched
+	return result, nil
+}
+
+// streamBackend passes the results of a streaming backend to fn in batches of size,
+// until fn returns false. The search params are adapted to the capabilities of the backend like in searchBackend.
+func streamBackend(ctx context.Context, backend logs.SearchBackend, q logs.SearchParams, size int, fn func([]logs.Result) bool) error {
+	streamer, ok := backend.API.(logs.SearchStreamer)
+	if !ok {
+		return fmt.Errorf("the backend doesn't support streaming")
+	}
+
+	capabilities := backend.API.Capabilities()
+	narrowTimeRange(capabilities, &q)
+
+	// The slot is held until the whole stream is read
+	release, err := acquire(ctx, backend)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan logs.Result, size)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- streamer.SearchStream(ctx, &q, results)
+		close(results)
+	}()
+
+	query := ""
+	if !capabilities.Query {
+		query = strings.ToLower(q.Query)
+	}
+
+	done := false
+	batch := make([]logs.Result, 0, size)
+	for r := range results {
+		// Drain the results sent before the search noticed the cancellation
+		if done || !matchesQuery(r, query) {
 			continue
 		}
-		results.Append(&searchResult)
 
-		// If the route is additive, all the previous search results are discarded
-		// and just the search result from this backend is returned exclusively.
-		if isAdditive {
-			logger.Infof("additive route matched. discarding previous results and exiting early")
-			results := &logs.SearchResults{}
-			results.Append(&searchResult)
-			break
+		batch = append(batch, r)
+		if len(batch) == size {
+			if done = !fn(batch); done {
+				cancel()
+			}
+			batch = make([]logs.Result, 0, size)
 		}
 	}
 
-	logger.Infof("[%s] => %d results in %s", searchParams, results.Total, timer)
+	err = <-errs
+	if done {
+		return nil
+	}
+	if len(batch) > 0 {
+		fn(batch)
+	}
+	return err
+}
+
+// acquire takes a slot of the concurrency limit of the backend
+func acquire(ctx context.Context, backend logs.SearchBackend) (func(), error) {
+	if backend.Limiter == nil {
+		return func() {}, nil
+	}
+	return backend.Limiter.Acquire(ctx)
+}
+
+// narrowTimeRange moves the start of the search forward to the widest time range the backend supports
+func narrowTimeRange(capabilities logs.Capabilities, q *logs.SearchParams) {
+	if capabilities.MaxTimeRange <= 0 {
+		return
+	}
+
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+	if start := q.GetStart(); start == nil || end.Sub(*start) > capabilities.MaxTimeRange {
+		q.SetStart(end.Add(-capabilities.MaxTimeRange))
+	}
+}
+
+// filterQuery keeps the results whose message contains the query, ignoring the case
+func filterQuery(results []logs.Result, query string) []logs.Result {
+	query = strings.ToLower(query)
+	matched := results[:0]
+	for _, r := range results {
+		if matchesQuery(r, query) {
+			matched = append(matched, r)
+		}
+	}
+	return matched
+}
+
+// matchesQuery returns true if the message contains the lower case query
+func matchesQuery(r logs.Result, query string) bool {
+	return query == "" || strings.Contains(strings.ToLower(r.Message), query)
+}
+
+// processResults runs the results of the backend through the pipelines and filters them.
+// The results are filtered last, as the pipelines can rename or create the labels they're filtered by.
+func processResults(tenant string, backend logs.SearchBackend, grant *auth.Grant, results []logs.Result) []logs.Result {
+	if backend.Pipeline != nil {
+		results = backend.Pipeline.Process(results)
+	}
+	results = pipeline.GlobalPipeline.Process(results)
+
+	results = grant.Filter(results)
+	// The internal searches aren't scoped to a tenant
+	if tenant != "" {
+		results = auth.GlobalTenancy.Filter(tenant, results)
+	}
+	return results
+}
+
+func newBackendQuery(i int, backend logs.SearchBackend, q *logs.SearchParams, duration time.Duration, count int, err error) slowquery.BackendQuery {
+	bq := slowquery.BackendQuery{
+		Backend:  backendName(i, backend),
+		Duration: duration.String(),
+		Results:  count,
+	}
+
+	if err != nil {
+		bq.Error = err.Error()
+	}
+
+	if renderer, ok := backend.API.(logs.QueryRenderer); ok && slowquery.Threshold > 0 {
+		query, err := renderer.RenderQuery(q)
+		if err != nil {
+			logger.Debugf("error rendering the query for backend[%d]: %v", i, err)
+		}
+		bq.Query = query
+	}
+
+	return bq
+}
+
+// backendName returns the configured name of the backend
+// falling back to its position for unnamed backends
+func backendName(i int, backend logs.SearchBackend) string {
+	if backend.Name != "" {
+		return backend.Name
+	}
+	return fmt.Sprintf("backend[%d]", i)
+}
+
+func newAuditEvent(cc *api.Context, q *logs.SearchParams, backends []string, total int, err error) audit.Event {
+	event := audit.Event{
+		Time:     time.Now(),
+		RemoteIP: cc.RealIP(),
+		Tenant:   cc.Tenant,
+		Params:   *q,
+		Backends: backends,
+		Results:  total,
+	}
+
+	if cc.User != nil {
+		event.User = cc.User.Name
+	}
+
+	if err != nil {
+		event.Error = err.Error()
+	}
 
-	return cc.JSON(http.StatusOK, *results)
+	return event
 }
@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/auth"
+	"github.com/flanksource/apm-hub/pkg/mapper"
+	"github.com/flanksource/apm-hub/pkg/pipeline"
+)
+
+func TestProcessResults_FiltersMappedLabels(t *testing.T) {
+	authorizer := auth.NewAuthorizer(logs.RBACConfig{
+		Rules: []logs.RBACRule{{Groups: []string{"team-a"}, Labels: map[string]string{"namespace": "team-a"}}},
+	})
+	grant, err := authorizer.Authorize(&api.User{Name: "alice", Groups: []string{"team-a"}}, &logs.SearchParams{})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	p, err := pipeline.New("elastic", []logs.PipelineStep{
+		{LabelMap: &logs.LabelMapStep{Rename: map[string]string{"kubernetes.namespace": "namespace"}}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("pipeline.New() error = %v", err)
+	}
+
+	backend := logs.SearchBackend{Name: "elastic", Pipeline: p}
+	results := []logs.Result{
+		{Id: "a", Labels: map[string]string{"kubernetes.namespace": "team-a"}},
+		{Id: "b", Labels: map[string]string{"kubernetes.namespace": "team-b"}},
+	}
+
+	got := processResults("", backend, grant, results)
+	if len(got) != 1 || got[0].Id != "a" {
+		t.Errorf("processResults() = %+v, want the result of team-a only", got)
+	}
+}
+
+func TestMapSearches_ReauthorizesExpandedSearches(t *testing.T) {
+	aliases, err := mapper.NewAliasMapper([]logs.SearchAlias{{
+		Id: "checkout",
+		Targets: []logs.SearchAliasTarget{
+			{Type: "KubernetesPod", Id: "shop/checkout-0"},
+			{Type: "VM", Id: "checkout-db"},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(chain mapper.Chain) { mapper.GlobalChain = chain }(mapper.GlobalChain)
+	mapper.GlobalChain = mapper.Chain{aliases}
+
+	authorizer := auth.NewAuthorizer(logs.RBACConfig{
+		Rules: []logs.RBACRule{{Groups: []string{"team-a"}, Types: []string{"KubernetesPod"}}},
+	})
+	params := &logs.SearchParams{Type: "KubernetesPod", Id: "checkout"}
+	grant, err := authorizer.Authorize(&api.User{Name: "alice", Groups: []string{"team-a"}}, params)
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	got := mapSearches(context.Background(), params, grant)
+	want := []logs.SearchParams{{Type: "KubernetesPod", Id: "shop/checkout-0"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mapSearches() = %+v, want %+v", got, want)
+	}
+}
+
+// pagedSearch returns a page of results per search
+type pagedSearch struct {
+	fakeSearch
+	pages [][]logs.Result
+	// cycle returns the first page after the last one
+	cycle    bool
+	searches int
+}
+
+func (t *pagedSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	t.searches++
+	page, _ := strconv.Atoi(q.Page)
+	result := logs.SearchResults{Results: t.pages[page]}
+	if page+1 < len(t.pages) {
+		result.NextPage = strconv.Itoa(page + 1)
+	} else if t.cycle {
+		result.NextPage = "0"
+	}
+	return result, nil
+}
+
+func TestSearchAllPages(t *testing.T) {
+	defer func(backends []logs.SearchBackend) { logs.GlobalBackends = backends }(logs.GlobalBackends)
+	logs.GlobalBackends = []logs.SearchBackend{
+		{Name: "elastic", API: &pagedSearch{pages: [][]logs.Result{{{Id: "a"}, {Id: "b"}}, {{Id: "c"}}}}},
+		{Name: "loki", API: &fakeSearch{results: []logs.Result{{Id: "d"}}}},
+	}
+
+	results, err := SearchAllPages(&logs.SearchParams{Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range results {
+		got = append(got, r.Id)
+	}
+	if !reflect.DeepEqual(got, []string{"a", "b", "c", "d"}) {
+		t.Errorf("SearchAllPages() = %v, want the results of every page of every backend", got)
+	}
+}
+
+func TestSearchAllPages_Errors(t *testing.T) {
+	defer func(backends []logs.SearchBackend) { logs.GlobalBackends = backends }(logs.GlobalBackends)
+	cycling := &pagedSearch{pages: [][]logs.Result{{{Id: "a"}}, {{Id: "b"}}}, cycle: true}
+	logs.GlobalBackends = []logs.SearchBackend{
+		{Name: "elastic", API: cycling},
+		{Name: "loki", API: &fakeSearch{err: errors.New("connection refused")}},
+	}
+
+	results, err := SearchAllPages(&logs.SearchParams{Limit: 1})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Errorf("SearchAllPages() error = %v, want the error of the failed backend", err)
+	}
+	if cycling.searches != maxPages || len(results) != maxPages {
+		t.Errorf("SearchAllPages() followed %d pages, want the cycling pages capped at %d", cycling.searches, maxPages)
+	}
+}
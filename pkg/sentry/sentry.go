@@ -0,0 +1,316 @@
+// Package sentry searches the issues of the Sentry api
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+const defaultURL = "https://sentry.io"
+
+// nextLink matches the cursor of the next page in the Link header, when the page has results
+var nextLink = regexp.MustCompile(`rel="next";\s*results="true";\s*cursor="([^"]+)"`)
+
+type SentrySearch struct {
+	config *logs.SentryBackendConfig
+	client *rest.Client
+
+	lock sync.Mutex
+	// projectID is the id of the project, looked up once as the issues are searched by project id
+	projectID string
+}
+
+func NewSentrySearchBackend(kClient *kommons.Client, config *logs.SentryBackendConfig) (*SentrySearch, error) {
+	if config.Organization == "" {
+		return nil, fmt.Errorf("the organization is required")
+	}
+	if config.Events && config.Project == "" {
+		return nil, fmt.Errorf("the project is required to search its events")
+	}
+
+	conn := config.HTTPConnection
+	if conn.URL == "" {
+		conn.URL = defaultURL
+	}
+	client, err := rest.NewClient(kClient, conn)
+	if err != nil {
+		return nil, err
+	}
+	return &SentrySearch{config: config, client: client}, nil
+}
+
+// Search returns the issues seen in the time range, most recently seen first.
+// The events of the issue are returned instead when the search has an issue_id label, and the events of the project
+// when the backend searches the events.
+func (t *SentrySearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	query := url.Values{}
+	query.Set("query", t.query(q))
+	if start := q.GetStart(); start != nil {
+		query.Set("start", start.UTC().Format(time.RFC3339))
+		end := time.Now()
+		if e := q.GetEnd(); e != nil {
+			end = *e
+		}
+		query.Set("end", end.UTC().Format(time.RFC3339))
+	}
+	if q.Page != "" {
+		query.Set("cursor", q.Page)
+	}
+
+	if issueID := q.Labels["issue_id"]; issueID != "" {
+		return t.events(ctx, t.organizationPath()+"/issues/"+url.PathEscape(issueID)+"/events/", query)
+	}
+	if t.config.Events {
+		return t.events(ctx, t.projectPath()+"events/", query)
+	}
+
+	query.Set("sort", "date")
+	if q.Limit > 0 {
+		query.Set("limit", strconv.FormatInt(q.Limit, 10))
+	}
+	if t.config.Project != "" {
+		id, err := t.project(ctx)
+		if err != nil {
+			return logs.SearchResults{}, err
+		}
+		query.Set("project", id)
+	}
+
+	var issues []issue
+	nextPage, err := t.get(ctx, t.organizationPath()+"/issues/", query, &issues)
+	if err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error getting the issues: %w", err)
+	}
+
+	results := logs.SearchResults{NextPage: nextPage}
+	for _, issue := range issues {
+		results.Results = append(results.Results, issue.result(t.config.Labels))
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// events returns the events of the path, with their tags
+func (t *SentrySearch) events(ctx context.Context, path string, query url.Values) (logs.SearchResults, error) {
+	query.Set("full", "true")
+
+	var events []event
+	nextPage, err := t.get(ctx, path, query, &events)
+	if err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error getting the events: %w", err)
+	}
+
+	results := logs.SearchResults{NextPage: nextPage}
+	for _, event := range events {
+		results.Results = append(results.Results, event.result(t.config.Labels))
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+// get decodes the page of the path, and returns the cursor of the next page from the Link header
+func (t *SentrySearch) get(ctx context.Context, path string, query url.Values, v any) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.client.URL(path, query), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return "", err
+	}
+	if match := nextLink.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+		return match[1], nil
+	}
+	return "", nil
+}
+
+// query returns the query of the search, or the configured query, with the tags of the search
+func (t *SentrySearch) query(q *logs.SearchParams) string {
+	query := q.Query
+	if query == "" {
+		query = t.config.Query
+	}
+
+	filters := []string{}
+	if query != "" {
+		filters = append(filters, query)
+	}
+	for _, tag := range t.config.Tags {
+		if v, ok := q.Labels[tag]; ok {
+			filters = append(filters, tag+":"+strconv.Quote(v))
+		}
+	}
+	return strings.Join(filters, " ")
+}
+
+func (t *SentrySearch) organizationPath() string {
+	return "/api/0/organizations/" + url.PathEscape(t.config.Organization)
+}
+
+func (t *SentrySearch) projectPath() string {
+	return "/api/0/projects/" + url.PathEscape(t.config.Organization) + "/" + url.PathEscape(t.config.Project) + "/"
+}
+
+// project returns the id of the project
+func (t *SentrySearch) project(ctx context.Context) (string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if t.projectID != "" {
+		return t.projectID, nil
+	}
+
+	var project struct {
+		ID string `json:"id"`
+	}
+	if err := t.client.Get(ctx, t.projectPath(), nil, &project); err != nil {
+		return "", fmt.Errorf("error getting the project %s: %w", t.config.Project, err)
+	}
+	t.projectID = project.ID
+	return t.projectID, nil
+}
+
+func (t *SentrySearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Sentry backend, the query is sent as a Sentry search query
+func (t *SentrySearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck gets the organization
+func (t *SentrySearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, t.organizationPath()+"/", nil, nil)
+}
+
+type issue struct {
+	ID        string `json:"id"`
+	ShortID   string `json:"shortId"`
+	Title     string `json:"title"`
+	Culprit   string `json:"culprit"`
+	Permalink string `json:"permalink"`
+	Level     string `json:"level"`
+	Status    string `json:"status"`
+	Platform  string `json:"platform"`
+	Count     string `json:"count"`
+	UserCount int    `json:"userCount"`
+	LastSeen  string `json:"lastSeen"`
+	FirstSeen string `json:"firstSeen"`
+	Project   struct {
+		Slug string `json:"slug"`
+	} `json:"project"`
+	Metadata struct {
+		Type     string `json:"type"`
+		Value    string `json:"value"`
+		Filename string `json:"filename"`
+		Function string `json:"function"`
+	} `json:"metadata"`
+}
+
+// result renders the issue with the summary of its stack trace as message,
+// e.g. ZeroDivisionError: division by zero at index (app/views.py)
+func (i issue) result(labels map[string]string) logs.Result {
+	message := i.Title
+	location := i.Culprit
+	if i.Metadata.Function != "" {
+		location = i.Metadata.Function
+		if i.Metadata.Filename != "" {
+			location += " (" + i.Metadata.Filename + ")"
+		}
+	}
+	if location != "" {
+		message += " at " + location
+	}
+
+	r := logs.Result{
+		Id:      i.ID,
+		Time:    i.LastSeen,
+		Message: message,
+		Labels: map[string]string{
+			"short_id":   i.ShortID,
+			"level":      i.Level,
+			"status":     i.Status,
+			"project":    i.Project.Slug,
+			"count":      i.Count,
+			"users":      strconv.Itoa(i.UserCount),
+			"first_seen": i.FirstSeen,
+		},
+	}
+	for k, v := range map[string]string{
+		"platform":  i.Platform,
+		"culprit":   i.Culprit,
+		"type":      i.Metadata.Type,
+		"permalink": i.Permalink,
+	} {
+		if v != "" {
+			r.Labels[k] = v
+		}
+	}
+	for k, v := range labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+type event struct {
+	EventID     string `json:"eventID"`
+	GroupID     string `json:"groupID"`
+	Title       string `json:"title"`
+	Message     string `json:"message"`
+	Culprit     string `json:"culprit"`
+	Platform    string `json:"platform"`
+	DateCreated string `json:"dateCreated"`
+	Tags        []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"tags"`
+}
+
+// result renders the event with its message, or its title, as message. The tags of the event become labels.
+func (e event) result(labels map[string]string) logs.Result {
+	message := e.Message
+	if message == "" {
+		message = e.Title
+	}
+
+	r := logs.Result{
+		Id:      e.EventID,
+		Time:    e.DateCreated,
+		Message: message,
+		Labels:  make(map[string]string, len(e.Tags)+len(labels)+3),
+	}
+	for _, tag := range e.Tags {
+		r.Labels[tag.Key] = tag.Value
+	}
+	for k, v := range map[string]string{
+		"issue_id": e.GroupID,
+		"platform": e.Platform,
+		"culprit":  e.Culprit,
+	} {
+		if v != "" {
+			r.Labels[k] = v
+		}
+	}
+	for k, v := range labels {
+		r.Labels[k] = v
+	}
+	return r
+}
@@ -0,0 +1,155 @@
+package sentry
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+const issuesResponse = `[{
+	"id": "4012", "shortId": "CHECKOUT-1F", "title": "ZeroDivisionError: division by zero", "culprit": "app.views in total",
+	"permalink": "https://sentry.io/organizations/acme/issues/4012/", "level": "error", "status": "unresolved",
+	"platform": "python", "count": "42", "userCount": 7,
+	"firstSeen": "2023-04-30T08:00:00Z", "lastSeen": "2023-05-01T12:00:00Z", "project": {"slug": "checkout"},
+	"metadata": {"type": "ZeroDivisionError", "value": "division by zero", "filename": "app/views.py", "function": "total"}
+}]`
+
+func TestSentrySearch(t *testing.T) {
+	var queries []string
+	projectLookups := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/api/0/projects/acme/checkout/":
+			projectLookups++
+			w.Write([]byte(`{"id": "12", "slug": "checkout"}`))
+		case "/api/0/organizations/acme/issues/":
+			query := r.URL.Query()
+			queries = append(queries, query.Get("project")+"|"+query.Get("query")+"|"+query.Get("cursor")+"|"+query.Get("start"))
+			w.Header().Set("Link", `<https://sentry.io/api/0/organizations/acme/issues/?&cursor=0:0:1>; rel="previous"; results="false"; cursor="0:0:1", `+
+				`<https://sentry.io/api/0/organizations/acme/issues/?&cursor=0:100:0>; rel="next"; results="true"; cursor="0:100:0"`)
+			w.Write([]byte(issuesResponse))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewSentrySearchBackend(nil, &logs.SentryBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "sentry"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Token: &kommons.EnvVar{Value: "secret"}},
+		Organization:   "acme",
+		Project:        "checkout",
+		Query:          "is:unresolved",
+		Tags:           []string{"environment"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Labels: map[string]string{"environment": "prod", "namespace": "shop"},
+		Start:  "2023-05-01T00:00:00Z",
+		End:    "2023-05-02T00:00:00Z",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 1, NextPage: "0:100:0", Results: []logs.Result{{
+		Id:      "4012",
+		Time:    "2023-05-01T12:00:00Z",
+		Message: "ZeroDivisionError: division by zero at total (app/views.py)",
+		Labels: map[string]string{
+			"short_id": "CHECKOUT-1F", "level": "error", "status": "unresolved", "project": "checkout", "count": "42", "users": "7",
+			"first_seen": "2023-04-30T08:00:00Z", "platform": "python", "culprit": "app.views in total", "type": "ZeroDivisionError",
+			"permalink": "https://sentry.io/organizations/acme/issues/4012/", "source": "sentry",
+		},
+	}}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Query: "level:fatal", Page: res.NextPage}); err != nil {
+		t.Fatal(err)
+	}
+	wantQueries := []string{`12|is:unresolved environment:"prod"||2023-05-01T00:00:00Z`, "12|level:fatal|0:100:0|"}
+	if !reflect.DeepEqual(queries, wantQueries) {
+		t.Errorf("queries = %v, want %v", queries, wantQueries)
+	}
+	if projectLookups != 1 {
+		t.Errorf("the project was looked up %d times, want once", projectLookups)
+	}
+}
+
+const eventsResponse = `[{
+	"eventID": "9fac2ceed9344f2bbfdd1fdacb0ed9b1",
+	"groupID": "4012",
+	"title": "ZeroDivisionError: division by zero",
+	"message": "",
+	"culprit": "app.views in total",
+	"platform": "python",
+	"dateCreated": "2023-05-01T12:00:00Z",
+	"tags": [{"key": "environment", "value": "prod"}, {"key": "release", "value": "1.4.2"}, {"key": "level", "value": "error"}]
+}]`
+
+func TestSentrySearch_Events(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/0/projects/acme/checkout/events/", "/api/0/organizations/acme/issues/4012/events/":
+			paths = append(paths, r.URL.Path+"?"+r.URL.Query().Get("query")+"|"+r.URL.Query().Get("full"))
+			w.Header().Set("Link", `<https://sentry.io/api/0/projects/acme/checkout/events/?&cursor=0:100:0>; rel="next"; results="true"; cursor="0:100:0"`)
+			w.Write([]byte(eventsResponse))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewSentrySearchBackend(nil, &logs.SentryBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "sentry"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		Organization:   "acme",
+		Project:        "checkout",
+		Events:         true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := logs.SearchResults{Total: 1, NextPage: "0:100:0", Results: []logs.Result{{
+		Id:      "9fac2ceed9344f2bbfdd1fdacb0ed9b1",
+		Time:    "2023-05-01T12:00:00Z",
+		Message: "ZeroDivisionError: division by zero",
+		Labels: map[string]string{
+			"environment": "prod", "release": "1.4.2", "level": "error", "issue_id": "4012", "platform": "python",
+			"culprit": "app.views in total", "source": "sentry",
+		},
+	}}}
+	for _, q := range []*logs.SearchParams{{Query: "release:1.4.2"}, {Labels: map[string]string{"issue_id": "4012"}}} {
+		res, err := backend.Search(context.Background(), q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(res, want) {
+			t.Errorf("Search() = %+v, want %+v", res, want)
+		}
+	}
+
+	wantPaths := []string{"/api/0/projects/acme/checkout/events/?release:1.4.2|true", "/api/0/organizations/acme/issues/4012/events/?|true"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("paths = %v, want %v", paths, wantPaths)
+	}
+
+	if _, err := NewSentrySearchBackend(nil, &logs.SentryBackendConfig{Organization: "acme", Events: true}); err == nil {
+		t.Error("NewSentrySearchBackend() of the events without project succeeded")
+	}
+}
@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/health"
+	"github.com/flanksource/apm-hub/pkg/ratelimit"
+	"github.com/flanksource/commons/logger"
+)
+
+// shadowTimeout bounds the searches of the shadow backends, which outlive the requests
+var shadowTimeout = time.Minute
+
+// shadowComparison is the latency and the number of results of a shadow backend,
+// next to the ones of the other backends matching the same search
+type shadowComparison struct {
+	Backend  string
+	Results  int
+	Duration time.Duration
+	Err      error
+
+	PrimaryResults  int
+	PrimaryDuration time.Duration
+}
+
+func (t shadowComparison) String() string {
+	if t.Err != nil {
+		return fmt.Sprintf("shadow backend %s failed after %s: %v, the other backends returned %d results in %s",
+			t.Backend, t.Duration, t.Err, t.PrimaryResults, t.PrimaryDuration)
+	}
+	return fmt.Sprintf("shadow backend %s returned %d results in %s, the other backends %d results in %s",
+		t.Backend, t.Results, t.Duration, t.PrimaryResults, t.PrimaryDuration)
+}
+
+// searchShadow searches the shadow backend and logs how it compares to the other backends, whose results are in primary
+func searchShadow(i int, backend logs.SearchBackend, q logs.SearchParams, primary shadowComparison) shadowComparison {
+	ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+	defer cancel()
+
+	comparison := shadowComparison{
+		Backend:         backendName(i, backend),
+		PrimaryResults:  primary.Results,
+		PrimaryDuration: primary.Duration,
+	}
+
+	start := time.Now()
+	result, err := searchBackend(ctx, backend, q)
+	comparison.Duration = time.Since(start)
+	comparison.Results = len(result.Results)
+	comparison.Err = err
+	if !errors.Is(err, ratelimit.ErrBackendBusy) {
+		health.GlobalChecker.Record(comparison.Backend, err)
+	}
+
+	logger.Infof("[%s] %s", q, comparison)
+	return comparison
+}
@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+type fakeSearch struct {
+	results []logs.Result
+	err     error
+	// searched receives the search params of every search
+	searched chan logs.SearchParams
+}
+
+func (t *fakeSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	if t.searched != nil {
+		t.searched <- *q
+	}
+	return logs.SearchResults{Total: len(t.results), Results: t.results}, t.err
+}
+
+func (t *fakeSearch) MatchRoute(q *logs.SearchParams) (bool, bool) { return true, false }
+
+func (t *fakeSearch) Capabilities() logs.Capabilities { return logs.Capabilities{Query: true} }
+
+func TestSearchBackends_Shadow(t *testing.T) {
+	shadow := &fakeSearch{results: []logs.Result{{Message: "new cluster"}}, searched: make(chan logs.SearchParams, 1)}
+	defer func(backends []logs.SearchBackend) { logs.GlobalBackends = backends }(logs.GlobalBackends)
+	logs.GlobalBackends = []logs.SearchBackend{
+		{Name: "elastic", API: &fakeSearch{results: []logs.Result{{Message: "old cluster"}}}},
+		{Name: "elastic-next", API: shadow, Shadow: true},
+	}
+
+	results, _ := searchBackends(context.Background(), "", &logs.SearchParams{Query: "timeout"}, nil)
+	if len(results) != 1 || results[0].Backend != "elastic" {
+		t.Fatalf("searchBackends() = %+v, want the results of elastic only", results)
+	}
+	if q := <-shadow.searched; q.Query != "timeout" {
+		t.Errorf("the shadow backend was searched with %+v", q)
+	}
+}
+
+func TestSearchShadow(t *testing.T) {
+	primary := shadowComparison{Results: 2}
+	q := logs.SearchParams{Query: "timeout"}
+
+	got := searchShadow(1, logs.SearchBackend{API: &fakeSearch{results: []logs.Result{{Message: "a"}}}}, q, primary)
+	if got.Backend != "backend[1]" || got.Results != 1 || got.PrimaryResults != 2 || got.Err != nil {
+		t.Errorf("searchShadow() = %+v", got)
+	}
+
+	failure := errors.New("connection refused")
+	got = searchShadow(1, logs.SearchBackend{Name: "next", API: &fakeSearch{err: failure}}, q, primary)
+	if got.Backend != "next" || !errors.Is(got.Err, failure) {
+		t.Errorf("searchShadow() = %+v, want the error", got)
+	}
+}
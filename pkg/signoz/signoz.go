@@ -0,0 +1,198 @@
+// Package signoz searches the logs of SigNoz with the query range api of its query service
+package signoz
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+// defaultResources are the resource attributes matched against the labels of the searches by default
+var defaultResources = []string{"service.name", "k8s.cluster.name", "k8s.namespace.name", "k8s.pod.name", "k8s.container.name", "host.name"}
+
+type SigNozSearch struct {
+	config    *logs.SigNozBackendConfig
+	client    *rest.Client
+	resources []string
+}
+
+func NewSigNozSearchBackend(kClient *kommons.Client, config *logs.SigNozBackendConfig) (*SigNozSearch, error) {
+	client, err := rest.NewClient(kClient, config.HTTPConnection)
+	if err != nil {
+		return nil, err
+	}
+
+	resources := config.Resources
+	if len(resources) == 0 {
+		resources = defaultResources
+	}
+	return &SigNozSearch{config: config, client: client, resources: resources}, nil
+}
+
+type filterKey struct {
+	Key      string `json:"key"`
+	DataType string `json:"dataType"`
+	Type     string `json:"type"`
+	IsColumn bool   `json:"isColumn"`
+}
+
+type filterItem struct {
+	Key   filterKey `json:"key"`
+	Op    string    `json:"op"`
+	Value string    `json:"value"`
+}
+
+// filters matches the labels of the search against the resource attributes and the attributes of the logs,
+// and the body of the logs against the query of the search
+func (t *SigNozSearch) filters(q *logs.SearchParams) []filterItem {
+	items := []filterItem{}
+	for _, resource := range t.resources {
+		if v, ok := q.Labels[resource]; ok {
+			items = append(items, filterItem{Key: filterKey{Key: resource, DataType: "string", Type: "resource"}, Op: "=", Value: v})
+		}
+	}
+	for _, attribute := range t.config.Attributes {
+		if v, ok := q.Labels[attribute]; ok {
+			items = append(items, filterItem{Key: filterKey{Key: attribute, DataType: "string", Type: "tag"}, Op: "=", Value: v})
+		}
+	}
+	if q.Query != "" {
+		items = append(items, filterItem{Key: filterKey{Key: "body", DataType: "string", IsColumn: true}, Op: "contains", Value: q.Query})
+	}
+	return items
+}
+
+// Search lists the most recent logs of the time range of the search, the last hour by default.
+// The page of the search is the offset of the next logs.
+func (t *SigNozSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+	start := end.Add(-time.Hour)
+	if s := q.GetStart(); s != nil {
+		start = *s
+	}
+	var offset int64
+	if q.Page != "" {
+		var err error
+		if offset, err = strconv.ParseInt(q.Page, 10, 64); err != nil {
+			return logs.SearchResults{}, fmt.Errorf("error parsing the page: %w", err)
+		}
+	}
+
+	builderQuery := map[string]any{
+		"queryName":         "A",
+		"expression":        "A",
+		"dataSource":        "logs",
+		"aggregateOperator": "noop",
+		"disabled":          false,
+		"offset":            offset,
+		"orderBy":           []map[string]string{{"columnName": "timestamp", "order": "desc"}},
+		"filters":           map[string]any{"op": "AND", "items": t.filters(q)},
+	}
+	if q.Limit > 0 {
+		builderQuery["limit"] = q.Limit
+	}
+	body := map[string]any{
+		"start": start.UnixMilli(),
+		"end":   end.UnixMilli(),
+		"step":  60,
+		"compositeQuery": map[string]any{
+			"queryType":      "builder",
+			"panelType":      "list",
+			"builderQueries": map[string]any{"A": builderQuery},
+		},
+	}
+
+	var res struct {
+		Data struct {
+			Result []struct {
+				List []struct {
+					Timestamp string  `json:"timestamp"`
+					Data      logData `json:"data"`
+				} `json:"list"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := t.client.Post(ctx, "/api/v3/query_range", nil, body, &res); err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	var results logs.SearchResults
+	for _, result := range res.Data.Result {
+		for _, row := range result.List {
+			results.Results = append(results.Results, t.result(row.Timestamp, row.Data))
+		}
+	}
+	results.Total = len(results.Results)
+	if q.Limit > 0 && int64(len(results.Results)) == q.Limit {
+		results.NextPage = strconv.FormatInt(offset+q.Limit, 10)
+	}
+	return results, nil
+}
+
+type logData struct {
+	ID                string             `json:"id"`
+	Body              string             `json:"body"`
+	SeverityText      string             `json:"severity_text"`
+	TraceID           string             `json:"trace_id"`
+	SpanID            string             `json:"span_id"`
+	AttributesString  map[string]string  `json:"attributes_string"`
+	AttributesInt64   map[string]int64   `json:"attributes_int64"`
+	AttributesFloat64 map[string]float64 `json:"attributes_float64"`
+	AttributesBool    map[string]bool    `json:"attributes_bool"`
+	ResourcesString   map[string]string  `json:"resources_string"`
+}
+
+// result renders the log, with its body as message and its resource attributes and attributes as labels
+func (t *SigNozSearch) result(timestamp string, data logData) logs.Result {
+	r := logs.Result{Id: data.ID, Message: data.Body, Labels: make(map[string]string, len(data.ResourcesString)+len(data.AttributesString)+len(t.config.Labels)+3)}
+	if ts, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+		r.Time = ts.UTC().Format(time.RFC3339Nano)
+	}
+
+	for k, v := range data.AttributesString {
+		r.Labels[k] = v
+	}
+	for k, v := range data.AttributesInt64 {
+		r.Labels[k] = strconv.FormatInt(v, 10)
+	}
+	for k, v := range data.AttributesFloat64 {
+		r.Labels[k] = strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	for k, v := range data.AttributesBool {
+		r.Labels[k] = strconv.FormatBool(v)
+	}
+	for k, v := range data.ResourcesString {
+		r.Labels[k] = v
+	}
+	for label, value := range map[string]string{"severity": data.SeverityText, "trace_id": data.TraceID, "span_id": data.SpanID} {
+		if value != "" {
+			r.Labels[label] = value
+		}
+	}
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *SigNozSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the SigNoz backend, the logs are filtered by the text of the query in their body
+func (t *SigNozSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck gets the health of the query service
+func (t *SigNozSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/v1/health", nil, nil)
+}
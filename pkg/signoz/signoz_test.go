@@ -0,0 +1,75 @@
+package signoz
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestSigNozSearch(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("SIGNOZ-API-KEY") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/query_range" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"","result":[{"queryName":"A","list":[
+			{"timestamp":"2023-05-01T12:00:01.5Z","data":{"id":"l2","body":"connection timeout","severity_text":"ERROR","trace_id":"abc",
+				"attributes_string":{"http.method":"GET"},"attributes_int64":{"http.status_code":504},"attributes_float64":{},
+				"resources_string":{"k8s.namespace.name":"shop","k8s.pod.name":"api-0"}}},
+			{"timestamp":"2023-05-01T12:00:00Z","data":{"id":"l1","body":"GET /","resources_string":{"k8s.namespace.name":"shop","k8s.pod.name":"api-1"}}}
+		]}]}}`))
+	}))
+	defer server.Close()
+
+	backend, err := NewSigNozSearchBackend(nil, &logs.SigNozBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"source": "signoz"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL, Headers: map[string]kommons.EnvVar{"SIGNOZ-API-KEY": {Value: "secret"}}},
+		Attributes:     []string{"http.status_code"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query:  "timeout",
+		Labels: map[string]string{"k8s.namespace.name": "shop", "http.status_code": "504", "team": "a"},
+		Start:  "2023-05-01T11:00:00Z", End: "2023-05-01T12:00:00Z", Limit: 2, Page: "4",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, NextPage: "6", Results: []logs.Result{
+		{Id: "l2", Time: "2023-05-01T12:00:01.5Z", Message: "connection timeout", Labels: map[string]string{
+			"http.method": "GET", "http.status_code": "504", "k8s.namespace.name": "shop", "k8s.pod.name": "api-0", "severity": "ERROR", "trace_id": "abc", "source": "signoz",
+		}},
+		{Id: "l1", Time: "2023-05-01T12:00:00Z", Message: "GET /", Labels: map[string]string{"k8s.namespace.name": "shop", "k8s.pod.name": "api-1", "source": "signoz"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	query := body["compositeQuery"].(map[string]any)["builderQueries"].(map[string]any)["A"].(map[string]any)
+	wantFilters := map[string]any{"op": "AND", "items": []any{
+		map[string]any{"key": map[string]any{"key": "k8s.namespace.name", "dataType": "string", "type": "resource", "isColumn": false}, "op": "=", "value": "shop"},
+		map[string]any{"key": map[string]any{"key": "http.status_code", "dataType": "string", "type": "tag", "isColumn": false}, "op": "=", "value": "504"},
+		map[string]any{"key": map[string]any{"key": "body", "dataType": "string", "type": "", "isColumn": true}, "op": "contains", "value": "timeout"},
+	}}
+	if !reflect.DeepEqual(query["filters"], wantFilters) {
+		t.Errorf("filters = %v, want %v", query["filters"], wantFilters)
+	}
+	if body["start"] != float64(1682938800000) || body["end"] != float64(1682942400000) || query["offset"] != float64(4) || query["limit"] != float64(2) {
+		t.Errorf("query = %v", body)
+	}
+}
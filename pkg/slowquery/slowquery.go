@@ -0,0 +1,146 @@
+package slowquery
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/audit"
+	"github.com/flanksource/apm-hub/pkg/auth"
+	"github.com/flanksource/commons/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// Threshold is the duration after which a search is considered slow.
+	// A zero threshold disables the slow query log.
+	Threshold time.Duration
+
+	// LogFile is an optional path to which the slow queries are appended as json lines.
+	LogFile string
+
+	// Size is the number of slow queries retained in memory.
+	Size = 100
+)
+
+func Flags(flags *pflag.FlagSet) {
+	flags.DurationVar(&Threshold, "slow-query-threshold", 10*time.Second, "Searches taking longer than this are recorded in the slow query log. 0 disables it")
+	flags.StringVar(&LogFile, "slow-query-log", "", "Path of the file to append slow queries to")
+	flags.IntVar(&Size, "slow-query-size", 100, "Number of slow queries to keep in memory")
+}
+
+// BackendQuery is the query sent to a single backend as part of a search.
+type BackendQuery struct {
+	Backend  string `json:"backend"`
+	Query    string `json:"query,omitempty"`
+	Duration string `json:"duration"`
+	Results  int    `json:"results"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Entry is a single slow search.
+type Entry struct {
+	Time     time.Time         `json:"time"`
+	Duration string            `json:"duration"`
+	Tenant   string            `json:"tenant,omitempty"`
+	Params   logs.SearchParams `json:"params"`
+	Total    int               `json:"total"`
+	Backends []BackendQuery    `json:"backends,omitempty"`
+}
+
+var (
+	lock    sync.Mutex
+	entries []Entry
+)
+
+// Record records the search of the tenant in the slow query log
+// if it took longer than the configured threshold.
+// The search params and the queries are redacted like in the audit log.
+func Record(tenant string, params logs.SearchParams, duration time.Duration, total int, backends []BackendQuery) {
+	if Threshold <= 0 || duration < Threshold {
+		return
+	}
+
+	redactedBackends := make([]BackendQuery, len(backends))
+	for i, b := range backends {
+		b.Query = audit.GlobalAuditor.RedactText(params, b.Query)
+		redactedBackends[i] = b
+	}
+	params = audit.GlobalAuditor.RedactParams(params)
+
+	entry := Entry{
+		Time:     time.Now(),
+		Duration: duration.String(),
+		Tenant:   tenant,
+		Params:   params,
+		Total:    total,
+		Backends: redactedBackends,
+	}
+
+	logger.Warnf("[slow-query] [%s] => %d results in %s", params, total, duration)
+	for _, b := range redactedBackends {
+		logger.Debugf("[slow-query] %s took %s: %s", b.Backend, b.Duration, b.Query)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	entries = append(entries, entry)
+	if Size > 0 && len(entries) > Size {
+		entries = entries[len(entries)-Size:]
+	}
+
+	if LogFile != "" {
+		if err := appendToFile(LogFile, entry); err != nil {
+			logger.Errorf("error writing to slow query log: %v", err)
+		}
+	}
+}
+
+// Entries returns the slow queries of the tenant retained in memory, most recent first.
+// An empty tenant returns the slow queries of every tenant.
+func Entries(tenant string) []Entry {
+	lock.Lock()
+	defer lock.Unlock()
+
+	out := make([]Entry, 0, len(entries))
+	for i := len(entries) - 1; i >= 0; i-- {
+		if tenant != "" && entries[i].Tenant != tenant {
+			continue
+		}
+		out = append(out, entries[i])
+	}
+	return out
+}
+
+// Handler returns the slow queries of the tenant of the request retained in memory.
+// They're only returned to the admins, as they contain the searches of the other users.
+func Handler(c echo.Context) error {
+	cc := c.(*api.Context)
+	if !auth.GlobalAuthorizer.IsAdmin(cc.User) {
+		return echo.NewHTTPError(http.StatusForbidden, "the slow query log is restricted to the admins")
+	}
+	return cc.JSON(http.StatusOK, Entries(cc.Tenant))
+}
+
+func appendToFile(path string, entry Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error marshalling entry: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,138 @@
+package slowquery
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/flanksource/apm-hub/api"
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/audit"
+	"github.com/flanksource/apm-hub/pkg/auth"
+)
+
+// reset restores the slow query log once the test is done
+func reset(t *testing.T) {
+	threshold, logFile, size := Threshold, LogFile, Size
+	t.Cleanup(func() {
+		Threshold, LogFile, Size = threshold, logFile, size
+		entries = nil
+	})
+	entries = nil
+}
+
+func TestRecord(t *testing.T) {
+	reset(t)
+	Threshold = time.Second
+	Size = 2
+	LogFile = filepath.Join(t.TempDir(), "slow.log")
+
+	Record("", logs.SearchParams{Query: "fast"}, time.Millisecond, 1, nil)
+	if got := Entries(""); len(got) != 0 {
+		t.Fatalf("Entries() = %+v, want the searches under the threshold ignored", got)
+	}
+
+	for _, q := range []string{"a", "b", "c"} {
+		Record("", logs.SearchParams{Query: q}, 2*time.Second, 1, []BackendQuery{{Backend: "elastic"}})
+	}
+
+	got := Entries("")
+	if len(got) != 2 || got[0].Params.Query != "c" || got[1].Params.Query != "b" {
+		t.Errorf("Entries() = %+v, want the 2 most recent searches", got)
+	}
+	if got[0].Backends[0].Backend != "elastic" {
+		t.Errorf("the backend = %q, want its name", got[0].Backends[0].Backend)
+	}
+
+	f, err := os.Open(LogFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); lines++ {
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("invalid entry %s: %v", scanner.Text(), err)
+		}
+	}
+	if lines != 3 {
+		t.Errorf("the log file has %d entries, want 3", lines)
+	}
+}
+
+func TestRecord_Redacted(t *testing.T) {
+	reset(t)
+	Threshold = time.Second
+
+	auditor, err := audit.NewAuditor(nil, logs.AuditConfig{
+		File:   filepath.Join(t.TempDir(), "audit.log"),
+		Redact: logs.AuditRedaction{Query: true, Labels: []string{"user"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { audit.GlobalAuditor = nil }()
+	audit.GlobalAuditor = auditor
+
+	params := logs.SearchParams{Query: "password=hunter2", Labels: map[string]string{"user": "alice", "namespace": "default"}}
+	Record("", params, 2*time.Second, 1, []BackendQuery{
+		{Backend: "loki", Query: `{user="alice",namespace="default"} |= "password=hunter2"`},
+	})
+
+	got := Entries("")[0]
+	if got.Params.Query != "[REDACTED]" || got.Params.Labels["user"] != "[REDACTED]" || got.Params.Labels["namespace"] != "default" {
+		t.Errorf("the params = %+v, want the query and the user redacted", got.Params)
+	}
+	if want := `{user="[REDACTED]",namespace="default"} |= "[REDACTED]"`; got.Backends[0].Query != want {
+		t.Errorf("the query = %s, want %s", got.Backends[0].Query, want)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	reset(t)
+	Threshold = time.Second
+	Record("acme", logs.SearchParams{Query: "acme"}, 2*time.Second, 1, nil)
+	Record("globex", logs.SearchParams{Query: "globex"}, 2*time.Second, 1, nil)
+
+	defer func() { auth.GlobalAuthorizer = nil }()
+	auth.GlobalAuthorizer = auth.NewAuthorizer(logs.RBACConfig{
+		Rules: []logs.RBACRule{
+			{Groups: []string{"admins"}},
+			{Groups: []string{"team-a"}, Labels: map[string]string{"namespace": "team-a"}},
+		},
+	})
+
+	serve := func(user *api.User, tenant string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		c := &api.Context{Context: echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/slow-queries", nil), rec), User: user, Tenant: tenant}
+		if err := Handler(c); err != nil {
+			httpErr, ok := err.(*echo.HTTPError)
+			if !ok {
+				t.Fatal(err)
+			}
+			rec.Code = httpErr.Code
+		}
+		return rec
+	}
+
+	if rec := serve(&api.User{Name: "alice", Groups: []string{"team-a"}}, "acme"); rec.Code != http.StatusForbidden {
+		t.Errorf("Handler() = %d for a non admin, want %d", rec.Code, http.StatusForbidden)
+	}
+
+	rec := serve(&api.User{Name: "bob", Groups: []string{"admins"}}, "acme")
+	var got []Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Tenant != "acme" {
+		t.Errorf("Handler() = %+v, want the slow queries of acme only", got)
+	}
+}
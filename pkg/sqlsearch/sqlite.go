@@ -0,0 +1,31 @@
+package sqlsearch
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+
+	// The sqlite driver doesn't need cgo
+	_ "modernc.org/sqlite"
+)
+
+// NewSQLiteSearchBackend searches the database file with the sqlite driver, read only.
+// The times are bound in the format of the sqlite date and time functions, e.g. datetime(:start).
+func NewSQLiteSearchBackend(config *logs.SQLiteBackendConfig) (*SQLSearch, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("the path is required")
+	}
+
+	dsn := url.URL{Scheme: "file", Opaque: config.Path, RawQuery: "mode=ro&_time_format=sqlite"}
+	return NewSQLSearchBackend(nil, &logs.SQLBackendConfig{
+		CommonBackend:   config.CommonBackend,
+		Driver:          "sqlite",
+		Connection:      kommons.EnvVar{Value: dsn.String()},
+		Query:           config.Query,
+		TimestampColumn: config.TimestampColumn,
+		MessageColumn:   config.MessageColumn,
+		IDColumn:        config.IDColumn,
+	})
+}
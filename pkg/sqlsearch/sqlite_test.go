@@ -0,0 +1,60 @@
+package sqlsearch
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestSQLiteSearch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agent.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE logs (id INTEGER PRIMARY KEY, ts TEXT, unit TEXT, message TEXT)`,
+		`INSERT INTO logs (ts, unit, message) VALUES
+			('2023-05-01T10:59:59Z', 'sshd', 'session opened'),
+			('2023-05-01T11:30:00Z', 'nginx', 'GET /'),
+			('2023-05-01T11:30:01.5Z', 'nginx', 'connection timeout'),
+			('2023-05-01T11:30:02Z', 'sshd', 'connection closed')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	backend, err := NewSQLiteSearchBackend(&logs.SQLiteBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"source": "sqlite"}},
+		Path:          path,
+		Query: `SELECT id, ts AS timestamp, unit, message FROM logs
+			WHERE datetime(ts) >= datetime(:start) AND unit = :labels.unit AND message LIKE '%' || :query || '%'
+			ORDER BY ts LIMIT :limit`,
+		IDColumn: "id",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "connection", Labels: map[string]string{"unit": "nginx"}, Start: "2023-05-01T11:00:00Z", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 1, Results: []logs.Result{
+		{Id: "3", Time: "2023-05-01T11:30:01.5Z", Message: "connection timeout", Labels: map[string]string{"unit": "nginx", "source": "sqlite"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	// The database is opened read only
+	if _, err := backend.db.Exec(`DELETE FROM logs`); err == nil {
+		t.Errorf("the database isn't read only")
+	}
+}
@@ -0,0 +1,250 @@
+// Package sqlsearch searches the logs of any database with a database/sql driver, with a query template of named placeholders
+package sqlsearch
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+
+	// The drivers of MySQL and SQL Server, and pgx for Postgres and the databases speaking its protocol, e.g. CockroachDB
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/microsoft/go-mssqldb"
+)
+
+const (
+	defaultTimestampColumn = "timestamp"
+	defaultMessageColumn   = "message"
+)
+
+// namedPlaceholder matches the named placeholders, and what isn't a placeholder:
+// the casts, e.g. ::text, the quoted literals and identifiers, and the comments
+var namedPlaceholder = regexp.MustCompile(`'(?:[^']|'')*'|"(?:[^"]|"")*"|` + "`[^`]*`" + `|--[^\n]*|/\*(?s:.*?)\*/|::|:(labels\.[\w.\-/]*\w|[A-Za-z_]\w*)`)
+
+type SQLSearch struct {
+	config *logs.SQLBackendConfig
+	db     *sql.DB
+	// query is the query with the placeholders of the driver, binding the params in order
+	query  string
+	params []string
+}
+
+func NewSQLSearchBackend(kClient *kommons.Client, config *logs.SQLBackendConfig) (*SQLSearch, error) {
+	if config.Driver == "" {
+		return nil, fmt.Errorf("the driver is required")
+	}
+	t, err := newSQLSearch(config)
+	if err != nil {
+		return nil, err
+	}
+
+	_, connection, err := kClient.GetEnvValue(config.Connection, config.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting the connection: %w", err)
+	}
+	// The connections are opened with the first search
+	if t.db, err = sql.Open(config.Driver, connection); err != nil {
+		return nil, fmt.Errorf("error opening the database: %w", err)
+	}
+	return t, nil
+}
+
+func newSQLSearch(config *logs.SQLBackendConfig) (*SQLSearch, error) {
+	if config.Query == "" {
+		return nil, fmt.Errorf("the query is required")
+	}
+
+	t := &SQLSearch{config: config}
+	var err error
+	t.query = namedPlaceholder.ReplaceAllStringFunc(config.Query, func(match string) string {
+		if !strings.HasPrefix(match, ":") || match == "::" {
+			return match
+		}
+		name := match[1:]
+		switch {
+		case name == "start", name == "end", name == "limit", name == "offset", name == "query", strings.HasPrefix(name, "labels."):
+		default:
+			err = fmt.Errorf("unknown placeholder %s", match)
+			return match
+		}
+		t.params = append(t.params, name)
+		return placeholder(config.Driver, len(t.params))
+	})
+	return t, err
+}
+
+// placeholder returns the nth positional placeholder of the driver
+func placeholder(driver string, n int) string {
+	switch driver {
+	case "pgx", "postgres", "cloudsqlpostgres":
+		return "$" + strconv.Itoa(n)
+	case "sqlserver", "mssql", "azuresql":
+		return "@p" + strconv.Itoa(n)
+	case "godror", "oracle":
+		return ":" + strconv.Itoa(n)
+	default:
+		return "?"
+	}
+}
+
+// args binds the params of the query to the search params
+func (t *SQLSearch) args(q *logs.SearchParams) ([]any, error) {
+	var offset int64
+	if q.Page != "" {
+		var err error
+		if offset, err = strconv.ParseInt(q.Page, 10, 64); err != nil {
+			return nil, fmt.Errorf("error parsing the page: %w", err)
+		}
+	}
+
+	args := make([]any, 0, len(t.params))
+	for _, param := range t.params {
+		var arg any
+		switch param {
+		case "start":
+			if start := q.GetStart(); start != nil {
+				arg = start.UTC()
+			}
+		case "end":
+			if end := q.GetEnd(); end != nil {
+				arg = end.UTC()
+			}
+		case "limit":
+			arg = q.Limit
+		case "offset":
+			arg = offset
+		case "query":
+			arg = q.Query
+		default:
+			if value, ok := q.Labels[strings.TrimPrefix(param, "labels.")]; ok {
+				arg = value
+			}
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+func (t *SQLSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	args, err := t.args(q)
+	if err != nil {
+		return logs.SearchResults{}, err
+	}
+
+	rows, err := t.db.QueryContext(ctx, t.query, args...)
+	if err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error querying the logs: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error getting the columns: %w", err)
+	}
+
+	var results logs.SearchResults
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return logs.SearchResults{}, fmt.Errorf("error scanning the logs: %w", err)
+		}
+		results.Results = append(results.Results, t.result(columns, values))
+	}
+	if err := rows.Err(); err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error querying the logs: %w", err)
+	}
+
+	results.Total = len(results.Results)
+	if q.Limit > 0 && int64(len(results.Results)) == q.Limit && t.paginates() {
+		offset, _ := strconv.ParseInt(q.Page, 10, 64)
+		results.NextPage = strconv.FormatInt(offset+q.Limit, 10)
+	}
+	return results, nil
+}
+
+// result renders a row, with the timestamp, message and id columns as the time, message and id of the result
+// and the other columns as labels
+func (t *SQLSearch) result(columns []string, row []any) logs.Result {
+	timestampColumn := t.config.TimestampColumn
+	if timestampColumn == "" {
+		timestampColumn = defaultTimestampColumn
+	}
+	messageColumn := t.config.MessageColumn
+	if messageColumn == "" {
+		messageColumn = defaultMessageColumn
+	}
+
+	r := logs.Result{Labels: make(map[string]string, len(columns)+len(t.config.Labels))}
+	for i, column := range columns {
+		var value string
+		switch v := row[i].(type) {
+		case nil:
+			continue
+		case time.Time:
+			value = v.UTC().Format(time.RFC3339Nano)
+		case []byte:
+			value = string(v)
+		default:
+			value = fmt.Sprint(v)
+		}
+
+		switch column {
+		case timestampColumn:
+			if ts, err := time.Parse(time.RFC3339Nano, value); err == nil {
+				r.Time = ts.UTC().Format(time.RFC3339Nano)
+			} else {
+				r.Time = value
+			}
+		case messageColumn:
+			r.Message = value
+		case t.config.IDColumn:
+			r.Id = value
+		default:
+			r.Labels[column] = value
+		}
+	}
+	for k, v := range t.config.Labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+func (t *SQLSearch) paginates() bool {
+	for _, param := range t.params {
+		if param == "offset" {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *SQLSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the SQL backend, the query and pages of the search are applied when the query uses their placeholders
+func (t *SQLSearch) Capabilities() logs.Capabilities {
+	capabilities := logs.Capabilities{Pagination: t.paginates()}
+	for _, param := range t.params {
+		if param == "query" {
+			capabilities.Query = true
+		}
+	}
+	return capabilities
+}
+
+// HealthCheck pings the database
+func (t *SQLSearch) HealthCheck(ctx context.Context) error {
+	return t.db.PingContext(ctx)
+}
@@ -0,0 +1,147 @@
+package sqlsearch
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+// fakeDriver returns its rows to every query, and records the queries and their args
+type fakeDriver struct {
+	columns []string
+	rows    [][]driver.Value
+	queries []string
+	args    [][]driver.Value
+}
+
+func (d *fakeDriver) Open(string) (driver.Conn, error) { return &fakeConn{d}, nil }
+
+type fakeConn struct{ d *fakeDriver }
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return &fakeStmt{c.d, query}, nil }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type fakeStmt struct {
+	d     *fakeDriver
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+func (s *fakeStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.d.queries = append(s.d.queries, s.query)
+	s.d.args = append(s.d.args, args)
+	return &fakeRows{columns: s.d.columns, rows: s.d.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSQLSearch(t *testing.T) {
+	fake := &fakeDriver{
+		columns: []string{"id", "ts", "msg", "pod", "status", "trace"},
+		rows: [][]driver.Value{
+			{int64(2), time.Date(2023, 5, 1, 14, 0, 1, 0, time.FixedZone("CEST", 7200)), []byte("connection timeout"), "api-0", int64(504), nil},
+			{int64(1), time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC), "GET /", "api-1", int64(200), "abc"},
+		},
+	}
+	sql.Register("fake", fake)
+
+	backend, err := NewSQLSearchBackend(nil, &logs.SQLBackendConfig{
+		CommonBackend:   logs.CommonBackend{Labels: map[string]string{"source": "sql"}},
+		Driver:          "fake",
+		Connection:      kommons.EnvVar{Value: "fake://logs"},
+		Query:           "SELECT id, ts, msg, pod, status, trace FROM logs WHERE ts BETWEEN :start AND :end AND (:labels.pod IS NULL OR pod = :labels.pod) AND msg LIKE '%' || :query || '%' ORDER BY ts DESC LIMIT :limit OFFSET :offset",
+		TimestampColumn: "ts",
+		MessageColumn:   "msg",
+		IDColumn:        "id",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{
+		Query: "timeout", Labels: map[string]string{"namespace": "shop"}, Start: "2023-05-01T11:00:00Z", End: "2023-05-01T12:00:00Z", Limit: 2, Page: "2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, NextPage: "4", Results: []logs.Result{
+		{Id: "2", Time: "2023-05-01T12:00:01Z", Message: "connection timeout", Labels: map[string]string{"pod": "api-0", "status": "504", "source": "sql"}},
+		{Id: "1", Time: "2023-05-01T12:00:00Z", Message: "GET /", Labels: map[string]string{"pod": "api-1", "status": "200", "trace": "abc", "source": "sql"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantQuery := "SELECT id, ts, msg, pod, status, trace FROM logs WHERE ts BETWEEN ? AND ? AND (? IS NULL OR pod = ?) AND msg LIKE '%' || ? || '%' ORDER BY ts DESC LIMIT ? OFFSET ?"
+	wantArgs := []driver.Value{
+		time.Date(2023, 5, 1, 11, 0, 0, 0, time.UTC), time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC), nil, nil, "timeout", int64(2), int64(2),
+	}
+	if len(fake.queries) != 1 || fake.queries[0] != wantQuery || !reflect.DeepEqual(fake.args[0], wantArgs) {
+		t.Errorf("queries = %v %v, want %v %v", fake.queries, fake.args, wantQuery, wantArgs)
+	}
+}
+
+func TestNewSQLSearch(t *testing.T) {
+	tests := []struct {
+		driver  string
+		query   string
+		want    string
+		params  []string
+		wantErr bool
+	}{
+		{driver: "pgx", query: "SELECT * FROM logs WHERE labels->>'pod' = :labels.pod AND id::text > :offset", want: "SELECT * FROM logs WHERE labels->>'pod' = $1 AND id::text > $2", params: []string{"labels.pod", "offset"}},
+		{driver: "sqlserver", query: "SELECT TOP (:limit) * FROM logs WHERE app = :labels.app.kubernetes.io/name", want: "SELECT TOP (@p1) * FROM logs WHERE app = @p2", params: []string{"limit", "labels.app.kubernetes.io/name"}},
+		{driver: "mysql", query: "SELECT * FROM logs WHERE ts > :since", wantErr: true},
+		{
+			driver: "mysql",
+			query:  "SELECT * FROM logs -- :comment\nWHERE msg <> 'it''s 10:30' AND `col:name` = \":quoted\" /* :limit\n:offset */ AND ts > :start",
+			want:   "SELECT * FROM logs -- :comment\nWHERE msg <> 'it''s 10:30' AND `col:name` = \":quoted\" /* :limit\n:offset */ AND ts > ?",
+			params: []string{"start"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			got, err := newSQLSearch(&logs.SQLBackendConfig{Driver: tt.driver, Query: tt.query})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("newSQLSearch() didn't fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.query != tt.want || !reflect.DeepEqual(got.params, tt.params) {
+				t.Errorf("newSQLSearch() = %s %v, want %s %v", got.query, got.params, tt.want, tt.params)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+// Package sshsearch greps the log files of hosts over SSH
+package sshsearch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/logger"
+	"github.com/flanksource/kommons"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func NewSSHSearchBackend(kClient *kommons.Client, config *logs.SSHBackendConfig) (*SSHSearch, error) {
+	if len(config.Hosts) == 0 {
+		return nil, fmt.Errorf("at least one host is required")
+	}
+	if len(config.Paths) == 0 {
+		return nil, fmt.Errorf("at least one path is required")
+	}
+
+	timeout := 30 * time.Second
+	if config.Timeout != "" {
+		d, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the timeout: %w", err)
+		}
+		timeout = time.Duration(d)
+	}
+
+	clientConfig := &ssh.ClientConfig{User: config.Username, Timeout: timeout}
+	if config.PrivateKey != nil {
+		_, key, err := kClient.GetEnvValue(*config.PrivateKey, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the private key: %w", err)
+		}
+		var passphrase string
+		if config.Passphrase != nil {
+			if _, passphrase, err = kClient.GetEnvValue(*config.Passphrase, config.Namespace); err != nil {
+				return nil, fmt.Errorf("error getting the passphrase: %w", err)
+			}
+		}
+
+		var signer ssh.Signer
+		if passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase([]byte(key), []byte(passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey([]byte(key))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the private key: %w", err)
+		}
+		clientConfig.Auth = append(clientConfig.Auth, ssh.PublicKeys(signer))
+	}
+	if config.Password != nil {
+		_, password, err := kClient.GetEnvValue(*config.Password, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the password: %w", err)
+		}
+		clientConfig.Auth = append(clientConfig.Auth, ssh.Password(password))
+	}
+
+	switch {
+	case config.KnownHosts != nil:
+		_, knownHosts, err := kClient.GetEnvValue(*config.KnownHosts, config.Namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error getting the known hosts: %w", err)
+		}
+		if clientConfig.HostKeyCallback, err = hostKeyCallback(knownHosts); err != nil {
+			return nil, err
+		}
+	case config.InsecureIgnoreHostKey:
+		clientConfig.HostKeyCallback = ssh.InsecureIgnoreHostKey()
+	default:
+		return nil, fmt.Errorf("the known hosts are required, unless the host keys are ignored")
+	}
+
+	return &SSHSearch{config: config, clientConfig: clientConfig}, nil
+}
+
+// hostKeyCallback checks the keys of the hosts with the known hosts, read from a temporary file by knownhosts
+func hostKeyCallback(knownHosts string) (ssh.HostKeyCallback, error) {
+	file, err := os.CreateTemp("", "known_hosts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+
+	if _, err := file.WriteString(knownHosts); err != nil {
+		return nil, err
+	}
+	callback, err := knownhosts.New(file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the known hosts: %w", err)
+	}
+	return callback, nil
+}
+
+type SSHSearch struct {
+	config       *logs.SSHBackendConfig
+	clientConfig *ssh.ClientConfig
+}
+
+// Search greps the files of the host of the search, or of every host, returning the last matching lines of each host
+// up to the limit per item of the search. The lines with a leading timestamp outside of the time range are skipped.
+func (t *SSHSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var results logs.SearchResults
+	var errs []error
+	searched := 0
+
+	for _, host := range t.config.Hosts {
+		name, _, err := net.SplitHostPort(host)
+		if err != nil {
+			name = host
+		}
+		if q.Id != "" && q.Id != name && q.Id != host {
+			continue
+		}
+
+		searched++
+		lines, err := t.grep(ctx, address(host), command(q, t.config.Paths))
+		if err != nil {
+			logger.Errorf("error searching the files of %s: %v", host, err)
+			errs = append(errs, fmt.Errorf("error searching the files of %s: %w", host, err))
+			continue
+		}
+
+		start, end := q.GetStart(), q.GetEnd()
+		for _, line := range lines {
+			path, message, _ := strings.Cut(line, ":")
+			r := logs.Result{Message: message}.Process()
+			if r.Time != "" {
+				if ts, err := time.Parse(time.RFC3339Nano, r.Time); err == nil && ((start != nil && ts.Before(*start)) || (end != nil && ts.After(*end))) {
+					continue
+				}
+			}
+
+			r.Labels = map[string]string{"host": name, "path": path}
+			for k, v := range t.config.Labels {
+				r.Labels[k] = v
+			}
+			results.Results = append(results.Results, r)
+		}
+	}
+	// The results of the other hosts are returned when only some of them fail
+	if searched > 0 && len(errs) == searched {
+		return logs.SearchResults{}, errors.Join(errs...)
+	}
+	results.Total = len(results.Results)
+	return results, nil
+}
+
+func address(host string) string {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		return net.JoinHostPort(host, "22")
+	}
+	return host
+}
+
+// command returns the shell command grepping the paths for the query, each line being prefixed with the path of its file.
+// The paths that don't exist on the host are skipped silently, so that the errors are those of the host.
+func command(q *logs.SearchParams, paths []string) string {
+	cmd := "grep -F -H -i -s -e " + quote(q.Query) + " -- " + strings.Join(paths, " ")
+	limit := q.LimitPerItem
+	if limit == 0 {
+		limit = q.Limit
+	}
+	if limit > 0 {
+		cmd += " | tail -n " + strconv.FormatInt(limit, 10)
+	}
+	return cmd
+}
+
+// quote quotes the value for the shell, the single quotes being closed, escaped and reopened
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// grep runs the command on the host, returning the lines of its output.
+// The files that can't be read fail the search only when no line is found.
+func (t *SSHSearch) grep(ctx context.Context, address, cmd string) ([]string, error) {
+	client, err := ssh.Dial("tcp", address, t.clientConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to %s: %w", address, err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, t.clientConfig.Timeout)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		client.Close()
+	}()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, fmt.Errorf("error opening a session: %w", err)
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	err = session.Run(cmd)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("the search didn't complete before the deadline: %w", ctx.Err())
+	}
+	var exitErr *ssh.ExitError
+	if stdout.Len() == 0 && stderr.Len() > 0 {
+		return nil, fmt.Errorf("%s", strings.TrimSpace(stderr.String()))
+	}
+	// grep exits with 1 when no line matches
+	if err != nil && !(errors.As(err, &exitErr) && exitErr.ExitStatus() <= 2) {
+		return nil, err
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(&stdout)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+func (t *SSHSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+func (t *SSHSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true}
+}
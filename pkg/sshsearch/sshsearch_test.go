@@ -0,0 +1,163 @@
+package sshsearch
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// serve runs the exec requests of the sessions with sh, in the directory
+func serve(t *testing.T, dir string) (string, ssh.PublicKey) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+		if conn.User() != "logs" || string(password) != "secret" {
+			return nil, errors.New("invalid credentials")
+		}
+		return nil, nil
+	}}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(requests)
+				for newChannel := range channels {
+					channel, requests, err := newChannel.Accept()
+					if err != nil {
+						return
+					}
+					for req := range requests {
+						if req.Type != "exec" {
+							_ = req.Reply(false, nil)
+							continue
+						}
+						_ = req.Reply(true, nil)
+						cmd := exec.Command("sh", "-c", string(req.Payload[4:]))
+						cmd.Dir = dir
+						cmd.Stdout, cmd.Stderr = channel, channel.Stderr()
+						status := make([]byte, 4)
+						if err := cmd.Run(); err != nil {
+							var exitErr *exec.ExitError
+							if errors.As(err, &exitErr) {
+								binary.BigEndian.PutUint32(status, uint32(exitErr.ExitCode()))
+							}
+						}
+						_, _ = channel.SendRequest("exit-status", false, status)
+						channel.Close()
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), signer.PublicKey()
+}
+
+func TestSSHSearch(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app.log"), []byte(
+		"2023-05-01T11:00:00Z ERROR too old\n"+
+			"2023-05-01T12:00:00Z INFO started\n"+
+			"2023-05-01T12:00:01Z ERROR it's failing\n"+
+			"no timestamp error\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "other.txt"), []byte("2023-05-01T12:00:01Z ERROR not a log\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	address, hostKey := serve(t, dir)
+	backend, err := NewSSHSearchBackend(nil, &logs.SSHBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"env": "legacy"}},
+		Hosts:         []string{address, "10.0.0.1"},
+		Paths:         []string{"*.log", "missing.log"},
+		Username:      "logs",
+		Password:      &kommons.EnvVar{Value: "secret"},
+		KnownHosts:    &kommons.EnvVar{Value: knownhosts.Line([]string{address}, hostKey) + "\n"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	host, _, _ := net.SplitHostPort(address)
+	labels := map[string]string{"host": host, "path": "app.log", "env": "legacy"}
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Id: host, Query: "error", Start: "2023-05-01T12:00:00Z", LimitPerItem: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 2, Results: []logs.Result{
+		{Time: "2023-05-01T12:00:01Z", Message: "ERROR it's failing", Labels: labels},
+		{Message: "no timestamp error", Labels: labels},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Id: host, Query: "it's"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 1 {
+		t.Errorf("Search() of a quoted query = %+v, want a result", res)
+	}
+
+	res, err = backend.Search(context.Background(), &logs.SearchParams{Id: host, Query: "nothing"})
+	if err != nil || res.Total != 0 {
+		t.Errorf("Search() without match = %+v, %v, want no results", res, err)
+	}
+
+	unreachable, err := NewSSHSearchBackend(nil, &logs.SSHBackendConfig{
+		Hosts:                 []string{"127.0.0.1:1"},
+		Paths:                 []string{"*.log"},
+		Username:              "logs",
+		Password:              &kommons.EnvVar{Value: "secret"},
+		InsecureIgnoreHostKey: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unreachable.Search(context.Background(), &logs.SearchParams{Query: "error"}); err == nil || !strings.Contains(err.Error(), "127.0.0.1:1") {
+		t.Errorf("Search() error = %v, want the error of the unreachable host", err)
+	}
+}
+
+func TestNewSSHSearchBackend(t *testing.T) {
+	_, err := NewSSHSearchBackend(nil, &logs.SSHBackendConfig{Hosts: []string{"vm-1"}, Paths: []string{"/var/log/syslog"}, Username: "logs"})
+	if err == nil {
+		t.Error("NewSSHSearchBackend() without known hosts succeeded")
+	}
+}
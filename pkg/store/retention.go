@@ -0,0 +1,394 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/flanksource/commons/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var (
+	deletedLines = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "apm_hub_store_deleted_lines_total",
+		Help: "Number of log lines deleted from the store, by reason (age or size)",
+	}, []string{"reason"})
+
+	deletedBytes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "apm_hub_store_deleted_bytes_total",
+		Help: "Number of bytes deleted from the store, by reason (age or size)",
+	}, []string{"reason"})
+
+	storeSize = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "apm_hub_store_size_bytes",
+		Help: "Size of the store after the last compaction",
+	})
+)
+
+// Retention deletes the old lines of the store
+type Retention struct {
+	interval time.Duration
+	// maxAge is 0 when the lines are kept forever
+	maxAge    time.Duration
+	maxSize   int64
+	overrides []retentionOverride
+}
+
+type retentionOverride struct {
+	labels map[string]string
+	maxAge time.Duration
+}
+
+func NewRetention(config logs.RetentionConfig) (*Retention, error) {
+	t := &Retention{interval: time.Hour}
+
+	var err error
+	if config.Interval != "" {
+		if t.interval, err = parseDuration(config.Interval); err != nil {
+			return nil, fmt.Errorf("error parsing the retention interval: %w", err)
+		}
+	}
+	if config.MaxAge != "" {
+		if t.maxAge, err = parseDuration(config.MaxAge); err != nil {
+			return nil, fmt.Errorf("error parsing the retention max age: %w", err)
+		}
+	}
+	if config.MaxSize != "" {
+		size, err := resource.ParseQuantity(config.MaxSize)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the retention max size: %w", err)
+		}
+		t.maxSize = size.Value()
+	}
+
+	for i, override := range config.Overrides {
+		if len(override.Labels) == 0 {
+			return nil, fmt.Errorf("retention override[%d] has no labels", i)
+		}
+		maxAge, err := parseDuration(override.MaxAge)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the max age of retention override[%d]: %w", i, err)
+		}
+		t.overrides = append(t.overrides, retentionOverride{labels: override.Labels, maxAge: maxAge})
+	}
+
+	return t, nil
+}
+
+func parseDuration(value string) (time.Duration, error) {
+	d, err := durationUtil.ParseDuration(value)
+	return time.Duration(d), err
+}
+
+// expired returns true if the line is older than the max age of its labels
+func (t *Retention) expired(r logs.Result, now time.Time) bool {
+	maxAge := t.maxAge
+	for _, override := range t.overrides {
+		if matchAll(r.Labels, override.labels) {
+			maxAge = override.maxAge
+			break
+		}
+	}
+
+	if maxAge <= 0 {
+		return false
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, r.Time)
+	return err == nil && now.Sub(ts) > maxAge
+}
+
+func (t *Retention) checksAge() bool {
+	return t.maxAge > 0 || len(t.overrides) > 0
+}
+
+func matchAll(labels, selector map[string]string) bool {
+	for k, v := range selector {
+		val, ok := labels[k]
+		if !ok || !collections.MatchItems(val, strings.Split(v, ",")...) {
+			return false
+		}
+	}
+	return true
+}
+
+// CompactStats are the changes made by a compaction
+type CompactStats struct {
+	DeletedLines int64
+	DeletedBytes int64
+	// Merged is the number of segments merged into the previous ones
+	Merged int
+}
+
+// StartRetention compacts the store at every interval of the retention
+func (t *Store) StartRetention(r *Retention) {
+	go func() {
+		for {
+			stats, err := t.Compact(r)
+			if err != nil {
+				logger.Errorf("error compacting the store %s: %v", t.dir, err)
+			} else if stats.DeletedLines > 0 || stats.Merged > 0 {
+				logger.Infof("compacted the store %s: deleted %d lines (%d bytes), merged %d segments", t.dir, stats.DeletedLines, stats.DeletedBytes, stats.Merged)
+			}
+			time.Sleep(r.interval)
+		}
+	}()
+}
+
+// Compact deletes the lines older than their max age, merges the small segments
+// and then deletes the oldest segments until the store is within its max size.
+// The active segment is left untouched.
+func (t *Store) Compact(r *Retention) (CompactStats, error) {
+	var stats CompactStats
+
+	segments, err := t.sealedSegments()
+	if err != nil {
+		return stats, err
+	}
+
+	if r.checksAge() {
+		now := now()
+		for _, segment := range segments {
+			lines, bytes, err := rewriteSegment(segment.path, func(result logs.Result) bool { return !r.expired(result, now) })
+			if err != nil {
+				return stats, err
+			}
+			stats.DeletedLines += lines
+			stats.DeletedBytes += bytes
+			deletedLines.WithLabelValues("age").Add(float64(lines))
+			deletedBytes.WithLabelValues("age").Add(float64(bytes))
+		}
+	}
+
+	if stats.Merged, err = t.merge(); err != nil {
+		return stats, err
+	}
+
+	all, err := t.segments()
+	if err != nil {
+		return stats, err
+	}
+	var size int64
+	for _, segment := range all {
+		size += segment.size()
+	}
+
+	if r.maxSize > 0 && size > r.maxSize {
+		segments, err := t.sealedSegments()
+		if err != nil {
+			return stats, err
+		}
+
+		for _, segment := range segments {
+			if size <= r.maxSize {
+				break
+			}
+
+			bytes := segment.size()
+			lines, err := countLines(segment.path)
+			if err != nil {
+				return stats, err
+			}
+			if err := os.Remove(segment.path); err != nil {
+				return stats, fmt.Errorf("error deleting the segment: %w", err)
+			}
+
+			size -= bytes
+			stats.DeletedLines += lines
+			stats.DeletedBytes += bytes
+			deletedLines.WithLabelValues("size").Add(float64(lines))
+			deletedBytes.WithLabelValues("size").Add(float64(bytes))
+		}
+	}
+
+	storeSize.Set(float64(size))
+	return stats, nil
+}
+
+// sealedSegments returns the segments that are no longer written to
+func (t *Store) sealedSegments() ([]segment, error) {
+	segments, err := t.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	t.lock.Lock()
+	var active string
+	if t.active != nil {
+		active = t.active.Name()
+	}
+	t.lock.Unlock()
+
+	sealed := segments[:0]
+	for _, segment := range segments {
+		if segment.path != active {
+			sealed = append(sealed, segment)
+		}
+	}
+	return sealed, nil
+}
+
+// merge appends the consecutive segments that fit in SegmentSize to the first one of the run.
+// The merged segment keeps the name of the first one, as its lines were all written after it was created.
+func (t *Store) merge() (int, error) {
+	segments, err := t.sealedSegments()
+	if err != nil {
+		return 0, err
+	}
+
+	merged := 0
+	for i := 0; i < len(segments); {
+		run := []segment{segments[i]}
+		size := segments[i].size()
+		for j := i + 1; j < len(segments) && size+segments[j].size() <= SegmentSize; j++ {
+			run = append(run, segments[j])
+			size += segments[j].size()
+		}
+		i += len(run)
+
+		if len(run) == 1 {
+			continue
+		}
+		if err := mergeSegments(run); err != nil {
+			return merged, err
+		}
+		merged += len(run) - 1
+	}
+	return merged, nil
+}
+
+// mergeSegments copies the complete lines of the run to its first segment, dropping any line cut by a crash
+func mergeSegments(run []segment) error {
+	tmp := run[0].path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return fmt.Errorf("error creating the merged segment: %w", err)
+	}
+	writer := bufio.NewWriter(out)
+
+	for _, segment := range run {
+		err = readSegmentLines(segment.path, func(line []byte) error {
+			_, err := writer.Write(line)
+			return err
+		})
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error writing the merged segment: %w", err)
+	}
+
+	if err := os.Rename(tmp, run[0].path); err != nil {
+		return fmt.Errorf("error replacing the merged segment: %w", err)
+	}
+	for _, segment := range run[1:] {
+		if err := os.Remove(segment.path); err != nil {
+			return fmt.Errorf("error deleting the merged segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// rewriteSegment removes the lines that aren't kept, deleting the segment if none is left.
+// It returns the number of lines and bytes removed.
+func rewriteSegment(path string, keep func(logs.Result) bool) (lines, bytes int64, err error) {
+	tmp := path + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error creating the rewritten segment: %w", err)
+	}
+	writer := bufio.NewWriter(out)
+
+	kept := 0
+	err = readSegmentLines(path, func(line []byte) error {
+		var r logs.Result
+		if json.Unmarshal(line, &r) == nil && !keep(r) {
+			lines++
+			bytes += int64(len(line))
+			return nil
+		}
+		kept++
+		_, err := writer.Write(line)
+		return err
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil || lines == 0 {
+		os.Remove(tmp)
+		if err != nil {
+			return 0, 0, fmt.Errorf("error rewriting the segment: %w", err)
+		}
+		return 0, 0, nil
+	}
+
+	if kept == 0 {
+		os.Remove(tmp)
+		return lines, bytes, os.Remove(path)
+	}
+	return lines, bytes, os.Rename(tmp, path)
+}
+
+// readSegmentLines passes the complete lines of the segment to fn, including their newline.
+// A partial last line is still being written.
+func readSegmentLines(path string, fn func(line []byte) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			if err := fn(line); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func countLines(path string) (int64, error) {
+	var lines int64
+	err := readSegmentLines(path, func([]byte) error {
+		lines++
+		return nil
+	})
+	return lines, err
+}
+
+func (t segment) size() int64 {
+	info, err := os.Stat(t.path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestNewRetention(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  logs.RetentionConfig
+		wantErr bool
+	}{
+		{name: "valid", config: logs.RetentionConfig{MaxAge: "7d", MaxSize: "10Gi", Overrides: []logs.RetentionOverride{{Labels: map[string]string{"level": "debug"}, MaxAge: "1d"}}}},
+		{name: "max age", config: logs.RetentionConfig{MaxAge: "a week"}, wantErr: true},
+		{name: "max size", config: logs.RetentionConfig{MaxSize: "10 gigs"}, wantErr: true},
+		{name: "override without labels", config: logs.RetentionConfig{Overrides: []logs.RetentionOverride{{MaxAge: "1d"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRetention(tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("NewRetention() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStore_Compact(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	defer func(size int64) { SegmentSize = size }(SegmentSize)
+	defer func() { now = time.Now }()
+	current := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return current }
+
+	// One segment per append
+	SegmentSize = 1
+	batches := [][]logs.Result{
+		{
+			{Time: "2023-05-01T12:00:00Z", Message: "expired"},
+			{Time: "2023-05-09T12:00:00Z", Message: "debug", Labels: map[string]string{"level": "debug"}},
+		},
+		{{Time: "2023-05-09T12:00:00Z", Message: "kept"}},
+		{{Time: "2023-05-09T13:00:00Z", Message: "kept"}},
+		{{Time: "2023-05-10T11:00:00Z", Message: "active"}},
+	}
+	for _, batch := range batches {
+		current = current.Add(time.Second)
+		if err := s.Append(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	retention, err := NewRetention(logs.RetentionConfig{
+		MaxAge:    "7d",
+		Overrides: []logs.RetentionOverride{{Labels: map[string]string{"level": "debug,trace"}, MaxAge: "12h"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SegmentSize = 1024
+	stats, err := s.Compact(retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DeletedLines != 2 || stats.Merged != 1 {
+		t.Errorf("Compact() = %+v, want 2 deleted lines and 1 merged segment", stats)
+	}
+
+	// The first segment is empty and deleted, the two next are merged, the active one is untouched
+	if segments, _ := os.ReadDir(dir); len(segments) != 2 {
+		t.Errorf("expected 2 segments, got %d", len(segments))
+	}
+	res, err := s.Search(&logs.SearchParams{Start: "2023-05-01T00:00:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Total != 3 {
+		t.Errorf("expected 3 lines left, got %v", res.Results)
+	}
+
+	// Deletes the merged segment, which is older than the active one
+	retention.maxSize = 100
+	stats, err = s.Compact(retention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.DeletedLines != 2 {
+		t.Errorf("Compact() = %+v, want 2 deleted lines", stats)
+	}
+	if res, _ := s.Search(&logs.SearchParams{Start: "2023-05-01T00:00:00Z"}); res.Total != 1 || res.Results[0].Message != "active" {
+		t.Errorf("expected the active segment only, got %v", res.Results)
+	}
+}
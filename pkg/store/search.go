@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+)
+
+func NewStoreSearchBackend(config *logs.StoreBackendConfig) (*StoreSearch, error) {
+	store, err := Open(config.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StoreSearch{
+		config: config,
+		store:  store,
+	}, nil
+}
+
+// StoreSearch searches the logs pushed to the /ingest endpoint
+type StoreSearch struct {
+	config *logs.StoreBackendConfig
+	store  *Store
+}
+
+// Search reads the local segments, which isn't interrupted by the context
+func (t *StoreSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	res, err := t.store.Search(q)
+	if err != nil {
+		return res, err
+	}
+
+	if len(t.config.Labels) > 0 {
+		for i := range res.Results {
+			res.Results[i].Labels = collections.MergeMap(res.Results[i].Labels, t.config.Labels)
+		}
+	}
+
+	return res, nil
+}
+
+// SearchStream implements logs.SearchStreamer
+func (t *StoreSearch) SearchStream(ctx context.Context, q *logs.SearchParams, results chan<- logs.Result) error {
+	return t.store.Stream(q, func(r logs.Result) error {
+		if len(t.config.Labels) > 0 {
+			r.Labels = collections.MergeMap(r.Labels, t.config.Labels)
+		}
+
+		select {
+		case results <- r:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+func (t *StoreSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+func (t *StoreSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Streaming: true}
+}
@@ -0,0 +1,321 @@
+// Package store is a local log store, written by the /ingest endpoint and searched by the store backends.
+//
+// The logs are appended as json lines to segment files named after the time they were created at.
+// A new segment is started once the active one is too large or too old.
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/commons/collections"
+)
+
+const segmentExt = ".ndjson"
+
+var (
+	// SegmentSize is the size after which a new segment is started
+	SegmentSize int64 = 64 * 1024 * 1024
+
+	// SegmentDuration is the age after which a new segment is started
+	SegmentDuration = time.Hour
+)
+
+// now is replaced in the tests
+var now = time.Now
+
+var (
+	storesLock sync.Mutex
+	stores     = make(map[string]*Store)
+)
+
+// Store appends the logs to segment files in a directory
+type Store struct {
+	dir string
+
+	lock sync.Mutex
+	// active is the segment being written to
+	active     *os.File
+	activeSize int64
+	activeTime time.Time
+}
+
+// Open returns the store in the directory, creating the directory if needed.
+// The ingest endpoint and the backends searching the same directory share the store.
+func Open(dir string) (*Store, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving the store path: %w", err)
+	}
+
+	storesLock.Lock()
+	defer storesLock.Unlock()
+	if s, ok := stores[dir]; ok {
+		return s, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating the store directory: %w", err)
+	}
+
+	s := &Store{dir: dir}
+	stores[dir] = s
+	return s, nil
+}
+
+// Append writes the results to the active segment
+func (t *Store) Append(results []logs.Result) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, r := range results {
+		if err := encoder.Encode(r); err != nil {
+			return fmt.Errorf("error encoding the result: %w", err)
+		}
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if err := t.roll(int64(buf.Len())); err != nil {
+		return err
+	}
+
+	n, err := t.active.Write(buf.Bytes())
+	t.activeSize += int64(n)
+	if err != nil {
+		return fmt.Errorf("error writing to the segment: %w", err)
+	}
+	return nil
+}
+
+// roll starts a new segment when the write would make the active one too large, or it's too old
+func (t *Store) roll(size int64) error {
+	if t.active != nil && t.activeSize+size <= SegmentSize && now().Sub(t.activeTime) < SegmentDuration {
+		return nil
+	}
+
+	if t.active != nil {
+		if err := t.active.Close(); err != nil {
+			return fmt.Errorf("error closing the segment: %w", err)
+		}
+		t.active = nil
+	}
+
+	created := now()
+	// Segments of the same nanosecond can only happen with a tiny SegmentSize, keep them ordered anyway
+	if !created.After(t.activeTime) {
+		created = t.activeTime.Add(time.Nanosecond)
+	}
+
+	f, err := os.OpenFile(filepath.Join(t.dir, segmentName(created)), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("error creating the segment: %w", err)
+	}
+
+	t.active = f
+	t.activeSize = 0
+	t.activeTime = created
+	return nil
+}
+
+// Close closes the active segment
+func (t *Store) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.active == nil {
+		return nil
+	}
+	err := t.active.Close()
+	t.active = nil
+	return err
+}
+
+// Search returns the most recent results matching the search params, in chronological order.
+//
+// Labels of the search params that the results don't have are ignored, as they are used to route the search.
+func (t *Store) Search(q *logs.SearchParams) (logs.SearchResults, error) {
+	var res logs.SearchResults
+
+	segments, err := t.segments()
+	if err != nil {
+		return res, err
+	}
+
+	start, end := q.GetStart(), q.GetEnd()
+	query := strings.ToLower(q.Query)
+	var matched []entry
+	for i, segment := range segments {
+		// The logs of a segment were written before the next segment was created,
+		// so it can't hold logs after the start of the search unless the agents sent timestamps in the future.
+		if start != nil && i+1 < len(segments) && segments[i+1].created.Before(*start) {
+			continue
+		}
+
+		err := readSegment(segment.path, func(r logs.Result) error {
+			ts, _ := time.Parse(time.RFC3339Nano, r.Time)
+			if !match(r, ts, q.Labels, query, start, end) {
+				return nil
+			}
+
+			res.Total++
+			matched = append(matched, entry{time: ts, result: r})
+			if q.Limit > 0 && int64(len(matched)) > 2*q.Limit {
+				matched = mostRecent(matched, q.Limit)
+			}
+			return nil
+		})
+		if err != nil {
+			return res, err
+		}
+	}
+
+	for _, e := range mostRecent(matched, q.Limit) {
+		res.Results = append(res.Results, e.result)
+	}
+	return res, nil
+}
+
+// Stream passes the logs matching the search params to fn, from the oldest segment,
+// until the limit of the search params is reached or fn returns an error.
+func (t *Store) Stream(q *logs.SearchParams, fn func(r logs.Result) error) error {
+	segments, err := t.segments()
+	if err != nil {
+		return err
+	}
+
+	start, end := q.GetStart(), q.GetEnd()
+	query := strings.ToLower(q.Query)
+	var sent int64
+	for i, segment := range segments {
+		if start != nil && i+1 < len(segments) && segments[i+1].created.Before(*start) {
+			continue
+		}
+
+		err := readSegment(segment.path, func(r logs.Result) error {
+			ts, _ := time.Parse(time.RFC3339Nano, r.Time)
+			if !match(r, ts, q.Labels, query, start, end) {
+				return nil
+			}
+
+			if err := fn(r); err != nil {
+				return err
+			}
+			if sent++; q.Limit > 0 && sent >= q.Limit {
+				return errLimitReached
+			}
+			return nil
+		})
+		if errors.Is(err, errLimitReached) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type entry struct {
+	time   time.Time
+	result logs.Result
+}
+
+func match(r logs.Result, ts time.Time, labels map[string]string, query string, start, end *time.Time) bool {
+	if start != nil && ts.Before(*start) {
+		return false
+	}
+	if end != nil && ts.After(*end) {
+		return false
+	}
+
+	for k, v := range labels {
+		if val, ok := r.Labels[k]; ok && !collections.MatchItems(val, strings.Split(v, ",")...) {
+			return false
+		}
+	}
+
+	return query == "" || strings.Contains(strings.ToLower(r.Message), query)
+}
+
+// mostRecent sorts the entries chronologically and keeps the last limit ones
+func mostRecent(entries []entry, limit int64) []entry {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].time.Before(entries[j].time) })
+	if limit > 0 && int64(len(entries)) > limit {
+		entries = append([]entry(nil), entries[int64(len(entries))-limit:]...)
+	}
+	return entries
+}
+
+type segment struct {
+	path    string
+	created time.Time
+}
+
+// segments returns the segments of the store from the oldest
+func (t *Store) segments() ([]segment, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error listing the segments: %w", err)
+	}
+
+	var segments []segment
+	for _, entry := range entries {
+		created, ok := parseSegmentName(entry.Name())
+		if entry.IsDir() || !ok {
+			continue
+		}
+		segments = append(segments, segment{path: filepath.Join(t.dir, entry.Name()), created: created})
+	}
+
+	sort.Slice(segments, func(i, j int) bool { return segments[i].created.Before(segments[j].created) })
+	return segments, nil
+}
+
+// errLimitReached stops the reading of a segment once the search has enough results
+var errLimitReached = errors.New("limit reached")
+
+// readSegment passes the results of the segment to fn, until it returns an error
+func readSegment(path string, fn func(r logs.Result) error) error {
+	err := readSegmentLines(path, func(line []byte) error {
+		var r logs.Result
+		if json.Unmarshal(line, &r) != nil {
+			return nil
+		}
+		return fn(r)
+	})
+	// Removed by a compaction since it was listed
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error reading the segment: %w", err)
+	}
+	return nil
+}
+
+func segmentName(created time.Time) string {
+	return fmt.Sprintf("%020d%s", created.UnixNano(), segmentExt)
+}
+
+func parseSegmentName(name string) (time.Time, bool) {
+	nanos, err := strconv.ParseInt(strings.TrimSuffix(name, segmentExt), 10, 64)
+	if err != nil || !strings.HasSuffix(name, segmentExt) {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	if other, _ := Open(dir); other != s {
+		t.Errorf("Open() returned a different store for the same directory")
+	}
+
+	// Roll a segment for every append
+	defer func(size int64) { SegmentSize = size }(SegmentSize)
+	SegmentSize = 1
+
+	batches := [][]logs.Result{
+		{
+			{Time: "2023-05-01T12:00:10Z", Message: "GET /healthz", Labels: map[string]string{"app": "api"}},
+			{Time: "2023-05-01T12:00:20Z", Message: "connection refused", Labels: map[string]string{"app": "api"}},
+		},
+		{
+			{Time: "2023-05-01T12:00:30.5Z", Message: "Connection reset", Labels: map[string]string{"app": "web"}},
+			{Time: "2023-05-01T12:00:30Z", Message: "GET /", Labels: map[string]string{"app": "web"}},
+		},
+	}
+	for _, batch := range batches {
+		if err := s.Append(batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if segments, _ := os.ReadDir(dir); len(segments) != 2 {
+		t.Errorf("expected 2 segments, got %d", len(segments))
+	}
+
+	tests := []struct {
+		name      string
+		q         logs.SearchParams
+		wantTotal int
+		want      []string
+	}{
+		{name: "all", q: logs.SearchParams{Start: "2023-05-01T12:00:00Z"}, wantTotal: 4, want: []string{"GET /healthz", "connection refused", "GET /", "Connection reset"}},
+		{name: "limit keeps the most recent", q: logs.SearchParams{Start: "2023-05-01T12:00:00Z", Limit: 2}, wantTotal: 4, want: []string{"GET /", "Connection reset"}},
+		{name: "query", q: logs.SearchParams{Start: "2023-05-01T12:00:00Z", Query: "connection"}, wantTotal: 2, want: []string{"connection refused", "Connection reset"}},
+		{name: "labels", q: logs.SearchParams{Start: "2023-05-01T12:00:00Z", Labels: map[string]string{"app": "web", "type": "ingested"}}, wantTotal: 2, want: []string{"GET /", "Connection reset"}},
+		{name: "range", q: logs.SearchParams{Start: "2023-05-01T12:00:15Z", End: "2023-05-01T12:00:30Z"}, wantTotal: 2, want: []string{"connection refused", "GET /"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := s.Search(&tt.q)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var messages []string
+			for _, r := range res.Results {
+				messages = append(messages, r.Message)
+			}
+			if res.Total != tt.wantTotal || !reflect.DeepEqual(messages, tt.want) {
+				t.Errorf("Search() = %d %v, want %d %v", res.Total, messages, tt.wantTotal, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_SkipsOldSegments(t *testing.T) {
+	dir := t.TempDir()
+	s, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	defer func() { now = time.Now }()
+	for i, message := range []string{"old", "new"} {
+		now = func() time.Time { return time.Date(2023, 5, 1, 10+i*2, 0, 0, 0, time.UTC) }
+		// Timestamps in the future are only found in the segments after the start of the search
+		if err := s.Append([]logs.Result{{Time: "2023-05-01T13:00:00Z", Message: message}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := s.Search(&logs.SearchParams{Start: "2023-05-01T12:30:00Z"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 1 || res.Results[0].Message != "new" {
+		t.Errorf("Search() = %v, want the new segment only", res.Results)
+	}
+}
+
+func TestStoreSearch_SearchStream(t *testing.T) {
+	backend, err := NewStoreSearchBackend(&logs.StoreBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"source": "ingest"}},
+		Path:          t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backend.store.Close()
+
+	err = backend.store.Append([]logs.Result{
+		{Time: "2023-05-01T12:00:10Z", Message: "connection refused"},
+		{Time: "2023-05-01T12:00:20Z", Message: "GET /"},
+		{Time: "2023-05-01T12:00:30Z", Message: "connection reset"},
+		{Time: "2023-05-01T12:00:40Z", Message: "connection closed"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results := make(chan logs.Result, 10)
+	q := logs.SearchParams{Start: "2023-05-01T12:00:00Z", Query: "connection", Limit: 2}
+	if err := backend.SearchStream(context.Background(), &q, results); err != nil {
+		t.Fatal(err)
+	}
+	close(results)
+
+	var messages []string
+	for r := range results {
+		if r.Labels["source"] != "ingest" {
+			t.Errorf("expected the labels of the backend, got %v", r.Labels)
+		}
+		messages = append(messages, r.Message)
+	}
+	if want := []string{"connection refused", "connection reset"}; !reflect.DeepEqual(messages, want) {
+		t.Errorf("SearchStream() = %v, want %v", messages, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	q = logs.SearchParams{Start: "2023-05-01T12:00:00Z"}
+	if err := backend.SearchStream(ctx, &q, make(chan logs.Result)); !errors.Is(err, context.Canceled) {
+		t.Errorf("SearchStream() error = %v, want %v", err, context.Canceled)
+	}
+}
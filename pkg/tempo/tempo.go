@@ -0,0 +1,313 @@
+// Package tempo searches the traces of Grafana Tempo with TraceQL
+package tempo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/apm-hub/pkg/traces"
+	"github.com/flanksource/kommons"
+)
+
+var traceIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{16,32}$`)
+
+type TempoSearch struct {
+	config *logs.TempoBackendConfig
+	client *rest.Client
+}
+
+func NewTempoSearchBackend(kClient *kommons.Client, config *logs.TempoBackendConfig) (*TempoSearch, error) {
+	client, err := rest.NewClient(kClient, config.HTTPConnection)
+	if err != nil {
+		return nil, err
+	}
+	return &TempoSearch{config: config, client: client}, nil
+}
+
+// Search returns the spans of the trace of the search, see traces.TraceID,
+// otherwise the spans matching the TraceQL query in the time range
+func (t *TempoSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	traceID := traces.TraceID(q)
+
+	if traceID != "" {
+		var res traceResponse
+		if err := t.client.Get(ctx, "/api/traces/"+url.PathEscape(traceID), nil, &res); err != nil {
+			return logs.SearchResults{}, err
+		}
+		return traces.Results(res.spans(), q.Limit, t.config.Labels), nil
+	}
+
+	var res searchResponse
+	if err := t.client.Get(ctx, "/api/search", t.query(q), &res); err != nil {
+		return logs.SearchResults{}, err
+	}
+	return traces.Results(res.spans(), q.Limit, t.config.Labels), nil
+}
+
+// query returns the params of the TraceQL search
+func (t *TempoSearch) query(q *logs.SearchParams) url.Values {
+	query := url.Values{}
+	query.Set("q", t.traceQL(q))
+
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+	if start := q.GetStart(); start != nil {
+		query.Set("start", strconv.FormatInt(start.Unix(), 10))
+	}
+	query.Set("end", strconv.FormatInt(end.Unix(), 10))
+	if q.Limit > 0 {
+		query.Set("limit", strconv.FormatInt(q.Limit, 10))
+	}
+	if t.config.SpansPerSpanSet > 0 {
+		query.Set("spss", strconv.Itoa(t.config.SpansPerSpanSet))
+	}
+	return query
+}
+
+// traceQL returns the query of the search when it's a TraceQL query.
+// Otherwise it selects the spans by service, operation and tags, and by name with the text of the query.
+func (t *TempoSearch) traceQL(q *logs.SearchParams) string {
+	if strings.HasPrefix(strings.TrimSpace(q.Query), "{") {
+		return q.Query
+	}
+
+	var conditions []string
+	service := q.Labels["service"]
+	if service == "" {
+		service = q.Id
+	}
+	if service != "" {
+		conditions = append(conditions, "resource.service.name = "+strconv.Quote(service))
+	}
+	if operation := q.Labels["operation"]; operation != "" {
+		conditions = append(conditions, "name = "+strconv.Quote(operation))
+	}
+	for _, tag := range t.config.Tags {
+		if v, ok := q.Labels[tag]; ok {
+			conditions = append(conditions, "."+tag+" = "+literal(v))
+		}
+	}
+	if q.Query != "" {
+		conditions = append(conditions, "name =~ "+strconv.Quote("(?i).*"+regexp.QuoteMeta(q.Query)+".*"))
+	}
+
+	if len(conditions) == 0 {
+		if t.config.Query != "" {
+			return t.config.Query
+		}
+		return "{}"
+	}
+	return "{ " + strings.Join(conditions, " && ") + " }"
+}
+
+// literal returns the value as a TraceQL number or boolean when it is one, so that it matches the typed attributes
+func literal(v string) string {
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return v
+	}
+	if v == "true" || v == "false" {
+		return v
+	}
+	return strconv.Quote(v)
+}
+
+func (t *TempoSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Tempo backend, the query is sent as TraceQL
+func (t *TempoSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true}
+}
+
+// HealthCheck checks that the search api is ready to answer
+func (t *TempoSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/echo", nil, nil)
+}
+
+// traceResponse is the OTLP json of a trace
+type traceResponse struct {
+	Batches       []resourceSpans `json:"batches"`
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource struct {
+		Attributes []keyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+	// InstrumentationLibrarySpans are the scope spans of the older versions of Tempo
+	InstrumentationLibrarySpans []scopeSpans `json:"instrumentationLibrarySpans"`
+}
+
+type scopeSpans struct {
+	Spans []span `json:"spans"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId"`
+	Name              string     `json:"name"`
+	StartTimeUnixNano int64      `json:"startTimeUnixNano,string"`
+	EndTimeUnixNano   int64      `json:"endTimeUnixNano,string"`
+	Attributes        []keyValue `json:"attributes"`
+	Events            []struct {
+		TimeUnixNano int64      `json:"timeUnixNano,string"`
+		Name         string     `json:"name"`
+		Attributes   []keyValue `json:"attributes"`
+	} `json:"events"`
+	Status struct {
+		// Code is either the name or the number of the status code
+		Code any `json:"code"`
+	} `json:"status"`
+}
+
+func (t traceResponse) spans() []traces.Span {
+	var spans []traces.Span
+	for _, batch := range append(t.Batches, t.ResourceSpans...) {
+		resource := attributes(batch.Resource.Attributes)
+		for _, scope := range append(batch.ScopeSpans, batch.InstrumentationLibrarySpans...) {
+			for _, s := range scope.Spans {
+				spans = append(spans, s.span(resource))
+			}
+		}
+	}
+	return spans
+}
+
+func (s span) span(resource map[string]string) traces.Span {
+	result := traces.Span{
+		TraceID:   id(s.TraceID),
+		SpanID:    id(s.SpanID),
+		ParentID:  id(s.ParentSpanID),
+		Service:   resource["service.name"],
+		Operation: s.Name,
+		Start:     time.Unix(0, s.StartTimeUnixNano),
+		Duration:  time.Duration(s.EndTimeUnixNano - s.StartTimeUnixNano),
+		Error:     s.Status.Code == "STATUS_CODE_ERROR" || s.Status.Code == float64(2),
+		Tags:      attributes(s.Attributes),
+	}
+	for _, event := range s.Events {
+		result.Events = append(result.Events, traces.Event{
+			Time:       time.Unix(0, event.TimeUnixNano),
+			Name:       event.Name,
+			Attributes: attributes(event.Attributes),
+		})
+	}
+	return result
+}
+
+// searchResponse is the json of the TraceQL search, with the spans matched in each trace
+type searchResponse struct {
+	Traces []struct {
+		TraceID         string  `json:"traceID"`
+		RootServiceName string  `json:"rootServiceName"`
+		SpanSet         spanSet `json:"spanSet"`
+		// SpanSets is set instead of SpanSet by the queries selecting more than one span set
+		SpanSets []spanSet `json:"spanSets"`
+	} `json:"traces"`
+}
+
+type spanSet struct {
+	Spans []struct {
+		SpanID            string     `json:"spanID"`
+		Name              string     `json:"name"`
+		StartTimeUnixNano int64      `json:"startTimeUnixNano,string"`
+		DurationNanos     int64      `json:"durationNanos,string"`
+		Attributes        []keyValue `json:"attributes"`
+	} `json:"spans"`
+}
+
+func (t searchResponse) spans() []traces.Span {
+	var spans []traces.Span
+	for _, trace := range t.Traces {
+		seen := map[string]bool{}
+		for _, set := range append([]spanSet{trace.SpanSet}, trace.SpanSets...) {
+			for _, s := range set.Spans {
+				if seen[s.SpanID] {
+					continue
+				}
+				seen[s.SpanID] = true
+
+				tags := attributes(s.Attributes)
+				service := tags["service.name"]
+				if service == "" {
+					service = trace.RootServiceName
+				}
+				spans = append(spans, traces.Span{
+					TraceID:   trace.TraceID,
+					SpanID:    s.SpanID,
+					Service:   service,
+					Operation: s.Name,
+					Start:     time.Unix(0, s.StartTimeUnixNano),
+					Duration:  time.Duration(s.DurationNanos),
+					Error:     tags["status"] == "error",
+					Tags:      tags,
+				})
+			}
+		}
+	}
+	return spans
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string         `json:"stringValue"`
+	IntValue    *string         `json:"intValue"`
+	BoolValue   *bool           `json:"boolValue"`
+	DoubleValue *float64        `json:"doubleValue"`
+	ArrayValue  json.RawMessage `json:"arrayValue"`
+	KvlistValue json.RawMessage `json:"kvlistValue"`
+}
+
+func (v anyValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.IntValue != nil:
+		return *v.IntValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'f', -1, 64)
+	case v.ArrayValue != nil:
+		return string(v.ArrayValue)
+	default:
+		return string(v.KvlistValue)
+	}
+}
+
+func attributes(kvs []keyValue) map[string]string {
+	m := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		m[kv.Key] = kv.Value.String()
+	}
+	return m
+}
+
+// id returns the trace or span id as hex, Tempo encodes the ids of the traces as base64
+func id(s string) string {
+	if s == "" || (len(s)%2 == 0 && traceIDPattern.MatchString(s)) {
+		return s
+	}
+	if data, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return hex.EncodeToString(data)
+	}
+	return s
+}
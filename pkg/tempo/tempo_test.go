@@ -0,0 +1,103 @@
+package tempo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+const traceResponseJSON = `{"batches": [{
+	"resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "checkout"}}]},
+	"scopeSpans": [{"spans": [{
+		"traceId": "S/kvNXezTaajzpKdDg5HNg==", "spanId": "APBnqgupArc=", "name": "POST /checkout",
+		"startTimeUnixNano": "1682942400000000000", "endTimeUnixNano": "1682942400250000000",
+		"attributes": [{"key": "http.status_code", "value": {"intValue": "500"}}],
+		"events": [{"timeUnixNano": "1682942400200000000", "name": "exception",
+			"attributes": [{"key": "exception.message", "value": {"stringValue": "card declined"}}]}],
+		"status": {"code": "STATUS_CODE_ERROR"}
+	}]}]
+}]}`
+
+const searchResponseJSON = `{"traces": [{
+	"traceID": "4bf92f3577b34da6a3ce929d0e0e4736", "rootServiceName": "checkout",
+	"spanSet": {"spans": [{"spanID": "00f067aa0ba902b7", "name": "POST /checkout",
+		"startTimeUnixNano": "1682942400000000000", "durationNanos": "250000000",
+		"attributes": [{"key": "status", "value": {"stringValue": "error"}}]}]},
+	"spanSets": [{"spans": [{"spanID": "00f067aa0ba902b7", "name": "POST /checkout",
+		"startTimeUnixNano": "1682942400000000000", "durationNanos": "250000000"}]}]
+}]}`
+
+func TestTempoSearch(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/traces/4bf92f3577b34da6a3ce929d0e0e4736":
+			w.Write([]byte(traceResponseJSON))
+		case "/api/search":
+			queries = append(queries, r.URL.Query().Get("q"))
+			w.Write([]byte(searchResponseJSON))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	backend, err := NewTempoSearchBackend(nil, &logs.TempoBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"cluster": "prod"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		Tags:           []string{"http.status_code"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Id: "4bf92f3577b34da6a3ce929d0e0e4736", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []logs.Result{
+		{Id: "00f067aa0ba902b7", Time: "2023-05-01T12:00:00Z", Message: "checkout POST /checkout 250ms error", Labels: map[string]string{
+			"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "span_id": "00f067aa0ba902b7", "service": "checkout", "operation": "POST /checkout",
+			"duration": "250ms", "status": "error", "http.status_code": "500", "cluster": "prod",
+		}},
+		{Id: "00f067aa0ba902b7-0", Time: "2023-05-01T12:00:00.2Z", Message: "checkout POST /checkout exception", Labels: map[string]string{
+			"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "span_id": "00f067aa0ba902b7", "service": "checkout", "operation": "POST /checkout",
+			"event": "exception", "exception.message": "card declined", "cluster": "prod",
+		}},
+	}
+	if !reflect.DeepEqual(res.Results, want) {
+		t.Errorf("Search() = %+v, want %+v", res.Results, want)
+	}
+
+	searches := []logs.SearchParams{
+		{Id: "checkout", Labels: map[string]string{"operation": "POST /checkout", "http.status_code": "500", "namespace": "shop"}},
+		{Query: `{ span.http.status_code >= 500 }`},
+		{Labels: map[string]string{"service": "checkout"}, Query: "declined"},
+		{},
+	}
+	for _, q := range searches {
+		q := q
+		q.Start = "1h"
+		res, err := backend.Search(context.Background(), &q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Results) != 1 || res.Results[0].Labels["status"] != "error" {
+			t.Errorf("Search(%+v) = %+v, want the span of the search response once", q, res.Results)
+		}
+	}
+
+	wantQueries := []string{
+		`{ resource.service.name = "checkout" && name = "POST /checkout" && .http.status_code = 500 }`,
+		`{ span.http.status_code >= 500 }`,
+		`{ resource.service.name = "checkout" && name =~ "(?i).*declined.*" }`,
+		`{}`,
+	}
+	if !reflect.DeepEqual(queries, wantQueries) {
+		t.Errorf("queries = %v, want %v", queries, wantQueries)
+	}
+}
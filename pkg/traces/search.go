@@ -0,0 +1,24 @@
+package traces
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+var traceIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{16,32}$`)
+
+// TraceID returns the trace of the search: its trace_id or traceId label, or its id when the type is Trace
+// or the id is a trace id. It's empty when the search isn't the search of a trace.
+func TraceID(q *logs.SearchParams) string {
+	for _, label := range []string{"trace_id", "traceId"} {
+		if id := q.Labels[label]; id != "" {
+			return id
+		}
+	}
+	if strings.EqualFold(q.Type, "Trace") || traceIDPattern.MatchString(q.Id) {
+		return q.Id
+	}
+	return ""
+}
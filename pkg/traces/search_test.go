@@ -0,0 +1,28 @@
+package traces
+
+import (
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+func TestTraceID(t *testing.T) {
+	tests := []struct {
+		name string
+		q    logs.SearchParams
+		want string
+	}{
+		{name: "trace_id label", q: logs.SearchParams{Id: "checkout", Labels: map[string]string{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"}}, want: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "traceId label", q: logs.SearchParams{Labels: map[string]string{"traceId": "4bf92f3577b34da6a3ce929d0e0e4736"}}, want: "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{name: "trace type", q: logs.SearchParams{Type: "Trace", Id: "1-5759e988-bd862e3fe1be46a994272793"}, want: "1-5759e988-bd862e3fe1be46a994272793"},
+		{name: "trace id", q: logs.SearchParams{Id: "a3ce929d0e0e4736"}, want: "a3ce929d0e0e4736"},
+		{name: "service", q: logs.SearchParams{Type: "Service", Id: "checkout"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TraceID(&tt.q); got != tt.want {
+				t.Errorf("TraceID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
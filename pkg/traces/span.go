@@ -0,0 +1,121 @@
+// Package traces renders the spans of the tracing backends as results,
+// so that the traces can be searched next to the logs.
+package traces
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// Span is the part of a span rendered as a result
+type Span struct {
+	TraceID   string
+	SpanID    string
+	ParentID  string
+	Service   string
+	Operation string
+	Start     time.Time
+	Duration  time.Duration
+	Error     bool
+	Tags      map[string]string
+	Events    []Event
+}
+
+// Event is an event recorded during the span, e.g. an exception
+type Event struct {
+	Time       time.Time
+	Name       string
+	Attributes map[string]string
+}
+
+// Result renders the span as a result whose message is the service, the operation and the duration.
+// The span tags become labels, overridden by the labels of the span itself and the given labels.
+func (t Span) Result(labels map[string]string) logs.Result {
+	status := "ok"
+	if t.Error {
+		status = "error"
+	}
+
+	message := fmt.Sprintf("%s %s %s", t.Service, t.Operation, t.Duration)
+	if t.Error {
+		message += " error"
+	}
+
+	r := logs.Result{
+		Id:      t.SpanID,
+		Time:    t.Start.UTC().Format(time.RFC3339Nano),
+		Message: message,
+		Labels:  make(map[string]string, len(t.Tags)+len(labels)+7),
+	}
+	for k, v := range t.Tags {
+		r.Labels[k] = v
+	}
+	r.Labels["trace_id"] = t.TraceID
+	r.Labels["span_id"] = t.SpanID
+	if t.ParentID != "" {
+		r.Labels["parent_span_id"] = t.ParentID
+	}
+	r.Labels["service"] = t.Service
+	r.Labels["operation"] = t.Operation
+	r.Labels["duration"] = t.Duration.String()
+	r.Labels["status"] = status
+	for k, v := range labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+// EventResult renders the event of the span as a result whose message is the service, the operation and the event name.
+// The event attributes become labels, overridden by the labels identifying the span and the given labels.
+func (t Span) EventResult(i int, labels map[string]string) logs.Result {
+	event := t.Events[i]
+	r := logs.Result{
+		Id:      fmt.Sprintf("%s-%d", t.SpanID, i),
+		Time:    event.Time.UTC().Format(time.RFC3339Nano),
+		Message: fmt.Sprintf("%s %s %s", t.Service, t.Operation, event.Name),
+		Labels:  make(map[string]string, len(event.Attributes)+len(labels)+5),
+	}
+	for k, v := range event.Attributes {
+		r.Labels[k] = v
+	}
+	r.Labels["trace_id"] = t.TraceID
+	r.Labels["span_id"] = t.SpanID
+	r.Labels["service"] = t.Service
+	r.Labels["operation"] = t.Operation
+	r.Labels["event"] = event.Name
+	for k, v := range labels {
+		r.Labels[k] = v
+	}
+	return r
+}
+
+// Results renders the spans and their events in chronological order, up to the limit
+func Results(spans []Span, limit int64, labels map[string]string) logs.SearchResults {
+	type entry struct {
+		time   time.Time
+		result func() logs.Result
+	}
+
+	var entries []entry
+	for _, span := range spans {
+		span := span
+		entries = append(entries, entry{time: span.Start, result: func() logs.Result { return span.Result(labels) }})
+		for j, event := range span.Events {
+			j := j
+			entries = append(entries, entry{time: event.Time, result: func() logs.Result { return span.EventResult(j, labels) }})
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].time.Before(entries[j].time) })
+
+	results := logs.SearchResults{Total: len(entries)}
+	for _, entry := range entries {
+		if limit > 0 && int64(len(results.Results)) >= limit {
+			break
+		}
+		results.Results = append(results.Results, entry.result())
+	}
+	return results
+}
@@ -0,0 +1,214 @@
+// Package wasm searches the backends implemented by WebAssembly modules, see logs.WASMBackendConfig for their exports
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	durationUtil "github.com/flanksource/commons/duration"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+func NewWASMSearchBackend(config *logs.WASMBackendConfig) (*WASMSearch, error) {
+	if config.Path == "" {
+		return nil, fmt.Errorf("wasm module path is required")
+	}
+
+	timeout := 30 * time.Second
+	if config.Timeout != "" {
+		d, err := durationUtil.ParseDuration(config.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing the timeout: %w", err)
+		}
+		timeout = time.Duration(d)
+	}
+
+	code, err := os.ReadFile(config.Path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading the wasm module: %w", err)
+	}
+
+	ctx := context.Background()
+	// The searches exceeding the timeout are interrupted, closing the instance of the module
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("error instantiating wasi: %w", err)
+	}
+	compiled, err := runtime.CompileModule(ctx, code)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("error compiling the wasm module %s: %w", config.Path, err)
+	}
+
+	t := &WASMSearch{config: config, timeout: timeout, runtime: runtime, compiled: compiled}
+	if err := t.instantiate(ctx); err != nil {
+		runtime.Close(ctx)
+		return nil, err
+	}
+	return t, nil
+}
+
+// WASMSearch implements the SearchAPI with the search function of a WebAssembly module
+type WASMSearch struct {
+	config   *logs.WASMBackendConfig
+	timeout  time.Duration
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+
+	// lock serializes the calls, a module instance isn't safe for concurrent use
+	lock sync.Mutex
+	// module is nil when the instance was closed, after a search exceeding the timeout
+	module   api.Module
+	allocate api.Function
+	search   api.Function
+}
+
+// instantiate instantiates the module and configures it with the settings
+func (t *WASMSearch) instantiate(ctx context.Context) error {
+	moduleConfig := wazero.NewModuleConfig().
+		WithName(t.config.Path).
+		WithStartFunctions("_initialize").
+		WithStdout(os.Stderr).
+		WithStderr(os.Stderr)
+	for k, v := range t.config.Env {
+		moduleConfig = moduleConfig.WithEnv(k, v)
+	}
+
+	module, err := t.runtime.InstantiateModule(ctx, t.compiled, moduleConfig)
+	if err != nil {
+		return fmt.Errorf("error instantiating the wasm module %s: %w", t.config.Path, err)
+	}
+
+	allocate, search := module.ExportedFunction("allocate"), module.ExportedFunction("search")
+	switch {
+	case module.Memory() == nil:
+		err = fmt.Errorf("wasm module %s doesn't export its memory", t.config.Path)
+	case allocate == nil:
+		err = fmt.Errorf("wasm module %s doesn't export an allocate function", t.config.Path)
+	case search == nil:
+		err = fmt.Errorf("wasm module %s doesn't export a search function", t.config.Path)
+	}
+	if err != nil {
+		module.Close(ctx)
+		return err
+	}
+
+	if configure := module.ExportedFunction("configure"); configure != nil {
+		ptr, err := write(ctx, module, allocate, []byte(t.config.Settings))
+		if err == nil {
+			var returned []uint64
+			if returned, err = configure.Call(ctx, uint64(ptr), uint64(len(t.config.Settings))); err == nil && returned[0] != 0 {
+				err = fmt.Errorf("configure returned %d", returned[0])
+			}
+		}
+		if err != nil {
+			module.Close(ctx)
+			return fmt.Errorf("error configuring the wasm module %s: %w", t.config.Path, err)
+		}
+	} else if t.config.Settings != "" {
+		module.Close(ctx)
+		return fmt.Errorf("wasm module %s doesn't export a configure function for its settings", t.config.Path)
+	}
+
+	t.module, t.allocate, t.search = module, allocate, search
+	return nil
+}
+
+// results are the search results returned by the module, with the error of the search
+type results struct {
+	logs.SearchResults
+	Error string `json:"error,omitempty"`
+}
+
+func (t *WASMSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	input, err := json.Marshal(q)
+	if err != nil {
+		return logs.SearchResults{}, fmt.Errorf("error encoding the search params: %w", err)
+	}
+
+	output, err := t.call(ctx, input)
+	if err != nil || output == nil {
+		return logs.SearchResults{}, err
+	}
+
+	var res results
+	if err := json.Unmarshal(output, &res); err != nil {
+		return logs.SearchResults{}, fmt.Errorf("wasm module %s returned invalid results: %w", t.config.Path, err)
+	}
+	if res.Error != "" {
+		return logs.SearchResults{}, errors.New(res.Error)
+	}
+	return res.SearchResults, nil
+}
+
+// call passes the input to the search function and returns a copy of its output, nil without results
+func (t *WASMSearch) call(ctx context.Context, input []byte) ([]byte, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.module == nil {
+		if err := t.instantiate(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	ptr, err := write(ctx, t.module, t.allocate, input)
+	if err == nil {
+		var returned []uint64
+		if returned, err = t.search.Call(ctx, uint64(ptr), uint64(len(input))); err == nil {
+			if returned[0] == 0 {
+				return nil, nil
+			}
+
+			outPtr, outLen := uint32(returned[0]>>32), uint32(returned[0])
+			view, ok := t.module.Memory().Read(outPtr, outLen)
+			if !ok {
+				return nil, fmt.Errorf("output %d+%d is out of the memory", outPtr, outLen)
+			}
+			// The view is only valid until the next call
+			return append([]byte(nil), view...), nil
+		}
+	}
+
+	if ctx.Err() != nil {
+		// The instance was closed by the interruption, it's instantiated again by the next search
+		t.module.Close(context.Background())
+		t.module = nil
+		return nil, fmt.Errorf("the search of wasm module %s didn't complete before the deadline: %w", t.config.Path, ctx.Err())
+	}
+	return nil, fmt.Errorf("error calling wasm module %s: %w", t.config.Path, err)
+}
+
+// write writes the data to a buffer allocated by the module
+func write(ctx context.Context, module api.Module, allocate api.Function, data []byte) (uint32, error) {
+	allocated, err := allocate.Call(ctx, uint64(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("error allocating the input: %w", err)
+	}
+	ptr := uint32(allocated[0])
+	if !module.Memory().Write(ptr, data) {
+		return 0, fmt.Errorf("allocated buffer %d is out of the memory", ptr)
+	}
+	return ptr, nil
+}
+
+func (t *WASMSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the module, the module receives the full search params
+func (t *WASMSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
@@ -0,0 +1,214 @@
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+// wasmModule assembles a module exporting its memory, allocate returning a buffer at 1024, search with the given body
+// and configure with the given body, unless empty. The data is written at the start of the memory.
+func wasmModule(search, configure []byte, data string) []byte {
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+
+	// (i32) -> i32, (i32, i32) -> i64 and (i32, i32) -> i32
+	module = append(module, wasmSection(1, []byte{0x03, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f})...)
+	functions := []byte{0x02, 0x00, 0x01}
+	if configure != nil {
+		functions = []byte{0x03, 0x00, 0x01, 0x02}
+	}
+	module = append(module, wasmSection(3, functions)...)
+	module = append(module, wasmSection(5, []byte{0x01, 0x00, 0x01})...)
+
+	exports := []byte{0x03}
+	exports = append(exports, wasmName("memory", 0x02, 0)...)
+	exports = append(exports, wasmName("allocate", 0x00, 0)...)
+	exports = append(exports, wasmName("search", 0x00, 1)...)
+	if configure != nil {
+		exports[0] = 0x04
+		exports = append(exports, wasmName("configure", 0x00, 2)...)
+	}
+	module = append(module, wasmSection(7, exports)...)
+
+	allocate := []byte{0x00, 0x41, 0x80, 0x08, 0x0b}
+	code := []byte{functions[0]}
+	code = append(code, wasmVector(allocate)...)
+	code = append(code, wasmVector(search)...)
+	if configure != nil {
+		code = append(code, wasmVector(configure)...)
+	}
+	module = append(module, wasmSection(10, code)...)
+
+	if data != "" {
+		segment := []byte{0x01, 0x00, 0x41, 0x00, 0x0b}
+		segment = append(segment, wasmVector([]byte(data))...)
+		module = append(module, wasmSection(11, segment)...)
+	}
+	return module
+}
+
+func wasmSection(id byte, content []byte) []byte {
+	return append([]byte{id}, wasmVector(content)...)
+}
+
+func wasmVector(content []byte) []byte {
+	return append(uleb128(uint64(len(content))), content...)
+}
+
+func wasmName(name string, kind, index byte) []byte {
+	return append(wasmVector([]byte(name)), kind, index)
+}
+
+func uleb128(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		if v >>= 7; v != 0 {
+			out = append(out, b|0x80)
+			continue
+		}
+		return append(out, b)
+	}
+}
+
+// sleb128 encodes the positive value of a const instruction
+func sleb128(v uint64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && b&0x40 == 0 {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+// wasmConstant returns a search body returning the data written at the start of the memory,
+// after looping forever when the input is longer than loopAbove, unless 0
+func wasmConstant(data string, loopAbove int) []byte {
+	body := []byte{0x00}
+	if loopAbove > 0 {
+		// if (len > loopAbove) { loop { br 0 } }
+		body = append(body, 0x20, 0x01, 0x41)
+		body = append(body, sleb128(uint64(loopAbove))...)
+		body = append(body, 0x4b, 0x04, 0x40, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b)
+	}
+	body = append(body, 0x42)
+	body = append(body, sleb128(uint64(len(data)))...)
+	return append(body, 0x0b)
+}
+
+var (
+	// search returns 0
+	wasmEmpty = []byte{0x00, 0x42, 0x00, 0x0b}
+
+	// configure succeeds, or fails
+	wasmConfigured = []byte{0x00, 0x41, 0x00, 0x0b}
+	wasmFailing    = []byte{0x00, 0x41, 0x01, 0x0b}
+)
+
+func writeModule(t *testing.T, module []byte) string {
+	path := filepath.Join(t.TempDir(), "module.wasm")
+	if err := os.WriteFile(path, module, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestWASMSearch(t *testing.T) {
+	found := `{"total":1,"results":[{"message":"from wasm","labels":{"wasm":"true"}}],"nextPage":"2"}`
+
+	tests := []struct {
+		name    string
+		module  []byte
+		want    logs.SearchResults
+		wantErr bool
+	}{
+		{
+			name:   "results",
+			module: wasmModule(wasmConstant(found, 0), wasmConfigured, found),
+			want:   logs.SearchResults{Total: 1, Results: []logs.Result{{Message: "from wasm", Labels: map[string]string{"wasm": "true"}}}, NextPage: "2"},
+		},
+		{
+			name:   "no results",
+			module: wasmModule(wasmEmpty, wasmConfigured, ""),
+		},
+		{
+			name:    "error",
+			module:  wasmModule(wasmConstant(`{"error":"backend unavailable"}`, 0), wasmConfigured, `{"error":"backend unavailable"}`),
+			wantErr: true,
+		},
+		{
+			name:    "invalid output",
+			module:  wasmModule(wasmConstant("not json", 0), wasmConfigured, "not json"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend, err := NewWASMSearchBackend(&logs.WASMBackendConfig{Path: writeModule(t, tt.module), Settings: `{"region": "eu-west-1"}`})
+			if err != nil {
+				t.Fatalf("NewWASMSearchBackend() error = %v", err)
+			}
+
+			res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "error"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Search() error = %v, want an error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Errorf("Search() = %+v, want %+v", res, tt.want)
+			}
+		})
+	}
+}
+
+func TestWASMSearch_Timeout(t *testing.T) {
+	found := `{"total":0}`
+	short, err := json.Marshal(&logs.SearchParams{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend, err := NewWASMSearchBackend(&logs.WASMBackendConfig{
+		Path:    writeModule(t, wasmModule(wasmConstant(found, len(short)+10), nil, found)),
+		Timeout: "100ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{Query: strings.Repeat("x", 20)}); err == nil {
+		t.Error("Search() looping forever succeeded")
+	}
+	// The module is instantiated again after the interruption
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{}); err != nil {
+		t.Errorf("Search() after a timeout error = %v", err)
+	}
+}
+
+func TestNewWASMSearchBackend(t *testing.T) {
+	dir := t.TempDir()
+	noExports := filepath.Join(dir, "empty.wasm")
+	if err := os.WriteFile(noExports, []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, config := range []logs.WASMBackendConfig{
+		{},
+		{Path: filepath.Join(dir, "missing.wasm")},
+		{Path: noExports},
+		{Path: writeModule(t, wasmModule(wasmEmpty, wasmFailing, "")), Settings: "{}"},
+		{Path: writeModule(t, wasmModule(wasmEmpty, nil, "")), Settings: "{}"},
+	} {
+		if _, err := NewWASMSearchBackend(&config); err == nil {
+			t.Errorf("NewWASMSearchBackend(%+v) expected an error", config)
+		}
+	}
+}
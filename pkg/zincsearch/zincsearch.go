@@ -0,0 +1,106 @@
+// Package zincsearch searches the indexes of ZincSearch with its Elasticsearch compatible search api
+package zincsearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"text/template"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	pkgElasticsearch "github.com/flanksource/apm-hub/external/elasticsearch"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/kommons"
+)
+
+type ZincSearchBackend struct {
+	client   *rest.Client
+	fields   logs.ElasticSearchFields
+	template *template.Template
+	config   *logs.ZincSearchBackendConfig
+}
+
+func NewZincSearchBackend(kClient *kommons.Client, config *logs.ZincSearchBackendConfig) (*ZincSearchBackend, error) {
+	if config.Index == "" {
+		return nil, fmt.Errorf("index is empty")
+	}
+
+	template, err := template.New("query").Parse(config.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing template: %w", err)
+	}
+
+	client, err := rest.NewClient(kClient, config.HTTPConnection)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ZincSearchBackend{
+		client:   client,
+		fields:   config.Fields,
+		template: template,
+		config:   config,
+	}, nil
+}
+
+func (t *ZincSearchBackend) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+func (t *ZincSearchBackend) Capabilities() logs.Capabilities {
+	return logs.Capabilities{Query: true, Pagination: true}
+}
+
+// HealthCheck gets the health of the server
+func (t *ZincSearchBackend) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/healthz", nil, nil)
+}
+
+// RenderQuery renders the query template for the given search params,
+// with one more hit than the limit to tell if there's a next page.
+func (t *ZincSearchBackend) RenderQuery(q *logs.SearchParams) (string, error) {
+	var buf bytes.Buffer
+	if err := t.template.Execute(&buf, q); err != nil {
+		return "", fmt.Errorf("error executing template: %w", err)
+	}
+
+	// ZincSearch takes the size in the body only
+	body := make(map[string]any)
+	if err := json.Unmarshal(buf.Bytes(), &body); err != nil {
+		return "", fmt.Errorf("error parsing the query: %w", err)
+	}
+	body["size"] = q.Limit + 1
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling the query: %w", err)
+	}
+	return string(data), nil
+}
+
+func (t *ZincSearchBackend) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	var result logs.SearchResults
+	query, err := t.RenderQuery(q)
+	if err != nil {
+		return result, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.client.URL("/es/"+url.PathEscape(t.config.Index)+"/_search", nil), bytes.NewBufferString(query))
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	var r pkgElasticsearch.SearchResponse
+	if err := t.client.DoJSON(req, &r); err != nil {
+		return result, fmt.Errorf("error searching: %w", err)
+	}
+
+	result.Results = r.Hits.GetResultsFromHits(q.Limit, t.fields.Message, t.fields.Timestamp, t.config.Labels, t.fields.Exclusions...)
+	result.Total = int(r.Hits.Total.Value)
+	result.NextPage = r.Hits.NextPage(int(q.Limit))
+	return result, nil
+}
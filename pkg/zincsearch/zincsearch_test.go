@@ -0,0 +1,64 @@
+package zincsearch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/kommons"
+)
+
+func TestZincSearchBackend(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/es/logs-shop/_search" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"took":1,"timed_out":false,"hits":{"total":{"value":3},"hits":[
+			{"_index":"logs-shop","_id":"z2","sort":["2023-05-01T12:00:01Z"],"_source":{"@timestamp":"2023-05-01T12:00:01Z","log":"connection timeout","pod":"api-0","stream":"stderr"}},
+			{"_index":"logs-shop","_id":"z1","sort":["2023-05-01T12:00:00Z"],"_source":{"@timestamp":"2023-05-01T12:00:00Z","log":"GET /","pod":"api-1","stream":"stdout"}}
+		]}}`))
+	}))
+	defer server.Close()
+
+	backend, err := NewZincSearchBackend(nil, &logs.ZincSearchBackendConfig{
+		CommonBackend: logs.CommonBackend{Labels: map[string]string{"source": "zinc"}},
+		HTTPConnection: logs.HTTPConnection{
+			URL:      server.URL,
+			Username: &kommons.EnvVar{Value: "admin"},
+			Password: &kommons.EnvVar{Value: "secret"},
+		},
+		Index:  "logs-shop",
+		Query:  `{"query": {"match": {"log": "{{.Query}}"}}, "sort": ["-@timestamp"]}`,
+		Fields: logs.ElasticSearchFields{Message: "log", Timestamp: "@timestamp", Exclusions: []string{"stream"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Query: "timeout", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logs.SearchResults{Total: 3, NextPage: `["2023-05-01T12:00:01Z"]`, Results: []logs.Result{
+		{Id: "z2", Time: "2023-05-01T12:00:01Z", Message: "connection timeout", Labels: map[string]string{"pod": "api-0", "source": "zinc"}},
+	}}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Search() = %+v, want %+v", res, want)
+	}
+
+	wantBody := map[string]any{"query": map[string]any{"match": map[string]any{"log": "timeout"}}, "sort": []any{"-@timestamp"}, "size": float64(2)}
+	if !reflect.DeepEqual(body, wantBody) {
+		t.Errorf("query = %v, want %v", body, wantBody)
+	}
+}
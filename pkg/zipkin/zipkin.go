@@ -0,0 +1,158 @@
+// Package zipkin searches the spans of the Zipkin api
+package zipkin
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/apm-hub/api/logs"
+	"github.com/flanksource/apm-hub/pkg/rest"
+	"github.com/flanksource/apm-hub/pkg/traces"
+	"github.com/flanksource/kommons"
+)
+
+type ZipkinSearch struct {
+	config *logs.ZipkinBackendConfig
+	client *rest.Client
+}
+
+func NewZipkinSearchBackend(kClient *kommons.Client, config *logs.ZipkinBackendConfig) (*ZipkinSearch, error) {
+	client, err := rest.NewClient(kClient, config.HTTPConnection)
+	if err != nil {
+		return nil, err
+	}
+	return &ZipkinSearch{config: config, client: client}, nil
+}
+
+// Search returns the spans of the trace of the search, see traces.TraceID,
+// otherwise the spans of the traces of the service in the time range
+func (t *ZipkinSearch) Search(ctx context.Context, q *logs.SearchParams) (logs.SearchResults, error) {
+	traceID := traces.TraceID(q)
+
+	var spans []traces.Span
+	if traceID != "" {
+		var trace []span
+		if err := t.client.Get(ctx, "/api/v2/trace/"+url.PathEscape(traceID), nil, &trace); err != nil {
+			return logs.SearchResults{}, err
+		}
+		for _, s := range trace {
+			spans = append(spans, s.span())
+		}
+	} else {
+		query, err := t.query(q)
+		if err != nil {
+			return logs.SearchResults{}, err
+		}
+		var res [][]span
+		if err := t.client.Get(ctx, "/api/v2/traces", query, &res); err != nil {
+			return logs.SearchResults{}, err
+		}
+		for _, trace := range res {
+			for _, s := range trace {
+				spans = append(spans, s.span())
+			}
+		}
+	}
+	return traces.Results(spans, q.Limit, t.config.Labels), nil
+}
+
+// query returns the params of the search of the traces of the service
+func (t *ZipkinSearch) query(q *logs.SearchParams) (url.Values, error) {
+	service := q.Labels["service"]
+	if service == "" {
+		service = q.Id
+	}
+	if service == "" {
+		service = t.config.Service
+	}
+	if service == "" {
+		return nil, fmt.Errorf("the search requires a trace id or a service")
+	}
+
+	query := url.Values{}
+	query.Set("serviceName", service)
+	if operation := q.Labels["operation"]; operation != "" {
+		query.Set("spanName", operation)
+	}
+
+	var annotations []string
+	for _, tag := range t.config.Tags {
+		if v, ok := q.Labels[tag]; ok {
+			annotations = append(annotations, tag+"="+v)
+		}
+	}
+	if len(annotations) > 0 {
+		query.Set("annotationQuery", strings.Join(annotations, " and "))
+	}
+
+	end := time.Now()
+	if e := q.GetEnd(); e != nil {
+		end = *e
+	}
+	query.Set("endTs", strconv.FormatInt(end.UnixMilli(), 10))
+	if start := q.GetStart(); start != nil {
+		query.Set("lookback", strconv.FormatInt(end.Sub(*start).Milliseconds(), 10))
+	}
+	if q.Limit > 0 {
+		query.Set("limit", strconv.FormatInt(q.Limit, 10))
+	}
+	return query, nil
+}
+
+func (t *ZipkinSearch) MatchRoute(q *logs.SearchParams) (match bool, isAdditive bool) {
+	return t.config.CommonBackend.Routes.MatchRoute(q)
+}
+
+// Capabilities of the Zipkin backend, the spans are filtered by the query once returned
+func (t *ZipkinSearch) Capabilities() logs.Capabilities {
+	return logs.Capabilities{}
+}
+
+// HealthCheck lists the services
+func (t *ZipkinSearch) HealthCheck(ctx context.Context) error {
+	return t.client.Get(ctx, "/api/v2/services", nil, nil)
+}
+
+type span struct {
+	TraceID  string `json:"traceId"`
+	ID       string `json:"id"`
+	ParentID string `json:"parentId"`
+	Name     string `json:"name"`
+	// Timestamp and Duration are in microseconds
+	Timestamp     int64 `json:"timestamp"`
+	Duration      int64 `json:"duration"`
+	LocalEndpoint struct {
+		ServiceName string `json:"serviceName"`
+	} `json:"localEndpoint"`
+	Annotations []struct {
+		Timestamp int64  `json:"timestamp"`
+		Value     string `json:"value"`
+	} `json:"annotations"`
+	Tags map[string]string `json:"tags"`
+}
+
+func (s span) span() traces.Span {
+	_, failed := s.Tags["error"]
+	result := traces.Span{
+		TraceID:   s.TraceID,
+		SpanID:    s.ID,
+		ParentID:  s.ParentID,
+		Service:   s.LocalEndpoint.ServiceName,
+		Operation: s.Name,
+		Start:     time.UnixMicro(s.Timestamp),
+		Duration:  time.Duration(s.Duration) * time.Microsecond,
+		Error:     failed,
+		Tags:      s.Tags,
+	}
+	for _, annotation := range s.Annotations {
+		result.Events = append(result.Events, traces.Event{
+			Time: time.UnixMicro(annotation.Timestamp),
+			Name: annotation.Value,
+		})
+	}
+	return result
+}
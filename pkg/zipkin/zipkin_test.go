@@ -0,0 +1,86 @@
+package zipkin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/flanksource/apm-hub/api/logs"
+)
+
+const traceResponse = `[
+	{"traceId": "4bf92f3577b34da6", "id": "b", "parentId": "a", "name": "select orders", "timestamp": 1682942400100000, "duration": 2500,
+	 "localEndpoint": {"serviceName": "orders-db"}, "tags": {"db.system": "postgresql", "error": "timeout"}},
+	{"traceId": "4bf92f3577b34da6", "id": "a", "name": "get /orders", "timestamp": 1682942400000000, "duration": 120000,
+	 "localEndpoint": {"serviceName": "frontend"}, "annotations": [{"timestamp": 1682942400050000, "value": "retry"}]}
+]`
+
+func TestZipkinSearch(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		requests = append(requests, r.URL.Path+"?"+query.Get("serviceName")+"|"+query.Get("spanName")+"|"+query.Get("annotationQuery")+"|"+query.Get("lookback"))
+		if r.URL.Path == "/api/v2/traces" {
+			w.Write([]byte("[" + traceResponse + "]"))
+			return
+		}
+		w.Write([]byte(traceResponse))
+	}))
+	defer server.Close()
+
+	backend, err := NewZipkinSearchBackend(nil, &logs.ZipkinBackendConfig{
+		CommonBackend:  logs.CommonBackend{Labels: map[string]string{"cluster": "prod"}},
+		HTTPConnection: logs.HTTPConnection{URL: server.URL},
+		Tags:           []string{"http.status_code", "error"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := backend.Search(context.Background(), &logs.SearchParams{Id: "4bf92f3577b34da6", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []logs.Result{
+		{Id: "a", Time: "2023-05-01T12:00:00Z", Message: "frontend get /orders 120ms", Labels: map[string]string{
+			"trace_id": "4bf92f3577b34da6", "span_id": "a", "service": "frontend", "operation": "get /orders",
+			"duration": "120ms", "status": "ok", "cluster": "prod",
+		}},
+		{Id: "a-0", Time: "2023-05-01T12:00:00.05Z", Message: "frontend get /orders retry", Labels: map[string]string{
+			"trace_id": "4bf92f3577b34da6", "span_id": "a", "service": "frontend", "operation": "get /orders",
+			"event": "retry", "cluster": "prod",
+		}},
+		{Id: "b", Time: "2023-05-01T12:00:00.1Z", Message: "orders-db select orders 2.5ms error", Labels: map[string]string{
+			"trace_id": "4bf92f3577b34da6", "span_id": "b", "parent_span_id": "a", "service": "orders-db", "operation": "select orders",
+			"duration": "2.5ms", "status": "error", "db.system": "postgresql", "error": "timeout", "cluster": "prod",
+		}},
+	}
+	if !reflect.DeepEqual(res.Results, want) {
+		t.Errorf("Search() = %+v, want %+v", res.Results, want)
+	}
+
+	res, err = backend.Search(context.Background(), &logs.SearchParams{
+		Id:     "frontend",
+		Labels: map[string]string{"operation": "get /orders", "http.status_code": "500", "error": "timeout"},
+		Start:  "2023-05-01T11:00:00Z",
+		End:    "2023-05-01T12:00:00Z",
+		Limit:  1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 1 || res.Total != 3 {
+		t.Errorf("Search() returned %d results of %d, want 1 of 3", len(res.Results), res.Total)
+	}
+
+	wantRequests := []string{"/api/v2/trace/4bf92f3577b34da6?|||", "/api/v2/traces?frontend|get /orders|http.status_code=500 and error=timeout|3600000"}
+	if !reflect.DeepEqual(requests, wantRequests) {
+		t.Errorf("requests = %v, want %v", requests, wantRequests)
+	}
+
+	if _, err := backend.Search(context.Background(), &logs.SearchParams{}); err == nil {
+		t.Errorf("expected an error without a trace id or a service")
+	}
+}